@@ -51,6 +51,17 @@ func NewCommand() *cobra.Command {
 	return etcdCmd
 }
 
+// Secrets and configmaps are backed up from every namespace of the cluster, so they must be listable cluster-wide.
+var requiredPermissions = []utilk8s.Permission{
+	{Verb: "list", Resource: "namespaces"},
+	{Verb: "list", Resource: "secrets"},
+	{Verb: "list", Resource: "configmaps"},
+	{Verb: "list", Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"},
+	{Verb: "list", Group: "rbac.authorization.k8s.io", Resource: "clusterroles"},
+	{Verb: "list", Group: "rbac.authorization.k8s.io", Resource: "clusterrolebindings"},
+	{Verb: "list", Group: "storage.k8s.io", Resource: "storageclasses"},
+}
+
 type BackupStage struct {
 	payload BackupFunc
 	filter  tarball.BackupResourcesFilter
@@ -72,6 +83,10 @@ func backupConfigs(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if err = utilk8s.CheckPermissions(context.Background(), kubeCl, requiredPermissions...); err != nil {
+		return err
+	}
+
 	namespaces, err := getNamespacesFromCluster(kubeCl)
 	if err != nil {
 		return err
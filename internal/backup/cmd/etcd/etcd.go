@@ -72,6 +72,12 @@ const (
 	bufferSize16MB = 16 * 1024 * 1024
 )
 
+var requiredPermissions = []utilk8s.Permission{
+	{Verb: "list", Resource: "pods", Namespace: etcdPodNamespace},
+	{Verb: "get", Resource: "pods", Namespace: etcdPodNamespace},
+	{Verb: "create", Resource: "pods", Subresource: "exec", Namespace: etcdPodNamespace},
+}
+
 var (
 	requestedEtcdPodName string
 
@@ -94,6 +100,10 @@ func etcd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Failed to setup Kubernetes client: %w", err)
 	}
 
+	if err = utilk8s.CheckPermissions(context.Background(), kubeCl, requiredPermissions...); err != nil {
+		return err
+	}
+
 	etcdPods, err := findETCDPods(kubeCl)
 	if err != nil {
 		return fmt.Errorf("Looking up etcd pods failed: %w", err)
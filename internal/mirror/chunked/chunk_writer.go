@@ -17,8 +17,8 @@ limitations under the License.
 package chunked
 
 import (
-	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -27,9 +27,10 @@ type FileWriter struct {
 	chunkSize  int64
 	chunkIndex int
 
-	workingDir   string
-	baseFileName string
-	activeChunk  *os.File
+	workingDir      string
+	baseFileName    string
+	activeChunk     *os.File
+	activeChunkSize int64
 }
 
 func NewChunkedFileWriter(chunkSize int64, dirPath, baseFileName string) *FileWriter {
@@ -40,51 +41,73 @@ func NewChunkedFileWriter(chunkSize int64, dirPath, baseFileName string) *FileWr
 	}
 }
 
-func (c *FileWriter) Write(p []byte) (int, error) {
-	if len(p) == 0 {
-		return 0, nil
+// NewResumedChunkedFileWriter returns FileWriter that continues interrupted write of the file with baseFileName from offset.
+// Chunks left by interrupted write are kept up to offset, the chunk offset falls into is cut there and the rest of them are removed.
+func NewResumedChunkedFileWriter(chunkSize int64, dirPath, baseFileName string, offset int64) (*FileWriter, error) {
+	c := NewChunkedFileWriter(chunkSize, dirPath, baseFileName)
+	chunks, err := FindChunks(c.workingDir, baseFileName)
+	if err != nil {
+		return nil, fmt.Errorf("Find chunks to resume: %w", err)
 	}
 
-	if c.activeChunk == nil {
-		if err := c.swapActiveChunk(); err != nil {
-			return 0, fmt.Errorf("Create first chunk: %w", err)
+	for i, chunkPath := range chunks {
+		if offset == 0 || c.activeChunk != nil {
+			if err = os.Remove(chunkPath); err != nil {
+				return nil, fmt.Errorf("Remove chunk after resume offset: %w", err)
+			}
+			continue
 		}
-	}
 
-	chunkStat, err := c.activeChunk.Stat()
-	if err != nil {
-		return 0, fmt.Errorf("Read chunk size: %w", err)
+		if _, index, _ := ParseChunkName(filepath.Base(chunkPath)); index != i {
+			return nil, fmt.Errorf("Chunk %s is missing", ChunkName(baseFileName, i))
+		}
+		chunkStat, err := os.Stat(chunkPath)
+		if err != nil {
+			return nil, fmt.Errorf("Read chunk size: %w", err)
+		}
+		if offset > chunkStat.Size() || (offset == chunkStat.Size() && offset >= chunkSize) {
+			offset -= chunkStat.Size()
+			c.chunkIndex = i + 1
+			continue
+		}
+
+		c.activeChunk, err = os.OpenFile(chunkPath, os.O_WRONLY, 0o666)
+		if err != nil {
+			return nil, fmt.Errorf("Open chunk to resume: %w", err)
+		}
+		if err = c.activeChunk.Truncate(offset); err != nil {
+			return nil, fmt.Errorf("Cut chunk at resume offset: %w", err)
+		}
+		if _, err = c.activeChunk.Seek(offset, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("Cut chunk at resume offset: %w", err)
+		}
+		c.chunkIndex, c.activeChunkSize, offset = i, offset, 0
 	}
+	if offset > 0 {
+		return nil, fmt.Errorf("Chunks end %d bytes before resume offset", offset)
+	}
+	return c, nil
+}
 
-	buf := bytes.NewBuffer(p)
+func (c *FileWriter) Write(p []byte) (int, error) {
 	bytesWritten := 0
-	for {
-		for c.chunkSize-chunkStat.Size() > 0 {
-			s := buf.Next(512 * 1024)
-			if len(s) == 0 {
-				return bytesWritten, nil
-			}
-
-			written, err := c.activeChunk.Write(s)
-			bytesWritten += written
-			if err != nil {
-				return 0, fmt.Errorf("Write to chunk: %w", err)
-			}
-
-			chunkStat, err = c.activeChunk.Stat()
-			if err != nil {
-				return 0, fmt.Errorf("Read chunk size: %w", err)
+	for len(p) > 0 {
+		if c.activeChunk == nil || c.activeChunkSize >= c.chunkSize {
+			if err := c.swapActiveChunk(); err != nil {
+				return bytesWritten, fmt.Errorf("Swap active chunk: %w", err)
 			}
 		}
 
-		if err = c.swapActiveChunk(); err != nil {
-			return 0, fmt.Errorf("Swap active chunk: %w", err)
-		}
-		chunkStat, err = c.activeChunk.Stat()
+		part := p[:min(int64(len(p)), c.chunkSize-c.activeChunkSize, 512*1024)]
+		written, err := c.activeChunk.Write(part)
+		bytesWritten += written
+		c.activeChunkSize += int64(written)
 		if err != nil {
-			return 0, fmt.Errorf("Read chunk size: %w", err)
+			return bytesWritten, fmt.Errorf("Write to chunk: %w", err)
 		}
+		p = p[written:]
 	}
+	return bytesWritten, nil
 }
 
 func (c *FileWriter) Close() error {
@@ -99,12 +122,13 @@ func (c *FileWriter) swapActiveChunk() error {
 		c.chunkIndex += 1
 	}
 
-	newChunk, err := os.Create(filepath.Join(c.workingDir, fmt.Sprintf("%s.%04d.chunk", c.baseFileName, c.chunkIndex)))
+	newChunk, err := os.Create(filepath.Join(c.workingDir, ChunkName(c.baseFileName, c.chunkIndex)))
 	if err != nil {
 		return fmt.Errorf("Create new chunk file: %w", err)
 	}
 
 	c.activeChunk = newChunk
+	c.activeChunkSize = 0
 	return nil
 }
 
@@ -119,3 +119,61 @@ func validateSizes(t *testing.T, workingDir string, totalSize, chunkSize int) {
 		require.Equal(t, int64(lastChunkSize), s.Size())
 	}
 }
+
+func TestResumedChunkedFileWriter(t *testing.T) {
+	const chunkSize = 256 * 1024
+	sourceFile := make([]byte, 5*chunkSize/2)
+	_, err := rand.Reader.Read(sourceFile)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		interrupted, resumeOffset int
+	}{
+		"resumed within chunk":           {interrupted: chunkSize + 1000, resumeOffset: chunkSize + 512},
+		"resumed at chunk border":        {interrupted: 2*chunkSize + 1000, resumeOffset: 2 * chunkSize},
+		"resumed at the end of chunks":   {interrupted: chunkSize + 1000, resumeOffset: chunkSize + 1000},
+		"resumed from the start":         {interrupted: 2*chunkSize + 1000, resumeOffset: 0},
+		"interrupted write was complete": {interrupted: len(sourceFile), resumeOffset: len(sourceFile) - 100},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			workingDir := t.TempDir()
+			interruptedWriter := NewChunkedFileWriter(chunkSize, workingDir, "d8.tar")
+			_, err := interruptedWriter.Write(sourceFile[:tt.interrupted])
+			require.NoError(t, err)
+			require.NoError(t, interruptedWriter.Close())
+
+			writer, err := NewResumedChunkedFileWriter(chunkSize, workingDir, "d8.tar", int64(tt.resumeOffset))
+			require.NoError(t, err)
+			_, err = io.Copy(writer, bytes.NewReader(sourceFile[tt.resumeOffset:]))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			validateSizes(t, workingDir, len(sourceFile), chunkSize)
+			compareHashes(t, sourceFile, len(sourceFile), workingDir)
+		})
+	}
+}
+
+func TestResumedChunkedFileWriterRejectsOffsetPastChunks(t *testing.T) {
+	workingDir := t.TempDir()
+	_, err := NewChunkedFileWriter(1024, workingDir, "d8.tar").Write(make([]byte, 1500))
+	require.NoError(t, err)
+
+	_, err = NewResumedChunkedFileWriter(1024, workingDir, "d8.tar", 2000)
+	require.Error(t, err)
+}
+
+func TestFindChunksSkipsChunksOfOtherFiles(t *testing.T) {
+	workingDir := t.TempDir()
+	for _, fileName := range []string{"d8.tar.0001.chunk", "d8.tar.0000.chunk", "d8.tar.old.0000.chunk", "ee-d8.tar.0000.chunk", "d8.tar.gostsum"} {
+		require.NoError(t, os.WriteFile(filepath.Join(workingDir, fileName), nil, 0o644))
+	}
+
+	chunks, err := FindChunks(workingDir, "d8.tar")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(workingDir, "d8.tar.0000.chunk"),
+		filepath.Join(workingDir, "d8.tar.0001.chunk"),
+	}, chunks)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chunked
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const chunkExtension = ".chunk"
+
+// ChunkName returns file name of chunk with index of the file with baseFileName.
+func ChunkName(baseFileName string, index int) string {
+	return fmt.Sprintf("%s.%04d%s", baseFileName, index, chunkExtension)
+}
+
+// ParseChunkName splits chunk file name produced by ChunkName back into the name of the file it is a chunk of and its index.
+func ParseChunkName(fileName string) (string, int, bool) {
+	name, isChunk := strings.CutSuffix(fileName, chunkExtension)
+	if !isChunk {
+		return "", 0, false
+	}
+	dot := strings.LastIndexByte(name, '.')
+	if dot <= 0 {
+		return "", 0, false
+	}
+	digits := name[dot+1:]
+	if len(digits) < 4 || strings.Trim(digits, "0123456789") != "" {
+		return "", 0, false
+	}
+	index, err := strconv.Atoi(digits)
+	if err != nil {
+		return "", 0, false
+	}
+	return name[:dot], index, true
+}
+
+// FindChunks returns paths to chunks of the file with baseFileName in dir, ordered by chunk index.
+// Chunks of other files in the same dir are never returned, even if names of those files start with baseFileName.
+func FindChunks(dir, baseFileName string) ([]string, error) {
+	catalog, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make(map[string]int)
+	chunks := make([]string, 0)
+	for _, entry := range catalog {
+		chunkOf, index, isChunk := ParseChunkName(entry.Name())
+		if !entry.Type().IsRegular() || !isChunk || chunkOf != baseFileName {
+			continue
+		}
+		chunkPath := filepath.Join(dir, entry.Name())
+		indexes[chunkPath] = index
+		chunks = append(chunks, chunkPath)
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		return indexes[chunks[i]] < indexes[chunks[j]]
+	})
+	return chunks, nil
+}
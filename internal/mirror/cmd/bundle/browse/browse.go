@@ -0,0 +1,254 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package browse
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+)
+
+var browseLong = templates.LongDesc(`
+Browse contents of Deckhouse Kubernetes Platform distribution bundle without unpacking it.
+
+This command opens interactive shell to navigate bundle repositories, their tags and image layers
+with sizes and digests, and to search for images by repository and tag.
+Type "help" in the shell for the list of commands.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+func NewCommand() *cobra.Command {
+	browseCmd := &cobra.Command{
+		Use:           "browse <images-bundle-path>",
+		Short:         "Browse contents of Deckhouse Kubernetes Platform distribution bundle",
+		Long:          browseLong,
+		ValidArgs:     []string{"images-bundle-path"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          browse,
+	}
+
+	addFlags(browseCmd.Flags())
+	return browseCmd
+}
+
+var (
+	ImagesBundlePath string
+	FindQuery        string
+)
+
+const browseHelp = `Commands:
+  ls [path]      list repositories and tags at path, or layers of a tag
+  cd <path>      change location, e.g. "cd modules/console", "cd v1.55.7", "cd ..", "cd /"
+  find <query>   list images whose repository:tag reference contains query
+  help           show this help
+  exit           leave the browser
+`
+
+func browse(cmd *cobra.Command, _ []string) error {
+	contents, err := bundle.ReadContents(ImagesBundlePath)
+	if err != nil {
+		return fmt.Errorf("Read bundle contents: %w", err)
+	}
+
+	b := &browser{contents: contents, out: output.FromCommand(cmd).Data()}
+	if FindQuery != "" {
+		if !b.find(FindQuery) {
+			return fmt.Errorf("No images matching %q found in bundle", FindQuery)
+		}
+		return nil
+	}
+
+	fmt.Fprint(b.out, browseHelp)
+	input := bufio.NewScanner(cmd.InOrStdin())
+	for {
+		fmt.Fprintf(b.out, "%s:/%s> ", path.Base(ImagesBundlePath), b.location())
+		if !input.Scan() {
+			fmt.Fprintln(b.out)
+			return input.Err()
+		}
+
+		command, arg, _ := strings.Cut(strings.TrimSpace(input.Text()), " ")
+		arg = strings.TrimSpace(arg)
+		switch command {
+		case "":
+		case "ls":
+			b.list(arg)
+		case "cd":
+			b.changeLocation(arg)
+		case "find":
+			if !b.find(arg) {
+				fmt.Fprintf(b.out, "No images matching %q\n", arg)
+			}
+		case "help":
+			fmt.Fprint(b.out, browseHelp)
+		case "exit", "quit":
+			return nil
+		default:
+			fmt.Fprintf(b.out, "Unknown command %q, type \"help\" for the list of commands\n", command)
+		}
+	}
+}
+
+type browser struct {
+	contents *bundle.Contents
+	out      io.Writer
+
+	// dir is the current path in repositories tree, tag is set once one of the tags in dir is opened.
+	dir string
+	tag string
+}
+
+func (b *browser) location() string {
+	if b.tag != "" {
+		return b.dir + ":" + b.tag
+	}
+	return b.dir
+}
+
+// resolve returns directory and tag that target refers to relative to current location.
+func (b *browser) resolve(target string) (dir, tag string, ok bool) {
+	switch {
+	case target == "":
+		return b.dir, b.tag, true
+	case strings.HasPrefix(target, "/"):
+		dir = strings.TrimPrefix(target, "/")
+	case target == ".." && b.tag != "":
+		return b.dir, "", true
+	default:
+		dir = path.Join(b.dir, target)
+	}
+
+	dir, tag, _ = strings.Cut(dir, ":")
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	if tag != "" {
+		repo := b.contents.Repository(dir)
+		return dir, tag, repo != nil && repo.Tag(tag) != nil
+	}
+	if b.isDir(dir) {
+		return dir, "", true
+	}
+
+	// Tags are listed next to nested repositories, so "cd v1.55.7" opens tag of the current repository.
+	parent, name := path.Dir(dir), path.Base(dir)
+	if parent == "." {
+		parent = ""
+	}
+	if repo := b.contents.Repository(parent); repo != nil && repo.Tag(name) != nil {
+		return parent, name, true
+	}
+	return "", "", false
+}
+
+func (b *browser) isDir(dir string) bool {
+	for _, repo := range b.contents.Repositories {
+		if dir == "" || repo.Path == dir || strings.HasPrefix(repo.Path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *browser) changeLocation(target string) {
+	if target == "" {
+		target = "/"
+	}
+	dir, tag, ok := b.resolve(target)
+	if !ok {
+		fmt.Fprintf(b.out, "%s: not found\n", target)
+		return
+	}
+	b.dir, b.tag = dir, tag
+}
+
+func (b *browser) list(target string) {
+	dir, tag, ok := b.resolve(target)
+	if !ok {
+		fmt.Fprintf(b.out, "%s: not found\n", target)
+		return
+	}
+
+	w := tabwriter.NewWriter(b.out, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	repo := b.contents.Repository(dir)
+	if tag != "" {
+		t := repo.Tag(tag)
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.Digest, formatSize(t.Size), t.MediaType)
+		for _, layer := range t.Layers {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", layer.Digest, formatSize(layer.Size), layer.MediaType)
+		}
+		return
+	}
+
+	for _, child := range b.childDirs(dir) {
+		fmt.Fprintf(w, "%s/\t\t\n", child)
+	}
+	if repo != nil {
+		for _, t := range repo.Tags {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, formatSize(t.Size), t.Digest)
+		}
+	}
+}
+
+func (b *browser) childDirs(dir string) []string {
+	children := make([]string, 0)
+	prefix := dir + "/"
+	if dir == "" {
+		prefix = ""
+	}
+	for _, repo := range b.contents.Repositories {
+		rest, found := strings.CutPrefix(repo.Path, prefix)
+		if !found || rest == "" {
+			continue
+		}
+		child, _, _ := strings.Cut(rest, "/")
+		if !slices.Contains(children, child) {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+func (b *browser) find(query string) bool {
+	found := b.contents.Find(query)
+	w := tabwriter.NewWriter(b.out, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	for _, ref := range found {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", ref, formatSize(ref.Tag.Size), ref.Tag.Digest)
+	}
+	return len(found) > 0
+}
+
+func formatSize(size int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(size)/1024/1024)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/bundle/browse"
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/bundle/gc"
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/bundle/inspect"
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/bundle/sign"
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/bundle/stats"
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/bundle/verify"
+	verifysignature "github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/bundle/verify-signature"
+)
+
+var bundleLong = templates.LongDesc(`
+Manage Deckhouse Kubernetes Platform distribution bundles produced by d8 mirror pull.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+func NewCommand() *cobra.Command {
+	bundleCmd := &cobra.Command{
+		Use:           "bundle",
+		Short:         "Manage Deckhouse Kubernetes Platform distribution bundles",
+		Long:          bundleLong,
+		SilenceErrors: true,
+	}
+
+	bundleCmd.AddCommand(
+		browse.NewCommand(),
+		gc.NewCommand(),
+		inspect.NewCommand(),
+		sign.NewCommand(),
+		stats.NewCommand(),
+		verify.NewCommand(),
+		verifysignature.NewCommand(),
+	)
+
+	return bundleCmd
+}
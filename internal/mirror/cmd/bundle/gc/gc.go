@@ -0,0 +1,86 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gc
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+)
+
+var gcLong = templates.LongDesc(`
+Remove orphaned blobs from unpacked Deckhouse Kubernetes Platform distribution bundle.
+
+Interrupted pulls leave partially written blobs and blobs of images that never made it into layout indexes
+in the working directory of d8 mirror pull. Every OCI layout under the given directory is scanned,
+and blobs that are not referenced by its index or by manifests and indexes it references are removed.
+
+d8 mirror pull does the same automatically before packing the bundle.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+var gcExample = templates.Examples(`
+# Clean up working directory of interrupted pull
+d8 mirror bundle gc /tmp/mirror/pull/8b1a9953c4611296a827abf8c47804d7
+
+# Clean up bundle pulled with --no-pack
+d8 mirror bundle gc /opt/d8-bundle
+`)
+
+func NewCommand() *cobra.Command {
+	gcCmd := &cobra.Command{
+		Use:           "gc <dir>",
+		Short:         "Remove blobs not referenced by any index or manifest from unpacked bundle",
+		Long:          gcLong,
+		Example:       gcExample,
+		ValidArgs:     []string{"dir"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          gc,
+	}
+
+	return gcCmd
+}
+
+var UnpackedImagesPath string
+
+func gc(cmd *cobra.Command, _ []string) error {
+	out := output.FromCommand(cmd)
+
+	var result *bundle.GCResult
+	err := out.Logger().Process("Collect garbage", func() error {
+		var err error
+		result, err = bundle.CollectGarbage(UnpackedImagesPath)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out.Data(), "%s: %d layouts, %d orphaned blobs removed, %.1f MiB reclaimed\n",
+		UnpackedImagesPath, result.Layouts, result.RemovedBlobs, float64(result.ReclaimedSize)/1024/1024)
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+)
+
+var inspectLong = templates.LongDesc(`
+Describe what Deckhouse Kubernetes Platform distribution bundle delivers without unpacking it.
+
+Deckhouse releases, the releases that release channels point to, modules with their versions,
+vulnerability databases and the number of tags and size of every repository in the bundle are printed.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+var inspectExample = templates.Examples(`
+# Describe bundle received from outside of the secure network
+d8 mirror bundle inspect /opt/d8-bundle/d8.tar
+
+# Describe bundle in machine-readable form
+d8 mirror bundle inspect /opt/d8-bundle/d8.tar -o json
+`)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+func NewCommand() *cobra.Command {
+	inspectCmd := &cobra.Command{
+		Use:           "inspect <images-bundle-path>",
+		Short:         "Describe Deckhouse releases, modules and databases in the bundle",
+		Long:          inspectLong,
+		Example:       inspectExample,
+		ValidArgs:     []string{"images-bundle-path"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          inspect,
+	}
+
+	addFlags(inspectCmd.Flags())
+	return inspectCmd
+}
+
+var (
+	ImagesBundlePath string
+	OutputFormat     string
+)
+
+func inspect(cmd *cobra.Command, _ []string) error {
+	contents, err := bundle.ReadContents(ImagesBundlePath)
+	if err != nil {
+		return fmt.Errorf("Read bundle contents: %w", err)
+	}
+	inspection := bundle.Inspect(ImagesBundlePath, contents)
+
+	out := output.FromCommand(cmd).Data()
+	if OutputFormat == outputJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(inspection); err != nil {
+			return fmt.Errorf("Write bundle description: %w", err)
+		}
+		return nil
+	}
+
+	printInspection(out, inspection)
+	return nil
+}
+
+func printInspection(w io.Writer, inspection *bundle.Inspection) {
+	fmt.Fprintf(w, "Deckhouse releases: %s\n", listOrNone(inspection.DeckhouseVersions))
+
+	fmt.Fprintln(w, "\nRelease channels:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, channel := range inspection.Channels {
+		version := channel.Version
+		if version == "" {
+			version = "unknown version"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", channel.Channel, version)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nModules:")
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, module := range inspection.Modules {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", module.Name, listOrNone(module.Versions), formatSize(module.Size))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nSecurity databases:")
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, db := range inspection.SecurityDatabases {
+		fmt.Fprintf(tw, "  %s\t%s\n", db.Name, listOrNone(db.Tags))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tTAGS\tSIZE")
+	for _, repo := range inspection.Repositories {
+		repoPath := repo.Path
+		if repoPath == "" {
+			repoPath = "<root>"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", repoPath, repo.Tags, formatSize(repo.Size))
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t%s\n", formatSize(inspection.TotalSize))
+	tw.Flush()
+}
+
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
+
+func formatSize(size int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(size)/1024/1024)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+)
+
+var signLong = templates.LongDesc(`
+Sign Deckhouse Kubernetes Platform distribution bundle with a detached signature.
+
+This command calculates SHA-256 digests of the bundle tar file or of all its chunks,
+writes them into the integrity manifest <images-bundle-path>.manifest.json and signs
+this manifest. Signature is written to <images-bundle-path>.manifest.json.sig.
+
+Signature format is compatible with "cosign sign-blob", so the manifest may also be
+signed and verified with cosign itself.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+func NewCommand() *cobra.Command {
+	signCmd := &cobra.Command{
+		Use:           "sign <images-bundle-path>",
+		Short:         "Sign Deckhouse Kubernetes Platform distribution bundle with a detached signature",
+		Long:          signLong,
+		ValidArgs:     []string{"images-bundle-path"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          sign,
+	}
+
+	addFlags(signCmd.Flags())
+	return signCmd
+}
+
+var (
+	ImagesBundlePath string
+	PrivateKeyPath   string
+	CertificatePath  string
+)
+
+func sign(cmd *cobra.Command, _ []string) error {
+	logger := output.FromCommand(cmd).Logger()
+
+	signer, err := signature.LoadSigner(PrivateKeyPath)
+	if err != nil {
+		return err
+	}
+
+	var manifest *bundle.IntegrityManifest
+	err = logger.Process("Calculate bundle digests", func() error {
+		manifest, err = bundle.BuildIntegrityManifest(ImagesBundlePath)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	rawManifest, err := manifest.Marshal()
+	if err != nil {
+		return fmt.Errorf("Marshal integrity manifest: %w", err)
+	}
+	sig, err := signature.Sign(signer, rawManifest)
+	if err != nil {
+		return fmt.Errorf("Sign integrity manifest: %w", err)
+	}
+
+	manifestPath := bundle.IntegrityManifestPath(ImagesBundlePath)
+	if err = os.WriteFile(manifestPath, rawManifest, 0o644); err != nil {
+		return fmt.Errorf("Write integrity manifest: %w", err)
+	}
+	if err = os.WriteFile(manifestPath+".sig", sig, 0o644); err != nil {
+		return fmt.Errorf("Write signature: %w", err)
+	}
+
+	if CertificatePath != "" {
+		rawCert, err := os.ReadFile(CertificatePath)
+		if err != nil {
+			return fmt.Errorf("Read certificate: %w", err)
+		}
+		if err = os.WriteFile(manifestPath+".crt", rawCert, 0o644); err != nil {
+			return fmt.Errorf("Write certificate: %w", err)
+		}
+	}
+
+	logger.InfoF("Integrity manifest written to %s", manifestPath)
+	logger.InfoF("Signature written to %s", manifestPath+".sig")
+	return nil
+}
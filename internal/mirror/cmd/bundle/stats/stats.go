@@ -0,0 +1,133 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+var statsLong = templates.LongDesc(`
+Print statistics of Deckhouse Kubernetes Platform distribution bundle without unpacking it.
+
+By default, number of tags and their total size is printed for every repository in the bundle.
+With --by-version, number of images and aggregate size of every Deckhouse release recorded during pull
+is printed instead, along with growth from the previous release, to track platform footprint for capacity planning.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+func NewCommand() *cobra.Command {
+	statsCmd := &cobra.Command{
+		Use:           "stats <images-bundle-path>",
+		Short:         "Print statistics of Deckhouse Kubernetes Platform distribution bundle",
+		Long:          statsLong,
+		ValidArgs:     []string{"images-bundle-path"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          stats,
+	}
+
+	addFlags(statsCmd.Flags())
+	return statsCmd
+}
+
+var (
+	ImagesBundlePath string
+	ByVersion        bool
+)
+
+func stats(cmd *cobra.Command, _ []string) error {
+	contents, err := bundle.ReadContents(ImagesBundlePath)
+	if err != nil {
+		return fmt.Errorf("Read bundle contents: %w", err)
+	}
+
+	if ByVersion {
+		if len(contents.ReleaseFootprints) == 0 {
+			return errors.New("Bundle has no per-release statistics, it was pulled with an older version of d8 or with modules only")
+		}
+		printReleaseFootprints(output.FromCommand(cmd).Data(), contents.ReleaseFootprints)
+		return nil
+	}
+
+	printRepositories(output.FromCommand(cmd).Data(), contents)
+	return nil
+}
+
+func printRepositories(out io.Writer, contents *bundle.Contents) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "REPOSITORY\tTAGS\tSIZE")
+	var totalTags int
+	var totalSize int64
+	for _, repo := range contents.Repositories {
+		var size int64
+		for _, tag := range repo.Tags {
+			size += tag.Size
+		}
+		repoPath := repo.Path
+		if repoPath == "" {
+			repoPath = "<root>"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", repoPath, len(repo.Tags), formatSize(size))
+		totalTags += len(repo.Tags)
+		totalSize += size
+	}
+	fmt.Fprintf(w, "TOTAL\t%d\t%s\n", totalTags, formatSize(totalSize))
+}
+
+func printReleaseFootprints(out io.Writer, footprints []contexts.ReleaseFootprint) {
+	footprints = append([]contexts.ReleaseFootprint(nil), footprints...)
+	contexts.SortReleaseFootprints(footprints)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "VERSION\tIMAGES\tSIZE\tIMAGES GROWTH\tSIZE GROWTH")
+	for i, footprint := range footprints {
+		imagesGrowth, sizeGrowth := "-", "-"
+		if i > 0 {
+			previous := footprints[i-1]
+			imagesGrowth = fmt.Sprintf("%+d", footprint.Images-previous.Images)
+			sizeGrowth = fmt.Sprintf("%+.1f MiB", float64(footprint.Size-previous.Size)/1024/1024)
+			if previous.Size > 0 {
+				sizeGrowth += fmt.Sprintf(" (%+.1f%%)", float64(footprint.Size-previous.Size)/float64(previous.Size)*100)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n",
+			footprint.Version, footprint.Images, formatSize(footprint.Size), imagesGrowth, sizeGrowth)
+	}
+}
+
+func formatSize(size int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(size)/1024/1024)
+}
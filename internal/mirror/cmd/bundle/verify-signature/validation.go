@@ -0,0 +1,57 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verifysignature
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+)
+
+func parseAndValidateParameters(_ *cobra.Command, args []string) error {
+	if l := len(args); l != 1 {
+		return fmt.Errorf("accepts 1 argument, received %d", l)
+	}
+
+	ImagesBundlePath = filepath.Clean(args[0])
+	if filepath.Ext(ImagesBundlePath) != ".tar" {
+		return errors.New("images-bundle-path argument should be a path to tar archive (.tar)")
+	}
+
+	manifestPath := bundle.IntegrityManifestPath(ImagesBundlePath)
+	if SignaturePath == "" {
+		SignaturePath = manifestPath + ".sig"
+	}
+	if VerificationKeyPath == "" {
+		// Certificate shipped with the bundle can be replaced together with the bundle itself,
+		// so it is only trusted when its chain is checked against CA roots provided by the user.
+		if CARootsPath == "" {
+			return errors.New("--key or --ca-roots is required")
+		}
+		if _, err := os.Stat(manifestPath + ".crt"); err != nil {
+			return errors.New("--key is required as bundle contains no signing certificate")
+		}
+		VerificationKeyPath = manifestPath + ".crt"
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verifysignature
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+)
+
+var verifyLong = templates.LongDesc(`
+Verify detached signature and integrity of Deckhouse Kubernetes Platform distribution bundle.
+
+This command checks that integrity manifest <images-bundle-path>.manifest.json is signed
+by the owner of the provided public key or certificate, and that every bundle file
+matches the digest recorded in this manifest.
+
+Signing certificate shipped with the bundle is used only when --ca-roots is given,
+since it is not trusted unless issued by one of these CAs.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+func NewCommand() *cobra.Command {
+	verifyCmd := &cobra.Command{
+		Use:           "verify-signature <images-bundle-path>",
+		Short:         "Verify detached signature and integrity of Deckhouse Kubernetes Platform distribution bundle",
+		Long:          verifyLong,
+		ValidArgs:     []string{"images-bundle-path"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          verify,
+	}
+
+	addFlags(verifyCmd.Flags())
+	return verifyCmd
+}
+
+var (
+	ImagesBundlePath    string
+	VerificationKeyPath string
+	CARootsPath         string
+	SignaturePath       string
+)
+
+func verify(cmd *cobra.Command, _ []string) error {
+	logger := output.FromCommand(cmd).Logger()
+
+	pub, err := signature.LoadVerificationKey(VerificationKeyPath, CARootsPath)
+	if err != nil {
+		return err
+	}
+
+	manifestPath := bundle.IntegrityManifestPath(ImagesBundlePath)
+	rawManifest, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return fmt.Errorf("Read integrity manifest: %w", err)
+	}
+	sig, err := os.ReadFile(SignaturePath)
+	if err != nil {
+		return fmt.Errorf("Read signature: %w", err)
+	}
+
+	if err = signature.Verify(pub, rawManifest, sig); err != nil {
+		return fmt.Errorf("Integrity manifest signature verification failed: %w", err)
+	}
+	logger.InfoLn("Integrity manifest signature is valid")
+
+	signedManifest, err := bundle.ParseIntegrityManifest(rawManifest)
+	if err != nil {
+		return err
+	}
+
+	return logger.Process("Verify bundle digests", func() error {
+		actualManifest, err := bundle.BuildIntegrityManifest(ImagesBundlePath)
+		if err != nil {
+			return err
+		}
+		if err = signedManifest.Compare(actualManifest); err != nil {
+			return fmt.Errorf("Bundle integrity verification failed: %w", err)
+		}
+		logger.InfoF("All %d bundle files match signed integrity manifest", len(actualManifest.Files))
+		return nil
+	})
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+)
+
+var verifyLong = templates.LongDesc(`
+Verify that Deckhouse Kubernetes Platform distribution bundle was written and copied without damage.
+
+Every file of the bundle, either a single tar archive or its chunks, is read completely.
+Contents of every blob of OCI layouts stored in the bundle are checked against their digests,
+manifests and configs referenced by layout indexes and layers referenced by image manifests must be stored with declared sizes,
+and image configs must list as many layers as their manifests do.
+Chunks shorter than others and bundles ending in the middle of a file are reported as truncated.
+
+Command exits with non-zero code if any problem is found.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+var verifyExample = templates.Examples(`
+# Verify bundle before shipping it to the air-gapped site
+d8 mirror bundle verify /opt/d8-bundle/d8.tar
+
+# Verify bundle pulled with --images-bundle-chunk-size, path of the bundle is given as if it was not chunked
+d8 mirror bundle verify /opt/d8-bundle/d8.tar
+`)
+
+// ErrFailed is returned when bundle has any problems.
+var ErrFailed = errors.New("Bundle verification failed")
+
+func NewCommand() *cobra.Command {
+	verifyCmd := &cobra.Command{
+		Use:           "verify <images-bundle-path>",
+		Short:         "Verify digests of all blobs and consistency of all manifests in the bundle",
+		Long:          verifyLong,
+		Example:       verifyExample,
+		ValidArgs:     []string{"images-bundle-path"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          verify,
+	}
+
+	return verifyCmd
+}
+
+var ImagesBundlePath string
+
+func verify(cmd *cobra.Command, _ []string) error {
+	out := output.FromCommand(cmd)
+
+	var result *bundle.VerifyResult
+	err := out.Logger().Process("Verify bundle", func() error {
+		var err error
+		if result, err = bundle.Verify(cmd.Context(), ImagesBundlePath); err != nil {
+			return fmt.Errorf("Read bundle: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	w := out.Data()
+	for _, problem := range result.Problems {
+		fmt.Fprintln(w, problem)
+	}
+	fmt.Fprintf(w, "%s: %d layouts, %d manifests, %d blobs verified, %d problems found\n",
+		ImagesBundlePath, result.Layouts, result.Manifests, result.Blobs, len(result.Problems))
+
+	if !result.OK() {
+		return ErrFailed
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checktarget
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/operations"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+)
+
+var checkTargetLong = templates.LongDesc(`
+Check that third-party registry meets prerequisites of d8 mirror push before a long push is attempted.
+
+Following checks are run, each of them either passes, fails or is skipped if images cannot be pushed at all:
+
+- push: images can be pushed into the target repo;
+- repositories: nested repositories that d8 mirror push writes to, like installers and security databases, can be created;
+- tags: tags of the target repo can be listed, which d8 mirror push relies on to skip and verify pushed images;
+- manifest-size: manifests of 4 MiB are accepted, as OCI distribution spec requires;
+- media-types: Docker and OCI images and indexes are accepted, as are OCI artifacts of security databases.
+
+Checks push small images tagged d8WriteCheck, the same tag d8 mirror push checks write access with.
+They are not deleted afterwards. Command exits with non-zero code if any of the checks fails.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+var checkTargetExample = templates.Examples(`
+# Check registry before pushing bundle into it
+d8 mirror check-target registry.example.com/deckhouse/ee -u pusher -p secret
+
+# Check registry that repositories are pushed next to the target repo of and print machine-readable report
+d8 mirror check-target registry.example.com/deckhouse/ee --flatten-repositories -o json
+`)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// ErrFailed is returned when any of the registry checks fails.
+var ErrFailed = errors.New("Target registry does not meet push prerequisites")
+
+func NewCommand() *cobra.Command {
+	checkTargetCmd := &cobra.Command{
+		Use:           "check-target <registry>",
+		Short:         "Check that third-party registry meets prerequisites of d8 mirror push",
+		Long:          checkTargetLong,
+		Example:       checkTargetExample,
+		ValidArgs:     []string{"registry"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          checkTarget,
+	}
+
+	addFlags(checkTargetCmd.Flags())
+	return checkTargetCmd
+}
+
+var (
+	RegistryHost     string
+	RegistryPath     string
+	RegistryUsername string
+	RegistryPassword string
+	RegistryAuthFile string
+
+	registryFileAuth authn.Authenticator
+
+	Insecure            bool
+	TLSSkipVerify       bool
+	FlattenRepositories bool
+	ModulesPathSuffix   string
+
+	OutputFormat string
+)
+
+func checkTarget(cmd *cobra.Command, _ []string) error {
+	out := output.FromCommand(cmd)
+	logger := out.Logger()
+	mirrorCtx := &contexts.PushContext{
+		BaseContext: contexts.BaseContext{
+			Logger:              logger,
+			Insecure:            Insecure,
+			SkipTLSVerification: TLSSkipVerify,
+			RegistryHost:        RegistryHost,
+			RegistryPath:        RegistryPath,
+			RegistryAuth:        getRegistryAuthProvider(),
+			ModulesPathSuffix:   ModulesPathSuffix,
+		},
+		FlattenRepositories: FlattenRepositories,
+	}
+
+	var report *operations.TargetCheckReport
+	err := logger.Process("Check target registry", func() error {
+		var err error
+		report, err = operations.CheckTarget(context.Background(), mirrorCtx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Check target registry: %w", err)
+	}
+
+	if OutputFormat == outputJSON {
+		encoder := json.NewEncoder(out.Data())
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(report); err != nil {
+			return fmt.Errorf("Write report: %w", err)
+		}
+	} else {
+		printReport(out.Data(), report)
+	}
+
+	if !report.Passed() {
+		return ErrFailed
+	}
+	return nil
+}
+
+func printReport(w io.Writer, report *operations.TargetCheckReport) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tSUMMARY")
+	for _, check := range report.Checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", check.Name, check.Status, check.Summary)
+	}
+	tw.Flush()
+
+	for _, check := range report.Checks {
+		if len(check.Problems) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s problems:\n", check.Name)
+		for _, problem := range check.Problems {
+			fmt.Fprintf(w, "  %s\n", problem)
+		}
+	}
+	fmt.Fprintf(w, "\n%s: %s\n", report.Registry, report.Status)
+}
+
+func getRegistryAuthProvider() authn.Authenticator {
+	if registryFileAuth != nil {
+		return registryFileAuth
+	}
+	if RegistryUsername != "" {
+		return authn.FromConfig(authn.AuthConfig{
+			Username: RegistryUsername,
+			Password: RegistryPassword,
+		})
+	}
+	return auth.DefaultAuthenticator(RegistryHost + RegistryPath)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checktarget
+
+import (
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func addFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVarP(
+		&RegistryUsername,
+		"registry-login",
+		"u",
+		os.Getenv("D8_MIRROR_REGISTRY_LOGIN"),
+		"Username to log into the target registry.",
+	)
+	flagSet.StringVarP(
+		&RegistryPassword,
+		"registry-password",
+		"p",
+		os.Getenv("D8_MIRROR_REGISTRY_PASSWORD"),
+		"Password to log into the target registry.",
+	)
+	flagSet.StringVar(
+		&RegistryAuthFile,
+		"target-auth-file",
+		os.Getenv("D8_MIRROR_REGISTRY_AUTH_FILE"),
+		"File with credentials to log into the target registry, either Docker config.json or a single username:password line. "+
+			"Must be accessible only by its owner. Conflicts with --registry-login.",
+	)
+	flagSet.BoolVar(
+		&TLSSkipVerify,
+		"tls-skip-verify",
+		false,
+		"Disable TLS certificate validation.",
+	)
+	flagSet.BoolVar(
+		&Insecure,
+		"insecure",
+		false,
+		"Interact with registries over HTTP.",
+	)
+	flagSet.BoolVar(
+		&FlattenRepositories,
+		"flatten-repositories",
+		false,
+		"Check repositories next to the target repo instead of inside it, as d8 mirror push --flatten-repositories pushes to.",
+	)
+	flagSet.StringVar(
+		&ModulesPathSuffix,
+		"modules-path-suffix",
+		contexts.DefaultModulesPathSuffix,
+		"Path of modules repositories relative to the target repo, as in d8 mirror push --modules-path-suffix.",
+	)
+	flagSet.StringVarP(
+		&OutputFormat,
+		"output",
+		"o",
+		outputText,
+		`Format of check report: "text" or "json". JSON reports carry "kind" and "schemaVersion" fields, schema version changes only on incompatible changes.`,
+	)
+}
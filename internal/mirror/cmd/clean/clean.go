@@ -0,0 +1,138 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clean
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/operations"
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+var cleanLong = templates.LongDesc(`
+Remove data left behind by failed or interrupted d8 mirror runs from the given directory and its subdirectories.
+
+Working directories, like the ones created in the temporary directory to pull or unpack images,
+and bundles that were not completely written, either tar archives or their chunks, are removed.
+d8 marks them when they are created, so files and directories created by anything else are never touched.
+Data of runs that are still in progress on this host is kept.
+
+Working directories of interrupted pulls are kept too, as the next pull resumes from them.
+Use --all to remove them as well, such pulls cannot be resumed afterwards.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+var cleanExample = templates.Examples(`
+# Remove working directories of failed runs from the default temporary directory
+d8 mirror clean /tmp/mirror
+
+# List unfinished bundles in the bundle directory without removing them
+d8 mirror clean /opt/d8-bundle --dry-run
+
+# Also remove working directories interrupted pulls could be resumed from
+d8 mirror clean /tmp/mirror --all
+`)
+
+func NewCommand() *cobra.Command {
+	cleanCmd := &cobra.Command{
+		Use:           "clean <dir>",
+		Short:         "Remove working directories and unfinished bundles left by failed runs",
+		Long:          cleanLong,
+		Example:       cleanExample,
+		ValidArgs:     []string{"dir"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          clean,
+	}
+
+	addFlags(cleanCmd.Flags())
+	return cleanCmd
+}
+
+var (
+	Dir    string
+	DryRun bool
+	All    bool
+)
+
+func clean(cmd *cobra.Command, _ []string) error {
+	out := output.FromCommand(cmd)
+
+	leftovers, inUse, err := operations.FindLeftovers(Dir)
+	if err != nil {
+		return fmt.Errorf("Find leftovers of previous runs: %w", err)
+	}
+	for _, leftover := range inUse {
+		out.Warnf("%s %s is kept, d8 that created it may still be running", leftover.Kind, leftover.Path)
+	}
+	if !All {
+		leftovers = slices.DeleteFunc(leftovers, func(leftover operations.Leftover) bool {
+			if leftover.Resumable {
+				out.Warnf("%s %s is kept as interrupted pull can be resumed from it, use --all to remove it", leftover.Kind, leftover.Path)
+			}
+			return leftover.Resumable
+		})
+	}
+	if len(leftovers) == 0 {
+		out.Messagef("Nothing to clean in %s", Dir)
+		return nil
+	}
+
+	var size int64
+	for _, leftover := range leftovers {
+		if !DryRun {
+			if err = operations.RemoveLeftover(leftover); err != nil {
+				return err
+			}
+		}
+		size += leftover.Size
+	}
+
+	printLeftovers(out.Data(), leftovers)
+	if DryRun {
+		out.Messagef("\n%.1f MiB can be reclaimed", float64(size)/1024/1024)
+		return nil
+	}
+	out.Messagef("\nReclaimed %.1f MiB", float64(size)/1024/1024)
+	return nil
+}
+
+func printLeftovers(w io.Writer, leftovers []operations.Leftover) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "KIND\tPATH\tSIZE\tCREATED")
+	for _, leftover := range leftovers {
+		created := "-"
+		if !leftover.CreatedAt.IsZero() {
+			created = reportschema.Timestamp(leftover.CreatedAt)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f MiB\t%s\n", leftover.Kind, leftover.Path, float64(leftover.Size)/1024/1024, created)
+	}
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clean
+
+import (
+	"github.com/spf13/pflag"
+)
+
+func addFlags(flagSet *pflag.FlagSet) {
+	flagSet.BoolVar(
+		&DryRun,
+		"dry-run",
+		false,
+		"Only list working directories and unfinished bundles that would be removed.",
+	)
+	flagSet.BoolVar(
+		&All,
+		"all",
+		false,
+		"Also remove working directories of interrupted pulls, which are kept by default for the next pull to resume from them.",
+	)
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/internal/utilk8s"
+	libcompare "github.com/deckhouse/deckhouse-cli/pkg/libmirror/compare"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flatten"
+)
+
+var compareLong = templates.LongDesc(`
+Check that Deckhouse Kubernetes Platform distribution was mirrored from source to target intact.
+
+Both <source> and <target> are either registry repositories, like registry.example.com/deckhouse/ee,
+oci-layout:// paths to unpacked bundles or s3:// URLs of tar bundles. Every repository and tag of the source
+must be present in target and point to the same image. With --deep, presence of every image layer is checked too.
+Repositories that are compared depend on Deckhouse edition given with --edition, as editions are distributed
+in different sets of repositories.
+
+Comparison can be narrowed down to what a deployment actually needs: with --deckhouse-tag only images of
+the given Deckhouse release are compared in Deckhouse, installers and release channel repositories,
+and with --modules only repositories of the given modules are compared among modules ones.
+
+Command exits with non-zero code if target is not consistent with source.
+When run as an in-cluster Job, comparison results can be recorded into a ConfigMap with --report-configmap
+and reported as an event of the Job with --report-event-object, for dashboards and GitOps controllers to react on.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+var compareExample = templates.Examples(`
+# Check that bundle was pushed to the registry
+d8 mirror compare oci-layout:///opt/d8-bundle registry.example.com/deckhouse/ee --target-login admin --target-password secret
+
+# Compare mirror with the source registry and print machine-readable report
+d8 mirror compare registry.deckhouse.io/deckhouse/ee registry.example.com/deckhouse/ee --license $LICENSE --deep -o json
+
+# Check that the release and modules about to be deployed are mirrored
+d8 mirror compare registry.deckhouse.io/deckhouse/ee registry.example.com/deckhouse/ee --license $LICENSE \
+  --deckhouse-tag v1.60.3 --modules console,commander -o json
+
+# Record comparison results of in-cluster Job into ConfigMap and Job events
+d8 mirror compare oci-layout:///opt/d8-bundle registry.example.com/deckhouse/ee \
+  --report-configmap d8-system/mirror-verify --report-event-object Job/d8-system/mirror-verify
+`)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// ErrInconsistent is returned when target misses contents of source.
+var ErrInconsistent = errors.New("Target is not consistent with source")
+
+func NewCommand() *cobra.Command {
+	compareCmd := &cobra.Command{
+		Use:           "compare <source> <target>",
+		Short:         "Check that Deckhouse Kubernetes Platform distribution was mirrored intact",
+		Long:          compareLong,
+		Example:       compareExample,
+		ValidArgs:     []string{"source", "target"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          compare,
+	}
+
+	addFlags(compareCmd.Flags())
+	return compareCmd
+}
+
+var (
+	Source string
+	Target string
+
+	SourceLogin           string
+	SourcePassword        string
+	SourceAuthFile        string
+	DeckhouseLicenseToken string
+	TargetLogin           string
+	TargetPassword        string
+	TargetAuthFile        string
+
+	sourceAuth    authn.Authenticator
+	targetAuth    authn.Authenticator
+	targetMapping *flatten.Mapping
+
+	Insecure      bool
+	TLSSkipVerify bool
+
+	Deep               bool
+	IncludeSignatures  bool
+	FailOnExtra        bool
+	ExtraAllowlist     []string
+	FlattenMappingPath string
+	ModulesPathSuffix  string
+	EditionName        string
+	DeckhouseTag       string
+	Modules            []string
+
+	edition *contexts.Edition
+	scope   *libcompare.Scope
+
+	OutputFormat string
+
+	KubeconfigPath    string
+	ReportConfigMap   string
+	ReportEventObject string
+
+	reportConfigMapNamespace string
+	reportConfigMapName      string
+	reportEventObject        *corev1.ObjectReference
+)
+
+// newKubeClient connects to the cluster that comparison results are reported to.
+var newKubeClient = func(kubeconfigPath string) (kubernetes.Interface, error) {
+	_, kubeCl, err := utilk8s.SetupK8sClientSet(kubeconfigPath)
+	return kubeCl, err
+}
+
+func compare(cmd *cobra.Command, _ []string) error {
+	out := output.FromCommand(cmd)
+	logger := out.Logger()
+
+	comparator := libcompare.NewRegistryComparator(Source, Target, libcompare.ComparatorOptions{
+		SourceAuth:        sourceAuth,
+		TargetAuth:        targetAuth,
+		Insecure:          Insecure,
+		SkipTLSVerify:     TLSSkipVerify,
+		Deep:              Deep,
+		TargetMapping:     targetMapping,
+		ModulesPathSuffix: ModulesPathSuffix,
+		Edition:           edition,
+		Scope:             scope,
+		FailOnExtra:       FailOnExtra,
+		ExtraAllowlist:    ExtraAllowlist,
+		SkipRules:         libcompare.DefaultSkipRules(IncludeSignatures),
+	})
+
+	var report *libcompare.ComparisonReport
+	err := logger.Process(fmt.Sprintf("Compare %s with %s", Target, Source), func() error {
+		var err error
+		report, err = comparator.Compare(context.Background())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Compare %s with %s: %w", Target, Source, err)
+	}
+	if report.TargetCapabilities != nil {
+		logger.DebugLn("Target registry capabilities:")
+		for _, line := range report.TargetCapabilities.Matrix() {
+			logger.DebugF("  %s", line)
+		}
+	}
+
+	if OutputFormat == outputJSON {
+		encoder := json.NewEncoder(out.Data())
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(report); err != nil {
+			return fmt.Errorf("Write report: %w", err)
+		}
+	} else {
+		printReport(out.Data(), report)
+	}
+
+	if err = publishReport(context.Background(), report); err != nil {
+		return err
+	}
+
+	if !report.IsConsistent() {
+		return ErrInconsistent
+	}
+	return nil
+}
+
+// publishReport records report into ConfigMap and emits event with its summary, if asked to.
+func publishReport(ctx context.Context, report *libcompare.ComparisonReport) error {
+	if reportConfigMapName == "" && reportEventObject == nil {
+		return nil
+	}
+
+	kubeCl, err := newKubeClient(KubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("Failed to setup Kubernetes client: %w", err)
+	}
+	if reportConfigMapName != "" {
+		err = libcompare.ExportReportToConfigMap(ctx, kubeCl, reportConfigMapNamespace, reportConfigMapName, report)
+		if err != nil {
+			return fmt.Errorf("Export comparison report: %w", err)
+		}
+	}
+	if reportEventObject != nil {
+		if err = libcompare.EmitReportEvent(ctx, kubeCl, *reportEventObject, report); err != nil {
+			return fmt.Errorf("Report comparison results: %w", err)
+		}
+	}
+	return nil
+}
+
+func printReport(w io.Writer, report *libcompare.ComparisonReport) {
+	printList := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s:\n", title)
+		for _, item := range items {
+			fmt.Fprintf(w, "  %s\n", item)
+		}
+	}
+
+	printList("Missing repositories", report.MissingRepositories)
+	printList("Missing images", report.MissingImages)
+	if len(report.MismatchedImages) > 0 {
+		fmt.Fprintln(w, "Mismatched images:")
+		for _, mismatch := range report.MismatchedImages {
+			fmt.Fprintf(w, "  %s: source %s, target %s", mismatch.Image, mismatch.SourceDigest, mismatch.TargetDigest)
+			switch {
+			case mismatch.Recompressed:
+				fmt.Fprint(w, ", layers recompressed")
+			case len(mismatch.MissingLayers) > 0:
+				fmt.Fprintf(w, ", %d layers missing", len(mismatch.MissingLayers))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	if len(report.TagConflicts) > 0 {
+		fmt.Fprintln(w, "Tag conflicts:")
+		for _, conflict := range report.TagConflicts {
+			fmt.Fprintf(w, "  %s\n", conflict)
+		}
+	}
+	if report.FailOnExtra {
+		printList("Extra repositories", report.ExtraRepositories)
+		printList("Extra images", report.ExtraImages)
+	}
+	printList("Repositories not compared", report.UndiscoveredRepositories)
+	fmt.Fprintln(w, report.Summary())
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	libcompare "github.com/deckhouse/deckhouse-cli/pkg/libmirror/compare"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+)
+
+func TestCompareReportsResultsToCluster(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	appendRandomImageToLayout(t, filepath.Join(source, "install"), "v1.60.3")
+	appendRandomImageToLayout(t, filepath.Join(source, "install"), "stable")
+
+	kubeCl := fake.NewSimpleClientset()
+	newKubeClientOrig := newKubeClient
+	newKubeClient = func(string) (kubernetes.Interface, error) { return kubeCl, nil }
+	t.Cleanup(func() { newKubeClient = newKubeClientOrig })
+
+	cmd := NewCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{
+		libcompare.OCILayoutScheme + source,
+		libcompare.OCILayoutScheme + target,
+		"--report-configmap", "d8-system/mirror-verify",
+		"--report-event-object", "Job/d8-system/mirror-verify",
+	})
+	require.ErrorIs(t, cmd.Execute(), ErrInconsistent)
+
+	cm, err := kubeCl.CoreV1().ConfigMaps("d8-system").Get(context.Background(), "mirror-verify", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "false", cm.Data[libcompare.ReportConsistentKey])
+	require.Contains(t, cm.Data[libcompare.ReportSummaryKey], "1 missing repositories")
+
+	events, err := kubeCl.CoreV1().Events("d8-system").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	require.Equal(t, corev1.EventTypeWarning, events.Items[0].Type)
+	require.Equal(t, libcompare.ReportEventReasonDrift, events.Items[0].Reason)
+	require.Equal(t, "Job", events.Items[0].InvolvedObject.Kind)
+	require.Equal(t, "mirror-verify", events.Items[0].InvolvedObject.Name)
+}
+
+func TestParseReportTargets(t *testing.T) {
+	t.Cleanup(func() { ReportConfigMap, ReportEventObject = "", "" })
+
+	ReportConfigMap, ReportEventObject = "d8-system/mirror-verify", "Job/d8-system/mirror-verify"
+	require.NoError(t, parseReportTargets())
+	require.Equal(t, "d8-system", reportConfigMapNamespace)
+	require.Equal(t, "mirror-verify", reportConfigMapName)
+	require.Equal(t, &corev1.ObjectReference{Kind: "Job", Namespace: "d8-system", Name: "mirror-verify"}, reportEventObject)
+
+	ReportConfigMap, ReportEventObject = "mirror-verify", ""
+	require.ErrorContains(t, parseReportTargets(), "--report-configmap")
+
+	ReportConfigMap, ReportEventObject = "", "d8-system/mirror-verify"
+	require.ErrorContains(t, parseReportTargets(), "--report-event-object")
+}
+
+func appendRandomImageToLayout(t *testing.T, layoutPath, tag string) {
+	t.Helper()
+	l, err := layout.FromPath(layoutPath)
+	if err != nil {
+		l, err = layouts.CreateEmptyImageLayoutAtPath(layoutPath)
+		require.NoError(t, err)
+	}
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	require.NoError(t, l.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"io.deckhouse.image.short_tag": tag,
+	})))
+}
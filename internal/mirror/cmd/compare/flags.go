@@ -0,0 +1,165 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func addFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&SourceLogin,
+		"source-login",
+		os.Getenv("D8_MIRROR_SOURCE_LOGIN"),
+		"Source registry login.",
+	)
+	flagSet.StringVar(
+		&SourcePassword,
+		"source-password",
+		os.Getenv("D8_MIRROR_SOURCE_PASSWORD"),
+		"Source registry password.",
+	)
+	flagSet.StringVarP(
+		&DeckhouseLicenseToken,
+		"license",
+		"l",
+		os.Getenv("D8_MIRROR_LICENSE_TOKEN"),
+		"Deckhouse license key. Shortcut for --source-login=license-token --source-password=<>.",
+	)
+	flagSet.StringVar(
+		&SourceAuthFile,
+		"source-auth-file",
+		os.Getenv("D8_MIRROR_SOURCE_AUTH_FILE"),
+		"File with source registry credentials, either Docker config.json or a single username:password line. "+
+			"Must be accessible only by its owner. Conflicts with --source-login and --license.",
+	)
+	flagSet.StringVar(
+		&TargetLogin,
+		"target-login",
+		os.Getenv("D8_MIRROR_REGISTRY_LOGIN"),
+		"Target registry login.",
+	)
+	flagSet.StringVar(
+		&TargetPassword,
+		"target-password",
+		os.Getenv("D8_MIRROR_REGISTRY_PASSWORD"),
+		"Target registry password.",
+	)
+	flagSet.StringVar(
+		&TargetAuthFile,
+		"target-auth-file",
+		os.Getenv("D8_MIRROR_REGISTRY_AUTH_FILE"),
+		"File with target registry credentials, either Docker config.json or a single username:password line. "+
+			"Must be accessible only by its owner. Conflicts with --target-login.",
+	)
+	flagSet.BoolVar(
+		&TLSSkipVerify,
+		"tls-skip-verify",
+		false,
+		"Disable TLS certificate validation.",
+	)
+	flagSet.BoolVar(
+		&Insecure,
+		"insecure",
+		false,
+		"Interact with registries over HTTP.",
+	)
+	flagSet.BoolVar(
+		&Deep,
+		"deep",
+		false,
+		"Check that every layer of every image is present in target, not only image manifests. Takes considerably longer.",
+	)
+	flagSet.BoolVar(
+		&IncludeSignatures,
+		"include-signatures",
+		false,
+		"Compare cosign signatures, attestations and SBOMs (sha256-<digest>.sig, .att and .sbom tags) and OCI referrers indexes too, they are skipped by default.",
+	)
+	flagSet.BoolVar(
+		&FailOnExtra,
+		"fail-on-extra",
+		false,
+		"Consider repositories and images of target that source does not have an inconsistency.",
+	)
+	flagSet.StringArrayVar(
+		&ExtraAllowlist,
+		"allow-extra",
+		nil,
+		`Pattern of extra repository or image ("repo:tag") tolerated with --fail-on-extra, e.g. "custom/*" or "install:*-debug". May be repeated.`,
+	)
+	flagSet.StringVar(
+		&FlattenMappingPath,
+		"flatten-mapping-file",
+		"",
+		"Mapping of repositories recorded by d8 mirror push, if target was pushed with --flatten-repositories or some repositories were lowercased.",
+	)
+	flagSet.StringVar(
+		&ModulesPathSuffix,
+		"modules-path-suffix",
+		contexts.DefaultModulesPathSuffix,
+		"Path of modules repositories relative to the source and target repos, as in d8 mirror pull and push --modules-path-suffix.",
+	)
+	flagSet.StringVar(
+		&EditionName,
+		"edition",
+		contexts.EnterpriseEdition.Name,
+		"Deckhouse edition of source and target, fe or ee. Selects Deckhouse repositories that are compared.",
+	)
+	flagSet.StringVar(
+		&DeckhouseTag,
+		"deckhouse-tag",
+		"",
+		"Compare only images of this Deckhouse release, like v1.60.3, in Deckhouse, installers and release channel repositories.",
+	)
+	flagSet.StringSliceVar(
+		&Modules,
+		"modules",
+		nil,
+		"Compare only repositories of these modules among modules ones. Comma-separated or repeated.",
+	)
+	flagSet.StringVar(
+		&ReportConfigMap,
+		"report-configmap",
+		"",
+		"Record comparison results into this ConfigMap, given as <namespace>/<name>. It is created if missing.",
+	)
+	flagSet.StringVar(
+		&ReportEventObject,
+		"report-event-object",
+		"",
+		"Emit event with comparison results on this object, given as <kind>/<namespace>/<name>, e.g. Job/d8-system/mirror-verify. "+
+			"Drift is reported as Warning event.",
+	)
+	flagSet.StringVar(
+		&KubeconfigPath,
+		"kubeconfig",
+		os.Getenv("KUBECONFIG"),
+		"KubeConfig of the cluster that comparison results are reported to. In-cluster configuration is used if not set.",
+	)
+	flagSet.StringVarP(
+		&OutputFormat,
+		"output",
+		"o",
+		outputText,
+		`Format of comparison report: "text" or "json". JSON reports carry "kind" and "schemaVersion" fields, schema version changes only on incompatible changes.`,
+	)
+}
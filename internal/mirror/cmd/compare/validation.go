@@ -0,0 +1,147 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+
+	libcompare "github.com/deckhouse/deckhouse-cli/pkg/libmirror/compare"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flagrules"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flatten"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/s3"
+)
+
+var flagRules = []flagrules.Rule{
+	flagrules.Conflicts("source-auth-file", "source-login", "license"),
+	flagrules.Conflicts("license", "source-login"),
+	flagrules.Conflicts("target-auth-file", "target-login"),
+	flagrules.Requires("allow-extra", "fail-on-extra").Because("extras are tolerated anyway"),
+	flagrules.Requires("kubeconfig", "report-configmap", "report-event-object"),
+}
+
+func parseAndValidateParameters(cmd *cobra.Command, args []string) error {
+	if err := flagrules.Validate(cmd.Flags(), flagRules...); err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return errors.New("invalid number of arguments, expected 2")
+	}
+	Source, Target = strings.TrimSuffix(args[0], "/"), strings.TrimSuffix(args[1], "/")
+
+	var err error
+	if err = validateOutputFormat(); err != nil {
+		return err
+	}
+	if edition, err = contexts.LookupEdition(EditionName); err != nil {
+		return fmt.Errorf("Invalid --edition: %w", err)
+	}
+	if err = parseScope(); err != nil {
+		return err
+	}
+	if err = parseReportTargets(); err != nil {
+		return err
+	}
+	if sourceAuth, err = authProvider(Source, SourceLogin, SourcePassword, SourceAuthFile); err != nil {
+		return fmt.Errorf("Invalid source credentials: %w", err)
+	}
+	if DeckhouseLicenseToken != "" {
+		sourceAuth = authn.FromConfig(authn.AuthConfig{Username: "license-token", Password: DeckhouseLicenseToken})
+	}
+	if targetAuth, err = authProvider(Target, TargetLogin, TargetPassword, TargetAuthFile); err != nil {
+		return fmt.Errorf("Invalid target credentials: %w", err)
+	}
+	if FlattenMappingPath != "" {
+		if targetMapping, err = flatten.LoadMapping(FlattenMappingPath); err != nil {
+			return fmt.Errorf("Invalid --flatten-mapping-file: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateOutputFormat() error {
+	if OutputFormat != outputText && OutputFormat != outputJSON {
+		return fmt.Errorf("Unknown --output %q, expected %q or %q", OutputFormat, outputText, outputJSON)
+	}
+	return nil
+}
+
+// authProvider returns credentials for registry of ref, which may also be a bundle that needs none.
+// Credentials of Docker config are used if none are given.
+func authProvider(ref, login, password, authFile string) (authn.Authenticator, error) {
+	if strings.HasPrefix(ref, libcompare.OCILayoutScheme) || s3.IsURL(ref) {
+		return authn.Anonymous, nil
+	}
+	if login == "" && password == "" && authFile == "" {
+		return auth.DefaultAuthenticator(ref), nil
+	}
+	if password != "" && login == "" {
+		return nil, errors.New("registry username not specified")
+	}
+	if authFile != "" {
+		return auth.LoadAuthFileForRepo(authFile, ref)
+	}
+	return authn.FromConfig(authn.AuthConfig{Username: login, Password: password}), nil
+}
+
+// parseScope narrows comparison down with --deckhouse-tag and --modules, if any of them is given.
+func parseScope() error {
+	scope = nil
+	if DeckhouseTag == "" && len(Modules) == 0 {
+		return nil
+	}
+	if DeckhouseTag != "" {
+		if _, err := semver.StrictNewVersion(strings.TrimPrefix(DeckhouseTag, "v")); err != nil || !strings.HasPrefix(DeckhouseTag, "v") {
+			return fmt.Errorf("Invalid --deckhouse-tag %q: must be Deckhouse release tag, like v1.60.3", DeckhouseTag)
+		}
+	}
+	for _, module := range Modules {
+		if module == "" || strings.Contains(module, "/") {
+			return fmt.Errorf("Invalid --modules: %q is not a module name", module)
+		}
+	}
+	scope = &libcompare.Scope{DeckhouseTag: DeckhouseTag, Modules: Modules}
+	return nil
+}
+
+// parseReportTargets parses objects given with --report-configmap and --report-event-object.
+func parseReportTargets() error {
+	reportConfigMapNamespace, reportConfigMapName, reportEventObject = "", "", nil
+	if ReportConfigMap != "" {
+		parts := strings.Split(ReportConfigMap, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("Invalid --report-configmap %q: expected <namespace>/<name>", ReportConfigMap)
+		}
+		reportConfigMapNamespace, reportConfigMapName = parts[0], parts[1]
+	}
+	if ReportEventObject != "" {
+		parts := strings.Split(ReportEventObject, "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return fmt.Errorf("Invalid --report-event-object %q: expected <kind>/<namespace>/<name>", ReportEventObject)
+		}
+		reportEventObject = &corev1.ObjectReference{Kind: parts[0], Namespace: parts[1], Name: parts[2]}
+	}
+	return nil
+}
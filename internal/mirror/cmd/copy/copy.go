@@ -0,0 +1,284 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package copy
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/pull"
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/releases"
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/operations"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/httppool"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/workdir"
+)
+
+const enterpriseEditionRepo = "registry.deckhouse.io/deckhouse/ee"
+
+var copyLong = templates.LongDesc(`
+Copy Deckhouse Kubernetes Platform distribution from the source registry straight into the third-party registry.
+
+Images are selected the same way d8 mirror pull selects them and streamed registry-to-registry
+without writing a bundle to the local filesystem, so both registries must be reachable from the same host.
+Target registry ends up with the same repositories and tags d8 mirror push would create from the pulled bundle.
+Images already present in target are skipped, interrupted copy is resumed by running it again.
+
+Multi-platform images are copied the way d8 mirror pull --platforms pulls them, and cosign signatures of
+source images are verified and copied with the same flags pull has.
+Use --source-insecure, --target-insecure and their --*-tls-skip-verify counterparts to relax TLS
+for only one of the registries, --insecure and --tls-skip-verify apply to both.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+var copyExample = templates.Examples(`
+# Copy Deckhouse releases from current Rock Solid onwards with all modules
+d8 mirror copy --license $LICENSE --target registry.example.com/deckhouse/ee --target-login admin --target-password secret
+
+# Copy specific release without modules
+d8 mirror copy --license $LICENSE --target registry.example.com/deckhouse/ee --release v1.60.3 --no-modules
+
+# Copy images of linux/amd64 and linux/arm64 platforms with their verified signatures from a source mirror served over HTTP
+d8 mirror copy --source mirror.local/deckhouse/ee --source-insecure --target registry.example.com/deckhouse/ee \
+  --platforms linux/amd64,linux/arm64 --verify-source-signatures --key vendor.pub --include-cosign
+`)
+
+func NewCommand() *cobra.Command {
+	copyCmd := &cobra.Command{
+		Use:           "copy",
+		Short:         "Copy Deckhouse Kubernetes Platform distribution from source registry to the third-party registry",
+		Long:          copyLong,
+		Example:       copyExample,
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          copyDistribution,
+	}
+
+	addFlags(copyCmd.Flags())
+	return copyCmd
+}
+
+var (
+	TempDir = filepath.Join(os.TempDir(), "mirror")
+
+	SourceRegistryRepo    string
+	SourceLogin           string
+	SourcePassword        string
+	SourceAuthFile        string
+	DeckhouseLicenseToken string
+
+	TargetRegistryRepo string
+	TargetHost         string
+	TargetPath         string
+	TargetLogin        string
+	TargetPassword     string
+	TargetAuthFile     string
+
+	sourceAuth authn.Authenticator
+	targetAuth authn.Authenticator
+
+	Insecure            bool
+	TLSSkipVerify       bool
+	SourceInsecure      bool
+	SourceTLSSkipVerify bool
+	TargetInsecure      bool
+	TargetTLSSkipVerify bool
+
+	PullConcurrency int
+
+	platformStrings []string
+	Platforms       []v1.Platform
+	AllPlatforms    bool
+
+	VerifySourceSignatures bool
+	SourceSignatureKeyPath string
+	SourceSignatureKey     crypto.PublicKey
+	SignaturePolicy        string
+
+	IncludeCosign bool
+
+	minVersionString string
+	MinVersion       *semver.Version
+
+	specificReleaseString string
+	SpecificRelease       *semver.Version
+
+	SinceChannel string
+
+	NoModules          bool
+	ModulesPathSuffix  string
+	FlattenMappingPath string
+)
+
+func copyDistribution(cmd *cobra.Command, _ []string) error {
+	_, err := copyReleases(context.Background(), output.FromCommand(cmd).Logger(), nil)
+	return err
+}
+
+// copyReleases copies selected Deckhouse releases with modules and security databases into target registry
+// and returns copied releases. Releases listed in copiedBefore are left out, release channels are copied anyway.
+func copyReleases(ctx context.Context, logger contexts.Logger, copiedBefore []semver.Version) (copied []semver.Version, err error) {
+	run := contexts.NewRunContext(ctx)
+	sourceCtx := &contexts.PullContext{
+		BaseContext: contexts.BaseContext{
+			Logger:                logger,
+			Insecure:              Insecure || SourceInsecure,
+			SkipTLSVerification:   TLSSkipVerify || SourceTLSSkipVerify,
+			DeckhouseRegistryRepo: SourceRegistryRepo,
+			ModulesPathSuffix:     ModulesPathSuffix,
+			RegistryAuth:          sourceAuth,
+			Run:                   run,
+		},
+		Concurrency:     PullConcurrency,
+		SkipModulesPull: NoModules,
+		SpecificVersion: SpecificRelease,
+		MinVersion:      MinVersion,
+		SinceChannel:    SinceChannel,
+
+		SourceSignatureKey: SourceSignatureKey,
+		SignaturePolicy:    SignaturePolicy,
+		IncludeCosign:      IncludeCosign,
+
+		Platforms:    Platforms,
+		AllPlatforms: AllPlatforms,
+	}
+	targetCtx := &contexts.PushContext{
+		BaseContext: contexts.BaseContext{
+			Logger:              logger,
+			Insecure:            Insecure || TargetInsecure,
+			SkipTLSVerification: TLSSkipVerify || TargetTLSSkipVerify,
+			RegistryHost:        TargetHost,
+			RegistryPath:        TargetPath,
+			ModulesPathSuffix:   ModulesPathSuffix,
+			RegistryAuth:        targetAuth,
+			Run:                 run,
+		},
+		FlattenMappingPath: FlattenMappingPath,
+	}
+
+	if err = validateRegistriesAccess(sourceCtx, targetCtx); err != nil {
+		return nil, err
+	}
+
+	// Planning creates empty layouts to group images the way pull does, nothing is written into them.
+	workDirs := workdir.NewManager(TempDir, false, logger)
+	defer func() {
+		if cleanupErr := workDirs.Cleanup(err); cleanupErr != nil && err == nil {
+			err = fmt.Errorf("Cleanup temporary data after mirroring: %w", cleanupErr)
+		}
+	}()
+	layoutsDir, err := workDirs.Create(time.Now().Format("copy_tmp_02-01-2006_15-04-05"))
+	if err != nil {
+		return nil, err
+	}
+
+	var versionsToMirror []semver.Version
+	err = logger.Process("Looking for required Deckhouse releases", func() error {
+		if sourceCtx.SpecificVersion != nil {
+			versionsToMirror = append(versionsToMirror, *sourceCtx.SpecificVersion)
+			logger.InfoF("Skipped releases lookup as release %v is specifically requested with --release", sourceCtx.SpecificVersion)
+			return nil
+		}
+		plan, err := releases.PlanVersionsToMirror(sourceCtx)
+		if err != nil {
+			return fmt.Errorf("Find versions to mirror: %w", err)
+		}
+		versionsToMirror = slices.DeleteFunc(plan.SelectedVersions(), func(version semver.Version) bool {
+			return slices.ContainsFunc(copiedBefore, func(before semver.Version) bool { return before.Equal(&version) })
+		})
+		if len(versionsToMirror) == 0 && len(copiedBefore) > 0 {
+			logger.InfoLn("No new Deckhouse releases since the previous sync")
+			return nil
+		}
+		logger.InfoF("Deckhouse releases to copy: %+v", versionsToMirror)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var plan *layouts.DownloadPlan
+	err = logger.Process("Find images to copy", func() error {
+		plan, err = pull.PlanDeckhouseDownload(sourceCtx, versionsToMirror, nil, layoutsDir.Path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = logger.Process("Copy images to registry", func() error {
+		return operations.CopyDeckhouseToRegistry(run.Context(), sourceCtx, targetCtx, plan.Images())
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.InfoF("Connections: %s", httppool.Snapshot())
+	return versionsToMirror, nil
+}
+
+func validateRegistriesAccess(sourceCtx *contexts.PullContext, targetCtx *contexts.PushContext) error {
+	if os.Getenv("MIRROR_BYPASS_ACCESS_CHECKS") == "1" {
+		return nil
+	}
+
+	accessValidationTag := "alpha"
+	if sourceCtx.SpecificVersion != nil {
+		v := sourceCtx.SpecificVersion
+		accessValidationTag = fmt.Sprintf("v%d.%d.%d", v.Major(), v.Minor(), v.Patch())
+	}
+	readAccessTimeoutCtx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	if err := auth.ValidateReadAccessForImageContext(
+		readAccessTimeoutCtx,
+		sourceCtx.DeckhouseRegistryRepo+":"+accessValidationTag,
+		sourceCtx.RegistryAuth,
+		sourceCtx.Insecure,
+		sourceCtx.SkipTLSVerification,
+	); err != nil {
+		return fmt.Errorf("Source registry access validation failure: %w", err)
+	}
+
+	if err := auth.ValidateWriteAccessForRepo(
+		targetCtx.RegistryHost+targetCtx.RegistryPath,
+		targetCtx.RegistryAuth,
+		targetCtx.Insecure,
+		targetCtx.SkipTLSVerification,
+	); err != nil {
+		return fmt.Errorf("Target registry credentials validation failure: %w", err)
+	}
+	return nil
+}
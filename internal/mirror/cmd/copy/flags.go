@@ -0,0 +1,195 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package copy
+
+import (
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func addFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&SourceRegistryRepo,
+		"source",
+		enterpriseEditionRepo,
+		"Source registry to copy Deckhouse images from.",
+	)
+	flagSet.StringVar(
+		&SourceLogin,
+		"source-login",
+		os.Getenv("D8_MIRROR_SOURCE_LOGIN"),
+		"Source registry login.",
+	)
+	flagSet.StringVar(
+		&SourcePassword,
+		"source-password",
+		os.Getenv("D8_MIRROR_SOURCE_PASSWORD"),
+		"Source registry password.",
+	)
+	flagSet.StringVar(
+		&SourceAuthFile,
+		"source-auth-file",
+		os.Getenv("D8_MIRROR_SOURCE_AUTH_FILE"),
+		"File with source registry credentials, either Docker config.json or a single username:password line. "+
+			"Must be accessible only by its owner. Conflicts with --source-login and --license.",
+	)
+	flagSet.StringVarP(
+		&DeckhouseLicenseToken,
+		"license",
+		"l",
+		os.Getenv("D8_MIRROR_LICENSE_TOKEN"),
+		"Deckhouse license key. Shortcut for --source-login=license-token --source-password=<>.",
+	)
+	flagSet.StringVar(
+		&TargetRegistryRepo,
+		"target",
+		"",
+		"Target registry repository to copy Deckhouse images into, e.g. registry.example.com/deckhouse/ee. Required.",
+	)
+	flagSet.StringVar(
+		&TargetLogin,
+		"target-login",
+		os.Getenv("D8_MIRROR_REGISTRY_LOGIN"),
+		"Target registry login.",
+	)
+	flagSet.StringVar(
+		&TargetPassword,
+		"target-password",
+		os.Getenv("D8_MIRROR_REGISTRY_PASSWORD"),
+		"Target registry password.",
+	)
+	flagSet.StringVar(
+		&TargetAuthFile,
+		"target-auth-file",
+		os.Getenv("D8_MIRROR_REGISTRY_AUTH_FILE"),
+		"File with target registry credentials, either Docker config.json or a single username:password line. "+
+			"Must be accessible only by its owner. Conflicts with --target-login.",
+	)
+	flagSet.StringVarP(
+		&minVersionString,
+		"min-version",
+		"m",
+		"",
+		"Minimal Deckhouse release to copy. Ignored if above current Rock Solid release. Conflicts with --release and --since-channel.",
+	)
+	flagSet.StringVar(
+		&SinceChannel,
+		"since-channel",
+		"",
+		"Copy Deckhouse releases starting from the current version of the given release channel, e.g. lts or rock-solid. Conflicts with --min-version and --release.",
+	)
+	flagSet.StringVar(
+		&specificReleaseString,
+		"release",
+		"",
+		"Specific Deckhouse release to copy. Conflicts with --min-version and --since-channel.",
+	)
+	flagSet.BoolVar(
+		&NoModules,
+		"no-modules",
+		false,
+		"Do not copy Deckhouse modules into target registry.",
+	)
+	flagSet.StringVar(
+		&ModulesPathSuffix,
+		"modules-path-suffix",
+		contexts.DefaultModulesPathSuffix,
+		"Path of modules repositories relative to the source and target repos.",
+	)
+	flagSet.StringVar(
+		&FlattenMappingPath,
+		"flatten-mapping-file",
+		"d8-mirror-repositories-mapping.json",
+		"Where to write the mapping of repositories lowercased when copied, as registries do not allow uppercase characters in repository names.",
+	)
+	flagSet.IntVar(
+		&PullConcurrency,
+		"pull-concurrency",
+		4,
+		"Number of images copied in parallel, same as d8 mirror pull --pull-concurrency.",
+	)
+	flagSet.StringSliceVar(
+		&platformStrings,
+		"platforms",
+		nil,
+		"Copy multi-platform images as image indexes thinned to the given platforms, like linux/amd64,linux/arm64, or kept whole with \"all\". "+
+			"By default only linux/amd64 image is copied from multi-platform index.",
+	)
+	flagSet.BoolVar(
+		&VerifySourceSignatures,
+		"verify-source-signatures",
+		false,
+		"Verify cosign signatures of copied images with the public key provided by --key.",
+	)
+	flagSet.StringVar(
+		&SourceSignatureKeyPath,
+		"key",
+		"",
+		"Path to PEM-encoded vendor public key or certificate to verify source images signatures with.",
+	)
+	flagSet.StringVar(
+		&SignaturePolicy,
+		"signature-policy",
+		contexts.SignaturePolicyEnforce,
+		`What to do if image signature is missing or invalid: "enforce" fails the copy, "warn" only reports it.`,
+	)
+	flagSet.BoolVar(
+		&IncludeCosign,
+		"include-cosign",
+		false,
+		"Also copy cosign signatures, attestations and SBOMs (.sig, .att and .sbom tags) of every copied image.",
+	)
+	flagSet.BoolVar(
+		&TLSSkipVerify,
+		"tls-skip-verify",
+		false,
+		"Disable TLS certificate validation of both registries.",
+	)
+	flagSet.BoolVar(
+		&Insecure,
+		"insecure",
+		false,
+		"Interact with both registries over HTTP.",
+	)
+	flagSet.BoolVar(
+		&SourceTLSSkipVerify,
+		"source-tls-skip-verify",
+		false,
+		"Disable TLS certificate validation of the source registry.",
+	)
+	flagSet.BoolVar(
+		&SourceInsecure,
+		"source-insecure",
+		false,
+		"Interact with the source registry over HTTP.",
+	)
+	flagSet.BoolVar(
+		&TargetTLSSkipVerify,
+		"target-tls-skip-verify",
+		false,
+		"Disable TLS certificate validation of the target registry.",
+	)
+	flagSet.BoolVar(
+		&TargetInsecure,
+		"target-insecure",
+		false,
+		"Interact with the target registry over HTTP.",
+	)
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package copy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flagrules"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/notify"
+)
+
+var syncLong = templates.LongDesc(`
+Keep the third-party registry in sync with Deckhouse Kubernetes Platform releases of the source registry.
+
+Sync selects and copies images the way d8 mirror copy does. With --watch, it keeps running as a lightweight
+mirroring agent: release channels are re-evaluated every --interval, and only releases that appeared since
+the previous sync are copied together with updated release channels, modules and security databases.
+Images already present in target with the same digest are never copied again.
+Failed syncs are retried on the next interval, agent stops on SIGINT or SIGTERM.
+Outcome of every sync is posted to --notify-url, if it is given.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+var syncExample = templates.Examples(`
+# Sync releases from current LTS onwards every 6 hours
+d8 mirror sync --watch --interval 6h --license $LICENSE --since-channel lts --target registry.example.com/deckhouse/ee --target-auth-file /etc/d8/target-auth
+`)
+
+var (
+	Watch    bool
+	Interval time.Duration
+
+	NotifyURL      string
+	NotifyTemplate string
+	notifier       *notify.Notifier
+)
+
+var syncFlagRules = []flagrules.Rule{
+	flagrules.Requires("interval", "watch"),
+	flagrules.Conflicts("watch", "release").Because("specific release never changes"),
+}
+
+func NewSyncCommand() *cobra.Command {
+	syncCmd := &cobra.Command{
+		Use:           "sync",
+		Short:         "Keep third-party registry in sync with Deckhouse Kubernetes Platform releases",
+		Long:          syncLong,
+		Example:       syncExample,
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateSyncParameters,
+		RunE:          syncDistribution,
+	}
+
+	addFlags(syncCmd.Flags())
+	addSyncFlags(syncCmd.Flags())
+	return syncCmd
+}
+
+func addSyncFlags(flagSet *pflag.FlagSet) {
+	flagSet.BoolVar(
+		&Watch,
+		"watch",
+		false,
+		"Keep running and sync target registry every --interval.",
+	)
+	flagSet.DurationVar(
+		&Interval,
+		"interval",
+		6*time.Hour,
+		"Time between syncs with --watch, e.g. 30m or 6h.",
+	)
+	flagSet.StringVar(
+		&NotifyURL,
+		"notify-url",
+		os.Getenv("D8_MIRROR_NOTIFY_URL"),
+		"Webhook to POST summary of every finished sync to: mirrored releases, registry traffic, duration and errors.",
+	)
+	flagSet.StringVar(
+		&NotifyTemplate,
+		"notify-template",
+		string(notify.TemplateJSON),
+		`Shape of --notify-url message: "json" to post summary as is, "slack" for Slack incoming webhooks `+
+			`or "telegram" for Telegram Bot API sendMessage URL with chat_id query parameter.`,
+	)
+}
+
+func parseAndValidateSyncParameters(cmd *cobra.Command, args []string) error {
+	if err := flagrules.Validate(cmd.Flags(), syncFlagRules...); err != nil {
+		return err
+	}
+	if Interval < time.Minute {
+		return errors.New("--interval must be at least 1m")
+	}
+	var err error
+	if notifier, err = notify.NewNotifier(NotifyURL, notify.Template(NotifyTemplate)); err != nil {
+		return fmt.Errorf("Invalid --notify-url: %w", err)
+	}
+	return parseAndValidateParameters(cmd, args)
+}
+
+func syncDistribution(cmd *cobra.Command, _ []string) error {
+	logger := output.FromCommand(cmd).Logger()
+	if !Watch {
+		_, err := syncOnce(context.Background(), logger, nil)
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Releases copied by previous syncs are not planned again, their images are in target already.
+	synced := make([]semver.Version, 0)
+	for {
+		copied, err := syncOnce(ctx, logger, synced)
+		switch {
+		case ctx.Err() != nil:
+			logger.InfoLn("Sync stopped")
+			return nil
+		case err != nil:
+			logger.WarnF("Sync failed, next attempt in %s: %v", Interval, err)
+		default:
+			synced = append(synced, copied...)
+			logger.InfoF("Target registry is in sync, next sync in %s", Interval)
+		}
+
+		timer := time.NewTimer(Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.InfoLn("Sync stopped")
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// syncOnce copies releases that were not copied by previous syncs and posts summary of the sync to --notify-url.
+// Syncs interrupted by agent stop are not reported.
+func syncOnce(ctx context.Context, logger contexts.Logger, synced []semver.Version) ([]semver.Version, error) {
+	notifyRun := notify.StartRun("sync")
+	copied, err := copyReleases(ctx, logger, synced)
+	notifyRun.SetVersions(copied)
+	if ctx.Err() == nil {
+		notifier.Notify(logger, notifyRun.Summary(err))
+	}
+	return copied, err
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package copy
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/pull"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flagrules"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+)
+
+var releaseChannelNameRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+var flagRules = []flagrules.Rule{
+	flagrules.Conflicts("source-auth-file", "source-login", "license"),
+	flagrules.Conflicts("license", "source-login"),
+	flagrules.Conflicts("target-auth-file", "target-login"),
+	flagrules.Conflicts("release", "min-version", "since-channel").Because("it is ambiguous which releases to copy"),
+	flagrules.Conflicts("since-channel", "min-version").Because("it is ambiguous which releases to copy"),
+	flagrules.Conflicts("no-modules", "modules-path-suffix").Because("modules are not copied"),
+	flagrules.Requires("key", "verify-source-signatures"),
+	flagrules.Requires("signature-policy", "verify-source-signatures").Because("signatures are not verified"),
+}
+
+func parseAndValidateParameters(cmd *cobra.Command, _ []string) error {
+	var err error
+	if err = flagrules.Validate(cmd.Flags(), flagRules...); err != nil {
+		return err
+	}
+	if err = parseAndValidateVersionFlags(); err != nil {
+		return err
+	}
+	if err = parseAndValidateTargetFlag(); err != nil {
+		return err
+	}
+	SourceRegistryRepo = strings.TrimSuffix(SourceRegistryRepo, "/")
+	if TargetRegistryRepo == SourceRegistryRepo || strings.HasPrefix(TargetRegistryRepo, SourceRegistryRepo+"/") {
+		return errors.New("--target must not be the --source repository or nested in it")
+	}
+
+	if sourceAuth, err = authProvider(SourceRegistryRepo, SourceLogin, SourcePassword, SourceAuthFile); err != nil {
+		return fmt.Errorf("Invalid source credentials: %w", err)
+	}
+	if DeckhouseLicenseToken != "" {
+		sourceAuth = authn.FromConfig(authn.AuthConfig{Username: "license-token", Password: DeckhouseLicenseToken})
+	}
+	if targetAuth, err = authProvider(TargetRegistryRepo, TargetLogin, TargetPassword, TargetAuthFile); err != nil {
+		return fmt.Errorf("Invalid target credentials: %w", err)
+	}
+
+	ModulesPathSuffix = strings.Trim(ModulesPathSuffix, "/")
+	if err = contexts.ValidateModulesPathSuffix(ModulesPathSuffix); err != nil {
+		return fmt.Errorf("Invalid --modules-path-suffix: %w", err)
+	}
+
+	if PullConcurrency < 1 {
+		return errors.New("--pull-concurrency should be at least 1")
+	}
+	if Platforms, AllPlatforms, err = pull.ParsePlatforms(platformStrings); err != nil {
+		return err
+	}
+	return parseAndValidateSignatureFlags()
+}
+
+func parseAndValidateSignatureFlags() error {
+	if SignaturePolicy != contexts.SignaturePolicyEnforce && SignaturePolicy != contexts.SignaturePolicyWarn {
+		return fmt.Errorf("Unknown signature policy %q, expected %q or %q", SignaturePolicy, contexts.SignaturePolicyEnforce, contexts.SignaturePolicyWarn)
+	}
+	if !VerifySourceSignatures {
+		SourceSignatureKey = nil
+		return nil
+	}
+	if SourceSignatureKeyPath == "" {
+		return errors.New("--verify-source-signatures requires vendor public key to be provided with --key")
+	}
+
+	var err error
+	if SourceSignatureKey, err = signature.LoadVerificationKey(SourceSignatureKeyPath, ""); err != nil {
+		return fmt.Errorf("Load source signature verification key: %w", err)
+	}
+	return nil
+}
+
+func parseAndValidateVersionFlags() error {
+	if SinceChannel != "" && !releaseChannelNameRegexp.MatchString(SinceChannel) {
+		return fmt.Errorf("Invalid release channel name %q", SinceChannel)
+	}
+
+	var err error
+	if minVersionString != "" {
+		MinVersion, err = semver.NewVersion(minVersionString)
+		if err != nil {
+			return fmt.Errorf("Parse minimal deckhouse version: %w", err)
+		}
+	}
+	if specificReleaseString != "" {
+		SpecificRelease, err = semver.NewVersion(specificReleaseString)
+		if err != nil {
+			return fmt.Errorf("Parse required deckhouse version: %w", err)
+		}
+	}
+	return nil
+}
+
+func parseAndValidateTargetFlag() error {
+	target := strings.NewReplacer("http://", "", "https://", "").Replace(TargetRegistryRepo)
+	if target == "" {
+		return errors.New("--target is required")
+	}
+
+	targetURL, err := url.ParseRequestURI("docker://" + strings.TrimSuffix(target, "/"))
+	if err != nil {
+		return fmt.Errorf("Validate target registry address: %w", err)
+	}
+	TargetHost, TargetPath = targetURL.Host, targetURL.Path
+	if TargetHost == "" {
+		return errors.New("--target contains no registry host")
+	}
+	if TargetPath == "" {
+		return errors.New("--target contains no path to repo")
+	}
+	TargetRegistryRepo = TargetHost + TargetPath
+	return nil
+}
+
+// authProvider returns credentials for registry of repo given either by login and password or by file,
+// falling back to Docker config.
+func authProvider(repo, login, password, authFile string) (authn.Authenticator, error) {
+	if login == "" && password == "" && authFile == "" {
+		return auth.DefaultAuthenticator(repo), nil
+	}
+	if password != "" && login == "" {
+		return nil, errors.New("registry username not specified")
+	}
+	if authFile != "" {
+		return auth.LoadAuthFileForRepo(authFile, repo)
+	}
+	return authn.FromConfig(authn.AuthConfig{Username: login, Password: password}), nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package doctorbundle
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+)
+
+var doctorLong = templates.LongDesc(`
+Validate Deckhouse Kubernetes Platform distribution bundle end to end before importing it into the secure network.
+
+Following checks are run, each of them either passes, fails or is skipped if bundle lacks data for it:
+
+- integrity: every bundle file matches the digest recorded in <images-bundle-path>.manifest.json,
+  which is signed by the owner of the provided key, or of the certificate shipped with the bundle
+  if it is issued by CA given with --ca-roots. Signature is not verified without either of them;
+- contents: OCI layouts of the bundle and their image manifests can be read;
+- structure: no image is listed twice in layout index and multi-platform images have images of their platforms,
+  as well as of every platform given with --platforms;
+- manifests: every config and layer referenced by image manifests is stored in the bundle;
+- tags: no tag refers to several images and release channels point to the same Deckhouse version in every layout;
+- releases: images of every release listed in deckhousereleases.yaml and bundle release footprints are stored in the bundle.
+
+Command exits with non-zero code if any of the checks fails.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+var doctorExample = templates.Examples(`
+# Validate bundle pulled into /opt/d8-bundle
+d8 mirror doctor-bundle /opt/d8-bundle/d8.tar
+
+# Validate bundle signed with d8 mirror bundle sign and print machine-readable report
+d8 mirror doctor-bundle /opt/d8-bundle/d8.tar --key signer.pub -o json
+
+# Validate bundle pulled with --platforms linux/amd64,linux/arm64
+d8 mirror doctor-bundle /opt/d8-bundle/d8.tar --platforms linux/amd64,linux/arm64
+`)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// ErrFailed is returned when any of the bundle checks fails.
+var ErrFailed = errors.New("Bundle validation failed")
+
+func NewCommand() *cobra.Command {
+	doctorCmd := &cobra.Command{
+		Use:           "doctor-bundle <images-bundle-path>",
+		Short:         "Validate Deckhouse Kubernetes Platform distribution bundle end to end",
+		Long:          doctorLong,
+		Example:       doctorExample,
+		ValidArgs:     []string{"images-bundle-path"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          doctor,
+	}
+
+	addFlags(doctorCmd.Flags())
+	return doctorCmd
+}
+
+var (
+	ImagesBundlePath     string
+	VerificationKeyPath  string
+	CARootsPath          string
+	SignaturePath        string
+	ReleaseManifestsPath string
+	platformStrings      []string
+
+	platforms []v1.Platform
+
+	OutputFormat string
+)
+
+func doctor(cmd *cobra.Command, _ []string) error {
+	out := output.FromCommand(cmd)
+	logger := out.Logger()
+
+	var pub crypto.PublicKey
+	if VerificationKeyPath == "" && fileExists(shippedCertificatePath()) {
+		logger.WarnF("Signature of integrity manifest is not verified: certificate shipped with the bundle is only trusted with --ca-roots, pass them or --key")
+	}
+	if VerificationKeyPath != "" {
+		var err error
+		if pub, err = signature.LoadVerificationKey(VerificationKeyPath, CARootsPath); err != nil {
+			return err
+		}
+	}
+
+	var report *bundle.DoctorReport
+	err := logger.Process("Validate bundle", func() error {
+		report = bundle.Doctor(ImagesBundlePath, bundle.DoctorOptions{
+			VerificationKey:      pub,
+			SignaturePath:        SignaturePath,
+			ReleaseManifestsPath: ReleaseManifestsPath,
+			Platforms:            platforms,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if OutputFormat == outputJSON {
+		encoder := json.NewEncoder(out.Data())
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(report); err != nil {
+			return fmt.Errorf("Write report: %w", err)
+		}
+	} else {
+		printReport(out.Data(), report)
+	}
+
+	if !report.Passed() {
+		return ErrFailed
+	}
+	return nil
+}
+
+func printReport(w io.Writer, report *bundle.DoctorReport) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tSUMMARY")
+	for _, check := range report.Checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", check.Name, check.Status, check.Summary)
+	}
+	tw.Flush()
+
+	for _, check := range report.Checks {
+		if len(check.Problems) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s problems:\n", check.Name)
+		for _, problem := range check.Problems {
+			fmt.Fprintf(w, "  %s\n", problem)
+		}
+	}
+	fmt.Fprintf(w, "\n%s: %s\n", report.Bundle, report.Status)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package doctorbundle
+
+import (
+	"github.com/spf13/pflag"
+)
+
+func addFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&VerificationKeyPath,
+		"key",
+		"",
+		"Path to PEM-encoded public key or x509 certificate to verify signature of integrity manifest with. "+
+			"Defaults to the certificate shipped with the bundle, if --ca-roots is set. Signature is not verified without either.",
+	)
+	flagSet.StringVar(
+		&CARootsPath,
+		"ca-roots",
+		"",
+		"Path to PEM-encoded CA certificates that must have issued the signing certificate. "+
+			"Required to verify signature with the certificate shipped with the bundle.",
+	)
+	flagSet.StringVar(
+		&SignaturePath,
+		"signature",
+		"",
+		"Path to detached signature. Defaults to <images-bundle-path>.manifest.json.sig.",
+	)
+	flagSet.StringVar(
+		&ReleaseManifestsPath,
+		"release-manifests",
+		"",
+		"Path to DeckhouseRelease manifests generated by d8 mirror pull. Defaults to deckhousereleases.yaml next to the bundle.",
+	)
+	flagSet.StringSliceVar(
+		&platformStrings,
+		"platforms",
+		nil,
+		"Platforms that every multi-platform image of the bundle must have images of, like linux/amd64,linux/arm64, "+
+			"as given to d8 mirror pull --platforms.",
+	)
+	flagSet.StringVarP(
+		&OutputFormat,
+		"output",
+		"o",
+		outputText,
+		`Format of validation report: "text" or "json". JSON reports carry "kind" and "schemaVersion" fields, schema version changes only on incompatible changes.`,
+	)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package doctorbundle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+)
+
+func parseAndValidateParameters(_ *cobra.Command, args []string) error {
+	if l := len(args); l != 1 {
+		return fmt.Errorf("accepts 1 argument, received %d", l)
+	}
+
+	ImagesBundlePath = filepath.Clean(args[0])
+	if filepath.Ext(ImagesBundlePath) != ".tar" {
+		return errors.New("images-bundle-path argument should be a path to tar archive (.tar)")
+	}
+	if OutputFormat != outputText && OutputFormat != outputJSON {
+		return fmt.Errorf("Unknown --output %q, expected %q or %q", OutputFormat, outputText, outputJSON)
+	}
+	platforms = nil
+	for _, platformString := range platformStrings {
+		platform, err := v1.ParsePlatform(strings.TrimSpace(platformString))
+		if err != nil {
+			return fmt.Errorf("Invalid --platforms value %q: %w", platformString, err)
+		}
+		if platform.OS == "" || platform.Architecture == "" {
+			return fmt.Errorf("Invalid --platforms value %q: expected os/arch[/variant]", platformString)
+		}
+		platforms = append(platforms, *platform)
+	}
+
+	// Certificate shipped with the bundle could have been replaced along with the signature,
+	// so it is only trusted when it has to chain to --ca-roots. Signature is not verified otherwise, see doctor.
+	if VerificationKeyPath == "" && CARootsPath != "" {
+		if !fileExists(shippedCertificatePath()) {
+			return errors.New("--ca-roots requires --key as bundle has no certificate shipped with it")
+		}
+		VerificationKeyPath = shippedCertificatePath()
+	}
+	return nil
+}
+
+func shippedCertificatePath() string {
+	return bundle.IntegrityManifestPath(ImagesBundlePath) + ".crt"
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
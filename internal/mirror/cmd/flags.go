@@ -0,0 +1,81 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mirror
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func addPersistentFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringArrayVar(
+		&RegistryCAs,
+		"registry-ca",
+		nil,
+		"Trust certificates of the registry host signed by CAs from the given PEM bundle, in addition to system CAs, as <host>=<path>, e.g. registry.example.com:5000=/etc/ssl/registry-ca.pem. Can be repeated to trust different CAs for source and target registries.",
+	)
+	flagSet.StringArrayVar(
+		&RegistryClientCerts,
+		"registry-client-cert",
+		nil,
+		"Authenticate to the registry host that requires mutual TLS with client certificate from the given PEM file, as <host>=<path>. "+
+			"Must be accompanied by --registry-client-key for the same host. Can be repeated to use different certificates for source and target registries.",
+	)
+	flagSet.StringArrayVar(
+		&RegistryClientKeys,
+		"registry-client-key",
+		nil,
+		"Private key of the --registry-client-cert of the registry host, as <host>=<path to PEM file>.",
+	)
+	flagSet.BoolVar(
+		&NoDockerConfig,
+		"no-docker-config",
+		false,
+		"Do not look up registry credentials in Docker config.json, including its credential helpers, when no credentials are given with flags.",
+	)
+	flagSet.UintVar(
+		&RetryCount,
+		"retry-count",
+		3,
+		"How many times registry requests that failed with network errors, timeouts or 5xx responses are repeated. 0 disables retries. Requests throttled by registry with 429 Too Many Requests are repeated regardless.",
+	)
+	flagSet.DurationVar(
+		&RetryBackoff,
+		"retry-backoff",
+		time.Second,
+		"Wait before the first retry of failed registry request, doubled with every next retry and randomized. Retry-After header of the response takes precedence.",
+	)
+	flagSet.StringVar(
+		&HTTPProxy,
+		"http-proxy",
+		"",
+		"Proxy for plain HTTP registry requests. Overrides HTTP_PROXY environment variable.",
+	)
+	flagSet.StringVar(
+		&HTTPSProxy,
+		"https-proxy",
+		"",
+		"Proxy for HTTPS registry requests. Overrides HTTPS_PROXY environment variable.",
+	)
+	flagSet.StringVar(
+		&NoProxy,
+		"no-proxy",
+		"",
+		"Comma-separated hosts, domains, IPs and CIDRs of registries that are reached directly, bypassing proxies, e.g. registry.local,.corp.example.com,10.0.0.0/8. Overrides NO_PROXY environment variable.",
+	)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inittarget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/operations"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+)
+
+var initTargetLong = templates.LongDesc(`
+Prepare third-party registry to receive Deckhouse Kubernetes Platform distribution.
+
+This command is meant to be run once before the first d8 mirror push to the registry.
+It creates Harbor project if the registry is Harbor, creates every repository that d8 mirror push writes to,
+validating push permission on each of them, and writes readiness report.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+func NewCommand() *cobra.Command {
+	initTargetCmd := &cobra.Command{
+		Use:           "init-target <registry>",
+		Short:         "Prepare third-party registry to receive Deckhouse Kubernetes Platform distribution",
+		Long:          initTargetLong,
+		ValidArgs:     []string{"registry"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          initTarget,
+	}
+
+	addFlags(initTargetCmd.Flags())
+	return initTargetCmd
+}
+
+var (
+	RegistryHost     string
+	RegistryPath     string
+	RegistryUsername string
+	RegistryPassword string
+	RegistryAuthFile string
+
+	registryFileAuth authn.Authenticator
+
+	Insecure            bool
+	TLSSkipVerify       bool
+	FlattenRepositories bool
+	ModulesPathSuffix   string
+
+	ReportPath string
+)
+
+func initTarget(cmd *cobra.Command, _ []string) error {
+	mirrorCtx := &contexts.PushContext{
+		BaseContext: contexts.BaseContext{
+			Logger:              output.FromCommand(cmd).Logger(),
+			Insecure:            Insecure,
+			SkipTLSVerification: TLSSkipVerify,
+			RegistryHost:        RegistryHost,
+			RegistryPath:        RegistryPath,
+			RegistryAuth:        getRegistryAuthProvider(),
+			ModulesPathSuffix:   ModulesPathSuffix,
+		},
+		FlattenRepositories: FlattenRepositories,
+	}
+
+	report, err := operations.InitTarget(context.Background(), mirrorCtx)
+	if err != nil {
+		return fmt.Errorf("Initialize target registry: %w", err)
+	}
+	if err = report.Save(ReportPath); err != nil {
+		return err
+	}
+	mirrorCtx.Logger.InfoF("Readiness report is written to %s", ReportPath)
+
+	if !report.Ready {
+		return errors.New("Target registry is not ready for push, see readiness report for details")
+	}
+	mirrorCtx.Logger.InfoLn("Target registry is ready for push")
+	return nil
+}
+
+func getRegistryAuthProvider() authn.Authenticator {
+	if registryFileAuth != nil {
+		return registryFileAuth
+	}
+	if RegistryUsername != "" {
+		return authn.FromConfig(authn.AuthConfig{
+			Username: RegistryUsername,
+			Password: RegistryPassword,
+		})
+	}
+	return auth.DefaultAuthenticator(RegistryHost + RegistryPath)
+}
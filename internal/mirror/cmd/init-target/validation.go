@@ -0,0 +1,97 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inittarget
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+)
+
+func parseAndValidateParameters(_ *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("invalid number of arguments, expected 1")
+	}
+
+	var err error
+	if err = parseAndValidateRegistryURLArg(args); err != nil {
+		return err
+	}
+	if err = validateRegistryCredentials(); err != nil {
+		return err
+	}
+	if err = validateModulesPathSuffixFlag(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func validateRegistryCredentials() error {
+	if RegistryPassword != "" && RegistryUsername == "" {
+		return errors.New("registry username not specified")
+	}
+	if RegistryAuthFile == "" {
+		return nil
+	}
+	if RegistryUsername != "" {
+		return errors.New("--target-auth-file cannot be used together with --registry-login")
+	}
+
+	var err error
+	registryFileAuth, err = auth.LoadAuthFile(RegistryAuthFile, RegistryHost)
+	if err != nil {
+		return fmt.Errorf("Invalid --target-auth-file: %w", err)
+	}
+	return nil
+}
+
+func parseAndValidateRegistryURLArg(args []string) error {
+	registry := strings.NewReplacer("http://", "", "https://", "").Replace(args[0])
+	if registry == "" {
+		return errors.New("<registry> argument is empty")
+	}
+
+	registryUrl, err := url.ParseRequestURI("docker://" + registry)
+	if err != nil {
+		return fmt.Errorf("Validate registry address: %w", err)
+	}
+	RegistryHost = registryUrl.Host
+	RegistryPath = registryUrl.Path
+	if RegistryHost == "" {
+		return errors.New("<registry> argument contains no registry host. Please specify registry address correctly.")
+	}
+	if RegistryPath == "" {
+		return errors.New("<registry> argument contains no path to repo. Please specify registry repo path correctly.")
+	}
+
+	return nil
+}
+
+func validateModulesPathSuffixFlag() error {
+	ModulesPathSuffix = strings.Trim(ModulesPathSuffix, "/")
+	if err := contexts.ValidateModulesPathSuffix(ModulesPathSuffix); err != nil {
+		return fmt.Errorf("Invalid --modules-path-suffix: %w", err)
+	}
+	return nil
+}
@@ -18,15 +18,28 @@ package mirror
 
 import (
 	"os"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/spf13/cobra"
 	"k8s.io/kubectl/pkg/util/templates"
 
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/bundle"
+	checktarget "github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/check-target"
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/clean"
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/compare"
+	mirrorcopy "github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/copy"
+	doctorbundle "github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/doctor-bundle"
+	inittarget "github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/init-target"
 	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/modules"
 	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/pull"
 	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/push"
+	releasemanifests "github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/release-manifests"
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/sbom"
+	verifysignatures "github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/verify-signatures"
 	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/vulndb"
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
 )
 
@@ -43,18 +56,63 @@ valid license for any commercial version of the Deckhouse Kubernetes Platform.
 
 © Flant JSC 2024`)
 
+var (
+	RegistryCAs         []string
+	RegistryClientCerts []string
+	RegistryClientKeys  []string
+	NoDockerConfig      bool
+
+	RetryCount   uint
+	RetryBackoff time.Duration
+
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+)
+
 func NewCommand() *cobra.Command {
 	mirrorCmd := &cobra.Command{
 		Use:   "mirror",
 		Short: "Copy Deckhouse Kubernetes Platform distribution to the local filesystem or third-party registry",
 		Long:  mirrorLong,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if err := output.ValidateFlags(cmd); err != nil {
+				return err
+			}
+			if NoDockerConfig {
+				auth.DisableDockerConfig()
+			}
+			if err := parseRetryFlags(); err != nil {
+				return err
+			}
+			if err := parseProxyFlags(); err != nil {
+				return err
+			}
+			if err := parseRegistryClientCertFlags(); err != nil {
+				return err
+			}
+			return parseRegistryCAFlag()
+		},
 	}
+	output.AddPersistentFlags(mirrorCmd)
+	addPersistentFlags(mirrorCmd.PersistentFlags())
 
 	mirrorCmd.AddCommand(
 		pull.NewCommand(),
 		push.NewCommand(),
+		inittarget.NewCommand(),
+		checktarget.NewCommand(),
 		modules.NewCommand(),
 		vulndb.NewCommand(),
+		bundle.NewCommand(),
+		compare.NewCommand(),
+		mirrorcopy.NewCommand(),
+		mirrorcopy.NewSyncCommand(),
+		verifysignatures.NewCommand(),
+		sbom.NewCommand(),
+		doctorbundle.NewCommand(),
+		releasemanifests.NewCommand(),
+		clean.NewCommand(),
 	)
 
 	debugLogLevel := log.DebugLogLevel()
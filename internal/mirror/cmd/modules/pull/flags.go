@@ -41,6 +41,7 @@ func addFlags(flagSet *pflag.FlagSet) {
 		"f",
 		"",
 		`Filter which modules starting with which version to pull. Format is "moduleName@v1.2.3" separated by ';' where version after @ is the earliest pulled version of the module.
+Semver range can be used instead of the version to pull only matching module releases, e.g. "moduleName@>=1.4 <1.6".
 If the version of the module specified in the filter exceeds the version of the RockSolid channel of this module, then the version from RockSolid is considered as the filter version for the module.`,
 	)
 	flagSet.BoolVar(
@@ -20,7 +20,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log/slog"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -32,10 +31,10 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/deckhouse/deckhouse-cli/internal/mirror/api/v1alpha1"
+	"github.com/deckhouse/deckhouse-cli/internal/output"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/modules"
-	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
 )
 
 var pullLong = templates.LongDesc(`
@@ -73,12 +72,8 @@ var (
 	SkipTLSVerify bool
 )
 
-func pull(_ *cobra.Command, _ []string) error {
-	logLevel := slog.LevelInfo
-	if log.DebugLogLevel() >= 3 {
-		logLevel = slog.LevelDebug
-	}
-	logger := log.NewSLogger(logLevel)
+func pull(cmd *cobra.Command, _ []string) error {
+	logger := output.FromCommand(cmd).Logger()
 
 	return pullExternalModulesToLocalFS(
 		logger,
@@ -125,7 +120,9 @@ func pullExternalModulesToLocalFS(
 				continue
 			}
 
-			modulesFilter.FilterReleases(&moduleData)
+			if err = modulesFilter.FilterReleases(&moduleData); err != nil {
+				return fmt.Errorf("Bad modules filter: %w", err)
+			}
 			filteredModules = append(filteredModules, moduleData)
 		}
 		modulesFromRepo = filteredModules
@@ -36,7 +36,7 @@ func validateModuleFilterFormat() error {
 		return nil
 	}
 
-	if !regexp.MustCompile(`([a-zA-Z0-9-_]+@(v?\d+\.\d+\.\d+));?`).MatchString(ModulesFilter) {
+	if !regexp.MustCompile(`([a-zA-Z0-9-_]+@\s*([<>=!~^]*\s*v?\d+(\.\d+){0,2}));?`).MatchString(ModulesFilter) {
 		return errors.New("Invalid filter pattern")
 	}
 
@@ -51,6 +51,13 @@ func addFlags(flagSet *pflag.FlagSet) {
 		os.Getenv("D8_MIRROR_REGISTRY_PASSWORD"),
 		"Password to log into your registry",
 	)
+	flagSet.StringVar(
+		&MirrorModulesRegistryAuthFile,
+		"target-auth-file",
+		os.Getenv("D8_MIRROR_REGISTRY_AUTH_FILE"),
+		"File with credentials to log into your registry, either Docker config.json or a single username:password line. "+
+			"Must be accessible only by its owner. Conflicts with --registry-login.",
+	)
 	flagSet.BoolVar(
 		&MirrorModulesTLSSkipVerify,
 		"tls-skip-verify",
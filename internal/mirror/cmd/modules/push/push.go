@@ -18,7 +18,6 @@ package push
 
 import (
 	"fmt"
-	"log/slog"
 	"os"
 	"path"
 	"path/filepath"
@@ -31,10 +30,10 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/kubectl/pkg/util/templates"
 
+	"github.com/deckhouse/deckhouse-cli/internal/output"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
-	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
 )
 
 var pushLong = templates.LongDesc(`
@@ -72,17 +71,16 @@ var (
 	MirrorModulesRegistry         string
 	MirrorModulesRegistryUsername string
 	MirrorModulesRegistryPassword string
+	MirrorModulesRegistryAuthFile string
+
+	registryFileAuth authn.Authenticator
 
 	MirrorModulesInsecure      bool
 	MirrorModulesTLSSkipVerify bool
 )
 
-func push(_ *cobra.Command, _ []string) error {
-	logLevel := slog.LevelInfo
-	if log.DebugLogLevel() >= 3 {
-		logLevel = slog.LevelDebug
-	}
-	logger := log.NewSLogger(logLevel)
+func push(cmd *cobra.Command, _ []string) error {
+	logger := output.FromCommand(cmd).Logger()
 
 	var authProvider authn.Authenticator = nil
 	if MirrorModulesRegistryUsername != "" {
@@ -91,6 +89,9 @@ func push(_ *cobra.Command, _ []string) error {
 			Password: MirrorModulesRegistryPassword,
 		})
 	}
+	if registryFileAuth != nil {
+		authProvider = registryFileAuth
+	}
 
 	return pushModulesToRegistry(
 		logger,
@@ -115,6 +116,7 @@ func pushModulesToRegistry(
 	}
 
 	refOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptions(authProvider, insecure, skipVerifyTLS)
+	pushedBlobs := layouts.NewPushedBlobs()
 
 	for i, entry := range dirEntries {
 		if !entry.IsDir() {
@@ -144,6 +146,7 @@ func pushModulesToRegistry(
 			contexts.DefaultParallelism,
 			insecure,
 			skipVerifyTLS,
+			layouts.WithPushedBlobs(pushedBlobs),
 		); err != nil {
 			return fmt.Errorf("Push module to registry: %w", err)
 		}
@@ -157,6 +160,7 @@ func pushModulesToRegistry(
 			contexts.DefaultParallelism,
 			insecure,
 			skipVerifyTLS,
+			layouts.WithPushedBlobs(pushedBlobs),
 		); err != nil {
 			return fmt.Errorf("Push module to registry: %w", err)
 		}
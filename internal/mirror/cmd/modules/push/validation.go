@@ -22,6 +22,8 @@ import (
 	"net/url"
 
 	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
 )
 
 func parseAndValidateParameters(_ *cobra.Command, _ []string) error {
@@ -43,5 +45,16 @@ func validateRegistryFlags() error {
 	if MirrorModulesRegistryPassword != "" && MirrorModulesRegistryUsername == "" {
 		return errors.New("Registry credentials not provided")
 	}
+
+	if MirrorModulesRegistryAuthFile != "" {
+		if MirrorModulesRegistryUsername != "" {
+			return errors.New("--target-auth-file cannot be used together with --registry-login")
+		}
+		var err error
+		registryFileAuth, err = auth.LoadAuthFileForRepo(MirrorModulesRegistryAuthFile, MirrorModulesRegistry)
+		if err != nil {
+			return fmt.Errorf("Invalid --target-auth-file: %w", err)
+		}
+	}
 	return nil
 }
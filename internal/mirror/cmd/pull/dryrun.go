@@ -0,0 +1,222 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pull
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"text/tabwriter"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/images"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/modules"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/workdir"
+)
+
+// PlanDeckhouseDownload walks the same steps as PullDeckhouseToLocalFS, but only resolves manifests of images
+// instead of pulling them. Empty layouts are created under layoutsRoot to group images the way pull does.
+// Installers are read directly from the source registry, as lists of Deckhouse images are stored in them.
+func PlanDeckhouseDownload(
+	pullCtx *contexts.PullContext,
+	versions []semver.Version,
+	modulesSpec *modules.Filter,
+	layoutsRoot string,
+) (*layouts.DownloadPlan, error) {
+	logger := pullCtx.Logger
+	var err error
+	modulesData := make([]modules.Module, 0)
+
+	if !pullCtx.SkipModulesPull {
+		logger.InfoF("Fetching Deckhouse external modules list")
+		modulesData, err = getDeckhouseExternalModules(pullCtx, modulesSpec)
+		if err != nil {
+			return nil, fmt.Errorf("get Deckhouse modules: %w", err)
+		}
+	}
+
+	imageLayouts, err := layouts.CreateOCIImageLayoutsForDeckhouse(layoutsRoot, modulesData)
+	if err != nil {
+		return nil, fmt.Errorf("create OCI Image Layouts: %w", err)
+	}
+	layouts.FillLayoutsWithBasicDeckhouseImages(pullCtx, imageLayouts, versions)
+	layouts.LeaveOutSkippedComponents(pullCtx, imageLayouts)
+	if err = imageLayouts.TagsResolver.ResolveTagsDigestsForImageLayouts(&pullCtx.BaseContext, imageLayouts); err != nil {
+		return nil, fmt.Errorf("Resolve images tags to digests: %w", err)
+	}
+
+	plan := layouts.NewDownloadPlan(layoutsRoot)
+	withDigests := layouts.WithTagToDigestMapper(imageLayouts.TagsResolver.GetTagDigest)
+	if err = layouts.PlanImageSet(pullCtx, plan, imageLayouts.Install, imageLayouts.InstallImages, withDigests); err != nil {
+		return nil, fmt.Errorf("plan installers: %w", err)
+	}
+	if err = layouts.PlanImageSet(
+		pullCtx, plan, imageLayouts.InstallStandalone, imageLayouts.InstallStandaloneImages,
+		withDigests, layouts.WithAllowMissingTags(true),
+	); err != nil {
+		return nil, fmt.Errorf("plan standalone installers: %w", err)
+	}
+	if err = layouts.PlanImageSet(
+		pullCtx, plan, imageLayouts.ReleaseChannel, imageLayouts.ReleaseChannelImages,
+		withDigests, layouts.WithAllowMissingTags(pullCtx.SpecificVersion != nil),
+	); err != nil {
+		return nil, fmt.Errorf("plan release channels: %w", err)
+	}
+
+	logger.InfoF("Searching for Deckhouse built-in modules digests")
+	installerImages, err := readInstallersImages(pullCtx, imageLayouts)
+	if err != nil {
+		return nil, err
+	}
+	suspiciousImages, err := images.VerifyImagesExistInRegistry(&pullCtx.BaseContext, installerImages)
+	if err != nil {
+		return nil, fmt.Errorf("verify images digests: %w", err)
+	}
+	for _, suspiciousImage := range suspiciousImages {
+		logger.WarnF("⚠️ Suspicious image extracted from installer %s would be skipped", suspiciousImage)
+	}
+	maps.Copy(imageLayouts.DeckhouseImages, installerImages)
+	if err = layouts.PlanImageSet(
+		pullCtx, plan, imageLayouts.Deckhouse, imageLayouts.DeckhouseImages,
+		withDigests, layouts.WithSkipAnnotated(pullCtx.SkipAnnotated),
+	); err != nil {
+		return nil, fmt.Errorf("plan Deckhouse: %w", err)
+	}
+
+	if err = layouts.PlanTrivyVulnerabilityDatabasesImages(pullCtx, plan, imageLayouts); err != nil {
+		return nil, err
+	}
+
+	if pullCtx.SkipModulesPull {
+		return plan, nil
+	}
+	logger.InfoLn("Searching for Deckhouse external modules images")
+	if err = layouts.FindDeckhouseModulesImages(pullCtx, imageLayouts); err != nil {
+		return nil, fmt.Errorf("find Deckhouse modules images: %w", err)
+	}
+	for moduleName, moduleData := range imageLayouts.Modules {
+		if err = layouts.PlanImageSet(
+			pullCtx, plan, moduleData.ModuleLayout, moduleData.ModuleImages,
+			withDigests, layouts.WithStage(contexts.StageModules), layouts.WithSkipAnnotated(pullCtx.SkipAnnotated),
+		); err != nil {
+			return nil, fmt.Errorf("plan %q module: %w", moduleName, err)
+		}
+		if err = layouts.PlanImageSet(
+			pullCtx, plan, moduleData.ReleasesLayout, moduleData.ReleaseImages,
+			withDigests, layouts.WithAllowMissingTags(true), layouts.WithStage(contexts.StageModules),
+		); err != nil {
+			return nil, fmt.Errorf("plan %q module release information: %w", moduleName, err)
+		}
+	}
+	return plan, nil
+}
+
+// readInstallersImages reads references of Deckhouse images from installers in the source registry.
+func readInstallersImages(pullCtx *contexts.PullContext, imageLayouts *layouts.ImageLayouts) (map[string]struct{}, error) {
+	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&pullCtx.BaseContext)
+	remoteOpts = append(remoteOpts, remote.WithContext(pullCtx.Run.Context()))
+
+	installerImages := map[string]struct{}{}
+	for imageTag := range imageLayouts.InstallImages {
+		if _, excluded := pullCtx.Exclusions.MatchReference(imageTag); excluded {
+			continue
+		}
+		ref, err := name.ParseReference(imageTag, nameOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("parse installer reference %q: %w", imageTag, err)
+		}
+		if digest := imageLayouts.TagsResolver.GetTagDigest(imageTag); digest != nil {
+			ref = ref.Context().Digest(digest.String())
+		}
+
+		img, err := remote.Image(ref, remoteOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("read installer %q: %w", imageTag, err)
+		}
+		digests, err := images.ExtractImageDigestsFromInstallerImage(pullCtx, imageTag, img)
+		if err != nil {
+			return nil, fmt.Errorf("extract images digests: %w", err)
+		}
+		maps.Copy(installerImages, digests)
+	}
+	return installerImages, nil
+}
+
+// dryRun prints estimated download size of every bundle component without downloading images.
+// Every image pull would download is also listed unless only the estimate is requested.
+func dryRun(mirrorCtx *contexts.PullContext, versions []semver.Version, workDirs *workdir.Manager, out *output.Output) error {
+	layoutsDir, err := workDirs.Create(pullWorkDir() + "-dry-run")
+	if err != nil {
+		return err
+	}
+
+	var plan *layouts.DownloadPlan
+	err = mirrorCtx.Logger.Process("Plan images download", func() error {
+		plan, err = PlanDeckhouseDownload(mirrorCtx, versions, ModulesSpec, layoutsDir.Path)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if DryRun {
+		printDownloadPlan(out.Data(), plan)
+		fmt.Fprintln(out.Data())
+	}
+	printComponentSizes(out.Data(), plan)
+	return nil
+}
+
+func printDownloadPlan(w io.Writer, plan *layouts.DownloadPlan) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "LAYOUT\tREFERENCE\tDIGEST\tSIZE")
+	planned := plan.Images()
+	for _, image := range planned {
+		layoutPath := image.Layout
+		if layoutPath == "" {
+			layoutPath = "<root>"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", layoutPath, image.Reference, image.Digest, formatSize(image.Size))
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d images\t\t%s\n", len(planned), formatSize(plan.TotalSize()))
+}
+
+func printComponentSizes(w io.Writer, plan *layouts.DownloadPlan) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "COMPONENT\tIMAGES\tESTIMATED SIZE")
+	var totalImages int
+	for _, component := range plan.ComponentSizes() {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", component.Component, component.Images, formatSize(component.Size))
+		totalImages += component.Images
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\t%s\n", totalImages, formatSize(plan.TotalSize()))
+}
+
+func formatSize(size int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(size)/1024/1024)
+}
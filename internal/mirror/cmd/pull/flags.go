@@ -20,6 +20,10 @@ import (
 	"os"
 
 	"github.com/spf13/pflag"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/health"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/notify"
 )
 
 func addFlags(flagSet *pflag.FlagSet) {
@@ -29,6 +33,13 @@ func addFlags(flagSet *pflag.FlagSet) {
 		enterpriseEditionRepo,
 		"Source registry to pull Deckhouse images from.",
 	)
+	flagSet.StringArrayVar(
+		&SourceFallbackRepos,
+		"source-fallback",
+		nil,
+		"Mirror of the --source registry to pull from when it is unreachable or throttles requests. "+
+			"Mirrors must serve the same repositories and accept the same credentials. May be repeated, mirrors are tried in the given order.",
+	)
 	flagSet.StringVar(
 		&SourceRegistryLogin,
 		"source-login",
@@ -41,6 +52,13 @@ func addFlags(flagSet *pflag.FlagSet) {
 		os.Getenv("D8_MIRROR_SOURCE_PASSWORD"),
 		"Source registry password.",
 	)
+	flagSet.StringVar(
+		&SourceRegistryAuthFile,
+		"source-insecure-basic-auth-file",
+		os.Getenv("D8_MIRROR_SOURCE_AUTH_FILE"),
+		"File with source registry credentials, either Docker config.json or a single username:password line. "+
+			"Must be accessible only by its owner. Conflicts with --source-login and --license.",
+	)
 	flagSet.StringVarP(
 		&DeckhouseLicenseToken,
 		"license",
@@ -53,20 +71,78 @@ func addFlags(flagSet *pflag.FlagSet) {
 		"min-version",
 		"m",
 		"",
-		"Minimal Deckhouse release to copy. Ignored if above current Rock Solid release. Conflicts with --release.",
+		"Minimal Deckhouse release to copy. Ignored if above current Rock Solid release. Conflicts with --release and --since-channel.",
+	)
+	flagSet.StringVar(
+		&SinceChannel,
+		"since-channel",
+		"",
+		"Copy Deckhouse releases starting from the current version of the given release channel, e.g. lts or rock-solid. Conflicts with --min-version and --release.",
 	)
 	flagSet.StringVar(
 		&specificReleaseString,
 		"release",
 		"",
-		"Specific Deckhouse release to copy. Conflicts with --min-version. WARNING!: Clusters installed with this option will not be able to automatically update due to lack of release-channels information in bundle and, as such, will require special attention and manual intervention during updates.",
+		"Specific Deckhouse release to copy. Conflicts with --min-version and --since-channel. WARNING!: Clusters installed with this option will not be able to automatically update due to lack of release-channels information in bundle and, as such, will require special attention and manual intervention during updates.",
+	)
+	flagSet.StringVar(
+		&ExplainVersionsPath,
+		"explain-versions",
+		"",
+		"Write JSON description of how Deckhouse releases to copy were selected to the given file. Conflicts with --release.",
+	)
+	flagSet.StringVar(
+		&InventoryPath,
+		"inventory-file",
+		"",
+		"Write CycloneDX JSON inventory of pulled images with their source, tag, digest and size to the given file.",
+	)
+	flagSet.StringVar(
+		&ChecksumsSigningKeyPath,
+		"sign-checksums",
+		"",
+		"Path to PEM-encoded unencrypted private key (ECDSA, RSA or Ed25519) to sign checksums.sha256 written next to the bundle with. "+
+			"Signature is written to checksums.sha256.sig.",
+	)
+	flagSet.StringArrayVar(
+		&EncryptRecipientPaths,
+		"encrypt-recipient",
+		nil,
+		"Path to armored or binary OpenPGP public key, e.g. exported with gpg --export, to encrypt the bundle to. "+
+			"Can be repeated, every recipient can decrypt the bundle. Encrypted bundle is decrypted by d8 mirror push --decrypt-key.",
+	)
+	flagSet.StringVar(
+		&DiffAgainst,
+		"diff-against",
+		"",
+		"Path or S3 URL of previously pulled bundle tar archive, chunked or not. Layers stored in it are left out of the new bundle, which can then only be pushed into registry the base bundle was already pushed to.",
 	)
 	flagSet.Int64VarP(
 		&ImagesBundleChunkSizeGB,
 		"images-bundle-chunk-size",
 		"c",
 		0,
-		"Split resulting bundle file into chunks of at most N gigabytes",
+		"Split resulting bundle file into chunks of at most N gigabytes. "+
+			"If packing of chunked bundle is interrupted, the next pull continues it after the last file packed completely.",
+	)
+	flagSet.StringVar(
+		&ChunkPrefix,
+		"chunk-prefix",
+		"",
+		"Prefix prepended to the name of bundle chunks, so that chunks of several bundles can be kept in one directory, "+
+			"e.g. --chunk-prefix ee- writes ee-d8.tar.0000.chunk and so on. The bundle is then pushed as ee-d8.tar.",
+	)
+	flagSet.IntVar(
+		&PullConcurrency,
+		"pull-concurrency",
+		4,
+		"Number of images pulled in parallel. Layers of every image are always downloaded in parallel.",
+	)
+	flagSet.StringVar(
+		&rateLimitString,
+		"rate-limit",
+		"",
+		"Limit bandwidth of all registry transfers to this many bytes per second, e.g. 50MiB or 500KB.",
 	)
 	flagSet.BoolVar(
 		&DoGOSTDigest,
@@ -74,6 +150,13 @@ func addFlags(flagSet *pflag.FlagSet) {
 		false,
 		"Calculate GOST R 34.11-2012 STREEBOG digest for downloaded bundle",
 	)
+	flagSet.DurationVar(
+		&MaxSecurityDBAge,
+		"max-security-db-age",
+		0,
+		"Fail if vulnerability databases in source registry were built longer ago than this, e.g. 72h. "+
+			"Databases older than a week are always warned about.",
+	)
 	flagSet.BoolVar(
 		&DontContinuePartialPull,
 		"no-pull-resume",
@@ -86,6 +169,63 @@ func addFlags(flagSet *pflag.FlagSet) {
 		false,
 		"Do not pull Deckhouse modules into bundle.",
 	)
+	flagSet.StringSliceVar(
+		&OnlyComponents,
+		"only",
+		nil,
+		"Pull only the given components of distribution: platform (Deckhouse, installers and release channels), modules, security (vulnerability databases) "+
+			"or install-standalone (standalone installers). Comma-separated or repeated.",
+	)
+	flagSet.BoolVar(
+		&SkipPlatform,
+		"skip-platform",
+		false,
+		"Do not pull Deckhouse images, installers and release channels.",
+	)
+	flagSet.BoolVar(
+		&SkipModules,
+		"skip-modules",
+		false,
+		"Do not pull Deckhouse modules, same as --no-modules.",
+	)
+	flagSet.BoolVar(
+		&SkipSecurity,
+		"skip-security",
+		false,
+		"Do not pull vulnerability databases.",
+	)
+	flagSet.BoolVar(
+		&SkipStandalone,
+		"skip-install-standalone",
+		false,
+		"Do not pull standalone installers.",
+	)
+	flagSet.BoolVar(
+		&NoPack,
+		"no-pack",
+		false,
+		"Leave pulled OCI image layouts in images-bundle-path directory instead of packing them into tar archive. Directory can be pushed with d8 mirror push as is.",
+	)
+	flagSet.BoolVar(
+		&AllowIncomplete,
+		"allow-incomplete",
+		false,
+		"Only warn about Deckhouse releases and release channels whose installer or standalone installer is missing from source registry, "+
+			"and about images missing some of --platforms, instead of failing the pull.",
+	)
+	flagSet.StringVar(
+		&ModulesPathSuffix,
+		"modules-path-suffix",
+		contexts.DefaultModulesPathSuffix,
+		"Path of modules repositories relative to the source repo, for registries that host modules outside of the standard location.",
+	)
+	flagSet.StringVar(
+		&ModulesSpecPath,
+		"modules-spec",
+		"",
+		"Path to YAML file listing the only modules to pull, each with exact version or semver range of versions. "+
+			"Pinned modules are pulled in matching versions only, regardless of what their release channels point to.",
+	)
 	flagSet.BoolVar(
 		&TLSSkipVerify,
 		"tls-skip-verify",
@@ -98,4 +238,119 @@ func addFlags(flagSet *pflag.FlagSet) {
 		false,
 		"Interact with registries over HTTP.",
 	)
+	flagSet.BoolVar(
+		&VerifySourceSignatures,
+		"verify-source-signatures",
+		false,
+		"Verify cosign signatures of pulled images with the public key provided by --key.",
+	)
+	flagSet.StringVar(
+		&SourceSignatureKeyPath,
+		"key",
+		"",
+		"Path to PEM-encoded vendor public key or certificate to verify source images signatures with.",
+	)
+	flagSet.StringVar(
+		&SignaturePolicy,
+		"signature-policy",
+		contexts.SignaturePolicyEnforce,
+		`What to do if image signature is missing or invalid: "enforce" fails the pull, "warn" only reports it.`,
+	)
+	flagSet.BoolVar(
+		&IncludeCosign,
+		"include-cosign",
+		false,
+		"Also pull cosign signatures, attestations and SBOMs (.sig, .att and .sbom tags) of every pulled image.",
+	)
+	flagSet.StringSliceVar(
+		&platformStrings,
+		"platforms",
+		nil,
+		"Pull multi-platform images as image indexes thinned to the given platforms, like linux/amd64,linux/arm64, or kept whole with \"all\". "+
+			"By default only linux/amd64 image is pulled from multi-platform index.",
+	)
+	flagSet.StringArrayVar(
+		&skipAnnotatedStrings,
+		"skip-annotated",
+		nil,
+		"Do not pull Deckhouse and module images that have manifest annotation or config label key=value, e.g. io.deckhouse.image/deprecated=true. May be repeated.",
+	)
+	flagSet.StringArrayVar(
+		&ExcludeRefs,
+		"exclude-ref",
+		nil,
+		"Do not pull images whose references match this glob, where * matches any characters, "+
+			"e.g. '*/modules/console:*'. Exclusions are recorded in the bundle. May be repeated.",
+	)
+	flagSet.StringArrayVar(
+		&ExcludeDigests,
+		"exclude-digest",
+		nil,
+		"Do not pull images with this manifest digest, e.g. sha256:0123... Exclusions are recorded in the bundle. May be repeated.",
+	)
+	flagSet.BoolVar(
+		&DryRun,
+		"dry-run",
+		false,
+		"Print every image that would be pulled with its size and the estimated bundle size instead of pulling. "+
+			"Installers are still read from the source registry to find Deckhouse images.",
+	)
+	flagSet.BoolVar(
+		&EstimateSize,
+		"estimate-size",
+		false,
+		"Print estimated download size of platform, security databases and every module instead of pulling, "+
+			"to provision disk space for the bundle. Installers are still read from the source registry to find Deckhouse images.",
+	)
+	flagSet.BoolVar(
+		&KeepWorkDir,
+		"keep-workdir",
+		false,
+		"Do not remove temporary working directories after the command is finished. Useful for debugging.",
+	)
+	flagSet.StringVar(
+		&HealthFile,
+		"health-file",
+		"",
+		"Periodically touch this file while mirroring makes progress, for use in Kubernetes liveness probes. File is removed once no progress is made for --health-timeout.",
+	)
+	flagSet.StringVar(
+		&HealthAddr,
+		"health-addr",
+		"",
+		"Serve HTTP health checks on this address, e.g. :8080. Responds with 503 once no progress is made for --health-timeout.",
+	)
+	flagSet.DurationVar(
+		&HealthTimeout,
+		"health-timeout",
+		health.DefaultStaleTimeout,
+		"Time without progress after which mirroring is considered stuck by --health-file and --health-addr.",
+	)
+	flagSet.StringVar(
+		&ProgressSocket,
+		"progress-socket",
+		"",
+		"Stream mirroring progress as newline-delimited JSON to clients of the Unix socket created at this path.",
+	)
+	flagSet.BoolVar(
+		&FixtureMode,
+		"fixture-mode",
+		false,
+		"Pull tiny synthetic bundle with a few small images of fake Deckhouse releases instead of the real ones. "+
+			"Bundle has the same layouts structure and manifests as real bundles and is meant for CI tests of push and verification. "+
+			"Source registry flags are ignored.",
+	)
+	flagSet.StringVar(
+		&NotifyURL,
+		"notify-url",
+		os.Getenv("D8_MIRROR_NOTIFY_URL"),
+		"Webhook to POST summary of the finished pull to: mirrored releases, registry traffic, duration and errors.",
+	)
+	flagSet.StringVar(
+		&NotifyTemplate,
+		"notify-template",
+		string(notify.TemplateJSON),
+		`Shape of --notify-url message: "json" to post summary as is, "slack" for Slack incoming webhooks `+
+			`or "telegram" for Telegram Bot API sendMessage URL with chat_id query parameter.`,
+	)
 }
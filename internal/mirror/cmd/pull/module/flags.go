@@ -0,0 +1,90 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"os"
+
+	"github.com/spf13/pflag"
+)
+
+func addFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&SourceRegistryRepo,
+		"source",
+		enterpriseEditionRepo,
+		"Source registry to pull Deckhouse module from.",
+	)
+	flagSet.StringVar(
+		&SourceRegistryLogin,
+		"source-login",
+		os.Getenv("D8_MIRROR_SOURCE_LOGIN"),
+		"Source registry login.",
+	)
+	flagSet.StringVar(
+		&SourceRegistryPassword,
+		"source-password",
+		os.Getenv("D8_MIRROR_SOURCE_PASSWORD"),
+		"Source registry password.",
+	)
+	flagSet.StringVar(
+		&SourceRegistryAuthFile,
+		"source-insecure-basic-auth-file",
+		os.Getenv("D8_MIRROR_SOURCE_AUTH_FILE"),
+		"File with source registry credentials, either Docker config.json or a single username:password line. "+
+			"Must be accessible only by its owner. Conflicts with --source-login and --license.",
+	)
+	flagSet.StringVarP(
+		&DeckhouseLicenseToken,
+		"license",
+		"l",
+		os.Getenv("D8_MIRROR_LICENSE_TOKEN"),
+		"Deckhouse license key. Shortcut for --source-login=license-token --source-password=<>.",
+	)
+	flagSet.StringVar(
+		&ModuleVersion,
+		"version",
+		"",
+		"Module releases to copy in addition to the ones on release channels. "+
+			"Either minimal version, like v1.2.0, or semver range, like \">=1.2.0 <1.4.0\".",
+	)
+	flagSet.Int64VarP(
+		&ImagesBundleChunkSizeGB,
+		"images-bundle-chunk-size",
+		"c",
+		0,
+		"Split resulting bundle file into chunks of at most N gigabytes",
+	)
+	flagSet.BoolVar(
+		&TLSSkipVerify,
+		"tls-skip-verify",
+		false,
+		"Disable TLS certificate validation.",
+	)
+	flagSet.BoolVar(
+		&Insecure,
+		"insecure",
+		false,
+		"Interact with registries over HTTP.",
+	)
+	flagSet.BoolVar(
+		&KeepWorkDir,
+		"keep-workdir",
+		false,
+		"Do not remove temporary working directories after the command is finished. Useful for debugging.",
+	)
+}
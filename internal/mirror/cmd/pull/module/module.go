@@ -0,0 +1,258 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/modules"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/workdir"
+)
+
+const enterpriseEditionRepo = "registry.deckhouse.io/deckhouse/ee"
+
+var moduleLong = templates.LongDesc(`
+Download a single Deckhouse module to the local filesystem.
+
+This command downloads images and release information of the named module 
+into module-<name>.tar bundle inside the given directory, along with 
+the bundle integrity manifest, module-<name>.tar.manifest.json.
+Resulting bundle may be pushed into the air-gapped registry with "d8 mirror push".
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+func NewCommand() *cobra.Command {
+	moduleCmd := &cobra.Command{
+		Use:           "module <module-name> <images-bundle-dir>",
+		Short:         "Copy a single Deckhouse module to the local filesystem",
+		Long:          moduleLong,
+		ValidArgs:     []string{"module-name", "images-bundle-dir"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          pullModule,
+	}
+
+	addFlags(moduleCmd.Flags())
+	return moduleCmd
+}
+
+var (
+	TempDir = filepath.Join(os.TempDir(), "mirror")
+
+	ModuleName    string
+	ModuleVersion string
+	BundleDir     string
+
+	Insecure      bool
+	TLSSkipVerify bool
+
+	SourceRegistryRepo     = enterpriseEditionRepo
+	SourceRegistryLogin    string
+	SourceRegistryPassword string
+	SourceRegistryAuthFile string
+	DeckhouseLicenseToken  string
+
+	ImagesBundleChunkSizeGB int64
+
+	KeepWorkDir bool
+)
+
+func pullModule(cmd *cobra.Command, _ []string) (err error) {
+	logger := output.FromCommand(cmd).Logger()
+
+	workDirs := workdir.NewManager(TempDir, KeepWorkDir, logger)
+	stopCleanupOnInterrupt := workDirs.CleanupOnInterrupt()
+	defer stopCleanupOnInterrupt()
+	defer func() {
+		if cleanupErr := workDirs.Cleanup(err); cleanupErr != nil && err == nil {
+			err = fmt.Errorf("Cleanup temporary data after mirroring: %w", cleanupErr)
+		}
+	}()
+
+	workDir, err := workDirs.Create(filepath.Join("module", ModuleName+time.Now().Format("_02-01-2006_15-04-05")))
+	if err != nil {
+		return err
+	}
+
+	pullCtx := &contexts.PullContext{
+		BaseContext: contexts.BaseContext{
+			Logger:                logger,
+			Insecure:              Insecure,
+			SkipTLSVerification:   TLSSkipVerify,
+			DeckhouseRegistryRepo: SourceRegistryRepo,
+			RegistryAuth:          getSourceRegistryAuthProvider(),
+			BundlePath:            filepath.Join(BundleDir, "module-"+ModuleName+".tar"),
+			UnpackedImagesPath:    workDir.Path,
+			Run:                   contexts.NewRunContext(context.Background()),
+		},
+		BundleChunkSize: ImagesBundleChunkSizeGB * 1000 * 1000 * 1000,
+	}
+
+	versionFilter := ""
+	if ModuleVersion != "" {
+		versionFilter = ModuleName + "@" + ModuleVersion
+	}
+	filter, err := modules.NewFilter(versionFilter, logger)
+	if err != nil {
+		return fmt.Errorf("Bad module version: %w", err)
+	}
+
+	err = logger.Process("Pull module "+ModuleName, func() error {
+		return PullModuleToLocalFS(pullCtx, ModuleName, filter)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = logger.Process("Pack images", func() error {
+		return bundle.Pack(pullCtx)
+	})
+	if err != nil {
+		return err
+	}
+
+	manifestPath := bundle.IntegrityManifestPath(pullCtx.BundlePath)
+	if err = writeIntegrityManifest(pullCtx.BundlePath, manifestPath); err != nil {
+		return err
+	}
+	checksumsPath, err := bundle.WriteChecksums(pullCtx.BundlePath)
+	if err != nil {
+		return fmt.Errorf("Write bundle checksums: %w", err)
+	}
+	logger.InfoF("Module bundle is written to %s, integrity manifest to %s, checksums to %s", pullCtx.BundlePath, manifestPath, checksumsPath)
+
+	return nil
+}
+
+// PullModuleToLocalFS pulls images and release information of the single module from Deckhouse registry
+// into modules/<name> layouts under pullCtx.UnpackedImagesPath, like "d8 mirror pull" does for every module.
+// Filter selects module releases to pull in addition to the ones on release channels.
+func PullModuleToLocalFS(pullCtx *contexts.PullContext, moduleName string, filter *modules.Filter) error {
+	modulesData, err := modules.GetDeckhouseExternalModules(pullCtx)
+	if err != nil {
+		return fmt.Errorf("Get Deckhouse modules: %w", err)
+	}
+
+	var module *modules.Module
+	for i := range modulesData {
+		if modulesData[i].Name == moduleName {
+			module = &modulesData[i]
+			break
+		}
+	}
+	if module == nil {
+		return fmt.Errorf("Module %s is not found in %s", moduleName, pullCtx.DeckhouseRegistryRepo)
+	}
+	if err = filter.FilterReleases(module); err != nil {
+		return fmt.Errorf("Bad module version: %w", err)
+	}
+
+	imageLayouts, err := layouts.CreateOCIImageLayoutsForModules(pullCtx.UnpackedImagesPath, []modules.Module{*module})
+	if err != nil {
+		return fmt.Errorf("Create OCI Image Layouts: %w", err)
+	}
+
+	moduleLayout := imageLayouts.Modules[moduleName]
+	moduleLayout.ModuleImages, moduleLayout.ReleaseImages, err = modules.FindExternalModuleImages(
+		module,
+		filter,
+		pullCtx.RegistryAuth,
+		pullCtx.Insecure,
+		pullCtx.SkipTLSVerification,
+	)
+	if err != nil {
+		return fmt.Errorf("Find module images: %w", err)
+	}
+	imageLayouts.Modules[moduleName] = moduleLayout
+
+	for _, imageSet := range []map[string]struct{}{moduleLayout.ModuleImages, moduleLayout.ReleaseImages} {
+		if err = imageLayouts.TagsResolver.ResolveTagsDigestsFromImageSet(
+			imageSet,
+			pullCtx.RegistryAuth,
+			pullCtx.Insecure,
+			pullCtx.SkipTLSVerification,
+		); err != nil {
+			return fmt.Errorf("Resolve images tags to digests: %w", err)
+		}
+	}
+
+	if err = layouts.PullModules(pullCtx, imageLayouts); err != nil {
+		return err
+	}
+	if err = layouts.ValidateStructure(imageLayouts, pullCtx.Platforms, pullCtx.Concurrency); err != nil {
+		return fmt.Errorf("Pulled module is malformed:\n%w", err)
+	}
+	return nil
+}
+
+func writeIntegrityManifest(bundlePath, manifestPath string) error {
+	manifest, err := bundle.BuildIntegrityManifest(bundlePath)
+	if err != nil {
+		return fmt.Errorf("Build integrity manifest: %w", err)
+	}
+	rawManifest, err := manifest.Marshal()
+	if err != nil {
+		return fmt.Errorf("Marshal integrity manifest: %w", err)
+	}
+	if err = os.WriteFile(manifestPath, rawManifest, 0o644); err != nil {
+		return fmt.Errorf("Write integrity manifest: %w", err)
+	}
+	return nil
+}
+
+// sourceRegistryFileAuth is loaded from --source-insecure-basic-auth-file during flags validation.
+var sourceRegistryFileAuth authn.Authenticator
+
+func getSourceRegistryAuthProvider() authn.Authenticator {
+	if sourceRegistryFileAuth != nil {
+		return sourceRegistryFileAuth
+	}
+
+	if SourceRegistryLogin != "" {
+		return authn.FromConfig(authn.AuthConfig{
+			Username: SourceRegistryLogin,
+			Password: SourceRegistryPassword,
+		})
+	}
+
+	if DeckhouseLicenseToken != "" {
+		return authn.FromConfig(authn.AuthConfig{
+			Username: "license-token",
+			Password: DeckhouseLicenseToken,
+		})
+	}
+
+	return auth.DefaultAuthenticator(SourceRegistryRepo)
+}
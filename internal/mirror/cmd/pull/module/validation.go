@@ -0,0 +1,112 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+)
+
+var moduleNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9-_]+$`)
+
+func parseAndValidateParameters(_ *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return errors.New("invalid number of arguments, expected 2")
+	}
+
+	var err error
+	if err = validateModuleNameArg(args); err != nil {
+		return err
+	}
+	if err = validateBundleDirArg(args); err != nil {
+		return err
+	}
+	if err = validateVersionFlag(); err != nil {
+		return err
+	}
+	if ImagesBundleChunkSizeGB < 0 {
+		return errors.New("Chunk size cannot be less than zero GB")
+	}
+
+	if err = parseAndValidateSourceAuthFileFlag(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func parseAndValidateSourceAuthFileFlag() error {
+	if SourceRegistryAuthFile == "" {
+		return nil
+	}
+	if SourceRegistryLogin != "" || DeckhouseLicenseToken != "" {
+		return errors.New("--source-insecure-basic-auth-file cannot be used together with --source-login or --license")
+	}
+
+	var err error
+	sourceRegistryFileAuth, err = auth.LoadAuthFileForRepo(SourceRegistryAuthFile, SourceRegistryRepo)
+	if err != nil {
+		return fmt.Errorf("Invalid --source-insecure-basic-auth-file: %w", err)
+	}
+	return nil
+}
+
+func validateModuleNameArg(args []string) error {
+	ModuleName = args[0]
+	if !moduleNameRegexp.MatchString(ModuleName) {
+		return fmt.Errorf("Invalid module name %q", ModuleName)
+	}
+	return nil
+}
+
+func validateBundleDirArg(args []string) error {
+	BundleDir = filepath.Clean(args[1])
+	stat, err := os.Stat(BundleDir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err = os.MkdirAll(BundleDir, 0o755); err != nil {
+			return fmt.Errorf("Create images bundle directory: %w", err)
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("invalid images bundle directory: %w", err)
+	case !stat.IsDir():
+		return fmt.Errorf("%s: not a directory", BundleDir)
+	}
+	return nil
+}
+
+func validateVersionFlag() error {
+	if ModuleVersion == "" {
+		return nil
+	}
+	if _, err := semver.NewVersion(ModuleVersion); err == nil {
+		return nil
+	}
+	if _, err := semver.NewConstraint(ModuleVersion); err != nil {
+		return fmt.Errorf("--version should be a version or semver range: %w", err)
+	}
+	return nil
+}
@@ -19,30 +19,45 @@ package pull
 import (
 	"bufio"
 	"context"
+	"crypto"
 	"crypto/md5"
 	"fmt"
 	"io"
-	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/spf13/cobra"
 	"golang.org/x/exp/maps"
 	"k8s.io/kubectl/pkg/util/templates"
 
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/pull/module"
 	"github.com/deckhouse/deckhouse-cli/internal/mirror/gostsums"
 	"github.com/deckhouse/deckhouse-cli/internal/mirror/manifests"
 	"github.com/deckhouse/deckhouse-cli/internal/mirror/releases"
+	"github.com/deckhouse/deckhouse-cli/internal/output"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/fixture"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/images"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/modules"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
-	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/failover"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/health"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/httppool"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/notify"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/progress"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/ratelimit"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/s3"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/workdir"
 )
 
 const (
@@ -50,6 +65,8 @@ const (
 	enterpriseEditionRepoPath = "/deckhouse/ee"
 
 	enterpriseEditionRepo = deckhouseRegistryHost + enterpriseEditionRepoPath
+
+	slowestTimingsToReport = 5
 )
 
 var pullLong = templates.LongDesc(`
@@ -59,6 +76,16 @@ This command downloads the Deckhouse Kubernetes Platform distribution bundle
 containing specific platform releases and it's modules, 
 to be pushed into the air-gapped container registry at a later time.
 
+With --no-pack, images-bundle-path is a directory that OCI image layouts of the bundle are pulled into
+and left there without packing them into tar archive. d8 mirror push accepts such directory as is,
+which saves packing and unpacking of the bundle when pull and push are run on the same host.
+DeckhouseRelease manifests are written into this directory instead of next to the bundle.
+
+Bundle can be written directly to S3-compatible object storage by passing s3://bucket/path/to/bundle.tar
+as images-bundle-path. Region and endpoint of the storage are read from AWS_REGION and AWS_ENDPOINT_URL
+environment variables. Credentials are looked up like AWS CLI does: in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY,
+in web identity token of IAM role for service account, in AWS_PROFILE of shared credentials file and in EC2 instance role.
+
 For more information on how to use it, consult the docs at 
 https://deckhouse.io/products/kubernetes-platform/documentation/v1/deckhouse-faq.html#manually-uploading-images-to-an-air-gapped-registry
 
@@ -78,12 +105,10 @@ func NewCommand() *cobra.Command {
 		SilenceUsage:  true,
 		PreRunE:       parseAndValidateParameters,
 		RunE:          pull,
-		PostRunE: func(_ *cobra.Command, _ []string) error {
-			return os.RemoveAll(TempDir)
-		},
 	}
 
 	addFlags(pullCmd.Flags())
+	pullCmd.AddCommand(module.NewCommand())
 	return pullCmd
 }
 
@@ -95,6 +120,8 @@ var (
 
 	ImagesBundlePath        string
 	ImagesBundleChunkSizeGB int64
+	ChunkPrefix             string
+	PullConcurrency         int
 
 	minVersionString string
 	MinVersion       *semver.Version
@@ -102,22 +129,88 @@ var (
 	specificReleaseString string
 	SpecificRelease       *semver.Version
 
+	SinceChannel string
+
+	ExplainVersionsPath string
+	InventoryPath       string
+	DiffAgainst         string
+
 	SourceRegistryRepo     = enterpriseEditionRepo // Fallback to EE if nothing was given as source.
 	SourceRegistryLogin    string
 	SourceRegistryPassword string
+	SourceRegistryAuthFile string
 	DeckhouseLicenseToken  string
+	SourceFallbackRepos    []string
 
 	DoGOSTDigest            bool
 	DontContinuePartialPull bool
 	NoModules               bool
+	OnlyComponents          []string
+	SkipPlatform            bool
+	SkipModules             bool
+	SkipSecurity            bool
+	SkipStandalone          bool
+	SkippedComponents       map[string]bool
+	ModulesPathSuffix       string
+	ModulesSpecPath         string
+	ModulesSpec             *modules.Filter
+	AllowIncomplete         bool
+	NoPack                  bool
+
+	KeepWorkDir bool
+
+	VerifySourceSignatures bool
+	SourceSignatureKeyPath string
+	SourceSignatureKey     crypto.PublicKey
+	SignaturePolicy        string
+
+	IncludeCosign bool
+
+	ChecksumsSigningKeyPath string
+	checksumsSigner         crypto.Signer
+
+	EncryptRecipientPaths []string
+	bundleRecipients      openpgp.EntityList
+
+	platformStrings []string
+	Platforms       []v1.Platform
+	AllPlatforms    bool
+
+	skipAnnotatedStrings []string
+	SkipAnnotated        map[string]string
+
+	ExcludeRefs    []string
+	ExcludeDigests []string
+	Exclusions     *contexts.ImageExclusions
+
+	HealthFile    string
+	HealthAddr    string
+	HealthTimeout time.Duration
+
+	MaxSecurityDBAge time.Duration
+
+	rateLimitString string
+	RateLimit       int64 // bytes per second
+
+	ProgressSocket string
+
+	NotifyURL      string
+	NotifyTemplate string
+	notifier       *notify.Notifier
+
+	FixtureMode bool
+
+	DryRun       bool
+	EstimateSize bool
 )
 
-func buildPullContext() *contexts.PullContext {
-	logLevel := slog.LevelInfo
-	if log.DebugLogLevel() >= 3 {
-		logLevel = slog.LevelDebug
+func buildPullContext(out *output.Output) *contexts.PullContext {
+	unpackedImagesPath := filepath.Join(TempDir, pullWorkDir())
+	if NoPack {
+		unpackedImagesPath = ImagesBundlePath
 	}
-	logger := log.NewSLogger(logLevel)
+
+	logger := out.Logger()
 
 	mirrorCtx := &contexts.PullContext{
 		BaseContext: contexts.BaseContext{
@@ -125,32 +218,122 @@ func buildPullContext() *contexts.PullContext {
 			Insecure:              Insecure,
 			SkipTLSVerification:   TLSSkipVerify,
 			DeckhouseRegistryRepo: SourceRegistryRepo,
+			ModulesPathSuffix:     ModulesPathSuffix,
 			RegistryAuth:          getSourceRegistryAuthProvider(),
 			BundlePath:            ImagesBundlePath,
-			UnpackedImagesPath: filepath.Join(
-				TempDir,
-				"pull",
-				fmt.Sprintf("%x", md5.Sum([]byte(SourceRegistryRepo))),
-			),
+			UnpackedImagesPath:    unpackedImagesPath,
+			Run:                   contexts.NewRunContext(context.Background()),
 		},
 
 		BundleChunkSize: ImagesBundleChunkSizeGB * 1000 * 1000 * 1000,
+		Concurrency:     PullConcurrency,
 
 		DoGOSTDigests:   DoGOSTDigest,
 		SkipModulesPull: NoModules,
 		SpecificVersion: SpecificRelease,
 		MinVersion:      MinVersion,
+		SinceChannel:    SinceChannel,
+
+		SkippedComponents: SkippedComponents,
+		AllowIncomplete:   AllowIncomplete,
+
+		SourceSignatureKey: SourceSignatureKey,
+		SignaturePolicy:    SignaturePolicy,
+		IncludeCosign:      IncludeCosign,
+
+		Platforms:    Platforms,
+		AllPlatforms: AllPlatforms,
+
+		SkipAnnotated: SkipAnnotated,
+		Exclusions:    Exclusions,
+
+		BundleRecipients: bundleRecipients,
 	}
 	return mirrorCtx
 }
 
-func pull(_ *cobra.Command, _ []string) error {
-	mirrorCtx := buildPullContext()
+// pullWorkDir is unique for every source registry, so that interrupted pull from it can be resumed.
+func pullWorkDir() string {
+	return filepath.Join("pull", fmt.Sprintf("%x", md5.Sum([]byte(SourceRegistryRepo))))
+}
+
+// sourceFailover is built from --source-fallback during flags validation.
+var sourceFailover *failover.Group
+
+func pull(cmd *cobra.Command, _ []string) (err error) {
+	if FixtureMode {
+		fixtureRegistry, err := fixture.StartRegistry()
+		if err != nil {
+			return fmt.Errorf("Start fixture registry: %w", err)
+		}
+		defer fixtureRegistry.Close()
+		SourceRegistryRepo, Insecure, TLSSkipVerify = fixtureRegistry.Repo(), true, false
+	}
+
+	mirrorCtx := buildPullContext(output.FromCommand(cmd))
 	logger := mirrorCtx.Logger
+	notifyRun := notify.StartRun("pull")
+	defer func() { notifier.Notify(logger, notifyRun.Summary(err)) }()
+
+	if sourceFailover != nil {
+		sourceFailover.OnFailover = func(class, from, to string, reason error) {
+			if reason == nil {
+				logger.InfoF("Source registry %s is available again, %s requests are sent to it", to, class)
+				return
+			}
+			logger.WarnF("⚠️ %s requests fail over from %s to %s: %v", class, from, to, reason)
+		}
+		disableFailover := failover.Enable(sourceFailover)
+		defer disableFailover()
+	}
 
-	if DontContinuePartialPull || lastPullWasTooLongAgoToRetry(mirrorCtx) {
-		if err := os.RemoveAll(mirrorCtx.UnpackedImagesPath); err != nil {
-			return fmt.Errorf("Cleanup last unfinished pull data: %w", err)
+	if RateLimit > 0 {
+		disableRateLimit := ratelimit.Enable(ratelimit.NewLimiter(RateLimit))
+		defer disableRateLimit()
+	}
+
+	workDirs := workdir.NewManager(TempDir, KeepWorkDir, logger)
+	stopCleanupOnInterrupt := workDirs.CleanupOnInterrupt()
+	defer stopCleanupOnInterrupt()
+	defer func() {
+		if cleanupErr := workDirs.Cleanup(err); cleanupErr != nil && err == nil {
+			err = fmt.Errorf("Cleanup temporary data after mirroring: %w", cleanupErr)
+		}
+	}()
+
+	stopHealthReporting, err := health.Start(mirrorCtx.Run, health.Options{
+		File:         HealthFile,
+		Addr:         HealthAddr,
+		StaleTimeout: HealthTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("Start health reporting: %w", err)
+	}
+	defer stopHealthReporting()
+
+	stopProgressReporting, err := progress.Serve(mirrorCtx.Run, progress.Options{
+		SocketPath: ProgressSocket,
+		Operation:  "pull",
+	})
+	if err != nil {
+		return fmt.Errorf("Start progress reporting: %w", err)
+	}
+	defer func() { stopProgressReporting(err) }()
+
+	var baseContents *bundle.Contents
+	if DiffAgainst != "" {
+		if baseContents, err = bundle.ReadContents(DiffAgainst); err != nil {
+			return fmt.Errorf("Read base bundle %s: %w", DiffAgainst, err)
+		}
+		if baseContents.Delta != nil {
+			return fmt.Errorf("Base bundle %s is a delta against %s itself, only full bundles can be diffed against", DiffAgainst, baseContents.Delta.Base)
+		}
+	}
+
+	// Dry run must leave data of interrupted pull intact for the real one to resume.
+	if !DryRun && !EstimateSize {
+		if err = prepareResumablePull(mirrorCtx, workDirs); err != nil {
+			return err
 		}
 	}
 
@@ -176,18 +359,26 @@ func pull(_ *cobra.Command, _ []string) error {
 	}
 	cancel()
 
+	if mirrorCtx.PullsComponent(contexts.ComponentSecurity) {
+		if _, err = layouts.CheckSecurityDatabasesAge(mirrorCtx.Run.Context(), mirrorCtx, MaxSecurityDBAge, time.Now()); err != nil {
+			return fmt.Errorf("Check vulnerability databases age: %w", err)
+		}
+	}
+
 	var versionsToMirror []semver.Version
-	var err error
 	err = logger.Process("Looking for required Deckhouse releases", func() error {
-		if mirrorCtx.SpecificVersion != nil {
-			versionsToMirror = append(versionsToMirror, *mirrorCtx.SpecificVersion)
-			logger.InfoF("Skipped releases lookup as release %v is specifically requested with --release", mirrorCtx.SpecificVersion)
+		var plan *releases.VersionsPlan
+		if versionsToMirror, plan, err = LookupVersionsToPull(mirrorCtx); err != nil {
+			return err
+		}
+		if plan == nil {
 			return nil
 		}
-
-		versionsToMirror, err = releases.VersionsToMirror(mirrorCtx)
-		if err != nil {
-			return fmt.Errorf("Find versions to mirror: %w", err)
+		if ExplainVersionsPath != "" {
+			if err = writeVersionsPlan(plan, ExplainVersionsPath); err != nil {
+				return err
+			}
+			logger.InfoF("Releases lookup plan is written to %s", ExplainVersionsPath)
 		}
 		logger.InfoF("Deckhouse releases to pull: %+v", versionsToMirror)
 		return nil
@@ -195,13 +386,83 @@ func pull(_ *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
+	notifyRun.SetVersions(versionsToMirror)
+
+	if DryRun || EstimateSize {
+		return dryRun(mirrorCtx, versionsToMirror, workDirs, output.FromCommand(cmd))
+	}
 
 	err = logger.Process("Pull images", func() error {
-		return PullDeckhouseToLocalFS(mirrorCtx, versionsToMirror)
+		return PullDeckhouseToLocalFS(mirrorCtx, versionsToMirror, ModulesSpec)
 	})
 	if err != nil {
 		return err
 	}
+	for _, stage := range mirrorCtx.Run.Progress.Snapshot() {
+		logger.InfoF("Stage %s: %d / %d images pulled", stage.Stage, stage.Done, stage.Total)
+	}
+	for _, image := range mirrorCtx.Run.Timings.SlowestImages(slowestTimingsToReport) {
+		logger.InfoF("Slowest image %s: pulled in %s, registry responded to manifest requests in %s",
+			image.Reference, image.Total.Round(time.Millisecond), image.ManifestResponse.Round(time.Millisecond))
+	}
+	for _, layer := range mirrorCtx.Run.Timings.SlowestLayers(slowestTimingsToReport) {
+		logger.InfoF("Slowest %s of %s", layouts.FormatLayerTiming(layer), layer.Image)
+	}
+	for _, footprint := range mirrorCtx.Run.Footprints.Releases() {
+		logger.InfoF("Release %s: %d images, %.1f MiB", footprint.Version, footprint.Images, float64(footprint.Size)/1024/1024)
+	}
+	logger.InfoF("Connections: %s", httppool.Snapshot())
+	if sourceFailover != nil {
+		for _, served := range sourceFailover.Served() {
+			logger.InfoF("Source %s served %d %s requests", served.Endpoint, served.Requests, served.Class)
+		}
+	}
+	if skipped := mirrorCtx.Run.Metrics.ImagesSkipped.Load(); skipped > 0 {
+		logger.InfoF("Skipped %d images matching --skip-annotated, --exclude-ref or --exclude-digest:", skipped)
+		for _, entry := range mirrorCtx.Run.Audit.Entries() {
+			if entry.Action == contexts.AuditActionSkip {
+				logger.InfoF("\t%s", entry.Subject)
+			}
+		}
+	}
+
+	if err = mirrorCtx.Checkpoint.Remove(); err != nil {
+		return err
+	}
+	segmentCounts, err := layouts.CountBundleSegments(mirrorCtx.UnpackedImagesPath)
+	if err != nil {
+		return fmt.Errorf("Count bundle contents: %w", err)
+	}
+	if err = bundle.WriteSegmentCounts(mirrorCtx.UnpackedImagesPath, segmentCounts); err != nil {
+		return err
+	}
+	if err = bundle.WriteExclusions(mirrorCtx.UnpackedImagesPath, mirrorCtx.Exclusions); err != nil {
+		return err
+	}
+	if baseContents != nil {
+		delta, err := bundle.MakeDelta(mirrorCtx.UnpackedImagesPath, path.Base(DiffAgainst), baseContents)
+		if err != nil {
+			return err
+		}
+		omitted := 0
+		for _, blobs := range delta.OmittedBlobs {
+			omitted += len(blobs)
+		}
+		logger.InfoF("Left out %d layers (%.1f MiB) stored in base bundle %s", omitted, float64(delta.OmittedSize)/1024/1024, DiffAgainst)
+	}
+
+	gcResult, err := bundle.CollectGarbage(mirrorCtx.UnpackedImagesPath)
+	if err != nil {
+		return err
+	}
+	if gcResult.RemovedBlobs > 0 {
+		logger.InfoF("Removed %d orphaned blobs (%.1f MiB) left by interrupted pulls", gcResult.RemovedBlobs, float64(gcResult.ReclaimedSize)/1024/1024)
+	}
+
+	if NoPack {
+		logger.InfoF("Bundle is left unpacked in %s, it can be pushed with d8 mirror push %s <registry>", mirrorCtx.BundlePath, mirrorCtx.BundlePath)
+		return nil
+	}
 
 	err = logger.Process("Pack images", func() error {
 		return bundle.Pack(mirrorCtx)
@@ -210,6 +471,20 @@ func pull(_ *cobra.Command, _ []string) error {
 		return err
 	}
 
+	if !s3.IsURL(mirrorCtx.BundlePath) {
+		if err = writeChecksums(mirrorCtx.BundlePath, checksumsSigner); err != nil {
+			return err
+		}
+		logger.InfoF("Checksums of bundle files are written to %s", bundle.ChecksumsPath(mirrorCtx.BundlePath))
+	}
+
+	if InventoryPath != "" {
+		if err = writeInventory(mirrorCtx, InventoryPath); err != nil {
+			return fmt.Errorf("Write images inventory: %w", err)
+		}
+		logger.InfoF("Inventory of pulled images is written to %s", InventoryPath)
+	}
+
 	if mirrorCtx.DoGOSTDigests {
 		err = logger.Process("Compute GOST digest", func() error {
 			if err = computeGOSTDigest(&mirrorCtx.BaseContext); err != nil {
@@ -222,26 +497,76 @@ func pull(_ *cobra.Command, _ []string) error {
 		}
 	}
 
-	if err = os.RemoveAll(TempDir); err != nil {
-		return fmt.Errorf("Cleanup temporary data after mirroring: %w", err)
+	return nil
+}
+
+// writeChecksums lists digests of bundle files in checksums file next to the bundle, and signs it if signer is set.
+func writeChecksums(bundlePath string, signer crypto.Signer) error {
+	checksumsPath, err := bundle.WriteChecksums(bundlePath)
+	if err != nil {
+		return fmt.Errorf("Write bundle checksums: %w", err)
+	}
+	if signer == nil {
+		return nil
 	}
 
+	rawChecksums, err := os.ReadFile(checksumsPath)
+	if err != nil {
+		return fmt.Errorf("Read bundle checksums: %w", err)
+	}
+	sig, err := signature.Sign(signer, rawChecksums)
+	if err != nil {
+		return fmt.Errorf("Sign bundle checksums: %w", err)
+	}
+	if err = os.WriteFile(checksumsPath+".sig", sig, 0o644); err != nil {
+		return fmt.Errorf("Write checksums signature: %w", err)
+	}
+	return nil
+}
+
+// writeInventory lists images of the packed bundle, so that the inventory matches exactly what was delivered.
+func writeInventory(mirrorCtx *contexts.PullContext, inventoryPath string) error {
+	contents, err := bundle.ReadContents(mirrorCtx.BundlePath)
+	if err != nil {
+		return err
+	}
+
+	inventoryFile, err := os.Create(inventoryPath)
+	if err != nil {
+		return err
+	}
+	defer inventoryFile.Close()
+
+	err = bundle.WriteCycloneDXInventory(inventoryFile, contents, bundle.InventoryOptions{
+		SourceRepo:        mirrorCtx.DeckhouseRegistryRepo,
+		ModulesPathSuffix: mirrorCtx.ModulesPathSuffix,
+		Timestamp:         time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return inventoryFile.Close()
+}
+
+func writeVersionsPlan(plan *releases.VersionsPlan, path string) error {
+	rawPlan, err := plan.Marshal()
+	if err != nil {
+		return fmt.Errorf("Marshal releases lookup plan: %w", err)
+	}
+	if err = os.WriteFile(path, rawPlan, 0o644); err != nil {
+		return fmt.Errorf("Write releases lookup plan: %w", err)
+	}
 	return nil
 }
 
 func computeGOSTDigest(mirrorCtx *contexts.BaseContext) error {
-	bundleDir := filepath.Dir(mirrorCtx.BundlePath)
-	catalog, err := os.ReadDir(bundleDir)
+	bundleFiles, err := bundle.FindBundleFiles(mirrorCtx.BundlePath)
 	if err != nil {
-		return fmt.Errorf("read tar bundle directory: %w", err)
+		return fmt.Errorf("read tar bundle: %w", err)
 	}
-	streams := make([]io.Reader, 0)
-	for _, entry := range catalog {
-		fileName := entry.Name()
-		if !entry.Type().IsRegular() || filepath.Ext(fileName) != ".chunk" {
-			continue
-		}
-		chunkStream, err := os.Open(filepath.Join(bundleDir, fileName))
+	streams := make([]io.Reader, 0, len(bundleFiles))
+	for _, bundleFile := range bundleFiles {
+		chunkStream, err := os.Open(bundleFile)
 		if err != nil {
 			return fmt.Errorf("open bundle chunk for reading: %w", err)
 		}
@@ -249,16 +574,7 @@ func computeGOSTDigest(mirrorCtx *contexts.BaseContext) error {
 		streams = append(streams, chunkStream)
 	}
 
-	bundleStream := io.NopCloser(io.MultiReader(streams...))
-	if len(streams) == 0 {
-		bundleStream, err = os.Open(mirrorCtx.BundlePath)
-		if err != nil {
-			return fmt.Errorf("read tar bundle: %w", err)
-		}
-	}
-	defer bundleStream.Close()
-
-	gostDigest, err := gostsums.CalculateBlobGostDigest(bufio.NewReaderSize(bundleStream, 512*1024))
+	gostDigest, err := gostsums.CalculateBlobGostDigest(bufio.NewReaderSize(io.MultiReader(streams...), 512*1024))
 	if err != nil {
 		return fmt.Errorf("Calculate GOST Checksum: %w", err)
 	}
@@ -271,6 +587,33 @@ func computeGOSTDigest(mirrorCtx *contexts.BaseContext) error {
 	return nil
 }
 
+// prepareResumablePull creates working directory of the pull, picking up images pulled by previous interrupted run.
+func prepareResumablePull(mirrorCtx *contexts.PullContext, workDirs *workdir.Manager) error {
+	if DontContinuePartialPull || lastPullWasTooLongAgoToRetry(mirrorCtx) {
+		if err := os.RemoveAll(mirrorCtx.UnpackedImagesPath); err != nil {
+			return fmt.Errorf("Cleanup last unfinished pull data: %w", err)
+		}
+	}
+	// Bundle directory of --no-pack pull is not a working directory, it is never removed.
+	if NoPack {
+		if err := os.MkdirAll(mirrorCtx.UnpackedImagesPath, 0o755); err != nil {
+			return fmt.Errorf("Create bundle directory: %w", err)
+		}
+	} else if _, err := workDirs.CreateResumable(pullWorkDir()); err != nil {
+		return err
+	}
+
+	var err error
+	mirrorCtx.Checkpoint, err = contexts.LoadPullCheckpoint(filepath.Join(mirrorCtx.UnpackedImagesPath, contexts.PullCheckpointFile))
+	if err != nil {
+		return err
+	}
+	if resumed := mirrorCtx.Checkpoint.Len(); resumed > 0 {
+		mirrorCtx.Logger.InfoF("Resuming interrupted pull, %d images were already pulled", resumed)
+	}
+	return nil
+}
+
 func lastPullWasTooLongAgoToRetry(mirrorCtx *contexts.PullContext) bool {
 	s, err := os.Lstat(mirrorCtx.UnpackedImagesPath)
 	if err != nil {
@@ -280,7 +623,14 @@ func lastPullWasTooLongAgoToRetry(mirrorCtx *contexts.PullContext) bool {
 	return time.Since(s.ModTime()) > 24*time.Hour
 }
 
+// sourceRegistryFileAuth is loaded from --source-insecure-basic-auth-file during flags validation.
+var sourceRegistryFileAuth authn.Authenticator
+
 func getSourceRegistryAuthProvider() authn.Authenticator {
+	if sourceRegistryFileAuth != nil {
+		return sourceRegistryFileAuth
+	}
+
 	if SourceRegistryLogin != "" {
 		return authn.FromConfig(authn.AuthConfig{
 			Username: SourceRegistryLogin,
@@ -295,12 +645,51 @@ func getSourceRegistryAuthProvider() authn.Authenticator {
 		})
 	}
 
-	return authn.Anonymous
+	return auth.DefaultAuthenticator(SourceRegistryRepo)
+}
+
+// LookupVersionsToPull returns Deckhouse releases to pull: the one requested with --release or the ones selected
+// by releases lookup plan, which is returned as well. Plan is nil if no lookup was made.
+func LookupVersionsToPull(pullCtx *contexts.PullContext) ([]semver.Version, *releases.VersionsPlan, error) {
+	if pullCtx.SpecificVersion != nil {
+		pullCtx.Logger.InfoF("Skipped releases lookup as release %v is specifically requested with --release", pullCtx.SpecificVersion)
+		return []semver.Version{*pullCtx.SpecificVersion}, nil, nil
+	}
+	if !pullCtx.PullsComponent(contexts.ComponentPlatform) && !pullCtx.PullsComponent(contexts.ComponentStandaloneInstallers) {
+		pullCtx.Logger.InfoLn("Skipped releases lookup as neither platform nor standalone installers are pulled")
+		return nil, nil, nil
+	}
+
+	plan, err := releases.PlanVersionsToMirror(pullCtx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Find versions to mirror: %w", err)
+	}
+	return plan.SelectedVersions(), plan, nil
 }
 
+// getDeckhouseExternalModules lists modules in the source registry, leaving only the ones listed in modulesSpec if it is given.
+func getDeckhouseExternalModules(pullCtx *contexts.PullContext, modulesSpec *modules.Filter) ([]modules.Module, error) {
+	modulesData, err := modules.GetDeckhouseExternalModules(pullCtx)
+	if err != nil || modulesSpec == nil {
+		return modulesData, err
+	}
+
+	modulesData, pullCtx.PinnedModules, err = modulesSpec.PinVersions(modulesData)
+	if err != nil {
+		return nil, fmt.Errorf("pin module versions: %w", err)
+	}
+	for moduleName, versions := range pullCtx.PinnedModules {
+		pullCtx.Logger.InfoF("Module %s is pinned to versions %s", moduleName, strings.Join(versions, ", "))
+	}
+	return modulesData, nil
+}
+
+// PullDeckhouseToLocalFS pulls Deckhouse releases, modules and vulnerability databases into layouts under pullCtx.UnpackedImagesPath.
+// If modulesSpec is given, only modules listed in it are pulled in their pinned versions.
 func PullDeckhouseToLocalFS(
 	pullCtx *contexts.PullContext,
 	versions []semver.Version,
+	modulesSpec *modules.Filter,
 ) error {
 	logger := pullCtx.Logger
 	var err error
@@ -308,7 +697,7 @@ func PullDeckhouseToLocalFS(
 
 	if !pullCtx.SkipModulesPull {
 		logger.InfoF("Fetching Deckhouse external modules list")
-		modulesData, err = modules.GetDeckhouseExternalModules(pullCtx)
+		modulesData, err = getDeckhouseExternalModules(pullCtx, modulesSpec)
 		if err != nil {
 			return fmt.Errorf("get Deckhouse modules: %w", err)
 		}
@@ -322,6 +711,7 @@ func PullDeckhouseToLocalFS(
 	logger.InfoLn("Created OCI Image Layouts")
 
 	layouts.FillLayoutsWithBasicDeckhouseImages(pullCtx, imageLayouts, versions)
+	layouts.LeaveOutSkippedComponents(pullCtx, imageLayouts)
 	if err = imageLayouts.TagsResolver.ResolveTagsDigestsForImageLayouts(&pullCtx.BaseContext, imageLayouts); err != nil {
 		return fmt.Errorf("Resolve images tags to digests: %w", err)
 	}
@@ -335,37 +725,108 @@ func PullDeckhouseToLocalFS(
 	}
 
 	logger.InfoF("Searching for Deckhouse built-in modules digests")
+	installerImages := map[string]struct{}{}
+	releaseImages := map[string]map[string]struct{}{}
 	for imageTag := range imageLayouts.InstallImages {
 		digests, err := images.ExtractImageDigestsFromDeckhouseInstaller(pullCtx, imageTag, imageLayouts.Install)
 		if err != nil {
 			return fmt.Errorf("extract images digests: %w", err)
 		}
-		maps.Copy(imageLayouts.DeckhouseImages, digests)
+		maps.Copy(installerImages, digests)
+
+		// Release channel installers duplicate one of the releases, only versioned ones are accounted in footprints.
+		version := imageTag[strings.LastIndex(imageTag, ":")+1:]
+		if _, err = semver.NewVersion(version); err == nil {
+			releaseImages[version] = maps.Clone(digests)
+			releaseImages[version][imageTag] = struct{}{}
+			releaseImages[version][pullCtx.DeckhouseRegistryRepo+":"+version] = struct{}{}
+		}
+	}
+
+	suspiciousImages, err := images.VerifyImagesExistInRegistry(&pullCtx.BaseContext, installerImages)
+	if err != nil {
+		return fmt.Errorf("verify images digests: %w", err)
 	}
+	for _, suspiciousImage := range suspiciousImages {
+		logger.WarnF("⚠️ Skipping suspicious image extracted from installer %s", suspiciousImage)
+	}
+	maps.Copy(imageLayouts.DeckhouseImages, installerImages)
 	logger.InfoF("Found %d images", len(imageLayouts.DeckhouseImages))
 
 	if err = layouts.PullDeckhouseReleaseChannels(pullCtx, imageLayouts); err != nil {
 		return fmt.Errorf("pull release channels: %w", err)
 	}
 
-	// We should not generate deckhousereleases.yaml manifest for single-release bundles
-	if pullCtx.SpecificVersion == nil {
+	// We should not generate deckhousereleases.yaml manifest for single-release bundles and bundles without platform
+	if pullCtx.SpecificVersion == nil && pullCtx.PullsComponent(contexts.ComponentPlatform) {
 		logger.InfoF("Generating DeckhouseRelease manifests")
-		deckhouseReleasesManifestFile := filepath.Join(filepath.Dir(pullCtx.BundlePath), "deckhousereleases.yaml")
+		deckhouseReleasesManifestFile := filepath.Join(filepath.Dir(pullCtx.BundlePath), bundle.ReleaseManifestsFile)
+		switch {
+		case s3.IsURL(pullCtx.BundlePath):
+			deckhouseReleasesManifestFile = filepath.Join(TempDir, bundle.ReleaseManifestsFile)
+		case pullCtx.UnpackedImagesPath == pullCtx.BundlePath:
+			// Bundle left unpacked with --no-pack is a directory, manifests are kept inside it to be moved along with it.
+			deckhouseReleasesManifestFile = filepath.Join(pullCtx.BundlePath, bundle.ReleaseManifestsFile)
+		}
 		if err = manifests.GenerateDeckhouseReleaseManifestsForVersions(versions, deckhouseReleasesManifestFile, imageLayouts.ReleaseChannel); err != nil {
 			return fmt.Errorf("Generate DeckhouseRelease manifests: %w", err)
 		}
+		if s3.IsURL(pullCtx.BundlePath) {
+			manifestURL, err := bundle.UploadSidecarFile(pullCtx.Run.Context(), pullCtx.BundlePath, deckhouseReleasesManifestFile)
+			if err != nil {
+				return fmt.Errorf("Upload DeckhouseRelease manifests: %w", err)
+			}
+			logger.InfoF("DeckhouseRelease manifests are uploaded to %s", manifestURL)
+		}
 	}
 
 	if err = layouts.PullDeckhouseImages(pullCtx, imageLayouts); err != nil {
 		return fmt.Errorf("pull Deckhouse: %w", err)
 	}
 
-	logger.InfoLn("Pulling Trivy vulnerability databases")
-	if err = layouts.PullTrivyVulnerabilityDatabasesImages(pullCtx, imageLayouts); err != nil {
-		return fmt.Errorf("pull vulnerability database: %w", err)
+	footprints, err := layouts.ComputeReleaseFootprints(imageLayouts, releaseImages)
+	if err != nil {
+		return fmt.Errorf("Compute release footprints: %w", err)
+	}
+	for _, footprint := range footprints {
+		pullCtx.Run.RecordReleaseFootprint(footprint)
+	}
+	if err = bundle.WriteReleaseFootprints(pullCtx.UnpackedImagesPath, footprints); err != nil {
+		return err
+	}
+
+	tagConflicts, err := layouts.CheckTagsConsistency(imageLayouts)
+	if err != nil {
+		return fmt.Errorf("check tags consistency: %w", err)
+	}
+	for _, conflict := range tagConflicts {
+		logger.WarnF("⚠️ Conflicting tag %s", conflict)
+	}
+	missingInstallers, err := layouts.CheckInstallersCompleteness(imageLayouts)
+	if err != nil {
+		return fmt.Errorf("check installers completeness: %w", err)
+	}
+	if len(missingInstallers) > 0 {
+		missing := make([]string, 0, len(missingInstallers))
+		for _, installer := range missingInstallers {
+			missing = append(missing, installer.String())
+		}
+		if !pullCtx.AllowIncomplete {
+			return fmt.Errorf("Installers of pulled Deckhouse releases are missing from source registry, use --allow-incomplete to pull bundle without them:\n\t%s",
+				strings.Join(missing, "\n\t"))
+		}
+		for _, installer := range missing {
+			logger.WarnF("⚠️ Missing installer %s", installer)
+		}
+	}
+
+	if pullCtx.PullsComponent(contexts.ComponentSecurity) {
+		logger.InfoLn("Pulling Trivy vulnerability databases")
+		if err = layouts.PullTrivyVulnerabilityDatabasesImages(pullCtx, imageLayouts); err != nil {
+			return fmt.Errorf("pull vulnerability database: %w", err)
+		}
+		logger.InfoLn("Trivy vulnerability databases pulled")
 	}
-	logger.InfoLn("Trivy vulnerability databases pulled")
 
 	if !pullCtx.SkipModulesPull {
 		logger.InfoLn("Searching for Deckhouse external modules images")
@@ -378,5 +839,15 @@ func PullDeckhouseToLocalFS(
 		}
 	}
 
+	logger.InfoLn("Validating structure of pulled layouts")
+	if err = layouts.ValidateStructure(imageLayouts, pullCtx.Platforms, pullCtx.Concurrency); err != nil {
+		if !pullCtx.AllowIncomplete {
+			return fmt.Errorf("Pulled layouts are malformed, use --allow-incomplete to pack them anyway:\n%w", err)
+		}
+		for _, line := range strings.Split(err.Error(), "\n") {
+			logger.WarnF("⚠️ %s", line)
+		}
+	}
+
 	return nil
 }
@@ -21,14 +21,63 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/modules"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/failover"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flagrules"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/notify"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/ratelimit"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/s3"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
 )
 
-func parseAndValidateParameters(_ *cobra.Command, args []string) error {
+var releaseChannelNameRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// flagRules reject flag combinations that pull cannot satisfy. Credentials flags default to environment variables,
+// so their conflicts are checked by value in parseAndValidateSourceAuthFileFlag and validateFixtureModeFlag instead.
+var flagRules = []flagrules.Rule{
+	flagrules.Conflicts("release", "min-version", "since-channel").Because("it is ambiguous which releases to pull"),
+	flagrules.Conflicts("since-channel", "min-version").Because("it is ambiguous which releases to pull"),
+	flagrules.Conflicts("explain-versions", "release").Because("there is nothing to explain when specific release is requested"),
+	flagrules.Conflicts("no-modules", "modules-path-suffix").Because("modules are not pulled"),
+	flagrules.Conflicts("no-modules", "modules-spec").Because("modules are not pulled"),
+	flagrules.Conflicts("skip-modules", "modules-path-suffix", "modules-spec").Because("modules are not pulled"),
+	flagrules.Conflicts("only", "skip-platform", "skip-modules", "skip-security", "skip-install-standalone", "no-modules").
+		Because("components not listed in --only are skipped anyway"),
+	flagrules.Conflicts("fixture-mode", "source", "source-fallback").Because("synthetic registry is pulled instead of the source one"),
+	flagrules.Conflicts("fixture-mode", "verify-source-signatures").Because("synthetic images are not signed"),
+	flagrules.Requires("key", "verify-source-signatures"),
+	flagrules.Requires("signature-policy", "verify-source-signatures").Because("signatures are not verified"),
+	flagrules.Conflicts("dry-run", "gost-digest", "inventory-file", "no-pull-resume", "no-pack").Because("bundle is not written in dry run"),
+	flagrules.Conflicts("estimate-size", "dry-run", "gost-digest", "inventory-file", "no-pull-resume", "no-pack").Because("bundle is not written when only its size is estimated"),
+	flagrules.Conflicts("no-pack", "images-bundle-chunk-size", "gost-digest", "inventory-file").Because("bundle is left in directory instead of tar archive"),
+	flagrules.Requires("health-timeout", "health-file", "health-addr").Because("health is not reported"),
+	flagrules.Conflicts("diff-against", "dry-run", "estimate-size").Because("bundle is not written"),
+	flagrules.Conflicts("sign-checksums", "dry-run", "estimate-size", "no-pack").Because("bundle is not packed"),
+	flagrules.Conflicts("encrypt-recipient", "dry-run", "estimate-size", "no-pack").Because("bundle is not packed"),
+	flagrules.Conflicts("encrypt-recipient", "inventory-file").Because("contents of encrypted bundle cannot be read back"),
+	flagrules.Requires("chunk-prefix", "images-bundle-chunk-size").Because("bundle is not split into chunks"),
+}
+
+func parseAndValidateParameters(cmd *cobra.Command, args []string) error {
 	var err error
+	if err = flagrules.Validate(cmd.Flags(), flagRules...); err != nil {
+		return err
+	}
 	if err = parseAndValidateVersionFlags(); err != nil {
 		return err
 	}
@@ -38,7 +87,126 @@ func parseAndValidateParameters(_ *cobra.Command, args []string) error {
 	if err = validateChunkSizeFlag(); err != nil {
 		return err
 	}
+	if err = applyChunkPrefixFlag(); err != nil {
+		return err
+	}
+	if err = validatePullConcurrencyFlag(); err != nil {
+		return err
+	}
+	if err = parseRateLimitFlag(); err != nil {
+		return err
+	}
+	if MaxSecurityDBAge < 0 {
+		return errors.New("--max-security-db-age cannot be negative")
+	}
+	if err = parseAndValidateSignatureFlags(); err != nil {
+		return err
+	}
+	if ChecksumsSigningKeyPath != "" {
+		if s3.IsURL(ImagesBundlePath) {
+			return errors.New("--sign-checksums cannot be used with bundles written to S3-compatible object storage")
+		}
+		if checksumsSigner, err = signature.LoadSigner(ChecksumsSigningKeyPath); err != nil {
+			return fmt.Errorf("Load checksums signing key: %w", err)
+		}
+	}
+	if len(EncryptRecipientPaths) > 0 {
+		if bundleRecipients, err = bundle.LoadOpenPGPKeys(EncryptRecipientPaths, nil); err != nil {
+			return fmt.Errorf("Load bundle recipients keys: %w", err)
+		}
+	}
+	if err = parseComponentFlags(); err != nil {
+		return err
+	}
+	if err = parseSkipAnnotatedFlag(); err != nil {
+		return err
+	}
+	if err = parseExclusionFlags(); err != nil {
+		return err
+	}
 
+	if err = parseAndValidateSourceAuthFileFlag(); err != nil {
+		return err
+	}
+	if err = parseSourceFallbackFlag(); err != nil {
+		return err
+	}
+	if err = validateFixtureModeFlag(); err != nil {
+		return err
+	}
+	if err = validateModulesPathSuffixFlag(); err != nil {
+		return err
+	}
+	if err = parseModulesSpecFlag(cmd); err != nil {
+		return err
+	}
+	if err = parsePlatformsFlag(); err != nil {
+		return err
+	}
+	if err = validateDiffAgainstFlag(); err != nil {
+		return err
+	}
+	if notifier, err = notify.NewNotifier(NotifyURL, notify.Template(NotifyTemplate)); err != nil {
+		return fmt.Errorf("Invalid --notify-url: %w", err)
+	}
+
+	return nil
+}
+
+func validateDiffAgainstFlag() error {
+	if DiffAgainst == "" || s3.IsURL(DiffAgainst) {
+		return nil
+	}
+	DiffAgainst = filepath.Clean(DiffAgainst)
+	if filepath.Ext(DiffAgainst) != ".tar" {
+		return errors.New("--diff-against should be a path to tar archive (.tar)")
+	}
+	if DiffAgainst == ImagesBundlePath {
+		return errors.New("--diff-against cannot point to the bundle being pulled")
+	}
+	return nil
+}
+
+func parseAndValidateSourceAuthFileFlag() error {
+	if SourceRegistryAuthFile == "" {
+		return nil
+	}
+	if SourceRegistryLogin != "" || DeckhouseLicenseToken != "" {
+		return errors.New("--source-insecure-basic-auth-file cannot be used together with --source-login or --license")
+	}
+
+	var err error
+	sourceRegistryFileAuth, err = auth.LoadAuthFileForRepo(SourceRegistryAuthFile, SourceRegistryRepo)
+	if err != nil {
+		return fmt.Errorf("Invalid --source-insecure-basic-auth-file: %w", err)
+	}
+	return nil
+}
+
+func parseSourceFallbackFlag() error {
+	if len(SourceFallbackRepos) == 0 {
+		return nil
+	}
+
+	nameOpts := make([]name.Option, 0)
+	if Insecure {
+		nameOpts = append(nameOpts, name.Insecure)
+	}
+	var err error
+	sourceFailover, err = failover.NewGroup(SourceRegistryRepo, SourceFallbackRepos, nameOpts...)
+	if err != nil {
+		return fmt.Errorf("Invalid --source-fallback: %w", err)
+	}
+	return nil
+}
+
+func validateFixtureModeFlag() error {
+	if !FixtureMode {
+		return nil
+	}
+	if SourceRegistryLogin != "" || DeckhouseLicenseToken != "" || SourceRegistryAuthFile != "" {
+		return errors.New("--fixture-mode pulls from synthetic registry and cannot be used with source registry credentials")
+	}
 	return nil
 }
 
@@ -46,6 +214,23 @@ func validateImagesBundlePathArg(args []string) error {
 	if len(args) != 1 {
 		return errors.New("invalid number of arguments")
 	}
+	if NoPack {
+		return validateUnpackedBundlePathArg(args[0])
+	}
+
+	if s3.IsURL(args[0]) {
+		ImagesBundlePath = args[0]
+		if _, _, err := s3.ParseURL(ImagesBundlePath); err != nil {
+			return err
+		}
+		if path.Ext(ImagesBundlePath) != ".tar" {
+			return errors.New("images-bundle-path argument should be a path to tar archive (.tar)")
+		}
+		if DoGOSTDigest {
+			return errors.New("--gost-digest is not supported for bundles stored in S3")
+		}
+		return nil
+	}
 
 	ImagesBundlePath = filepath.Clean(args[0])
 	if filepath.Ext(ImagesBundlePath) != ".tar" {
@@ -69,9 +254,33 @@ func validateImagesBundlePathArg(args []string) error {
 	return nil
 }
 
+// validateUnpackedBundlePathArg only accepts empty directory or directory of interrupted pull to resume,
+// as contents of the directory are removed if pull cannot be resumed.
+func validateUnpackedBundlePathArg(bundlePath string) error {
+	if s3.IsURL(bundlePath) {
+		return errors.New("--no-pack bundle cannot be written to S3")
+	}
+
+	ImagesBundlePath = filepath.Clean(bundlePath)
+	entries, err := os.ReadDir(ImagesBundlePath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if _, err = os.Stat(filepath.Dir(ImagesBundlePath)); err != nil {
+			return err
+		}
+	case err != nil:
+		return fmt.Errorf("%s should be a directory with --no-pack: %w", ImagesBundlePath, err)
+	case len(entries) > 0:
+		if _, err = os.Stat(filepath.Join(ImagesBundlePath, contexts.PullCheckpointFile)); err != nil {
+			return fmt.Errorf("%s is not empty and has no interrupted pull to resume", ImagesBundlePath)
+		}
+	}
+	return nil
+}
+
 func parseAndValidateVersionFlags() error {
-	if minVersionString != "" && specificReleaseString != "" {
-		return errors.New("Using both --release and --min-version at the same time is ambiguous.")
+	if SinceChannel != "" && !releaseChannelNameRegexp.MatchString(SinceChannel) {
+		return fmt.Errorf("Invalid release channel name %q", SinceChannel)
 	}
 
 	var err error
@@ -98,3 +307,170 @@ func validateChunkSizeFlag() error {
 
 	return nil
 }
+
+var chunkPrefixRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// applyChunkPrefixFlag prepends --chunk-prefix to the name of bundle, so that its chunks and other files written next to it are named after it.
+func applyChunkPrefixFlag() error {
+	if ChunkPrefix == "" {
+		return nil
+	}
+	if !chunkPrefixRegexp.MatchString(ChunkPrefix) {
+		return fmt.Errorf("--chunk-prefix %q should only contain letters, digits, dots, dashes and underscores", ChunkPrefix)
+	}
+	if s3.IsURL(ImagesBundlePath) {
+		nameStart := strings.LastIndex(ImagesBundlePath, "/") + 1
+		ImagesBundlePath = ImagesBundlePath[:nameStart] + ChunkPrefix + ImagesBundlePath[nameStart:]
+		return nil
+	}
+	ImagesBundlePath = filepath.Join(filepath.Dir(ImagesBundlePath), ChunkPrefix+filepath.Base(ImagesBundlePath))
+	return nil
+}
+
+func validatePullConcurrencyFlag() error {
+	if PullConcurrency < 1 {
+		return errors.New("--pull-concurrency should be at least 1")
+	}
+	return nil
+}
+
+func parseRateLimitFlag() error {
+	if rateLimitString == "" {
+		return nil
+	}
+
+	var err error
+	RateLimit, err = ratelimit.ParseRate(rateLimitString)
+	if err != nil {
+		return fmt.Errorf("Invalid --rate-limit: %w", err)
+	}
+	return nil
+}
+
+func parseSkipAnnotatedFlag() error {
+	if len(skipAnnotatedStrings) == 0 {
+		return nil
+	}
+
+	SkipAnnotated = make(map[string]string, len(skipAnnotatedStrings))
+	for _, annotation := range skipAnnotatedStrings {
+		key, value, found := strings.Cut(annotation, "=")
+		if !found || key == "" {
+			return fmt.Errorf("Invalid --skip-annotated %q, expected key=value", annotation)
+		}
+		SkipAnnotated[key] = value
+	}
+	return nil
+}
+
+func parseExclusionFlags() error {
+	var err error
+	Exclusions, err = contexts.NewImageExclusions(ExcludeRefs, ExcludeDigests)
+	if err != nil {
+		return fmt.Errorf("Invalid --exclude-ref or --exclude-digest: %w", err)
+	}
+	return nil
+}
+
+func parseAndValidateSignatureFlags() error {
+	if SignaturePolicy != contexts.SignaturePolicyEnforce && SignaturePolicy != contexts.SignaturePolicyWarn {
+		return fmt.Errorf("Unknown signature policy %q, expected %q or %q", SignaturePolicy, contexts.SignaturePolicyEnforce, contexts.SignaturePolicyWarn)
+	}
+
+	if !VerifySourceSignatures {
+		return nil
+	}
+
+	if SourceSignatureKeyPath == "" {
+		return errors.New("--verify-source-signatures requires vendor public key to be provided with --key")
+	}
+
+	var err error
+	SourceSignatureKey, err = signature.LoadVerificationKey(SourceSignatureKeyPath, "")
+	if err != nil {
+		return fmt.Errorf("Load source signature verification key: %w", err)
+	}
+	return nil
+}
+
+func validateModulesPathSuffixFlag() error {
+	ModulesPathSuffix = strings.Trim(ModulesPathSuffix, "/")
+	if err := contexts.ValidateModulesPathSuffix(ModulesPathSuffix); err != nil {
+		return fmt.Errorf("Invalid --modules-path-suffix: %w", err)
+	}
+	return nil
+}
+
+func parseModulesSpecFlag(cmd *cobra.Command) error {
+	if ModulesSpecPath == "" {
+		return nil
+	}
+	if NoModules {
+		return errors.New("--modules-spec is given while modules are not pulled")
+	}
+
+	var err error
+	ModulesSpec, err = modules.NewFilterFromSpec(ModulesSpecPath, output.FromCommand(cmd).Logger())
+	if err != nil {
+		return fmt.Errorf("Invalid --modules-spec: %w", err)
+	}
+	return nil
+}
+
+func parsePlatformsFlag() error {
+	var err error
+	Platforms, AllPlatforms, err = ParsePlatforms(platformStrings)
+	return err
+}
+
+// ParsePlatforms parses values of --platforms flag: either os/arch[/variant] platforms or a single "all".
+func ParsePlatforms(platformStrings []string) (platforms []v1.Platform, all bool, err error) {
+	if slices.Contains(platformStrings, "all") {
+		if len(platformStrings) > 1 {
+			return nil, false, errors.New("--platforms all cannot be combined with other platforms")
+		}
+		return nil, true, nil
+	}
+
+	for _, platformString := range platformStrings {
+		platform, err := v1.ParsePlatform(strings.TrimSpace(platformString))
+		if err != nil {
+			return nil, false, fmt.Errorf("Invalid --platforms value %q: %w", platformString, err)
+		}
+		if platform.OS == "" || platform.Architecture == "" {
+			return nil, false, fmt.Errorf("Invalid --platforms value %q: expected os/arch[/variant]", platformString)
+		}
+		platforms = append(platforms, *platform)
+	}
+	return platforms, false, nil
+}
+
+// parseComponentFlags builds the set of skipped distribution components from --only and --skip-* flags.
+// Skipped modules are also recorded as NoModules, which the rest of pull checks.
+func parseComponentFlags() error {
+	SkippedComponents = map[string]bool{
+		contexts.ComponentPlatform:             SkipPlatform,
+		contexts.ComponentModules:              SkipModules || NoModules,
+		contexts.ComponentSecurity:             SkipSecurity,
+		contexts.ComponentStandaloneInstallers: SkipStandalone,
+	}
+	if len(OnlyComponents) > 0 {
+		for _, component := range contexts.Components {
+			SkippedComponents[component] = true
+		}
+		for _, component := range OnlyComponents {
+			if !slices.Contains(contexts.Components, component) {
+				return fmt.Errorf("Unknown component %q in --only, expected one of %s", component, strings.Join(contexts.Components, ", "))
+			}
+			SkippedComponents[component] = false
+		}
+	}
+
+	NoModules = SkippedComponents[contexts.ComponentModules]
+	for _, skipped := range SkippedComponents {
+		if !skipped {
+			return nil
+		}
+	}
+	return errors.New("All components of distribution are skipped, there is nothing to pull")
+}
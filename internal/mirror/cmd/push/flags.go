@@ -20,6 +20,10 @@ import (
 	"os"
 
 	"github.com/spf13/pflag"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/health"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/notify"
 )
 
 func addFlags(flagSet *pflag.FlagSet) {
@@ -37,6 +41,13 @@ func addFlags(flagSet *pflag.FlagSet) {
 		os.Getenv("D8_MIRROR_REGISTRY_PASSWORD"),
 		"Password to log into the target registry.",
 	)
+	flagSet.StringVar(
+		&RegistryAuthFile,
+		"target-auth-file",
+		os.Getenv("D8_MIRROR_REGISTRY_AUTH_FILE"),
+		"File with credentials to log into the target registry, either Docker config.json or a single username:password line. "+
+			"Must be accessible only by its owner. Conflicts with --registry-login.",
+	)
 	flagSet.BoolVar(
 		&TLSSkipVerify,
 		"tls-skip-verify",
@@ -49,4 +60,138 @@ func addFlags(flagSet *pflag.FlagSet) {
 		false,
 		"Interact with registries over HTTP.",
 	)
+	flagSet.StringVar(
+		&rateLimitString,
+		"rate-limit",
+		"",
+		"Limit bandwidth of all registry transfers to this many bytes per second, e.g. 50MiB or 500KB.",
+	)
+	flagSet.BoolVar(
+		&SkipChecksums,
+		"skip-checksums",
+		false,
+		"Do not check bundle files against checksums.sha256 written next to the bundle by d8 mirror pull before unpacking them.",
+	)
+	flagSet.StringVar(
+		&ChecksumsKeyPath,
+		"checksums-key",
+		"",
+		"Path to PEM-encoded public key or x509 certificate to verify checksums.sha256.sig with. Bundle must then be listed in signed checksums.sha256.",
+	)
+	flagSet.StringArrayVar(
+		&DecryptKeyPaths,
+		"decrypt-key",
+		nil,
+		"Path to armored or binary OpenPGP private key, e.g. exported with gpg --export-secret-keys, "+
+			"to decrypt bundle encrypted by d8 mirror pull --encrypt-recipient with. Can be repeated.",
+	)
+	flagSet.StringVar(
+		&DecryptKeyPassphrase,
+		"decrypt-key-passphrase",
+		os.Getenv("D8_MIRROR_DECRYPT_KEY_PASSPHRASE"),
+		"Passphrase of the keys given with --decrypt-key, if they are protected with one.",
+	)
+	flagSet.BoolVar(
+		&StreamBundle,
+		"stream",
+		false,
+		"Push images straight from packed bundle, tar file or its chunks, without unpacking it into a temporary directory first. "+
+			"Only bundle metadata is unpacked, so almost no scratch disk space is needed. Encrypted bundles cannot be streamed.",
+	)
+	flagSet.BoolVar(
+		&SkipExistingTags,
+		"skip-existing-tags",
+		false,
+		"Do not re-upload images whose tags are already present in the target registry with the same digest. "+
+			"Useful for registries with immutable tags. Version and digest tags pointing to different images are reported as conflicts, release channel tags are overwritten.",
+	)
+	flagSet.BoolVar(
+		&IncludeCosign,
+		"include-cosign",
+		false,
+		"Push cosign signatures, attestations and SBOMs pulled into the bundle with --include-cosign, they are left out otherwise.",
+	)
+	flagSet.BoolVar(
+		&FlattenRepositories,
+		"flatten-repositories",
+		false,
+		"Push nested repositories, like modules and installers, next to the target repo instead of inside it. "+
+			"For registries that limit repository path depth, like Docker Hub. See --flatten-mapping-file.",
+	)
+	flagSet.StringVar(
+		&FlattenMappingPath,
+		"flatten-mapping-file",
+		"d8-mirror-repositories-mapping.json",
+		"Where to write the mapping of nested repositories to the ones they were pushed into by --flatten-repositories "+
+			"or lowercased to, as registries do not allow uppercase characters in repository names.",
+	)
+	flagSet.IntVar(
+		&PruneOldPatches,
+		"prune-old-patches",
+		0,
+		"After successful push, delete version tags of Deckhouse repositories in the target registry that are older than N newest patches of their minor release. "+
+			"Tags referring to the same images as release channel tags are kept.",
+	)
+	flagSet.BoolVar(
+		&PruneDryRun,
+		"prune-dry-run",
+		false,
+		"Only print tags that --prune-old-patches would delete.",
+	)
+	flagSet.StringVar(
+		&ModulesPathSuffix,
+		"modules-path-suffix",
+		contexts.DefaultModulesPathSuffix,
+		"Path of modules repositories relative to the target repo, for registries that host modules outside of the standard location.",
+	)
+	flagSet.BoolVar(
+		&KeepWorkDir,
+		"keep-workdir",
+		false,
+		"Do not remove temporary working directories after the command is finished. Useful for debugging.",
+	)
+	flagSet.StringVar(
+		&HealthFile,
+		"health-file",
+		"",
+		"Periodically touch this file while mirroring makes progress, for use in Kubernetes liveness probes. File is removed once no progress is made for --health-timeout.",
+	)
+	flagSet.StringVar(
+		&HealthAddr,
+		"health-addr",
+		"",
+		"Serve HTTP health checks on this address, e.g. :8080. Responds with 503 once no progress is made for --health-timeout.",
+	)
+	flagSet.DurationVar(
+		&HealthTimeout,
+		"health-timeout",
+		health.DefaultStaleTimeout,
+		"Time without progress after which mirroring is considered stuck by --health-file and --health-addr.",
+	)
+	flagSet.StringVar(
+		&ProgressSocket,
+		"progress-socket",
+		"",
+		"Stream mirroring progress as newline-delimited JSON to clients of the Unix socket created at this path.",
+	)
+	flagSet.StringVar(
+		&HarborSetupScriptPath,
+		"emit-harbor-setup",
+		"",
+		"If the target registry is Harbor, write to this path a script that idempotently creates the project, "+
+			"a pull-only robot account and a retention policy matching the pushed repositories.",
+	)
+	flagSet.StringVar(
+		&NotifyURL,
+		"notify-url",
+		os.Getenv("D8_MIRROR_NOTIFY_URL"),
+		"Webhook to POST summary of the finished push to: mirrored releases, registry traffic, duration and errors.",
+	)
+	flagSet.StringVar(
+		&NotifyTemplate,
+		"notify-template",
+		string(notify.TemplateJSON),
+		`Shape of --notify-url message: "json" to post summary as is, "slack" for Slack incoming webhooks `+
+			`or "telegram" for Telegram Bot API sendMessage URL with chat_id query parameter.`,
+	)
 }
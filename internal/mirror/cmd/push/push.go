@@ -17,21 +17,37 @@ limitations under the License.
 package push
 
 import (
+	"context"
+	"crypto"
+	"errors"
 	"fmt"
-	"log/slog"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/spf13/cobra"
 	"k8s.io/kubectl/pkg/util/templates"
 
+	"github.com/deckhouse/deckhouse-cli/internal/output"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/operations"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/harbor"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/health"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/httppool"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/notify"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/progress"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/ratelimit"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/regcaps"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/s3"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/throttle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/workdir"
 )
 
 var pushLong = templates.LongDesc(`
@@ -39,6 +55,17 @@ Upload Deckhouse Kubernetes Platform distribution bundle to the third-party regi
 
 This command pushes the Deckhouse Kubernetes Platform distribution into the specified container registry.
 
+Bundle left unpacked by d8 mirror pull --no-pack is pushed by passing its directory as images-bundle-path.
+
+Bundle pulled into S3-compatible object storage can be pushed by passing its s3://bucket/path/to/bundle.tar URL
+as images-bundle-path, storage is configured with the same environment variables d8 mirror pull uses.
+
+With --stream, images are pushed straight from packed bundle instead of unpacking it into a temporary directory first,
+for hosts that lack scratch disk space to hold the unpacked bundle.
+
+Requests throttled by registry quotas are repeated with growing delays instead of failing the push,
+registries that throttled any requests are listed once images are pushed.
+
 For more information on how to use it, consult the docs at 
 https://deckhouse.io/products/kubernetes-platform/documentation/v1/deckhouse-faq.html#manually-uploading-images-to-an-air-gapped-registry
 
@@ -58,9 +85,6 @@ func NewCommand() *cobra.Command {
 		SilenceUsage:  true,
 		PreRunE:       parseAndValidateParameters,
 		RunE:          push,
-		PostRunE: func(_ *cobra.Command, _ []string) error {
-			return os.RemoveAll(TempDir)
-		},
 	}
 
 	addFlags(pushCmd.Flags())
@@ -74,15 +98,92 @@ var (
 	RegistryPath     string
 	RegistryUsername string
 	RegistryPassword string
+	RegistryAuthFile string
+
+	registryFileAuth authn.Authenticator
 
 	Insecure         bool
 	TLSSkipVerify    bool
+	SkipExistingTags bool
+	IncludeCosign    bool
+	rateLimitString  string
+	RateLimit        int64 // bytes per second
 	ImagesBundlePath string
+
+	FlattenRepositories bool
+	FlattenMappingPath  string
+
+	ModulesPathSuffix string
+
+	PruneOldPatches int
+	PruneDryRun     bool
+
+	KeepWorkDir bool
+
+	HealthFile    string
+	HealthAddr    string
+	HealthTimeout time.Duration
+
+	ProgressSocket string
+
+	HarborSetupScriptPath string
+
+	SkipChecksums    bool
+	ChecksumsKeyPath string
+	checksumsKey     crypto.PublicKey
+
+	DecryptKeyPaths      []string
+	DecryptKeyPassphrase string
+	bundleDecryptionKeys openpgp.EntityList
+
+	StreamBundle bool
+
+	NotifyURL      string
+	NotifyTemplate string
+	notifier       *notify.Notifier
 )
 
-func push(_ *cobra.Command, _ []string) error {
-	mirrorCtx := buildPushContext()
+func push(cmd *cobra.Command, _ []string) (err error) {
+	mirrorCtx := buildPushContext(output.FromCommand(cmd))
 	logger := mirrorCtx.Logger
+	notifyRun := notify.StartRun("push")
+	defer func() { notifier.Notify(logger, notifyRun.Summary(err)) }()
+
+	if RateLimit > 0 {
+		disableRateLimit := ratelimit.Enable(ratelimit.NewLimiter(RateLimit))
+		defer disableRateLimit()
+	}
+	throttler := throttle.NewThrottler()
+	disableThrottling := throttle.Enable(throttler)
+	defer disableThrottling()
+
+	workDirs := workdir.NewManager(TempDir, KeepWorkDir, logger)
+	stopCleanupOnInterrupt := workDirs.CleanupOnInterrupt()
+	defer stopCleanupOnInterrupt()
+	defer func() {
+		if cleanupErr := workDirs.Cleanup(err); cleanupErr != nil && err == nil {
+			err = fmt.Errorf("Cleanup temporary data after mirroring: %w", cleanupErr)
+		}
+	}()
+
+	stopHealthReporting, err := health.Start(mirrorCtx.Run, health.Options{
+		File:         HealthFile,
+		Addr:         HealthAddr,
+		StaleTimeout: HealthTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("Start health reporting: %w", err)
+	}
+	defer stopHealthReporting()
+
+	stopProgressReporting, err := progress.Serve(mirrorCtx.Run, progress.Options{
+		SocketPath: ProgressSocket,
+		Operation:  "push",
+	})
+	if err != nil {
+		return fmt.Errorf("Start progress reporting: %w", err)
+	}
+	defer func() { stopProgressReporting(err) }()
 
 	if RegistryUsername != "" {
 		mirrorCtx.RegistryAuth = authn.FromConfig(authn.AuthConfig{
@@ -90,6 +191,12 @@ func push(_ *cobra.Command, _ []string) error {
 			Password: RegistryPassword,
 		})
 	}
+	if registryFileAuth != nil {
+		mirrorCtx.RegistryAuth = registryFileAuth
+	}
+	if mirrorCtx.RegistryAuth == nil {
+		mirrorCtx.RegistryAuth = auth.DefaultAuthenticator(mirrorCtx.RegistryHost + mirrorCtx.RegistryPath)
+	}
 
 	if err := auth.ValidateWriteAccessForRepo(
 		mirrorCtx.RegistryHost+mirrorCtx.RegistryPath,
@@ -102,14 +209,40 @@ func push(_ *cobra.Command, _ []string) error {
 		}
 	}
 
+	probeRegistryCapabilities(mirrorCtx)
+
+	if err = emitHarborSetup(mirrorCtx); err != nil {
+		return err
+	}
+
 	if filepath.Ext(mirrorCtx.BundlePath) == ".tar" || filepath.Ext(mirrorCtx.BundlePath) == ".chunk" {
-		err := logger.Process("Unpacking Deckhouse bundle", func() error {
-			return bundle.Unpack(&mirrorCtx.BaseContext)
-		})
+		if !SkipChecksums && !s3.IsURL(mirrorCtx.BundlePath) {
+			if err = verifyChecksums(mirrorCtx); err != nil {
+				return err
+			}
+		}
+
+		workDir, err := workDirs.Create(time.Now().Format("mirror_tmp_02-01-2006_15-04-05"))
 		if err != nil {
 			return err
 		}
-		defer os.RemoveAll(mirrorCtx.UnpackedImagesPath)
+		mirrorCtx.UnpackedImagesPath = workDir.Path
+
+		if StreamBundle {
+			streamedBundle, err := openStreamedBundle(mirrorCtx)
+			if err != nil {
+				return err
+			}
+			defer streamedBundle.Close()
+			mirrorCtx.StreamedBundle = streamedBundle
+		} else {
+			err = logger.Process("Unpacking Deckhouse bundle", func() error {
+				return bundle.Unpack(&mirrorCtx.BaseContext)
+			})
+			if err != nil {
+				return err
+			}
+		}
 	} else {
 		bundleStat, err := os.Stat(mirrorCtx.BundlePath)
 		if err != nil {
@@ -117,6 +250,9 @@ func push(_ *cobra.Command, _ []string) error {
 		}
 
 		if bundleStat.IsDir() {
+			if StreamBundle {
+				logger.WarnLn("Bundle is already unpacked, --stream is ignored")
+			}
 			logger.InfoLn("Using bundle at", mirrorCtx.BundlePath)
 			mirrorCtx.UnpackedImagesPath = mirrorCtx.BundlePath
 			if err := bundle.ValidateUnpackedBundle(mirrorCtx); err != nil {
@@ -127,22 +263,117 @@ func push(_ *cobra.Command, _ []string) error {
 		}
 	}
 
-	err := logger.Process("Push Deckhouse images to registry", func() error {
-		return operations.PushDeckhouseToRegistry(mirrorCtx)
+	delta, err := bundle.ReadDelta(mirrorCtx.UnpackedImagesPath)
+	if err != nil {
+		return err
+	}
+	if delta != nil {
+		logger.InfoF("Bundle is a delta against %s, layers stored in it are expected to be present in registry already", delta.Base)
+	}
+
+	err = logger.Process("Push Deckhouse images to registry", func() error {
+		return operations.PushDeckhouseToRegistryContext(mirrorCtx.Run.Context(), mirrorCtx)
 	})
+	reportThrottling(logger, throttler)
 	if err != nil {
+		if delta != nil {
+			return fmt.Errorf("%w\nBundle is a delta against %s, which must be pushed to the registry first", err, delta.Base)
+		}
 		return err
 	}
+	logger.InfoF("Connections: %s", httppool.Snapshot())
+
+	if PruneOldPatches > 0 {
+		err = logger.Process("Prune old patch versions", func() error {
+			return pruneOldPatches(mirrorCtx)
+		})
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-func buildPushContext() *contexts.PushContext {
-	logLevel := slog.LevelInfo
-	if log.DebugLogLevel() >= 3 {
-		logLevel = slog.LevelDebug
+// reportThrottling summarizes throttling by registry quotas, which slowed the push down and hints at raising the quotas.
+func reportThrottling(logger contexts.Logger, throttler *throttle.Throttler) {
+	for _, host := range throttler.Summary() {
+		logger.WarnF("Registry %s", host)
+	}
+}
+
+// openStreamedBundle unpacks only metadata of packed bundle, images are then pushed straight from it.
+func openStreamedBundle(mirrorCtx *contexts.PushContext) (*bundle.StreamedBundle, error) {
+	var streamedBundle *bundle.StreamedBundle
+	err := mirrorCtx.Logger.Process("Reading Deckhouse bundle metadata", func() error {
+		var err error
+		streamedBundle, err = bundle.OpenStreamed(mirrorCtx.Run.Context(), &mirrorCtx.BaseContext)
+		if errors.Is(err, bundle.ErrNotStreamable) {
+			return fmt.Errorf("%w, push it without --stream and with --decrypt-key", err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	mirrorCtx.Logger.InfoLn("Images will be pushed straight from", mirrorCtx.BundlePath)
+	return streamedBundle, nil
+}
+
+// verifyChecksums checks bundle files against checksums file written by d8 mirror pull before unpacking them,
+// and checks signature of checksums file if --checksums-key is given.
+func verifyChecksums(mirrorCtx *contexts.PushContext) error {
+	logger := mirrorCtx.Logger
+	checksumsPath := bundle.ChecksumsPath(mirrorCtx.BundlePath)
+	if checksumsKey != nil {
+		rawChecksums, err := os.ReadFile(checksumsPath)
+		if err != nil {
+			return fmt.Errorf("Read bundle checksums: %w", err)
+		}
+		sig, err := os.ReadFile(checksumsPath + ".sig")
+		if err != nil {
+			return fmt.Errorf("Read checksums signature: %w", err)
+		}
+		if err = signature.Verify(checksumsKey, rawChecksums, sig); err != nil {
+			return fmt.Errorf("Checksums signature verification failed: %w", err)
+		}
+		logger.InfoLn("Checksums signature is valid")
 	}
-	logger := log.NewSLogger(logLevel)
+
+	return logger.Process("Verify bundle checksums", func() error {
+		verified, err := bundle.VerifyChecksums(mirrorCtx.BundlePath)
+		switch {
+		case errors.Is(err, bundle.ErrNoChecksums) && checksumsKey == nil:
+			logger.WarnF("⚠️ %s does not list bundle files, they are not checked for corruption before unpacking", checksumsPath)
+			return nil
+		case err != nil:
+			return fmt.Errorf("Bundle checksums verification failed: %w", err)
+		}
+		logger.InfoF("All %d bundle files match %s", verified, checksumsPath)
+		return nil
+	})
+}
+
+func pruneOldPatches(mirrorCtx *contexts.PushContext) error {
+	pruned, err := operations.PruneOldPatches(mirrorCtx.Run.Context(), mirrorCtx, PruneOldPatches, PruneDryRun)
+	for _, tag := range pruned {
+		if PruneDryRun {
+			mirrorCtx.Logger.InfoF("Would prune %s", tag)
+		} else {
+			mirrorCtx.Logger.InfoF("Pruned %s", tag)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Prune old patch versions: %w", err)
+	}
+	if len(pruned) == 0 {
+		mirrorCtx.Logger.InfoF("No tags older than %d newest patches of their minor release found", PruneOldPatches)
+	}
+	return nil
+}
+
+func buildPushContext(out *output.Output) *contexts.PushContext {
+	logger := out.Logger()
 
 	mirrorCtx := &contexts.PushContext{
 		BaseContext: contexts.BaseContext{
@@ -151,14 +382,86 @@ func buildPushContext() *contexts.PushContext {
 			SkipTLSVerification: TLSSkipVerify,
 			RegistryHost:        RegistryHost,
 			RegistryPath:        RegistryPath,
+			ModulesPathSuffix:   ModulesPathSuffix,
 			BundlePath:          ImagesBundlePath,
-			UnpackedImagesPath:  filepath.Join(TempDir, time.Now().Format("mirror_tmp_02-01-2006_15-04-05")),
+			Run:                 contexts.NewRunContext(context.Background()),
+
+			BundleDecryptionKeys: bundleDecryptionKeys,
 		},
 
 		Parallelism: contexts.ParallelismConfig{
 			Blobs:  4,
 			Images: 1,
 		},
+		SkipExistingTags:    SkipExistingTags,
+		IncludeCosign:       IncludeCosign,
+		FlattenRepositories: FlattenRepositories,
+		FlattenMappingPath:  FlattenMappingPath,
 	}
 	return mirrorCtx
 }
+
+// probeRegistryCapabilities detects optional APIs of target registry to choose upload strategies
+// and prints them in debug output. Push goes on with default strategies if probing fails.
+// Upload APIs are only probed when debug output is requested, as probing them leaves a blob in the target repository.
+func probeRegistryCapabilities(mirrorCtx *contexts.PushContext) {
+	nameOpts, _ := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&mirrorCtx.BaseContext)
+	repo, err := name.NewRepository(mirrorCtx.RegistryHost+mirrorCtx.RegistryPath, nameOpts...)
+	if err != nil {
+		mirrorCtx.Logger.DebugF("Registry capabilities probing failed: %v", err)
+		return
+	}
+	caps, err := regcaps.Probe(mirrorCtx.Run.Context(), repo, regcaps.Options{
+		Auth:      mirrorCtx.RegistryAuth,
+		Transport: auth.MakeTransport(mirrorCtx.SkipTLSVerification),
+		Write:     log.DebugLogLevel() >= 3,
+	})
+	if err != nil {
+		mirrorCtx.Logger.DebugF("Registry capabilities probing failed: %v", err)
+		return
+	}
+
+	mirrorCtx.RegistryCapabilities = caps
+	mirrorCtx.Logger.DebugLn("Registry capabilities:")
+	for _, line := range caps.Matrix() {
+		mirrorCtx.Logger.DebugF("  %s", line)
+	}
+}
+
+func emitHarborSetup(mirrorCtx *contexts.PushContext) error {
+	isHarbor, err := harbor.Detect(
+		mirrorCtx.Run.Context(),
+		mirrorCtx.RegistryHost,
+		mirrorCtx.Insecure,
+		mirrorCtx.SkipTLSVerification,
+	)
+	if err != nil {
+		mirrorCtx.Logger.DebugF("Harbor detection failed: %v", err)
+	}
+
+	switch {
+	case !isHarbor && HarborSetupScriptPath != "":
+		mirrorCtx.Logger.WarnLn("Target registry does not look like Harbor, --emit-harbor-setup is ignored")
+		return nil
+	case !isHarbor:
+		return nil
+	case HarborSetupScriptPath == "":
+		mirrorCtx.Logger.InfoLn("Target registry is Harbor, use --emit-harbor-setup to generate a script that configures its project, robot account and retention policy")
+		return nil
+	}
+
+	script, err := harbor.SetupScript(harbor.SetupParams{
+		Host:      mirrorCtx.RegistryHost,
+		Insecure:  mirrorCtx.Insecure,
+		RepoPath:  mirrorCtx.RegistryPath,
+		Flattened: mirrorCtx.FlattenRepositories,
+	})
+	if err != nil {
+		return fmt.Errorf("Generate Harbor setup script: %w", err)
+	}
+	if err = os.WriteFile(HarborSetupScriptPath, script, 0o700); err != nil {
+		return fmt.Errorf("Write Harbor setup script: %w", err)
+	}
+	mirrorCtx.Logger.InfoF("Harbor setup script is written to %s", HarborSetupScriptPath)
+	return nil
+}
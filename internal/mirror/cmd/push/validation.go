@@ -21,18 +21,42 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flagrules"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/notify"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/ratelimit"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/s3"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
 )
 
-func parseAndValidateParameters(_ *cobra.Command, args []string) error {
+// flagRules reject flag combinations that push cannot satisfy. Credentials flags default to environment variables,
+// so their conflicts are checked by value in validateRegistryCredentials instead.
+var flagRules = []flagrules.Rule{
+	flagrules.Requires("flatten-mapping-file", "flatten-repositories").Because("repositories are not flattened"),
+	flagrules.Requires("health-timeout", "health-file", "health-addr").Because("health is not reported"),
+	flagrules.Requires("prune-dry-run", "prune-old-patches"),
+	flagrules.Conflicts("skip-checksums", "checksums-key").Because("checksums are not verified"),
+	flagrules.Requires("decrypt-key-passphrase", "decrypt-key"),
+	flagrules.Conflicts("stream", "decrypt-key").Because("encrypted bundles can only be read from start to end"),
+}
+
+func parseAndValidateParameters(cmd *cobra.Command, args []string) error {
 	if len(args) != 2 {
 		return errors.New("invalid number of arguments, expected 2")
 	}
 
 	var err error
+	if err = flagrules.Validate(cmd.Flags(), flagRules...); err != nil {
+		return err
+	}
 	if err = parseAndValidateRegistryURLArg(args); err != nil {
 		return err
 	}
@@ -42,11 +66,44 @@ func parseAndValidateParameters(_ *cobra.Command, args []string) error {
 	if err = validateImagesBundlePathArg(args); err != nil {
 		return err
 	}
+	if err = validateModulesPathSuffixFlag(); err != nil {
+		return err
+	}
+	if err = parseRateLimitFlag(); err != nil {
+		return err
+	}
+	if PruneOldPatches < 0 {
+		return errors.New("--prune-old-patches cannot be less than zero")
+	}
+	if ChecksumsKeyPath != "" {
+		if checksumsKey, err = signature.LoadVerificationKey(ChecksumsKeyPath, ""); err != nil {
+			return fmt.Errorf("Load checksums verification key: %w", err)
+		}
+	}
+	if len(DecryptKeyPaths) > 0 {
+		if bundleDecryptionKeys, err = bundle.LoadOpenPGPKeys(DecryptKeyPaths, []byte(DecryptKeyPassphrase)); err != nil {
+			return fmt.Errorf("Load bundle decryption keys: %w", err)
+		}
+	}
+	if notifier, err = notify.NewNotifier(NotifyURL, notify.Template(NotifyTemplate)); err != nil {
+		return fmt.Errorf("Invalid --notify-url: %w", err)
+	}
 
 	return nil
 }
 
 func validateImagesBundlePathArg(args []string) error {
+	if s3.IsURL(args[0]) {
+		ImagesBundlePath = args[0]
+		if _, _, err := s3.ParseURL(ImagesBundlePath); err != nil {
+			return err
+		}
+		if path.Ext(ImagesBundlePath) != ".tar" {
+			return errors.New("images-bundle-path argument should be a path to tar archive (.tar) or a directory containing unpacked bundle")
+		}
+		return nil
+	}
+
 	ImagesBundlePath = filepath.Clean(args[0])
 	bundleExtension := filepath.Ext(ImagesBundlePath)
 	stat, err := os.Stat(ImagesBundlePath)
@@ -75,6 +132,18 @@ func validateRegistryCredentials() error {
 	if RegistryPassword != "" && RegistryUsername == "" {
 		return errors.New("registry username not specified")
 	}
+	if RegistryAuthFile == "" {
+		return nil
+	}
+	if RegistryUsername != "" {
+		return errors.New("--target-auth-file cannot be used together with --registry-login")
+	}
+
+	var err error
+	registryFileAuth, err = auth.LoadAuthFile(RegistryAuthFile, RegistryHost)
+	if err != nil {
+		return fmt.Errorf("Invalid --target-auth-file: %w", err)
+	}
 	return nil
 }
 
@@ -99,3 +168,24 @@ func parseAndValidateRegistryURLArg(args []string) error {
 
 	return nil
 }
+
+func validateModulesPathSuffixFlag() error {
+	ModulesPathSuffix = strings.Trim(ModulesPathSuffix, "/")
+	if err := contexts.ValidateModulesPathSuffix(ModulesPathSuffix); err != nil {
+		return fmt.Errorf("Invalid --modules-path-suffix: %w", err)
+	}
+	return nil
+}
+
+func parseRateLimitFlag() error {
+	if rateLimitString == "" {
+		return nil
+	}
+
+	var err error
+	RateLimit, err = ratelimit.ParseRate(rateLimitString)
+	if err != nil {
+		return fmt.Errorf("Invalid --rate-limit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releasemanifests
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+func addFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringSliceVar(
+		&Channels,
+		"channels",
+		ReleaseChannels,
+		"Release channels to generate manifests for, one of "+strings.Join(ReleaseChannels, ", ")+".",
+	)
+	flagSet.StringVar(
+		&OutputDir,
+		"output",
+		".",
+		"Directory to write "+manifestsFileName+" to.",
+	)
+	flagSet.StringVar(
+		&SourceLogin,
+		"source-login",
+		os.Getenv("D8_MIRROR_SOURCE_LOGIN"),
+		"Source registry login.",
+	)
+	flagSet.StringVar(
+		&SourcePassword,
+		"source-password",
+		os.Getenv("D8_MIRROR_SOURCE_PASSWORD"),
+		"Source registry password.",
+	)
+	flagSet.StringVarP(
+		&DeckhouseLicenseToken,
+		"license",
+		"l",
+		os.Getenv("D8_MIRROR_LICENSE_TOKEN"),
+		"Deckhouse license key. Shortcut for --source-login=license-token --source-password=<>.",
+	)
+	flagSet.StringVar(
+		&SourceAuthFile,
+		"source-auth-file",
+		os.Getenv("D8_MIRROR_SOURCE_AUTH_FILE"),
+		"File with source registry credentials, either Docker config.json or a single username:password line. "+
+			"Must be accessible only by its owner. Conflicts with --source-login and --license.",
+	)
+	flagSet.BoolVar(
+		&TLSSkipVerify,
+		"tls-skip-verify",
+		false,
+		"Disable TLS certificate validation.",
+	)
+	flagSet.BoolVar(
+		&Insecure,
+		"insecure",
+		false,
+		"Interact with registries over HTTP.",
+	)
+}
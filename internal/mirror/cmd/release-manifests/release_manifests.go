@@ -0,0 +1,194 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releasemanifests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/manifests"
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
+)
+
+var releaseManifestsLong = templates.LongDesc(`
+Generate DeckhouseRelease manifests for releases that the selected release channels are on.
+
+<source> is either Deckhouse bundle pulled with d8 mirror pull, as tar file, its chunks or unpacked directory,
+or Deckhouse registry repository, like registry.deckhouse.io/deckhouse/ee.
+Manifests are written to deckhousereleases.yaml in --output directory. Unlike the manifests d8 mirror pull writes
+for every pulled release, applying them to air-gapped cluster offers it only the releases of chosen channels.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+var releaseManifestsExample = templates.Examples(`
+# Generate manifests of stable and rock-solid releases stored in the bundle
+d8 mirror release-manifests /opt/d8-bundle/d8.tar --channels stable,rock-solid --output /opt/d8-manifests
+
+# Generate manifest of the current stable release of the source registry
+d8 mirror release-manifests registry.deckhouse.io/deckhouse/ee --license $LICENSE --channels stable --output .
+`)
+
+const manifestsFileName = "deckhousereleases.yaml"
+
+// ReleaseChannels are channels Deckhouse releases are published to, from the least to the most stable one.
+var ReleaseChannels = []string{"alpha", "beta", "early-access", "stable", "rock-solid"}
+
+func NewCommand() *cobra.Command {
+	releaseManifestsCmd := &cobra.Command{
+		Use:           "release-manifests <source>",
+		Short:         "Generate DeckhouseRelease manifests for selected release channels",
+		Long:          releaseManifestsLong,
+		Example:       releaseManifestsExample,
+		ValidArgs:     []string{"source"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          releaseManifests,
+	}
+
+	addFlags(releaseManifestsCmd.Flags())
+	return releaseManifestsCmd
+}
+
+var (
+	Source    string
+	Channels  []string
+	OutputDir string
+
+	SourceLogin           string
+	SourcePassword        string
+	SourceAuthFile        string
+	DeckhouseLicenseToken string
+
+	sourceAuth authn.Authenticator
+
+	Insecure      bool
+	TLSSkipVerify bool
+)
+
+func releaseManifests(cmd *cobra.Command, _ []string) error {
+	logger := output.FromCommand(cmd).Logger()
+	ctx := context.Background()
+
+	findReleaseImage, closeSource, err := openSource(ctx)
+	if err != nil {
+		return fmt.Errorf("Open %s: %w", Source, err)
+	}
+	defer closeSource()
+
+	manifestsPath := filepath.Join(OutputDir, manifestsFileName)
+	var channelVersions map[string]semver.Version
+	err = logger.Process("Generate DeckhouseRelease manifests", func() error {
+		channelVersions, err = manifests.GenerateDeckhouseReleaseManifestsForChannels(Channels, manifestsPath, findReleaseImage)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, channel := range Channels {
+		version := channelVersions[channel]
+		logger.InfoF("Release channel %s is on v%s", channel, version.String())
+	}
+	logger.InfoF("DeckhouseRelease manifests are written to %s", manifestsPath)
+	return nil
+}
+
+// openSource returns finder of release-channel repository images of the bundle or registry given as <source>.
+// Returned func releases resources held by the source.
+func openSource(ctx context.Context) (manifests.ReleaseImageFinder, func(), error) {
+	stat, statErr := os.Stat(Source)
+	switch {
+	case statErr == nil && stat.IsDir():
+		releaseChannelLayout := layout.Path(filepath.Join(Source, "release-channel"))
+		if _, err := os.Stat(filepath.Join(string(releaseChannelLayout), "index.json")); err != nil {
+			return nil, nil, fmt.Errorf("Bundle has no release channels: %w", err)
+		}
+		return func(tag string) (v1.Image, error) {
+			return layouts.FindImageByTag(releaseChannelLayout, tag)
+		}, func() {}, nil
+
+	case statErr == nil || filepath.Ext(Source) == ".tar" || filepath.Ext(Source) == ".chunk":
+		return openPackedBundle(ctx)
+
+	default:
+		return registryReleaseImageFinder(ctx), func() {}, nil
+	}
+}
+
+// openPackedBundle reads release-channel images straight from packed bundle, unpacking only its metadata.
+func openPackedBundle(ctx context.Context) (manifests.ReleaseImageFinder, func(), error) {
+	unpackDir, err := os.MkdirTemp("", "d8-release-manifests-")
+	if err != nil {
+		return nil, nil, err
+	}
+	streamedBundle, err := bundle.OpenStreamed(ctx, &contexts.BaseContext{BundlePath: Source, UnpackedImagesPath: unpackDir})
+	if err != nil {
+		_ = os.RemoveAll(unpackDir)
+		return nil, nil, err
+	}
+	closeBundle := func() {
+		_ = streamedBundle.Close()
+		_ = os.RemoveAll(unpackDir)
+	}
+
+	index, err := streamedBundle.ImageIndex("release-channel")
+	if err != nil {
+		closeBundle()
+		return nil, nil, fmt.Errorf("Bundle has no release channels: %w", err)
+	}
+	return func(tag string) (v1.Image, error) {
+		return layouts.FindImageInIndexByTag(index, tag)
+	}, closeBundle, nil
+}
+
+func registryReleaseImageFinder(ctx context.Context) manifests.ReleaseImageFinder {
+	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptions(sourceAuth, Insecure, TLSSkipVerify)
+	remoteOpts = append(remoteOpts, remote.WithContext(ctx))
+	repo := strings.TrimSuffix(Source, "/") + "/release-channel"
+	return func(tag string) (v1.Image, error) {
+		ref, err := name.ParseReference(repo+":"+tag, nameOpts...)
+		if err != nil {
+			return nil, err
+		}
+		img, err := remote.Image(ref, remoteOpts...)
+		if errorutil.IsImageNotFoundError(err) {
+			return nil, nil
+		}
+		return img, err
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releasemanifests
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flagrules"
+)
+
+var flagRules = []flagrules.Rule{
+	flagrules.Conflicts("source-auth-file", "source-login", "license"),
+	flagrules.Conflicts("license", "source-login"),
+}
+
+func parseAndValidateParameters(cmd *cobra.Command, args []string) error {
+	if err := flagrules.Validate(cmd.Flags(), flagRules...); err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("invalid number of arguments, expected 1")
+	}
+	Source = args[0]
+
+	if len(Channels) == 0 {
+		return errors.New("--channels must list at least one release channel")
+	}
+	for _, channel := range Channels {
+		if !slices.Contains(ReleaseChannels, channel) {
+			return fmt.Errorf("Unknown release channel %q, expected one of %v", channel, ReleaseChannels)
+		}
+	}
+
+	var err error
+	if sourceAuth, err = authProvider(); err != nil {
+		return fmt.Errorf("Invalid source credentials: %w", err)
+	}
+	return nil
+}
+
+// authProvider returns credentials for source registry, falling back to Docker config.
+// They are not used if source is a bundle.
+func authProvider() (authn.Authenticator, error) {
+	if DeckhouseLicenseToken != "" {
+		return authn.FromConfig(authn.AuthConfig{Username: "license-token", Password: DeckhouseLicenseToken}), nil
+	}
+	if SourceLogin == "" && SourcePassword == "" && SourceAuthFile == "" {
+		return auth.DefaultAuthenticator(Source), nil
+	}
+	if SourcePassword != "" && SourceLogin == "" {
+		return nil, errors.New("registry username not specified")
+	}
+	if SourceAuthFile != "" {
+		return auth.LoadAuthFileForRepo(SourceAuthFile, Source)
+	}
+	return authn.FromConfig(authn.AuthConfig{Username: SourceLogin, Password: SourcePassword}), nil
+}
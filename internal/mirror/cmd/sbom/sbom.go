@@ -0,0 +1,124 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sbom
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	libcompare "github.com/deckhouse/deckhouse-cli/pkg/libmirror/compare"
+	libsbom "github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/sbom"
+)
+
+var sbomLong = templates.LongDesc(`
+Export software bills of materials of Deckhouse Kubernetes Platform images to the directory.
+
+<source> is either a registry repository, like registry.example.com/deckhouse/ee, or a path to the tar bundle
+or its chunks, local or s3://. SBOMs are the documents attached to images with cosign under sha256-<digest>.sbom tags,
+bundles only have them if they were pulled with --include-cosign. SPDX and CycloneDX documents are written
+to the directory laid out as the repositories: SBOM of modules/foo:v1.2.0 is written to modules/foo/v1.2.0.spdx.json, for example.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+var sbomExample = templates.Examples(`
+# Export SBOMs of images in the bundle for offline review
+d8 mirror sbom /opt/d8-bundle/d8.tar --output-dir /opt/d8-sbom
+
+# Export SBOMs of images mirrored to the registry
+d8 mirror sbom registry.example.com/deckhouse/ee --output-dir /opt/d8-sbom --source-login admin --source-password secret
+`)
+
+func NewCommand() *cobra.Command {
+	sbomCmd := &cobra.Command{
+		Use:           "sbom <source>",
+		Short:         "Export SBOMs of Deckhouse Kubernetes Platform images from registry or bundle",
+		Long:          sbomLong,
+		Example:       sbomExample,
+		ValidArgs:     []string{"source"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          exportSBOMs,
+	}
+
+	addFlags(sbomCmd.Flags())
+	return sbomCmd
+}
+
+var (
+	Source   string
+	isBundle bool
+
+	SourceLogin           string
+	SourcePassword        string
+	SourceAuthFile        string
+	DeckhouseLicenseToken string
+	sourceAuth            authn.Authenticator
+
+	Insecure          bool
+	TLSSkipVerify     bool
+	ModulesPathSuffix string
+
+	OutputDir string
+)
+
+func exportSBOMs(cmd *cobra.Command, _ []string) error {
+	logger := output.FromCommand(cmd).Logger()
+
+	var docs []libsbom.Document
+	err := logger.Process(fmt.Sprintf("Read SBOMs of images in %s", Source), func() error {
+		var err error
+		if isBundle {
+			docs, err = bundle.ReadSBOMs(context.Background(), Source)
+			return err
+		}
+		docs, err = libcompare.ReadSBOMs(context.Background(), Source, libcompare.RegistryOptions{
+			Auth:              sourceAuth,
+			Insecure:          Insecure,
+			SkipTLSVerify:     TLSSkipVerify,
+			ModulesPathSuffix: ModulesPathSuffix,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Read SBOMs of images in %s: %w", Source, err)
+	}
+	if len(docs) == 0 {
+		logger.WarnF("No SPDX or CycloneDX SBOMs found in %s", Source)
+		return nil
+	}
+
+	written, err := libsbom.WriteFiles(OutputDir, docs)
+	if err != nil {
+		return fmt.Errorf("Write SBOMs: %w", err)
+	}
+	for _, file := range written {
+		logger.DebugF("Wrote %s", file)
+	}
+	logger.InfoF("Exported %d SBOMs to %s", len(written), OutputDir)
+	return nil
+}
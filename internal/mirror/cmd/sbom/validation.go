@@ -0,0 +1,77 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sbom
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flagrules"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/s3"
+)
+
+var flagRules = []flagrules.Rule{
+	flagrules.Conflicts("source-auth-file", "source-login", "license"),
+	flagrules.Conflicts("license", "source-login"),
+}
+
+func parseAndValidateParameters(cmd *cobra.Command, args []string) error {
+	if err := flagrules.Validate(cmd.Flags(), flagRules...); err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("invalid number of arguments, expected 1")
+	}
+	Source = strings.TrimSuffix(args[0], "/")
+	if OutputDir == "" {
+		return errors.New("Directory to write SBOMs to must be provided with --output-dir")
+	}
+
+	// Registry repositories cannot be told apart from relative paths, so anything that is not an existing bundle is a registry
+	_, err := bundle.FindBundleFiles(Source)
+	isBundle = s3.IsURL(Source) || strings.HasSuffix(Source, ".tar") || err == nil
+	if isBundle {
+		return nil
+	}
+
+	if sourceAuth, err = sourceAuthProvider(); err != nil {
+		return fmt.Errorf("Invalid source credentials: %w", err)
+	}
+	return nil
+}
+
+// sourceAuthProvider returns credentials given by flags, or ones of Docker config if none are given.
+func sourceAuthProvider() (authn.Authenticator, error) {
+	switch {
+	case DeckhouseLicenseToken != "":
+		return authn.FromConfig(authn.AuthConfig{Username: "license-token", Password: DeckhouseLicenseToken}), nil
+	case SourceAuthFile != "":
+		return auth.LoadAuthFileForRepo(SourceAuthFile, Source)
+	case SourcePassword != "" && SourceLogin == "":
+		return nil, errors.New("registry username not specified")
+	case SourceLogin != "" || SourcePassword != "":
+		return authn.FromConfig(authn.AuthConfig{Username: SourceLogin, Password: SourcePassword}), nil
+	default:
+		return auth.DefaultAuthenticator(Source), nil
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mirror
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/httppool"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/retry"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/throttle"
+)
+
+// parseRetryFlags enables retries of registry requests for the whole run of the command.
+// Retry policy does not cover throttled requests, they are repeated by throttler enabled here as well.
+// Commands that summarize throttling, like push, enable their own throttler instead.
+func parseRetryFlags() error {
+	if RetryBackoff < 0 {
+		return errors.New("--retry-backoff cannot be negative")
+	}
+	retry.Enable(retry.Policy{Retries: RetryCount, Backoff: RetryBackoff})
+	throttle.Enable(throttle.NewThrottler())
+	return nil
+}
+
+// parseProxyFlags makes registry requests go through proxies given by flags, falling back to environment variables
+// for the ones that are not given.
+func parseProxyFlags() error {
+	if HTTPProxy == "" && HTTPSProxy == "" && NoProxy == "" {
+		return nil
+	}
+
+	config := httpproxy.FromEnvironment()
+	for flagName, proxy := range map[string]*string{"--http-proxy": &HTTPProxy, "--https-proxy": &HTTPSProxy} {
+		if *proxy == "" {
+			continue
+		}
+		proxyURL := *proxy
+		if !strings.Contains(proxyURL, "://") {
+			proxyURL = "http://" + proxyURL
+		}
+		if parsed, err := url.Parse(proxyURL); err != nil || parsed.Host == "" {
+			return fmt.Errorf("Invalid %s %q, expected proxy URL like http://proxy.example.com:3128", flagName, *proxy)
+		}
+	}
+	if HTTPProxy != "" {
+		config.HTTPProxy = HTTPProxy
+	}
+	if HTTPSProxy != "" {
+		config.HTTPSProxy = HTTPSProxy
+	}
+	if NoProxy != "" {
+		config.NoProxy = NoProxy
+	}
+
+	proxyFunc := config.ProxyFunc()
+	httppool.SetProxy(func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	})
+	return nil
+}
+
+func parseRegistryCAFlag() error {
+	if len(RegistryCAs) == 0 {
+		return nil
+	}
+
+	rootCAs := map[string]*x509.CertPool{}
+	for _, hostCA := range RegistryCAs {
+		host, caPath, found := strings.Cut(hostCA, "=")
+		if !found || host == "" || caPath == "" || strings.Contains(host, "/") {
+			return fmt.Errorf("Invalid --registry-ca %q, expected <host>=<path to PEM bundle>", hostCA)
+		}
+
+		pool, found := rootCAs[host]
+		if !found {
+			var err error
+			if pool, err = x509.SystemCertPool(); err != nil {
+				pool = x509.NewCertPool()
+			}
+		}
+		rawPEM, err := os.ReadFile(caPath)
+		if err != nil {
+			return fmt.Errorf("Read CA bundle of %s: %w", host, err)
+		}
+		if !pool.AppendCertsFromPEM(rawPEM) {
+			return fmt.Errorf("CA bundle %s of %s has no PEM certificates", caPath, host)
+		}
+		rootCAs[host] = pool
+	}
+
+	httppool.SetHostRootCAs(rootCAs)
+	return nil
+}
+
+func parseRegistryClientCertFlags() error {
+	if len(RegistryClientCerts) == 0 && len(RegistryClientKeys) == 0 {
+		return nil
+	}
+
+	parse := func(flagName string, values []string) (map[string]string, error) {
+		paths := make(map[string]string, len(values))
+		for _, value := range values {
+			host, filePath, found := strings.Cut(value, "=")
+			if !found || host == "" || filePath == "" || strings.Contains(host, "/") {
+				return nil, fmt.Errorf("Invalid %s %q, expected <host>=<path to PEM file>", flagName, value)
+			}
+			if _, duplicate := paths[host]; duplicate {
+				return nil, fmt.Errorf("%s is given more than once for %s", flagName, host)
+			}
+			paths[host] = filePath
+		}
+		return paths, nil
+	}
+	certPaths, err := parse("--registry-client-cert", RegistryClientCerts)
+	if err != nil {
+		return err
+	}
+	keyPaths, err := parse("--registry-client-key", RegistryClientKeys)
+	if err != nil {
+		return err
+	}
+
+	certificates := make(map[string]tls.Certificate, len(certPaths))
+	for host, certPath := range certPaths {
+		keyPath, found := keyPaths[host]
+		if !found {
+			return fmt.Errorf("--registry-client-cert of %s is given without --registry-client-key", host)
+		}
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return fmt.Errorf("Load client certificate of %s: %w", host, err)
+		}
+		certificates[host] = cert
+	}
+	for host := range keyPaths {
+		if _, found := certPaths[host]; !found {
+			return fmt.Errorf("--registry-client-key of %s is given without --registry-client-cert", host)
+		}
+	}
+
+	httppool.SetHostClientCertificates(certificates)
+	return nil
+}
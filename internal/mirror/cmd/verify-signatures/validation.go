@@ -0,0 +1,81 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verifysignatures
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flagrules"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flatten"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+)
+
+var flagRules = []flagrules.Rule{
+	flagrules.Conflicts("registry-auth-file", "registry-login"),
+}
+
+func parseAndValidateParameters(cmd *cobra.Command, args []string) error {
+	if err := flagrules.Validate(cmd.Flags(), flagRules...); err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("invalid number of arguments, expected 1")
+	}
+	Registry = strings.TrimSuffix(args[0], "/")
+
+	if OutputFormat != outputText && OutputFormat != outputJSON {
+		return fmt.Errorf("Unknown --output %q, expected %q or %q", OutputFormat, outputText, outputJSON)
+	}
+
+	var err error
+	if KeyPath == "" {
+		return errors.New("Path to the public key must be provided with --key")
+	}
+	if key, err = signature.LoadVerificationKey(KeyPath, ""); err != nil {
+		return fmt.Errorf("Invalid --key: %w", err)
+	}
+
+	if registryAuth, err = registryAuthProvider(); err != nil {
+		return fmt.Errorf("Invalid registry credentials: %w", err)
+	}
+	if FlattenMappingPath != "" {
+		if mapping, err = flatten.LoadMapping(FlattenMappingPath); err != nil {
+			return fmt.Errorf("Invalid --flatten-mapping-file: %w", err)
+		}
+	}
+	return nil
+}
+
+// registryAuthProvider returns credentials given by flags, or ones of Docker config if none are given.
+func registryAuthProvider() (authn.Authenticator, error) {
+	switch {
+	case RegistryAuthFile != "":
+		return auth.LoadAuthFileForRepo(RegistryAuthFile, Registry)
+	case RegistryPassword != "" && RegistryLogin == "":
+		return nil, errors.New("registry username not specified")
+	case RegistryLogin != "" || RegistryPassword != "":
+		return authn.FromConfig(authn.AuthConfig{Username: RegistryLogin, Password: RegistryPassword}), nil
+	default:
+		return auth.DefaultAuthenticator(Registry), nil
+	}
+}
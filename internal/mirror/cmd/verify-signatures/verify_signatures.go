@@ -0,0 +1,152 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verifysignatures
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	libcompare "github.com/deckhouse/deckhouse-cli/pkg/libmirror/compare"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flatten"
+)
+
+var verifySignaturesLong = templates.LongDesc(`
+Verify cosign signatures of Deckhouse Kubernetes Platform images pushed to the registry.
+
+Every image of Deckhouse, installer, release channel, security databases and modules repositories
+under <registry> must have cosign signature made with the key provided by --key.
+Signatures are pulled and pushed along with images by d8 mirror pull and push with --include-cosign.
+
+Command exits with non-zero code if any of the images is not signed or none of its signatures is valid.
+
+LICENSE NOTE:
+The d8 mirror functionality is exclusively available to users holding a 
+valid license for any commercial version of the Deckhouse Kubernetes Platform.
+
+© Flant JSC 2024`)
+
+var verifySignaturesExample = templates.Examples(`
+# Verify signatures of images pushed to the registry with the Deckhouse public key
+d8 mirror verify-signatures registry.example.com/deckhouse/ee --key deckhouse.pub --registry-login admin --registry-password secret
+`)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// ErrUnverified is returned when some images are unsigned or have invalid signatures.
+var ErrUnverified = errors.New("Signatures of some images cannot be verified")
+
+func NewCommand() *cobra.Command {
+	verifySignaturesCmd := &cobra.Command{
+		Use:           "verify-signatures <registry>",
+		Short:         "Verify cosign signatures of Deckhouse Kubernetes Platform images pushed to the registry",
+		Long:          verifySignaturesLong,
+		Example:       verifySignaturesExample,
+		ValidArgs:     []string{"registry"},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE:       parseAndValidateParameters,
+		RunE:          verifySignatures,
+	}
+
+	addFlags(verifySignaturesCmd.Flags())
+	return verifySignaturesCmd
+}
+
+var (
+	Registry string
+
+	RegistryLogin    string
+	RegistryPassword string
+	RegistryAuthFile string
+	registryAuth     authn.Authenticator
+
+	KeyPath string
+	key     crypto.PublicKey
+
+	Insecure      bool
+	TLSSkipVerify bool
+
+	FlattenMappingPath string
+	mapping            *flatten.Mapping
+	ModulesPathSuffix  string
+
+	OutputFormat string
+)
+
+func verifySignatures(cmd *cobra.Command, _ []string) error {
+	out := output.FromCommand(cmd)
+	logger := out.Logger()
+
+	var report *libcompare.SignatureReport
+	err := logger.Process(fmt.Sprintf("Verify signatures of images in %s", Registry), func() error {
+		var err error
+		report, err = libcompare.VerifySignatures(context.Background(), Registry, key, libcompare.RegistryOptions{
+			Auth:              registryAuth,
+			Insecure:          Insecure,
+			SkipTLSVerify:     TLSSkipVerify,
+			Mapping:           mapping,
+			ModulesPathSuffix: ModulesPathSuffix,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Verify signatures of images in %s: %w", Registry, err)
+	}
+
+	if OutputFormat == outputJSON {
+		encoder := json.NewEncoder(out.Data())
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(report); err != nil {
+			return fmt.Errorf("Write report: %w", err)
+		}
+	} else {
+		printReport(out.Data(), report)
+	}
+
+	if !report.OK() {
+		return ErrUnverified
+	}
+	return nil
+}
+
+func printReport(w io.Writer, report *libcompare.SignatureReport) {
+	printList := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s:\n", title)
+		for _, item := range items {
+			fmt.Fprintf(w, "  %s\n", item)
+		}
+	}
+
+	printList("Unsigned images", report.UnsignedImages)
+	printList("Images with invalid signatures", report.InvalidSignatures)
+	fmt.Fprintln(w, report.Summary())
+}
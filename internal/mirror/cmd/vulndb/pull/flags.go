@@ -41,6 +41,13 @@ func addFlags(flagSet *pflag.FlagSet) {
 		os.Getenv("D8_MIRROR_SOURCE_PASSWORD"),
 		"Source registry password.",
 	)
+	flagSet.StringVar(
+		&SourceRegistryAuthFile,
+		"source-insecure-basic-auth-file",
+		os.Getenv("D8_MIRROR_SOURCE_AUTH_FILE"),
+		"File with source registry credentials, either Docker config.json or a single username:password line. "+
+			"Must be accessible only by its owner. Conflicts with --source-login and --license.",
+	)
 	flagSet.StringVarP(
 		&LicenseToken,
 		"license",
@@ -18,16 +18,16 @@ package pull
 
 import (
 	"fmt"
-	"log/slog"
 	"path/filepath"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/spf13/cobra"
 	"k8s.io/kubectl/pkg/util/templates"
 
+	"github.com/deckhouse/deckhouse-cli/internal/output"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
-	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
 )
 
 const (
@@ -66,6 +66,7 @@ var (
 	SourceRegistryRepo     string
 	SourceRegistryLogin    string
 	SourceRegistryPassword string
+	SourceRegistryAuthFile string
 
 	VulnerabilityDBPath string
 	LicenseToken        string
@@ -74,12 +75,8 @@ var (
 	Insecure      bool
 )
 
-func pull(_ *cobra.Command, _ []string) error {
-	logLevel := slog.LevelInfo
-	if log.DebugLogLevel() >= 3 {
-		logLevel = slog.LevelDebug
-	}
-	logger := log.NewSLogger(logLevel)
+func pull(cmd *cobra.Command, _ []string) error {
+	logger := output.FromCommand(cmd).Logger()
 
 	pullContext := &contexts.PullContext{
 		BaseContext: contexts.BaseContext{
@@ -118,7 +115,14 @@ func pull(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// sourceRegistryFileAuth is loaded from --source-insecure-basic-auth-file during flags validation.
+var sourceRegistryFileAuth authn.Authenticator
+
 func getSourceRegistryAuthProvider() authn.Authenticator {
+	if sourceRegistryFileAuth != nil {
+		return sourceRegistryFileAuth
+	}
+
 	if SourceRegistryLogin != "" {
 		return authn.FromConfig(authn.AuthConfig{
 			Username: SourceRegistryLogin,
@@ -133,5 +137,5 @@ func getSourceRegistryAuthProvider() authn.Authenticator {
 		})
 	}
 
-	return authn.Anonymous
+	return auth.DefaultAuthenticator(SourceRegistryRepo)
 }
@@ -24,6 +24,8 @@ import (
 	"path/filepath"
 
 	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
 )
 
 func parseAndValidateParameters(_ *cobra.Command, args []string) error {
@@ -36,6 +38,26 @@ func parseAndValidateParameters(_ *cobra.Command, args []string) error {
 		return err
 	}
 
+	if err = parseAndValidateSourceAuthFileFlag(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func parseAndValidateSourceAuthFileFlag() error {
+	if SourceRegistryAuthFile == "" {
+		return nil
+	}
+	if SourceRegistryLogin != "" || LicenseToken != "" {
+		return errors.New("--source-insecure-basic-auth-file cannot be used together with --source-login or --license")
+	}
+
+	var err error
+	sourceRegistryFileAuth, err = auth.LoadAuthFileForRepo(SourceRegistryAuthFile, SourceRegistryRepo)
+	if err != nil {
+		return fmt.Errorf("Invalid --source-insecure-basic-auth-file: %w", err)
+	}
 	return nil
 }
 
@@ -18,7 +18,6 @@ package push
 
 import (
 	"fmt"
-	"log/slog"
 	"path"
 	"path/filepath"
 
@@ -27,9 +26,10 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/kubectl/pkg/util/templates"
 
+	"github.com/deckhouse/deckhouse-cli/internal/output"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
-	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
 )
 
 var pushLong = templates.LongDesc(`
@@ -63,6 +63,7 @@ var (
 	RegistryPath     string
 	RegistryLogin    string
 	RegistryPassword string
+	RegistryAuthFile string
 
 	VulnerabilityDBPath string
 
@@ -70,12 +71,8 @@ var (
 	Insecure      bool
 )
 
-func push(_ *cobra.Command, _ []string) error {
-	logLevel := slog.LevelInfo
-	if log.DebugLogLevel() >= 3 {
-		logLevel = slog.LevelDebug
-	}
-	logger := log.NewSLogger(logLevel)
+func push(cmd *cobra.Command, _ []string) error {
+	logger := output.FromCommand(cmd).Logger()
 
 	pushContext := &contexts.PushContext{
 		BaseContext: contexts.BaseContext{
@@ -125,7 +122,14 @@ func push(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// registryFileAuth is loaded from --target-auth-file during flags validation.
+var registryFileAuth authn.Authenticator
+
 func getRegistryAuthProvider() authn.Authenticator {
+	if registryFileAuth != nil {
+		return registryFileAuth
+	}
+
 	if RegistryLogin != "" {
 		return authn.FromConfig(authn.AuthConfig{
 			Username: RegistryLogin,
@@ -133,5 +137,5 @@ func getRegistryAuthProvider() authn.Authenticator {
 		})
 	}
 
-	return authn.Anonymous
+	return auth.DefaultAuthenticator(RegistryRepo)
 }
@@ -25,6 +25,8 @@ import (
 	"path/filepath"
 
 	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
 )
 
 func parseAndValidateParameters(_ *cobra.Command, args []string) error {
@@ -44,6 +46,9 @@ func parseAndValidateParameters(_ *cobra.Command, args []string) error {
 	if err = parseAndValidateRegistryURLArg(args); err != nil {
 		return err
 	}
+	if err = parseAndValidateTargetAuthFileFlag(); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -69,6 +74,22 @@ func validateRegistryCredentials() error {
 	return nil
 }
 
+func parseAndValidateTargetAuthFileFlag() error {
+	if RegistryAuthFile == "" {
+		return nil
+	}
+	if RegistryLogin != "" {
+		return errors.New("--target-auth-file cannot be used together with --registry-login")
+	}
+
+	var err error
+	registryFileAuth, err = auth.LoadAuthFile(RegistryAuthFile, RegistryHost)
+	if err != nil {
+		return fmt.Errorf("Invalid --target-auth-file: %w", err)
+	}
+	return nil
+}
+
 func parseAndValidateRegistryURLArg(args []string) error {
 	registryUrl, err := url.Parse("docker://" + args[1])
 	if err != nil {
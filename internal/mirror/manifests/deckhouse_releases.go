@@ -18,10 +18,13 @@ package manifests
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"github.com/Masterminds/semver/v3"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -38,14 +41,69 @@ func GenerateDeckhouseReleaseManifestsForVersions(
 	versionsToMirror []semver.Version,
 	pathToManifestYAML string,
 	releaseChannelsImagesLayout layout.Path,
+) error {
+	return writeDeckhouseReleaseManifests(versionsToMirror, pathToManifestYAML, func(tag string) (v1.Image, error) {
+		return layouts.FindImageByTag(releaseChannelsImagesLayout, tag)
+	})
+}
+
+// ReleaseImageFinder returns image of release-channel repository by its tag, which is either the name of release channel
+// or release version. Nil image is returned if there is no image with the tag.
+type ReleaseImageFinder func(tag string) (v1.Image, error)
+
+// GenerateDeckhouseReleaseManifestsForChannels writes DeckhouseRelease manifests of releases that release channels are on.
+// Channels on the same release share its manifest. Versions of channels are returned.
+func GenerateDeckhouseReleaseManifestsForChannels(
+	channels []string,
+	pathToManifestYAML string,
+	findReleaseImage ReleaseImageFinder,
+) (map[string]semver.Version, error) {
+	channelVersions := make(map[string]semver.Version, len(channels))
+	versions := make([]semver.Version, 0, len(channels))
+	for _, channel := range channels {
+		channelImage, err := findReleaseImage(channel)
+		if err != nil {
+			return nil, fmt.Errorf("Find %s release channel image: %w", channel, err)
+		}
+		if channelImage == nil {
+			return nil, fmt.Errorf("Release channel %s not found", channel)
+		}
+		version, err := extractReleaseChannelVersion(channelImage)
+		if err != nil {
+			return nil, fmt.Errorf("Read %s release channel version: %w", channel, err)
+		}
+
+		channelVersions[channel] = *version
+		if !slices.ContainsFunc(versions, func(v semver.Version) bool { return v.Equal(version) }) {
+			versions = append(versions, *version)
+		}
+	}
+	slices.SortFunc(versions, func(a, b semver.Version) int { return a.Compare(&b) })
+
+	if err := writeDeckhouseReleaseManifests(versions, pathToManifestYAML, findReleaseImage); err != nil {
+		return nil, err
+	}
+	return channelVersions, nil
+}
+
+func writeDeckhouseReleaseManifests(
+	versions []semver.Version,
+	pathToManifestYAML string,
+	findReleaseImage ReleaseImageFinder,
 ) error {
 	// It feels like most of the time manifests yaml length would not exceed the size of 4 KiB buffer,
 	// so let's preallocate that ahead of time to avoid reallocs.
 	// I have no scientific reasoning to back this up.
 	manifests := &bytes.Buffer{}
 	manifests.Grow(4 * 1024)
-	for _, version := range versionsToMirror {
-		versionReleaseImage, err := layouts.FindImageByTag(releaseChannelsImagesLayout, "v"+version.String())
+	for _, version := range versions {
+		versionReleaseImage, err := findReleaseImage("v" + version.String())
+		if err == nil && versionReleaseImage == nil {
+			err = errors.New("release image not found")
+		}
+		if err != nil {
+			return fmt.Errorf("Build manifest for version %q: %w", version, err)
+		}
 		releaseData, err := extractReleaseInfoForDeckhouseRelease(versionReleaseImage)
 		if err != nil {
 			return fmt.Errorf("Build manifest for version %q: %w", version, err)
@@ -142,3 +200,18 @@ func extractReleaseInfoForDeckhouseRelease(versionReleaseImage v1.Image) (*relea
 
 	return release, nil
 }
+
+// extractReleaseChannelVersion reads version release channel image points to.
+func extractReleaseChannelVersion(channelImage v1.Image) (*semver.Version, error) {
+	rawVersion, err := images.ExtractFileFromImage(channelImage, "version.json")
+	if err != nil {
+		return nil, fmt.Errorf("Extract version from release channel image: %w", err)
+	}
+	channel := &struct {
+		Version string `json:"version"`
+	}{}
+	if err = json.Unmarshal(rawVersion.Bytes(), channel); err != nil {
+		return nil, fmt.Errorf("Extract version from release channel image: %w", err)
+	}
+	return semver.NewVersion(channel.Version)
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/Masterminds/semver/v3"
@@ -222,6 +223,50 @@ status:
 	}
 }
 
+func TestGenerateDeckhouseReleaseManifestsForChannels(t *testing.T) {
+	testDir := t.TempDir()
+	releaseChannelsLayout, err := layouts.CreateEmptyImageLayoutAtPath(filepath.Join(testDir, "layout"))
+	require.NoError(t, err)
+	tags := map[string]string{
+		"v1.56.12":     "1.56.12",
+		"v1.57.5":      "1.57.5",
+		"v1.58.1":      "1.58.1",
+		"rock-solid":   "1.56.12",
+		"stable":       "1.57.5",
+		"early-access": "1.57.5",
+		"alpha":        "1.58.1",
+	}
+	for tag, version := range tags {
+		require.NoError(t, releaseChannelsLayout.AppendImage(
+			createDeckhouseReleaseChannelImage(t, version),
+			layout.WithAnnotations(map[string]string{"org.opencontainers.image.ref.name": "release-channel:" + tag}),
+		))
+	}
+	findReleaseImage := func(tag string) (v1.Image, error) {
+		return layouts.FindImageByTag(releaseChannelsLayout, tag)
+	}
+
+	pathToManifestFile := filepath.Join(testDir, "deckhousereleases.yaml")
+	channelVersions, err := GenerateDeckhouseReleaseManifestsForChannels(
+		[]string{"stable", "rock-solid", "early-access"}, pathToManifestFile, findReleaseImage,
+	)
+	require.NoError(t, err)
+	require.Equal(t, map[string]semver.Version{
+		"stable":       *semver.MustParse("v1.57.5"),
+		"rock-solid":   *semver.MustParse("v1.56.12"),
+		"early-access": *semver.MustParse("v1.57.5"),
+	}, channelVersions)
+
+	fileContents, err := os.ReadFile(pathToManifestFile)
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(string(fileContents), "kind: DeckhouseRelease"), "channels on the same release must share its manifest")
+	require.Less(t, strings.Index(string(fileContents), "name: v1.56.12"), strings.Index(string(fileContents), "name: v1.57.5"))
+	require.NotContains(t, string(fileContents), "v1.58.1")
+
+	_, err = GenerateDeckhouseReleaseManifestsForChannels([]string{"beta"}, pathToManifestFile, findReleaseImage)
+	require.ErrorContains(t, err, "Release channel beta not found")
+}
+
 func createDeckhouseReleaseChannelImage(t *testing.T, version string) v1.Image {
 	t.Helper()
 
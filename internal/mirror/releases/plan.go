@@ -0,0 +1,145 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releases
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+// VersionsPlan describes how the set of Deckhouse releases to mirror was resolved.
+// It is written as JSON with --explain-versions to debug why a particular release was or was not pulled.
+type VersionsPlan struct {
+	reportschema.Header
+
+	Channels      []ChannelVersion  `json:"channels"`
+	MinVersion    string            `json:"minVersion"`
+	MinVersionBy  string            `json:"minVersionSource"`
+	MaxVersion    string            `json:"maxVersion"`
+	Candidates    []string          `json:"candidates"`
+	LatestPatches []string          `json:"latestPatches"`
+	Excluded      []ExcludedVersion `json:"excluded"`
+	Versions      []string          `json:"versions"`
+
+	versions []semver.Version
+}
+
+type ChannelVersion struct {
+	Channel string `json:"channel"`
+	Version string `json:"version"`
+
+	version *semver.Version
+}
+
+type ExcludedVersion struct {
+	Tag    string `json:"tag"`
+	Reason string `json:"reason"`
+}
+
+// SelectedVersions returns the final set of releases to mirror.
+func (p *VersionsPlan) SelectedVersions() []semver.Version {
+	return p.versions
+}
+
+func (p *VersionsPlan) Marshal() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// buildVersionsPlan selects releases between minVersion and alpha channel version from registry tags,
+// keeping only latest patch of every minor release, plus the versions of all channels.
+func buildVersionsPlan(channels []ChannelVersion, minVersion *semver.Version, minVersionSource string, tags []string) *VersionsPlan {
+	plan := &VersionsPlan{
+		Header:        reportschema.NewHeader(reportschema.KindVersionsPlan),
+		Channels:      channels,
+		MinVersion:    "v" + minVersion.String(),
+		MinVersionBy:  minVersionSource,
+		Candidates:    make([]string, 0),
+		LatestPatches: make([]string, 0),
+		Excluded:      make([]ExcludedVersion, 0),
+	}
+
+	alphaChannelVersion := channels[0].version
+	for _, channel := range channels {
+		if channel.Channel == "alpha" {
+			alphaChannelVersion = channel.version
+			break
+		}
+	}
+	plan.MaxVersion = "v" + alphaChannelVersion.String()
+
+	candidates := make([]*semver.Version, 0)
+	for _, tag := range tags {
+		version, err := semver.NewVersion(tag)
+		switch {
+		case err != nil:
+			plan.Excluded = append(plan.Excluded, ExcludedVersion{Tag: tag, Reason: "not a release version"})
+		case minVersion.GreaterThan(version):
+			plan.Excluded = append(plan.Excluded, ExcludedVersion{Tag: tag, Reason: "below minimal version " + plan.MinVersion})
+		case version.GreaterThan(alphaChannelVersion):
+			plan.Excluded = append(plan.Excluded, ExcludedVersion{Tag: tag, Reason: "above alpha channel version " + plan.MaxVersion})
+		default:
+			candidates = append(candidates, version)
+			plan.Candidates = append(plan.Candidates, tag)
+		}
+	}
+
+	latestPatches := filterOnlyLatestPatches(candidates)
+	latestPatchOf := make(map[[2]uint64]*semver.Version, len(latestPatches))
+	for _, version := range latestPatches {
+		latestPatchOf[[2]uint64{version.Major(), version.Minor()}] = version
+		plan.LatestPatches = append(plan.LatestPatches, "v"+version.String())
+	}
+	for _, version := range candidates {
+		latest := latestPatchOf[[2]uint64{version.Major(), version.Minor()}]
+		if version.Patch() < latest.Patch() {
+			plan.Excluded = append(plan.Excluded, ExcludedVersion{
+				Tag:    version.Original(),
+				Reason: "superseded by newer patch v" + latest.String(),
+			})
+		}
+	}
+	sortVersionStrings(plan.LatestPatches)
+
+	channelVersions := make([]*semver.Version, 0, len(channels))
+	for _, channel := range channels {
+		channelVersions = append(channelVersions, channel.version)
+	}
+	plan.versions = deduplicateVersions(append(channelVersions, latestPatches...))
+	sort.Slice(plan.versions, func(i, j int) bool {
+		return plan.versions[i].LessThan(&plan.versions[j])
+	})
+	plan.Versions = make([]string, 0, len(plan.versions))
+	for _, version := range plan.versions {
+		plan.Versions = append(plan.Versions, "v"+version.String())
+	}
+
+	return plan
+}
+
+func sortVersionStrings(versions []string) {
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.MustParse(versions[i]).LessThan(semver.MustParse(versions[j]))
+	})
+}
+
+func newChannelVersion(channel string, version *semver.Version) ChannelVersion {
+	return ChannelVersion{Channel: channel, Version: "v" + version.String(), version: version}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releases
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildVersionsPlan(t *testing.T) {
+	channels := []ChannelVersion{
+		newChannelVersion("alpha", semver.MustParse("v1.64.1")),
+		newChannelVersion("stable", semver.MustParse("v1.62.3")),
+		newChannelVersion("rock-solid", semver.MustParse("v1.61.5")),
+	}
+	tags := []string{"alpha", "v1.60.9", "v1.61.2", "v1.61.5", "v1.62.1", "v1.62.3", "v1.63.0", "v1.64.1", "v1.65.0"}
+
+	plan := buildVersionsPlan(channels, semver.MustParse("v1.61.0"), "--min-version", tags)
+
+	require.Equal(t, "v1.61.0", plan.MinVersion)
+	require.Equal(t, "v1.64.1", plan.MaxVersion)
+	require.Equal(t, []string{"v1.61.5", "v1.62.3", "v1.63.0", "v1.64.1"}, plan.LatestPatches)
+	require.Equal(t, []string{"v1.61.5", "v1.62.3", "v1.63.0", "v1.64.1"}, plan.Versions)
+	require.Len(t, plan.SelectedVersions(), 4)
+	require.ElementsMatch(t, []ExcludedVersion{
+		{Tag: "alpha", Reason: "not a release version"},
+		{Tag: "v1.60.9", Reason: "below minimal version v1.61.0"},
+		{Tag: "v1.65.0", Reason: "above alpha channel version v1.64.1"},
+		{Tag: "v1.61.2", Reason: "superseded by newer patch v1.61.5"},
+		{Tag: "v1.62.1", Reason: "superseded by newer patch v1.62.3"},
+	}, plan.Excluded)
+}
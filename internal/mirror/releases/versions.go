@@ -24,6 +24,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/samber/lo"
 	"golang.org/x/exp/maps"
 
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
@@ -33,22 +34,48 @@ import (
 )
 
 func VersionsToMirror(mirrorCtx *contexts.PullContext) ([]semver.Version, error) {
+	plan, err := PlanVersionsToMirror(mirrorCtx)
+	if err != nil {
+		return nil, err
+	}
+	return plan.SelectedVersions(), nil
+}
+
+// PlanVersionsToMirror resolves versions of release channels and selects Deckhouse releases to mirror,
+// recording every decision made along the way.
+func PlanVersionsToMirror(mirrorCtx *contexts.PullContext) (*VersionsPlan, error) {
 	releaseChannelsToCopy := []string{"alpha", "beta", "early-access", "stable", "rock-solid"}
 	releaseChannelsVersions := make([]*semver.Version, len(releaseChannelsToCopy))
+	channels := make([]ChannelVersion, 0, len(releaseChannelsToCopy)+1)
 	for i, channel := range releaseChannelsToCopy {
 		v, err := getReleaseChannelVersionFromRegistry(mirrorCtx, channel)
 		if err != nil {
 			return nil, fmt.Errorf("get %s release version from registry: %w", channel, err)
 		}
 		releaseChannelsVersions[i] = v
+		channels = append(channels, newChannelVersion(channel, v))
 	}
 
 	rockSolidVersion := releaseChannelsVersions[len(releaseChannelsToCopy)-1]
 	mirrorFromVersion := *rockSolidVersion
-	if mirrorCtx.MinVersion != nil {
+	mirrorFromSource := "rock-solid channel"
+	switch {
+	case mirrorCtx.MinVersion != nil:
 		mirrorFromVersion = *mirrorCtx.MinVersion
+		mirrorFromSource = "--min-version"
 		if rockSolidVersion.LessThan(mirrorCtx.MinVersion) {
 			mirrorFromVersion = *rockSolidVersion
+			mirrorFromSource = "rock-solid channel, as --min-version is above it"
+		}
+	case mirrorCtx.SinceChannel != "":
+		channelVersion, err := sinceChannelVersion(mirrorCtx, releaseChannelsToCopy, releaseChannelsVersions)
+		if err != nil {
+			return nil, err
+		}
+		mirrorFromVersion = *channelVersion
+		mirrorFromSource = "--since-channel " + mirrorCtx.SinceChannel
+		if !lo.Contains(releaseChannelsToCopy, mirrorCtx.SinceChannel) {
+			channels = append(channels, newChannelVersion(mirrorCtx.SinceChannel, channelVersion))
 		}
 	}
 
@@ -57,17 +84,30 @@ func VersionsToMirror(mirrorCtx *contexts.PullContext) ([]semver.Version, error)
 		return nil, fmt.Errorf("get releases from github: %w", err)
 	}
 
-	alphaChannelVersion := releaseChannelsVersions[0]
-	for i := range releaseChannelsToCopy {
-		if releaseChannelsToCopy[i] == "alpha" {
-			alphaChannelVersion = releaseChannelsVersions[i]
-			break
+	return buildVersionsPlan(channels, &mirrorFromVersion, mirrorFromSource, tags), nil
+}
+
+// sinceChannelVersion resolves the version of release channel requested with --since-channel.
+// Well-known channels are already fetched, others (e.g. lts) are looked up in the registry.
+func sinceChannelVersion(
+	mirrorCtx *contexts.PullContext,
+	knownChannels []string,
+	knownChannelsVersions []*semver.Version,
+) (*semver.Version, error) {
+	for i, channel := range knownChannels {
+		if channel == mirrorCtx.SinceChannel {
+			return knownChannelsVersions[i], nil
 		}
 	}
-	versionsAboveMinimal := parseAndFilterVersionsAboveMinimalAnbBelowAlpha(&mirrorFromVersion, tags, alphaChannelVersion)
-	versionsAboveMinimal = filterOnlyLatestPatches(versionsAboveMinimal)
 
-	return deduplicateVersions(append(releaseChannelsVersions, versionsAboveMinimal...)), nil
+	v, err := getReleaseChannelVersionFromRegistry(mirrorCtx, mirrorCtx.SinceChannel)
+	if err != nil {
+		if errorutil.IsImageNotFoundError(err) {
+			return nil, fmt.Errorf("release channel %q does not exist in source registry", mirrorCtx.SinceChannel)
+		}
+		return nil, fmt.Errorf("get %s release version from registry: %w", mirrorCtx.SinceChannel, err)
+	}
+	return v, nil
 }
 
 func getReleasedTagsFromRegistry(mirrorCtx *contexts.PullContext) ([]string, error) {
@@ -76,7 +116,12 @@ func getReleasedTagsFromRegistry(mirrorCtx *contexts.PullContext) ([]string, err
 	if err != nil {
 		return nil, fmt.Errorf("parsing repo: %v", err)
 	}
-	tags, err := remote.List(repo, remoteOpts...)
+	tags, err := mirrorCtx.Run.TagLister().List(
+		mirrorCtx.Run.Context(),
+		repo,
+		auth.MakeTransport(mirrorCtx.SkipTLSVerification),
+		remoteOpts...,
+	)
 	if err != nil {
 		return nil, fmt.Errorf("get tags from Deckhouse registry: %w", err)
 	}
@@ -84,22 +129,6 @@ func getReleasedTagsFromRegistry(mirrorCtx *contexts.PullContext) ([]string, err
 	return tags, nil
 }
 
-func parseAndFilterVersionsAboveMinimalAnbBelowAlpha(
-	minVersion *semver.Version,
-	tags []string,
-	alphaChannelVersion *semver.Version,
-) []*semver.Version {
-	versionsAboveMinimal := make([]*semver.Version, 0)
-	for _, tag := range tags {
-		version, err := semver.NewVersion(tag)
-		if err != nil || minVersion.GreaterThan(version) || version.GreaterThan(alphaChannelVersion) {
-			continue
-		}
-		versionsAboveMinimal = append(versionsAboveMinimal, version)
-	}
-	return versionsAboveMinimal
-}
-
 func filterOnlyLatestPatches(versions []*semver.Version) []*semver.Version {
 	type majorMinor [2]uint64
 	patches := map[majorMinor]uint64{}
@@ -0,0 +1,121 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package output is how commands print. It separates data, the result of the command meant to be consumed by other
+// programs, from messages for the user and diagnostics, so that output flags apply the same way to every command.
+package output
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// AddPersistentFlags registers output flags for cmd and all of its subcommands.
+func AddPersistentFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolP("quiet", "q", false, "Print only command results, errors and warnings.")
+	cmd.PersistentFlags().String("log-format", LogFormatText, `Format of log records: "text" or "json".`)
+	cmd.PersistentFlags().String("timezone", "UTC", `Time zone to render timestamps of reports in, e.g. "Local" or "Europe/Berlin".`)
+}
+
+// ValidateFlags rejects unknown values of output flags and sets time zone of report timestamps from --timezone.
+func ValidateFlags(cmd *cobra.Command) error {
+	if format := logFormat(cmd); format != LogFormatText && format != LogFormatJSON {
+		return fmt.Errorf("Unknown --log-format %q, expected %q or %q", format, LogFormatText, LogFormatJSON)
+	}
+	if timezone, err := cmd.Flags().GetString("timezone"); err == nil {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return fmt.Errorf("Unknown --timezone %q: %w", timezone, err)
+		}
+		reportschema.SetLocation(loc)
+	}
+	return nil
+}
+
+// Output writes data to standard output of the command and everything else to its standard error,
+// so that data can be piped to other programs. User messages and logs are left out with --quiet.
+type Output struct {
+	data        io.Writer
+	diagnostics io.Writer
+	quiet       bool
+	logFormat   string
+}
+
+// FromCommand returns output of cmd configured by output flags of cmd, if it has them.
+func FromCommand(cmd *cobra.Command) *Output {
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	return &Output{
+		data:        cmd.OutOrStdout(),
+		diagnostics: cmd.ErrOrStderr(),
+		quiet:       quiet,
+		logFormat:   logFormat(cmd),
+	}
+}
+
+func logFormat(cmd *cobra.Command) string {
+	format, err := cmd.Flags().GetString("log-format")
+	if err != nil {
+		return LogFormatText
+	}
+	return format
+}
+
+// Data is where results of the command are written, like tables or generated manifests. It is never silenced.
+func (o *Output) Data() io.Writer {
+	return o.data
+}
+
+// Messagef prints message for the user, like a notice that the command has nothing to do.
+func (o *Output) Messagef(format string, a ...any) {
+	if o.quiet {
+		return
+	}
+	fmt.Fprintf(o.diagnostics, format+"\n", a...)
+}
+
+// Warnf prints diagnostic message to standard error. Warnings are not silenced by --quiet.
+func (o *Output) Warnf(format string, a ...any) {
+	fmt.Fprintf(o.diagnostics, "Warning: "+format+"\n", a...)
+}
+
+// Logger returns logger for progress of long-running commands, it writes to standard error.
+// Level is raised to warnings by --quiet and lowered to debug with MIRROR_DEBUG_LOG=3 or higher.
+func (o *Output) Logger() *log.SLogger {
+	level := slog.LevelInfo
+	switch {
+	case o.quiet:
+		level = slog.LevelWarn
+	case log.DebugLogLevel() >= 3:
+		level = slog.LevelDebug
+	}
+
+	if o.logFormat == LogFormatJSON {
+		return log.NewJSONSLoggerWithWriter(o.diagnostics, level)
+	}
+	return log.NewSLoggerWithWriter(o.diagnostics, level)
+}
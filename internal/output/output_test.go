@@ -0,0 +1,95 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+func TestOutput(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		wantData     string
+		wantMessages bool
+		wantJSONLogs bool
+	}{
+		{name: "defaults", wantMessages: true},
+		{name: "quiet", args: []string{"--quiet"}},
+		{name: "json logs", args: []string{"--log-format=json"}, wantMessages: true, wantJSONLogs: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			root := &cobra.Command{Use: "root", PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+				return ValidateFlags(cmd)
+			}}
+			AddPersistentFlags(root)
+			root.AddCommand(&cobra.Command{Use: "sub", RunE: func(cmd *cobra.Command, _ []string) error {
+				out := FromCommand(cmd)
+				out.Messagef("message")
+				out.Warnf("warning")
+				out.Logger().WarnF("log record")
+				_, err := out.Data().Write([]byte("data\n"))
+				return err
+			}})
+			root.SetOut(stdout)
+			root.SetErr(stderr)
+			root.SetArgs(append([]string{"sub"}, tt.args...))
+			require.NoError(t, root.Execute())
+
+			require.Equal(t, "data\n", stdout.String())
+			require.Contains(t, stderr.String(), "log record")
+			require.Contains(t, stderr.String(), "Warning: warning\n")
+			require.Equal(t, tt.wantMessages, bytes.Contains(stderr.Bytes(), []byte("message\n")))
+			if tt.wantJSONLogs {
+				for _, line := range bytes.Split(stderr.Bytes(), []byte("\n")) {
+					if bytes.Contains(line, []byte("log record")) {
+						require.True(t, json.Valid(line), string(line))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValidateFlagsRejectsUnknownLogFormat(t *testing.T) {
+	cmd := &cobra.Command{Use: "root"}
+	AddPersistentFlags(cmd)
+	require.NoError(t, cmd.ParseFlags([]string{"--log-format=yaml"}))
+	require.EqualError(t, ValidateFlags(cmd), `Unknown --log-format "yaml", expected "text" or "json"`)
+}
+
+func TestValidateFlagsSetsReportsTimezone(t *testing.T) {
+	cmd := &cobra.Command{Use: "root"}
+	AddPersistentFlags(cmd)
+	require.NoError(t, cmd.ParseFlags([]string{"--timezone=Mars/Olympus"}))
+	require.ErrorContains(t, ValidateFlags(cmd), `Unknown --timezone "Mars/Olympus"`)
+
+	require.NoError(t, cmd.ParseFlags([]string{"--timezone=Etc/GMT-3"}))
+	require.NoError(t, ValidateFlags(cmd))
+	defer reportschema.SetLocation(nil)
+	require.Equal(t, "2024-06-01T12:00:00+03:00", reportschema.Timestamp(time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)))
+}
@@ -14,15 +14,22 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
+	"github.com/deckhouse/deckhouse-cli/internal/output"
 	"github.com/deckhouse/deckhouse-cli/internal/utilk8s"
 )
 
 func BaseEditConfigCMD(cmd *cobra.Command, name, secret, dataKey string) error {
+	out := output.FromCommand(cmd)
 	editor, err := cmd.Flags().GetString("editor")
 	if err != nil {
 		return fmt.Errorf("Failed to get editor from --editor flag: %w", err)
 	}
 
+	readOnly, err := cmd.Flags().GetBool("read-only")
+	if err != nil {
+		return fmt.Errorf("Failed to get --read-only flag: %w", err)
+	}
+
 	kubeconfigPath, err := cmd.Flags().GetString("kubeconfig")
 	if err != nil {
 		return fmt.Errorf("Failed to setup Kubernetes client: %w", err)
@@ -33,6 +40,18 @@ func BaseEditConfigCMD(cmd *cobra.Command, name, secret, dataKey string) error {
 		return fmt.Errorf("Failed to setup Kubernetes client: %w", err)
 	}
 
+	requiredPermissions := []utilk8s.Permission{
+		{Verb: "get", Resource: "secrets", Namespace: "kube-system", Name: secret},
+	}
+	if !readOnly {
+		requiredPermissions = append(requiredPermissions, utilk8s.Permission{
+			Verb: "patch", Resource: "secrets", Namespace: "kube-system", Name: secret,
+		})
+	}
+	if err = utilk8s.CheckPermissions(context.Background(), kubeCl, requiredPermissions...); err != nil {
+		return err
+	}
+
 	secretConfig, err := kubeCl.CoreV1().
 		Secrets("kube-system").
 		Get(context.Background(), secret, metav1.GetOptions{})
@@ -58,7 +77,7 @@ func BaseEditConfigCMD(cmd *cobra.Command, name, secret, dataKey string) error {
 		return fmt.Errorf("Error opening in editor: %w", err)
 	}
 
-	updatedContent, contentNotChanged, err := compareEditedSecret(tempFile, secretConfig, dataKey)
+	updatedContent, contentNotChanged, err := compareEditedSecret(out, tempFile, secretConfig, dataKey)
 	if err != nil {
 		return fmt.Errorf("Cannot open edited temp file: %w", err)
 	}
@@ -66,6 +85,10 @@ func BaseEditConfigCMD(cmd *cobra.Command, name, secret, dataKey string) error {
 	if contentNotChanged {
 		return nil
 	}
+	if readOnly {
+		out.Messagef("Read-only mode, changes are discarded")
+		return nil
+	}
 
 	encodedValue, err := encodeSecretTmp(updatedContent, dataKey)
 	_, err = kubeCl.CoreV1().
@@ -75,7 +98,7 @@ func BaseEditConfigCMD(cmd *cobra.Command, name, secret, dataKey string) error {
 		return fmt.Errorf("Error updating secret: %w", err)
 	}
 
-	fmt.Println("Secret updated successfully")
+	out.Messagef("Secret updated successfully")
 	return err
 }
 
@@ -96,14 +119,14 @@ func writeSecretTmp(secretConfig *v1.Secret, dataKey string) (*os.File, error) {
 	return tempFile, nil
 }
 
-func compareEditedSecret(tempFile *os.File, secretConfig *v1.Secret, dataKey string) ([]byte, bool, error) {
+func compareEditedSecret(out *output.Output, tempFile *os.File, secretConfig *v1.Secret, dataKey string) ([]byte, bool, error) {
 	updatedContent, err := os.ReadFile(tempFile.Name())
 	if err != nil {
 		return nil, false, fmt.Errorf("Error reading updated file: %w", err)
 	}
 
 	if sha256.Sum256(secretConfig.Data[dataKey]) == sha256.Sum256(updatedContent) {
-		fmt.Println("Configurations are equal. Nothing to update.")
+		out.Messagef("Configurations are equal. Nothing to update.")
 		return nil, true, nil
 	}
 
@@ -26,4 +26,9 @@ func AddFlags(flagSet *pflag.FlagSet) {
 		"vi",
 		"Your favourite editor.",
 	)
+	flagSet.Bool(
+		"read-only",
+		false,
+		"Open configuration for viewing only, changes are never applied to the cluster.",
+	)
 }
@@ -20,6 +20,7 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/kubectl/pkg/util/templates"
 
+	"github.com/deckhouse/deckhouse-cli/internal/output"
 	"github.com/deckhouse/deckhouse-cli/internal/platform/cmd/edit"
 	"github.com/deckhouse/deckhouse-cli/internal/platform/flags"
 )
@@ -36,6 +37,9 @@ func NewCommand() *cobra.Command {
 		Aliases: []string{"p"},
 		Long:    platformLong,
 		PreRunE: flags.ValidateParameters,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return output.ValidateFlags(cmd)
+		},
 	}
 
 	platformCmd.AddCommand(
@@ -43,6 +47,7 @@ func NewCommand() *cobra.Command {
 	)
 
 	flags.AddPersistentFlags(platformCmd)
+	output.AddPersistentFlags(platformCmd)
 
 	return platformCmd
 }
@@ -0,0 +1,47 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serveapi
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+)
+
+const defaultListenAddr = "127.0.0.1:8471"
+
+func addFlags(flagSet *pflag.FlagSet) {
+	defaultTokenFile := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		defaultTokenFile = filepath.Join(home, ".d8", "serve-api-token")
+	}
+
+	flagSet.StringVar(
+		&ListenAddr,
+		"listen",
+		defaultListenAddr,
+		"Address to serve the API on. Only loopback addresses are allowed, as the API runs commands on this host.",
+	)
+	flagSet.StringVar(
+		&TokenFile,
+		"token-file",
+		defaultTokenFile,
+		"File with the API token that clients must send in \"Authorization: Bearer <token>\" header. "+
+			"Random token is generated and written to it if the file does not exist.",
+	)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serveapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/internal/serveapi/server"
+)
+
+var serveAPILong = templates.LongDesc(`
+Serve local HTTP API to run d8 mirror operations as jobs.
+
+The API is meant for management UIs of appliances that embed Deckhouse Kubernetes Platform distribution.
+Jobs run d8 mirror pull, push, bundle verify, bundle verify-signature and compare with the arguments given by the client.
+Clients can follow status, logs and progress of jobs, and cancel them. Progress messages have the same format
+as the ones streamed with --progress-socket. Only the latest logs of every job and the latest finished jobs are kept.
+
+Every request must carry the API token from --token-file in "Authorization: Bearer <token>" header.
+
+© Flant JSC 2024`)
+
+var serveAPIExample = templates.Examples(`
+# Start the API server
+d8 serve-api
+
+# Start d8 mirror pull job
+curl -H "Authorization: Bearer $(cat ~/.d8/serve-api-token)" http://127.0.0.1:8471/api/v1/jobs \
+  -d '{"operation": "pull", "args": ["--license=<license>", "/opt/bundle"]}'
+
+# Follow progress of the job
+curl -H "Authorization: Bearer $(cat ~/.d8/serve-api-token)" http://127.0.0.1:8471/api/v1/jobs/<id>/events
+`)
+
+// shutdownTimeout is how long running requests are waited for on shutdown.
+const shutdownTimeout = 10 * time.Second
+
+func NewCommand() *cobra.Command {
+	serveAPICmd := &cobra.Command{
+		Use:           "serve-api",
+		Short:         "Serve local HTTP API to run d8 mirror operations as jobs",
+		Long:          serveAPILong,
+		Example:       serveAPIExample,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return output.ValidateFlags(cmd)
+		},
+		PreRunE: parseAndValidateParameters,
+		RunE:    serveAPI,
+	}
+
+	output.AddPersistentFlags(serveAPICmd)
+	addFlags(serveAPICmd.Flags())
+	return serveAPICmd
+}
+
+var (
+	ListenAddr string
+	TokenFile  string
+)
+
+func serveAPI(cmd *cobra.Command, _ []string) error {
+	logger := output.FromCommand(cmd).Logger()
+
+	token, err := server.LoadOrCreateToken(TokenFile)
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("Find d8 executable to run jobs with: %w", err)
+	}
+	socketsDir, err := os.MkdirTemp("", "d8-serve-api")
+	if err != nil {
+		return fmt.Errorf("Create directory for progress sockets of jobs: %w", err)
+	}
+	defer os.RemoveAll(socketsDir)
+
+	manager := server.NewManager(server.ExecRunner(executable), socketsDir)
+	defer manager.Shutdown()
+
+	httpServer := &http.Server{
+		Addr:              ListenAddr,
+		Handler:           server.NewHandler(manager, token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		logger.InfoLn("Shutting down, running jobs are cancelled")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	logger.InfoF("Serving API on http://%s, token is read from %s", ListenAddr, TokenFile)
+	if err = httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("Serve API: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serveapi
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/spf13/cobra"
+)
+
+func parseAndValidateParameters(_ *cobra.Command, _ []string) error {
+	if err := validateListenAddr(); err != nil {
+		return err
+	}
+	if TokenFile == "" {
+		return errors.New("--token-file is required, as home directory to keep API token in by default cannot be determined")
+	}
+	return nil
+}
+
+func validateListenAddr() error {
+	host, _, err := net.SplitHostPort(ListenAddr)
+	if err != nil {
+		return fmt.Errorf("Invalid --listen address: %w", err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("--listen address %s is not a loopback one, API can only be served to local clients", ListenAddr)
+	}
+	return nil
+}
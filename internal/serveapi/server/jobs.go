@@ -0,0 +1,487 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/progress"
+)
+
+var (
+	ErrUnknownOperation = errors.New("unknown operation")
+	ErrJobNotFound      = errors.New("job not found")
+	ErrJobFinished      = errors.New("job is already finished")
+)
+
+// operations are d8 commands that jobs can run, by operation name.
+var operations = map[string][]string{
+	"pull":             {"mirror", "pull"},
+	"push":             {"mirror", "push"},
+	"verify":           {"mirror", "bundle", "verify"},
+	"verify-signature": {"mirror", "bundle", "verify-signature"},
+	"compare":          {"mirror", "compare"},
+}
+
+// progressOperations report their progress over --progress-socket.
+var progressOperations = map[string]struct{}{
+	"pull": {},
+	"push": {},
+}
+
+const (
+	// cancelGracePeriod is how long cancelled job is given to clean up before it is killed.
+	cancelGracePeriod = 30 * time.Second
+	// progressDialInterval is how often job progress socket is checked until the job creates it.
+	progressDialInterval = 200 * time.Millisecond
+	// progressDrainTimeout is how long final progress messages are waited for after job exits.
+	progressDrainTimeout = 5 * time.Second
+
+	// maxJobLogSize is how much of the latest output is kept for every job, earlier output is dropped.
+	maxJobLogSize = 1 << 20
+	// maxFinishedJobs is how many finished jobs are kept, the ones finished earliest are forgotten first.
+	maxFinishedJobs = 100
+)
+
+// Operations returns names of operations that jobs can run.
+func Operations() []string {
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Runner runs d8 with command line arguments, writing its output to logs.
+// It must stop the command once ctx is cancelled.
+type Runner func(ctx context.Context, args []string, logs io.Writer) error
+
+// ExecRunner runs commands as child processes of d8 executable at path.
+// Cancelled commands are interrupted first, so that they clean up their temporary data, and killed after a grace period.
+func ExecRunner(path string) Runner {
+	return func(ctx context.Context, args []string, logs io.Writer) error {
+		cmd := exec.CommandContext(ctx, path, args...)
+		cmd.Stdout, cmd.Stderr = logs, logs
+		cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+		cmd.WaitDelay = cancelGracePeriod
+		return cmd.Run()
+	}
+}
+
+type State string
+
+const (
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// Job is the status of operation started through the API.
+type Job struct {
+	ID         string     `json:"id"`
+	Operation  string     `json:"operation"`
+	State      State      `json:"state"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+
+	// Progress is the latest progress message of the job, in the same format --progress-socket streams them.
+	// Finished jobs always have a message of type "done".
+	Progress *progress.Message `json:"progress,omitempty"`
+}
+
+// Manager runs jobs and keeps their status and logs until it is shut down
+// or until there are more than maxFinishedJobs finished jobs.
+type Manager struct {
+	runner     Runner
+	socketsDir string
+
+	mu    sync.Mutex
+	jobs  map[string]*job
+	order []string
+	wg    sync.WaitGroup
+}
+
+// NewManager returns manager running jobs with runner. Progress sockets of jobs are created in socketsDir.
+func NewManager(runner Runner, socketsDir string) *Manager {
+	return &Manager{
+		runner:     runner,
+		socketsDir: socketsDir,
+		jobs:       make(map[string]*job),
+	}
+}
+
+type job struct {
+	mu              sync.Mutex
+	status          Job
+	cancel          context.CancelFunc
+	cancelRequested bool
+	logs            logBuffer
+	// updated is closed and replaced every time status changes, so that watchers wake up.
+	updated chan struct{}
+}
+
+// Start runs operation with args, which are passed to the d8 command of the operation as is.
+func (m *Manager) Start(operation string, args []string) (Job, error) {
+	command, found := operations[operation]
+	if !found {
+		return Job{}, fmt.Errorf("%w %q, expected one of: %s", ErrUnknownOperation, operation, strings.Join(Operations(), ", "))
+	}
+	if slices.ContainsFunc(args, func(arg string) bool { return strings.HasPrefix(arg, "--progress-socket") }) {
+		return Job{}, errors.New("--progress-socket is set by the API server and cannot be passed with job arguments")
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+	args = append(slices.Clone(command), args...)
+	socketPath := ""
+	if _, reportsProgress := progressOperations[operation]; reportsProgress {
+		socketPath = filepath.Join(m.socketsDir, id+".sock")
+		args = append(args, "--progress-socket", socketPath)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		status: Job{
+			ID:        id,
+			Operation: operation,
+			State:     StateRunning,
+			StartedAt: time.Now().UTC(),
+		},
+		cancel:  cancel,
+		updated: make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.evictFinishedJobsLocked()
+	m.jobs[id] = j
+	m.order = append(m.order, id)
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer cancel()
+		j.run(ctx, m.runner, args, socketPath)
+	}()
+	return j.snapshot(), nil
+}
+
+// List returns status of every job in the order they were started.
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]Job, 0, len(m.order))
+	for _, id := range m.order {
+		jobs = append(jobs, m.jobs[id].snapshot())
+	}
+	return jobs
+}
+
+func (m *Manager) Get(id string) (Job, error) {
+	j, err := m.job(id)
+	if err != nil {
+		return Job{}, err
+	}
+	return j.snapshot(), nil
+}
+
+// Cancel stops running job. Job status changes to cancelled once its command exits.
+func (m *Manager) Cancel(id string) (Job, error) {
+	j, err := m.job(id)
+	if err != nil {
+		return Job{}, err
+	}
+
+	j.mu.Lock()
+	if j.status.State != StateRunning {
+		j.mu.Unlock()
+		return Job{}, ErrJobFinished
+	}
+	j.cancelRequested = true
+	j.mu.Unlock()
+	j.cancel()
+	return j.snapshot(), nil
+}
+
+// Logs returns what the job command has written to its standard output and error so far,
+// up to the last maxJobLogSize bytes of it.
+func (m *Manager) Logs(id string) ([]byte, error) {
+	j, err := m.job(id)
+	if err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.logs.Bytes(), nil
+}
+
+// Watch calls fn with job status right away and then every time it changes, until the job is finished,
+// ctx is cancelled or fn returns an error.
+func (m *Manager) Watch(ctx context.Context, id string, fn func(Job) error) error {
+	j, err := m.job(id)
+	if err != nil {
+		return err
+	}
+	for {
+		j.mu.Lock()
+		status, updated := j.snapshotLocked(), j.updated
+		j.mu.Unlock()
+
+		if err = fn(status); err != nil {
+			return err
+		}
+		if status.State != StateRunning {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-updated:
+		}
+	}
+}
+
+// Shutdown cancels all running jobs and waits for them to exit.
+func (m *Manager) Shutdown() {
+	m.mu.Lock()
+	for _, j := range m.jobs {
+		j.cancel()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
+
+// evictFinishedJobsLocked forgets the earliest finished jobs, so that no more than maxFinishedJobs-1 of them are left.
+func (m *Manager) evictFinishedJobsLocked() {
+	finished := make([]string, 0)
+	for _, id := range m.order {
+		if m.jobs[id].snapshot().State != StateRunning {
+			finished = append(finished, id)
+		}
+	}
+	if len(finished) < maxFinishedJobs {
+		return
+	}
+
+	evicted := make(map[string]struct{})
+	for _, id := range finished[:len(finished)-maxFinishedJobs+1] {
+		evicted[id] = struct{}{}
+		delete(m.jobs, id)
+	}
+	m.order = slices.DeleteFunc(m.order, func(id string) bool {
+		_, found := evicted[id]
+		return found
+	})
+}
+
+func (m *Manager) job(id string) (*job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, found := m.jobs[id]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return j, nil
+}
+
+func (j *job) run(ctx context.Context, runner Runner, args []string, socketPath string) {
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	connected := &atomic.Bool{}
+	go func() {
+		defer close(relayDone)
+		if socketPath != "" {
+			j.relayProgress(relayCtx, socketPath, connected)
+		}
+	}()
+
+	runErr := runner(ctx, args, logWriter{j})
+
+	// Command has exited, but final progress messages may still be in flight
+	if connected.Load() {
+		select {
+		case <-relayDone:
+		case <-time.After(progressDrainTimeout):
+		}
+	}
+	stopRelay()
+	<-relayDone
+	j.finish(runErr)
+}
+
+// relayProgress connects to progress socket of the job once it is created and records every received message.
+func (j *job) relayProgress(ctx context.Context, socketPath string, connected *atomic.Bool) {
+	var conn net.Conn
+	dialer := &net.Dialer{}
+	for conn == nil {
+		var err error
+		if conn, err = dialer.DialContext(ctx, "unix", socketPath); err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(progressDialInterval):
+		}
+	}
+	connected.Store(true)
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		msg := &progress.Message{}
+		if err := json.Unmarshal(scanner.Bytes(), msg); err != nil {
+			continue
+		}
+		j.update(func(status *Job) { status.Progress = msg })
+		if msg.Type == progress.TypeDone {
+			return
+		}
+	}
+}
+
+func (j *job) finish(runErr error) {
+	j.update(func(status *Job) {
+		finishedAt := time.Now().UTC()
+		status.FinishedAt = &finishedAt
+		switch {
+		case j.cancelRequested:
+			status.State = StateCancelled
+		case runErr != nil:
+			status.State = StateFailed
+			status.Error = runErr.Error()
+		default:
+			status.State = StateSucceeded
+		}
+
+		// Jobs that do not report progress, or failed before they could, still get their final message
+		if status.Progress == nil || status.Progress.Type != progress.TypeDone {
+			done := &progress.Message{
+				Version:   progress.ProtocolVersion,
+				Type:      progress.TypeDone,
+				Time:      finishedAt,
+				Operation: status.Operation,
+				Stages:    make([]progress.Stage, 0),
+				Error:     status.Error,
+			}
+			if status.Progress != nil {
+				done.Stages = status.Progress.Stages
+				done.ImagesPulled = status.Progress.ImagesPulled
+				done.ReposPushed = status.Progress.ReposPushed
+			}
+			if status.State == StateCancelled {
+				done.Error = "cancelled"
+			}
+			status.Progress = done
+		}
+	})
+}
+
+func (j *job) update(fn func(status *Job)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.status)
+	close(j.updated)
+	j.updated = make(chan struct{})
+}
+
+func (j *job) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.snapshotLocked()
+}
+
+func (j *job) snapshotLocked() Job {
+	status := j.status
+	if status.Progress != nil {
+		msg := *status.Progress
+		status.Progress = &msg
+	}
+	return status
+}
+
+// logWriter appends command output to job logs.
+type logWriter struct{ j *job }
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.j.mu.Lock()
+	defer w.j.mu.Unlock()
+	return w.j.logs.Write(p)
+}
+
+// logBuffer keeps the last maxJobLogSize bytes written to it.
+type logBuffer struct {
+	// data grows up to twice maxJobLogSize before earlier output is dropped, so that it is not moved on every write.
+	data    []byte
+	dropped int64
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > 2*maxJobLogSize {
+		excess := len(b.data) - maxJobLogSize
+		b.dropped += int64(excess)
+		b.data = append(b.data[:0], b.data[excess:]...)
+	}
+	return len(p), nil
+}
+
+// Bytes returns copy of kept logs, prefixed with a notice if earlier logs were dropped.
+func (b *logBuffer) Bytes() []byte {
+	kept, dropped := b.data, b.dropped
+	if excess := len(kept) - maxJobLogSize; excess > 0 {
+		kept, dropped = kept[excess:], dropped+int64(excess)
+	}
+	if dropped == 0 {
+		return bytes.Clone(kept)
+	}
+	notice := fmt.Sprintf("[%d bytes of earlier logs are dropped]\n", dropped)
+	return append([]byte(notice), kept...)
+}
+
+func newJobID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("Generate job ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
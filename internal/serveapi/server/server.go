@@ -0,0 +1,171 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package server implements local HTTP API that lets management UIs of appliances run d8 mirror operations as jobs.
+//
+// Every request must carry the API token in "Authorization: Bearer <token>" header. Endpoints:
+//
+//	GET  /api/v1/operations         names of operations jobs can run
+//	POST /api/v1/jobs               start a job, request body is {"operation": "pull", "args": ["--license=...", "/bundle"]}
+//	GET  /api/v1/jobs               status of all jobs
+//	GET  /api/v1/jobs/{id}          status of the job
+//	POST /api/v1/jobs/{id}/cancel   cancel the job
+//	GET  /api/v1/jobs/{id}/logs     output of the job command as plain text
+//	GET  /api/v1/jobs/{id}/events   newline-delimited JSON progress messages, as streamed by --progress-socket,
+//	                                ending with a message of type "done" once the job is finished
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/progress"
+)
+
+// maxRequestBody limits size of job start requests.
+const maxRequestBody = 1 << 20
+
+type startJobRequest struct {
+	Operation string   `json:"operation"`
+	Args      []string `json:"args"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler returns API handler for jobs of manager, accepting requests with token only.
+func NewHandler(manager *Manager, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/operations", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, Operations())
+	})
+	mux.HandleFunc("POST /api/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		req := &startJobRequest{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(req); err != nil {
+			writeError(w, http.StatusBadRequest, "Parse request: "+err.Error())
+			return
+		}
+		job, err := manager.Start(req.Operation, req.Args)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, job)
+	})
+	mux.HandleFunc("GET /api/v1/jobs", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, manager.List())
+	})
+	mux.HandleFunc("GET /api/v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, err := manager.Get(r.PathValue("id"))
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+	mux.HandleFunc("POST /api/v1/jobs/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		job, err := manager.Cancel(r.PathValue("id"))
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, job)
+	})
+	mux.HandleFunc("GET /api/v1/jobs/{id}/logs", func(w http.ResponseWriter, r *http.Request) {
+		logs, err := manager.Logs(r.PathValue("id"))
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write(logs)
+	})
+	mux.HandleFunc("GET /api/v1/jobs/{id}/events", func(w http.ResponseWriter, r *http.Request) {
+		streamEvents(w, r, manager)
+	})
+
+	return requireToken(token, mux)
+}
+
+// streamEvents writes progress message of the job every time it changes until the job is finished.
+func streamEvents(w http.ResponseWriter, r *http.Request, manager *Manager) {
+	id := r.PathValue("id")
+	if _, err := manager.Get(id); err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	var lastSent *progress.Message
+	_ = manager.Watch(r.Context(), id, func(job Job) error {
+		// Status also changes for reasons other than progress, only new messages are sent
+		if job.Progress == nil || (lastSent != nil && isSameMessage(lastSent, job.Progress)) {
+			return nil
+		}
+		lastSent = job.Progress
+		if err := encoder.Encode(job.Progress); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+}
+
+func isSameMessage(a, b *progress.Message) bool {
+	return a.Type == b.Type && a.Time.Equal(b.Time)
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented, hasToken := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !hasToken || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="d8"`)
+			writeError(w, http.StatusUnauthorized, "API token is missing or invalid")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrJobFinished):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/progress"
+)
+
+const testToken = "test-token"
+
+// fakeRunner reports progress of a single push stage over the progress socket the way d8 mirror push does.
+func fakeRunner(ctx context.Context, args []string, logs io.Writer) error {
+	fmt.Fprintf(logs, "Running %s\n", strings.Join(args[:2], " "))
+	if slices.Contains(args, "--block") {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if slices.Contains(args, "--fail") {
+		return fmt.Errorf("exit status 1")
+	}
+
+	socketIdx := slices.Index(args, "--progress-socket")
+	if socketIdx == -1 {
+		return nil
+	}
+	run := contexts.NewRunContext(ctx)
+	stop, err := progress.Serve(run, progress.Options{SocketPath: args[socketIdx+1], Operation: args[1], Interval: 10 * time.Millisecond})
+	if err != nil {
+		return err
+	}
+	run.AddTotal(contexts.StagePush, 2)
+	for i := 0; i < 2; i++ {
+		time.Sleep(50 * time.Millisecond)
+		run.Advance(contexts.StagePush, 1)
+	}
+	time.Sleep(50 * time.Millisecond)
+	stop(nil)
+	return nil
+}
+
+func setupServer(t *testing.T) (*httptest.Server, *Manager) {
+	t.Helper()
+	socketsDir, err := os.MkdirTemp("", "d8-api")
+	require.NoError(t, err)
+	manager := NewManager(fakeRunner, socketsDir)
+	server := httptest.NewServer(NewHandler(manager, testToken))
+	t.Cleanup(func() {
+		server.Close()
+		manager.Shutdown()
+		_ = os.RemoveAll(socketsDir)
+	})
+	return server, manager
+}
+
+func apiRequest(t *testing.T, server *httptest.Server, method, path, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func startJob(t *testing.T, server *httptest.Server, operation string, args ...string) Job {
+	t.Helper()
+	body, err := json.Marshal(startJobRequest{Operation: operation, Args: args})
+	require.NoError(t, err)
+	resp := apiRequest(t, server, http.MethodPost, "/api/v1/jobs", string(body))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	job := Job{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+	require.Equal(t, StateRunning, job.State)
+	return job
+}
+
+func waitForJob(t *testing.T, manager *Manager, id string) Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var finished Job
+	require.NoError(t, manager.Watch(ctx, id, func(job Job) error {
+		finished = job
+		return nil
+	}))
+	return finished
+}
+
+func TestJobStreamsProgressEvents(t *testing.T) {
+	server, manager := setupServer(t)
+	job := startJob(t, server, "push", "/bundle", "registry.example.com/deckhouse")
+
+	resp := apiRequest(t, server, http.MethodGet, "/api/v1/jobs/"+job.ID+"/events", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	messages := make([]progress.Message, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		msg := progress.Message{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &msg))
+		messages = append(messages, msg)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.NotEmpty(t, messages)
+	last := messages[len(messages)-1]
+	require.Equal(t, progress.TypeDone, last.Type)
+	require.Equal(t, []progress.Stage{{Name: contexts.StagePush, Done: 2, Total: 2}}, last.Stages)
+
+	finished := waitForJob(t, manager, job.ID)
+	require.Equal(t, StateSucceeded, finished.State)
+	require.NotNil(t, finished.FinishedAt)
+
+	resp = apiRequest(t, server, http.MethodGet, "/api/v1/jobs/"+job.ID+"/logs", "")
+	logs, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "Running mirror push\n", string(logs))
+}
+
+func TestFailedJobGetsFinalProgressMessage(t *testing.T) {
+	server, manager := setupServer(t)
+	job := startJob(t, server, "verify", "--fail")
+
+	finished := waitForJob(t, manager, job.ID)
+	require.Equal(t, StateFailed, finished.State)
+	require.Equal(t, "exit status 1", finished.Error)
+	require.NotNil(t, finished.Progress)
+	require.Equal(t, progress.TypeDone, finished.Progress.Type)
+	require.Equal(t, "exit status 1", finished.Progress.Error)
+}
+
+func TestCancelJob(t *testing.T) {
+	server, manager := setupServer(t)
+	job := startJob(t, server, "pull", "--block")
+
+	resp := apiRequest(t, server, http.MethodPost, "/api/v1/jobs/"+job.ID+"/cancel", "")
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Equal(t, StateCancelled, waitForJob(t, manager, job.ID).State)
+
+	resp = apiRequest(t, server, http.MethodPost, "/api/v1/jobs/"+job.ID+"/cancel", "")
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestListJobs(t *testing.T) {
+	server, manager := setupServer(t)
+	first := startJob(t, server, "verify")
+	second := startJob(t, server, "verify")
+	waitForJob(t, manager, first.ID)
+	waitForJob(t, manager, second.ID)
+
+	resp := apiRequest(t, server, http.MethodGet, "/api/v1/jobs", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	jobs := make([]Job, 0)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobs))
+	require.Len(t, jobs, 2)
+	require.Equal(t, first.ID, jobs[0].ID)
+	require.Equal(t, second.ID, jobs[1].ID)
+
+	resp = apiRequest(t, server, http.MethodGet, "/api/v1/jobs/unknown", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestFinishedJobsAreEvicted(t *testing.T) {
+	_, manager := setupServer(t)
+	first, err := manager.Start("verify", nil)
+	require.NoError(t, err)
+	waitForJob(t, manager, first.ID)
+	for i := 1; i < maxFinishedJobs; i++ {
+		job, err := manager.Start("verify", nil)
+		require.NoError(t, err)
+		waitForJob(t, manager, job.ID)
+	}
+	_, err = manager.Get(first.ID)
+	require.NoError(t, err)
+
+	last, err := manager.Start("verify", nil)
+	require.NoError(t, err)
+	_, err = manager.Get(first.ID)
+	require.ErrorIs(t, err, ErrJobNotFound)
+	require.Len(t, manager.List(), maxFinishedJobs)
+	require.Equal(t, last.ID, manager.List()[maxFinishedJobs-1].ID)
+}
+
+func TestJobLogsKeepLatestOutput(t *testing.T) {
+	logs := &logBuffer{}
+	_, err := logs.Write([]byte(strings.Repeat("a", maxJobLogSize)))
+	require.NoError(t, err)
+	_, err = logs.Write([]byte("latest"))
+	require.NoError(t, err)
+
+	kept := string(logs.Bytes())
+	require.True(t, strings.HasPrefix(kept, "[6 bytes of earlier logs are dropped]\n"), kept[:64])
+	require.True(t, strings.HasSuffix(kept, "latest"))
+
+	_, err = logs.Write([]byte(strings.Repeat("b", maxJobLogSize)))
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(logs.data), 2*maxJobLogSize)
+	require.Equal(t, "[1048582 bytes of earlier logs are dropped]\n"+strings.Repeat("b", maxJobLogSize), string(logs.Bytes()))
+}
+
+func TestStartJobRejectsInvalidRequests(t *testing.T) {
+	server, _ := setupServer(t)
+
+	for name, body := range map[string]string{
+		"unknown operation":       `{"operation": "plugins"}`,
+		"progress socket in args": `{"operation": "pull", "args": ["--progress-socket=/tmp/socket"]}`,
+		"malformed body":          `{"operation":`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := apiRequest(t, server, http.MethodPost, "/api/v1/jobs", body)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
+
+func TestRequestsWithoutTokenAreRejected(t *testing.T) {
+	server, _ := setupServer(t)
+
+	for _, authorization := range []string{"", "Bearer wrong-token", "Basic " + testToken} {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/jobs", nil)
+		require.NoError(t, err)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode, authorization)
+	}
+}
+
+func TestLoadOrCreateToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d8", "api-token")
+
+	token, err := LoadOrCreateToken(path)
+	require.NoError(t, err)
+	require.Len(t, token, 64)
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), stat.Mode().Perm())
+
+	reloaded, err := LoadOrCreateToken(path)
+	require.NoError(t, err)
+	require.Equal(t, token, reloaded)
+
+	require.NoError(t, os.Chmod(path, 0o644))
+	_, err = LoadOrCreateToken(path)
+	require.ErrorContains(t, err, "accessible only by its owner")
+}
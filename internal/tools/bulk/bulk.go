@@ -0,0 +1,417 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bulk applies annotation and label changes to every matching object in the cluster,
+// regardless of its kind, using dynamic client and API discovery.
+package bulk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+const DefaultConcurrency = 10
+
+// Change describes annotations and labels to set or remove on every object.
+type Change struct {
+	SetAnnotations    map[string]string
+	RemoveAnnotations []string
+	SetLabels         map[string]string
+	RemoveLabels      []string
+}
+
+func (c Change) IsEmpty() bool {
+	return len(c.SetAnnotations) == 0 && len(c.RemoveAnnotations) == 0 && len(c.SetLabels) == 0 && len(c.RemoveLabels) == 0
+}
+
+// AppliedTo reports whether object already has the change applied, so that it does not need to be patched.
+func (c Change) AppliedTo(obj metav1.Object) bool {
+	return mapChangeApplied(obj.GetAnnotations(), c.SetAnnotations, c.RemoveAnnotations) &&
+		mapChangeApplied(obj.GetLabels(), c.SetLabels, c.RemoveLabels)
+}
+
+func mapChangeApplied(current, set map[string]string, remove []string) bool {
+	for k, v := range set {
+		if currentValue, found := current[k]; !found || currentValue != v {
+			return false
+		}
+	}
+	for _, k := range remove {
+		if _, found := current[k]; found {
+			return false
+		}
+	}
+	return true
+}
+
+// MergePatch returns JSON merge patch that applies the change.
+func (c Change) MergePatch() ([]byte, error) {
+	metadata := map[string]interface{}{}
+	if annotations := mapPatch(c.SetAnnotations, c.RemoveAnnotations); annotations != nil {
+		metadata["annotations"] = annotations
+	}
+	if labels := mapPatch(c.SetLabels, c.RemoveLabels); labels != nil {
+		metadata["labels"] = labels
+	}
+	return json.Marshal(map[string]interface{}{"metadata": metadata})
+}
+
+func mapPatch(set map[string]string, remove []string) map[string]interface{} {
+	if len(set) == 0 && len(remove) == 0 {
+		return nil
+	}
+	patch := make(map[string]interface{}, len(set)+len(remove))
+	for _, k := range remove {
+		patch[k] = nil
+	}
+	for k, v := range set {
+		patch[k] = v
+	}
+	return patch
+}
+
+// Target is a kind of resources to process.
+type Target struct {
+	GVR        schema.GroupVersionResource
+	Namespaced bool
+}
+
+// String returns resource name in kubectl form, e.g. "deployments.apps".
+func (t Target) String() string {
+	return t.GVR.GroupResource().String()
+}
+
+// ObjectRef points to a single object to be processed.
+type ObjectRef struct {
+	Target    Target
+	Namespace string
+	Name      string
+}
+
+// String returns reference in form used by retry files, e.g. "deployments.apps d8-system/deckhouse".
+func (r ObjectRef) String() string {
+	if r.Namespace == "" {
+		return r.Target.String() + " " + r.Name
+	}
+	return r.Target.String() + " " + r.Namespace + "/" + r.Name
+}
+
+// ResourceFilter selects resources to be processed by their kubectl names, like "pods" or "deployments.apps".
+// Empty Include matches every resource.
+type ResourceFilter struct {
+	Include []string
+	Exclude []string
+}
+
+func (f ResourceFilter) matches(gr schema.GroupResource) bool {
+	names := []string{gr.Resource, gr.String()}
+	for _, excluded := range f.Exclude {
+		if containsString(names, excluded) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, included := range f.Include {
+		if containsString(names, included) {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscoverTargets returns preferred versions of all resources that can be listed and patched and match the filter.
+func DiscoverTargets(discoveryCl discovery.DiscoveryInterface, filter ResourceFilter) ([]Target, error) {
+	resourceLists, err := discoveryCl.ServerPreferredResources()
+	if err != nil && len(resourceLists) == 0 {
+		return nil, fmt.Errorf("Discover API resources: %w", err)
+	}
+	// Partial discovery failures, e.g. of unavailable aggregated APIs, are fine, such resources are just skipped.
+
+	targets := make([]Target, 0)
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			return nil, fmt.Errorf("Parse group version %q: %w", resourceList.GroupVersion, err)
+		}
+		for _, resource := range resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") {
+				continue // Subresources are not objects on their own
+			}
+			if !containsString(resource.Verbs, "list") || !containsString(resource.Verbs, "patch") {
+				continue
+			}
+			gvr := gv.WithResource(resource.Name)
+			if !filter.matches(gvr.GroupResource()) {
+				continue
+			}
+			targets = append(targets, Target{GVR: gvr, Namespaced: resource.Namespaced})
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].String() < targets[j].String() })
+	return targets, nil
+}
+
+type Options struct {
+	Change Change
+
+	// Namespaces limits processed namespaced objects to these namespaces. Cluster-wide objects are always processed.
+	Namespaces    []string
+	LabelSelector string
+
+	// DryRun only reports objects that would be changed.
+	DryRun      bool
+	Concurrency int
+
+	// FallbackClient, if set, is used to retry patches that were forbidden for the main client, e.g. with impersonation.
+	FallbackClient dynamic.Interface
+
+	// Out receives progress messages.
+	Out io.Writer
+}
+
+type Result struct {
+	Total   int
+	Patched int
+	Skipped int
+	Failed  []FailedObject
+	// Forbidden are resources that current user is not allowed to list, their objects are left unchanged.
+	Forbidden []ForbiddenTarget
+}
+
+type FailedObject struct {
+	Ref ObjectRef
+	Err error
+}
+
+// ForbiddenTarget is a resource that could not be listed in Namespace, or cluster-wide if Namespace is empty.
+type ForbiddenTarget struct {
+	Target    Target
+	Namespace string
+	Err       error
+}
+
+func (t ForbiddenTarget) String() string {
+	if t.Namespace == "" {
+		return t.Target.String()
+	}
+	return t.Target.String() + " in namespace " + t.Namespace
+}
+
+// Run applies change to all objects of targets matching options.
+// Resources that current user is not allowed to list are skipped and reported in Result.Forbidden.
+func Run(ctx context.Context, client dynamic.Interface, targets []Target, opts Options) (*Result, error) {
+	if opts.Out == nil {
+		opts.Out = io.Discard
+	}
+
+	refs := make([]ObjectRef, 0)
+	forbidden := make([]ForbiddenTarget, 0)
+	for _, target := range targets {
+		targetRefs, targetForbidden, err := listObjects(ctx, client, target, opts)
+		if err != nil {
+			return nil, fmt.Errorf("List %s: %w", target, err)
+		}
+		for _, f := range targetForbidden {
+			fmt.Fprintf(opts.Out, "Skipping %s as it cannot be listed: %v\n", f, f.Err)
+		}
+		refs = append(refs, targetRefs...)
+		forbidden = append(forbidden, targetForbidden...)
+	}
+
+	result, err := Apply(ctx, client, refs, opts)
+	if result != nil {
+		result.Forbidden = forbidden
+	}
+	return result, err
+}
+
+// Apply applies change to exactly the given objects, e.g. ones read from retry file.
+func Apply(ctx context.Context, client dynamic.Interface, refs []ObjectRef, opts Options) (*Result, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.Out == nil {
+		opts.Out = io.Discard
+	}
+	patch, err := opts.Change.MergePatch()
+	if err != nil {
+		return nil, fmt.Errorf("Build patch: %w", err)
+	}
+
+	result := &Result{Total: len(refs)}
+	var patched, skipped, processed atomic.Int64
+	failedMu := &sync.Mutex{}
+	queue := make(chan ObjectRef)
+	wg := &sync.WaitGroup{}
+	for range opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ref := range queue {
+				changed, err := applyToObject(ctx, client, ref, patch, opts)
+				switch {
+				case err != nil:
+					failedMu.Lock()
+					result.Failed = append(result.Failed, FailedObject{Ref: ref, Err: err})
+					failedMu.Unlock()
+					fmt.Fprintf(opts.Out, "Failed to patch %s: %v\n", ref, err)
+				case changed:
+					patched.Add(1)
+				default:
+					skipped.Add(1)
+				}
+
+				if done := processed.Add(1); done%100 == 0 || int(done) == len(refs) {
+					fmt.Fprintf(opts.Out, "Processed %d / %d objects\n", done, len(refs))
+				}
+			}
+		}()
+	}
+
+	for _, ref := range refs {
+		select {
+		case queue <- ref:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	result.Patched = int(patched.Load())
+	result.Skipped = int(skipped.Load())
+	sort.Slice(result.Failed, func(i, j int) bool { return result.Failed[i].Ref.String() < result.Failed[j].Ref.String() })
+	return result, ctx.Err()
+}
+
+// listObjects lists objects of target that do not have the change applied yet,
+// along with namespaces, or the whole target, that current user is not allowed to list.
+func listObjects(ctx context.Context, client dynamic.Interface, target Target, opts Options) ([]ObjectRef, []ForbiddenTarget, error) {
+	namespaces := []string{metav1.NamespaceAll}
+	if target.Namespaced && len(opts.Namespaces) > 0 {
+		namespaces = opts.Namespaces
+	}
+
+	refs := make([]ObjectRef, 0)
+	forbidden := make([]ForbiddenTarget, 0)
+namespacesLoop:
+	for _, namespace := range namespaces {
+		listOpts := metav1.ListOptions{LabelSelector: opts.LabelSelector}
+		for {
+			list, err := resourceClient(client, target, namespace).List(ctx, listOpts)
+			if err != nil {
+				if apierrors.IsNotFound(err) || apierrors.IsMethodNotSupported(err) {
+					return refs, forbidden, nil
+				}
+				if apierrors.IsForbidden(err) {
+					forbidden = append(forbidden, ForbiddenTarget{Target: target, Namespace: namespace, Err: err})
+					continue namespacesLoop
+				}
+				return nil, nil, err
+			}
+			for i := range list.Items {
+				obj := &list.Items[i]
+				if opts.Change.AppliedTo(obj) {
+					continue
+				}
+				refs = append(refs, ObjectRef{Target: target, Namespace: obj.GetNamespace(), Name: obj.GetName()})
+			}
+			if list.GetContinue() == "" {
+				break
+			}
+			listOpts.Continue = list.GetContinue()
+		}
+	}
+	return refs, forbidden, nil
+}
+
+// applyToObject patches a single object, returning false if it was not changed because change is already applied.
+func applyToObject(ctx context.Context, client dynamic.Interface, ref ObjectRef, patch []byte, opts Options) (bool, error) {
+	obj, err := resourceClient(client, ref.Target, ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if opts.Change.AppliedTo(obj) {
+		return false, nil
+	}
+	if opts.DryRun {
+		fmt.Fprintf(opts.Out, "Would patch %s\n", ref)
+		return true, nil
+	}
+
+	err = patchObject(ctx, client, ref, patch)
+	if apierrors.IsForbidden(err) && opts.FallbackClient != nil {
+		err = patchObject(ctx, opts.FallbackClient, ref, patch)
+	}
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func patchObject(ctx context.Context, client dynamic.Interface, ref ObjectRef, patch []byte) error {
+	return retry.OnError(retry.DefaultBackoff, isRetryableError, func() error {
+		_, err := resourceClient(client, ref.Target, ref.Namespace).Patch(ctx, ref.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
+}
+
+func isRetryableError(err error) bool {
+	return apierrors.IsConflict(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err)
+}
+
+func resourceClient(client dynamic.Interface, target Target, namespace string) dynamic.ResourceInterface {
+	if target.Namespaced && namespace != "" {
+		return client.Resource(target.GVR).Namespace(namespace)
+	}
+	return client.Resource(target.GVR)
+}
+
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var (
+	configMapsTarget  = Target{GVR: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, Namespaced: true}
+	deploymentsTarget = Target{GVR: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, Namespaced: true}
+	namespacesTarget  = Target{GVR: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}}
+)
+
+type preferredResourcesDiscovery struct {
+	*fakediscovery.FakeDiscovery
+	resources []*metav1.APIResourceList
+}
+
+func (d *preferredResourcesDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return d.resources, nil
+}
+
+func TestDiscoverTargets(t *testing.T) {
+	allVerbs := metav1.Verbs{"get", "list", "watch", "patch"}
+	discoveryCl := &preferredResourcesDiscovery{
+		FakeDiscovery: &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}},
+		resources: []*metav1.APIResourceList{
+			{GroupVersion: "v1", APIResources: []metav1.APIResource{
+				{Name: "configmaps", Namespaced: true, Verbs: allVerbs},
+				{Name: "namespaces", Verbs: allVerbs},
+				{Name: "pods/status", Namespaced: true, Verbs: allVerbs},
+				{Name: "bindings", Namespaced: true, Verbs: metav1.Verbs{"create"}},
+			}},
+			{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+				{Name: "deployments", Namespaced: true, Verbs: allVerbs},
+			}},
+		},
+	}
+
+	targets, err := DiscoverTargets(discoveryCl, ResourceFilter{})
+	require.NoError(t, err)
+	require.Equal(t, []Target{configMapsTarget, deploymentsTarget, namespacesTarget}, targets)
+
+	targets, err = DiscoverTargets(discoveryCl, ResourceFilter{Include: []string{"deployments.apps", "namespaces"}})
+	require.NoError(t, err)
+	require.Equal(t, []Target{deploymentsTarget, namespacesTarget}, targets)
+
+	targets, err = DiscoverTargets(discoveryCl, ResourceFilter{Exclude: []string{"deployments"}})
+	require.NoError(t, err)
+	require.Equal(t, []Target{configMapsTarget, namespacesTarget}, targets)
+}
+
+func TestChangeMergePatch(t *testing.T) {
+	change := Change{
+		SetAnnotations:    map[string]string{"example.com/owner": "team"},
+		RemoveAnnotations: []string{"example.com/legacy"},
+		RemoveLabels:      []string{"stale"},
+	}
+
+	patch, err := change.MergePatch()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"metadata":{"annotations":{"example.com/owner":"team","example.com/legacy":null},"labels":{"stale":null}}}`, string(patch))
+
+	obj := newObject("v1", "ConfigMap", "default", "cm")
+	require.False(t, change.AppliedTo(obj))
+	obj.SetAnnotations(map[string]string{"example.com/owner": "team"})
+	require.True(t, change.AppliedTo(obj))
+	obj.SetLabels(map[string]string{"stale": ""})
+	require.False(t, change.AppliedTo(obj))
+}
+
+func TestRun(t *testing.T) {
+	applied := newObject("v1", "ConfigMap", "default", "applied")
+	applied.SetLabels(map[string]string{"tier": "backend"})
+	client := newFakeClient(
+		newObject("v1", "ConfigMap", "default", "first"),
+		newObject("v1", "ConfigMap", "kube-system", "second"),
+		applied,
+		newObject("v1", "Namespace", "", "default"),
+	)
+	targets := []Target{configMapsTarget, namespacesTarget}
+	change := Change{SetLabels: map[string]string{"tier": "backend"}}
+
+	result, err := Run(context.Background(), client, targets, Options{Change: change, DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Total)
+	require.Equal(t, 3, result.Patched)
+	requireLabels(t, client, configMapsTarget, "default", "first", nil)
+
+	result, err = Run(context.Background(), client, targets, Options{Change: change, Namespaces: []string{"default"}})
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Patched)
+	require.Empty(t, result.Failed)
+	requireLabels(t, client, configMapsTarget, "default", "first", map[string]string{"tier": "backend"})
+	requireLabels(t, client, configMapsTarget, "kube-system", "second", nil)
+	requireLabels(t, client, namespacesTarget, "", "default", map[string]string{"tier": "backend"})
+}
+
+func TestRunSkipsForbiddenResources(t *testing.T) {
+	client := newFakeClient(
+		newObject("v1", "ConfigMap", "default", "cm"),
+		newObject("v1", "Namespace", "", "default"),
+	)
+	client.PrependReactor("list", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(configMapsTarget.GVR.GroupResource(), "", nil)
+	})
+	change := Change{SetLabels: map[string]string{"tier": "backend"}}
+	out := &bytes.Buffer{}
+
+	result, err := Run(context.Background(), client, []Target{configMapsTarget, namespacesTarget}, Options{Change: change, Out: out})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Patched)
+	require.Len(t, result.Forbidden, 1)
+	require.Equal(t, configMapsTarget, result.Forbidden[0].Target)
+	require.Contains(t, out.String(), "Skipping configmaps as it cannot be listed")
+	requireLabels(t, client, namespacesTarget, "", "default", map[string]string{"tier": "backend"})
+}
+
+func TestApplyFallsBackOnForbidden(t *testing.T) {
+	obj := newObject("v1", "ConfigMap", "default", "cm")
+	client := newFakeClient(obj)
+	client.PrependReactor("patch", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(configMapsTarget.GVR.GroupResource(), "cm", nil)
+	})
+	refs := []ObjectRef{{Target: configMapsTarget, Namespace: "default", Name: "cm"}}
+	change := Change{SetAnnotations: map[string]string{"example.com/owner": "team"}}
+
+	result, err := Apply(context.Background(), client, refs, Options{Change: change})
+	require.NoError(t, err)
+	require.Len(t, result.Failed, 1)
+	require.True(t, apierrors.IsForbidden(result.Failed[0].Err))
+
+	fallbackClient := newFakeClient(obj)
+	out := &bytes.Buffer{}
+	result, err = Apply(context.Background(), client, refs, Options{Change: change, FallbackClient: fallbackClient, Out: out})
+	require.NoError(t, err)
+	require.Empty(t, result.Failed)
+	require.Equal(t, 1, result.Patched)
+	require.Contains(t, out.String(), "Processed 1 / 1 objects")
+}
+
+func TestRetryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.txt")
+	failed := []FailedObject{
+		{Ref: ObjectRef{Target: deploymentsTarget, Namespace: "d8-system", Name: "deckhouse"}},
+		{Ref: ObjectRef{Target: namespacesTarget, Name: "default"}},
+	}
+
+	require.NoError(t, WriteRetryFile(path, failed))
+	refs, err := ReadRetryFile(path, []Target{deploymentsTarget, namespacesTarget})
+	require.NoError(t, err)
+	require.Equal(t, []ObjectRef{failed[0].Ref, failed[1].Ref}, refs)
+
+	_, err = ReadRetryFile(path, []Target{namespacesTarget})
+	require.ErrorContains(t, err, `unknown resource "deployments.apps"`)
+}
+
+func newObject(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func newFakeClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		configMapsTarget.GVR:  "ConfigMapList",
+		deploymentsTarget.GVR: "DeploymentList",
+		namespacesTarget.GVR:  "NamespaceList",
+	}, objects...)
+}
+
+func requireLabels(t *testing.T, client *dynamicfake.FakeDynamicClient, target Target, namespace, name string, expected map[string]string) {
+	t.Helper()
+	obj, err := resourceClient(client, target, namespace).Get(context.Background(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, expected, obj.GetLabels())
+}
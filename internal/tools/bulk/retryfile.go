@@ -0,0 +1,89 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bulk
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// WriteRetryFile saves references to failed objects, one per line, so that they can be processed again later.
+func WriteRetryFile(path string, failed []FailedObject) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Create retry file: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, obj := range failed {
+		if _, err = fmt.Fprintln(w, obj.Ref.String()); err != nil {
+			return fmt.Errorf("Write retry file: %w", err)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("Write retry file: %w", err)
+	}
+	return nil
+}
+
+// ReadRetryFile loads object references saved by WriteRetryFile.
+// Targets are used to resolve resource names into API versions and scopes.
+func ReadRetryFile(path string, targets []Target) ([]ObjectRef, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Open retry file: %w", err)
+	}
+	defer f.Close()
+
+	targetsByName := make(map[string]Target, len(targets))
+	for _, target := range targets {
+		targetsByName[target.String()] = target
+	}
+
+	refs := make([]ObjectRef, 0)
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		resource, objectName, found := strings.Cut(line, " ")
+		if !found {
+			return nil, fmt.Errorf("Retry file line %d: expected \"<resource> [<namespace>/]<name>\", got %q", lineNum, line)
+		}
+		target, found := targetsByName[schema.ParseGroupResource(resource).String()]
+		if !found {
+			return nil, fmt.Errorf("Retry file line %d: unknown resource %q", lineNum, resource)
+		}
+
+		ref := ObjectRef{Target: target, Name: objectName}
+		if namespace, name, isNamespaced := strings.Cut(objectName, "/"); isNamespaced {
+			ref.Namespace, ref.Name = namespace, name
+		}
+		refs = append(refs, ref)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Read retry file: %w", err)
+	}
+	return refs, nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotate_bulk
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/rest"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/deckhouse/deckhouse-cli/internal/output"
+	"github.com/deckhouse/deckhouse-cli/internal/tools/bulk"
+	"github.com/deckhouse/deckhouse-cli/internal/utilk8s"
+)
+
+var annotateBulkLong = templates.LongDesc(`
+Add or remove annotations and labels on all objects in the cluster.
+
+This command discovers every resource type that can be listed and patched,
+optionally filtered by resource name, namespace and label selector,
+and patches all matching objects that do not have the requested change applied yet.
+
+Objects that failed to be patched are saved to the retry file,
+which can be passed back with --from-retry-file to process only them.
+If patching is forbidden for the current user, the patch is retried impersonating --fallback-as user.
+Resources that the current user is not allowed to list are skipped and reported at the end.
+
+© Flant JSC 2024`)
+
+var annotateBulkExample = templates.Examples(`
+  # Preview which objects would get the annotation
+  d8 tools annotate-bulk --annotation example.com/owner=platform --dry-run
+
+  # Remove a label from deployments and statefulsets in the d8-system namespace
+  d8 tools annotate-bulk --remove-label legacy --resources deployments.apps,statefulsets.apps -n d8-system
+
+  # Retry objects that failed during the previous run
+  d8 tools annotate-bulk --annotation example.com/owner=platform --from-retry-file annotate-bulk-failed.txt`)
+
+func NewCommand() *cobra.Command {
+	annotateBulkCmd := &cobra.Command{
+		Use:           "annotate-bulk",
+		Short:         "Add or remove annotations and labels on all objects in the cluster",
+		Long:          annotateBulkLong,
+		Example:       annotateBulkExample,
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE:          annotateBulk,
+	}
+
+	addFlags(annotateBulkCmd.Flags())
+	return annotateBulkCmd
+}
+
+func annotateBulk(cmd *cobra.Command, _ []string) error {
+	change, err := parseAndValidateFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	kubeconfigPath, err := cmd.Flags().GetString("kubeconfig")
+	if err != nil {
+		return fmt.Errorf("Failed to setup Kubernetes client: %w", err)
+	}
+	config, kubeCl, err := utilk8s.SetupK8sClientSet(kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("Failed to setup Kubernetes client: %w", err)
+	}
+	dynamicCl, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("Failed to setup Kubernetes client: %w", err)
+	}
+
+	out := output.FromCommand(cmd)
+	logger := out.Logger()
+	opts := bulk.Options{
+		Change:        change,
+		Namespaces:    namespaces,
+		LabelSelector: labelSelector,
+		DryRun:        dryRun,
+		Concurrency:   concurrency,
+		Out:           cmd.ErrOrStderr(),
+	}
+	if fallbackUser != "" {
+		if opts.FallbackClient, err = impersonatingClient(config, fallbackUser); err != nil {
+			return fmt.Errorf("Failed to setup impersonating Kubernetes client: %w", err)
+		}
+	}
+
+	targets, err := bulk.DiscoverTargets(kubeCl.Discovery(), bulk.ResourceFilter{
+		Include: includedResources,
+		Exclude: excludedResources,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var result *bulk.Result
+	if fromRetryFile != "" {
+		refs, err := bulk.ReadRetryFile(fromRetryFile, targets)
+		if err != nil {
+			return err
+		}
+		logger.InfoF("Processing %d objects from %s", len(refs), fromRetryFile)
+		result, err = bulk.Apply(ctx, dynamicCl, refs, opts)
+		if err != nil {
+			return err
+		}
+	} else {
+		logger.InfoF("Processing objects of %d resource types", len(targets))
+		result, err = bulk.Run(ctx, dynamicCl, targets, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	verb := "Patched"
+	if dryRun {
+		verb = "Would patch"
+	}
+	logger.InfoF("%s %d objects, %d already up to date, %d failed", verb, result.Patched, result.Skipped, len(result.Failed))
+	for _, forbidden := range result.Forbidden {
+		out.Warnf("Objects of %s were left unchanged as listing them is forbidden", forbidden)
+	}
+
+	if len(result.Failed) == 0 {
+		return nil
+	}
+	if err = bulk.WriteRetryFile(retryFile, result.Failed); err != nil {
+		return err
+	}
+	return fmt.Errorf("Failed to patch %d objects, they are saved to %s, use --from-retry-file to process them again", len(result.Failed), retryFile)
+}
+
+func impersonatingClient(config *rest.Config, user string) (dynamic.Interface, error) {
+	impersonatingConfig := rest.CopyConfig(config)
+	impersonatingConfig.Impersonate = rest.ImpersonationConfig{UserName: user}
+	return dynamic.NewForConfig(impersonatingConfig)
+}
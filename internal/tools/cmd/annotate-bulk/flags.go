@@ -0,0 +1,124 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotate_bulk
+
+import (
+	"github.com/spf13/pflag"
+
+	"github.com/deckhouse/deckhouse-cli/internal/tools/bulk"
+)
+
+const defaultFallbackUser = "system:serviceaccount:d8-system:deckhouse"
+
+var (
+	annotationsToSet    []string
+	annotationsToRemove []string
+	labelsToSet         []string
+	labelsToRemove      []string
+
+	includedResources []string
+	excludedResources []string
+	namespaces        []string
+	labelSelector     string
+
+	dryRun        bool
+	concurrency   int
+	retryFile     string
+	fromRetryFile string
+	fallbackUser  string
+)
+
+func addFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringArrayVar(
+		&annotationsToSet,
+		"annotation",
+		nil,
+		"Annotation to set in key=value form. May be repeated.",
+	)
+	flagSet.StringArrayVar(
+		&annotationsToRemove,
+		"remove-annotation",
+		nil,
+		"Key of the annotation to remove. May be repeated.",
+	)
+	flagSet.StringArrayVar(
+		&labelsToSet,
+		"label",
+		nil,
+		"Label to set in key=value form. May be repeated.",
+	)
+	flagSet.StringArrayVar(
+		&labelsToRemove,
+		"remove-label",
+		nil,
+		"Key of the label to remove. May be repeated.",
+	)
+	flagSet.StringSliceVar(
+		&includedResources,
+		"resources",
+		nil,
+		"Process only these resources, e.g. \"pods,deployments.apps\". All listable resources are processed by default.",
+	)
+	flagSet.StringSliceVar(
+		&excludedResources,
+		"exclude-resources",
+		[]string{"events", "events.events.k8s.io"},
+		"Do not process these resources.",
+	)
+	flagSet.StringSliceVarP(
+		&namespaces,
+		"namespace", "n",
+		nil,
+		"Process namespaced objects only in these namespaces. Cluster-scoped objects are always processed. All namespaces by default.",
+	)
+	flagSet.StringVarP(
+		&labelSelector,
+		"selector", "l",
+		"",
+		"Process only objects matching this label selector.",
+	)
+	flagSet.BoolVar(
+		&dryRun,
+		"dry-run",
+		false,
+		"Only print objects that would be changed.",
+	)
+	flagSet.IntVar(
+		&concurrency,
+		"concurrency",
+		bulk.DefaultConcurrency,
+		"Number of objects patched in parallel.",
+	)
+	flagSet.StringVar(
+		&retryFile,
+		"retry-file",
+		"annotate-bulk-failed.txt",
+		"File to save references to objects that failed to be patched.",
+	)
+	flagSet.StringVar(
+		&fromRetryFile,
+		"from-retry-file",
+		"",
+		"Process only objects listed in this retry file instead of discovering them in the cluster.",
+	)
+	flagSet.StringVar(
+		&fallbackUser,
+		"fallback-as",
+		defaultFallbackUser,
+		"User to impersonate when patching an object is forbidden for current user. Empty value disables impersonation.",
+	)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotate_bulk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/deckhouse/deckhouse-cli/internal/tools/bulk"
+)
+
+func parseAndValidateFlags(cmd *cobra.Command) (bulk.Change, error) {
+	if err := validateKubeconfig(cmd); err != nil {
+		return bulk.Change{}, err
+	}
+
+	var err error
+	change := bulk.Change{
+		RemoveAnnotations: annotationsToRemove,
+		RemoveLabels:      labelsToRemove,
+	}
+	if change.SetAnnotations, err = parseKeyValues("--annotation", annotationsToSet); err != nil {
+		return bulk.Change{}, err
+	}
+	if change.SetLabels, err = parseKeyValues("--label", labelsToSet); err != nil {
+		return bulk.Change{}, err
+	}
+	for _, key := range append(annotationsToRemove, labelsToRemove...) {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return bulk.Change{}, fmt.Errorf("Invalid key %q: %s", key, strings.Join(errs, "; "))
+		}
+	}
+	if change.IsEmpty() {
+		return bulk.Change{}, errors.New("Nothing to do, specify at least one of --annotation, --remove-annotation, --label or --remove-label")
+	}
+
+	if _, err = labels.Parse(labelSelector); err != nil {
+		return bulk.Change{}, fmt.Errorf("Invalid --selector: %w", err)
+	}
+	if concurrency < 1 {
+		return bulk.Change{}, errors.New("--concurrency must be at least 1")
+	}
+	if fromRetryFile != "" {
+		if _, err = os.Stat(fromRetryFile); err != nil {
+			return bulk.Change{}, fmt.Errorf("Invalid --from-retry-file: %w", err)
+		}
+	}
+
+	return change, nil
+}
+
+func parseKeyValues(flagName string, pairs []string) (map[string]string, error) {
+	result := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("Invalid %s %q: expected key=value", flagName, pair)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("Invalid %s key %q: %s", flagName, key, strings.Join(errs, "; "))
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+func validateKubeconfig(cmd *cobra.Command) error {
+	kubeconfigPath, err := cmd.Flags().GetString("kubeconfig")
+	if err != nil {
+		return fmt.Errorf("Failed to setup Kubernetes client: %w", err)
+	}
+
+	stats, err := os.Stat(kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("Invalid --kubeconfig: %w", err)
+	}
+	if !stats.Mode().IsRegular() {
+		return fmt.Errorf("Invalid --kubeconfig: %s is not a regular file", kubeconfigPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tools
+
+import (
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	annotate_bulk "github.com/deckhouse/deckhouse-cli/internal/tools/cmd/annotate-bulk"
+)
+
+var toolsLong = templates.LongDesc(`
+Maintenance utilities for Deckhouse Kubernetes Platform clusters
+
+© Flant JSC 2024`)
+
+func NewCommand() *cobra.Command {
+	toolsCmd := &cobra.Command{
+		Use:   "tools",
+		Short: "Maintenance utilities for Deckhouse Kubernetes Platform clusters",
+		Long:  toolsLong,
+	}
+
+	addPersistentFlags(toolsCmd.PersistentFlags())
+
+	toolsCmd.AddCommand(
+		annotate_bulk.NewCommand(),
+	)
+
+	return toolsCmd
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utilk8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Permission describes a single verb on a resource that command requires to run.
+// Empty Namespace means cluster-wide or all namespaces, empty Name means any object.
+type Permission struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	Namespace   string
+	Name        string
+}
+
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	if p.Name != "" {
+		resource += " " + p.Name
+	}
+
+	if p.Namespace != "" {
+		return fmt.Sprintf("%s %s in namespace %s", p.Verb, resource, p.Namespace)
+	}
+	return fmt.Sprintf("%s %s", p.Verb, resource)
+}
+
+// CheckPermissions asks API server whether current user is allowed to perform all required actions
+// via SelfSubjectAccessReview and returns an error listing every missing permission, if any.
+func CheckPermissions(ctx context.Context, kubeCl kubernetes.Interface, required ...Permission) error {
+	missing := make([]string, 0)
+	for _, perm := range required {
+		review, err := kubeCl.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:        perm.Verb,
+					Group:       perm.Group,
+					Resource:    perm.Resource,
+					Subresource: perm.Subresource,
+					Namespace:   perm.Namespace,
+					Name:        perm.Name,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("Check access to %s: %w", perm, err)
+		}
+
+		if !review.Status.Allowed {
+			missing = append(missing, perm.String())
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("Insufficient permissions, missing:\n  - %s", strings.Join(missing, "\n  - "))
+	}
+
+	return nil
+}
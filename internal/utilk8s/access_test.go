@@ -0,0 +1,52 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utilk8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	kubeCl := fake.NewSimpleClientset()
+	kubeCl.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = review.Spec.ResourceAttributes.Verb == "get"
+		return true, review, nil
+	})
+
+	err := CheckPermissions(context.Background(), kubeCl,
+		Permission{Verb: "get", Resource: "pods", Namespace: "kube-system"},
+	)
+	require.NoError(t, err)
+
+	err = CheckPermissions(context.Background(), kubeCl,
+		Permission{Verb: "get", Resource: "pods", Namespace: "kube-system"},
+		Permission{Verb: "create", Resource: "pods", Subresource: "exec", Namespace: "kube-system"},
+		Permission{Verb: "list", Group: "storage.k8s.io", Resource: "storageclasses"},
+	)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "create pods/exec in namespace kube-system")
+	require.Contains(t, err.Error(), "list storageclasses.storage.k8s.io")
+	require.NotContains(t, err.Error(), "get pods")
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cli exposes d8 commands and operations for embedding into other tools,
+// like installer images or appliances, without shelling out to the d8 binary.
+package cli
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+
+	backup "github.com/deckhouse/deckhouse-cli/internal/backup/cmd"
+	mirror "github.com/deckhouse/deckhouse-cli/internal/mirror/cmd"
+	platform "github.com/deckhouse/deckhouse-cli/internal/platform/cmd"
+)
+
+// IOStreams are the streams embedded commands read from and write to. Nil streams fall back to process ones.
+type IOStreams struct {
+	In     io.Reader
+	Out    io.Writer
+	ErrOut io.Writer
+}
+
+// NewMirrorCommand returns "mirror" command tree, the same as "d8 mirror".
+func NewMirrorCommand(streams IOStreams) *cobra.Command {
+	return withStreams(mirror.NewCommand(), streams)
+}
+
+// NewBackupCommand returns "backup" command tree, the same as "d8 backup".
+func NewBackupCommand(streams IOStreams) *cobra.Command {
+	return withStreams(backup.NewCommand(), streams)
+}
+
+// NewPlatformCommand returns "platform" command tree, the same as "d8 platform".
+func NewPlatformCommand(streams IOStreams) *cobra.Command {
+	return withStreams(platform.NewCommand(), streams)
+}
+
+func withStreams(cmd *cobra.Command, streams IOStreams) *cobra.Command {
+	if streams.In != nil {
+		cmd.SetIn(streams.In)
+	}
+	if streams.Out != nil {
+		cmd.SetOut(streams.Out)
+	}
+	if streams.ErrOut != nil {
+		cmd.SetErr(streams.ErrOut)
+	}
+	return cmd
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/pull"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/modules"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/operations"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+)
+
+// NewLogger creates logger for mirroring operations that writes to w.
+func NewLogger(w io.Writer, level slog.Level) contexts.Logger {
+	return log.NewSLoggerWithWriter(w, level)
+}
+
+// PullDeckhouse resolves Deckhouse releases to mirror and pulls them into the bundle at pullCtx.BundlePath,
+// the same way "d8 mirror pull" does. If modulesSpec is given, only modules listed in it are pulled in their pinned versions,
+// like with --modules-spec. All output goes to pullCtx.Logger.
+func PullDeckhouse(pullCtx *contexts.PullContext, modulesSpec *modules.Filter) error {
+	if pullCtx.Logger == nil {
+		return errors.New("logger is required")
+	}
+	if pullCtx.UnpackedImagesPath == "" {
+		return errors.New("working directory for pulled images is required")
+	}
+
+	versions, _, err := pull.LookupVersionsToPull(pullCtx)
+	if err != nil {
+		return err
+	}
+	if err = pull.PullDeckhouseToLocalFS(pullCtx, versions, modulesSpec); err != nil {
+		return err
+	}
+	return bundle.Pack(pullCtx)
+}
+
+// PushDeckhouse pushes the bundle at pushCtx.BundlePath to the registry, the same way "d8 mirror push" does.
+// Bundle may either be a tar file or its chunks, which are unpacked into pushCtx.UnpackedImagesPath, or an unpacked directory.
+func PushDeckhouse(ctx context.Context, pushCtx *contexts.PushContext) error {
+	if pushCtx.Logger == nil {
+		return errors.New("logger is required")
+	}
+	if pushCtx.Parallelism.Images == 0 {
+		pushCtx.Parallelism = contexts.DefaultParallelism
+	}
+
+	switch filepath.Ext(pushCtx.BundlePath) {
+	case ".tar", ".chunk":
+		if pushCtx.UnpackedImagesPath == "" {
+			return errors.New("working directory to unpack bundle into is required")
+		}
+		if err := bundle.Unpack(&pushCtx.BaseContext); err != nil {
+			return fmt.Errorf("Unpack bundle: %w", err)
+		}
+	default:
+		bundleStat, err := os.Stat(pushCtx.BundlePath)
+		if err != nil {
+			return err
+		}
+		if !bundleStat.IsDir() {
+			return errors.New("bundle is not a tarball or directory")
+		}
+		pushCtx.UnpackedImagesPath = pushCtx.BundlePath
+		if err = bundle.ValidateUnpackedBundle(pushCtx); err != nil {
+			return fmt.Errorf("Invalid bundle: %w", err)
+		}
+	}
+
+	return operations.PushDeckhouseToRegistryContext(ctx, pushCtx)
+}
@@ -30,6 +30,8 @@ import (
 
 	"github.com/deckhouse/deckhouse-cli/internal/mirror/chunked"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/s3"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/workdir"
 )
 
 func Unpack(mirrorCtx *contexts.BaseContext) error {
@@ -40,23 +42,22 @@ func UnpackContext(ctx context.Context, mirrorCtx *contexts.BaseContext) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
+	if s3.IsURL(mirrorCtx.BundlePath) {
+		bundleStream, err := openS3BundleStream(ctx, mirrorCtx.BundlePath)
+		if err != nil {
+			return fmt.Errorf("read tar bundle: %w", err)
+		}
+		defer bundleStream.Close()
+		return unpackTar(ctx, mirrorCtx, bundleStream)
+	}
 
-	bundleDir := filepath.Dir(mirrorCtx.BundlePath)
-	catalog, err := os.ReadDir(bundleDir)
+	bundleFiles, err := FindBundleFiles(mirrorCtx.BundlePath)
 	if err != nil {
-		return fmt.Errorf("read tar bundle directory: %w", err)
+		return fmt.Errorf("read tar bundle: %w", err)
 	}
-	streams := make([]io.Reader, 0)
-	for _, entry := range catalog {
-		if err = ctx.Err(); err != nil {
-			return err
-		}
-
-		fileName := entry.Name()
-		if !entry.Type().IsRegular() || filepath.Ext(fileName) != ".chunk" {
-			continue
-		}
-		chunkStream, err := os.Open(filepath.Join(bundleDir, fileName))
+	streams := make([]io.Reader, 0, len(bundleFiles))
+	for _, bundleFile := range bundleFiles {
+		chunkStream, err := os.Open(bundleFile)
 		if err != nil {
 			return fmt.Errorf("open bundle chunk for reading: %w", err)
 		}
@@ -64,17 +65,18 @@ func UnpackContext(ctx context.Context, mirrorCtx *contexts.BaseContext) error {
 		streams = append(streams, chunkStream)
 	}
 
-	bundleStream := io.NopCloser(io.MultiReader(streams...))
-	if len(streams) == 0 {
-		bundleStream, err = os.Open(mirrorCtx.BundlePath)
-		if err != nil {
-			return fmt.Errorf("read tar bundle: %w", err)
-		}
+	return unpackTar(ctx, mirrorCtx, io.MultiReader(streams...))
+}
+
+func unpackTar(ctx context.Context, mirrorCtx *contexts.BaseContext, bundleStream io.Reader) error {
+	bundleStream, err := decryptStream(bundleStream, mirrorCtx.BundleDecryptionKeys)
+	if err != nil {
+		return err
 	}
 
 	tarReader := tar.NewReader(bundleStream)
 	for {
-		if err = ctx.Err(); err != nil {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
@@ -82,6 +84,9 @@ func UnpackContext(ctx context.Context, mirrorCtx *contexts.BaseContext) error {
 		if errors.Is(err, io.EOF) {
 			break
 		}
+		if err != nil {
+			return fmt.Errorf("read tar bundle: %w", err)
+		}
 		writePath := filepath.Join(
 			mirrorCtx.UnpackedImagesPath,
 			filepath.Clean(tarHdr.Name),
@@ -104,13 +109,51 @@ func UnpackContext(ctx context.Context, mirrorCtx *contexts.BaseContext) error {
 		}
 	}
 
-	return nil
+	return drain(bundleStream)
 }
 
+// PackingMarkerSuffix is appended to bundle path to get the path Pack leaves workdir.Marker at while bundle is written.
+// Marker is removed once bundle is complete, so that bundles left unfinished by failed runs can be found and removed.
+const PackingMarkerSuffix = ".d8-packing"
+
 func Pack(mirrorCtx *contexts.PullContext) error {
+	resumesPacking := false
+	if !s3.IsURL(mirrorCtx.BundlePath) {
+		_, err := os.Stat(mirrorCtx.BundlePath + PackingMarkerSuffix)
+		resumesPacking = err == nil
+		if err = workdir.WriteMarker(mirrorCtx.BundlePath+PackingMarkerSuffix, false); err != nil {
+			return fmt.Errorf("write tar bundle: %w", err)
+		}
+	}
+
 	var tarStream io.WriteCloser
-	if mirrorCtx.BundleChunkSize != 0 {
-		chunkWriter := chunked.NewChunkedFileWriter(mirrorCtx.BundleChunkSize, filepath.Dir(mirrorCtx.BundlePath), filepath.Base(mirrorCtx.BundlePath))
+	var resumedOffset int64
+	packedBlobs := map[string]struct{}{}
+	if s3.IsURL(mirrorCtx.BundlePath) {
+		s3Writer, err := newS3BundleWriter(mirrorCtx.Run.Context(), mirrorCtx.BundlePath, mirrorCtx.BundleChunkSize)
+		if err != nil {
+			return fmt.Errorf("write tar bundle: %w", err)
+		}
+		defer s3Writer.Abort()
+		tarStream = s3Writer
+	} else if mirrorCtx.BundleChunkSize != 0 {
+		// Files are removed once packed, so chunks left by interrupted Pack are continued with the files that are left.
+		// Encrypted stream cannot be continued, it is written anew.
+		var err error
+		if resumesPacking && len(mirrorCtx.BundleRecipients) == 0 {
+			if resumedOffset, packedBlobs, err = packedOffset(mirrorCtx.Run.Context(), mirrorCtx.BundlePath); err != nil {
+				return fmt.Errorf("resume writing tar bundle: %w", err)
+			}
+		}
+		chunkWriter, err := chunked.NewResumedChunkedFileWriter(
+			mirrorCtx.BundleChunkSize,
+			filepath.Dir(mirrorCtx.BundlePath),
+			filepath.Base(mirrorCtx.BundlePath),
+			resumedOffset,
+		)
+		if err != nil {
+			return fmt.Errorf("write tar bundle: %w", err)
+		}
 		tarStream = chunkWriter
 	} else {
 		tarFile, err := os.Create(mirrorCtx.BundlePath)
@@ -119,9 +162,16 @@ func Pack(mirrorCtx *contexts.PullContext) error {
 		}
 		tarStream = tarFile
 	}
+	if len(mirrorCtx.BundleRecipients) > 0 {
+		encryptedStream, err := encryptStream(tarStream, mirrorCtx.BundleRecipients)
+		if err != nil {
+			return fmt.Errorf("write tar bundle: %w", err)
+		}
+		tarStream = encryptedStream
+	}
 
 	tarWriter := tar.NewWriter(tarStream)
-	if err := filepath.Walk(mirrorCtx.UnpackedImagesPath, packFunc(&mirrorCtx.BaseContext, tarWriter)); err != nil {
+	if err := filepath.Walk(mirrorCtx.UnpackedImagesPath, packFunc(&mirrorCtx.BaseContext, tarWriter, packedBlobs)); err != nil {
 		return fmt.Errorf("pack mirrored images into tar: %w", err)
 	}
 
@@ -131,16 +181,70 @@ func Pack(mirrorCtx *contexts.PullContext) error {
 	if err := tarStream.Close(); err != nil {
 		return fmt.Errorf("close tar: %w", err)
 	}
+	if resumedOffset > 0 && mirrorCtx.Logger != nil {
+		mirrorCtx.Logger.InfoF("Packing of interrupted bundle was resumed, %.1f MiB packed before were kept", float64(resumedOffset)/1024/1024)
+	}
 
+	if !s3.IsURL(mirrorCtx.BundlePath) {
+		if err := os.Remove(mirrorCtx.BundlePath + PackingMarkerSuffix); err != nil {
+			return fmt.Errorf("remove packing marker: %w", err)
+		}
+	}
 	return nil
 }
 
-func packFunc(mirrorCtx *contexts.BaseContext, out *tar.Writer) filepath.WalkFunc {
+// packedOffset returns offset right after the last file that interrupted Pack has completely written into bundle chunks,
+// and paths of blobs written before it.
+func packedOffset(ctx context.Context, bundlePath string) (int64, map[string]struct{}, error) {
+	packedBlobs := map[string]struct{}{}
+	chunkPaths, err := chunked.FindChunks(filepath.Dir(bundlePath), filepath.Base(bundlePath))
+	if err != nil {
+		return 0, nil, err
+	}
+	bundleReader, err := openChunks(chunkPaths)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer bundleReader.Close()
+
+	stream := io.NewSectionReader(bundleReader, 0, bundleReader.size)
+	tarReader := tar.NewReader(stream)
+	var offset int64
+	for ctx.Err() == nil {
+		// Headers of file not written completely cannot be read, while its contents are only checked to be there.
+		hdr, err := tarReader.Next()
+		if err != nil {
+			break
+		}
+		dataOffset, _ := stream.Seek(0, io.SeekCurrent)
+		end := dataOffset + hdr.Size
+		if padding := end % 512; padding != 0 {
+			end += 512 - padding
+		}
+		if end > bundleReader.size {
+			break
+		}
+		offset = end
+		if _, isBlob := blobDigest(hdr.Name); isBlob {
+			packedBlobs[hdr.Name] = struct{}{}
+		}
+	}
+	return offset, packedBlobs, ctx.Err()
+}
+
+// packFunc writes files into tar and removes them. Blobs listed in packedBlobs are already in the tar, they are only removed.
+func packFunc(mirrorCtx *contexts.BaseContext, out *tar.Writer, packedBlobs map[string]struct{}) filepath.WalkFunc {
 	return func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if path == mirrorCtx.BundlePath || info.IsDir() {
+		if path == mirrorCtx.BundlePath || info.IsDir() || info.Name() == workdir.MarkerFile {
+			return nil
+		}
+
+		pathInTar := filepath.ToSlash(strings.TrimPrefix(path, mirrorCtx.UnpackedImagesPath+string(os.PathSeparator)))
+		if _, isPacked := packedBlobs[pathInTar]; isPacked {
+			_ = os.Remove(path)
 			return nil
 		}
 
@@ -149,9 +253,8 @@ func packFunc(mirrorCtx *contexts.BaseContext, out *tar.Writer) filepath.WalkFun
 			return fmt.Errorf("read file: %w", err)
 		}
 
-		pathInTar := strings.TrimPrefix(path, mirrorCtx.UnpackedImagesPath+string(os.PathSeparator))
 		err = out.WriteHeader(&tar.Header{
-			Name:    filepath.ToSlash(pathInTar),
+			Name:    pathInTar,
 			Size:    info.Size(),
 			Mode:    int64(info.Mode()),
 			ModTime: info.ModTime(),
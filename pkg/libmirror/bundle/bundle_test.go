@@ -18,6 +18,8 @@ package bundle
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
@@ -114,6 +116,92 @@ func TestChunkedBundlePackingAndUnpacking(t *testing.T) {
 	require.Equal(t, expectedFiles, resultingFiles, "Expected to find same file trees under source and target dirs")
 }
 
+func TestInterruptedChunkedPackingIsResumed(t *testing.T) {
+	sourceDir, packFromDir, unpackToDir := t.TempDir(), t.TempDir(), t.TempDir()
+	bundlePath := filepath.Join(t.TempDir(), "d8.tar")
+	fillTestLayout(t, sourceDir)
+	pullCtx := &contexts.PullContext{
+		BaseContext:     contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: packFromDir},
+		BundleChunkSize: 64 * 1024,
+	}
+
+	require.NoError(t, os.CopyFS(packFromDir, os.DirFS(sourceDir)))
+	require.NoError(t, Pack(pullCtx))
+	completeChunks := findAllPaths(t, filepath.Dir(bundlePath))
+	completeSize := bundleSize(t, bundlePath)
+
+	// Interrupt packing in the middle of the third blob, then pull the files removed by packing again.
+	chunks, err := FindBundleFiles(bundlePath)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(chunks[8], 1000))
+	for _, chunkPath := range chunks[9:] {
+		require.NoError(t, os.Remove(chunkPath))
+	}
+	require.NoError(t, os.WriteFile(bundlePath+PackingMarkerSuffix, nil, 0o644))
+	require.NoError(t, os.CopyFS(packFromDir, os.DirFS(sourceDir)))
+
+	require.NoError(t, Pack(pullCtx))
+	require.NoFileExists(t, bundlePath+PackingMarkerSuffix)
+	require.Equal(t, completeChunks, findAllPaths(t, filepath.Dir(bundlePath)))
+	require.Equal(t, completeSize, bundleSize(t, bundlePath), "blobs packed before interruption should not be packed again")
+
+	require.NoError(t, Unpack(&contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: unpackToDir}))
+	require.Equal(t, findAllPaths(t, sourceDir), findAllPaths(t, unpackToDir))
+	for _, filePath := range findAllPaths(t, sourceDir) {
+		if info, err := os.Stat(filepath.Join(sourceDir, filePath)); err != nil || info.IsDir() {
+			continue
+		}
+		want, err := os.ReadFile(filepath.Join(sourceDir, filePath))
+		require.NoError(t, err)
+		got, err := os.ReadFile(filepath.Join(unpackToDir, filePath))
+		require.NoError(t, err)
+		require.Equal(t, want, got, filePath)
+	}
+}
+
+func TestUnpackIgnoresChunksOfOtherBundles(t *testing.T) {
+	packFromDir, unpackToDir, bundleDir := t.TempDir(), t.TempDir(), t.TempDir()
+	fillTestLayout(t, packFromDir)
+	expectedFiles := findAllPaths(t, packFromDir)
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext:     contexts.BaseContext{BundlePath: filepath.Join(bundleDir, "d8.tar"), UnpackedImagesPath: packFromDir},
+		BundleChunkSize: 256 * 1024,
+	}))
+	require.NoError(t, os.WriteFile(filepath.Join(bundleDir, "ee-d8.tar.0000.chunk"), []byte("not a tar"), 0o644))
+
+	require.NoError(t, Unpack(&contexts.BaseContext{BundlePath: filepath.Join(bundleDir, "d8.tar"), UnpackedImagesPath: unpackToDir}))
+	require.Equal(t, expectedFiles, findAllPaths(t, unpackToDir))
+}
+
+// fillTestLayout writes five 200 KiB blobs and index.json into layoutDir.
+func fillTestLayout(t *testing.T, layoutDir string) {
+	t.Helper()
+
+	blobsDir := filepath.Join(layoutDir, "blobs", "sha256")
+	require.NoError(t, os.MkdirAll(blobsDir, 0o755))
+	for i := 0; i < 5; i++ {
+		blob := make([]byte, 200*1024)
+		_, err := rand.Read(blob)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(blobsDir, fmt.Sprintf("%x", sha256.Sum256(blob))), blob, 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(layoutDir, "index.json"), []byte(`{"schemaVersion":2,"manifests":[]}`), 0o644))
+}
+
+func bundleSize(t *testing.T, bundlePath string) int64 {
+	t.Helper()
+
+	files, err := FindBundleFiles(bundlePath)
+	require.NoError(t, err)
+	var size int64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		size += info.Size()
+	}
+	return size
+}
+
 func fillTestFileTree(t *testing.T, packFromDir string) {
 	t.Helper()
 
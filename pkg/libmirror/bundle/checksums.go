@@ -0,0 +1,166 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/chunked"
+)
+
+// ChecksumsFile is written next to bundles in sha256sum format, listing SHA-256 digests of every bundle tar and chunk
+// in the directory, so that it can also be checked with "sha256sum -c checksums.sha256".
+const ChecksumsFile = "checksums.sha256"
+
+// ErrNoChecksums is returned by VerifyChecksums if checksums file does not list the bundle.
+var ErrNoChecksums = errors.New("bundle is not listed in checksums file")
+
+// ChecksumsPath returns the path to checksums file covering bundle at bundlePath.
+func ChecksumsPath(bundlePath string) string {
+	return filepath.Join(filepath.Dir(bundlePath), ChecksumsFile)
+}
+
+// WriteChecksums lists digests of all files that make up the bundle at bundlePath in checksums file next to it.
+// Entries of other bundles in the same directory are kept, so that one file covers platform and module bundles.
+// Detached signature of the previous checksums file is removed, as it does not match the new one.
+func WriteChecksums(bundlePath string) (string, error) {
+	checksumsPath := ChecksumsPath(bundlePath)
+	checksums, err := readChecksums(checksumsPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if checksums == nil {
+		checksums = map[string]string{}
+	}
+
+	manifest, err := BuildIntegrityManifest(bundlePath)
+	if err != nil {
+		return "", err
+	}
+	bundleName := filepath.Base(bundlePath)
+	for fileName := range checksums {
+		if isBundleFile(bundleName, fileName) {
+			delete(checksums, fileName)
+		}
+	}
+	for _, entry := range manifest.Files {
+		checksums[entry.Name] = strings.TrimPrefix(entry.Digest, "sha256:")
+	}
+
+	fileNames := make([]string, 0, len(checksums))
+	for fileName := range checksums {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	buf := &bytes.Buffer{}
+	for _, fileName := range fileNames {
+		fmt.Fprintf(buf, "%s  %s\n", checksums[fileName], fileName)
+	}
+
+	if err = os.WriteFile(checksumsPath, buf.Bytes(), 0o644); err != nil {
+		return "", fmt.Errorf("write checksums: %w", err)
+	}
+	if err = os.Remove(checksumsPath + ".sig"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("remove outdated checksums signature: %w", err)
+	}
+	return checksumsPath, nil
+}
+
+// VerifyChecksums checks that every file of the bundle at bundlePath is listed in checksums file next to it
+// and has the listed digest, and that no listed file of the bundle is missing. It returns the number of verified files.
+func VerifyChecksums(bundlePath string) (int, error) {
+	checksums, err := readChecksums(ChecksumsPath(bundlePath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, ErrNoChecksums
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	bundleName := filepath.Base(bundlePath)
+	expected := map[string]string{}
+	for fileName, digest := range checksums {
+		if isBundleFile(bundleName, fileName) {
+			expected[fileName] = digest
+		}
+	}
+	if len(expected) == 0 {
+		return 0, ErrNoChecksums
+	}
+
+	files, err := FindBundleFiles(bundlePath)
+	if err != nil {
+		return 0, fmt.Errorf("find bundle files: %w", err)
+	}
+	for _, file := range files {
+		fileName := filepath.Base(file)
+		want, found := expected[fileName]
+		if !found {
+			return 0, fmt.Errorf("%s is not listed in %s", fileName, ChecksumsFile)
+		}
+		entry, err := digestBundleFile(file)
+		if err != nil {
+			return 0, fmt.Errorf("digest %s: %w", file, err)
+		}
+		if got := strings.TrimPrefix(entry.Digest, "sha256:"); got != want {
+			return 0, fmt.Errorf("%s is corrupted: its SHA-256 digest is %s while %s lists %s", fileName, got, ChecksumsFile, want)
+		}
+		delete(expected, fileName)
+	}
+	for fileName := range expected {
+		return 0, fmt.Errorf("%s is listed in %s but missing", fileName, ChecksumsFile)
+	}
+	return len(files), nil
+}
+
+// readChecksums parses sha256sum output into file names mapped to hex-encoded digests.
+func readChecksums(checksumsPath string) (map[string]string, error) {
+	rawChecksums, err := os.ReadFile(checksumsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(rawChecksums))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		digest, fileName, found := strings.Cut(text, " ")
+		fileName = strings.TrimPrefix(strings.TrimLeft(fileName, " "), "*")
+		if !found || len(digest) != 64 || fileName == "" {
+			return nil, fmt.Errorf("%s: line %d is malformed", checksumsPath, line)
+		}
+		checksums[fileName] = strings.ToLower(digest)
+	}
+	return checksums, scanner.Err()
+}
+
+// isBundleFile reports whether file is the tar bundle with bundleName or one of its chunks.
+func isBundleFile(bundleName, fileName string) bool {
+	chunkOf, _, isChunk := chunked.ParseChunkName(fileName)
+	return fileName == bundleName || (isChunk && chunkOf == bundleName)
+}
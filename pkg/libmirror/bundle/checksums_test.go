@@ -0,0 +1,76 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestWriteAndVerifyChecksums(t *testing.T) {
+	packFromDir, bundleDir := t.TempDir(), t.TempDir()
+	appendTaggedImage(t, packFromDir, "v1.60.0")
+	platformPath, modulePath := filepath.Join(bundleDir, "d8.tar"), filepath.Join(bundleDir, "module-console.tar")
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext:     contexts.BaseContext{BundlePath: platformPath, UnpackedImagesPath: packFromDir},
+		BundleChunkSize: 16 * 1024,
+	}))
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext: contexts.BaseContext{BundlePath: modulePath, UnpackedImagesPath: packFromDir},
+	}))
+
+	_, err := VerifyChecksums(platformPath)
+	require.ErrorIs(t, err, ErrNoChecksums)
+
+	checksumsPath, err := WriteChecksums(platformPath)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(bundleDir, ChecksumsFile), checksumsPath)
+	_, err = VerifyChecksums(modulePath)
+	require.ErrorIs(t, err, ErrNoChecksums)
+	_, err = WriteChecksums(modulePath)
+	require.NoError(t, err)
+
+	chunks, err := FindBundleFiles(platformPath)
+	require.NoError(t, err)
+	rawChecksums, err := os.ReadFile(checksumsPath)
+	require.NoError(t, err)
+	require.Len(t, strings.Split(strings.TrimSpace(string(rawChecksums)), "\n"), len(chunks)+1, "both bundles must be listed")
+
+	verified, err := VerifyChecksums(platformPath)
+	require.NoError(t, err)
+	require.Equal(t, len(chunks), verified)
+	verified, err = VerifyChecksums(modulePath)
+	require.NoError(t, err)
+	require.Equal(t, 1, verified)
+
+	rawChunk, err := os.ReadFile(chunks[1])
+	require.NoError(t, err)
+	rawChunk[100] ^= 0xff
+	require.NoError(t, os.WriteFile(chunks[1], rawChunk, 0o644))
+	_, err = VerifyChecksums(platformPath)
+	require.ErrorContains(t, err, filepath.Base(chunks[1])+" is corrupted")
+
+	require.NoError(t, os.Remove(chunks[1]))
+	_, err = VerifyChecksums(platformPath)
+	require.Error(t, err)
+}
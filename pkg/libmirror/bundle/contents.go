@@ -0,0 +1,356 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/s3"
+)
+
+// Contents describes images stored in the bundle, as read directly from its tar file or chunks without unpacking.
+type Contents struct {
+	// Repositories are sorted by path, root Deckhouse repository has empty path.
+	Repositories []*Repository
+	// ReleaseFootprints are recorded during pull, see ReleaseFootprintsFile. Bundles pulled by older versions do not have them.
+	ReleaseFootprints []contexts.ReleaseFootprint
+	// Delta is set for delta bundles, see DeltaFile.
+	Delta *Delta
+}
+
+type Repository struct {
+	Path string
+	Tags []*Tag
+
+	blobs map[string]struct{}
+}
+
+type Tag struct {
+	Name      string
+	Digest    string
+	MediaType string
+	// Size is the total size of manifest, config and layers.
+	Size   int64
+	Config v1.Descriptor
+	Layers []v1.Descriptor
+	// Manifests are images of image index, usually one per platform.
+	Manifests []v1.Descriptor
+}
+
+// TagRef is a tag found in bundle with the repository it belongs to.
+type TagRef struct {
+	Repository *Repository
+	Tag        *Tag
+}
+
+func (r TagRef) String() string {
+	if r.Repository.Path == "" {
+		return ":" + r.Tag.Name
+	}
+	return r.Repository.Path + ":" + r.Tag.Name
+}
+
+// Find returns tags whose "repository:tag" reference contains query.
+func (c *Contents) Find(query string) []TagRef {
+	found := make([]TagRef, 0)
+	for _, repo := range c.Repositories {
+		for _, tag := range repo.Tags {
+			ref := TagRef{Repository: repo, Tag: tag}
+			if strings.Contains(ref.String(), query) {
+				found = append(found, ref)
+			}
+		}
+	}
+	return found
+}
+
+// Repository returns repository at repoPath or nil if bundle has no such repository.
+func (c *Contents) Repository(repoPath string) *Repository {
+	for _, repo := range c.Repositories {
+		if repo.Path == repoPath {
+			return repo
+		}
+	}
+	return nil
+}
+
+// HasBlob reports whether blob with the digest is stored in the repository layout.
+func (r *Repository) HasBlob(digest v1.Hash) bool {
+	_, found := r.blobs[digest.String()]
+	return found
+}
+
+// Tag returns tag of repository by its name or nil if repository has no such tag.
+func (r *Repository) Tag(name string) *Tag {
+	for _, tag := range r.Tags {
+		if tag.Name == name {
+			return tag
+		}
+	}
+	return nil
+}
+
+type tarEntry struct {
+	offset int64
+	size   int64
+}
+
+// ReadContents lists repositories, tags and layers of the bundle at bundlePath.
+// Tar is scanned once to find OCI layouts in it, then only their indexes and manifests are read.
+// Bundles stored in S3 are read with ranged requests, see s3.Scheme.
+func ReadContents(bundlePath string) (*Contents, error) {
+	bundleReader, err := openBundle(context.Background(), bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	defer bundleReader.Close()
+
+	entries, layoutDirs, err := listEntries(bundleReader)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := &Contents{Repositories: make([]*Repository, 0, len(layoutDirs))}
+	for _, layoutDir := range layoutDirs {
+		repo, err := readRepository(bundleReader, entries, layoutDir)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", layoutDir, err)
+		}
+		contents.Repositories = append(contents.Repositories, repo)
+	}
+	sort.Slice(contents.Repositories, func(i, j int) bool {
+		return contents.Repositories[i].Path < contents.Repositories[j].Path
+	})
+
+	if entry, found := entries[ReleaseFootprintsFile]; found {
+		footprintsReader := io.NewSectionReader(bundleReader, entry.offset, entry.size)
+		if err = json.NewDecoder(footprintsReader).Decode(&contents.ReleaseFootprints); err != nil {
+			return nil, fmt.Errorf("read %s: %w", ReleaseFootprintsFile, err)
+		}
+	}
+	if entry, found := entries[DeltaFile]; found {
+		contents.Delta = &Delta{}
+		if err = json.NewDecoder(io.NewSectionReader(bundleReader, entry.offset, entry.size)).Decode(contents.Delta); err != nil {
+			return nil, fmt.Errorf("read %s: %w", DeltaFile, err)
+		}
+	}
+	return contents, nil
+}
+
+// listEntries finds regular files in bundle tar and directories of OCI layouts among them.
+func listEntries(bundleReader *chunks) (map[string]tarEntry, []string, error) {
+	entries := map[string]tarEntry{}
+	layoutDirs := make([]string, 0)
+	stream := io.NewSectionReader(bundleReader, 0, bundleReader.size)
+	tarReader := tar.NewReader(stream)
+	for {
+		hdr, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("read bundle tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		offset, err := stream.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read bundle tar: %w", err)
+		}
+		entryName := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		entries[entryName] = tarEntry{offset: offset, size: hdr.Size}
+		if path.Base(entryName) == "index.json" {
+			layoutDirs = append(layoutDirs, path.Dir(entryName))
+		}
+	}
+	return entries, layoutDirs, nil
+}
+
+func readRepository(bundle io.ReaderAt, entries map[string]tarEntry, layoutDir string) (*Repository, error) {
+	readJSON := func(entryName string, v any) error {
+		entry, found := entries[entryName]
+		if !found {
+			return fmt.Errorf("%s is missing from bundle", entryName)
+		}
+		return json.NewDecoder(io.NewSectionReader(bundle, entry.offset, entry.size)).Decode(v)
+	}
+
+	repo := &Repository{Path: strings.TrimPrefix(layoutDir, "."), Tags: make([]*Tag, 0), blobs: map[string]struct{}{}}
+	blobsDir := path.Join(layoutDir, "blobs") + "/"
+	for entryName := range entries {
+		if blobPath, isBlob := strings.CutPrefix(entryName, blobsDir); isBlob {
+			if algorithm, hex, found := strings.Cut(blobPath, "/"); found && !strings.Contains(hex, "/") {
+				repo.blobs[algorithm+":"+hex] = struct{}{}
+			}
+		}
+	}
+
+	index := &v1.IndexManifest{}
+	if err := readJSON(path.Join(layoutDir, "index.json"), index); err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+
+	for _, desc := range index.Manifests {
+		tag := &Tag{
+			Name:      tagName(desc),
+			Digest:    desc.Digest.String(),
+			MediaType: string(desc.MediaType),
+			Size:      desc.Size,
+		}
+		repo.Tags = append(repo.Tags, tag)
+		blobPath := path.Join(layoutDir, "blobs", desc.Digest.Algorithm, desc.Digest.Hex)
+		if desc.MediaType.IsIndex() {
+			imageIndex := &v1.IndexManifest{}
+			if err := readJSON(blobPath, imageIndex); err != nil {
+				return nil, fmt.Errorf("read image index of %s: %w", tag.Name, err)
+			}
+			tag.Manifests = imageIndex.Manifests
+			continue
+		}
+		if !desc.MediaType.IsImage() {
+			continue
+		}
+
+		manifest := &v1.Manifest{}
+		if err := readJSON(blobPath, manifest); err != nil {
+			return nil, fmt.Errorf("read manifest of %s: %w", tag.Name, err)
+		}
+		tag.Size += manifest.Config.Size
+		tag.Config = manifest.Config
+		tag.Layers = manifest.Layers
+		for _, layer := range manifest.Layers {
+			tag.Size += layer.Size
+		}
+	}
+
+	sort.Slice(repo.Tags, func(i, j int) bool { return repo.Tags[i].Name < repo.Tags[j].Name })
+	return repo, nil
+}
+
+func tagName(desc v1.Descriptor) string {
+	if tag := desc.Annotations["io.deckhouse.image.short_tag"]; tag != "" {
+		return tag
+	}
+	if ref := desc.Annotations["org.opencontainers.image.ref.name"]; ref != "" {
+		return ref[strings.LastIndex(ref, ":")+1:]
+	}
+	return desc.Digest.String()
+}
+
+// chunks reads bundle chunks as a single file.
+type chunks struct {
+	names   []string
+	parts   []io.ReaderAt
+	starts  []int64
+	size    int64
+	closers []io.Closer
+}
+
+// openBundle opens tar bundle or its chunks, either on local filesystem or in S3.
+func openBundle(ctx context.Context, bundlePath string) (*chunks, error) {
+	if s3.IsURL(bundlePath) {
+		objects, err := findS3BundleObjects(ctx, bundlePath)
+		if err != nil {
+			return nil, err
+		}
+		c := &chunks{}
+		for _, object := range objects {
+			c.add(object.String(), object, object.Size())
+		}
+		return c, nil
+	}
+
+	files, err := FindBundleFiles(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("find bundle files: %w", err)
+	}
+	return openChunks(files)
+}
+
+func openChunks(paths []string) (*chunks, error) {
+	c := &chunks{}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("open bundle file: %w", err)
+		}
+		c.closers = append(c.closers, f)
+		stat, err := f.Stat()
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("stat bundle file: %w", err)
+		}
+		c.add(p, f, stat.Size())
+	}
+	return c, nil
+}
+
+func (c *chunks) add(name string, part io.ReaderAt, size int64) {
+	c.names = append(c.names, name)
+	c.parts = append(c.parts, part)
+	c.starts = append(c.starts, c.size)
+	c.size += size
+}
+
+func (c *chunks) ReadAt(p []byte, off int64) (int, error) {
+	read := 0
+	for i := sort.Search(len(c.starts), func(i int) bool { return c.starts[i] > off }) - 1; i < len(c.parts) && read < len(p); i++ {
+		if i < 0 {
+			return 0, fmt.Errorf("negative offset %d", off)
+		}
+		n, err := c.parts[i].ReadAt(p[read:], off+int64(read)-c.starts[i])
+		read += n
+		if err != nil && !errors.Is(err, io.EOF) {
+			return read, err
+		}
+	}
+	if read < len(p) {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+// chunkAt returns name of the chunk holding byte at offset off, or of the last chunk if off is beyond the end.
+func (c *chunks) chunkAt(off int64) string {
+	if len(c.names) == 0 {
+		return ""
+	}
+	i := sort.Search(len(c.starts), func(i int) bool { return c.starts[i] > off }) - 1
+	return c.names[max(i, 0)]
+}
+
+func (c *chunks) Close() error {
+	var errs []error
+	for _, closer := range c.closers {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestReadContents(t *testing.T) {
+	for name, chunkSize := range map[string]int64{"tar": 0, "chunked": 64 * 1024} {
+		t.Run(name, func(t *testing.T) {
+			packFromDir, bundleDir := t.TempDir(), t.TempDir()
+			appendTaggedImage(t, packFromDir, "v1.55.7")
+			appendTaggedImage(t, filepath.Join(packFromDir, "install"), "v1.55.7")
+			appendTaggedImage(t, filepath.Join(packFromDir, "modules", "console"), "v1.2.0")
+			appendTaggedImage(t, filepath.Join(packFromDir, "modules", "console"), "v1.1.0")
+			footprints := []contexts.ReleaseFootprint{{Version: "v1.55.7", Images: 2, Size: 128 * 1024}}
+			require.NoError(t, WriteReleaseFootprints(packFromDir, footprints))
+
+			bundlePath := filepath.Join(bundleDir, "d8.tar")
+			require.NoError(t, Pack(&contexts.PullContext{
+				BaseContext:     contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: packFromDir},
+				BundleChunkSize: chunkSize,
+			}))
+
+			contents, err := ReadContents(bundlePath)
+			require.NoError(t, err)
+			require.Len(t, contents.Repositories, 3)
+			require.Equal(t, "", contents.Repositories[0].Path)
+			require.Equal(t, "install", contents.Repositories[1].Path)
+			require.Equal(t, "modules/console", contents.Repositories[2].Path)
+
+			console := contents.Repository("modules/console")
+			require.NotNil(t, console)
+			require.Len(t, console.Tags, 2)
+			tag := console.Tag("v1.2.0")
+			require.NotNil(t, tag)
+			require.Len(t, tag.Layers, 2)
+			require.Greater(t, tag.Size, tag.Layers[0].Size+tag.Layers[1].Size)
+
+			found := contents.Find("v1.55.7")
+			require.Len(t, found, 2)
+			require.Equal(t, ":v1.55.7", found[0].String())
+			require.Equal(t, "install:v1.55.7", found[1].String())
+			require.Empty(t, contents.Find("v1.56"))
+			require.Equal(t, footprints, contents.ReleaseFootprints)
+		})
+	}
+}
+
+func appendTaggedImage(t *testing.T, layoutPath, tag string) {
+	t.Helper()
+
+	l, err := layout.FromPath(layoutPath)
+	if err != nil {
+		l, err = layout.Write(layoutPath, empty.Index)
+		require.NoError(t, err)
+	}
+	img, err := random.Image(32*1024, 2)
+	require.NoError(t, err)
+	require.NoError(t, l.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"org.opencontainers.image.ref.name": "registry.local/deckhouse:" + tag,
+		"io.deckhouse.image.short_tag":      tag,
+	})))
+}
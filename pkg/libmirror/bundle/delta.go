@@ -0,0 +1,168 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+)
+
+// DeltaFile is stored in the bundle root next to the root OCI layout of delta bundles, pulled with layers
+// that are already stored in the base bundle left out. Delta bundle can only be pushed into registry the base bundle was pushed to.
+const DeltaFile = "delta.json"
+
+// Delta records the base bundle and the layers left out of delta bundle because base bundle has them.
+type Delta struct {
+	Base string `json:"base"`
+	// OmittedBlobs are digests of layers left out of every layout, by layout path relative to the bundle root, "" being the root itself.
+	OmittedBlobs map[string][]string `json:"omittedBlobs"`
+	// OmittedSize is the total size of layers left out.
+	OmittedSize int64 `json:"omittedSize"`
+}
+
+// Omits reports whether blob was left out of layout at layoutPath relative to the bundle root.
+func (d *Delta) Omits(layoutPath string, digest v1.Hash) bool {
+	if d == nil {
+		return false
+	}
+	for _, omitted := range d.OmittedBlobs[layoutPath] {
+		if omitted == digest.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// MakeDelta removes layers of OCI layouts in unpacked bundle directory that base bundle stores in the layouts with the same paths,
+// and writes DeltaFile recording them. Manifests and configs are kept, so that every tag is still pushed.
+func MakeDelta(unpackedImagesPath, baseName string, base *Contents) (*Delta, error) {
+	delta := &Delta{Base: baseName, OmittedBlobs: map[string][]string{}}
+
+	err := filepath.WalkDir(unpackedImagesPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "index.json" {
+			return nil
+		}
+
+		layoutDir := filepath.Dir(path)
+		layoutPath, err := filepath.Rel(unpackedImagesPath, layoutDir)
+		if err != nil {
+			return err
+		}
+		if layoutPath = filepath.ToSlash(layoutPath); layoutPath == "." {
+			layoutPath = ""
+		}
+		baseRepo := base.Repository(layoutPath)
+		if baseRepo == nil {
+			return nil
+		}
+
+		omitted, size, err := removeBaseLayers(layout.Path(layoutDir), baseRepo)
+		if err != nil {
+			return fmt.Errorf("%s: %w", layoutDir, err)
+		}
+		if len(omitted) > 0 {
+			delta.OmittedBlobs[layoutPath] = omitted
+			delta.OmittedSize += size
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("make delta bundle: %w", err)
+	}
+
+	rawDelta, err := json.MarshalIndent(delta, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal delta: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(unpackedImagesPath, DeltaFile), rawDelta, 0o644); err != nil {
+		return nil, fmt.Errorf("write delta: %w", err)
+	}
+	return delta, nil
+}
+
+func removeBaseLayers(imagesLayout layout.Path, baseRepo *Repository) ([]string, int64, error) {
+	index, err := imagesLayout.ImageIndex()
+	if err != nil {
+		return nil, 0, fmt.Errorf("read index: %w", err)
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, 0, fmt.Errorf("read index manifest: %w", err)
+	}
+
+	layers := map[v1.Hash]int64{}
+	for _, desc := range indexManifest.Manifests {
+		if !desc.MediaType.IsImage() {
+			continue
+		}
+		img, err := index.Image(desc.Digest)
+		if err != nil {
+			return nil, 0, fmt.Errorf("read image %s: %w", desc.Digest, err)
+		}
+		manifest, err := img.Manifest()
+		if err != nil {
+			return nil, 0, fmt.Errorf("read manifest of %s: %w", desc.Digest, err)
+		}
+		for _, layer := range manifest.Layers {
+			if baseRepo.HasBlob(layer.Digest) {
+				layers[layer.Digest] = layer.Size
+			}
+		}
+	}
+
+	omitted := make([]string, 0, len(layers))
+	var size int64
+	for digest, layerSize := range layers {
+		err = os.Remove(filepath.Join(string(imagesLayout), "blobs", digest.Algorithm, digest.Hex))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, 0, fmt.Errorf("remove layer %s: %w", digest, err)
+		}
+		omitted = append(omitted, digest.String())
+		size += layerSize
+	}
+	sort.Strings(omitted)
+	return omitted, size, nil
+}
+
+// ReadDelta loads delta recorded during pull from the unpacked bundle directory.
+// It returns nil without error for full bundles.
+func ReadDelta(unpackedImagesPath string) (*Delta, error) {
+	rawDelta, err := os.ReadFile(filepath.Join(unpackedImagesPath, DeltaFile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("read delta: %w", err)
+	}
+
+	delta := &Delta{}
+	if err = json.Unmarshal(rawDelta, delta); err != nil {
+		return nil, fmt.Errorf("parse delta: %w", err)
+	}
+	return delta, nil
+}
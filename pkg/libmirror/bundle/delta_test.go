@@ -0,0 +1,81 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestMakeDeltaLeavesOutLayersOfBaseBundle(t *testing.T) {
+	packFromDir, bundleDir := t.TempDir(), t.TempDir()
+	appendTaggedImage(t, packFromDir, "v1.60.0")
+	appendTaggedImage(t, filepath.Join(packFromDir, "install"), "v1.60.0")
+	// Packing removes packed files, so base bundle is packed from a copy of the layouts.
+	baseFromDir := t.TempDir()
+	require.NoError(t, os.CopyFS(baseFromDir, os.DirFS(packFromDir)))
+	basePath := filepath.Join(bundleDir, "base.tar")
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext: contexts.BaseContext{BundlePath: basePath, UnpackedImagesPath: baseFromDir},
+	}))
+	base, err := ReadContents(basePath)
+	require.NoError(t, err)
+	require.Nil(t, base.Delta)
+
+	appendTaggedImage(t, packFromDir, "v1.61.0")
+	delta, err := MakeDelta(packFromDir, "base.tar", base)
+	require.NoError(t, err)
+	require.Equal(t, "base.tar", delta.Base)
+	require.Len(t, delta.OmittedBlobs[""], 2)
+	require.Len(t, delta.OmittedBlobs["install"], 2)
+	for _, layer := range base.Repository("").Tag("v1.60.0").Layers {
+		require.Contains(t, delta.OmittedBlobs[""], layer.Digest.String())
+	}
+
+	readDelta, err := ReadDelta(packFromDir)
+	require.NoError(t, err)
+	require.Equal(t, delta, readDelta)
+
+	deltaPath := filepath.Join(bundleDir, "delta.tar")
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext: contexts.BaseContext{BundlePath: deltaPath, UnpackedImagesPath: packFromDir},
+	}))
+	contents, err := ReadContents(deltaPath)
+	require.NoError(t, err)
+	require.Equal(t, delta, contents.Delta)
+	for _, layer := range contents.Repository("").Tag("v1.61.0").Layers {
+		require.True(t, contents.Repository("").HasBlob(layer.Digest), "layers missing from base bundle must be kept")
+	}
+
+	report := Doctor(deltaPath, DoctorOptions{})
+	for _, check := range report.Checks {
+		if check.Name == CheckManifests {
+			require.Equal(t, CheckPassed, check.Status, check.Problems)
+		}
+	}
+}
+
+func TestReadDeltaOfFullBundle(t *testing.T) {
+	delta, err := ReadDelta(t.TempDir())
+	require.NoError(t, err)
+	require.Nil(t, delta)
+}
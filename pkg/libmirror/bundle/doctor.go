@@ -0,0 +1,392 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+// ReleaseManifestsFile is written by pull next to the bundle and lists DeckhouseRelease objects for every pulled version.
+const ReleaseManifestsFile = "deckhousereleases.yaml"
+
+// Repositories of the bundle every Deckhouse release must have an image in.
+var releaseRepositories = contexts.EnterpriseEdition.ReleaseSegments()
+
+type CheckStatus string
+
+const (
+	CheckPassed  CheckStatus = "PASS"
+	CheckFailed  CheckStatus = "FAIL"
+	CheckSkipped CheckStatus = "SKIP"
+)
+
+// Names of checks performed by Doctor, in the order they are run.
+const (
+	CheckIntegrity = "integrity"
+	CheckContents  = "contents"
+	CheckStructure = "structure"
+	CheckManifests = "manifests"
+	CheckTags      = "tags"
+	CheckReleases  = "releases"
+)
+
+// DoctorCheck is the outcome of a single check of the bundle.
+type DoctorCheck struct {
+	Name     string      `json:"name"`
+	Status   CheckStatus `json:"status"`
+	Summary  string      `json:"summary"`
+	Problems []string    `json:"problems,omitempty"`
+}
+
+// DoctorReport is the outcome of all checks of the bundle.
+type DoctorReport struct {
+	reportschema.Header
+
+	Bundle string              `json:"bundle"`
+	Timing reportschema.Timing `json:"timing"`
+	Status CheckStatus         `json:"status"`
+	Checks []DoctorCheck       `json:"checks"`
+}
+
+type DoctorOptions struct {
+	// VerificationKey, if set, is used to verify signature of integrity manifest, see "d8 mirror bundle sign".
+	VerificationKey crypto.PublicKey
+	// SignaturePath defaults to <bundle>.manifest.json.sig.
+	SignaturePath string
+	// ReleaseManifestsPath defaults to ReleaseManifestsFile next to the bundle. Its absence is not a failure,
+	// as pull does not generate it for single-release bundles.
+	ReleaseManifestsPath string
+	// Platforms must have images in every multi-platform image index of the bundle, like the ones pulled with --platforms.
+	Platforms []v1.Platform
+}
+
+// Doctor validates the bundle at bundlePath end to end: integrity of its files, structure of its layouts,
+// presence of every blob referenced by image manifests, consistency of release channel tags and presence of every release
+// listed in release manifests and footprints. Failing checks do not stop the remaining ones,
+// checks that depend on readable bundle contents are skipped if it cannot be read.
+func Doctor(bundlePath string, opts DoctorOptions) *DoctorReport {
+	start := time.Now()
+	report := &DoctorReport{Header: reportschema.NewHeader(reportschema.KindBundleDoctorReport), Bundle: bundlePath}
+	report.add(checkIntegrity(bundlePath, opts))
+
+	contents, err := ReadContents(bundlePath)
+	if err != nil {
+		report.add(failed(CheckContents, "bundle contents cannot be read", err.Error()))
+		for _, name := range []string{CheckStructure, CheckManifests, CheckTags, CheckReleases} {
+			report.add(DoctorCheck{Name: name, Status: CheckSkipped, Summary: "bundle contents cannot be read"})
+		}
+		report.Timing = reportschema.TimingSince(start)
+		return report
+	}
+	images := 0
+	for _, repo := range contents.Repositories {
+		images += len(repo.Tags)
+	}
+	report.add(DoctorCheck{
+		Name:    CheckContents,
+		Status:  CheckPassed,
+		Summary: fmt.Sprintf("%d repositories, %d images", len(contents.Repositories), images),
+	})
+
+	report.add(checkStructure(contents, opts.Platforms))
+	report.add(checkManifests(contents))
+	report.add(checkTags(contents))
+	report.add(checkReleases(bundlePath, contents, opts))
+	report.Timing = reportschema.TimingSince(start)
+	return report
+}
+
+// Passed reports whether none of the checks failed.
+func (r *DoctorReport) Passed() bool {
+	return r.Status == CheckPassed
+}
+
+func (r *DoctorReport) add(check DoctorCheck) {
+	r.Checks = append(r.Checks, check)
+	if r.Status == "" {
+		r.Status = CheckPassed
+	}
+	if check.Status == CheckFailed {
+		r.Status = CheckFailed
+	}
+}
+
+func failed(name, summary string, problems ...string) DoctorCheck {
+	return DoctorCheck{Name: name, Status: CheckFailed, Summary: summary, Problems: problems}
+}
+
+func checkIntegrity(bundlePath string, opts DoctorOptions) DoctorCheck {
+	manifestPath := IntegrityManifestPath(bundlePath)
+	rawManifest, err := os.ReadFile(manifestPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && opts.VerificationKey == nil:
+		return DoctorCheck{Name: CheckIntegrity, Status: CheckSkipped, Summary: "bundle has no integrity manifest"}
+	case err != nil:
+		return failed(CheckIntegrity, "integrity manifest cannot be read", err.Error())
+	}
+
+	signed := ""
+	if opts.VerificationKey != nil {
+		signaturePath := opts.SignaturePath
+		if signaturePath == "" {
+			signaturePath = manifestPath + ".sig"
+		}
+		sig, err := os.ReadFile(signaturePath)
+		if err != nil {
+			return failed(CheckIntegrity, "signature cannot be read", err.Error())
+		}
+		if err = signature.Verify(opts.VerificationKey, rawManifest, sig); err != nil {
+			return failed(CheckIntegrity, "integrity manifest signature is invalid", err.Error())
+		}
+		signed = "signed "
+	}
+
+	expected, err := ParseIntegrityManifest(rawManifest)
+	if err != nil {
+		return failed(CheckIntegrity, "integrity manifest cannot be read", err.Error())
+	}
+	actual, err := BuildIntegrityManifest(bundlePath)
+	if err != nil {
+		return failed(CheckIntegrity, "bundle files cannot be read", err.Error())
+	}
+	if err = expected.Compare(actual); err != nil {
+		return failed(CheckIntegrity, "bundle files do not match "+signed+"integrity manifest", err.Error())
+	}
+	return DoctorCheck{
+		Name:    CheckIntegrity,
+		Status:  CheckPassed,
+		Summary: fmt.Sprintf("%d bundle files match %sintegrity manifest", len(actual.Files), signed),
+	}
+}
+
+// checkStructure looks for entries repeated in layout indexes and for multi-platform image indexes
+// that lack images of their own platforms or of the required ones, see layouts.CheckIndexStructure.
+func checkStructure(contents *Contents, platforms []v1.Platform) DoctorCheck {
+	problems := make([]string, 0)
+	multiPlatformIndexes := 0
+	for _, repo := range contents.Repositories {
+		entries := make([]layouts.IndexEntry, 0, len(repo.Tags))
+		for _, tag := range repo.Tags {
+			digest, err := v1.NewHash(tag.Digest)
+			if err != nil {
+				// Invalid digests are reported by manifests check
+				continue
+			}
+			entries = append(entries, layouts.IndexEntry{
+				Tag:       tag.Name,
+				Digest:    digest,
+				MediaType: types.MediaType(tag.MediaType),
+				Manifests: tag.Manifests,
+			})
+		}
+
+		repoProblems, repoIndexes := layouts.CheckIndexStructure(entries, repo.HasBlob, platforms)
+		for _, problem := range repoProblems {
+			problems = append(problems, repo.Path+":"+problem)
+		}
+		multiPlatformIndexes += repoIndexes
+	}
+
+	if len(problems) > 0 {
+		return failed(CheckStructure, fmt.Sprintf("%d problems with structure of layouts", len(problems)), problems...)
+	}
+	return DoctorCheck{
+		Name:    CheckStructure,
+		Status:  CheckPassed,
+		Summary: fmt.Sprintf("no repeated index entries, %d multi-platform images have images of all platforms", multiPlatformIndexes),
+	}
+}
+
+// checkManifests cross-references image manifests with blobs stored in their layouts.
+func checkManifests(contents *Contents) DoctorCheck {
+	problems := make([]string, 0)
+	checked := 0
+	for _, repo := range contents.Repositories {
+		for _, tag := range repo.Tags {
+			ref := TagRef{Repository: repo, Tag: tag}
+			missing := func(kind string, digest v1.Hash) {
+				problems = append(problems, fmt.Sprintf("%s: %s %s is missing", ref, kind, digest))
+			}
+
+			digest, err := v1.NewHash(tag.Digest)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid digest %q", ref, tag.Digest))
+				continue
+			}
+			checked++
+			if !repo.HasBlob(digest) {
+				missing("manifest", digest)
+				continue
+			}
+			if !types.MediaType(tag.MediaType).IsImage() {
+				continue
+			}
+			if !repo.HasBlob(tag.Config.Digest) {
+				missing("config", tag.Config.Digest)
+			}
+			for _, layer := range tag.Layers {
+				if !repo.HasBlob(layer.Digest) && !contents.Delta.Omits(repo.Path, layer.Digest) {
+					missing("layer", layer.Digest)
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return failed(CheckManifests, fmt.Sprintf("%d blobs referenced by image manifests are missing", len(problems)), problems...)
+	}
+	summary := fmt.Sprintf("all blobs of %d manifests are present", checked)
+	if contents.Delta != nil {
+		summary += ", except layers stored in base bundle " + contents.Delta.Base
+	}
+	return DoctorCheck{Name: CheckManifests, Status: CheckPassed, Summary: summary}
+}
+
+// checkTags looks for ambiguous tags and release channels pointing to different versions in different layouts.
+func checkTags(contents *Contents) DoctorCheck {
+	segments := map[string]layouts.SegmentTags{}
+	for _, segment := range layouts.ChannelSegments {
+		repo := contents.Repository(segment)
+		if repo == nil {
+			continue
+		}
+		tags := layouts.SegmentTags{}
+		for _, tag := range repo.Tags {
+			digest, err := v1.NewHash(tag.Digest)
+			// Images pulled by digest have no tag to conflict over.
+			if err != nil || strings.Contains(tag.Name, ":") {
+				continue
+			}
+			tags.Add(tag.Name, digest)
+		}
+		segments[segment] = tags
+	}
+
+	conflicts := layouts.FindTagConflicts(segments)
+	if len(conflicts) > 0 {
+		problems := make([]string, 0, len(conflicts))
+		for _, conflict := range conflicts {
+			problems = append(problems, conflict.String())
+		}
+		return failed(CheckTags, fmt.Sprintf("%d tags are inconsistent", len(conflicts)), problems...)
+	}
+	return DoctorCheck{
+		Name:    CheckTags,
+		Status:  CheckPassed,
+		Summary: fmt.Sprintf("tags of %d layouts are consistent", len(segments)),
+	}
+}
+
+// checkReleases looks for images of every release listed in release manifests and footprints.
+func checkReleases(bundlePath string, contents *Contents, opts DoctorOptions) DoctorCheck {
+	manifestsPath := opts.ReleaseManifestsPath
+	if manifestsPath == "" {
+		manifestsPath = filepath.Join(filepath.Dir(bundlePath), ReleaseManifestsFile)
+	}
+	versions, err := readReleaseManifestVersions(manifestsPath)
+	if err != nil && (opts.ReleaseManifestsPath != "" || !errors.Is(err, fs.ErrNotExist)) {
+		return failed(CheckReleases, "release manifests cannot be read", err.Error())
+	}
+	for _, footprint := range contents.ReleaseFootprints {
+		versions = append(versions, footprint.Version)
+	}
+	if len(versions) == 0 {
+		return DoctorCheck{Name: CheckReleases, Status: CheckSkipped, Summary: "bundle lists no releases"}
+	}
+
+	problems := make([]string, 0)
+	checked := map[string]struct{}{}
+	for _, version := range versions {
+		if _, found := checked[version]; found {
+			continue
+		}
+		checked[version] = struct{}{}
+
+		for _, repoPath := range releaseRepositories {
+			ref := TagRef{Repository: &Repository{Path: repoPath}, Tag: &Tag{Name: version}}
+			repo := contents.Repository(repoPath)
+			if repo == nil || repo.Tag(version) == nil {
+				problems = append(problems, fmt.Sprintf("%s: release image is missing", ref))
+				continue
+			}
+			tag := repo.Tag(version)
+			if digest, err := v1.NewHash(tag.Digest); err != nil || !repo.HasBlob(digest) {
+				problems = append(problems, fmt.Sprintf("%s: manifest %s is missing", ref, tag.Digest))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return failed(CheckReleases, fmt.Sprintf("%d release images are missing", len(problems)), problems...)
+	}
+	return DoctorCheck{
+		Name:    CheckReleases,
+		Status:  CheckPassed,
+		Summary: fmt.Sprintf("images of %d releases are present", len(checked)),
+	}
+}
+
+var yamlDocumentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// readReleaseManifestVersions returns versions of DeckhouseRelease objects in the file.
+func readReleaseManifestVersions(path string) ([]string, error) {
+	rawManifests, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, 0)
+	for _, document := range yamlDocumentSeparator.Split(string(rawManifests), -1) {
+		if strings.TrimSpace(document) == "" {
+			continue
+		}
+		release := struct {
+			Kind string `json:"kind"`
+			Spec struct {
+				Version string `json:"version"`
+			} `json:"spec"`
+		}{}
+		if err = yaml.Unmarshal([]byte(document), &release); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		if release.Kind != "DeckhouseRelease" {
+			continue
+		}
+		if release.Spec.Version == "" {
+			return nil, fmt.Errorf("parse %s: DeckhouseRelease without version", path)
+		}
+		versions = append(versions, release.Spec.Version)
+	}
+	return versions, nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestDoctorPassesIntactBundle(t *testing.T) {
+	packFromDir, bundleDir := t.TempDir(), t.TempDir()
+	for _, repo := range []string{"", "install", "release-channel"} {
+		appendTaggedImage(t, filepath.Join(packFromDir, repo), "v1.60.0")
+	}
+	bundlePath := packDoctorBundle(t, packFromDir, bundleDir, "v1.60.0")
+
+	manifest, err := BuildIntegrityManifest(bundlePath)
+	require.NoError(t, err)
+	rawManifest, err := manifest.Marshal()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(IntegrityManifestPath(bundlePath), rawManifest, 0o644))
+
+	report := Doctor(bundlePath, DoctorOptions{})
+	require.True(t, report.Passed(), "%+v", report.Checks)
+	for _, check := range report.Checks {
+		require.Equal(t, CheckPassed, check.Status, check.Name)
+	}
+}
+
+func TestDoctorReportsBrokenBundle(t *testing.T) {
+	packFromDir, bundleDir := t.TempDir(), t.TempDir()
+	appendTaggedImage(t, packFromDir, "v1.60.0")
+	appendTaggedImage(t, filepath.Join(packFromDir, "install"), "v1.60.0")
+	appendTaggedImage(t, filepath.Join(packFromDir, "install"), "v1.60.0")
+
+	l, err := layout.FromPath(packFromDir)
+	require.NoError(t, err)
+	index, err := l.ImageIndex()
+	require.NoError(t, err)
+	indexManifest, err := index.IndexManifest()
+	require.NoError(t, err)
+	img, err := index.Image(indexManifest.Manifests[0].Digest)
+	require.NoError(t, err)
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	layerDigest, err := layers[0].Digest()
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(packFromDir, "blobs", layerDigest.Algorithm, layerDigest.Hex)))
+
+	bundlePath := packDoctorBundle(t, packFromDir, bundleDir, "v1.60.0")
+	report := Doctor(bundlePath, DoctorOptions{})
+	require.False(t, report.Passed())
+
+	statuses := map[string]CheckStatus{}
+	for _, check := range report.Checks {
+		statuses[check.Name] = check.Status
+	}
+	require.Equal(t, map[string]CheckStatus{
+		CheckIntegrity: CheckSkipped,
+		CheckContents:  CheckPassed,
+		CheckStructure: CheckPassed,
+		CheckManifests: CheckFailed,
+		CheckTags:      CheckFailed,
+		CheckReleases:  CheckFailed,
+	}, statuses)
+	require.Equal(t, []string{":v1.60.0: layer " + layerDigest.String() + " is missing"}, report.Checks[3].Problems)
+	require.Equal(t, []string{"release-channel:v1.60.0: release image is missing"}, report.Checks[5].Problems)
+}
+
+func TestDoctorChecksLayoutsStructure(t *testing.T) {
+	packFromDir, bundleDir := t.TempDir(), t.TempDir()
+	appendTaggedImage(t, filepath.Join(packFromDir, "install"), "v1.60.0")
+
+	l, err := layout.Write(packFromDir, empty.Index)
+	require.NoError(t, err)
+	amd64, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	arm64, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	multiPlatform := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+	)
+	annotations := layout.WithAnnotations(map[string]string{"io.deckhouse.image.short_tag": "v1.60.0"})
+	require.NoError(t, l.AppendIndex(multiPlatform, annotations))
+	require.NoError(t, l.AppendIndex(multiPlatform, annotations))
+	arm64Digest, err := arm64.Digest()
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(packFromDir, "blobs", arm64Digest.Algorithm, arm64Digest.Hex)))
+	indexDigest, err := multiPlatform.Digest()
+	require.NoError(t, err)
+
+	bundlePath := packDoctorBundle(t, packFromDir, bundleDir)
+	report := Doctor(bundlePath, DoctorOptions{
+		Platforms: []v1.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "s390x"}},
+	})
+	require.False(t, report.Passed())
+	require.Equal(t, CheckStructure, report.Checks[2].Name)
+	require.Equal(t, CheckFailed, report.Checks[2].Status)
+	require.Equal(t, []string{
+		":v1.60.0: " + indexDigest.String() + " is listed 2 times in layout index",
+		":v1.60.0: image of platform linux/arm64 is missing",
+		":v1.60.0: image index has no image of platform linux/s390x",
+	}, report.Checks[2].Problems)
+}
+
+func packDoctorBundle(t *testing.T, packFromDir, bundleDir string, releases ...string) string {
+	t.Helper()
+
+	rawReleases := make([]byte, 0)
+	for _, release := range releases {
+		rawReleases = append(rawReleases, "---\napiVersion: deckhouse.io/v1alpha1\nkind: DeckhouseRelease\nspec:\n  version: "+release+"\n"...)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(bundleDir, ReleaseManifestsFile), rawReleases, 0o644))
+
+	bundlePath := filepath.Join(bundleDir, "d8.tar")
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext: contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: packFromDir},
+	}))
+	return bundlePath
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// ErrEncrypted is returned when encrypted bundle is unpacked without decryption keys.
+var ErrEncrypted = errors.New("bundle is encrypted, decryption key is required to unpack it")
+
+// LoadOpenPGPKeys reads OpenPGP keys from armored or binary key files, like the ones exported by "gpg --export"
+// or "gpg --export-secret-keys". Private keys protected with passphrase are decrypted with it.
+func LoadOpenPGPKeys(paths []string, passphrase []byte) (openpgp.EntityList, error) {
+	keys := make(openpgp.EntityList, 0, len(paths))
+	for _, path := range paths {
+		rawKeys, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read OpenPGP key: %w", err)
+		}
+		entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(rawKeys))
+		if err != nil {
+			entities, err = openpgp.ReadKeyRing(bytes.NewReader(rawKeys))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse OpenPGP key %s: %w", path, err)
+		}
+
+		for _, entity := range entities {
+			if err = decryptPrivateKeys(entity, passphrase); err != nil {
+				return nil, fmt.Errorf("decrypt OpenPGP key %s: %w", path, err)
+			}
+		}
+		keys = append(keys, entities...)
+	}
+	return keys, nil
+}
+
+func decryptPrivateKeys(entity *openpgp.Entity, passphrase []byte) error {
+	privateKeys := []*packet.PrivateKey{entity.PrivateKey}
+	for _, subkey := range entity.Subkeys {
+		privateKeys = append(privateKeys, subkey.PrivateKey)
+	}
+	for _, privateKey := range privateKeys {
+		if privateKey == nil || !privateKey.Encrypted {
+			continue
+		}
+		if len(passphrase) == 0 {
+			return errors.New("key is protected with passphrase, but none was given")
+		}
+		if err := privateKey.Decrypt(passphrase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptingWriter encrypts everything written to it to the recipients and closes underlying writer once closed itself.
+type encryptingWriter struct {
+	io.WriteCloser
+	underlying io.WriteCloser
+}
+
+func encryptStream(w io.WriteCloser, recipients openpgp.EntityList) (io.WriteCloser, error) {
+	plaintext, err := openpgp.Encrypt(w, recipients, nil, &openpgp.FileHints{IsBinary: true}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt bundle: %w", err)
+	}
+	return &encryptingWriter{WriteCloser: plaintext, underlying: w}, nil
+}
+
+func (w *encryptingWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return w.underlying.Close()
+}
+
+// decryptingReader returns plaintext of OpenPGP message and checks its integrity once it is read to the end.
+type decryptingReader struct {
+	body io.Reader
+}
+
+// decryptStream returns bundle stream as is, unless it is OpenPGP message, which is decrypted with keys.
+// Plaintext must be read to the end for integrity of encrypted bundle to be checked, see drain.
+func decryptStream(r io.Reader, keys openpgp.EntityList) (io.Reader, error) {
+	bufferedStream := bufio.NewReader(r)
+	head, err := bufferedStream.Peek(1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	// Tar archives start with the name of the first file, while OpenPGP packets have the highest bit of the first byte set.
+	if len(head) == 0 || head[0]&0x80 == 0 {
+		return bufferedStream, nil
+	}
+	if len(keys) == 0 {
+		return nil, ErrEncrypted
+	}
+
+	message, err := openpgp.ReadMessage(bufferedStream, keys, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt bundle: %w", err)
+	}
+	return &decryptingReader{body: message.UnverifiedBody}, nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("decrypt bundle: %w", err)
+	}
+	return n, err
+}
+
+// drain reads the rest of bundle stream left after the end of tar archive, so that integrity of encrypted bundle is checked.
+func drain(r io.Reader) error {
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return fmt.Errorf("read tar bundle: %w", err)
+	}
+	return nil
+}
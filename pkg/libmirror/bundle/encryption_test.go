@@ -0,0 +1,77 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestEncryptedBundlePackingAndUnpacking(t *testing.T) {
+	packFromDir, unpackToDir, keysDir := t.TempDir(), t.TempDir(), t.TempDir()
+	bundlePath := filepath.Join(t.TempDir(), "d8.tar")
+	fillTestFileTree(t, packFromDir)
+	expectedFiles := findAllPaths(t, packFromDir)
+
+	publicKeyPath, privateKeyPath := writeTestOpenPGPKey(t, keysDir)
+	recipients, err := LoadOpenPGPKeys([]string{publicKeyPath}, nil)
+	require.NoError(t, err)
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext:      contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: packFromDir},
+		BundleChunkSize:  8 * 1024 * 1024,
+		BundleRecipients: recipients,
+	}))
+
+	err = Unpack(&contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: unpackToDir})
+	require.ErrorIs(t, err, ErrEncrypted)
+
+	decryptionKeys, err := LoadOpenPGPKeys([]string{privateKeyPath}, nil)
+	require.NoError(t, err)
+	require.NoError(t, Unpack(&contexts.BaseContext{
+		BundlePath:           bundlePath,
+		UnpackedImagesPath:   unpackToDir,
+		BundleDecryptionKeys: decryptionKeys,
+	}))
+	require.Equal(t, expectedFiles, findAllPaths(t, unpackToDir))
+}
+
+func writeTestOpenPGPKey(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("Bundle Recipient", "", "recipient@example.com", nil)
+	require.NoError(t, err)
+
+	publicKey, privateKey := &bytes.Buffer{}, &bytes.Buffer{}
+	armored, err := armor.Encode(publicKey, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(armored))
+	require.NoError(t, armored.Close())
+	require.NoError(t, entity.SerializePrivate(privateKey, nil))
+
+	publicKeyPath, privateKeyPath := filepath.Join(dir, "recipient.asc"), filepath.Join(dir, "recipient.gpg")
+	require.NoError(t, os.WriteFile(publicKeyPath, publicKey.Bytes(), 0o644))
+	require.NoError(t, os.WriteFile(privateKeyPath, privateKey.Bytes(), 0o600))
+	return publicKeyPath, privateKeyPath
+}
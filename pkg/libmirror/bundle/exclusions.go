@@ -0,0 +1,91 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+// ExclusionsFile is stored in the bundle root next to the root OCI layout and records the rules images were excluded
+// from the pull by and the images they matched, so that bundle missing them is known to be incomplete on purpose.
+const ExclusionsFile = "excluded-images.json"
+
+// Exclusions are the provenance of images deliberately left out of the bundle.
+type Exclusions struct {
+	RefPatterns []string                 `json:"refPatterns,omitempty"`
+	Digests     []string                 `json:"digests,omitempty"`
+	Images      []contexts.ExcludedImage `json:"images"`
+}
+
+// ExcludesFrom reports whether any image was excluded from layout at layoutPath relative to the bundle root.
+func (e *Exclusions) ExcludesFrom(layoutPath string) bool {
+	if e == nil {
+		return false
+	}
+	for _, image := range e.Images {
+		if image.Layout == layoutPath {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteExclusions stores exclusion rules and excluded images in the unpacked bundle directory so they are packed into the bundle.
+// Nothing is written if there are no exclusion rules.
+func WriteExclusions(unpackedImagesPath string, exclusions *contexts.ImageExclusions) error {
+	if exclusions == nil {
+		return nil
+	}
+
+	rawExclusions, err := json.MarshalIndent(Exclusions{
+		RefPatterns: exclusions.RefPatterns,
+		Digests:     exclusions.Digests,
+		Images:      exclusions.Excluded(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal excluded images: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(unpackedImagesPath, ExclusionsFile), rawExclusions, 0o644); err != nil {
+		return fmt.Errorf("write excluded images: %w", err)
+	}
+	return nil
+}
+
+// ReadExclusions loads exclusions recorded during pull from the unpacked bundle directory.
+// It returns nil without error for bundles that were pulled without exclusions.
+func ReadExclusions(unpackedImagesPath string) (*Exclusions, error) {
+	rawExclusions, err := os.ReadFile(filepath.Join(unpackedImagesPath, ExclusionsFile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("read excluded images: %w", err)
+	}
+
+	exclusions := &Exclusions{}
+	if err = json.Unmarshal(rawExclusions, exclusions); err != nil {
+		return nil, fmt.Errorf("parse excluded images: %w", err)
+	}
+	return exclusions, nil
+}
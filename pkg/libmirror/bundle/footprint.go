@@ -0,0 +1,42 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+// ReleaseFootprintsFile is stored in the bundle root next to the root OCI layout
+// and lists number of images and aggregate size of every Deckhouse release in the bundle.
+const ReleaseFootprintsFile = "release-footprints.json"
+
+// WriteReleaseFootprints stores footprints in the unpacked bundle directory so they are packed into the bundle.
+func WriteReleaseFootprints(unpackedImagesPath string, footprints []contexts.ReleaseFootprint) error {
+	rawFootprints, err := json.MarshalIndent(footprints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal release footprints: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(unpackedImagesPath, ReleaseFootprintsFile), rawFootprints, 0o644); err != nil {
+		return fmt.Errorf("write release footprints: %w", err)
+	}
+	return nil
+}
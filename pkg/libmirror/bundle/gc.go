@@ -0,0 +1,156 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// GCResult is the outcome of CollectGarbage.
+type GCResult struct {
+	Layouts       int
+	RemovedBlobs  int
+	ReclaimedSize int64
+}
+
+// CollectGarbage removes blobs of every OCI layout in unpacked bundle directory that are not referenced by layout index
+// or by manifests and indexes it references, like partially written blobs and blobs of images pulled by interrupted runs.
+// Manifests and indexes missing from layout are skipped, blobs they reference are kept if other manifests reference them.
+func CollectGarbage(unpackedImagesPath string) (*GCResult, error) {
+	result := &GCResult{}
+	err := filepath.WalkDir(unpackedImagesPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "index.json" {
+			return nil
+		}
+
+		layoutDir := filepath.Dir(path)
+		if err = collectLayoutGarbage(layoutDir, result); err != nil {
+			return fmt.Errorf("%s: %w", layoutDir, err)
+		}
+		result.Layouts++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collect garbage: %w", err)
+	}
+	return result, nil
+}
+
+func collectLayoutGarbage(layoutDir string, result *GCResult) error {
+	index := &v1.IndexManifest{}
+	if err := readLayoutJSON(filepath.Join(layoutDir, "index.json"), index); err != nil {
+		return fmt.Errorf("read index: %w", err)
+	}
+	referenced := map[string]struct{}{}
+	if err := markReferencedBlobs(layoutDir, index.Manifests, referenced); err != nil {
+		return err
+	}
+
+	blobsDir := filepath.Join(layoutDir, "blobs")
+	algorithms, err := os.ReadDir(blobsDir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("list blobs: %w", err)
+	}
+	for _, algorithm := range algorithms {
+		if !algorithm.IsDir() {
+			continue
+		}
+		blobs, err := os.ReadDir(filepath.Join(blobsDir, algorithm.Name()))
+		if err != nil {
+			return fmt.Errorf("list blobs: %w", err)
+		}
+		for _, blob := range blobs {
+			if blob.IsDir() {
+				continue
+			}
+			if _, found := referenced[algorithm.Name()+":"+blob.Name()]; found {
+				continue
+			}
+
+			info, err := blob.Info()
+			if err != nil {
+				return fmt.Errorf("stat blob: %w", err)
+			}
+			if err = os.Remove(filepath.Join(blobsDir, algorithm.Name(), blob.Name())); err != nil {
+				return fmt.Errorf("remove blob: %w", err)
+			}
+			result.RemovedBlobs++
+			result.ReclaimedSize += info.Size()
+		}
+	}
+	return nil
+}
+
+// markReferencedBlobs adds digests of manifests described by descriptors and of every blob they reference to referenced set.
+func markReferencedBlobs(layoutDir string, descriptors []v1.Descriptor, referenced map[string]struct{}) error {
+	for _, desc := range descriptors {
+		if _, found := referenced[desc.Digest.String()]; found {
+			continue
+		}
+		referenced[desc.Digest.String()] = struct{}{}
+
+		blobPath := filepath.Join(layoutDir, "blobs", desc.Digest.Algorithm, desc.Digest.Hex)
+		switch {
+		case desc.MediaType.IsIndex():
+			index := &v1.IndexManifest{}
+			if err := readLayoutJSON(blobPath, index); err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return fmt.Errorf("read index %s: %w", desc.Digest, err)
+			}
+			if err := markReferencedBlobs(layoutDir, index.Manifests, referenced); err != nil {
+				return err
+			}
+		default:
+			// Artifacts of unknown media types are kept together with their blobs as long as they look like image manifests.
+			manifest := &v1.Manifest{}
+			if err := readLayoutJSON(blobPath, manifest); err != nil {
+				if errors.Is(err, fs.ErrNotExist) || !desc.MediaType.IsImage() {
+					continue
+				}
+				return fmt.Errorf("read manifest %s: %w", desc.Digest, err)
+			}
+			referenced[manifest.Config.Digest.String()] = struct{}{}
+			for _, layer := range manifest.Layers {
+				referenced[layer.Digest.String()] = struct{}{}
+			}
+		}
+	}
+	return nil
+}
+
+func readLayoutJSON(path string, target any) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, target)
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectGarbageRemovesOrphanedBlobs(t *testing.T) {
+	unpackedDir := t.TempDir()
+	appendTaggedImage(t, unpackedDir, "v1.60.0")
+	appendTaggedImage(t, filepath.Join(unpackedDir, "modules", "console"), "v1.2.3")
+
+	blobs := func(layoutDir string) []string {
+		entries, err := os.ReadDir(filepath.Join(layoutDir, "blobs", "sha256"))
+		require.NoError(t, err)
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+	referencedBlobs := blobs(unpackedDir)
+
+	blobsDir := filepath.Join(unpackedDir, "blobs", "sha256")
+	require.NoError(t, os.WriteFile(filepath.Join(blobsDir, "0123456789abcdef"), make([]byte, 1024), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(blobsDir, "0123456789abcdef.tmp"), make([]byte, 512), 0o644))
+
+	result, err := CollectGarbage(unpackedDir)
+	require.NoError(t, err)
+	require.Equal(t, &GCResult{Layouts: 2, RemovedBlobs: 2, ReclaimedSize: 1536}, result)
+	require.ElementsMatch(t, referencedBlobs, blobs(unpackedDir))
+	require.Len(t, blobs(filepath.Join(unpackedDir, "modules", "console")), 4, "blobs of nested layouts must be kept")
+
+	result, err = CollectGarbage(unpackedDir)
+	require.NoError(t, err)
+	require.Zero(t, result.RemovedBlobs)
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+const securityRepoPrefix = "security/"
+
+// Inspection summarizes what the bundle delivers: Deckhouse releases, release channels, modules and vulnerability databases.
+type Inspection struct {
+	reportschema.Header
+	Bundle string `json:"bundle"`
+
+	// DeckhouseVersions are version tags of the root Deckhouse repository, oldest first.
+	DeckhouseVersions []string               `json:"deckhouseVersions"`
+	Channels          []ChannelInspection    `json:"channels"`
+	Modules           []ModuleInspection     `json:"modules"`
+	SecurityDatabases []SecurityDBInspection `json:"securityDatabases"`
+	Repositories      []RepositoryInspection `json:"repositories"`
+	// TotalSize is the sum of sizes of all tags, images shared by several tags are counted for every one of them.
+	TotalSize int64 `json:"totalSize"`
+}
+
+type ChannelInspection struct {
+	Channel string `json:"channel"`
+	// Version is the Deckhouse version channel tag points to, or empty if bundle has no version tag for its image.
+	Version string `json:"version"`
+}
+
+type ModuleInspection struct {
+	Name     string   `json:"name"`
+	Versions []string `json:"versions"`
+	Size     int64    `json:"size"`
+}
+
+type SecurityDBInspection struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type RepositoryInspection struct {
+	// Path is relative to the bundle root, root Deckhouse repository has empty path.
+	Path string `json:"path"`
+	Tags int    `json:"tags"`
+	Size int64  `json:"size"`
+}
+
+// Inspect summarizes contents of the bundle at bundlePath read with ReadContents.
+func Inspect(bundlePath string, contents *Contents) *Inspection {
+	inspection := &Inspection{
+		Header:            reportschema.NewHeader(reportschema.KindBundleInspection),
+		Bundle:            bundlePath,
+		DeckhouseVersions: make([]string, 0),
+		Channels:          make([]ChannelInspection, 0),
+		Modules:           make([]ModuleInspection, 0),
+		SecurityDatabases: make([]SecurityDBInspection, 0),
+		Repositories:      make([]RepositoryInspection, 0, len(contents.Repositories)),
+	}
+
+	modules := map[string]*ModuleInspection{}
+	for _, repo := range contents.Repositories {
+		repoInspection := RepositoryInspection{Path: repo.Path, Tags: len(repo.Tags)}
+		for _, tag := range repo.Tags {
+			repoInspection.Size += tag.Size
+		}
+		inspection.Repositories = append(inspection.Repositories, repoInspection)
+		inspection.TotalSize += repoInspection.Size
+
+		switch {
+		case repo.Path == "":
+			inspection.DeckhouseVersions = versionTags(repo)
+			inspection.Channels = channelVersions(repo)
+		case strings.HasPrefix(repo.Path, securityRepoPrefix):
+			tags := make([]string, 0, len(repo.Tags))
+			for _, tag := range repo.Tags {
+				tags = append(tags, tag.Name)
+			}
+			inspection.SecurityDatabases = append(inspection.SecurityDatabases, SecurityDBInspection{
+				Name: strings.TrimPrefix(repo.Path, securityRepoPrefix),
+				Tags: tags,
+			})
+		default:
+			moduleName, isModule := strings.CutPrefix(layouts.LayoutComponent(repo.Path), layouts.ComponentModulePrefix)
+			if !isModule {
+				continue
+			}
+			module, found := modules[moduleName]
+			if !found {
+				module = &ModuleInspection{Name: moduleName, Versions: make([]string, 0)}
+				modules[moduleName] = module
+			}
+			module.Size += repoInspection.Size
+			// Module images and release images are both tagged with module versions.
+			if repo.Path == path.Join(contexts.DefaultModulesPathSuffix, moduleName) || path.Base(repo.Path) == "release" {
+				module.Versions = sortedVersionTags(append(module.Versions, versionTags(repo)...))
+			}
+		}
+	}
+
+	for _, module := range modules {
+		inspection.Modules = append(inspection.Modules, *module)
+	}
+	sort.Slice(inspection.Modules, func(i, j int) bool { return inspection.Modules[i].Name < inspection.Modules[j].Name })
+	return inspection
+}
+
+// versionTags returns semver tags of the repository, oldest first.
+func versionTags(repo *Repository) []string {
+	tags := make([]string, 0, len(repo.Tags))
+	for _, tag := range repo.Tags {
+		tags = append(tags, tag.Name)
+	}
+	return sortedVersionTags(tags)
+}
+
+// sortedVersionTags returns distinct semver tags, oldest first.
+func sortedVersionTags(tags []string) []string {
+	versions := map[string]*semver.Version{}
+	for _, tag := range tags {
+		if version, ok := parseVersionTag(tag); ok {
+			versions[tag] = version
+		}
+	}
+
+	sorted := make([]string, 0, len(versions))
+	for tag := range versions {
+		sorted = append(sorted, tag)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return versions[sorted[i]].LessThan(versions[sorted[j]]) })
+	return sorted
+}
+
+func parseVersionTag(tag string) (*semver.Version, bool) {
+	versionString, found := strings.CutPrefix(tag, "v")
+	if !found {
+		return nil, false
+	}
+	version, err := semver.StrictNewVersion(versionString)
+	return version, err == nil
+}
+
+// channelVersions resolves release channel tags of the root repository to version tags of the same image.
+func channelVersions(repo *Repository) []ChannelInspection {
+	versionsByDigest := map[string]string{}
+	for _, tag := range repo.Tags {
+		if _, ok := parseVersionTag(tag.Name); ok {
+			versionsByDigest[tag.Digest] = tag.Name
+		}
+	}
+
+	channels := make([]ChannelInspection, 0)
+	for _, tag := range repo.Tags {
+		if _, ok := parseVersionTag(tag.Name); ok || layouts.IsDigestTag(tag.Name) || strings.Contains(tag.Name, ":") {
+			continue
+		}
+		channels = append(channels, ChannelInspection{Channel: tag.Name, Version: versionsByDigest[tag.Digest]})
+	}
+	return channels
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+func TestInspect(t *testing.T) {
+	packFromDir, bundleDir := t.TempDir(), t.TempDir()
+	appendTaggedImage(t, packFromDir, "v1.60.0")
+	appendTaggedImage(t, packFromDir, "v1.61.0")
+	l, err := layout.FromPath(packFromDir)
+	require.NoError(t, err)
+	stable, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	for _, tag := range []string{"v1.60.1", "stable"} {
+		require.NoError(t, l.AppendImage(stable, layout.WithAnnotations(map[string]string{
+			"io.deckhouse.image.short_tag": tag,
+		})))
+	}
+	appendTaggedImage(t, packFromDir, "alpha")
+	appendTaggedImage(t, filepath.Join(packFromDir, "modules", "console"), "v1.2.0")
+	appendTaggedImage(t, filepath.Join(packFromDir, "modules", "console", "release"), "v1.2.0")
+	appendTaggedImage(t, filepath.Join(packFromDir, "modules", "console", "release"), "v1.3.0")
+	appendTaggedImage(t, filepath.Join(packFromDir, "security", "trivy-db"), "2")
+
+	bundlePath := filepath.Join(bundleDir, "d8.tar")
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext: contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: packFromDir},
+	}))
+	contents, err := ReadContents(bundlePath)
+	require.NoError(t, err)
+
+	inspection := Inspect(bundlePath, contents)
+	require.Equal(t, reportschema.KindBundleInspection, inspection.Kind)
+	require.Equal(t, []string{"v1.60.0", "v1.60.1", "v1.61.0"}, inspection.DeckhouseVersions)
+	require.Equal(t, []ChannelInspection{
+		{Channel: "alpha", Version: ""},
+		{Channel: "stable", Version: "v1.60.1"},
+	}, inspection.Channels)
+	require.Len(t, inspection.Modules, 1)
+	require.Equal(t, "console", inspection.Modules[0].Name)
+	require.Equal(t, []string{"v1.2.0", "v1.3.0"}, inspection.Modules[0].Versions)
+	require.Equal(t, []SecurityDBInspection{{Name: "trivy-db", Tags: []string{"2"}}}, inspection.SecurityDatabases)
+	require.Len(t, inspection.Repositories, 4)
+
+	var total int64
+	for _, repo := range inspection.Repositories {
+		total += repo.Size
+	}
+	require.Equal(t, total, inspection.TotalSize)
+	require.Equal(t, inspection.Repositories[1].Size+inspection.Repositories[2].Size, inspection.Modules[0].Size)
+}
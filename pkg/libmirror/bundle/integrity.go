@@ -0,0 +1,159 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/chunked"
+)
+
+const IntegrityManifestSuffix = ".manifest.json"
+
+// IntegrityManifest lists every file that makes up the bundle with its size and SHA-256 digest.
+// It is the payload that gets signed by "d8 mirror bundle sign".
+type IntegrityManifest struct {
+	Bundle string                   `json:"bundle"`
+	Files  []IntegrityManifestEntry `json:"files"`
+}
+
+type IntegrityManifestEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Digest string `json:"digest"`
+}
+
+// IntegrityManifestPath returns the path to integrity manifest of the bundle at bundlePath.
+func IntegrityManifestPath(bundlePath string) string {
+	return bundlePath + IntegrityManifestSuffix
+}
+
+// FindBundleFiles returns paths to the files that make up the bundle at bundlePath.
+// It is either the tar file itself, or the list of its chunks, ordered by chunk index.
+// Path to any chunk of the bundle stands for the whole bundle.
+func FindBundleFiles(bundlePath string) ([]string, error) {
+	if chunkOf, _, isChunk := chunked.ParseChunkName(filepath.Base(bundlePath)); isChunk {
+		bundlePath = filepath.Join(filepath.Dir(bundlePath), chunkOf)
+	}
+
+	stat, err := os.Stat(bundlePath)
+	switch {
+	case err == nil && stat.Mode().IsRegular():
+		return []string{bundlePath}, nil
+	case err == nil:
+		return nil, fmt.Errorf("%s: not a regular file", bundlePath)
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	chunks, err := chunked.FindChunks(filepath.Dir(bundlePath), filepath.Base(bundlePath))
+	if err != nil {
+		return nil, fmt.Errorf("read tar bundle directory: %w", err)
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("%s: %w", bundlePath, fs.ErrNotExist)
+	}
+	return chunks, nil
+}
+
+// BuildIntegrityManifest calculates digests of all files that make up the bundle at bundlePath.
+func BuildIntegrityManifest(bundlePath string) (*IntegrityManifest, error) {
+	files, err := FindBundleFiles(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("find bundle files: %w", err)
+	}
+
+	manifest := &IntegrityManifest{
+		Bundle: filepath.Base(bundlePath),
+		Files:  make([]IntegrityManifestEntry, 0, len(files)),
+	}
+	for _, file := range files {
+		entry, err := digestBundleFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("digest %s: %w", file, err)
+		}
+		manifest.Files = append(manifest.Files, *entry)
+	}
+
+	return manifest, nil
+}
+
+func digestBundleFile(path string) (*IntegrityManifestEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, bufio.NewReaderSize(f, 512*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	return &IntegrityManifestEntry{
+		Name:   filepath.Base(path),
+		Size:   size,
+		Digest: fmt.Sprintf("sha256:%x", hasher.Sum(nil)),
+	}, nil
+}
+
+// Marshal returns canonical JSON representation of the manifest, which is the exact byte sequence being signed.
+func (m *IntegrityManifest) Marshal() ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
+
+func ParseIntegrityManifest(raw []byte) (*IntegrityManifest, error) {
+	manifest := &IntegrityManifest{}
+	if err := json.Unmarshal(raw, manifest); err != nil {
+		return nil, fmt.Errorf("parse integrity manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// Compare checks that other manifest describes exactly the same set of files with the same contents.
+func (m *IntegrityManifest) Compare(other *IntegrityManifest) error {
+	expected := make(map[string]IntegrityManifestEntry, len(m.Files))
+	for _, entry := range m.Files {
+		expected[entry.Name] = entry
+	}
+
+	for _, entry := range other.Files {
+		want, found := expected[entry.Name]
+		if !found {
+			return fmt.Errorf("%s is not listed in integrity manifest", entry.Name)
+		}
+		if want.Size != entry.Size || want.Digest != entry.Digest {
+			return fmt.Errorf("%s: digest mismatch, expected %s, got %s", entry.Name, want.Digest, entry.Digest)
+		}
+		delete(expected, entry.Name)
+	}
+
+	for name := range expected {
+		return fmt.Errorf("%s is listed in integrity manifest but missing from bundle", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+// CycloneDX BOM is written by hand to avoid pulling a library for a handful of fields.
+// See https://cyclonedx.org/docs/1.5/json/ for the schema.
+type cycloneDXBOM struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     cycloneDXMetadata    `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string `json:"timestamp"`
+	Tools     struct {
+		Components []cycloneDXComponent `json:"components"`
+	} `json:"tools"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type"`
+	BOMRef     string              `json:"bom-ref,omitempty"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	Hashes     []cycloneDXHash     `json:"hashes,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// InventoryOptions describe where images of the bundle were pulled from.
+type InventoryOptions struct {
+	// SourceRepo is the Deckhouse repository the bundle was pulled from.
+	SourceRepo string
+	// ModulesPathSuffix is the path of modules repositories in source registry, see contexts.RegistrySegment.
+	ModulesPathSuffix string
+	Timestamp         time.Time
+}
+
+// WriteCycloneDXInventory writes CycloneDX 1.5 JSON inventory of every image in the bundle contents:
+// its source repository, tag, digest and total size, for asset management systems to ingest alongside SBOMs.
+func WriteCycloneDXInventory(w io.Writer, contents *Contents, opts InventoryOptions) error {
+	serialNumber, err := randomUUID()
+	if err != nil {
+		return fmt.Errorf("generate BOM serial number: %w", err)
+	}
+
+	bom := cycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + serialNumber,
+		Version:      1,
+		Components:   make([]cycloneDXComponent, 0),
+	}
+	bom.Metadata.Timestamp = reportschema.Timestamp(opts.Timestamp)
+	bom.Metadata.Tools.Components = []cycloneDXComponent{{Type: "application", Name: "d8 mirror pull"}}
+
+	sourceRepo := strings.TrimSuffix(opts.SourceRepo, "/")
+	for _, repo := range contents.Repositories {
+		sourceRepoName := strings.TrimSuffix(path.Join(sourceRepo, contexts.RegistrySegment(repo.Path, opts.ModulesPathSuffix)), "/")
+		for _, tag := range repo.Tags {
+			digest, err := v1.NewHash(tag.Digest)
+			if err != nil {
+				return fmt.Errorf("parse digest of %s:%s: %w", sourceRepoName, tag.Name, err)
+			}
+			purl := imagePURL(sourceRepoName, tag.Name, digest)
+			bom.Components = append(bom.Components, cycloneDXComponent{
+				Type:    "container",
+				BOMRef:  purl,
+				Name:    sourceRepoName,
+				Version: tag.Name,
+				Hashes:  []cycloneDXHash{{Alg: cycloneDXHashAlgorithm(digest), Content: digest.Hex}},
+				PURL:    purl,
+				Properties: []cycloneDXProperty{
+					{Name: "deckhouse:mirror:bundle-path", Value: repo.Path},
+					{Name: "deckhouse:mirror:digest", Value: tag.Digest},
+					{Name: "deckhouse:mirror:media-type", Value: tag.MediaType},
+					{Name: "deckhouse:mirror:size", Value: strconv.FormatInt(tag.Size, 10)},
+					{Name: "deckhouse:mirror:source", Value: sourceRepoName + ":" + tag.Name},
+				},
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(bom); err != nil {
+		return fmt.Errorf("write inventory: %w", err)
+	}
+	return nil
+}
+
+// imagePURL is the package URL of the image, see https://github.com/package-url/purl-spec/blob/master/PURL-TYPES.rst#oci.
+func imagePURL(repo, tag string, digest v1.Hash) string {
+	query := url.Values{}
+	query.Set("repository_url", repo)
+	query.Set("tag", tag)
+	return "pkg:oci/" + path.Base(repo) + "@" + digest.Algorithm + "%3A" + digest.Hex + "?" + query.Encode()
+}
+
+func cycloneDXHashAlgorithm(digest v1.Hash) string {
+	switch digest.Algorithm {
+	case "sha256":
+		return "SHA-256"
+	case "sha512":
+		return "SHA-512"
+	default:
+		return strings.ToUpper(digest.Algorithm)
+	}
+}
+
+func randomUUID() (string, error) {
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		return "", err
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40 // Version 4
+	uuid[8] = uuid[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+}
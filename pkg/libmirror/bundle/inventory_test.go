@@ -0,0 +1,70 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCycloneDXInventory(t *testing.T) {
+	const digest = "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	contents := &Contents{Repositories: []*Repository{
+		{Path: "", Tags: []*Tag{{Name: "v1.60.0", Digest: digest, MediaType: "application/vnd.oci.image.manifest.v1+json", Size: 1024}}},
+		{Path: "modules/console", Tags: []*Tag{{Name: "v1.2.3", Digest: digest, Size: 2048}}},
+	}}
+
+	buf := &bytes.Buffer{}
+	err := WriteCycloneDXInventory(buf, contents, InventoryOptions{
+		SourceRepo:        "registry.deckhouse.io/deckhouse/ee/",
+		ModulesPathSuffix: "extra-modules",
+		Timestamp:         time.Date(2024, 6, 1, 12, 0, 0, 0, time.FixedZone("MSK", 3*60*60)),
+	})
+	require.NoError(t, err)
+
+	bom := cycloneDXBOM{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &bom))
+	require.Equal(t, "CycloneDX", bom.BOMFormat)
+	require.Equal(t, "1.5", bom.SpecVersion)
+	require.Regexp(t, `^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, bom.SerialNumber)
+	require.Equal(t, "2024-06-01T09:00:00Z", bom.Metadata.Timestamp)
+	require.Len(t, bom.Components, 2)
+
+	root := bom.Components[0]
+	require.Equal(t, "container", root.Type)
+	require.Equal(t, "registry.deckhouse.io/deckhouse/ee", root.Name)
+	require.Equal(t, "v1.60.0", root.Version)
+	require.Equal(t, []cycloneDXHash{{Alg: "SHA-256", Content: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"}}, root.Hashes)
+	require.Equal(t,
+		"pkg:oci/ee@sha256%3A2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"+
+			"?repository_url=registry.deckhouse.io%2Fdeckhouse%2Fee&tag=v1.60.0",
+		root.PURL,
+	)
+	require.Contains(t, root.Properties, cycloneDXProperty{Name: "deckhouse:mirror:size", Value: "1024"})
+
+	module := bom.Components[1]
+	require.Equal(t, "registry.deckhouse.io/deckhouse/ee/extra-modules/console", module.Name)
+	require.Contains(t, module.Properties, cycloneDXProperty{Name: "deckhouse:mirror:bundle-path", Value: "modules/console"})
+	require.Contains(t, module.Properties, cycloneDXProperty{
+		Name:  "deckhouse:mirror:source",
+		Value: "registry.deckhouse.io/deckhouse/ee/extra-modules/console:v1.2.3",
+	})
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/deckhouse/deckhouse-cli/internal/mirror/chunked"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/s3"
+)
+
+// findS3BundleObjects returns chunks of the bundle stored in S3 in order, or the bundle object itself if it is not chunked.
+func findS3BundleObjects(ctx context.Context, bundleURL string) ([]*s3.Object, error) {
+	client, err := s3.NewClientFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	bucket, key, err := s3.ParseURL(bundleURL)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := client.List(ctx, bucket, key+".")
+	if err != nil {
+		return nil, fmt.Errorf("find bundle chunks: %w", err)
+	}
+	chunkObjects := make([]*s3.Object, 0)
+	for _, info := range objects {
+		if chunkOf, _, isChunk := chunked.ParseChunkName(info.Key); !isChunk || chunkOf != key {
+			continue
+		}
+		object, err := client.Stat(ctx, bucket, info.Key)
+		if err != nil {
+			return nil, err
+		}
+		chunkObjects = append(chunkObjects, object)
+	}
+	if len(chunkObjects) > 0 {
+		return chunkObjects, nil
+	}
+
+	object, err := client.Stat(ctx, bucket, key)
+	if err != nil {
+		return nil, fmt.Errorf("find bundle: %w", err)
+	}
+	return []*s3.Object{object}, nil
+}
+
+// openS3BundleStream returns contents of all bundle chunks stored in S3 as a single stream.
+// Chunks are requested one after another, as the stream is read.
+func openS3BundleStream(ctx context.Context, bundleURL string) (io.ReadCloser, error) {
+	objects, err := findS3BundleObjects(ctx, bundleURL)
+	if err != nil {
+		return nil, err
+	}
+	return &objectsReader{objects: objects}, nil
+}
+
+type objectsReader struct {
+	objects []*s3.Object
+	current io.ReadCloser
+}
+
+func (r *objectsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.objects) == 0 {
+				return 0, io.EOF
+			}
+			stream, err := r.objects[0].Open()
+			if err != nil {
+				return 0, err
+			}
+			r.current, r.objects = stream, r.objects[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			err = r.current.Close()
+			r.current = nil
+			if n > 0 || err != nil {
+				return n, err
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *objectsReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	return r.current.Close()
+}
+
+// newS3BundleWriter returns writer that uploads bundle to S3, split into objects of chunkSize if it is not zero.
+// Chunks are named the same way chunked.FileWriter names files.
+func newS3BundleWriter(ctx context.Context, bundleURL string, chunkSize int64) (*s3BundleWriter, error) {
+	client, err := s3.NewClientFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	bucket, key, err := s3.ParseURL(bundleURL)
+	if err != nil {
+		return nil, err
+	}
+	return &s3BundleWriter{ctx: ctx, client: client, bucket: bucket, key: key, chunkSize: chunkSize}, nil
+}
+
+type s3BundleWriter struct {
+	ctx       context.Context
+	client    *s3.Client
+	bucket    string
+	key       string
+	chunkSize int64
+
+	chunkIndex int
+	current    *s3.Writer
+}
+
+func (w *s3BundleWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if w.current == nil {
+			key := w.key
+			if w.chunkSize != 0 {
+				key = chunked.ChunkName(w.key, w.chunkIndex)
+			}
+			w.current = w.client.Create(w.ctx, w.bucket, key)
+		}
+
+		part := p
+		if w.chunkSize != 0 {
+			part = p[:min(int64(len(p)), w.chunkSize-w.current.Written())]
+		}
+		n, err := w.current.Write(part)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+
+		if w.chunkSize != 0 && w.current.Written() >= w.chunkSize {
+			if err = w.current.Close(); err != nil {
+				return written, err
+			}
+			w.current = nil
+			w.chunkIndex++
+		}
+	}
+	return written, nil
+}
+
+func (w *s3BundleWriter) Close() error {
+	if w.current == nil {
+		return nil
+	}
+	return w.current.Close()
+}
+
+// Abort cancels upload of the chunk being written, chunks that were already uploaded are kept.
+func (w *s3BundleWriter) Abort() {
+	if w.current != nil {
+		w.current.Abort()
+	}
+}
+
+// UploadSidecarFile uploads file that accompanies the bundle stored in S3, like deckhousereleases.yaml,
+// next to the bundle and returns its URL.
+func UploadSidecarFile(ctx context.Context, bundleURL, filePath string) (string, error) {
+	client, err := s3.NewClientFromEnv()
+	if err != nil {
+		return "", err
+	}
+	bucket, key, err := s3.ParseURL(bundleURL)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sidecarKey := path.Join(path.Dir(key), filepath.Base(filePath))
+	w := client.Create(ctx, bucket, sidecarKey)
+	if _, err = io.Copy(w, f); err != nil {
+		w.Abort()
+		return "", err
+	}
+	if err = w.Close(); err != nil {
+		return "", err
+	}
+	return s3.Scheme + bucket + "/" + sidecarKey, nil
+}
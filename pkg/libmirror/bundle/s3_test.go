@@ -0,0 +1,63 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	mirrorTestUtils "github.com/deckhouse/deckhouse-cli/testing/util/mirror"
+)
+
+func TestS3BundlePackingAndUnpacking(t *testing.T) {
+	server := mirrorTestUtils.SetupTestS3()
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", mirrorTestUtils.TestS3AccessKeyID)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	for name, chunkSize := range map[string]int64{"tar": 0, "chunked": 64 * 1024} {
+		t.Run(name, func(t *testing.T) {
+			packFromDir, unpackToDir := t.TempDir(), t.TempDir()
+			appendTaggedImage(t, packFromDir, "v1.55.7")
+			appendTaggedImage(t, filepath.Join(packFromDir, "modules", "console"), "v1.2.0")
+			expectedPaths := findAllPaths(t, packFromDir)
+
+			bundleURL := "s3://bundles/" + name + "/d8.tar"
+			require.NoError(t, Pack(&contexts.PullContext{
+				BaseContext:     contexts.BaseContext{BundlePath: bundleURL, UnpackedImagesPath: packFromDir},
+				BundleChunkSize: chunkSize,
+			}))
+
+			contents, err := ReadContents(bundleURL)
+			require.NoError(t, err)
+			require.Len(t, contents.Repositories, 2)
+			console := contents.Repository("modules/console")
+			require.NotNil(t, console)
+			tag := console.Tag("v1.2.0")
+			require.NotNil(t, tag)
+			require.True(t, console.HasBlob(tag.Layers[0].Digest))
+			require.False(t, contents.Repository("").HasBlob(tag.Layers[0].Digest))
+
+			require.NoError(t, Unpack(&contexts.BaseContext{BundlePath: bundleURL, UnpackedImagesPath: unpackToDir}))
+			require.Equal(t, expectedPaths, findAllPaths(t, unpackToDir))
+		})
+	}
+}
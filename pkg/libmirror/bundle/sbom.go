@@ -0,0 +1,91 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/sbom"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+)
+
+// ReadSBOMs extracts SPDX and CycloneDX documents that cosign attached to images of the bundle at bundlePath,
+// which are only there if bundle was pulled with --include-cosign. Documents of other formats are left out.
+func ReadSBOMs(ctx context.Context, bundlePath string) ([]sbom.Document, error) {
+	bundleReader, err := openBundle(ctx, bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	defer bundleReader.Close()
+
+	entries, layoutDirs, err := listEntries(bundleReader)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(layoutDirs)
+
+	docs := make([]sbom.Document, 0)
+	for _, layoutDir := range layoutDirs {
+		repo, err := readRepository(bundleReader, entries, layoutDir)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", layoutDir, err)
+		}
+
+		for _, tag := range repo.Tags {
+			digest, kind, isArtifact := signature.ParseCosignArtifactTag(tag.Name)
+			if !isArtifact || kind != "sbom" {
+				continue
+			}
+			for _, layer := range tag.Layers {
+				if !sbom.IsSupported(layer.MediaType) {
+					continue
+				}
+				entry, found := entries[path.Join(layoutDir, "blobs", layer.Digest.Algorithm, layer.Digest.Hex)]
+				if !found {
+					return nil, fmt.Errorf("%s:%s: SBOM %s is missing from bundle", repo.Path, tag.Name, layer.Digest)
+				}
+				data, err := io.ReadAll(io.NewSectionReader(bundleReader, entry.offset, entry.size))
+				if err != nil {
+					return nil, fmt.Errorf("%s:%s: read SBOM %s: %w", repo.Path, tag.Name, layer.Digest, err)
+				}
+				docs = append(docs, sbom.Document{
+					Repository: repo.Path,
+					Image:      imageTagOf(repo, digest),
+					MediaType:  layer.MediaType,
+					Data:       data,
+				})
+			}
+		}
+	}
+	return docs, nil
+}
+
+// imageTagOf returns the first tag of repository that refers to image with digest, or "sha256-<hex>" if there is none.
+func imageTagOf(repo *Repository, digest v1.Hash) string {
+	for _, tag := range repo.Tags {
+		if tag.Digest == digest.String() && !signature.IsCosignArtifactTag(tag.Name) {
+			return tag.Name
+		}
+	}
+	return digest.Algorithm + "-" + digest.Hex
+}
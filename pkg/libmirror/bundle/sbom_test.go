@@ -0,0 +1,70 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+)
+
+func TestReadSBOMs(t *testing.T) {
+	packFromDir, bundleDir := t.TempDir(), t.TempDir()
+	installLayout, err := layout.Write(filepath.Join(packFromDir, "install"), empty.Index)
+	require.NoError(t, err)
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, installLayout.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"io.deckhouse.image.short_tag": "v1.60.0",
+	})))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	sbomImage, err := mutate.Append(empty.Image,
+		mutate.Addendum{Layer: static.NewLayer([]byte(`{"spdxVersion":"SPDX-2.3"}`), "text/spdx+json")},
+		mutate.Addendum{Layer: static.NewLayer([]byte(`{"artifacts":[]}`), "application/vnd.syft+json")},
+	)
+	require.NoError(t, err)
+	sbomImage = mutate.MediaType(sbomImage, types.OCIManifestSchema1)
+	require.NoError(t, installLayout.AppendImage(sbomImage, layout.WithAnnotations(map[string]string{
+		"io.deckhouse.image.short_tag": signature.CosignArtifactTags(digest)[2],
+	})))
+
+	bundlePath := filepath.Join(bundleDir, "d8.tar")
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext: contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: packFromDir},
+	}))
+
+	docs, err := ReadSBOMs(context.Background(), bundlePath)
+	require.NoError(t, err)
+	require.Len(t, docs, 1, "Only SPDX and CycloneDX documents should be read")
+	require.Equal(t, "install", docs[0].Repository)
+	require.Equal(t, "v1.60.0", docs[0].Image)
+	require.Equal(t, types.MediaType("text/spdx+json"), docs[0].MediaType)
+	require.JSONEq(t, `{"spdxVersion":"SPDX-2.3"}`, string(docs[0].Data))
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+)
+
+// SegmentCountsFile is stored in the bundle root next to the root OCI layout and lists number of tags, images and blobs
+// of every repository in the bundle, so that push can check that target received at least as much.
+const SegmentCountsFile = "segment-counts.json"
+
+// WriteSegmentCounts stores counts in the unpacked bundle directory so they are packed into the bundle.
+func WriteSegmentCounts(unpackedImagesPath string, counts map[string]layouts.SegmentCounts) error {
+	rawCounts, err := json.MarshalIndent(counts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal segment counts: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(unpackedImagesPath, SegmentCountsFile), rawCounts, 0o644); err != nil {
+		return fmt.Errorf("write segment counts: %w", err)
+	}
+	return nil
+}
+
+// ReadSegmentCounts loads counts recorded during pull from the unpacked bundle directory.
+// It returns nil counts without error for bundles pulled by older versions of d8 that do not record them.
+func ReadSegmentCounts(unpackedImagesPath string) (map[string]layouts.SegmentCounts, error) {
+	rawCounts, err := os.ReadFile(filepath.Join(unpackedImagesPath, SegmentCountsFile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("read segment counts: %w", err)
+	}
+
+	counts := make(map[string]layouts.SegmentCounts)
+	if err = json.Unmarshal(rawCounts, &counts); err != nil {
+		return nil, fmt.Errorf("parse segment counts: %w", err)
+	}
+	return counts, nil
+}
@@ -0,0 +1,295 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+// ErrNotStreamable is returned by OpenStreamed for bundles that can only be read from start to end.
+var ErrNotStreamable = errors.New("encrypted bundle cannot be pushed without unpacking it")
+
+const streamReadSize = 4 * 1024 * 1024
+
+// StreamedBundle serves OCI layouts of packed bundle straight from its tar file, chunks or S3 objects,
+// so that blobs, which make up almost all of the bundle, are never copied to disk before being pushed.
+type StreamedBundle struct {
+	bundle  *chunks
+	entries map[string]tarEntry
+}
+
+var _ contexts.LayoutIndexes = (*StreamedBundle)(nil)
+
+// OpenStreamed scans tar bundle at mirrorCtx.BundlePath and writes every file of it but blobs of OCI layouts
+// into mirrorCtx.UnpackedImagesPath, so that layouts and bundle metadata can be found there the same way as in unpacked bundle.
+// Images of the layouts are then read with ImageIndex. StreamedBundle must be closed once push is done.
+func OpenStreamed(ctx context.Context, mirrorCtx *contexts.BaseContext) (*StreamedBundle, error) {
+	bundleReader, err := openBundle(ctx, mirrorCtx.BundlePath)
+	if err != nil {
+		return nil, err
+	}
+	b := &StreamedBundle{bundle: bundleReader}
+
+	// Tar archives start with the name of the first file, while OpenPGP packets have the highest bit of the first byte set.
+	head := make([]byte, 1)
+	if _, err = bundleReader.ReadAt(head, 0); err == nil && head[0]&0x80 != 0 {
+		b.Close()
+		return nil, ErrNotStreamable
+	}
+	if b.entries, _, err = listEntries(bundleReader); err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	for entryName, entry := range b.entries {
+		if err = ctx.Err(); err != nil {
+			b.Close()
+			return nil, err
+		}
+		if _, isBlob := blobDigest(entryName); isBlob {
+			continue
+		}
+		if err = b.extract(entryName, entry, filepath.Join(mirrorCtx.UnpackedImagesPath, filepath.FromSlash(entryName))); err != nil {
+			b.Close()
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (b *StreamedBundle) extract(entryName string, entry tarEntry, writePath string) error {
+	if err := os.MkdirAll(filepath.Dir(writePath), 0o755); err != nil {
+		return fmt.Errorf("setup dir tree: %w", err)
+	}
+	file, err := os.Create(writePath)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	if _, err = io.Copy(file, io.NewSectionReader(b.bundle, entry.offset, entry.size)); err != nil {
+		file.Close()
+		return fmt.Errorf("write %q: %w", entryName, err)
+	}
+	return file.Close()
+}
+
+// ImageIndex returns index of OCI layout at layoutPath relative to the bundle root, "" being the root itself.
+func (b *StreamedBundle) ImageIndex(layoutPath string) (v1.ImageIndex, error) {
+	rawIndex, err := b.readEntry(path.Join(layoutPath, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+	return &streamedIndex{bundle: b, layoutPath: layoutPath, mediaType: types.OCIImageIndex, rawIndex: rawIndex}, nil
+}
+
+func (b *StreamedBundle) Close() error {
+	return b.bundle.Close()
+}
+
+func (b *StreamedBundle) readEntry(entryName string) ([]byte, error) {
+	entry, found := b.entries[entryName]
+	if !found {
+		return nil, fmt.Errorf("%s is missing from bundle", entryName)
+	}
+	return io.ReadAll(io.NewSectionReader(b.bundle, entry.offset, entry.size))
+}
+
+func (b *StreamedBundle) blobEntryName(layoutPath string, digest v1.Hash) string {
+	return path.Join(layoutPath, "blobs", digest.Algorithm, digest.Hex)
+}
+
+// streamedIndex mirrors index of layout.Path, reading manifests and blobs from the bundle instead of the filesystem.
+type streamedIndex struct {
+	bundle     *StreamedBundle
+	layoutPath string
+	mediaType  types.MediaType
+	rawIndex   []byte
+}
+
+var _ v1.ImageIndex = (*streamedIndex)(nil)
+
+func (i *streamedIndex) MediaType() (types.MediaType, error) {
+	return i.mediaType, nil
+}
+
+func (i *streamedIndex) Digest() (v1.Hash, error) {
+	return partial.Digest(i)
+}
+
+func (i *streamedIndex) Size() (int64, error) {
+	return partial.Size(i)
+}
+
+func (i *streamedIndex) IndexManifest() (*v1.IndexManifest, error) {
+	index := &v1.IndexManifest{}
+	err := json.Unmarshal(i.rawIndex, index)
+	return index, err
+}
+
+func (i *streamedIndex) RawManifest() ([]byte, error) {
+	return i.rawIndex, nil
+}
+
+func (i *streamedIndex) Image(h v1.Hash) (v1.Image, error) {
+	desc, err := i.findDescriptor(h)
+	if err != nil {
+		return nil, err
+	}
+	if !desc.MediaType.IsImage() {
+		return nil, fmt.Errorf("unexpected media type for %v: %s", h, desc.MediaType)
+	}
+	return partial.CompressedToImage(&streamedImage{bundle: i.bundle, layoutPath: i.layoutPath, desc: *desc})
+}
+
+func (i *streamedIndex) ImageIndex(h v1.Hash) (v1.ImageIndex, error) {
+	desc, err := i.findDescriptor(h)
+	if err != nil {
+		return nil, err
+	}
+	if !desc.MediaType.IsIndex() {
+		return nil, fmt.Errorf("unexpected media type for %v: %s", h, desc.MediaType)
+	}
+	rawIndex, err := i.bundle.readEntry(i.bundle.blobEntryName(i.layoutPath, h))
+	if err != nil {
+		return nil, err
+	}
+	return &streamedIndex{bundle: i.bundle, layoutPath: i.layoutPath, mediaType: desc.MediaType, rawIndex: rawIndex}, nil
+}
+
+func (i *streamedIndex) findDescriptor(h v1.Hash) (*v1.Descriptor, error) {
+	index, err := i.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range index.Manifests {
+		if desc.Digest == h {
+			return &desc, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find descriptor in index: %s", h)
+}
+
+type streamedImage struct {
+	bundle       *StreamedBundle
+	layoutPath   string
+	desc         v1.Descriptor
+	manifestLock sync.Mutex // Protects rawManifest
+	rawManifest  []byte
+}
+
+var _ partial.CompressedImageCore = (*streamedImage)(nil)
+
+func (i *streamedImage) MediaType() (types.MediaType, error) {
+	return i.desc.MediaType, nil
+}
+
+func (i *streamedImage) Manifest() (*v1.Manifest, error) {
+	return partial.Manifest(i)
+}
+
+func (i *streamedImage) RawManifest() ([]byte, error) {
+	i.manifestLock.Lock()
+	defer i.manifestLock.Unlock()
+	if i.rawManifest != nil {
+		return i.rawManifest, nil
+	}
+
+	rawManifest, err := i.bundle.readEntry(i.bundle.blobEntryName(i.layoutPath, i.desc.Digest))
+	if err != nil {
+		return nil, err
+	}
+	i.rawManifest = rawManifest
+	return i.rawManifest, nil
+}
+
+func (i *streamedImage) RawConfigFile() ([]byte, error) {
+	manifest, err := i.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return i.bundle.readEntry(i.bundle.blobEntryName(i.layoutPath, manifest.Config.Digest))
+}
+
+func (i *streamedImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	manifest, err := i.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	if h == manifest.Config.Digest {
+		return &streamedBlob{bundle: i.bundle, layoutPath: i.layoutPath, desc: manifest.Config}, nil
+	}
+	for _, desc := range manifest.Layers {
+		if h == desc.Digest {
+			return &streamedBlob{bundle: i.bundle, layoutPath: i.layoutPath, desc: desc}, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find layer in image: %s", h)
+}
+
+// streamedBlob is read from the bundle only when it is uploaded, so layers left out of delta bundles can be described too.
+type streamedBlob struct {
+	bundle     *StreamedBundle
+	layoutPath string
+	desc       v1.Descriptor
+}
+
+func (b *streamedBlob) Digest() (v1.Hash, error) {
+	return b.desc.Digest, nil
+}
+
+func (b *streamedBlob) Compressed() (io.ReadCloser, error) {
+	entryName := b.bundle.blobEntryName(b.layoutPath, b.desc.Digest)
+	entry, found := b.bundle.entries[entryName]
+	if !found {
+		return nil, fmt.Errorf("%s is missing from bundle", entryName)
+	}
+	// Reads from bundles in S3 are range requests, so layers are read in large parts.
+	return io.NopCloser(bufio.NewReaderSize(io.NewSectionReader(b.bundle.bundle, entry.offset, entry.size), streamReadSize)), nil
+}
+
+func (b *streamedBlob) Size() (int64, error) {
+	return b.desc.Size, nil
+}
+
+func (b *streamedBlob) MediaType() (types.MediaType, error) {
+	return b.desc.MediaType, nil
+}
+
+// Descriptor implements partial.withDescriptor.
+func (b *streamedBlob) Descriptor() (*v1.Descriptor, error) {
+	return &b.desc, nil
+}
+
+// See partial.Exists.
+func (b *streamedBlob) Exists() (bool, error) {
+	_, found := b.bundle.entries[b.bundle.blobEntryName(b.layoutPath, b.desc.Digest)]
+	return found, nil
+}
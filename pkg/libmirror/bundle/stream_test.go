@@ -0,0 +1,69 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestStreamedBundleServesImagesWithoutUnpackingBlobs(t *testing.T) {
+	packFromDir, bundleDir, unpackDir := t.TempDir(), t.TempDir(), t.TempDir()
+	appendTaggedImage(t, packFromDir, "v1.60.0")
+	appendTaggedImage(t, packFromDir, "v1.61.0")
+	appendTaggedImage(t, filepath.Join(packFromDir, "install"), "v1.60.0")
+	indexDigests := map[string]v1.Hash{}
+	for _, layoutPath := range []string{"", "install"} {
+		index, err := layout.Path(filepath.Join(packFromDir, layoutPath)).ImageIndex()
+		require.NoError(t, err)
+		indexDigests[layoutPath], err = index.Digest()
+		require.NoError(t, err)
+	}
+
+	bundlePath := filepath.Join(bundleDir, "d8.tar")
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext:     contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: packFromDir},
+		BundleChunkSize: 64 * 1024,
+	}))
+
+	streamed, err := OpenStreamed(context.Background(), &contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: unpackDir})
+	require.NoError(t, err)
+	defer streamed.Close()
+
+	for layoutPath, indexDigest := range indexDigests {
+		require.FileExists(t, filepath.Join(unpackDir, layoutPath, "index.json"))
+		require.NoDirExists(t, filepath.Join(unpackDir, layoutPath, "blobs"), "blobs must not be unpacked")
+
+		index, err := streamed.ImageIndex(layoutPath)
+		require.NoError(t, err)
+		digest, err := index.Digest()
+		require.NoError(t, err)
+		require.Equal(t, indexDigest, digest)
+		require.NoError(t, validate.Index(index), "layers read from bundle must match their digests")
+	}
+
+	_, err = streamed.ImageIndex("install-standalone")
+	require.Error(t, err)
+}
@@ -11,16 +11,21 @@ import (
 
 func ValidateUnpackedBundle(mirrorCtx *contexts.PushContext) error {
 	mandatoryLayouts := map[string]string{
-		"root layout":                mirrorCtx.UnpackedImagesPath,
-		"installers layout":          filepath.Join(mirrorCtx.UnpackedImagesPath, "install"),
-		"release channels layout":    filepath.Join(mirrorCtx.UnpackedImagesPath, "release-channel"),
-		"trivy database layout":      filepath.Join(mirrorCtx.UnpackedImagesPath, "security", "trivy-db"),
-		"trivy bdu layout":           filepath.Join(mirrorCtx.UnpackedImagesPath, "security", "trivy-bdu"),
-		"trivy java database layout": filepath.Join(mirrorCtx.UnpackedImagesPath, "security", "trivy-java-db"),
+		"root layout":                "",
+		"installers layout":          "install",
+		"release channels layout":    "release-channel",
+		"trivy database layout":      "security/trivy-db",
+		"trivy bdu layout":           "security/trivy-bdu",
+		"trivy java database layout": "security/trivy-java-db",
 	}
 
-	for layoutDescription, fsPath := range mandatoryLayouts {
-		l, err := layout.FromPath(fsPath)
+	exclusions, err := ReadExclusions(mirrorCtx.UnpackedImagesPath)
+	if err != nil {
+		return err
+	}
+
+	for layoutDescription, layoutPath := range mandatoryLayouts {
+		l, err := layout.FromPath(filepath.Join(mirrorCtx.UnpackedImagesPath, filepath.FromSlash(layoutPath)))
 		if err != nil {
 			return fmt.Errorf("%s: %w", layoutDescription, err)
 		}
@@ -34,7 +39,8 @@ func ValidateUnpackedBundle(mirrorCtx *contexts.PushContext) error {
 			return fmt.Errorf("%s image index manifest: %w", layoutDescription, err)
 		}
 
-		if len(indexManifest.Manifests) == 0 {
+		// Layout is left empty on purpose if all of its images were excluded from the pull.
+		if len(indexManifest.Manifests) == 0 && !exclusions.ExcludesFrom(layoutPath) {
 			return fmt.Errorf("No images in %s", layoutDescription)
 		}
 	}
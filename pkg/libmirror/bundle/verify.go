@@ -0,0 +1,255 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// VerifyResult is the outcome of Verify.
+type VerifyResult struct {
+	Layouts   int
+	Manifests int
+	// Blobs is the number of blobs whose contents were hashed and compared to their digests.
+	Blobs int
+	// Problems describe corrupted, truncated or missing bundle data, there are none for intact bundle.
+	Problems []string
+}
+
+func (r *VerifyResult) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *VerifyResult) problemf(format string, args ...any) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// Verify reads the whole bundle at bundlePath, tar file or its chunks, and checks that contents of every blob
+// of its OCI layouts match their digests, that manifests and configs referenced by layout indexes are stored
+// with declared sizes, and that image configs list as many layers as their manifests.
+// Bundle defects are reported in VerifyResult.Problems, error is only returned if bundle cannot be read at all.
+func Verify(ctx context.Context, bundlePath string) (*VerifyResult, error) {
+	bundleReader, err := openBundle(ctx, bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	defer bundleReader.Close()
+
+	result := &VerifyResult{Problems: make([]string, 0)}
+	// All chunks but the last one are written of the same size, so the shorter ones were not copied completely.
+	chunkSizes := make([]int64, 0, len(bundleReader.parts))
+	for i := 0; i < len(bundleReader.parts)-1; i++ {
+		chunkSizes = append(chunkSizes, bundleReader.starts[i+1]-bundleReader.starts[i])
+	}
+	if len(chunkSizes) > 0 {
+		chunkSize := slices.Max(chunkSizes)
+		for i, size := range chunkSizes {
+			if size < chunkSize {
+				result.problemf("%s is truncated: it is %d bytes long while other chunks are %d bytes", bundleReader.names[i], size, chunkSize)
+			}
+		}
+	}
+
+	entries, layoutDirs, err := verifyBlobs(ctx, bundleReader, result)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		result.problemf("%v", err)
+		return result, nil
+	}
+
+	var delta *Delta
+	if entry, found := entries[DeltaFile]; found {
+		delta = &Delta{}
+		if err = json.NewDecoder(io.NewSectionReader(bundleReader, entry.offset, entry.size)).Decode(delta); err != nil {
+			result.problemf("%s cannot be read: %v", DeltaFile, err)
+		}
+	}
+	for _, layoutDir := range layoutDirs {
+		v := &layoutVerifier{
+			bundle:   bundleReader,
+			entries:  entries,
+			dir:      layoutDir,
+			repoPath: strings.TrimPrefix(layoutDir, "."),
+			delta:    delta,
+			result:   result,
+		}
+		v.verify()
+	}
+	return result, nil
+}
+
+// verifyBlobs scans bundle tar once, hashing every blob on the way. Error is returned if tar cannot be read to the end.
+func verifyBlobs(ctx context.Context, bundleReader *chunks, result *VerifyResult) (map[string]tarEntry, []string, error) {
+	entries := map[string]tarEntry{}
+	layoutDirs := make([]string, 0)
+	stream := io.NewSectionReader(bundleReader, 0, bundleReader.size)
+	tarReader := tar.NewReader(stream)
+	for ctx.Err() == nil {
+		headerOffset, _ := stream.Seek(0, io.SeekCurrent)
+		hdr, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("bundle tar is broken in %s after offset %d: %w", bundleReader.chunkAt(headerOffset), headerOffset, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		offset, _ := stream.Seek(0, io.SeekCurrent)
+		entryName := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		entries[entryName] = tarEntry{offset: offset, size: hdr.Size}
+		if path.Base(entryName) == "index.json" {
+			layoutDirs = append(layoutDirs, path.Dir(entryName))
+		}
+
+		digest, isBlob := blobDigest(entryName)
+		if !isBlob {
+			continue
+		}
+		actual, n, err := v1.SHA256(tarReader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bundle is truncated in %s: %s ends after %d of %d bytes: %w",
+				bundleReader.chunkAt(offset+n), entryName, n, hdr.Size, err)
+		}
+		if actual != digest {
+			result.problemf("%s is corrupted: its contents have digest %s", entryName, actual)
+		}
+		result.Blobs++
+	}
+	return entries, layoutDirs, nil
+}
+
+// blobDigest parses digest of sha256 blob from its path in OCI layout.
+func blobDigest(entryName string) (v1.Hash, bool) {
+	dir, hex := path.Split(entryName)
+	if dir = path.Clean(dir); path.Base(dir) != "sha256" || path.Base(path.Dir(dir)) != "blobs" {
+		return v1.Hash{}, false
+	}
+	digest, err := v1.NewHash("sha256:" + hex)
+	return digest, err == nil
+}
+
+type layoutVerifier struct {
+	bundle   io.ReaderAt
+	entries  map[string]tarEntry
+	dir      string
+	repoPath string
+	delta    *Delta
+	result   *VerifyResult
+}
+
+func (v *layoutVerifier) verify() {
+	v.result.Layouts++
+	index := &v1.IndexManifest{}
+	if err := v.readJSON(path.Join(v.dir, "index.json"), index); err != nil {
+		v.result.problemf("%s: index cannot be read: %v", v.dir, err)
+		return
+	}
+	for _, desc := range index.Manifests {
+		v.verifyManifest(v.repoPath+":"+tagName(desc), desc)
+	}
+}
+
+func (v *layoutVerifier) verifyManifest(ref string, desc v1.Descriptor) {
+	if !v.verifyBlob(ref, "manifest", desc) {
+		return
+	}
+	v.result.Manifests++
+
+	switch {
+	case desc.MediaType.IsIndex():
+		index := &v1.IndexManifest{}
+		if err := v.readJSON(v.blobPath(desc.Digest), index); err != nil {
+			v.result.problemf("%s: index manifest cannot be read: %v", ref, err)
+			return
+		}
+		for _, child := range index.Manifests {
+			v.verifyManifest(ref+"@"+child.Digest.String(), child)
+		}
+	case desc.MediaType.IsImage():
+		manifest := &v1.Manifest{}
+		if err := v.readJSON(v.blobPath(desc.Digest), manifest); err != nil {
+			v.result.problemf("%s: manifest cannot be read: %v", ref, err)
+			return
+		}
+		v.verifyConfig(ref, manifest)
+		for _, layer := range manifest.Layers {
+			if !layer.MediaType.IsDistributable() || v.delta.Omits(v.repoPath, layer.Digest) {
+				continue
+			}
+			v.verifyBlob(ref, "layer", layer)
+		}
+	}
+}
+
+func (v *layoutVerifier) verifyConfig(ref string, manifest *v1.Manifest) {
+	if !v.verifyBlob(ref, "config", manifest.Config) {
+		return
+	}
+	if manifest.Config.MediaType != types.OCIConfigJSON && manifest.Config.MediaType != types.DockerConfigJSON {
+		return
+	}
+
+	config := &v1.ConfigFile{}
+	if err := v.readJSON(v.blobPath(manifest.Config.Digest), config); err != nil {
+		v.result.problemf("%s: config cannot be read: %v", ref, err)
+		return
+	}
+	if diffIDs, layers := len(config.RootFS.DiffIDs), len(manifest.Layers); diffIDs != layers {
+		v.result.problemf("%s: config lists %d layers while manifest lists %d", ref, diffIDs, layers)
+	}
+}
+
+// verifyBlob reports whether blob described by desc is stored in the layout with declared size.
+func (v *layoutVerifier) verifyBlob(ref, kind string, desc v1.Descriptor) bool {
+	entry, found := v.entries[v.blobPath(desc.Digest)]
+	switch {
+	case !found:
+		v.result.problemf("%s: %s %s is missing", ref, kind, desc.Digest)
+		return false
+	case entry.size != desc.Size:
+		v.result.problemf("%s: %s %s is %d bytes long while %d bytes are declared", ref, kind, desc.Digest, entry.size, desc.Size)
+		return false
+	}
+	return true
+}
+
+func (v *layoutVerifier) blobPath(digest v1.Hash) string {
+	return path.Join(v.dir, "blobs", digest.Algorithm, digest.Hex)
+}
+
+func (v *layoutVerifier) readJSON(entryName string, target any) error {
+	entry, found := v.entries[entryName]
+	if !found {
+		return fmt.Errorf("%s is missing from bundle", entryName)
+	}
+	return json.NewDecoder(io.NewSectionReader(v.bundle, entry.offset, entry.size)).Decode(target)
+}
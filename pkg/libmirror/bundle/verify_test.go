@@ -0,0 +1,97 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestVerifyIntactChunkedBundle(t *testing.T) {
+	packFromDir, bundleDir := t.TempDir(), t.TempDir()
+	appendTaggedImage(t, packFromDir, "v1.60.0")
+	appendTaggedImage(t, filepath.Join(packFromDir, "install"), "v1.60.0")
+	bundlePath := filepath.Join(bundleDir, "d8.tar")
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext:     contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: packFromDir},
+		BundleChunkSize: 64 * 1024,
+	}))
+
+	result, err := Verify(context.Background(), bundlePath)
+	require.NoError(t, err)
+	require.True(t, result.OK(), result.Problems)
+	require.Equal(t, 2, result.Layouts)
+	require.Equal(t, 2, result.Manifests)
+	require.Equal(t, 8, result.Blobs)
+}
+
+func TestVerifyReportsCorruptedLayer(t *testing.T) {
+	packFromDir, bundleDir := t.TempDir(), t.TempDir()
+	appendTaggedImage(t, packFromDir, "v1.60.0")
+	contents := packAndReadContents(t, packFromDir, bundleDir)
+	layer := contents.Repository("").Tag("v1.60.0").Layers[0]
+
+	layerPath := filepath.Join(packFromDir, "blobs", layer.Digest.Algorithm, layer.Digest.Hex)
+	require.NoError(t, os.WriteFile(layerPath, bytes.Repeat([]byte{0}, int(layer.Size)), 0o644))
+	bundlePath := filepath.Join(bundleDir, "d8.tar")
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext: contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: packFromDir},
+	}))
+
+	result, err := Verify(context.Background(), bundlePath)
+	require.NoError(t, err)
+	require.Len(t, result.Problems, 1)
+	require.Contains(t, result.Problems[0], "blobs/sha256/"+layer.Digest.Hex+" is corrupted")
+}
+
+func TestVerifyReportsTruncatedChunk(t *testing.T) {
+	packFromDir, bundleDir := t.TempDir(), t.TempDir()
+	appendTaggedImage(t, packFromDir, "v1.60.0")
+	bundlePath := filepath.Join(bundleDir, "d8.tar")
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext:     contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: packFromDir},
+		BundleChunkSize: 16 * 1024,
+	}))
+	chunks, err := FindBundleFiles(bundlePath)
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 2)
+	require.NoError(t, os.Truncate(chunks[1], 1000))
+
+	result, err := Verify(context.Background(), bundlePath)
+	require.NoError(t, err)
+	require.False(t, result.OK())
+	require.Contains(t, result.Problems[0], filepath.Base(chunks[1])+" is truncated")
+}
+
+func packAndReadContents(t *testing.T, packFromDir, bundleDir string) *Contents {
+	t.Helper()
+
+	bundlePath := filepath.Join(bundleDir, "original.tar")
+	require.NoError(t, Pack(&contexts.PullContext{
+		BaseContext: contexts.BaseContext{BundlePath: bundlePath, UnpackedImagesPath: packFromDir},
+	}))
+	contents, err := ReadContents(bundlePath)
+	require.NoError(t, err)
+	return contents
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+)
+
+// bundleSource reads repositories directly from tar bundle, without unpacking it.
+// It is used for bundles stored in S3, which are read with ranged requests.
+type bundleSource struct {
+	path string
+
+	once     sync.Once
+	contents *bundle.Contents
+	err      error
+}
+
+func (s *bundleSource) String() string { return s.path }
+
+func (s *bundleSource) load() (*bundle.Contents, error) {
+	s.once.Do(func() {
+		s.contents, s.err = bundle.ReadContents(s.path)
+		if s.err != nil {
+			s.err = fmt.Errorf("read bundle %s: %w", s.path, s.err)
+		}
+	})
+	return s.contents, s.err
+}
+
+func (s *bundleSource) repository(repo string) (*bundle.Repository, error) {
+	contents, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	repository := contents.Repository(repo)
+	if repository == nil {
+		return nil, ErrRepositoryNotFound
+	}
+	return repository, nil
+}
+
+func (s *bundleSource) listCatalog(_ context.Context) ([]string, bool, error) {
+	contents, err := s.load()
+	if err != nil {
+		return nil, false, err
+	}
+	repos := make([]string, 0, len(contents.Repositories))
+	for _, repo := range contents.Repositories {
+		repos = append(repos, repo.Path)
+	}
+	return repos, true, nil
+}
+
+func (s *bundleSource) listModules(_ context.Context) ([]string, error) {
+	contents, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	modules := make([]string, 0)
+	for _, repo := range contents.Repositories {
+		module, isModule := strings.CutPrefix(repo.Path, "modules/")
+		if isModule && !strings.Contains(module, "/") {
+			modules = append(modules, module)
+		}
+	}
+	return modules, nil
+}
+
+func (s *bundleSource) listTags(_ context.Context, repo string) ([]string, error) {
+	repository, err := s.repository(repo)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0, len(repository.Tags))
+	for _, tag := range repository.Tags {
+		tags = append(tags, tag.Name)
+	}
+	return tags, nil
+}
+
+func (s *bundleSource) getImageInfo(_ context.Context, repo, tag string) (*imageInfo, error) {
+	repository, err := s.repository(repo)
+	if err != nil {
+		return nil, err
+	}
+	bundleTag := repository.Tag(tag)
+	if bundleTag == nil {
+		return nil, fmt.Errorf("tag %s: %w", tag, fs.ErrNotExist)
+	}
+
+	digest, err := v1.NewHash(bundleTag.Digest)
+	if err != nil {
+		return nil, err
+	}
+	info := &imageInfo{digest: digest}
+	for _, layer := range bundleTag.Layers {
+		info.layers = append(info.layers, layer.Digest)
+	}
+	return info, nil
+}
+
+func (s *bundleSource) hasBlob(_ context.Context, repo string, digest v1.Hash) (bool, error) {
+	repository, err := s.repository(repo)
+	if err != nil {
+		return false, err
+	}
+	return repository.HasBlob(digest), nil
+}
+
+func (s *bundleSource) tagDigests(_ context.Context, repo string) (layouts.SegmentTags, error) {
+	repository, err := s.repository(repo)
+	if err != nil {
+		return nil, err
+	}
+	tags := layouts.SegmentTags{}
+	for _, tag := range repository.Tags {
+		digest, err := v1.NewHash(tag.Digest)
+		if err != nil {
+			return nil, err
+		}
+		tags.Add(tag.Name, digest)
+	}
+	return tags, nil
+}
@@ -0,0 +1,892 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package compare checks that Deckhouse repositories of one registry or bundle are mirrored to another one intact.
+package compare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flatten"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/regcaps"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/s3"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/taglist"
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+// OCILayoutScheme prefixes paths to unpacked bundles, so they can be compared like registries.
+const OCILayoutScheme = "oci-layout://"
+
+const rootRepositoryName = "<root>"
+
+var ErrRepositoryNotFound = errors.New("repository not found")
+
+// catalogUnsupportedStatuses are returned by registries that have catalog API disabled or restricted to administrators.
+var catalogUnsupportedStatuses = []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusMethodNotAllowed}
+
+// serviceTags are created by d8 itself and do not belong to mirrored content.
+var serviceTags = []string{"d8WriteCheck"}
+
+// signatureTagRegexp matches tags of cosign signatures, attestations and SBOMs, "sha256-<hex>.sig" and alike,
+// and of OCI referrers tag schema indexes, which are "sha256-<hex>" of the image they refer to.
+var signatureTagRegexp = regexp.MustCompile(`^sha256-[0-9a-f]{64}(\.(sig|att|sbom))?$`)
+
+// Scope narrows comparison down to a single Deckhouse release and selected modules, e.g. to check the release
+// a CD pipeline is going to install. Nil scope compares everything.
+type Scope struct {
+	// DeckhouseTag limits Deckhouse, installers and release channel repositories to images of this tag, like "v1.60.3".
+	DeckhouseTag string `json:"deckhouseTag,omitempty"`
+	// Modules limit module repositories to the ones of these modules.
+	Modules []string `json:"modules,omitempty"`
+}
+
+// includesRepository reports whether repository at segment, relative to Deckhouse repo root, is compared.
+func (s *Scope) includesRepository(segment string) bool {
+	if s == nil || len(s.Modules) == 0 {
+		return true
+	}
+	modulePath, isModule := strings.CutPrefix(segment, "modules/")
+	if !isModule {
+		return true
+	}
+	module, _, _ := strings.Cut(modulePath, "/")
+	return slices.Contains(s.Modules, module)
+}
+
+// includesTag reports whether tag of repository at segment is compared.
+func (s *Scope) includesTag(edition *contexts.Edition, segment, tag string) bool {
+	if s == nil || s.DeckhouseTag == "" || !slices.Contains(edition.PlatformSegments(), segment) {
+		return true
+	}
+	return tag == s.DeckhouseTag
+}
+
+// SkipRules select tags of both source and target that are left out of comparison.
+// Zero value skips nothing.
+type SkipRules struct {
+	// Tags are skipped if equal to any of these.
+	Tags []string
+	// Suffixes skip tags ending with any of these.
+	Suffixes []string
+	// Patterns skip tags they match.
+	Patterns []*regexp.Regexp
+}
+
+// DefaultSkipRules leave out tags created by d8 for its own needs and, unless includeSignatures is set,
+// cosign signatures, attestations, SBOMs and OCI referrers tag schema indexes.
+func DefaultSkipRules(includeSignatures bool) *SkipRules {
+	rules := &SkipRules{Tags: slices.Clone(serviceTags)}
+	if !includeSignatures {
+		rules.Patterns = append(rules.Patterns, signatureTagRegexp)
+	}
+	return rules
+}
+
+// Skips reports whether tag is left out of comparison. Nil rules skip nothing.
+func (r *SkipRules) Skips(tag string) bool {
+	if r == nil {
+		return false
+	}
+	if slices.Contains(r.Tags, tag) {
+		return true
+	}
+	for _, suffix := range r.Suffixes {
+		if strings.HasSuffix(tag, suffix) {
+			return true
+		}
+	}
+	for _, pattern := range r.Patterns {
+		if pattern.MatchString(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+type ComparatorOptions struct {
+	SourceAuth    authn.Authenticator
+	TargetAuth    authn.Authenticator
+	Insecure      bool
+	SkipTLSVerify bool
+
+	// Deep enables checking that every layer of every image is present in target, not only manifests.
+	Deep bool
+
+	// TargetMapping is set when target was pushed with flattened or renamed repositories, as recorded by d8 mirror push.
+	TargetMapping *flatten.Mapping
+
+	// ModulesPathSuffix is the path of modules repositories in registries, as set by --modules-path-suffix
+	// of d8 mirror pull and push. OCI layouts always keep modules under contexts.DefaultModulesPathSuffix.
+	ModulesPathSuffix string
+	// Edition selects repositories of Deckhouse repo that are compared, contexts.EnterpriseEdition if nil.
+	// Module repositories are discovered separately.
+	Edition *contexts.Edition
+	// Scope narrows comparison down to a single Deckhouse release and selected modules, everything is compared if nil.
+	Scope *Scope
+
+	// FailOnExtra makes extra repositories and images of target inconsistencies, for policies
+	// that require target to contain nothing beyond mirrored contents.
+	FailOnExtra bool
+	// ExtraAllowlist are path.Match patterns of extra repositories or images ("repo:tag")
+	// that are tolerated with FailOnExtra, e.g. "custom/*" or "install:*-debug".
+	ExtraAllowlist []string
+
+	// SkipRules select tags that are not compared, DefaultSkipRules(false) are used if nil.
+	SkipRules *SkipRules
+}
+
+// RegistryComparator compares contents of Deckhouse repositories in two registries or OCI layouts.
+// Both source and target may either be registry repo references, oci-layout:// paths to unpacked bundles
+// or s3:// URLs of tar bundles stored in S3.
+type RegistryComparator struct {
+	source    imageSource
+	target    imageSource
+	deep      bool
+	skipRules *SkipRules
+	edition   *contexts.Edition
+	scope     *Scope
+
+	failOnExtra    bool
+	extraAllowlist []string
+}
+
+type ComparisonReport struct {
+	reportschema.Header
+	Timing reportschema.Timing `json:"timing"`
+
+	Source string `json:"source"`
+	Target string `json:"target"`
+
+	ComparedRepositories int `json:"comparedRepositories"`
+	ComparedImages       int `json:"comparedImages"`
+
+	MissingRepositories []string        `json:"missingRepositories"`
+	ExtraRepositories   []string        `json:"extraRepositories"`
+	MissingImages       []string        `json:"missingImages"`
+	ExtraImages         []string        `json:"extraImages"`
+	MismatchedImages    []ImageMismatch `json:"mismatchedImages"`
+
+	// TagConflicts are tags of target that refer to inconsistent images, see layouts.FindTagConflicts.
+	TagConflicts []layouts.TagConflict `json:"tagConflicts"`
+
+	// UndiscoveredRepositories are repositories of target listed by registry catalog API that were not found by probing
+	// known segments, e.g. ones pushed by newer d8 versions or by hand. They are not compared.
+	UndiscoveredRepositories []string `json:"undiscoveredRepositories"`
+
+	// TargetCapabilities are optional APIs detected in target registry, nil if target is not a registry or probing failed.
+	TargetCapabilities *regcaps.Capabilities `json:"targetCapabilities,omitempty"`
+
+	// Guidance lists remediation advice for recognized patterns of inconsistencies, like RecompressionGuidance.
+	Guidance []string `json:"guidance,omitempty"`
+
+	// Scope is ComparatorOptions.Scope comparison was narrowed down to, if any.
+	Scope *Scope `json:"scope,omitempty"`
+
+	// FailOnExtra is set when extra repositories and images were not allowed by ComparatorOptions.FailOnExtra.
+	FailOnExtra bool `json:"failOnExtra"`
+	// AllowedExtras are extra repositories and images of target matched by ComparatorOptions.ExtraAllowlist.
+	AllowedExtras []string `json:"allowedExtras,omitempty"`
+}
+
+type ImageMismatch struct {
+	Image         string   `json:"image"`
+	SourceDigest  string   `json:"sourceDigest"`
+	TargetDigest  string   `json:"targetDigest"`
+	MissingLayers []string `json:"missingLayers,omitempty"`
+
+	// Recompressed is set when layers of target image have the same uncompressed contents (diffIDs) as source ones,
+	// but different compressed digests, which happens when registry, like Nexus, recompresses uploaded blobs.
+	Recompressed bool `json:"recompressed,omitempty"`
+}
+
+// RecompressionGuidance explains how to deal with images recompressed by target registry.
+const RecompressionGuidance = "Target registry recompressed image layers: their contents are intact, " +
+	"but digests differ from the source ones, so clusters will fail to pull images by digest. " +
+	"Disable blob recompression or content rewriting in target registry (for Nexus, check repository " +
+	"and proxy settings that alter uploaded blobs), delete affected repositories and push the bundle again."
+
+// IsConsistent reports whether target contains everything source has.
+// Extra contents of target are allowed unless comparison was made with ComparatorOptions.FailOnExtra.
+func (r *ComparisonReport) IsConsistent() bool {
+	if r.FailOnExtra && (len(r.ExtraRepositories) > 0 || len(r.ExtraImages) > 0) {
+		return false
+	}
+	return len(r.MissingRepositories) == 0 &&
+		len(r.MissingImages) == 0 &&
+		len(r.MismatchedImages) == 0 &&
+		len(r.TagConflicts) == 0
+}
+
+func NewRegistryComparator(source, target string, opts ComparatorOptions) *RegistryComparator {
+	if opts.SkipRules == nil {
+		opts.SkipRules = DefaultSkipRules(false)
+	}
+	tagLister := taglist.NewLister()
+	return &RegistryComparator{
+		source:    newImageSource(source, opts.SourceAuth, nil, tagLister, opts),
+		target:    newImageSource(target, opts.TargetAuth, opts.TargetMapping, tagLister, opts),
+		deep:      opts.Deep,
+		skipRules: opts.SkipRules,
+		edition:   opts.Edition,
+		scope:     opts.Scope,
+
+		failOnExtra:    opts.FailOnExtra,
+		extraAllowlist: opts.ExtraAllowlist,
+	}
+}
+
+func (c *RegistryComparator) Compare(ctx context.Context) (*ComparisonReport, error) {
+	start := time.Now()
+	report := &ComparisonReport{
+		Header:              reportschema.NewHeader(reportschema.KindComparisonReport),
+		Source:              c.source.String(),
+		Target:              c.target.String(),
+		MissingRepositories: make([]string, 0),
+		ExtraRepositories:   make([]string, 0),
+		MissingImages:       make([]string, 0),
+		ExtraImages:         make([]string, 0),
+		MismatchedImages:    make([]ImageMismatch, 0),
+
+		UndiscoveredRepositories: make([]string, 0),
+		Scope:                    c.scope,
+		FailOnExtra:              c.failOnExtra,
+	}
+
+	if target, isRegistry := c.target.(*registrySource); isRegistry {
+		// Probing failures are not fatal, comparison just does not get to skip unsupported API calls
+		report.TargetCapabilities, _ = target.probeCapabilities(ctx)
+	}
+
+	sourceRepos, err := discoverRepositories(ctx, c.source, c.edition, c.scope, c.skipRules)
+	if err != nil {
+		return nil, fmt.Errorf("discover repositories of %s: %w", c.source, err)
+	}
+	targetRepos, err := discoverRepositories(ctx, c.target, c.edition, c.scope, c.skipRules)
+	if err != nil {
+		return nil, fmt.Errorf("discover repositories of %s: %w", c.target, err)
+	}
+
+	for _, repo := range sortedKeys(sourceRepos) {
+		if _, found := targetRepos[repo]; !found {
+			report.MissingRepositories = append(report.MissingRepositories, displayRepo(repo))
+			continue
+		}
+		if err = c.compareRepository(ctx, repo, sourceRepos[repo], targetRepos[repo], report); err != nil {
+			return nil, fmt.Errorf("compare %s: %w", displayRepo(repo), err)
+		}
+		report.ComparedRepositories++
+	}
+	for _, repo := range sortedKeys(targetRepos) {
+		if _, found := sourceRepos[repo]; !found {
+			report.ExtraRepositories = append(report.ExtraRepositories, displayRepo(repo))
+		}
+	}
+
+	if c.failOnExtra {
+		c.separateAllowedExtras(report)
+	}
+
+	if report.TagConflicts, err = findTagConflicts(ctx, c.target, c.edition, targetRepos); err != nil {
+		return nil, fmt.Errorf("check tags consistency of %s: %w", c.target, err)
+	}
+	if report.UndiscoveredRepositories, err = findUndiscoveredRepositories(ctx, c.target, c.edition); err != nil {
+		return nil, fmt.Errorf("cross-check repositories of %s with catalog: %w", c.target, err)
+	}
+
+	report.Timing = reportschema.TimingSince(start)
+	return report, nil
+}
+
+// separateAllowedExtras moves extras matched by allowlist out of the ones that fail the comparison.
+func (c *RegistryComparator) separateAllowedExtras(report *ComparisonReport) {
+	isAllowed := func(extra string) bool {
+		for _, pattern := range c.extraAllowlist {
+			if matched, _ := path.Match(pattern, extra); matched {
+				report.AllowedExtras = append(report.AllowedExtras, extra)
+				return true
+			}
+		}
+		return false
+	}
+	report.ExtraRepositories = slices.DeleteFunc(report.ExtraRepositories, isAllowed)
+	report.ExtraImages = slices.DeleteFunc(report.ExtraImages, isAllowed)
+}
+
+func (c *RegistryComparator) compareRepository(
+	ctx context.Context,
+	repo string,
+	sourceTags, targetTags map[string]struct{},
+	report *ComparisonReport,
+) error {
+	for _, tag := range sortedKeys(sourceTags) {
+		image := displayRepo(repo) + ":" + tag
+		if _, found := targetTags[tag]; !found {
+			report.MissingImages = append(report.MissingImages, image)
+			continue
+		}
+
+		sourceInfo, err := c.source.getImageInfo(ctx, repo, tag)
+		if err != nil {
+			return fmt.Errorf("get %s from %s: %w", image, c.source, err)
+		}
+		targetInfo, err := c.target.getImageInfo(ctx, repo, tag)
+		if err != nil {
+			return fmt.Errorf("get %s from %s: %w", image, c.target, err)
+		}
+		report.ComparedImages++
+
+		mismatch := ImageMismatch{
+			Image:        image,
+			SourceDigest: sourceInfo.digest.String(),
+			TargetDigest: targetInfo.digest.String(),
+		}
+		if c.deep {
+			for _, layer := range sourceInfo.layers {
+				present, err := c.target.hasBlob(ctx, repo, layer)
+				if err != nil {
+					return fmt.Errorf("check layer %s of %s in %s: %w", layer, image, c.target, err)
+				}
+				if !present {
+					mismatch.MissingLayers = append(mismatch.MissingLayers, layer.String())
+				}
+			}
+		}
+		if sourceInfo.digest != targetInfo.digest || len(mismatch.MissingLayers) > 0 {
+			if mismatch.Recompressed, err = isRecompressed(sourceInfo, targetInfo); err != nil {
+				return fmt.Errorf("compare layers contents of %s: %w", image, err)
+			}
+			if mismatch.Recompressed && !slices.Contains(report.Guidance, RecompressionGuidance) {
+				report.Guidance = append(report.Guidance, RecompressionGuidance)
+			}
+			report.MismatchedImages = append(report.MismatchedImages, mismatch)
+		}
+	}
+
+	for _, tag := range sortedKeys(targetTags) {
+		if _, found := sourceTags[tag]; !found {
+			report.ExtraImages = append(report.ExtraImages, displayRepo(repo)+":"+tag)
+		}
+	}
+	return nil
+}
+
+// findTagConflicts checks that release channels of Deckhouse and installers repos point to the same versions
+// and that no tag refers to several images, which is only possible in OCI layouts.
+func findTagConflicts(ctx context.Context, source imageSource, edition *contexts.Edition, repos map[string]map[string]struct{}) ([]layouts.TagConflict, error) {
+	segments := make(map[string]layouts.SegmentTags)
+	for _, repo := range edition.ChannelSegments() {
+		if _, found := repos[repo]; !found {
+			continue
+		}
+		tags, err := source.tagDigests(ctx, repo)
+		if err != nil {
+			return nil, fmt.Errorf("get tags of %s: %w", displayRepo(repo), err)
+		}
+		segments[repo] = tags
+	}
+	return layouts.FindTagConflicts(segments), nil
+}
+
+// findUndiscoveredRepositories lists repositories under the source root with catalog API, if source supports it,
+// and returns those that are not probed by discoverRepositories.
+func findUndiscoveredRepositories(ctx context.Context, source imageSource, edition *contexts.Edition) ([]string, error) {
+	catalog, isCatalogSource := source.(catalogSource)
+	if !isCatalogSource {
+		return make([]string, 0), nil
+	}
+	repos, supported, err := catalog.listCatalog(ctx)
+	if err != nil || !supported {
+		return make([]string, 0), err
+	}
+
+	segments, err := probedSegments(ctx, source, edition)
+	if err != nil {
+		return nil, err
+	}
+	probed := make(map[string]struct{}, len(segments)+1)
+	for _, segment := range segments {
+		probed[segment] = struct{}{}
+	}
+	probed["modules"] = struct{}{} // Holds the list of modules, not the images
+
+	undiscovered := make([]string, 0)
+	for _, repo := range repos {
+		if _, found := probed[repo]; !found {
+			undiscovered = append(undiscovered, displayRepo(repo))
+		}
+	}
+	sort.Strings(undiscovered)
+	return undiscovered, nil
+}
+
+// probedSegments returns paths of all repositories relative to source root that comparison looks for,
+// which are repositories of edition and of modules found in source.
+func probedSegments(ctx context.Context, source imageSource, edition *contexts.Edition) ([]string, error) {
+	segments := edition.Segments()
+	modules, err := source.listModules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list modules: %w", err)
+	}
+	for _, module := range modules {
+		segments = append(segments, path.Join("modules", module), path.Join("modules", module, "release"))
+	}
+	return segments, nil
+}
+
+// discoverRepositories returns tags of every existing repository of source within scope, keyed by path relative to source root.
+func discoverRepositories(
+	ctx context.Context,
+	source imageSource,
+	edition *contexts.Edition,
+	scope *Scope,
+	skipRules *SkipRules,
+) (map[string]map[string]struct{}, error) {
+	segments, err := probedSegments(ctx, source, edition)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make(map[string]map[string]struct{})
+	for _, segment := range segments {
+		if !scope.includesRepository(segment) {
+			continue
+		}
+		tags, err := source.listTags(ctx, segment)
+		switch {
+		case errors.Is(err, ErrRepositoryNotFound):
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("list tags of %s: %w", displayRepo(segment), err)
+		}
+
+		tagSet := make(map[string]struct{}, len(tags))
+		for _, tag := range tags {
+			if !skipRules.Skips(tag) && scope.includesTag(edition, segment, tag) {
+				tagSet[tag] = struct{}{}
+			}
+		}
+		// Bundles contain empty layouts for repositories that had nothing to pull, those are never pushed.
+		if len(tagSet) == 0 {
+			continue
+		}
+		repos[segment] = tagSet
+	}
+	return repos, nil
+}
+
+func displayRepo(repo string) string {
+	if repo == "" {
+		return rootRepositoryName
+	}
+	return repo
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type imageInfo struct {
+	digest v1.Hash
+	layers []v1.Hash
+	// image is nil for indexes.
+	image v1.Image
+}
+
+// isRecompressed reports whether images have layers with the same uncompressed contents but different digests.
+// Config files with diffIDs are only fetched here, as they are not needed to compare consistent images.
+func isRecompressed(source, target *imageInfo) (bool, error) {
+	if source.image == nil || target.image == nil || slices.Equal(source.layers, target.layers) {
+		return false, nil
+	}
+
+	sourceConfig, err := source.image.ConfigFile()
+	if err != nil {
+		return false, err
+	}
+	targetConfig, err := target.image.ConfigFile()
+	if err != nil {
+		return false, err
+	}
+	return len(sourceConfig.RootFS.DiffIDs) > 0 && slices.Equal(sourceConfig.RootFS.DiffIDs, targetConfig.RootFS.DiffIDs), nil
+}
+
+// imageSource provides read access to repositories of either registry or unpacked bundle.
+// Repositories are addressed by their paths relative to the root of Deckhouse repo.
+type imageSource interface {
+	fmt.Stringer
+	listModules(ctx context.Context) ([]string, error)
+	listTags(ctx context.Context, repo string) ([]string, error)
+	getImageInfo(ctx context.Context, repo, tag string) (*imageInfo, error)
+	hasBlob(ctx context.Context, repo string, digest v1.Hash) (bool, error)
+	// tagDigests returns digests of images referred to by release channel and version tags of the repo.
+	tagDigests(ctx context.Context, repo string) (layouts.SegmentTags, error)
+}
+
+// catalogSource is implemented by sources that can list all of their repositories at once.
+type catalogSource interface {
+	// listCatalog returns paths of all repositories relative to source root,
+	// supported is false if source is unable to list them, e.g. because registry has catalog API disabled.
+	listCatalog(ctx context.Context) (repos []string, supported bool, err error)
+}
+
+func newImageSource(
+	ref string,
+	authProvider authn.Authenticator,
+	mapping *flatten.Mapping,
+	tagLister *taglist.Lister,
+	opts ComparatorOptions,
+) imageSource {
+	if layoutPath, isLayout := strings.CutPrefix(ref, OCILayoutScheme); isLayout {
+		return &layoutSource{root: filepath.Clean(layoutPath)}
+	}
+	if s3.IsURL(ref) {
+		return &bundleSource{path: ref}
+	}
+
+	if authProvider == nil {
+		authProvider = authn.Anonymous
+	}
+	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptions(authProvider, opts.Insecure, opts.SkipTLSVerify)
+	return &registrySource{
+		root:         strings.TrimSuffix(ref, "/"),
+		mapping:      mapping,
+		authProvider: authProvider,
+		nameOpts:     nameOpts,
+		remoteOpts:   remoteOpts,
+		tagLister:    tagLister,
+		transport:    auth.MakeTransport(opts.SkipTLSVerify),
+		skipRules:    opts.SkipRules,
+
+		modulesPathSuffix: opts.ModulesPathSuffix,
+	}
+}
+
+type registrySource struct {
+	root         string
+	mapping      *flatten.Mapping
+	authProvider authn.Authenticator
+	nameOpts     []name.Option
+	remoteOpts   []remote.Option
+	tagLister    *taglist.Lister
+	transport    http.RoundTripper
+	skipRules    *SkipRules
+
+	// capabilities are set by probeCapabilities to skip calls of APIs that registry does not implement.
+	capabilities *regcaps.Capabilities
+
+	modulesPathSuffix string
+}
+
+func (s *registrySource) String() string { return s.root }
+
+func (s *registrySource) repository(repo string) (name.Repository, error) {
+	repo = contexts.RegistrySegment(repo, s.modulesPathSuffix)
+	if s.mapping != nil {
+		// Repositories missing from mapping were not pushed, but may still be compared to find out they are missing.
+		return name.NewRepository(s.mapping.Resolve(repo), s.nameOpts...)
+	}
+	return name.NewRepository(strings.TrimSuffix(path.Join(s.root, repo), "/"), s.nameOpts...)
+}
+
+// probeCapabilities detects read-only APIs implemented by registry, comparison never writes anything to it.
+func (s *registrySource) probeCapabilities(ctx context.Context) (*regcaps.Capabilities, error) {
+	root, err := s.repository("")
+	if err != nil {
+		return nil, err
+	}
+	s.capabilities, err = regcaps.Probe(ctx, root, regcaps.Options{Auth: s.authProvider, Transport: s.transport})
+	return s.capabilities, err
+}
+
+func (s *registrySource) listCatalog(ctx context.Context) ([]string, bool, error) {
+	// Flattened repositories are not nested under the root, so catalog cannot be scoped to it
+	if s.mapping != nil {
+		return nil, false, nil
+	}
+	if s.capabilities != nil && s.capabilities.Catalog == regcaps.Unsupported {
+		return nil, false, nil
+	}
+
+	root, err := s.repository("")
+	if err != nil {
+		return nil, false, err
+	}
+	allRepos, err := remote.Catalog(ctx, root.Registry, s.remoteOpts...)
+	if err != nil {
+		var transportErr *transport.Error
+		if errors.As(err, &transportErr) && slices.Contains(catalogUnsupportedStatuses, transportErr.StatusCode) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("list catalog of %s: %w", root.RegistryStr(), err)
+	}
+
+	rootPath := root.RepositoryStr()
+	repos := make([]string, 0)
+	for _, repo := range allRepos {
+		if repo == rootPath {
+			repos = append(repos, "")
+			continue
+		}
+		if relPath, isNested := strings.CutPrefix(repo, rootPath+"/"); isNested {
+			repos = append(repos, contexts.BundleSegment(relPath, s.modulesPathSuffix))
+		}
+	}
+	return repos, true, nil
+}
+
+func (s *registrySource) listModules(ctx context.Context) ([]string, error) {
+	modules, err := s.listTags(ctx, "modules")
+	if errors.Is(err, ErrRepositoryNotFound) {
+		return nil, nil
+	}
+	return modules, err
+}
+
+func (s *registrySource) listTags(ctx context.Context, repo string) ([]string, error) {
+	repository, err := s.repository(repo)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.tagLister.List(ctx, repository, s.transport, s.remoteOpts...)
+	if err != nil {
+		if errorutil.IsRepoNotFoundError(err) || errorutil.IsImageNotFoundError(err) {
+			return nil, ErrRepositoryNotFound
+		}
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (s *registrySource) getImageInfo(ctx context.Context, repo, tag string) (*imageInfo, error) {
+	repository, err := s.repository(repo)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := remote.Get(repository.Tag(tag), append(s.remoteOpts, remote.WithContext(ctx))...)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &imageInfo{digest: desc.Digest}
+	if desc.MediaType.IsIndex() {
+		return info, nil
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return nil, err
+	}
+	info.image = img
+	if info.layers, err = layerDigests(img); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s *registrySource) hasBlob(ctx context.Context, repo string, digest v1.Hash) (bool, error) {
+	repository, err := s.repository(repo)
+	if err != nil {
+		return false, err
+	}
+	l, err := remote.Layer(repository.Digest(digest.String()), append(s.remoteOpts, remote.WithContext(ctx))...)
+	if err != nil {
+		return false, err
+	}
+	blob, err := l.Compressed()
+	if err != nil {
+		if errorutil.IsImageNotFoundError(err) || strings.Contains(err.Error(), "BLOB_UNKNOWN") {
+			return false, nil
+		}
+		return false, err
+	}
+	_ = blob.Close()
+	return true, nil
+}
+
+// layoutSource reads repositories from OCI layouts of unpacked bundle, as created by d8 mirror pull.
+type layoutSource struct {
+	root string
+}
+
+func (s *registrySource) tagDigests(ctx context.Context, repo string) (layouts.SegmentTags, error) {
+	repository, err := s.repository(repo)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.listTags(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	result := layouts.SegmentTags{}
+	for _, tag := range tags {
+		if s.skipRules.Skips(tag) || layouts.IsDigestTag(tag) {
+			continue
+		}
+		desc, err := remote.Head(repository.Tag(tag), append(s.remoteOpts, remote.WithContext(ctx))...)
+		if err != nil {
+			return nil, fmt.Errorf("get digest of %s: %w", tag, err)
+		}
+		result.Add(tag, desc.Digest)
+	}
+	return result, nil
+}
+
+func (s *layoutSource) String() string { return OCILayoutScheme + s.root }
+
+func (s *layoutSource) listModules(_ context.Context) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.root, "modules"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	modules := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			modules = append(modules, entry.Name())
+		}
+	}
+	return modules, nil
+}
+
+func (s *layoutSource) index(repo string) (layout.Path, *v1.IndexManifest, error) {
+	l, err := layout.FromPath(filepath.Join(s.root, filepath.FromSlash(repo)))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil, ErrRepositoryNotFound
+		}
+		return "", nil, err
+	}
+	index, err := l.ImageIndex()
+	if err != nil {
+		return "", nil, err
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return "", nil, err
+	}
+	return l, manifest, nil
+}
+
+func (s *layoutSource) listTags(_ context.Context, repo string) ([]string, error) {
+	_, manifest, err := s.index(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0, len(manifest.Manifests))
+	for _, desc := range manifest.Manifests {
+		if tag, found := desc.Annotations["io.deckhouse.image.short_tag"]; found {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func (s *layoutSource) getImageInfo(_ context.Context, repo, tag string) (*imageInfo, error) {
+	l, manifest, err := s.index(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, desc := range manifest.Manifests {
+		if desc.Annotations["io.deckhouse.image.short_tag"] != tag {
+			continue
+		}
+
+		info := &imageInfo{digest: desc.Digest}
+		if desc.MediaType.IsIndex() {
+			return info, nil
+		}
+		img, err := l.Image(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		info.image = img
+		if info.layers, err = layerDigests(img); err != nil {
+			return nil, err
+		}
+		return info, nil
+	}
+
+	return nil, fmt.Errorf("tag %s: %w", tag, fs.ErrNotExist)
+}
+
+func (s *layoutSource) hasBlob(_ context.Context, repo string, digest v1.Hash) (bool, error) {
+	_, err := os.Stat(filepath.Join(s.root, filepath.FromSlash(repo), "blobs", digest.Algorithm, digest.Hex))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, fs.ErrNotExist):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (s *layoutSource) tagDigests(_ context.Context, repo string) (layouts.SegmentTags, error) {
+	l, _, err := s.index(repo)
+	if err != nil {
+		return nil, err
+	}
+	return layouts.LayoutTags(l)
+}
+
+func layerDigests(img v1.Image) ([]v1.Hash, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	digests := make([]v1.Hash, 0, len(layers))
+	for _, l := range layers {
+		digest, err := l.Digest()
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, digest)
+	}
+	return digests, nil
+}
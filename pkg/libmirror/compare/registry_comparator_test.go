@@ -0,0 +1,341 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/operations"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flatten"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/regcaps"
+	mirrorTestUtils "github.com/deckhouse/deckhouse-cli/testing/util/mirror"
+)
+
+func TestRegistryComparatorWithOCILayouts(t *testing.T) {
+	oldBundle, newBundle := t.TempDir(), t.TempDir()
+
+	shared := randomImage(t)
+	appendImageToLayout(t, filepath.Join(oldBundle, "install"), "v1.55.7", shared)
+	appendImageToLayout(t, filepath.Join(newBundle, "install"), "v1.55.7", shared)
+	appendImageToLayout(t, filepath.Join(oldBundle, "install"), "stable", randomImage(t))
+	appendImageToLayout(t, filepath.Join(newBundle, "install"), "stable", randomImage(t))
+	appendImageToLayout(t, filepath.Join(oldBundle, "install"), "v1.54.0", randomImage(t))
+	appendImageToLayout(t, filepath.Join(newBundle, "install"), "v1.56.5", randomImage(t))
+	appendImageToLayout(t, filepath.Join(oldBundle, "release-channel"), "stable", randomImage(t))
+	appendImageToLayout(t, filepath.Join(newBundle, "modules", "console"), "v1.0.0", randomImage(t))
+
+	report, err := NewRegistryComparator(
+		OCILayoutScheme+oldBundle,
+		OCILayoutScheme+newBundle,
+		ComparatorOptions{Deep: true},
+	).Compare(context.Background())
+	require.NoError(t, err)
+
+	require.False(t, report.IsConsistent())
+	require.Equal(t, 1, report.ComparedRepositories)
+	require.Equal(t, 2, report.ComparedImages)
+	require.Equal(t, []string{"release-channel"}, report.MissingRepositories)
+	require.Equal(t, []string{"modules/console"}, report.ExtraRepositories)
+	require.Equal(t, []string{"install:v1.54.0"}, report.MissingImages)
+	require.Equal(t, []string{"install:v1.56.5"}, report.ExtraImages)
+	require.Len(t, report.MismatchedImages, 1)
+	require.Equal(t, "install:stable", report.MismatchedImages[0].Image)
+	require.Len(t, report.MismatchedImages[0].MissingLayers, 1)
+}
+
+func TestRegistryComparatorWithScope(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+
+	release := randomImage(t)
+	appendImageToLayout(t, filepath.Join(source, "install"), "v1.60.3", release)
+	appendImageToLayout(t, filepath.Join(target, "install"), "v1.60.3", release)
+	appendImageToLayout(t, filepath.Join(source, "install"), "v1.59.8", randomImage(t))
+	appendImageToLayout(t, filepath.Join(source, "install"), "stable", randomImage(t))
+	appendImageToLayout(t, filepath.Join(source, "modules", "console"), "v1.0.0", randomImage(t))
+	appendImageToLayout(t, filepath.Join(source, "modules", "commander"), "v1.0.0", randomImage(t))
+	appendImageToLayout(t, filepath.Join(source, "security", "trivy-db"), "2", randomImage(t))
+
+	scope := &Scope{DeckhouseTag: "v1.60.3", Modules: []string{"console"}}
+	report, err := NewRegistryComparator(
+		OCILayoutScheme+source,
+		OCILayoutScheme+target,
+		ComparatorOptions{Scope: scope},
+	).Compare(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, scope, report.Scope)
+	require.Equal(t, 1, report.ComparedImages)
+	require.Equal(t, []string{"modules/console", "security/trivy-db"}, report.MissingRepositories)
+	require.Empty(t, report.MissingImages)
+}
+
+func TestRegistryComparatorWithS3Bundle(t *testing.T) {
+	server := mirrorTestUtils.SetupTestS3()
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", mirrorTestUtils.TestS3AccessKeyID)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	packFromDir, unpacked := t.TempDir(), t.TempDir()
+	installer, module, moduleRelease := randomImage(t), randomImage(t), randomImage(t)
+	for _, dir := range []string{packFromDir, unpacked} {
+		appendImageToLayout(t, filepath.Join(dir, "install"), "v1.55.7", installer)
+		appendImageToLayout(t, filepath.Join(dir, "modules", "console"), "v1.0.0", module)
+		appendImageToLayout(t, filepath.Join(dir, "modules", "console", "release"), "v1.0.0", moduleRelease)
+	}
+	appendImageToLayout(t, filepath.Join(unpacked, "install"), "v1.56.5", randomImage(t))
+
+	bundleURL := "s3://bundles/d8.tar"
+	require.NoError(t, bundle.Pack(&contexts.PullContext{
+		BaseContext: contexts.BaseContext{BundlePath: bundleURL, UnpackedImagesPath: packFromDir},
+	}))
+
+	report, err := NewRegistryComparator(
+		bundleURL,
+		OCILayoutScheme+unpacked,
+		ComparatorOptions{Deep: true},
+	).Compare(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, 3, report.ComparedRepositories)
+	require.Equal(t, 3, report.ComparedImages)
+	require.Empty(t, report.MismatchedImages)
+	require.Empty(t, report.MissingImages)
+	require.Equal(t, []string{"install:v1.56.5"}, report.ExtraImages)
+}
+
+func TestRegistryComparatorFailsOnExtra(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+
+	img := randomImage(t)
+	appendImageToLayout(t, source, "v1.55.7", img)
+	appendImageToLayout(t, target, "v1.55.7", img)
+	appendImageToLayout(t, target, "v1.55.7-debug", randomImage(t))
+	appendImageToLayout(t, filepath.Join(target, "modules", "console"), "v1.0.0", randomImage(t))
+
+	compare := func(opts ComparatorOptions) *ComparisonReport {
+		report, err := NewRegistryComparator(OCILayoutScheme+source, OCILayoutScheme+target, opts).Compare(context.Background())
+		require.NoError(t, err)
+		return report
+	}
+
+	report := compare(ComparatorOptions{})
+	require.True(t, report.IsConsistent(), "Extras should be allowed by default")
+
+	report = compare(ComparatorOptions{FailOnExtra: true})
+	require.False(t, report.IsConsistent())
+	require.Equal(t, []string{"modules/console"}, report.ExtraRepositories)
+	require.Equal(t, []string{"<root>:v1.55.7-debug"}, report.ExtraImages)
+
+	report = compare(ComparatorOptions{FailOnExtra: true, ExtraAllowlist: []string{"modules/*", "<root>:*-debug"}})
+	require.True(t, report.IsConsistent(), "%+v", report)
+	require.Empty(t, report.ExtraRepositories)
+	require.Empty(t, report.ExtraImages)
+	require.ElementsMatch(t, []string{"modules/console", "<root>:v1.55.7-debug"}, report.AllowedExtras)
+}
+
+func TestRegistryComparatorWithFlattenedTarget(t *testing.T) {
+	bundle := t.TempDir()
+	appendImageToLayout(t, bundle, "v1.55.7", randomImage(t))
+	appendImageToLayout(t, filepath.Join(bundle, "install"), "v1.55.7", randomImage(t))
+	appendImageToLayout(t, filepath.Join(bundle, "modules", "console"), "v1.0.0", randomImage(t))
+	appendImageToLayout(t, filepath.Join(bundle, "modules", "console", "release"), "v1.0.0", randomImage(t))
+
+	reg := mirrorTestUtils.SetupTestRegistry()
+	defer reg.Server.Close()
+
+	mappingPath := filepath.Join(t.TempDir(), "mapping.json")
+	pushCtx := &contexts.PushContext{
+		BaseContext: contexts.BaseContext{
+			Logger:             log.NewSLogger(slog.LevelDebug),
+			Insecure:           true,
+			RegistryHost:       reg.Host,
+			RegistryPath:       reg.RepoPath,
+			BundlePath:         bundle,
+			UnpackedImagesPath: bundle,
+		},
+		Parallelism:         contexts.DefaultParallelism,
+		FlattenRepositories: true,
+		FlattenMappingPath:  mappingPath,
+	}
+	require.NoError(t, operations.PushDeckhouseToRegistryContext(context.Background(), pushCtx))
+
+	mapping, err := flatten.LoadMapping(mappingPath)
+	require.NoError(t, err)
+	for segment, repo := range mapping.Repositories {
+		require.Equal(t, flatten.Depth(mapping.Root), flatten.Depth(repo), "Repository %s is nested too deep", segment)
+	}
+
+	report, err := NewRegistryComparator(
+		OCILayoutScheme+bundle,
+		mapping.Root,
+		ComparatorOptions{Insecure: true, Deep: true, TargetMapping: mapping},
+	).Compare(context.Background())
+	require.NoError(t, err)
+	require.True(t, report.IsConsistent(), "%+v", report)
+	require.Equal(t, 4, report.ComparedRepositories)
+
+	report, err = NewRegistryComparator(
+		OCILayoutScheme+bundle,
+		mapping.Root,
+		ComparatorOptions{Insecure: true},
+	).Compare(context.Background())
+	require.NoError(t, err)
+	require.False(t, report.IsConsistent(), "Flattened repositories should not be found without mapping")
+}
+
+func TestRegistryComparatorReportsUndiscoveredRepositories(t *testing.T) {
+	bundle := t.TempDir()
+	img := randomImage(t)
+	appendImageToLayout(t, bundle, "v1.55.7", img)
+
+	reg := mirrorTestUtils.SetupTestRegistry()
+	defer reg.Server.Close()
+	for _, imageRef := range []string{
+		reg.Host + reg.RepoPath + ":v1.55.7",
+		reg.Host + reg.RepoPath + "/custom/segment:v1.55.7",
+		reg.Host + "/unrelated:v1.55.7",
+	} {
+		ref, err := name.ParseReference(imageRef, name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, img))
+	}
+
+	report, err := NewRegistryComparator(
+		OCILayoutScheme+bundle,
+		reg.Host+reg.RepoPath,
+		ComparatorOptions{Insecure: true},
+	).Compare(context.Background())
+	require.NoError(t, err)
+	require.True(t, report.IsConsistent(), "%+v", report)
+	require.Equal(t, []string{"custom/segment"}, report.UndiscoveredRepositories)
+	require.NotNil(t, report.TargetCapabilities)
+	require.Equal(t, regcaps.Supported, report.TargetCapabilities.Catalog)
+}
+
+func TestRegistryComparatorDetectsRecompressedLayers(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+
+	layerTar := tarWithFile(t, "etc/motd", bytes.Repeat([]byte("Deckhouse mirror layer contents\n"), 1024))
+	appendImageToLayout(t, filepath.Join(source, "install"), "stable", imageWithLayer(t, layerTar, gzip.BestSpeed))
+	appendImageToLayout(t, filepath.Join(target, "install"), "stable", imageWithLayer(t, layerTar, gzip.BestCompression))
+	appendImageToLayout(t, filepath.Join(source, "install"), "alpha", randomImage(t))
+	appendImageToLayout(t, filepath.Join(target, "install"), "alpha", randomImage(t))
+
+	report, err := NewRegistryComparator(
+		OCILayoutScheme+source,
+		OCILayoutScheme+target,
+		ComparatorOptions{Deep: true},
+	).Compare(context.Background())
+	require.NoError(t, err)
+
+	require.False(t, report.IsConsistent())
+	require.Len(t, report.MismatchedImages, 2)
+	require.Equal(t, "install:alpha", report.MismatchedImages[0].Image)
+	require.False(t, report.MismatchedImages[0].Recompressed)
+	require.Equal(t, "install:stable", report.MismatchedImages[1].Image)
+	require.True(t, report.MismatchedImages[1].Recompressed)
+	require.Equal(t, 1, report.RecompressedImages())
+	require.Equal(t, []string{RecompressionGuidance}, report.Guidance)
+	require.Contains(t, report.Summary(), RecompressionGuidance)
+}
+
+func tarWithFile(t *testing.T, name string, contents []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(contents))}))
+	_, err := tw.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func imageWithLayer(t *testing.T, layerTar []byte, compressionLevel int) v1.Image {
+	t.Helper()
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(layerTar)), nil
+	}, tarball.WithCompressionLevel(compressionLevel))
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	return img
+}
+
+func randomImage(t *testing.T) v1.Image {
+	t.Helper()
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	return img
+}
+
+func appendImageToLayout(t *testing.T, layoutPath, tag string, img v1.Image) {
+	t.Helper()
+	l, err := layout.FromPath(layoutPath)
+	if err != nil {
+		l, err = layouts.CreateEmptyImageLayoutAtPath(layoutPath)
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"io.deckhouse.image.short_tag": tag,
+	})))
+}
+
+func TestSkipRules(t *testing.T) {
+	digestTag := "sha256-" + strings.Repeat("ab", 32)
+
+	rules := DefaultSkipRules(false)
+	for _, tag := range []string{"d8WriteCheck", digestTag, digestTag + ".sig", digestTag + ".att", digestTag + ".sbom"} {
+		require.True(t, rules.Skips(tag), tag)
+	}
+	for _, tag := range []string{"v1.60.0", "alpha", "sha256-short.sig", digestTag + ".txt"} {
+		require.False(t, rules.Skips(tag), tag)
+	}
+
+	rules = DefaultSkipRules(true)
+	require.True(t, rules.Skips("d8WriteCheck"))
+	require.False(t, rules.Skips(digestTag+".sig"), "signatures must be compared when included")
+
+	rules = &SkipRules{Suffixes: []string{"-debug"}, Patterns: []*regexp.Regexp{regexp.MustCompile(`^pr-\d+$`)}}
+	require.True(t, rules.Skips("v1.60.0-debug"))
+	require.True(t, rules.Skips("pr-1234"))
+	require.False(t, rules.Skips("pr-1234-x"))
+	require.False(t, (*SkipRules)(nil).Skips("d8WriteCheck"))
+}
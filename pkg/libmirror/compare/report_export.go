@@ -0,0 +1,154 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+// Keys of ConfigMap written by ExportReportToConfigMap.
+const (
+	ReportConsistentKey = "consistent"
+	ReportSummaryKey    = "summary"
+	ReportCheckedAtKey  = "checkedAt"
+	ReportJSONKey       = "report.json"
+)
+
+// Reasons of events emitted by EmitReportEvent.
+const (
+	ReportEventReasonConsistent = "MirrorConsistent"
+	ReportEventReasonDrift      = "MirrorDrift"
+)
+
+const reportComponent = "d8-mirror-verify"
+
+// Summary describes comparison results in a single line, followed by remediation guidance if there is any.
+func (r *ComparisonReport) Summary() string {
+	summary := fmt.Sprintf(
+		"Compared %d repositories and %d images of %s with %s: %d missing repositories, %d missing images, "+
+			"%d mismatched images (%d recompressed), %d tag conflicts, %d extra repositories, %d extra images",
+		r.ComparedRepositories, r.ComparedImages, r.Target, r.Source,
+		len(r.MissingRepositories), len(r.MissingImages), len(r.MismatchedImages), r.RecompressedImages(),
+		len(r.TagConflicts), len(r.ExtraRepositories), len(r.ExtraImages),
+	)
+	for _, guidance := range r.Guidance {
+		summary += ". " + guidance
+	}
+	return summary
+}
+
+// RecompressedImages returns number of mismatched images which layers were recompressed by target registry.
+func (r *ComparisonReport) RecompressedImages() int {
+	count := 0
+	for _, mismatch := range r.MismatchedImages {
+		if mismatch.Recompressed {
+			count++
+		}
+	}
+	return count
+}
+
+// ExportReportToConfigMap records report into ConfigMap, creating it if needed, so that cluster dashboards
+// and GitOps controllers can react to mirror drift when comparison is run as an in-cluster Job.
+func ExportReportToConfigMap(
+	ctx context.Context,
+	kubeCl kubernetes.Interface,
+	namespace, name string,
+	report *ComparisonReport,
+) error {
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("Marshal comparison report: %w", err)
+	}
+	data := map[string]string{
+		ReportConsistentKey: strconv.FormatBool(report.IsConsistent()),
+		ReportSummaryKey:    report.Summary(),
+		ReportCheckedAtKey:  reportschema.Timestamp(time.Now()),
+		ReportJSONKey:       string(reportJSON),
+	}
+
+	configMaps := kubeCl.CoreV1().ConfigMaps(namespace)
+	cm, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{"app.kubernetes.io/managed-by": reportComponent},
+			},
+			Data: data,
+		}
+		if _, err = configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("Create ConfigMap %s/%s: %w", namespace, name, err)
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("Get ConfigMap %s/%s: %w", namespace, name, err)
+	}
+
+	cm.Data = data
+	if _, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("Update ConfigMap %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// EmitReportEvent emits event with report summary on the designated object, e.g. the Job running comparison.
+// Consistent results are reported as Normal events, drift is reported as Warning.
+func EmitReportEvent(
+	ctx context.Context,
+	kubeCl kubernetes.Interface,
+	object corev1.ObjectReference,
+	report *ComparisonReport,
+) error {
+	eventType, reason := corev1.EventTypeNormal, ReportEventReasonConsistent
+	if !report.IsConsistent() {
+		eventType, reason = corev1.EventTypeWarning, ReportEventReasonDrift
+	}
+
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: object.Name + "-",
+			Namespace:    object.Namespace,
+		},
+		InvolvedObject: object,
+		Type:           eventType,
+		Reason:         reason,
+		Message:        report.Summary(),
+		Source:         corev1.EventSource{Component: reportComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := kubeCl.CoreV1().Events(object.Namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("Create event for %s %s/%s: %w", object.Kind, object.Namespace, object.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestExportReportToConfigMap(t *testing.T) {
+	kubeCl := fake.NewSimpleClientset()
+	report := &ComparisonReport{Source: "oci-layout:///bundle", Target: "registry.local/deckhouse", ComparedImages: 3}
+
+	require.NoError(t, ExportReportToConfigMap(context.Background(), kubeCl, "d8-system", "mirror-verify", report))
+	cm, err := kubeCl.CoreV1().ConfigMaps("d8-system").Get(context.Background(), "mirror-verify", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "true", cm.Data[ReportConsistentKey])
+
+	report.MissingImages = []string{"install:v1.55.7"}
+	require.NoError(t, ExportReportToConfigMap(context.Background(), kubeCl, "d8-system", "mirror-verify", report))
+	cm, err = kubeCl.CoreV1().ConfigMaps("d8-system").Get(context.Background(), "mirror-verify", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "false", cm.Data[ReportConsistentKey])
+	require.Contains(t, cm.Data[ReportSummaryKey], "1 missing images")
+
+	exported := &ComparisonReport{}
+	require.NoError(t, json.Unmarshal([]byte(cm.Data[ReportJSONKey]), exported))
+	require.Equal(t, report.MissingImages, exported.MissingImages)
+}
+
+func TestEmitReportEvent(t *testing.T) {
+	kubeCl := fake.NewSimpleClientset()
+	job := corev1.ObjectReference{Kind: "Job", APIVersion: "batch/v1", Namespace: "d8-system", Name: "mirror-verify"}
+	report := &ComparisonReport{MismatchedImages: []ImageMismatch{{Image: "install:stable"}}}
+
+	require.NoError(t, EmitReportEvent(context.Background(), kubeCl, job, report))
+	events, err := kubeCl.CoreV1().Events("d8-system").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	require.Equal(t, corev1.EventTypeWarning, events.Items[0].Type)
+	require.Equal(t, ReportEventReasonDrift, events.Items[0].Reason)
+	require.Equal(t, job, events.Items[0].InvolvedObject)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/sbom"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+)
+
+// ReadSBOMs extracts SPDX and CycloneDX documents that cosign attached to images of Deckhouse repositories
+// under registry repo ref. Documents of other formats are left out.
+func ReadSBOMs(ctx context.Context, ref string, opts RegistryOptions) ([]sbom.Document, error) {
+	skipRules := &SkipRules{Tags: serviceTags}
+	source, err := newRegistrySource(ref, skipRules, opts)
+	if err != nil {
+		return nil, err
+	}
+	repos, err := discoverRepositories(ctx, source, contexts.EnterpriseEdition, nil, skipRules)
+	if err != nil {
+		return nil, fmt.Errorf("discover repositories of %s: %w", source, err)
+	}
+
+	docs := make([]sbom.Document, 0)
+	for _, repo := range sortedKeys(repos) {
+		tags := sortedKeys(repos[repo])
+		// Tags of every image are only looked up if repository has SBOMs at all
+		var imageTags map[v1.Hash]string
+		for _, tag := range tags {
+			digest, kind, isArtifact := signature.ParseCosignArtifactTag(tag)
+			if !isArtifact || kind != "sbom" {
+				continue
+			}
+			if imageTags == nil {
+				if imageTags, err = tagsByDigest(ctx, source, repo, tags); err != nil {
+					return nil, err
+				}
+			}
+			image, tagged := imageTags[digest]
+			if !tagged {
+				image = digest.Algorithm + "-" + digest.Hex
+			}
+
+			repoDocs, err := readSBOMImage(ctx, source, repo, tag)
+			if err != nil {
+				return nil, fmt.Errorf("read %s:%s: %w", displayRepo(repo), tag, err)
+			}
+			for _, doc := range repoDocs {
+				doc.Repository, doc.Image = repo, image
+				docs = append(docs, doc)
+			}
+		}
+	}
+	return docs, nil
+}
+
+// tagsByDigest maps digests of images in repo to the first of their tags, cosign artifacts are not images of their own.
+func tagsByDigest(ctx context.Context, source *registrySource, repo string, tags []string) (map[v1.Hash]string, error) {
+	imageTags := make(map[v1.Hash]string)
+	for _, tag := range tags {
+		if signature.IsCosignArtifactTag(tag) {
+			continue
+		}
+		info, err := source.getImageInfo(ctx, repo, tag)
+		if err != nil {
+			return nil, fmt.Errorf("get %s:%s: %w", displayRepo(repo), tag, err)
+		}
+		if _, found := imageTags[info.digest]; !found {
+			imageTags[info.digest] = tag
+		}
+	}
+	return imageTags, nil
+}
+
+func readSBOMImage(ctx context.Context, source *registrySource, repo, tag string) ([]sbom.Document, error) {
+	repository, err := source.repository(repo)
+	if err != nil {
+		return nil, err
+	}
+	img, err := remote.Image(repository.Tag(tag), append(source.remoteOpts, remote.WithContext(ctx))...)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]sbom.Document, 0, len(layers))
+	for _, layer := range layers {
+		mediaType, err := layer.MediaType()
+		if err != nil {
+			return nil, err
+		}
+		if !sbom.IsSupported(mediaType) {
+			continue
+		}
+		blob, err := layer.Compressed()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(blob)
+		_ = blob.Close()
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, sbom.Document{MediaType: mediaType, Data: data})
+	}
+	return docs, nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+	mirrorTestUtils "github.com/deckhouse/deckhouse-cli/testing/util/mirror"
+)
+
+func TestReadSBOMs(t *testing.T) {
+	host, repoPath, _ := mirrorTestUtils.SetupEmptyRegistryRepo(false)
+	root := host + repoPath
+
+	img := randomImage(t)
+	ref, err := name.ParseReference(root+"/install:v1.60.0", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	sbomImage, err := mutate.Append(empty.Image,
+		mutate.Addendum{Layer: static.NewLayer([]byte(`{"bomFormat":"CycloneDX"}`), "application/vnd.cyclonedx+json")},
+	)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref.Context().Tag(signature.CosignArtifactTags(digest)[2]), sbomImage))
+
+	docs, err := ReadSBOMs(context.Background(), root, RegistryOptions{Insecure: true})
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	require.Equal(t, "install", docs[0].Repository)
+	require.Equal(t, "v1.60.0", docs[0].Image)
+	require.Equal(t, types.MediaType("application/vnd.cyclonedx+json"), docs[0].MediaType)
+	require.JSONEq(t, `{"bomFormat":"CycloneDX"}`, string(docs[0].Data))
+}
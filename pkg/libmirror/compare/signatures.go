@@ -0,0 +1,130 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flatten"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/taglist"
+)
+
+// RegistryOptions configure access to the registry for VerifySignatures and ReadSBOMs.
+type RegistryOptions struct {
+	Auth          authn.Authenticator
+	Insecure      bool
+	SkipTLSVerify bool
+
+	// Mapping is set when registry was pushed with flattened or renamed repositories, as recorded by d8 mirror push.
+	Mapping           *flatten.Mapping
+	ModulesPathSuffix string
+}
+
+// SignatureReport lists images of mirrored Deckhouse repositories which cosign signatures were checked.
+type SignatureReport struct {
+	Registry string `json:"registry"`
+
+	VerifiedImages int `json:"verifiedImages"`
+	// UnsignedImages have no cosign signatures at all, e.g. because they were pushed without --include-cosign.
+	UnsignedImages []string `json:"unsignedImages"`
+	// InvalidSignatures are images none of which signatures were made with the verification key.
+	InvalidSignatures []string `json:"invalidSignatures"`
+}
+
+func (r *SignatureReport) OK() bool {
+	return len(r.UnsignedImages) == 0 && len(r.InvalidSignatures) == 0
+}
+
+func (r *SignatureReport) Summary() string {
+	return fmt.Sprintf(
+		"Verified signatures of %d images of %s: %d unsigned, %d with invalid signatures",
+		r.VerifiedImages, r.Registry, len(r.UnsignedImages), len(r.InvalidSignatures),
+	)
+}
+
+// VerifySignatures checks that every image of Deckhouse repositories under registry repo ref has cosign signature made with pub.
+// Images are checked once per digest, however many tags refer to them. Only registries can be checked, not bundles.
+func VerifySignatures(ctx context.Context, ref string, pub crypto.PublicKey, opts RegistryOptions) (*SignatureReport, error) {
+	skipRules := DefaultSkipRules(false)
+	source, err := newRegistrySource(ref, skipRules, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SignatureReport{
+		Registry:          source.String(),
+		UnsignedImages:    make([]string, 0),
+		InvalidSignatures: make([]string, 0),
+	}
+	repos, err := discoverRepositories(ctx, source, contexts.EnterpriseEdition, nil, skipRules)
+	if err != nil {
+		return nil, fmt.Errorf("discover repositories of %s: %w", source, err)
+	}
+
+	for _, repo := range sortedKeys(repos) {
+		repository, err := source.repository(repo)
+		if err != nil {
+			return nil, err
+		}
+
+		verified := make(map[v1.Hash]struct{})
+		for _, tag := range sortedKeys(repos[repo]) {
+			image := displayRepo(repo) + ":" + tag
+			info, err := source.getImageInfo(ctx, repo, tag)
+			if err != nil {
+				return nil, fmt.Errorf("get %s: %w", image, err)
+			}
+			if _, found := verified[info.digest]; found {
+				continue
+			}
+			verified[info.digest] = struct{}{}
+
+			err = signature.VerifyCosignSignature(ctx, repository, info.digest, pub, source.remoteOpts...)
+			switch {
+			case errors.Is(err, signature.ErrSignatureNotFound):
+				report.UnsignedImages = append(report.UnsignedImages, image)
+			case errors.Is(err, signature.ErrInvalidSignature):
+				report.InvalidSignatures = append(report.InvalidSignatures, image)
+			case err != nil:
+				return nil, fmt.Errorf("verify signature of %s: %w", image, err)
+			}
+			report.VerifiedImages++
+		}
+	}
+	return report, nil
+}
+
+func newRegistrySource(ref string, skipRules *SkipRules, opts RegistryOptions) (*registrySource, error) {
+	source, isRegistry := newImageSource(ref, opts.Auth, opts.Mapping, taglist.NewLister(), ComparatorOptions{
+		Insecure:          opts.Insecure,
+		SkipTLSVerify:     opts.SkipTLSVerify,
+		ModulesPathSuffix: opts.ModulesPathSuffix,
+		SkipRules:         skipRules,
+	}).(*registrySource)
+	if !isRegistry {
+		return nil, fmt.Errorf("%s is not a registry repository", ref)
+	}
+	return source, nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+	mirrorTestUtils "github.com/deckhouse/deckhouse-cli/testing/util/mirror"
+)
+
+func TestVerifySignatures(t *testing.T) {
+	host, repoPath, _ := mirrorTestUtils.SetupEmptyRegistryRepo(false)
+	root := host + repoPath
+	vendorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pushSignedImage(t, root, "v1.60.0", vendorKey)
+	pushSignedImage(t, root+"/release-channel", "stable", otherKey)
+	unsigned := randomImage(t)
+	for _, tag := range []string{"v1.60.0", "stable"} {
+		ref, err := name.ParseReference(root+"/install:"+tag, name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, unsigned))
+	}
+
+	report, err := VerifySignatures(context.Background(), root, vendorKey.Public(), RegistryOptions{Insecure: true})
+	require.NoError(t, err)
+	require.False(t, report.OK())
+	require.Equal(t, 3, report.VerifiedImages, "Images tagged more than once should be verified once")
+	require.Equal(t, []string{"install:stable"}, report.UnsignedImages)
+	require.Equal(t, []string{"release-channel:stable"}, report.InvalidSignatures)
+
+	_, err = VerifySignatures(context.Background(), OCILayoutScheme+t.TempDir(), vendorKey.Public(), RegistryOptions{})
+	require.Error(t, err, "Bundles cannot be verified")
+}
+
+func pushSignedImage(t *testing.T, repo, tag string, key *ecdsa.PrivateKey) {
+	t.Helper()
+
+	img := randomImage(t)
+	ref, err := name.ParseReference(repo+":"+tag, name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	payload := []byte(fmt.Sprintf(`{"critical":{"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"}}`, digest))
+	sig, err := signature.Sign(key, payload)
+	require.NoError(t, err)
+	sigImage, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       static.NewLayer(payload, "application/vnd.dev.cosign.simplesigning.v1+json"),
+		Annotations: map[string]string{"dev.cosignproject.cosign/signature": string(sig)},
+		MediaType:   types.MediaType("application/vnd.dev.cosign.simplesigning.v1+json"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref.Context().Tag(signature.CosignSignatureTag(digest)), sigImage))
+}
@@ -17,9 +17,19 @@ limitations under the License.
 package contexts
 
 import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/google/go-containerregistry/pkg/authn"
 )
 
+// DefaultModulesPathSuffix is the path of modules repositories relative to Deckhouse repo in registries
+// and to the root of bundles.
+const DefaultModulesPathSuffix = "modules"
+
 type Logger interface {
 	DebugF(format string, a ...interface{})
 	DebugLn(a ...interface{})
@@ -41,12 +51,80 @@ type BaseContext struct {
 	RegistryPath string // --registry (path)
 
 	DeckhouseRegistryRepo string // --source during pull, extracted from bundle data during push
+	ModulesPathSuffix     string // --modules-path-suffix, DefaultModulesPathSuffix if empty
 
 	BundlePath         string // --images-bundle-path
 	UnpackedImagesPath string
 
+	// OpenPGP private keys encrypted bundles are decrypted with during unpacking, nil if none were given.
+	BundleDecryptionKeys openpgp.EntityList // --decrypt-key
+
 	Insecure            bool // --insecure
 	SkipTLSVerification bool // --skip-tls-verify
 
 	Logger Logger
+
+	// Run is shared between all stages of the current mirroring run, may be nil.
+	Run *RunContext
+}
+
+// ModulesSegment returns path of modules repositories relative to Deckhouse repo in registry.
+func (b *BaseContext) ModulesSegment() string {
+	if b.ModulesPathSuffix == "" {
+		return DefaultModulesPathSuffix
+	}
+	return b.ModulesPathSuffix
+}
+
+// RegistrySegment maps path of repository relative to bundle root to its path relative to Deckhouse repo in registry.
+func (b *BaseContext) RegistrySegment(bundleSegment string) string {
+	return RegistrySegment(bundleSegment, b.ModulesPathSuffix)
+}
+
+// RegistrySegment maps path of repository relative to bundle root to its path relative to Deckhouse repo
+// in registry that hosts modules under modulesPathSuffix. Bundles always keep modules under DefaultModulesPathSuffix.
+func RegistrySegment(bundleSegment, modulesPathSuffix string) string {
+	return replaceModulesSegment(bundleSegment, DefaultModulesPathSuffix, modulesPathSuffix)
+}
+
+// BundleSegment is the inverse of RegistrySegment.
+func BundleSegment(registrySegment, modulesPathSuffix string) string {
+	return replaceModulesSegment(registrySegment, modulesPathSuffix, DefaultModulesPathSuffix)
+}
+
+func replaceModulesSegment(segment, from, to string) string {
+	if from == "" || to == "" || from == to {
+		return segment
+	}
+	if segment == from {
+		return to
+	}
+	if rest, found := strings.CutPrefix(segment, from+"/"); found {
+		return path.Join(to, rest)
+	}
+	return segment
+}
+
+// ValidateModulesPathSuffix checks that modulesPathSuffix is a valid repository path
+// that does not overlap with other Deckhouse repositories.
+func ValidateModulesPathSuffix(modulesPathSuffix string) error {
+	if modulesPathSuffix == "" {
+		return errors.New("Modules path suffix is empty")
+	}
+	if path.Clean(modulesPathSuffix) != modulesPathSuffix || path.IsAbs(modulesPathSuffix) || strings.HasPrefix(modulesPathSuffix, "..") {
+		return fmt.Errorf("Modules path suffix %q must be a relative path without leading or trailing slashes", modulesPathSuffix)
+	}
+	if strings.ToLower(modulesPathSuffix) != modulesPathSuffix {
+		return fmt.Errorf("Modules path suffix %q must be lowercase", modulesPathSuffix)
+	}
+	firstSegment, _, _ := strings.Cut(modulesPathSuffix, "/")
+	// Modules path suffix is not tied to edition, so it must not overlap with repositories of any of them.
+	for _, edition := range Editions {
+		for _, reserved := range edition.ReservedSegments() {
+			if firstSegment == reserved {
+				return fmt.Errorf("Modules path suffix %q overlaps with %s repository", modulesPathSuffix, reserved)
+			}
+		}
+	}
+	return nil
 }
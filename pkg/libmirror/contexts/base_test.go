@@ -0,0 +1,48 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contexts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModulesSegmentMapping(t *testing.T) {
+	ctx := &BaseContext{}
+	require.Equal(t, "modules", ctx.ModulesSegment())
+	require.Equal(t, "modules/console/release", ctx.RegistrySegment("modules/console/release"))
+
+	ctx.ModulesPathSuffix = "addons/modules"
+	require.Equal(t, "addons/modules", ctx.ModulesSegment())
+	require.Equal(t, "addons/modules", ctx.RegistrySegment("modules"))
+	require.Equal(t, "addons/modules/console/release", ctx.RegistrySegment("modules/console/release"))
+	require.Equal(t, "install", ctx.RegistrySegment("install"))
+	require.Equal(t, "modules-extra", ctx.RegistrySegment("modules-extra"))
+
+	require.Equal(t, "modules/console", BundleSegment("addons/modules/console", "addons/modules"))
+	require.Equal(t, "addons", BundleSegment("addons", "addons/modules"))
+}
+
+func TestValidateModulesPathSuffix(t *testing.T) {
+	require.NoError(t, ValidateModulesPathSuffix("modules"))
+	require.NoError(t, ValidateModulesPathSuffix("addons/modules"))
+
+	for _, invalid := range []string{"", "/modules", "modules/", "../modules", "Modules", "install", "security/modules"} {
+		require.Error(t, ValidateModulesPathSuffix(invalid), invalid)
+	}
+}
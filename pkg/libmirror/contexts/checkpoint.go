@@ -0,0 +1,186 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contexts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// PullCheckpointFile is kept in the unpacked bundle directory while pull is in progress.
+const PullCheckpointFile = "pull-checkpoint.jsonl"
+
+// PullCheckpoint records images already written into OCI layouts of the unpacked bundle, so that interrupted pull
+// can put them back into layouts indexes without downloading them again.
+// Checkpoint file is a journal of JSON lines, every recorded image is appended to it as a single checkpointEntry.
+// All of its methods are safe for concurrent use and for use on nil *PullCheckpoint, which records nothing.
+type PullCheckpoint struct {
+	path string
+
+	mu sync.Mutex
+	// Pulled images by layout path relative to checkpoint directory and by image reference.
+	layouts map[string]map[string]CheckpointedImage
+}
+
+// CheckpointedImage is an image already pulled into layout.
+type CheckpointedImage struct {
+	// Descriptor is the one image was added to layout index with.
+	Descriptor v1.Descriptor
+	// SourceDigest is digest of the image in source registry. It differs from Descriptor.Digest
+	// for image indexes thinned to pulled platforms.
+	SourceDigest v1.Hash
+}
+
+type checkpointEntry struct {
+	Layout     string        `json:"layout"`
+	Reference  string        `json:"reference"`
+	Descriptor v1.Descriptor `json:"descriptor"`
+	// SourceDigest is only recorded if it differs from Descriptor.Digest.
+	SourceDigest *v1.Hash `json:"sourceDigest,omitempty"`
+}
+
+// LoadPullCheckpoint reads checkpoint at path, returning an empty one if pull was not started yet.
+func LoadPullCheckpoint(path string) (*PullCheckpoint, error) {
+	checkpoint := &PullCheckpoint{path: path, layouts: map[string]map[string]CheckpointedImage{}}
+	rawCheckpoint, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return checkpoint, nil
+	case err != nil:
+		return nil, fmt.Errorf("read pull checkpoint: %w", err)
+	}
+
+	lines := bytes.Split(rawCheckpoint, []byte("\n"))
+	// Every entry ends with a newline, so the last line is empty, unless pull was interrupted in the middle of a write.
+	// Such incomplete entry is left out and its image is pulled again.
+	lines = lines[:len(lines)-1]
+	for i, line := range lines {
+		entry := checkpointEntry{}
+		if err = json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("parse pull checkpoint %s, line %d: %w", path, i+1, err)
+		}
+		checkpoint.add(entry)
+	}
+	return checkpoint, nil
+}
+
+// Len returns number of images recorded in checkpoint.
+func (c *PullCheckpoint) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	for _, images := range c.layouts {
+		total += len(images)
+	}
+	return total
+}
+
+// Lookup returns imageReference if it was already pulled into layout at layoutPath.
+func (c *PullCheckpoint) Lookup(layoutPath, imageReference string) (CheckpointedImage, bool) {
+	if c == nil {
+		return CheckpointedImage{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	image, found := c.layouts[c.layoutKey(layoutPath)][imageReference]
+	return image, found
+}
+
+// Record stores imageReference pulled into layout at layoutPath and appends it to checkpoint file.
+func (c *PullCheckpoint) Record(layoutPath, imageReference string, image CheckpointedImage) error {
+	if c == nil {
+		return nil
+	}
+	entry := checkpointEntry{Layout: c.layoutKey(layoutPath), Reference: imageReference, Descriptor: image.Descriptor}
+	if image.SourceDigest != image.Descriptor.Digest {
+		entry.SourceDigest = &image.SourceDigest
+	}
+	rawEntry, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal pull checkpoint entry: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(entry)
+	return c.appendEntry(append(rawEntry, '\n'))
+}
+
+func (c *PullCheckpoint) add(entry checkpointEntry) {
+	if c.layouts[entry.Layout] == nil {
+		c.layouts[entry.Layout] = map[string]CheckpointedImage{}
+	}
+	image := CheckpointedImage{Descriptor: entry.Descriptor, SourceDigest: entry.Descriptor.Digest}
+	if entry.SourceDigest != nil {
+		image.SourceDigest = *entry.SourceDigest
+	}
+	c.layouts[entry.Layout][entry.Reference] = image
+}
+
+// Remove deletes checkpoint from disk once pull is complete, so that it does not end up in the bundle.
+func (c *PullCheckpoint) Remove() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.layouts = map[string]map[string]CheckpointedImage{}
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove pull checkpoint: %w", err)
+	}
+	return nil
+}
+
+// layoutKey makes layout paths independent of where the unpacked bundle directory is located.
+func (c *PullCheckpoint) layoutKey(layoutPath string) string {
+	key, err := filepath.Rel(filepath.Dir(c.path), layoutPath)
+	if err != nil {
+		return layoutPath
+	}
+	return filepath.ToSlash(key)
+}
+
+// appendEntry writes a single journal line to the end of checkpoint file. Only the entry being written is lost
+// if write is interrupted, which LoadPullCheckpoint tolerates.
+func (c *PullCheckpoint) appendEntry(rawEntry []byte) error {
+	journal, err := os.OpenFile(c.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open pull checkpoint: %w", err)
+	}
+	if _, err = journal.Write(rawEntry); err != nil {
+		_ = journal.Close()
+		return fmt.Errorf("write pull checkpoint: %w", err)
+	}
+	if err = journal.Close(); err != nil {
+		return fmt.Errorf("write pull checkpoint: %w", err)
+	}
+	return nil
+}
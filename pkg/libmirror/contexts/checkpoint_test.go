@@ -0,0 +1,103 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contexts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullCheckpoint(t *testing.T) {
+	bundleDir := t.TempDir()
+	checkpointPath := filepath.Join(bundleDir, PullCheckpointFile)
+	checkpoint, err := LoadPullCheckpoint(checkpointPath)
+	require.NoError(t, err)
+	require.Equal(t, 0, checkpoint.Len())
+
+	desc := v1.Descriptor{
+		MediaType:   "application/vnd.oci.image.manifest.v1+json",
+		Size:        42,
+		Digest:      v1.Hash{Algorithm: "sha256", Hex: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+		Annotations: map[string]string{"io.deckhouse.image.short_tag": "v1.60.0"},
+	}
+	image := CheckpointedImage{Descriptor: desc, SourceDigest: desc.Digest}
+	require.NoError(t, checkpoint.Record(filepath.Join(bundleDir, "install"), "registry.example.com/deckhouse/ee/install:v1.60.0", image))
+
+	// Checkpoint must be usable from a different location of unpacked bundle directory.
+	movedDir := t.TempDir()
+	require.NoError(t, copyFile(checkpointPath, filepath.Join(movedDir, PullCheckpointFile)))
+	reloaded, err := LoadPullCheckpoint(filepath.Join(movedDir, PullCheckpointFile))
+	require.NoError(t, err)
+	require.Equal(t, 1, reloaded.Len())
+	found, ok := reloaded.Lookup(filepath.Join(movedDir, "install"), "registry.example.com/deckhouse/ee/install:v1.60.0")
+	require.True(t, ok)
+	require.Equal(t, image, found)
+	_, ok = reloaded.Lookup(movedDir, "registry.example.com/deckhouse/ee/install:v1.60.0")
+	require.False(t, ok)
+
+	// Entry left incomplete by interrupted write is ignored.
+	require.NoError(t, checkpoint.Record(filepath.Join(bundleDir, "install"), "registry.example.com/deckhouse/ee/install:v1.61.0", image))
+	rawCheckpoint, err := os.ReadFile(checkpointPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(checkpointPath, rawCheckpoint[:len(rawCheckpoint)-10], 0o644))
+	reloaded, err = LoadPullCheckpoint(checkpointPath)
+	require.NoError(t, err)
+	require.Equal(t, 1, reloaded.Len())
+
+	require.NoError(t, checkpoint.Remove())
+	require.NoFileExists(t, checkpointPath)
+	require.NoError(t, checkpoint.Remove())
+
+	var nilCheckpoint *PullCheckpoint
+	require.NoError(t, nilCheckpoint.Record(bundleDir, "ref", image))
+	_, ok = nilCheckpoint.Lookup(bundleDir, "ref")
+	require.False(t, ok)
+}
+
+func TestPullCheckpointKeepsSourceDigestOfThinnedIndex(t *testing.T) {
+	checkpointPath := filepath.Join(t.TempDir(), PullCheckpointFile)
+	checkpoint, err := LoadPullCheckpoint(checkpointPath)
+	require.NoError(t, err)
+
+	image := CheckpointedImage{
+		Descriptor: v1.Descriptor{
+			MediaType: "application/vnd.oci.image.index.v1+json",
+			Size:      42,
+			Digest:    v1.Hash{Algorithm: "sha256", Hex: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+		},
+		SourceDigest: v1.Hash{Algorithm: "sha256", Hex: "fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9"},
+	}
+	require.NoError(t, checkpoint.Record(filepath.Dir(checkpointPath), "registry.example.com/deckhouse/ee:v1.60.0", image))
+
+	reloaded, err := LoadPullCheckpoint(checkpointPath)
+	require.NoError(t, err)
+	found, ok := reloaded.Lookup(filepath.Dir(checkpointPath), "registry.example.com/deckhouse/ee:v1.60.0")
+	require.True(t, ok)
+	require.Equal(t, image, found)
+}
+
+func copyFile(from, to string) error {
+	contents, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(to, contents, 0o644)
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contexts
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Edition describes repositories that Deckhouse edition is distributed in, relative to its Deckhouse repo.
+// Every list of such repositories used by d8 mirror is derived from edition, modules repositories aside.
+// Methods of nil *Edition describe EnterpriseEdition.
+type Edition struct {
+	Name string
+	// InstallerSegment holds installer image of every release and release channel.
+	InstallerSegment string
+	// StandaloneInstallerSegment holds standalone installer images, editions without them leave it empty.
+	StandaloneInstallerSegment string
+	// ReleaseChannelSegment holds release channel images.
+	ReleaseChannelSegment string
+	// SecuritySegments hold vulnerability databases.
+	SecuritySegments []string
+}
+
+var (
+	FlantEdition = &Edition{
+		Name:                       "fe",
+		InstallerSegment:           "install",
+		StandaloneInstallerSegment: "install-standalone",
+		ReleaseChannelSegment:      "release-channel",
+		SecuritySegments:           securitySegments,
+	}
+	EnterpriseEdition = &Edition{
+		Name:                       "ee",
+		InstallerSegment:           "install",
+		StandaloneInstallerSegment: "install-standalone",
+		ReleaseChannelSegment:      "release-channel",
+		SecuritySegments:           securitySegments,
+	}
+
+	// Editions are all known editions, the ones that can be selected with --edition.
+	Editions = []*Edition{FlantEdition, EnterpriseEdition}
+)
+
+var securitySegments = []string{
+	"security/trivy-db",
+	"security/trivy-bdu",
+	"security/trivy-java-db",
+	"security/trivy-checks",
+}
+
+// LookupEdition returns edition by its name, as given with --edition.
+func LookupEdition(name string) (*Edition, error) {
+	names := make([]string, 0, len(Editions))
+	for _, edition := range Editions {
+		if edition.Name == name {
+			return edition, nil
+		}
+		names = append(names, edition.Name)
+	}
+	return nil, fmt.Errorf("Unknown edition %q, must be one of %s", name, strings.Join(names, ", "))
+}
+
+func (e *Edition) String() string {
+	return e.orDefault().Name
+}
+
+// InstallerSegments are repositories that hold installer images for every release and release channel.
+func (e *Edition) InstallerSegments() []string {
+	e = e.orDefault()
+	segments := []string{e.InstallerSegment}
+	if e.StandaloneInstallerSegment != "" {
+		segments = append(segments, e.StandaloneInstallerSegment)
+	}
+	return segments
+}
+
+// ChannelSegments are repositories in which every release channel tag must point to the same Deckhouse version,
+// so that installer and Deckhouse images installed from the same channel match.
+func (e *Edition) ChannelSegments() []string {
+	return append([]string{""}, e.InstallerSegments()...)
+}
+
+// ReleaseSegments are repositories every Deckhouse release must have an image in.
+// Standalone installers are not among them, as they can be left out of pull.
+func (e *Edition) ReleaseSegments() []string {
+	e = e.orDefault()
+	return []string{"", e.InstallerSegment, e.ReleaseChannelSegment}
+}
+
+// PlatformSegments are repositories of Deckhouse images, installers and release channels.
+func (e *Edition) PlatformSegments() []string {
+	return append(e.ChannelSegments(), e.orDefault().ReleaseChannelSegment)
+}
+
+// Segments are all repositories of the edition, the root one being "".
+func (e *Edition) Segments() []string {
+	return append(e.PlatformSegments(), e.orDefault().SecuritySegments...)
+}
+
+// ReservedSegments are first elements of repository paths of the edition, that modules cannot be hosted under.
+func (e *Edition) ReservedSegments() []string {
+	reserved := make([]string, 0)
+	for _, segment := range e.Segments() {
+		first, _, _ := strings.Cut(segment, "/")
+		if first != "" && !slices.Contains(reserved, first) {
+			reserved = append(reserved, first)
+		}
+	}
+	return reserved
+}
+
+func (e *Edition) orDefault() *Edition {
+	if e == nil {
+		return EnterpriseEdition
+	}
+	return e
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contexts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupEdition(t *testing.T) {
+	edition, err := LookupEdition("fe")
+	require.NoError(t, err)
+	require.Same(t, FlantEdition, edition)
+
+	_, err = LookupEdition("cse")
+	require.ErrorContains(t, err, "must be one of fe, ee")
+}
+
+func TestEditionSegments(t *testing.T) {
+	var defaultEdition *Edition
+	require.Equal(t, EnterpriseEdition.Segments(), defaultEdition.Segments())
+	require.Equal(t, []string{
+		"",
+		"install",
+		"install-standalone",
+		"release-channel",
+		"security/trivy-db",
+		"security/trivy-bdu",
+		"security/trivy-java-db",
+		"security/trivy-checks",
+	}, EnterpriseEdition.Segments())
+	require.Equal(t, []string{"install", "install-standalone", "release-channel", "security"}, EnterpriseEdition.ReservedSegments())
+
+	withoutStandaloneInstaller := &Edition{InstallerSegment: "install", ReleaseChannelSegment: "release-channel"}
+	require.Equal(t, []string{"", "install"}, withoutStandaloneInstaller.ChannelSegments())
+	require.NotContains(t, withoutStandaloneInstaller.Segments(), "install-standalone")
+}
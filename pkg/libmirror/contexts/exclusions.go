@@ -0,0 +1,133 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contexts
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ImageExclusions leave images that are known to be broken or prohibited from export out of the pull.
+// Images are matched by reference glob, where * matches any characters including slashes and ? matches a single one,
+// or by manifest digest. All of its methods are safe for concurrent use and for use on nil *ImageExclusions, which excludes nothing.
+type ImageExclusions struct {
+	RefPatterns []string `json:"refPatterns,omitempty"`
+	Digests     []string `json:"digests,omitempty"`
+
+	refRegexps []*regexp.Regexp
+	digests    map[string]struct{}
+
+	mu       sync.Mutex
+	excluded []ExcludedImage
+}
+
+// ExcludedImage is an image left out of the pull with the rule that excluded it.
+type ExcludedImage struct {
+	Reference string `json:"reference"`
+	// Layout is the path of OCI layout image would be pulled into relative to the bundle root, "" being the root itself.
+	Layout string `json:"layout"`
+	Digest string `json:"digest,omitempty"`
+	Rule   string `json:"rule"`
+}
+
+// NewImageExclusions validates reference globs and digests, returning nil if there are none.
+func NewImageExclusions(refPatterns, digests []string) (*ImageExclusions, error) {
+	if len(refPatterns) == 0 && len(digests) == 0 {
+		return nil, nil
+	}
+
+	e := &ImageExclusions{RefPatterns: refPatterns, Digests: digests, digests: make(map[string]struct{}, len(digests))}
+	for _, pattern := range refPatterns {
+		if pattern == "" {
+			return nil, errors.New("Reference pattern is empty")
+		}
+		e.refRegexps = append(e.refRegexps, globRegexp(pattern))
+	}
+	for _, digest := range digests {
+		if !strings.Contains(digest, ":") {
+			digest = "sha256:" + digest
+		}
+		hash, err := v1.NewHash(digest)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid digest %q: %w", digest, err)
+		}
+		e.digests[hash.String()] = struct{}{}
+	}
+	return e, nil
+}
+
+func globRegexp(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.NewReplacer(`\*`, `.*`, `\?`, `.`).Replace(quoted)
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+// MatchReference returns the first reference glob that matches ref.
+func (e *ImageExclusions) MatchReference(ref string) (pattern string, matched bool) {
+	if e == nil {
+		return "", false
+	}
+	for i, re := range e.refRegexps {
+		if re.MatchString(ref) {
+			return e.RefPatterns[i], true
+		}
+	}
+	return "", false
+}
+
+// MatchDigest reports whether image with manifest digest is excluded.
+func (e *ImageExclusions) MatchDigest(digest v1.Hash) bool {
+	if e == nil {
+		return false
+	}
+	_, found := e.digests[digest.String()]
+	return found
+}
+
+// Record remembers that image was excluded, so that exclusions can be stored in the bundle.
+func (e *ImageExclusions) Record(image ExcludedImage) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.excluded = append(e.excluded, image)
+}
+
+// Excluded returns all recorded images sorted by layout and reference.
+func (e *ImageExclusions) Excluded() []ExcludedImage {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	excluded := append([]ExcludedImage(nil), e.excluded...)
+	e.mu.Unlock()
+
+	sort.Slice(excluded, func(i, j int) bool {
+		if excluded[i].Layout != excluded[j].Layout {
+			return excluded[i].Layout < excluded[j].Layout
+		}
+		return excluded[i].Reference < excluded[j].Reference
+	})
+	return excluded
+}
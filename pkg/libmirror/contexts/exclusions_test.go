@@ -0,0 +1,61 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contexts
+
+import (
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageExclusions(t *testing.T) {
+	exclusions, err := NewImageExclusions(nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, exclusions, "no rules must exclude nothing")
+	_, matched := exclusions.MatchReference("registry.example.com/deckhouse/ee:v1.60.0")
+	require.False(t, matched)
+
+	digest := "sha256:" + strings.Repeat("a", 64)
+	exclusions, err = NewImageExclusions([]string{"*/modules/console:*", "*/ee:v1.6?.0"}, []string{strings.Repeat("a", 64)})
+	require.NoError(t, err)
+
+	pattern, matched := exclusions.MatchReference("registry.example.com/deckhouse/ee/modules/console:v1.2.3")
+	require.True(t, matched)
+	require.Equal(t, "*/modules/console:*", pattern)
+	pattern, matched = exclusions.MatchReference("registry.example.com/deckhouse/ee:v1.61.0")
+	require.True(t, matched)
+	require.Equal(t, "*/ee:v1.6?.0", pattern)
+	_, matched = exclusions.MatchReference("registry.example.com/deckhouse/ee/modules/console-extra:v1.2.3")
+	require.False(t, matched)
+
+	require.True(t, exclusions.MatchDigest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("a", 64)}), "digest without algorithm must default to sha256")
+	require.False(t, exclusions.MatchDigest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("b", 64)}))
+
+	exclusions.Record(ExcludedImage{Reference: "b:v1", Layout: "install", Rule: "--exclude-ref b:*"})
+	exclusions.Record(ExcludedImage{Reference: "a:v1", Layout: "install", Digest: digest, Rule: "--exclude-digest " + digest})
+	exclusions.Record(ExcludedImage{Reference: "c:v1", Rule: "--exclude-ref c:*"})
+	require.Equal(t, []string{"c:v1", "a:v1", "b:v1"}, []string{
+		exclusions.Excluded()[0].Reference, exclusions.Excluded()[1].Reference, exclusions.Excluded()[2].Reference,
+	})
+
+	_, err = NewImageExclusions([]string{""}, nil)
+	require.Error(t, err)
+	_, err = NewImageExclusions(nil, []string{"sha256:nothex"})
+	require.Error(t, err)
+}
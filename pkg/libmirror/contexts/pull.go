@@ -17,18 +17,86 @@ limitations under the License.
 package contexts
 
 import (
+	"crypto"
+
 	"github.com/Masterminds/semver/v3"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const (
+	SignaturePolicyEnforce = "enforce"
+	SignaturePolicyWarn    = "warn"
+)
+
+// Components of Deckhouse distribution that can be pulled selectively.
+const (
+	ComponentPlatform             = "platform"
+	ComponentModules              = "modules"
+	ComponentSecurity             = "security"
+	ComponentStandaloneInstallers = "install-standalone"
 )
 
+// Components lists all components of Deckhouse distribution, ComponentPlatform being Deckhouse images, installers and release channels.
+var Components = []string{ComponentPlatform, ComponentModules, ComponentSecurity, ComponentStandaloneInstallers}
+
 // PullContext holds data related to pending mirroring-from-registry operation.
 type PullContext struct {
 	BaseContext
 
 	DoGOSTDigests   bool  // --gost-digest
-	SkipModulesPull bool  // --no-modules
+	SkipModulesPull bool  // --no-modules, --skip-modules
 	BundleChunkSize int64 // Plain bytes
+	Concurrency     int   // --pull-concurrency, number of images of a single image set pulled at once
 
-	// Only one of those 2 is filled at a single time or none at all.
+	// Only one of those 3 is filled at a single time or none at all.
 	MinVersion      *semver.Version // --min-version
 	SpecificVersion *semver.Version // --release
+	SinceChannel    string          // --since-channel
+
+	// If set, cosign signatures of pulled images are checked against this key according to SignaturePolicy.
+	SourceSignatureKey crypto.PublicKey // --verify-source-signatures --key
+	SignaturePolicy    string           // --signature-policy
+
+	// Multi-platform image indexes are thinned to these platforms when written into layouts.
+	// If none are set, indexes are resolved to their linux/amd64 images instead, unless AllPlatforms is set.
+	Platforms    []v1.Platform // --platforms
+	AllPlatforms bool          // --platforms all
+
+	// IncludeCosign makes cosign signatures, attestations and SBOMs of every pulled image to be pulled alongside it.
+	IncludeCosign bool // --include-cosign
+
+	// If set, only modules from this map are pulled and only in the listed versions, release channels of them are not pulled.
+	PinnedModules map[string][]string // --modules-spec
+
+	// If set, pull succeeds even if installers of pulled releases are missing from source registry.
+	AllowIncomplete bool // --allow-incomplete
+
+	// Components of distribution left out of the pull, see PullsComponent.
+	SkippedComponents map[string]bool // --only, --skip-*
+
+	// Deckhouse and module images that have any of these manifest annotations or config labels set to the given value are not pulled.
+	SkipAnnotated map[string]string // --skip-annotated
+
+	// Images excluded from the pull by reference or digest, nil if none are.
+	Exclusions *ImageExclusions // --exclude-ref, --exclude-digest
+
+	// If set, packed bundle is encrypted to these OpenPGP public keys.
+	BundleRecipients openpgp.EntityList // --encrypt-recipient
+
+	// Images pulled by previous interrupted runs, nil if pull is not resumable.
+	Checkpoint *PullCheckpoint
+}
+
+// PullsImageIndexes reports whether multi-platform image indexes are written into layouts as indexes rather than as single images.
+func (c *PullContext) PullsImageIndexes() bool {
+	return c.AllPlatforms || len(c.Platforms) > 0
+}
+
+// PullsComponent reports whether component of distribution is pulled.
+func (c *PullContext) PullsComponent(component string) bool {
+	if component == ComponentModules && c.SkipModulesPull {
+		return false
+	}
+	return !c.SkippedComponents[component]
 }
@@ -16,11 +16,43 @@ limitations under the License.
 
 package contexts
 
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/regcaps"
+)
+
 // PushContext holds data related to pending mirroring-to-registry operation.
 type PushContext struct {
 	BaseContext
 
 	Parallelism ParallelismConfig
+
+	// SkipExistingTags enables checking registry for tags before pushing images,
+	// so that images already present in registry are not uploaded again.
+	SkipExistingTags bool
+
+	// IncludeCosign makes cosign signatures, attestations and SBOMs stored in bundle to be pushed along with images,
+	// they are left out otherwise.
+	IncludeCosign bool
+
+	// FlattenRepositories makes nested repositories to be pushed at the same path depth as the root one,
+	// for registries limiting it. Where every repository was pushed is recorded to FlattenMappingPath,
+	// as are repositories renamed to lowercase regardless of flattening.
+	FlattenRepositories bool
+	FlattenMappingPath  string
+
+	// RegistryCapabilities are optional APIs detected in target registry before push, nil if probing failed.
+	RegistryCapabilities *regcaps.Capabilities
+
+	// StreamedBundle serves images straight from packed bundle if set, only layouts metadata is unpacked
+	// into UnpackedImagesPath then. Images are read from layouts in UnpackedImagesPath otherwise.
+	StreamedBundle LayoutIndexes // --stream
+}
+
+// LayoutIndexes reads indexes of OCI layouts by their paths relative to the bundle root, "" being the root itself.
+type LayoutIndexes interface {
+	ImageIndex(layoutPath string) (v1.ImageIndex, error)
 }
 
 type ParallelismConfig struct {
@@ -0,0 +1,416 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contexts
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/taglist"
+)
+
+// Stages of a mirroring run that report their progress into RunContext.
+const (
+	StagePlatform = "platform"
+	StageModules  = "modules"
+	StageSecurity = "security"
+	StagePush     = "push"
+	StageVerify   = "verify"
+)
+
+// RunContext is shared by every stage of a single mirroring run within one process,
+// e.g. when pull and verification are chained or several services work in parallel.
+// All of its methods are safe for concurrent use and for use on nil *RunContext.
+type RunContext struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	Progress   *Progress
+	Metrics    *Metrics
+	Audit      *AuditLog
+	Timings    *Timings
+	Footprints *Footprints
+	Tags       *taglist.Lister
+}
+
+func NewRunContext(parent context.Context) *RunContext {
+	ctx, cancel := context.WithCancelCause(parent)
+	return &RunContext{
+		ctx:        ctx,
+		cancel:     cancel,
+		Progress:   &Progress{stages: map[string]*StageProgress{}},
+		Metrics:    &Metrics{},
+		Audit:      &AuditLog{},
+		Timings:    &Timings{},
+		Footprints: &Footprints{releases: map[string]ReleaseFootprint{}},
+		Tags:       taglist.NewLister(),
+	}
+}
+
+// Context returns context that is cancelled once any of the stages calls Cancel.
+func (r *RunContext) Context() context.Context {
+	if r == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// Cancel aborts the whole run, cause is returned by context.Cause for every stage.
+func (r *RunContext) Cancel(cause error) {
+	if r == nil {
+		return
+	}
+	r.cancel(cause)
+}
+
+func (r *RunContext) progress() *Progress {
+	if r == nil {
+		return nil
+	}
+	return r.Progress
+}
+
+func (r *RunContext) metrics() *Metrics {
+	if r == nil {
+		return nil
+	}
+	return r.Metrics
+}
+
+func (r *RunContext) audit() *AuditLog {
+	if r == nil {
+		return nil
+	}
+	return r.Audit
+}
+
+func (r *RunContext) timings() *Timings {
+	if r == nil {
+		return nil
+	}
+	return r.Timings
+}
+
+func (r *RunContext) footprints() *Footprints {
+	if r == nil {
+		return nil
+	}
+	return r.Footprints
+}
+
+// TagLister returns lister that caches repository tags for the whole run and handles registry throttling.
+func (r *RunContext) TagLister() *taglist.Lister {
+	if r == nil {
+		return nil
+	}
+	return r.Tags
+}
+
+// LastActivity returns the time progress of any stage was last updated, or zero time if there was none yet.
+func (r *RunContext) LastActivity() time.Time { return r.progress().LastUpdate() }
+
+// AddTotal increases amount of work expected to be done in stage.
+func (r *RunContext) AddTotal(stage string, n int) { r.progress().addTotal(stage, n) }
+
+// Advance marks n units of work in stage as done.
+func (r *RunContext) Advance(stage string, n int) { r.progress().advance(stage, n) }
+
+// RecordImagePulled updates metrics and audit log after image was written to the local layout.
+func (r *RunContext) RecordImagePulled(stage, ref string) {
+	if m := r.metrics(); m != nil {
+		m.ImagesPulled.Add(1)
+	}
+	r.audit().Record(stage, "pull", ref)
+}
+
+// RecordImageSkipped updates metrics and audit log after image was deliberately left out of the pull.
+func (r *RunContext) RecordImageSkipped(stage, ref string) {
+	if m := r.metrics(); m != nil {
+		m.ImagesSkipped.Add(1)
+	}
+	r.audit().Record(stage, AuditActionSkip, ref)
+}
+
+// RecordRepoPushed updates metrics and audit log after repository contents were written to the target registry.
+func (r *RunContext) RecordRepoPushed(stage, repo string) {
+	if m := r.metrics(); m != nil {
+		m.ReposPushed.Add(1)
+	}
+	r.audit().Record(stage, "push", repo)
+}
+
+// RecordTagPruned updates audit log after outdated tag was deleted from the target registry.
+func (r *RunContext) RecordTagPruned(stage, ref string) {
+	r.audit().Record(stage, AuditActionPrune, ref)
+}
+
+// RecordImageTiming saves time it took to pull image and its layers for the run summary.
+func (r *RunContext) RecordImageTiming(timing ImageTiming) { r.timings().record(timing) }
+
+// RecordReleaseFootprint saves amount and size of images required by a Deckhouse release for the run summary.
+func (r *RunContext) RecordReleaseFootprint(footprint ReleaseFootprint) {
+	r.footprints().record(footprint)
+}
+
+type StageProgress struct {
+	Stage string
+	Done  int
+	Total int
+}
+
+type Progress struct {
+	mu         sync.Mutex
+	stages     map[string]*StageProgress
+	lastUpdate time.Time
+}
+
+func (p *Progress) stage(name string) *StageProgress {
+	s, found := p.stages[name]
+	if !found {
+		s = &StageProgress{Stage: name}
+		p.stages[name] = s
+	}
+	return s
+}
+
+func (p *Progress) addTotal(stage string, n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stage(stage).Total += n
+	p.lastUpdate = time.Now()
+}
+
+func (p *Progress) advance(stage string, n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stage(stage).Done += n
+	p.lastUpdate = time.Now()
+}
+
+func (p *Progress) LastUpdate() time.Time {
+	if p == nil {
+		return time.Time{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastUpdate
+}
+
+// Snapshot returns a copy of current progress of all stages sorted by stage name.
+func (p *Progress) Snapshot() []StageProgress {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make([]StageProgress, 0, len(p.stages))
+	for _, s := range p.stages {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Stage < result[j].Stage })
+	return result
+}
+
+type Metrics struct {
+	ImagesPulled  atomic.Int64
+	ImagesSkipped atomic.Int64
+	ReposPushed   atomic.Int64
+}
+
+const (
+	AuditActionSkip  = "skip"
+	AuditActionPrune = "prune"
+)
+
+type AuditEntry struct {
+	Time    time.Time
+	Stage   string
+	Action  string
+	Subject string
+}
+
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+func (a *AuditLog) Record(stage, action, subject string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, AuditEntry{
+		Time:    time.Now(),
+		Stage:   stage,
+		Action:  action,
+		Subject: subject,
+	})
+}
+
+// Entries returns a copy of all audit log records in order they were recorded.
+func (a *AuditLog) Entries() []AuditEntry {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]AuditEntry(nil), a.entries...)
+}
+
+// LayerTiming describes how long it took to download a single layer blob.
+type LayerTiming struct {
+	Image  string
+	Digest string
+	Size   int64
+
+	// Response is time registry took to respond with headers, including redirects.
+	Response time.Duration
+	// Network is time spent waiting for the blob data to arrive from the network.
+	Network time.Duration
+	// Transfer is time from the response to the end of the blob data, which includes writing it to the local disk.
+	Transfer time.Duration
+}
+
+// Local returns time spent on local processing of the blob data, mostly writing it to disk.
+func (l LayerTiming) Local() time.Duration {
+	return max(l.Transfer-l.Network, 0)
+}
+
+// ImageTiming describes how long it took to pull a single image.
+type ImageTiming struct {
+	Stage     string
+	Reference string
+	Total     time.Duration
+
+	// ManifestResponse is total time registry took to respond to manifest requests for the image.
+	ManifestResponse time.Duration
+	// Layers are only those layers that were actually downloaded, blobs already present in the layout are not fetched again.
+	Layers []LayerTiming
+}
+
+type Timings struct {
+	mu     sync.Mutex
+	images []ImageTiming
+}
+
+func (t *Timings) record(timing ImageTiming) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.images = append(t.images, timing)
+}
+
+// SlowestImages returns up to n images that took longest to pull, slowest first.
+func (t *Timings) SlowestImages(n int) []ImageTiming {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	images := append([]ImageTiming(nil), t.images...)
+	t.mu.Unlock()
+
+	sort.SliceStable(images, func(i, j int) bool { return images[i].Total > images[j].Total })
+	return images[:min(n, len(images))]
+}
+
+// SlowestLayers returns up to n layers that took longest to download, slowest first.
+func (t *Timings) SlowestLayers(n int) []LayerTiming {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	layers := make([]LayerTiming, 0)
+	for _, image := range t.images {
+		layers = append(layers, image.Layers...)
+	}
+	t.mu.Unlock()
+
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].Response+layers[i].Transfer > layers[j].Response+layers[j].Transfer
+	})
+	return layers[:min(n, len(layers))]
+}
+
+// ReleaseFootprint is the number of images a single Deckhouse release consists of and their aggregate size.
+// Size counts every manifest, config and layer blob of the release once, even if it is shared by several images.
+type ReleaseFootprint struct {
+	Version string `json:"version"`
+	Images  int    `json:"images"`
+	Size    int64  `json:"size"`
+}
+
+type Footprints struct {
+	mu       sync.Mutex
+	releases map[string]ReleaseFootprint
+}
+
+func (f *Footprints) record(footprint ReleaseFootprint) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.releases[footprint.Version] = footprint
+}
+
+// Releases returns footprints of all recorded releases, oldest release first.
+func (f *Footprints) Releases() []ReleaseFootprint {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	releases := make([]ReleaseFootprint, 0, len(f.releases))
+	for _, footprint := range f.releases {
+		releases = append(releases, footprint)
+	}
+	f.mu.Unlock()
+
+	SortReleaseFootprints(releases)
+	return releases
+}
+
+// SortReleaseFootprints orders footprints by release version, oldest release first.
+// Versions that are not valid semver are placed last in lexical order.
+func SortReleaseFootprints(releases []ReleaseFootprint) {
+	sort.SliceStable(releases, func(i, j int) bool {
+		vi, errI := semver.NewVersion(releases[i].Version)
+		vj, errJ := semver.NewVersion(releases[j].Version)
+		switch {
+		case errI == nil && errJ == nil:
+			return vi.LessThan(vj)
+		case errI != nil && errJ != nil:
+			return releases[i].Version < releases[j].Version
+		default:
+			return errI == nil
+		}
+	})
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contexts
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunContextConcurrentUpdates(t *testing.T) {
+	run := NewRunContext(context.Background())
+
+	wg := &sync.WaitGroup{}
+	for _, stage := range []string{StagePlatform, StageModules, StageSecurity} {
+		run.AddTotal(stage, 100)
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				run.RecordImagePulled(stage, "registry.example.com/image:tag")
+				run.Advance(stage, 1)
+			}()
+		}
+	}
+	wg.Wait()
+
+	require.Equal(t, []StageProgress{
+		{Stage: StageModules, Done: 100, Total: 100},
+		{Stage: StagePlatform, Done: 100, Total: 100},
+		{Stage: StageSecurity, Done: 100, Total: 100},
+	}, run.Progress.Snapshot())
+	require.Equal(t, int64(300), run.Metrics.ImagesPulled.Load())
+	require.Len(t, run.Audit.Entries(), 300)
+}
+
+func TestRunContextCancel(t *testing.T) {
+	run := NewRunContext(context.Background())
+	cause := errors.New("verification failed")
+	run.Cancel(cause)
+
+	require.ErrorIs(t, run.Context().Err(), context.Canceled)
+	require.ErrorIs(t, context.Cause(run.Context()), cause)
+}
+
+func TestNilRunContextIsNoop(t *testing.T) {
+	var run *RunContext
+	run.AddTotal(StagePlatform, 1)
+	run.Advance(StagePlatform, 1)
+	run.RecordImagePulled(StagePlatform, "image")
+	run.Cancel(errors.New("ignored"))
+	require.NoError(t, run.Context().Err())
+}
+
+func TestRunContextSlowestTimings(t *testing.T) {
+	run := NewRunContext(context.Background())
+	run.RecordImageTiming(ImageTiming{Reference: "fast", Total: time.Second, Layers: []LayerTiming{
+		{Image: "fast", Digest: "sha256:a", Response: 100 * time.Millisecond, Transfer: 500 * time.Millisecond},
+	}})
+	run.RecordImageTiming(ImageTiming{Reference: "slow", Total: 3 * time.Second, Layers: []LayerTiming{
+		{Image: "slow", Digest: "sha256:b", Response: 2 * time.Second, Transfer: 200 * time.Millisecond},
+		{Image: "slow", Digest: "sha256:c", Response: 10 * time.Millisecond, Transfer: 10 * time.Millisecond},
+	}})
+
+	slowestImages := run.Timings.SlowestImages(1)
+	require.Len(t, slowestImages, 1)
+	require.Equal(t, "slow", slowestImages[0].Reference)
+
+	slowestLayers := run.Timings.SlowestLayers(5)
+	require.Len(t, slowestLayers, 3)
+	require.Equal(t, "sha256:b", slowestLayers[0].Digest)
+	require.Equal(t, "sha256:a", slowestLayers[1].Digest)
+
+	var nilRun *RunContext
+	nilRun.RecordImageTiming(ImageTiming{})
+}
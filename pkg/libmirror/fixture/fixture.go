@@ -0,0 +1,214 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fixture serves tiny synthetic Deckhouse source registry, so that bundles with correct layouts structure
+// and manifests can be pulled in a few seconds for CI tests of push and verification.
+package fixture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// RepoPath is the path of Deckhouse repository in fixture registry.
+const RepoPath = "/deckhouse/fixture"
+
+// ReleaseChannels maps fixture release channels to Deckhouse versions they point to.
+var ReleaseChannels = map[string]string{
+	"alpha":        "v1.56.5",
+	"beta":         "v1.56.5",
+	"early-access": "v1.55.7",
+	"stable":       "v1.55.7",
+	"rock-solid":   "v1.55.7",
+}
+
+// Versions are Deckhouse releases available in fixture registry.
+var Versions = []string{"v1.55.7", "v1.56.5"}
+
+// trivyDatabases are vulnerability databases images pulled by d8 mirror pull.
+var trivyDatabases = []string{
+	"security/trivy-db:2",
+	"security/trivy-bdu:1",
+	"security/trivy-java-db:1",
+	"security/trivy-checks:0",
+}
+
+// Registry is in-memory registry with fixture Deckhouse images listening on loopback interface.
+type Registry struct {
+	Host string
+
+	server *http.Server
+}
+
+// StartRegistry starts fixture registry and fills it with images. Images are built from fixed contents,
+// so their digests stay the same between runs and bundles can be used as golden files.
+func StartRegistry() (*Registry, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("Listen for fixture registry: %w", err)
+	}
+
+	r := &Registry{
+		Host:   listener.Addr().String(),
+		server: &http.Server{Handler: registry.New(registry.Logger(log.New(io.Discard, "", 0))), ReadHeaderTimeout: 10 * time.Second},
+	}
+	go func() { _ = r.server.Serve(listener) }()
+
+	if err = r.populate(); err != nil {
+		_ = r.Close()
+		return nil, fmt.Errorf("Fill fixture registry with images: %w", err)
+	}
+	return r, nil
+}
+
+// Repo returns Deckhouse repository of fixture registry, e.g. to be used as d8 mirror pull source.
+func (r *Registry) Repo() string {
+	return r.Host + RepoPath
+}
+
+func (r *Registry) Close() error {
+	if err := r.server.Close(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (r *Registry) populate() error {
+	repo := r.Repo()
+	for _, version := range Versions {
+		controller, err := image(map[string][]byte{"deckhouse/version": []byte(version)})
+		if err != nil {
+			return err
+		}
+		installer, err := r.installerImage(version)
+		if err != nil {
+			return err
+		}
+		releaseChannel, err := releaseChannelImage(version)
+		if err != nil {
+			return err
+		}
+
+		tags := []string{version}
+		for channel, channelVersion := range ReleaseChannels {
+			if channelVersion == version {
+				tags = append(tags, channel)
+			}
+		}
+		for _, tag := range tags {
+			if err = write(repo+":"+tag, controller); err != nil {
+				return err
+			}
+			if err = write(repo+"/install:"+tag, installer); err != nil {
+				return err
+			}
+			if err = write(repo+"/install-standalone:"+tag, installer); err != nil {
+				return err
+			}
+			if err = write(repo+"/release-channel:"+tag, releaseChannel); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, database := range trivyDatabases {
+		img, err := image(map[string][]byte{"db/metadata.json": []byte(`{"Version":2}`)})
+		if err != nil {
+			return err
+		}
+		if err = write(repo+"/"+database, img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// installerImage resembles Deckhouse installer that lists digests of built-in modules images
+// in /deckhouse/candi/images_digests.json, those images are written to registry as well.
+func (r *Registry) installerImage(version string) (v1.Image, error) {
+	digests := map[string]map[string]string{}
+	for module, moduleImages := range map[string][]string{"common": {"alpine"}, "nodeManager": {"bashibleApiserver"}} {
+		digests[module] = map[string]string{}
+		for _, moduleImage := range moduleImages {
+			img, err := image(map[string][]byte{moduleImage: []byte(module + "/" + moduleImage + ":" + version)})
+			if err != nil {
+				return nil, err
+			}
+			digest, err := img.Digest()
+			if err != nil {
+				return nil, err
+			}
+			if err = write(r.Repo()+"@"+digest.String(), img); err != nil {
+				return nil, err
+			}
+			digests[module][moduleImage] = digest.String()
+		}
+	}
+
+	imagesDigests, err := json.Marshal(digests)
+	if err != nil {
+		return nil, err
+	}
+	return image(map[string][]byte{
+		"deckhouse/version":                   []byte(version),
+		"deckhouse/candi/images_digests.json": imagesDigests,
+	})
+}
+
+func releaseChannelImage(version string) (v1.Image, error) {
+	versionInfo := fmt.Sprintf(`{"requirements":{"k8s":"1.23.0"},"version":%q}`, version)
+	return image(map[string][]byte{
+		"version.json":   []byte(versionInfo),
+		"changelog.yaml": []byte("candi:\n  fixes: []\n"),
+	})
+}
+
+func image(files map[string][]byte) (v1.Image, error) {
+	layer, err := crane.Layer(files)
+	if err != nil {
+		return nil, fmt.Errorf("Build image layer: %w", err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		return nil, fmt.Errorf("Build image: %w", err)
+	}
+	return img, nil
+}
+
+func write(imageRef string, img v1.Image) error {
+	ref, err := name.ParseReference(imageRef, name.Insecure)
+	if err != nil {
+		return fmt.Errorf("Parse %s: %w", imageRef, err)
+	}
+	if err = remote.Write(ref, img); err != nil {
+		return fmt.Errorf("Write %s: %w", imageRef, err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixture
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryImagesAreReproducible(t *testing.T) {
+	digestsOf := func() map[string]string {
+		r, err := StartRegistry()
+		require.NoError(t, err)
+		defer r.Close()
+
+		digests := map[string]string{}
+		for _, segment := range []string{"", "/install", "/release-channel", "/security/trivy-db"} {
+			repo, err := name.NewRepository(r.Repo()+segment, name.Insecure)
+			require.NoError(t, err)
+			tags, err := remote.List(repo)
+			require.NoError(t, err)
+			require.NotEmpty(t, tags, "Repository %q should have tags", segment)
+			for _, tag := range tags {
+				desc, err := remote.Head(repo.Tag(tag))
+				require.NoError(t, err)
+				digests[segment+":"+tag] = desc.Digest.String()
+			}
+		}
+		return digests
+	}
+
+	first, second := digestsOf(), digestsOf()
+	require.Equal(t, first, second)
+	require.Equal(t, first[":stable"], first[":v1.55.7"])
+	require.Equal(t, first["/release-channel:alpha"], first["/release-channel:v1.56.5"])
+	require.NotEqual(t, first["/install:v1.55.7"], first["/install:v1.56.5"])
+}
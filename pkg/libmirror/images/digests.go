@@ -23,11 +23,16 @@ import (
 	"io"
 	"io/fs"
 	"regexp"
+	"sort"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
 )
 
 var digestRegex = regexp.MustCompile(`sha256:([a-f0-9]{64})`)
@@ -64,6 +69,16 @@ func ExtractImageDigestsFromDeckhouseInstaller(
 		return nil, fmt.Errorf("cannot read image from index: %w", err)
 	}
 
+	return ExtractImageDigestsFromInstallerImage(mirrorCtx, installerTag, img)
+}
+
+// ExtractImageDigestsFromInstallerImage reads references of Deckhouse images from the installer image,
+// which may be either pulled into the local layout or read directly from the registry.
+func ExtractImageDigestsFromInstallerImage(
+	mirrorCtx *contexts.PullContext,
+	installerTag string,
+	img v1.Image,
+) (map[string]struct{}, error) {
 	tagsCompatMode := false
 	imagesJSON, err := ExtractFileFromImage(img, "deckhouse/candi/images_digests.json")
 	switch {
@@ -122,3 +137,56 @@ func parseImagesFromJSON(registryRepo string, jsonDigests io.Reader, dst map[str
 
 	return nil
 }
+
+// SuspiciousImage is an image reference extracted from the installer that could not be found in the source registry.
+type SuspiciousImage struct {
+	Reference string
+	Reason    string
+}
+
+func (s SuspiciousImage) String() string {
+	return s.Reference + ": " + s.Reason
+}
+
+// VerifyImagesExistInRegistry checks that every image from the set exists in the source registry
+// and is served with the digest it is referenced by.
+// Images that fail this check are removed from the set and returned, as they most likely come from a corrupted installer.
+func VerifyImagesExistInRegistry(mirrorCtx *contexts.BaseContext, images map[string]struct{}) ([]SuspiciousImage, error) {
+	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(mirrorCtx)
+
+	suspicious := make([]SuspiciousImage, 0)
+	for imageRef := range images {
+		reason, err := checkImageInRegistry(imageRef, nameOpts, remoteOpts)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			suspicious = append(suspicious, SuspiciousImage{Reference: imageRef, Reason: reason})
+			delete(images, imageRef)
+		}
+	}
+
+	sort.Slice(suspicious, func(i, j int) bool { return suspicious[i].Reference < suspicious[j].Reference })
+	return suspicious, nil
+}
+
+// checkImageInRegistry returns non-empty reason if the image reference is not trustworthy.
+func checkImageInRegistry(imageRef string, nameOpts []name.Option, remoteOpts []remote.Option) (string, error) {
+	ref, err := name.ParseReference(imageRef, nameOpts...)
+	if err != nil {
+		return "malformed image reference", nil
+	}
+
+	desc, err := remote.Head(ref, remoteOpts...)
+	switch {
+	case errorutil.IsImageNotFoundError(err):
+		return "not found in the source registry", nil
+	case err != nil:
+		return "", fmt.Errorf("get image descriptor for %q: %w", imageRef, err)
+	}
+
+	if digestRef, isDigest := ref.(name.Digest); isDigest && desc.Digest.String() != digestRef.DigestStr() {
+		return fmt.Sprintf("registry returned manifest with different digest %s", desc.Digest), nil
+	}
+	return "", nil
+}
@@ -18,16 +18,21 @@ package images
 
 import (
 	"encoding/json"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/exp/maps"
 
@@ -113,3 +118,29 @@ func createEmptyImageLayout(t *testing.T, path string) layout.Path {
 	require.NoError(t, err)
 	return l
 }
+
+func TestVerifyImagesExistInRegistry(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	repo := strings.TrimPrefix(server.URL, "http://") + "/deckhouse"
+
+	img, err := random.Image(256, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(repo+":existing", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	imgDigest, err := img.Digest()
+	require.NoError(t, err)
+
+	existingImage := repo + "@" + imgDigest.String()
+	missingImage := repo + "@sha256:" + strings.Repeat("0", 64)
+	imageSet := map[string]struct{}{
+		existingImage: {},
+		missingImage:  {},
+	}
+
+	suspicious, err := VerifyImagesExistInRegistry(&contexts.BaseContext{Insecure: true}, imageSet)
+	require.NoError(t, err)
+	require.Equal(t, []SuspiciousImage{{Reference: missingImage, Reason: "not found in the source registry"}}, suspicious)
+	require.Equal(t, map[string]struct{}{existingImage: {}}, imageSet)
+}
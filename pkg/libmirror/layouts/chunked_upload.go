@@ -0,0 +1,263 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+)
+
+// ErrUploadCorrupted is returned when blob upload keeps being rejected with digest mismatch even with small chunks.
+var ErrUploadCorrupted = errors.New("uploaded data is corrupted in transit")
+
+const proxyCorruptionDiagnosis = "registry keeps receiving data that does not match its digest. " +
+	"This is usually caused by TLS-inspecting or caching proxy between d8 and the registry modifying uploaded data. " +
+	"Exclude registry host from proxy inspection or push from a host with direct access to the registry"
+
+// digestMismatchRetryChunkSizes are sizes of chunks to re-upload blobs rejected with digest mismatch with, from largest to smallest.
+var digestMismatchRetryChunkSizes = []int64{16 << 20, 4 << 20, 1 << 20}
+
+// chunkedBlobUploader uploads blobs using chunked upload protocol of OCI distribution spec,
+// as a fallback for monolithic uploads corrupted by intermediary proxies.
+type chunkedBlobUploader struct {
+	authProvider  authn.Authenticator
+	skipVerifyTLS bool
+	chunkSizes    []int64
+}
+
+func newChunkedBlobUploader(authProvider authn.Authenticator, skipVerifyTLS bool) *chunkedBlobUploader {
+	if authProvider == nil {
+		authProvider = authn.Anonymous
+	}
+	return &chunkedBlobUploader{
+		authProvider:  authProvider,
+		skipVerifyTLS: skipVerifyTLS,
+		chunkSizes:    digestMismatchRetryChunkSizes,
+	}
+}
+
+// uploadImageBlobs uploads config and layers of the image missing from repo, decreasing chunk size every time
+// registry reports digest mismatch. It returns ErrUploadCorrupted if upload fails even with the smallest chunk size.
+func (u *chunkedBlobUploader) uploadImageBlobs(ctx context.Context, repo name.Repository, img v1.Image) error {
+	client, err := u.client(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("read image layers: %w", err)
+	}
+	configDigest, err := img.ConfigName()
+	if err != nil {
+		return fmt.Errorf("read image config digest: %w", err)
+	}
+	rawConfig, err := img.RawConfigFile()
+	if err != nil {
+		return fmt.Errorf("read image config: %w", err)
+	}
+
+	blobs := map[v1.Hash]func() (io.ReadCloser, error){
+		configDigest: func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(rawConfig)), nil },
+	}
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			return fmt.Errorf("read layer digest: %w", err)
+		}
+		blobs[digest] = layer.Compressed
+	}
+
+	for digest, open := range blobs {
+		exists, err := u.blobExists(ctx, client, repo, digest)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if err = u.uploadBlobWithShrinkingChunks(ctx, client, repo, digest, open); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *chunkedBlobUploader) uploadBlobWithShrinkingChunks(
+	ctx context.Context,
+	client *http.Client,
+	repo name.Repository,
+	digest v1.Hash,
+	open func() (io.ReadCloser, error),
+) error {
+	var lastErr error
+	for _, chunkSize := range u.chunkSizes {
+		lastErr = u.uploadBlob(ctx, client, repo, digest, open, chunkSize)
+		if lastErr == nil {
+			return nil
+		}
+		if !errors.Is(lastErr, ErrUploadCorrupted) {
+			return lastErr
+		}
+	}
+	return fmt.Errorf("upload blob %s: %w: %s", digest, lastErr, proxyCorruptionDiagnosis)
+}
+
+func (u *chunkedBlobUploader) uploadBlob(
+	ctx context.Context,
+	client *http.Client,
+	repo name.Repository,
+	digest v1.Hash,
+	open func() (io.ReadCloser, error),
+	chunkSize int64,
+) error {
+	blob, err := open()
+	if err != nil {
+		return fmt.Errorf("open blob %s: %w", digest, err)
+	}
+	defer blob.Close()
+
+	uploadsURL := url.URL{Scheme: repo.Registry.Scheme(), Host: repo.RegistryStr(), Path: "/v2/" + repo.RepositoryStr() + "/blobs/uploads/"}
+	location, err := u.do(ctx, client, http.MethodPost, uploadsURL.String(), nil, nil, http.StatusAccepted)
+	if err != nil {
+		return fmt.Errorf("start upload of blob %s: %w", digest, err)
+	}
+	if location == "" {
+		return fmt.Errorf("start upload of blob %s: registry did not return upload location", digest)
+	}
+
+	buf := make([]byte, chunkSize)
+	offset := int64(0)
+	for {
+		n, readErr := io.ReadFull(blob, buf)
+		if n > 0 {
+			headers := map[string]string{
+				"Content-Type":  "application/octet-stream",
+				"Content-Range": fmt.Sprintf("%d-%d", offset, offset+int64(n)-1),
+			}
+			location, err = u.do(ctx, client, http.MethodPatch, location, buf[:n], headers, http.StatusAccepted, http.StatusNoContent)
+			if err != nil {
+				return fmt.Errorf("upload chunk of blob %s: %w", digest, err)
+			}
+			offset += int64(n)
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("read blob %s: %w", digest, readErr)
+		}
+	}
+
+	commitURL, err := url.Parse(location)
+	if err != nil {
+		return fmt.Errorf("parse upload location: %w", err)
+	}
+	query := commitURL.Query()
+	query.Set("digest", digest.String())
+	commitURL.RawQuery = query.Encode()
+	if _, err = u.do(ctx, client, http.MethodPut, commitURL.String(), nil, nil, http.StatusCreated); err != nil {
+		return fmt.Errorf("commit blob %s: %w", digest, err)
+	}
+	return nil
+}
+
+func (u *chunkedBlobUploader) blobExists(ctx context.Context, client *http.Client, repo name.Repository, digest v1.Hash) (bool, error) {
+	blobURL := url.URL{Scheme: repo.Registry.Scheme(), Host: repo.RegistryStr(), Path: "/v2/" + repo.RepositoryStr() + "/blobs/" + digest.String()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, blobURL.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("check if blob %s exists: %w", digest, err)
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// do sends request and returns absolute Location header of response, as upload URL changes after every request.
+func (u *chunkedBlobUploader) do(
+	ctx context.Context,
+	client *http.Client,
+	method, rawURL string,
+	body []byte,
+	headers map[string]string,
+	expectedStatuses ...int,
+) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = int64(len(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err = transport.CheckError(resp, expectedStatuses...); err != nil {
+		var transportErr *transport.Error
+		if errors.As(err, &transportErr) && isDigestInvalid(transportErr) {
+			return "", fmt.Errorf("%w: %v", ErrUploadCorrupted, err)
+		}
+		return "", err
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		if errors.Is(err, http.ErrNoLocation) {
+			return "", nil
+		}
+		return "", err
+	}
+	return location.String(), nil
+}
+
+func (u *chunkedBlobUploader) client(ctx context.Context, repo name.Repository) (*http.Client, error) {
+	baseTransport := auth.MakeTransport(u.skipVerifyTLS)
+	rt, err := transport.NewWithContext(ctx, repo.Registry, u.authProvider, baseTransport, []string{repo.Scope(transport.PushScope)})
+	if err != nil {
+		return nil, fmt.Errorf("authenticate to %s: %w", repo.RegistryStr(), err)
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+func isDigestInvalid(err *transport.Error) bool {
+	for _, diagnostic := range err.Errors {
+		if diagnostic.Code == transport.DigestInvalidErrorCode {
+			return true
+		}
+	}
+	return false
+}
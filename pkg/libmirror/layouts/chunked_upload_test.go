@@ -0,0 +1,134 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/regcaps"
+)
+
+func TestPushLayoutToRepoRetriesCorruptedUploadsInChunks(t *testing.T) {
+	// Proxy corrupts every request body larger than 1 MiB, so that only the smallest chunks get through.
+	host := setupCorruptingRegistry(t, 1<<20)
+	repo := host + "/deckhouse/ee"
+
+	img, err := random.Image(3<<20, 1)
+	require.NoError(t, err)
+	imagesLayout := createEmptyOCILayout(t)
+	require.NoError(t, imagesLayout.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"io.deckhouse.image.short_tag": "v1.0.0",
+	})))
+
+	err = pushToCorruptingRegistry(imagesLayout, repo)
+	require.NoError(t, err)
+
+	ref, err := name.ParseReference(repo+":v1.0.0", name.Insecure)
+	require.NoError(t, err)
+	pushedImg, err := remote.Image(ref)
+	require.NoError(t, err)
+	expectedDigest, err := img.Digest()
+	require.NoError(t, err)
+	pushedDigest, err := pushedImg.Digest()
+	require.NoError(t, err)
+	require.Equal(t, expectedDigest, pushedDigest)
+}
+
+func TestPushLayoutToRepoReportsPersistentUploadCorruption(t *testing.T) {
+	host := setupCorruptingRegistry(t, 0)
+
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	imagesLayout := createEmptyOCILayout(t)
+	require.NoError(t, imagesLayout.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"io.deckhouse.image.short_tag": "v1.0.0",
+	})))
+
+	err = pushToCorruptingRegistry(imagesLayout, host+"/deckhouse/ee")
+	require.Error(t, err)
+	require.ErrorContains(t, err, ErrUploadCorrupted.Error())
+	require.ErrorContains(t, err, "proxy")
+}
+
+func TestPushLayoutToRepoDoesNotRetryInChunksIfRegistryRejectsThem(t *testing.T) {
+	host := setupCorruptingRegistry(t, 1<<20)
+
+	img, err := random.Image(3<<20, 1)
+	require.NoError(t, err)
+	imagesLayout := createEmptyOCILayout(t)
+	require.NoError(t, imagesLayout.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"io.deckhouse.image.short_tag": "v1.0.0",
+	})))
+
+	err = pushToCorruptingRegistry(
+		imagesLayout, host+"/deckhouse/ee",
+		WithRegistryCapabilities(&regcaps.Capabilities{ChunkedUpload: regcaps.Unsupported}),
+	)
+	require.ErrorContains(t, err, ErrUploadCorrupted.Error())
+}
+
+func pushToCorruptingRegistry(l layout.Path, repo string, opts ...func(opts *pushLayoutOptions)) error {
+	return PushLayoutToRepo(
+		l,
+		repo,
+		authn.Anonymous,
+		log.NewSLogger(slog.LevelDebug),
+		contexts.DefaultParallelism,
+		true,  // Use plain insecure HTTP
+		false, // TLS verification irrelevant to HTTP requests
+		opts...,
+	)
+}
+
+// setupCorruptingRegistry starts registry behind a proxy that flips a byte in request bodies larger than maxIntactBody.
+func setupCorruptingRegistry(t *testing.T, maxIntactBody int) string {
+	t.Helper()
+
+	registryHandler := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxIntactBody && strings.Contains(r.URL.Path, "/blobs/uploads/") {
+			body[len(body)/2] ^= 0xff
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		registryHandler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return strings.TrimPrefix(server.URL, "http://")
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+// ChannelSegments are bundle segments in which every release channel tag must point to the same Deckhouse version,
+// so that installer and Deckhouse images installed from the same channel match.
+// Bundles of every edition are checked with them, as segments missing from bundle are skipped.
+var ChannelSegments = contexts.EnterpriseEdition.ChannelSegments()
+
+// InstallerSegments are bundle segments that must have installer image for every Deckhouse release and release channel.
+var InstallerSegments = contexts.EnterpriseEdition.InstallerSegments()
+
+var (
+	versionTagRegexp = regexp.MustCompile(`^v\d+\.\d+\.\d+(-.+)?$`)
+	digestTagRegexp  = regexp.MustCompile(`^[a-f0-9]{64}$`)
+)
+
+// SegmentTags maps tags of a repository to digests of images they refer to.
+// Well-formed repository has exactly one digest for every tag.
+type SegmentTags map[string][]v1.Hash
+
+func (t SegmentTags) Add(tag string, digest v1.Hash) {
+	for _, d := range t[tag] {
+		if d == digest {
+			return
+		}
+	}
+	t[tag] = append(t[tag], digest)
+}
+
+// TagConflict describes a tag that refers to inconsistent images within the bundle or registry.
+type TagConflict struct {
+	Tag        string   `json:"tag"`
+	Reason     string   `json:"reason"`
+	References []string `json:"references"`
+}
+
+func (c TagConflict) String() string {
+	return fmt.Sprintf("%s: %s (%s)", c.Tag, c.Reason, strings.Join(c.References, ", "))
+}
+
+// LayoutTags reads tags of all images in the layout.
+func LayoutTags(l layout.Path) (SegmentTags, error) {
+	index, err := l.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("read index manifest: %w", err)
+	}
+
+	tags := SegmentTags{}
+	for _, desc := range manifest.Manifests {
+		if tag, found := desc.Annotations["io.deckhouse.image.short_tag"]; found {
+			tags.Add(tag, desc.Digest)
+		}
+	}
+	return tags, nil
+}
+
+// MissingInstaller is installer image of Deckhouse release or release channel that the bundle lacks.
+type MissingInstaller struct {
+	Segment string `json:"segment"`
+	Tag     string `json:"tag"`
+	// Channels are release channels pointing to the release, if any.
+	Channels []string `json:"channels,omitempty"`
+}
+
+func (m MissingInstaller) String() string {
+	if len(m.Channels) == 0 {
+		return segmentRef(m.Segment, m.Tag)
+	}
+	return fmt.Sprintf("%s (release channels %s)", segmentRef(m.Segment, m.Tag), strings.Join(m.Channels, ", "))
+}
+
+// CheckTagsConsistency looks for conflicting tags across Deckhouse and installers layouts after pull.
+func CheckTagsConsistency(imageLayouts *ImageLayouts) ([]TagConflict, error) {
+	segments, err := readChannelSegments(imageLayouts)
+	if err != nil {
+		return nil, err
+	}
+	return FindTagConflicts(segments), nil
+}
+
+// CheckInstallersCompleteness looks for Deckhouse releases and release channels pulled without installers after pull.
+// Missing standalone installers are otherwise only noticed during bootstrap, as they are allowed to be missing in registry.
+func CheckInstallersCompleteness(imageLayouts *ImageLayouts) ([]MissingInstaller, error) {
+	segments, err := readChannelSegments(imageLayouts)
+	if err != nil {
+		return nil, err
+	}
+	return FindMissingInstallers(segments), nil
+}
+
+func readChannelSegments(imageLayouts *ImageLayouts) (map[string]SegmentTags, error) {
+	segments := map[string]SegmentTags{}
+	for segment, l := range map[string]layout.Path{
+		"":                   imageLayouts.Deckhouse,
+		"install":            imageLayouts.Install,
+		"install-standalone": imageLayouts.InstallStandalone,
+	} {
+		if l == "" {
+			continue
+		}
+		tags, err := LayoutTags(l)
+		if err != nil {
+			return nil, fmt.Errorf("read tags of %s: %w", filepath.Base(string(l)), err)
+		}
+		segments[segment] = tags
+	}
+	return segments, nil
+}
+
+// FindMissingInstallers reports Deckhouse releases and release channels of the root segment
+// that have no tag in any of InstallerSegments present in segments, keyed by their path relative to Deckhouse repo root.
+func FindMissingInstallers(segments map[string]SegmentTags) []MissingInstaller {
+	releases := segments[""]
+	channels := map[string][]string{} // version -> channels pointing to it
+	for _, tag := range sortedKeys(releases) {
+		if versionTagRegexp.MatchString(tag) || IsDigestTag(tag) {
+			continue
+		}
+		for _, version := range strings.Split(resolveVersionTag(releases, releases[tag]), "|") {
+			channels[version] = append(channels[version], tag)
+		}
+	}
+
+	missing := make([]MissingInstaller, 0)
+	for _, segment := range InstallerSegments {
+		installers, found := segments[segment]
+		if !found {
+			continue
+		}
+		for _, tag := range sortedKeys(releases) {
+			if _, found = installers[tag]; found || IsDigestTag(tag) {
+				continue
+			}
+			missing = append(missing, MissingInstaller{Segment: segment, Tag: tag, Channels: channels[tag]})
+		}
+	}
+	return missing
+}
+
+// FindTagConflicts reports tags that refer to several images within one segment,
+// and release channel tags that point to different Deckhouse versions in different ChannelSegments.
+// Segments are keyed by their path relative to Deckhouse repo root.
+func FindTagConflicts(segments map[string]SegmentTags) []TagConflict {
+	conflicts := make([]TagConflict, 0)
+
+	for _, segment := range sortedKeys(segments) {
+		tags := segments[segment]
+		for _, tag := range sortedKeys(tags) {
+			if len(tags[tag]) < 2 {
+				continue
+			}
+			refs := make([]string, 0, len(tags[tag]))
+			for _, digest := range tags[tag] {
+				refs = append(refs, segmentRef(segment, tag)+"@"+digest.String())
+			}
+			conflicts = append(conflicts, TagConflict{
+				Tag:        tag,
+				Reason:     "tag refers to several different images",
+				References: refs,
+			})
+		}
+	}
+
+	channelVersions := map[string]map[string]string{} // channel -> segment -> version
+	for _, segment := range ChannelSegments {
+		tags, found := segments[segment]
+		if !found {
+			continue
+		}
+		for tag, digests := range tags {
+			if versionTagRegexp.MatchString(tag) || IsDigestTag(tag) {
+				continue
+			}
+			if channelVersions[tag] == nil {
+				channelVersions[tag] = map[string]string{}
+			}
+			channelVersions[tag][segment] = resolveVersionTag(tags, digests)
+		}
+	}
+
+	for _, channel := range sortedKeys(channelVersions) {
+		versions := channelVersions[channel]
+		distinct := map[string]struct{}{}
+		for _, version := range versions {
+			distinct[version] = struct{}{}
+		}
+		if len(distinct) < 2 {
+			continue
+		}
+
+		refs := make([]string, 0, len(versions))
+		for _, segment := range ChannelSegments {
+			if version, found := versions[segment]; found {
+				refs = append(refs, segmentRef(segment, channel)+" -> "+version)
+			}
+		}
+		conflicts = append(conflicts, TagConflict{
+			Tag:        channel,
+			Reason:     "release channel points to different Deckhouse versions",
+			References: refs,
+		})
+	}
+
+	return conflicts
+}
+
+// IsDigestTag reports whether tag was made from digest of image pulled by digest rather than by tag.
+func IsDigestTag(tag string) bool {
+	return digestTagRegexp.MatchString(tag)
+}
+
+// resolveVersionTag returns version tags of the segment referring to any of the digests.
+func resolveVersionTag(tags SegmentTags, digests []v1.Hash) string {
+	versions := make([]string, 0)
+	for tag, tagDigests := range tags {
+		// Ambiguous version tags are reported on their own and cannot tell which version the channel is at.
+		if !versionTagRegexp.MatchString(tag) || len(tagDigests) > 1 {
+			continue
+		}
+		for _, digest := range tagDigests {
+			if containsDigest(digests, digest) {
+				versions = append(versions, tag)
+				break
+			}
+		}
+	}
+	if len(versions) == 0 {
+		return "unknown version"
+	}
+	sort.Strings(versions)
+	return strings.Join(versions, "|")
+}
+
+func containsDigest(digests []v1.Hash, digest v1.Hash) bool {
+	for _, d := range digests {
+		if d == digest {
+			return true
+		}
+	}
+	return false
+}
+
+func segmentRef(segment, tag string) string {
+	if segment == "" {
+		return "<root>:" + tag
+	}
+	return segment + ":" + tag
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindTagConflicts(t *testing.T) {
+	v160, v161 := randomDigest(t), randomDigest(t)
+	installV160, installV161 := randomDigest(t), randomDigest(t)
+
+	conflicts := FindTagConflicts(map[string]SegmentTags{
+		"": {
+			"v1.60.0": {v160},
+			"v1.61.0": {v161},
+			"stable":  {v160},
+			"alpha":   {v161},
+		},
+		"install": {
+			"v1.60.0": {installV160},
+			"v1.61.0": {installV161},
+			"stable":  {installV161},
+			"alpha":   {installV161},
+			"v1.59.0": {installV160, installV161},
+		},
+	})
+
+	require.Equal(t, []TagConflict{
+		{
+			Tag:        "v1.59.0",
+			Reason:     "tag refers to several different images",
+			References: []string{"install:v1.59.0@" + installV160.String(), "install:v1.59.0@" + installV161.String()},
+		},
+		{
+			Tag:        "stable",
+			Reason:     "release channel points to different Deckhouse versions",
+			References: []string{"<root>:stable -> v1.60.0", "install:stable -> v1.61.0"},
+		},
+	}, conflicts)
+}
+
+func TestCheckTagsConsistency(t *testing.T) {
+	imageLayouts := &ImageLayouts{
+		Deckhouse: createEmptyOCILayout(t),
+		Install:   createEmptyOCILayout(t),
+	}
+	for _, l := range []layout.Path{imageLayouts.Deckhouse, imageLayouts.Install} {
+		v160, v161 := randomImage(t), randomImage(t)
+		appendWithTag(t, l, "v1.60.0", v160)
+		appendWithTag(t, l, "v1.61.0", v161)
+		appendWithTag(t, l, "stable", v160)
+		if l == imageLayouts.Deckhouse {
+			appendWithTag(t, l, "alpha", v161)
+		} else {
+			appendWithTag(t, l, "alpha", v160)
+		}
+	}
+
+	conflicts, err := CheckTagsConsistency(imageLayouts)
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1)
+	require.Equal(t, "alpha", conflicts[0].Tag)
+	require.Equal(t, []string{"<root>:alpha -> v1.61.0", "install:alpha -> v1.60.0"}, conflicts[0].References)
+}
+
+func TestFindMissingInstallers(t *testing.T) {
+	v160, v161 := randomDigest(t), randomDigest(t)
+
+	missing := FindMissingInstallers(map[string]SegmentTags{
+		"": {
+			"v1.60.0": {v160},
+			"v1.61.0": {v161},
+			"stable":  {v161},
+			"alpha":   {v161},
+		},
+		"install": {
+			"v1.60.0": {randomDigest(t)},
+			"v1.61.0": {randomDigest(t)},
+			"stable":  {randomDigest(t)},
+			"alpha":   {randomDigest(t)},
+		},
+		"install-standalone": {
+			"v1.60.0": {randomDigest(t)},
+			"alpha":   {randomDigest(t)},
+		},
+	})
+
+	require.Equal(t, []MissingInstaller{
+		{Segment: "install-standalone", Tag: "stable"},
+		{Segment: "install-standalone", Tag: "v1.61.0", Channels: []string{"alpha", "stable"}},
+	}, missing)
+	require.Equal(t, "install-standalone:v1.61.0 (release channels alpha, stable)", missing[1].String())
+}
+
+func randomDigest(t *testing.T) v1.Hash {
+	t.Helper()
+	digest, err := randomImage(t).Digest()
+	require.NoError(t, err)
+	return digest
+}
+
+func randomImage(t *testing.T) v1.Image {
+	t.Helper()
+	img, err := random.Image(32, 1)
+	require.NoError(t, err)
+	return img
+}
+
+func appendWithTag(t *testing.T, l layout.Path, tag string, img v1.Image) {
+	t.Helper()
+	require.NoError(t, l.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"io.deckhouse.image.short_tag": tag,
+	})))
+}
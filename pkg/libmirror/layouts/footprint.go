@@ -0,0 +1,104 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+// ComputeReleaseFootprints counts images of every Deckhouse release and sums sizes of their blobs in the pulled layouts.
+// releaseImages maps release version to references of the images it consists of,
+// images that were not pulled, e.g. skipped with --skip-annotated, are not counted.
+func ComputeReleaseFootprints(
+	imageLayouts *ImageLayouts,
+	releaseImages map[string]map[string]struct{},
+) ([]contexts.ReleaseFootprint, error) {
+	pulledImages := map[string]blobSet{}
+	for _, l := range []layout.Path{imageLayouts.Deckhouse, imageLayouts.Install} {
+		if err := collectImageBlobs(l, pulledImages); err != nil {
+			return nil, fmt.Errorf("Read images of %s: %w", l, err)
+		}
+	}
+
+	footprints := make([]contexts.ReleaseFootprint, 0, len(releaseImages))
+	for version, imageSet := range releaseImages {
+		footprint := contexts.ReleaseFootprint{Version: version}
+		releaseBlobs := blobSet{}
+		for imageRef := range imageSet {
+			blobs, found := pulledImages[imageRef]
+			if !found {
+				continue
+			}
+			footprint.Images++
+			for digest, size := range blobs {
+				releaseBlobs[digest] = size
+			}
+		}
+		for _, size := range releaseBlobs {
+			footprint.Size += size
+		}
+		footprints = append(footprints, footprint)
+	}
+
+	contexts.SortReleaseFootprints(footprints)
+	return footprints, nil
+}
+
+// blobSet maps digests of image blobs to their sizes.
+type blobSet map[v1.Hash]int64
+
+// collectImageBlobs finds blobs of every image in layout by the reference image was pulled with.
+func collectImageBlobs(l layout.Path, images map[string]blobSet) error {
+	index, err := l.ImageIndex()
+	if err != nil {
+		return err
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return err
+	}
+
+	for _, desc := range indexManifest.Manifests {
+		imageRef, found := desc.Annotations["org.opencontainers.image.ref.name"]
+		if !found {
+			continue
+		}
+
+		blobs := blobSet{desc.Digest: desc.Size}
+		if desc.MediaType.IsImage() {
+			img, err := l.Image(desc.Digest)
+			if err != nil {
+				return fmt.Errorf("read %s: %w", imageRef, err)
+			}
+			manifest, err := img.Manifest()
+			if err != nil {
+				return fmt.Errorf("read %s manifest: %w", imageRef, err)
+			}
+			blobs[manifest.Config.Digest] = manifest.Config.Size
+			for _, layer := range manifest.Layers {
+				blobs[layer.Digest] = layer.Size
+			}
+		}
+		images[imageRef] = blobs
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestComputeReleaseFootprints(t *testing.T) {
+	imageLayouts := &ImageLayouts{
+		Deckhouse: createEmptyOCILayout(t),
+		Install:   createEmptyOCILayout(t),
+	}
+	shared, v160Only, v161Only := randomImage(t), randomImage(t), randomImage(t)
+	installV160, installV161 := randomImage(t), randomImage(t)
+
+	sharedRef := appendWithRef(t, imageLayouts.Deckhouse, "registry.example.com/deckhouse", shared)
+	v160Ref := appendWithRef(t, imageLayouts.Deckhouse, "registry.example.com/deckhouse", v160Only)
+	v161Ref := appendWithRef(t, imageLayouts.Deckhouse, "registry.example.com/deckhouse", v161Only)
+	require.NoError(t, imageLayouts.Install.AppendImage(installV160, layout.WithAnnotations(map[string]string{
+		"org.opencontainers.image.ref.name": "registry.example.com/deckhouse/install:v1.60.0",
+	})))
+	require.NoError(t, imageLayouts.Install.AppendImage(installV161, layout.WithAnnotations(map[string]string{
+		"org.opencontainers.image.ref.name": "registry.example.com/deckhouse/install:v1.61.0",
+	})))
+
+	const notPulledRef = "registry.example.com/deckhouse@sha256:0123456789012345678901234567890123456789012345678901234567890123"
+	footprints, err := ComputeReleaseFootprints(imageLayouts, map[string]map[string]struct{}{
+		"v1.61.0": {
+			sharedRef: {},
+			v161Ref:   {},
+			"registry.example.com/deckhouse/install:v1.61.0": {},
+			notPulledRef: {},
+		},
+		"v1.60.0": {sharedRef: {}, v160Ref: {}, "registry.example.com/deckhouse/install:v1.60.0": {}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []contexts.ReleaseFootprint{
+		{Version: "v1.60.0", Images: 3, Size: imagesSize(t, shared, v160Only, installV160)},
+		{Version: "v1.61.0", Images: 3, Size: imagesSize(t, shared, v161Only, installV161)},
+	}, footprints)
+}
+
+func appendWithRef(t *testing.T, l layout.Path, repo string, img v1.Image) string {
+	t.Helper()
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	ref := repo + "@" + digest.String()
+	require.NoError(t, l.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"org.opencontainers.image.ref.name": ref,
+	})))
+	return ref
+}
+
+func imagesSize(t *testing.T, images ...v1.Image) int64 {
+	t.Helper()
+	var total int64
+	for _, img := range images {
+		manifestSize, err := img.Size()
+		require.NoError(t, err)
+		manifest, err := img.Manifest()
+		require.NoError(t, err)
+		total += manifestSize + manifest.Config.Size
+		for _, layer := range manifest.Layers {
+			total += layer.Size
+		}
+	}
+	return total
+}
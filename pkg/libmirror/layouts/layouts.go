@@ -101,17 +101,37 @@ func CreateOCIImageLayoutsForDeckhouse(
 		}
 	}
 
+	if err = createModulesLayouts(layouts, rootFolder, modules); err != nil {
+		return nil, err
+	}
+
+	return layouts, nil
+}
+
+// CreateOCIImageLayoutsForModules creates layouts only for the given modules, for bundles without Deckhouse platform images.
+func CreateOCIImageLayoutsForModules(rootFolder string, modules []modules.Module) (*ImageLayouts, error) {
+	layouts := &ImageLayouts{
+		TagsResolver: NewTagsResolver(),
+		Modules:      map[string]ModuleImageLayout{},
+	}
+	if err := createModulesLayouts(layouts, rootFolder, modules); err != nil {
+		return nil, err
+	}
+	return layouts, nil
+}
+
+func createModulesLayouts(layouts *ImageLayouts, rootFolder string, modules []modules.Module) error {
 	for _, module := range modules {
 		path := filepath.Join(rootFolder, "modules", module.Name)
 		moduleLayout, err := CreateEmptyImageLayoutAtPath(path)
 		if err != nil {
-			return nil, fmt.Errorf("create OCI Image Layout at %s: %w", path, err)
+			return fmt.Errorf("create OCI Image Layout at %s: %w", path, err)
 		}
 
 		path = filepath.Join(rootFolder, "modules", module.Name, "release")
 		moduleReleasesLayout, err := CreateEmptyImageLayoutAtPath(path)
 		if err != nil {
-			return nil, fmt.Errorf("create OCI Image Layout at %s: %w", path, err)
+			return fmt.Errorf("create OCI Image Layout at %s: %w", path, err)
 		}
 
 		layouts.Modules[module.Name] = ModuleImageLayout{
@@ -121,8 +141,7 @@ func CreateOCIImageLayoutsForDeckhouse(
 			ReleaseImages:  map[string]struct{}{},
 		}
 	}
-
-	return layouts, nil
+	return nil
 }
 
 func CreateEmptyImageLayoutAtPath(path string) (layout.Path, error) {
@@ -224,35 +243,45 @@ func FillLayoutsWithBasicDeckhouseImages(
 	layouts.ReleaseChannelImages[mirrorCtx.DeckhouseRegistryRepo+"/release-channel:early-access"] = struct{}{}
 	layouts.ReleaseChannelImages[mirrorCtx.DeckhouseRegistryRepo+"/release-channel:stable"] = struct{}{}
 	layouts.ReleaseChannelImages[mirrorCtx.DeckhouseRegistryRepo+"/release-channel:rock-solid"] = struct{}{}
+
+	// Channel requested with --since-channel may be outside the standard set, like lts, so it should also be pulled.
+	if mirrorCtx.SinceChannel != "" {
+		layouts.DeckhouseImages[mirrorCtx.DeckhouseRegistryRepo+":"+mirrorCtx.SinceChannel] = struct{}{}
+		layouts.InstallImages[mirrorCtx.DeckhouseRegistryRepo+"/install:"+mirrorCtx.SinceChannel] = struct{}{}
+		layouts.InstallStandaloneImages[mirrorCtx.DeckhouseRegistryRepo+"/install-standalone:"+mirrorCtx.SinceChannel] = struct{}{}
+		layouts.ReleaseChannelImages[mirrorCtx.DeckhouseRegistryRepo+"/release-channel:"+mirrorCtx.SinceChannel] = struct{}{}
+	}
+}
+
+// LeaveOutSkippedComponents empties image sets of platform and standalone installers if they are not pulled,
+// so that their layouts are left empty. Vulnerability databases and modules are skipped by their own pull functions.
+func LeaveOutSkippedComponents(mirrorCtx *contexts.PullContext, layouts *ImageLayouts) {
+	if !mirrorCtx.PullsComponent(contexts.ComponentPlatform) {
+		layouts.DeckhouseImages = map[string]struct{}{}
+		layouts.InstallImages = map[string]struct{}{}
+		layouts.ReleaseChannelImages = map[string]struct{}{}
+	}
+	if !mirrorCtx.PullsComponent(contexts.ComponentStandaloneInstallers) {
+		layouts.InstallStandaloneImages = map[string]struct{}{}
+	}
 }
 
 func FindDeckhouseModulesImages(mirrorCtx *contexts.PullContext, layouts *ImageLayouts) error {
 	modulesNames := maps.Keys(layouts.Modules)
 	for _, moduleName := range modulesNames {
 		moduleData := layouts.Modules[moduleName]
-		moduleData.ReleaseImages = map[string]struct{}{
-			mirrorCtx.DeckhouseRegistryRepo + "/modules/" + moduleName + "/release:alpha":        {},
-			mirrorCtx.DeckhouseRegistryRepo + "/modules/" + moduleName + "/release:beta":         {},
-			mirrorCtx.DeckhouseRegistryRepo + "/modules/" + moduleName + "/release:early-access": {},
-			mirrorCtx.DeckhouseRegistryRepo + "/modules/" + moduleName + "/release:stable":       {},
-			mirrorCtx.DeckhouseRegistryRepo + "/modules/" + moduleName + "/release:rock-solid":   {},
-		}
-
-		channelVersions, err := releases.FetchVersionsFromModuleReleaseChannels(
-			moduleData.ReleaseImages,
-			mirrorCtx.RegistryAuth,
-			mirrorCtx.Insecure,
-			mirrorCtx.SkipTLSVerification,
-		)
-		if err != nil {
+		moduleRepo := mirrorCtx.DeckhouseRegistryRepo + "/" + mirrorCtx.ModulesSegment() + "/" + moduleName
+		// Pinned modules are pulled in pinned versions only, without release channels that may point elsewhere.
+		if pinnedVersions, pinned := mirrorCtx.PinnedModules[moduleName]; pinned {
+			moduleData.ReleaseImages = map[string]struct{}{}
+			for _, moduleVersion := range pinnedVersions {
+				moduleData.ModuleImages[moduleRepo+":"+moduleVersion] = struct{}{}
+				moduleData.ReleaseImages[moduleRepo+"/release:"+moduleVersion] = struct{}{}
+			}
+		} else if err := findModuleReleaseChannelsImages(mirrorCtx, moduleRepo, &moduleData); err != nil {
 			return fmt.Errorf("fetch versions from %q release channels: %w", moduleName, err)
 		}
 
-		for _, moduleVersion := range channelVersions {
-			moduleData.ModuleImages[mirrorCtx.DeckhouseRegistryRepo+"/modules/"+moduleName+":"+moduleVersion] = struct{}{}
-			moduleData.ReleaseImages[mirrorCtx.DeckhouseRegistryRepo+"/modules/"+moduleName+"/release:"+moduleVersion] = struct{}{}
-		}
-
 		nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&mirrorCtx.BaseContext)
 		fetchDigestsFrom := maps.Clone(moduleData.ModuleImages)
 		for imageTag := range fetchDigestsFrom {
@@ -276,7 +305,7 @@ func FindDeckhouseModulesImages(mirrorCtx *contexts.PullContext, layouts *ImageL
 
 			digests := images.ExtractDigestsFromJSONFile(imagesDigestsJSON.Bytes())
 			for _, digest := range digests {
-				moduleData.ModuleImages[mirrorCtx.DeckhouseRegistryRepo+"/modules/"+moduleName+"@"+digest] = struct{}{}
+				moduleData.ModuleImages[moduleRepo+"@"+digest] = struct{}{}
 			}
 		}
 
@@ -286,11 +315,44 @@ func FindDeckhouseModulesImages(mirrorCtx *contexts.PullContext, layouts *ImageL
 	return nil
 }
 
+// findModuleReleaseChannelsImages adds release channels of module and versions they point to into module image sets.
+func findModuleReleaseChannelsImages(mirrorCtx *contexts.PullContext, moduleRepo string, moduleData *ModuleImageLayout) error {
+	moduleData.ReleaseImages = map[string]struct{}{
+		moduleRepo + "/release:alpha":        {},
+		moduleRepo + "/release:beta":         {},
+		moduleRepo + "/release:early-access": {},
+		moduleRepo + "/release:stable":       {},
+		moduleRepo + "/release:rock-solid":   {},
+	}
+
+	channelVersions, err := releases.FetchVersionsFromModuleReleaseChannels(
+		moduleData.ReleaseImages,
+		mirrorCtx.RegistryAuth,
+		mirrorCtx.Insecure,
+		mirrorCtx.SkipTLSVerification,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, moduleVersion := range channelVersions {
+		moduleData.ModuleImages[moduleRepo+":"+moduleVersion] = struct{}{}
+		moduleData.ReleaseImages[moduleRepo+"/release:"+moduleVersion] = struct{}{}
+	}
+	return nil
+}
+
 func FindImageByTag(l layout.Path, tag string) (v1.Image, error) {
 	index, err := l.ImageIndex()
 	if err != nil {
 		return nil, err
 	}
+	return FindImageInIndexByTag(index, tag)
+}
+
+// FindImageInIndexByTag is FindImageByTag for layout index that is not stored on disk, like the one of streamed bundle.
+// Nil image is returned if there is no image with the tag.
+func FindImageInIndexByTag(index v1.ImageIndex, tag string) (v1.Image, error) {
 	indexManifest, err := index.IndexManifest()
 	if err != nil {
 		return nil, err
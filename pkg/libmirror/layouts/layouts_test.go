@@ -21,7 +21,10 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
 )
 
 func TestCreateEmptyImageLayoutAtPath(t *testing.T) {
@@ -37,3 +40,22 @@ func TestCreateEmptyImageLayoutAtPath(t *testing.T) {
 	require.FileExists(t, filepath.Join(p, "oci-layout"))
 	require.FileExists(t, filepath.Join(p, "index.json"))
 }
+
+func TestLeaveOutSkippedComponents(t *testing.T) {
+	pullCtx := &contexts.PullContext{
+		BaseContext:       contexts.BaseContext{DeckhouseRegistryRepo: "registry.example.com/deckhouse/ee"},
+		SkippedComponents: map[string]bool{contexts.ComponentPlatform: true},
+	}
+	imageLayouts := &ImageLayouts{}
+	FillLayoutsWithBasicDeckhouseImages(pullCtx, imageLayouts, []semver.Version{*semver.MustParse("v1.60.0")})
+	LeaveOutSkippedComponents(pullCtx, imageLayouts)
+
+	require.Empty(t, imageLayouts.DeckhouseImages)
+	require.Empty(t, imageLayouts.InstallImages)
+	require.Empty(t, imageLayouts.ReleaseChannelImages)
+	require.Contains(t, imageLayouts.InstallStandaloneImages, "registry.example.com/deckhouse/ee/install-standalone:v1.60.0")
+	require.True(t, pullCtx.PullsComponent(contexts.ComponentSecurity))
+
+	pullCtx.SkipModulesPull = true
+	require.False(t, pullCtx.PullsComponent(contexts.ComponentModules), "--no-modules should skip modules component")
+}
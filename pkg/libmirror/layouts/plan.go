@@ -0,0 +1,388 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/samber/lo"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
+)
+
+// PlannedImage is an image that pull would download, with total size of its manifest, config and layers.
+// Multi-platform images pulled as image indexes have sizes of their images of every pulled platform summed up.
+type PlannedImage struct {
+	// Layout is the path of OCI layout image would be pulled into relative to the bundle root, "" being the root itself.
+	Layout    string `json:"layout"`
+	Reference string `json:"reference"`
+	// Digest is the digest of image as written into layout.
+	Digest string `json:"digest"`
+	// SourceDigest is set for image indexes thinned to pulled platforms, whose digest in source registry differs.
+	SourceDigest string `json:"sourceDigest,omitempty"`
+	Size         int64  `json:"size"`
+}
+
+// DownloadPlan lists images that pull would download, as resolved from manifests in the source registry.
+// All of its methods are safe for concurrent use.
+type DownloadPlan struct {
+	rootFolder string
+
+	mu     sync.Mutex
+	images []PlannedImage
+	// Sizes of blobs by layout path, blobs shared by images of a layout are stored in it once.
+	blobs map[string]map[v1.Hash]int64
+}
+
+// NewDownloadPlan returns empty plan for layouts created under rootFolder.
+func NewDownloadPlan(rootFolder string) *DownloadPlan {
+	return &DownloadPlan{rootFolder: rootFolder, blobs: map[string]map[v1.Hash]int64{}}
+}
+
+// Source returns repository of the image in source registry and its digest there.
+func (i PlannedImage) Source() (repo, digest string) {
+	repo, _, pinned := strings.Cut(i.Reference, "@")
+	if !pinned {
+		repo, _ = splitImageRefByRepoAndTag(i.Reference)
+	}
+	if i.SourceDigest != "" {
+		return repo, i.SourceDigest
+	}
+	return repo, i.Digest
+}
+
+func (p *DownloadPlan) add(targetLayout layout.Path, ref string, digest, sourceDigest v1.Hash, manifestSize int64, blobs []v1.Descriptor) {
+	layoutPath, err := filepath.Rel(p.rootFolder, string(targetLayout))
+	if err != nil || layoutPath == "." {
+		layoutPath = ""
+	}
+	layoutPath = filepath.ToSlash(layoutPath)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	layoutBlobs, found := p.blobs[layoutPath]
+	if !found {
+		layoutBlobs = map[v1.Hash]int64{}
+		p.blobs[layoutPath] = layoutBlobs
+	}
+	layoutBlobs[digest] = manifestSize
+
+	size := manifestSize
+	for _, blob := range blobs {
+		size += blob.Size
+		layoutBlobs[blob.Digest] = blob.Size
+	}
+	image := PlannedImage{Layout: layoutPath, Reference: ref, Digest: digest.String(), Size: size}
+	if sourceDigest != digest {
+		image.SourceDigest = sourceDigest.String()
+	}
+	p.images = append(p.images, image)
+}
+
+// Images returns all planned images sorted by layout and reference.
+func (p *DownloadPlan) Images() []PlannedImage {
+	p.mu.Lock()
+	images := append([]PlannedImage(nil), p.images...)
+	p.mu.Unlock()
+
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].Layout != images[j].Layout {
+			return images[i].Layout < images[j].Layout
+		}
+		return images[i].Reference < images[j].Reference
+	})
+	return images
+}
+
+// TotalSize returns estimated size of the bundle, which is the size of distinct blobs of every layout.
+// Tar headers and metadata files add a negligible amount on top of that.
+func (p *DownloadPlan) TotalSize() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var total int64
+	for _, layoutBlobs := range p.blobs {
+		for _, size := range layoutBlobs {
+			total += size
+		}
+	}
+	return total
+}
+
+// Components of the bundle that download size is estimated for.
+const (
+	ComponentPlatform     = "platform"
+	ComponentSecurity     = "security"
+	ComponentModulePrefix = "module-"
+)
+
+// ComponentSize is the estimated download size of a bundle component.
+type ComponentSize struct {
+	Component string `json:"component"`
+	Images    int    `json:"images"`
+	Size      int64  `json:"size"`
+}
+
+// LayoutComponent returns component of the bundle that layout at layoutPath relative to the bundle root belongs to:
+// vulnerability databases, one of the modules or Deckhouse platform itself.
+func LayoutComponent(layoutPath string) string {
+	if layoutPath == "security" || strings.HasPrefix(layoutPath, "security/") {
+		return ComponentSecurity
+	}
+	if rest, found := strings.CutPrefix(layoutPath, contexts.DefaultModulesPathSuffix+"/"); found {
+		moduleName, _, _ := strings.Cut(rest, "/")
+		return ComponentModulePrefix + moduleName
+	}
+	return ComponentPlatform
+}
+
+// ComponentSizes returns estimated size of every component of the bundle: platform first, then security, then modules by name.
+func (p *DownloadPlan) ComponentSizes() []ComponentSize {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	components := map[string]*ComponentSize{}
+	component := func(layoutPath string) *ComponentSize {
+		componentName := LayoutComponent(layoutPath)
+		if _, found := components[componentName]; !found {
+			components[componentName] = &ComponentSize{Component: componentName}
+		}
+		return components[componentName]
+	}
+	for _, image := range p.images {
+		component(image.Layout).Images++
+	}
+	for layoutPath, layoutBlobs := range p.blobs {
+		c := component(layoutPath)
+		for _, size := range layoutBlobs {
+			c.Size += size
+		}
+	}
+
+	order := func(componentName string) int {
+		switch componentName {
+		case ComponentPlatform:
+			return 0
+		case ComponentSecurity:
+			return 1
+		default:
+			return 2
+		}
+	}
+	sizes := make([]ComponentSize, 0, len(components))
+	for _, c := range components {
+		sizes = append(sizes, *c)
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		if order(sizes[i].Component) != order(sizes[j].Component) {
+			return order(sizes[i].Component) < order(sizes[j].Component)
+		}
+		return sizes[i].Component < sizes[j].Component
+	})
+	return sizes
+}
+
+// PlanImageSet adds images of the set to plan as PullImageSet would pull them into targetLayout,
+// reading only their manifests and configs from the source registry.
+func PlanImageSet(
+	pullCtx *contexts.PullContext,
+	plan *DownloadPlan,
+	targetLayout layout.Path,
+	imageSet map[string]struct{},
+	opts ...func(opts *pullImageSetOptions),
+) error {
+	pullOpts := &pullImageSetOptions{stage: contexts.StagePlatform}
+	for _, o := range opts {
+		o(pullOpts)
+	}
+	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&pullCtx.BaseContext)
+	remoteOpts = append(remoteOpts, remote.WithContext(pullCtx.Run.Context()))
+
+	imageReferences := lo.Keys(imageSet)
+	sort.Strings(imageReferences)
+	for _, imageReferenceString := range imageReferences {
+		if pattern, matched := pullCtx.Exclusions.MatchReference(imageReferenceString); matched {
+			pullCtx.Logger.DebugF("%s is excluded by --exclude-ref %s", imageReferenceString, pattern)
+			continue
+		}
+
+		pullReference := imageReferenceString
+		if pullOpts.tagToDigestMapper != nil {
+			if mapping := pullOpts.tagToDigestMapper(imageReferenceString); mapping != nil {
+				imageRepo, _ := splitImageRefByRepoAndTag(imageReferenceString)
+				pullReference = imageRepo + "@" + mapping.String()
+			}
+		}
+		ref, err := name.ParseReference(pullReference, nameOpts...)
+		if err != nil {
+			return fmt.Errorf("parse image reference %q: %w", pullReference, err)
+		}
+
+		img, remoteIndex, err := fetchImage(pullCtx, ref, remoteOpts)
+		if err != nil {
+			if errorutil.IsImageNotFoundError(err) && pullOpts.allowMissingTags {
+				pullCtx.Logger.DebugF("%s is not found in registry, it would be skipped", imageReferenceString)
+				continue
+			}
+			return fmt.Errorf("read image %q metadata: %w", imageReferenceString, err)
+		}
+		if remoteIndex != nil {
+			if err = planIndex(pullCtx, plan, targetLayout, imageReferenceString, remoteIndex); err != nil {
+				return fmt.Errorf("read image index %q: %w", imageReferenceString, err)
+			}
+			continue
+		}
+
+		digest, err := img.Digest()
+		if err != nil {
+			return fmt.Errorf("read image %q digest: %w", imageReferenceString, err)
+		}
+		if pullCtx.Exclusions.MatchDigest(digest) {
+			pullCtx.Logger.DebugF("%s is excluded by --exclude-digest %s", imageReferenceString, digest)
+			continue
+		}
+		if key, value, matched, err := matchAnnotations(img, pullOpts.skipAnnotated); err != nil {
+			return fmt.Errorf("read image %q annotations: %w", imageReferenceString, err)
+		} else if matched {
+			pullCtx.Logger.DebugF("%s is annotated with %s=%s, it would be skipped", imageReferenceString, key, value)
+			continue
+		}
+
+		manifest, err := img.Manifest()
+		if err != nil {
+			return fmt.Errorf("read image %q manifest: %w", imageReferenceString, err)
+		}
+		manifestSize, err := img.Size()
+		if err != nil {
+			return fmt.Errorf("read image %q manifest size: %w", imageReferenceString, err)
+		}
+		plan.add(targetLayout, imageReferenceString, digest, digest, manifestSize, append([]v1.Descriptor{manifest.Config}, manifest.Layers...))
+	}
+	return nil
+}
+
+// planIndex adds multi-platform image index to plan as pullIndex would write it, thinned to the requested platforms.
+// Manifests, configs and layers of its images count as its blobs.
+func planIndex(
+	pullCtx *contexts.PullContext,
+	plan *DownloadPlan,
+	targetLayout layout.Path,
+	imageReferenceString string,
+	remoteIndex *remote.Descriptor,
+) error {
+	if pullCtx.Exclusions.MatchDigest(remoteIndex.Digest) {
+		pullCtx.Logger.DebugF("%s is excluded by --exclude-digest %s", imageReferenceString, remoteIndex.Digest)
+		return nil
+	}
+	index, err := layoutIndex(pullCtx, imageReferenceString, remoteIndex)
+	if err != nil {
+		return err
+	}
+	digest, err := index.Digest()
+	if err != nil {
+		return err
+	}
+	manifestSize, err := index.Size()
+	if err != nil {
+		return err
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return err
+	}
+
+	blobs := make([]v1.Descriptor, 0)
+	for _, desc := range indexManifest.Manifests {
+		blobs = append(blobs, desc)
+		if !desc.MediaType.IsImage() {
+			continue
+		}
+		img, err := index.Image(desc.Digest)
+		if err != nil {
+			return err
+		}
+		manifest, err := img.Manifest()
+		if err != nil {
+			return fmt.Errorf("read manifest of %s: %w", desc.Digest, err)
+		}
+		blobs = append(blobs, manifest.Config)
+		blobs = append(blobs, manifest.Layers...)
+	}
+	plan.add(targetLayout, imageReferenceString, digest, remoteIndex.Digest, manifestSize, blobs)
+	return nil
+}
+
+// FetchPlannedImage reads image of the plan from source registry the way pull would write it into layout,
+// either v1.Image or v1.ImageIndex thinned to the requested platforms. Its source signature is verified if requested.
+// Layers are only read from source registry when image is written somewhere.
+func FetchPlannedImage(ctx context.Context, pullCtx *contexts.PullContext, image PlannedImage, remoteOpts ...remote.Option) (remote.Taggable, error) {
+	imageRepo, sourceDigest := image.Source()
+	digest, err := v1.NewHash(sourceDigest)
+	if err != nil {
+		return nil, fmt.Errorf("parse image digest: %w", err)
+	}
+	nameOpts, _ := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&pullCtx.BaseContext)
+	ref, err := name.NewDigest(imageRepo+"@"+sourceDigest, nameOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("parse image reference: %w", err)
+	}
+	remoteOpts = append(remoteOpts, remote.WithContext(ctx))
+
+	img, remoteIndex, err := fetchImage(pullCtx, ref, remoteOpts)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifySourceSignature(ctx, pullCtx, ref.Context(), digest, remoteOpts); err != nil {
+		return nil, err
+	}
+	if remoteIndex != nil {
+		return layoutIndex(pullCtx, image.Reference, remoteIndex)
+	}
+	return img, nil
+}
+
+// PlanTrivyVulnerabilityDatabasesImages adds vulnerability databases to plan as PullTrivyVulnerabilityDatabasesImages would pull them.
+func PlanTrivyVulnerabilityDatabasesImages(pullCtx *contexts.PullContext, plan *DownloadPlan, layouts *ImageLayouts) error {
+	if !pullCtx.PullsComponent(contexts.ComponentSecurity) {
+		return nil
+	}
+	for imageRef, dbImageLayout := range securityDatabaseImages(pullCtx.DeckhouseRegistryRepo, layouts) {
+		if err := PlanImageSet(
+			pullCtx,
+			plan,
+			dbImageLayout,
+			map[string]struct{}{imageRef: {}},
+			WithAllowMissingTags(true), // SE edition does not contain images for trivy
+			WithStage(contexts.StageSecurity),
+		); err != nil {
+			return fmt.Errorf("plan vulnerability database: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestPlanImageSet(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	deckhouseRepo := strings.TrimPrefix(server.URL, "http://") + "/deckhouse/ee"
+
+	img := randomImage(t)
+	excludedImg := randomImage(t)
+	for ref, image := range map[string]v1.Image{
+		deckhouseRepo + ":stable":   img,
+		deckhouseRepo + ":v1.60.0":  img,
+		deckhouseRepo + ":excluded": excludedImg,
+	} {
+		parsedRef, err := name.ParseReference(ref, name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(parsedRef, image))
+	}
+	excludedDigest, err := excludedImg.Digest()
+	require.NoError(t, err)
+
+	exclusions, err := contexts.NewImageExclusions(nil, []string{excludedDigest.String()})
+	require.NoError(t, err)
+	pullCtx := &contexts.PullContext{
+		BaseContext: contexts.BaseContext{
+			Logger:                testLogger,
+			Insecure:              true,
+			DeckhouseRegistryRepo: deckhouseRepo,
+		},
+		Exclusions: exclusions,
+	}
+
+	root := t.TempDir()
+	installLayout, err := CreateEmptyImageLayoutAtPath(filepath.Join(root, "install"))
+	require.NoError(t, err)
+	plan := NewDownloadPlan(root)
+	err = PlanImageSet(pullCtx, plan, installLayout, map[string]struct{}{
+		deckhouseRepo + ":stable":   {},
+		deckhouseRepo + ":v1.60.0":  {},
+		deckhouseRepo + ":excluded": {},
+		deckhouseRepo + ":missing":  {},
+	}, WithAllowMissingTags(true))
+	require.NoError(t, err)
+
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	manifestSize, err := img.Size()
+	require.NoError(t, err)
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+	imageSize := manifestSize + manifest.Config.Size + manifest.Layers[0].Size
+
+	require.Equal(t, []PlannedImage{
+		{Layout: "install", Reference: deckhouseRepo + ":stable", Digest: digest.String(), Size: imageSize},
+		{Layout: "install", Reference: deckhouseRepo + ":v1.60.0", Digest: digest.String(), Size: imageSize},
+	}, plan.Images(), "excluded and missing images must not be planned")
+	require.Equal(t, imageSize, plan.TotalSize(), "blobs of the same image must be counted once per layout")
+
+	entries, err := os.ReadDir(filepath.Join(root, "install", "blobs"))
+	require.NoError(t, err)
+	require.Empty(t, entries, "nothing must be downloaded")
+}
+
+func TestPlanImageSetThinsImageIndexes(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	deckhouseRepo := strings.TrimPrefix(server.URL, "http://") + "/deckhouse/ee"
+
+	amd64, arm64 := randomImage(t), randomImage(t)
+	multiPlatform := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+	)
+	ref, err := name.ParseReference(deckhouseRepo+"/install:v1.60.0", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, multiPlatform))
+
+	pullCtx := &contexts.PullContext{
+		BaseContext: contexts.BaseContext{Logger: testLogger, Insecure: true, DeckhouseRegistryRepo: deckhouseRepo},
+		Platforms:   []v1.Platform{{OS: "linux", Architecture: "amd64"}},
+	}
+	root := t.TempDir()
+	installLayout, err := CreateEmptyImageLayoutAtPath(filepath.Join(root, "install"))
+	require.NoError(t, err)
+	plan := NewDownloadPlan(root)
+	require.NoError(t, PlanImageSet(pullCtx, plan, installLayout, map[string]struct{}{deckhouseRepo + "/install:v1.60.0": {}}))
+
+	thinned, err := thinIndex(multiPlatform, pullCtx.Platforms)
+	require.NoError(t, err)
+	thinnedDigest, err := thinned.Digest()
+	require.NoError(t, err)
+	sourceDigest, err := multiPlatform.Digest()
+	require.NoError(t, err)
+	indexSize, err := thinned.Size()
+	require.NoError(t, err)
+	amd64Size, err := amd64.Size()
+	require.NoError(t, err)
+	amd64Manifest, err := amd64.Manifest()
+	require.NoError(t, err)
+
+	require.Equal(t, []PlannedImage{{
+		Layout:       "install",
+		Reference:    deckhouseRepo + "/install:v1.60.0",
+		Digest:       thinnedDigest.String(),
+		SourceDigest: sourceDigest.String(),
+		Size:         indexSize + amd64Size + amd64Manifest.Config.Size + amd64Manifest.Layers[0].Size,
+	}}, plan.Images(), "index must be planned thinned to pulled platforms")
+
+	fetched, err := FetchPlannedImage(context.Background(), pullCtx, plan.Images()[0])
+	require.NoError(t, err)
+	fetchedDigest, err := fetched.(v1.ImageIndex).Digest()
+	require.NoError(t, err)
+	require.Equal(t, thinnedDigest, fetchedDigest)
+}
+
+func TestDownloadPlanComponentSizes(t *testing.T) {
+	root := t.TempDir()
+	plan := NewDownloadPlan(root)
+	blob := func(hex string, size int64) v1.Descriptor {
+		return v1.Descriptor{Digest: v1.Hash{Algorithm: "sha256", Hex: strings.Repeat(hex, 64)}, Size: size}
+	}
+
+	plan.add(layout.Path(root), "dh:v1.60.0", blob("1", 0).Digest, blob("1", 0).Digest, 10, []v1.Descriptor{blob("a", 100)})
+	plan.add(layout.Path(filepath.Join(root, "install")), "dh/install:v1.60.0", blob("2", 0).Digest, blob("2", 0).Digest, 10, []v1.Descriptor{blob("a", 100)})
+	plan.add(layout.Path(filepath.Join(root, "security", "trivy-db")), "dh/security/trivy-db:2", blob("3", 0).Digest, blob("3", 0).Digest, 10, []v1.Descriptor{blob("b", 50)})
+	plan.add(layout.Path(filepath.Join(root, "modules", "console")), "dh/modules/console:v1.0.0", blob("4", 0).Digest, blob("4", 0).Digest, 10, []v1.Descriptor{blob("c", 20)})
+	plan.add(layout.Path(filepath.Join(root, "modules", "console", "release")), "dh/modules/console/release:v1.0.0", blob("5", 0).Digest, blob("5", 0).Digest, 10, []v1.Descriptor{blob("d", 5)})
+	plan.add(layout.Path(filepath.Join(root, "modules", "code")), "dh/modules/code:v1.0.0", blob("6", 0).Digest, blob("6", 0).Digest, 10, []v1.Descriptor{blob("e", 1)})
+
+	require.Equal(t, []ComponentSize{
+		{Component: ComponentPlatform, Images: 2, Size: 220},
+		{Component: ComponentSecurity, Images: 1, Size: 60},
+		{Component: "module-code", Images: 1, Size: 11},
+		{Component: "module-console", Images: 2, Size: 45},
+	}, plan.ComponentSizes(), "blobs shared by different layouts must be counted in every one of them")
+	require.Equal(t, int64(336), plan.TotalSize())
+}
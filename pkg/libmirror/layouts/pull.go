@@ -18,21 +18,33 @@ package layouts
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"path"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/go-multierror"
+	"github.com/samber/lo"
+	"github.com/samber/lo/parallel"
 
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/retry"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/retry/task"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
 )
 
 func PullInstallers(mirrorCtx *contexts.PullContext, layouts *ImageLayouts) error {
@@ -42,6 +54,7 @@ func PullInstallers(mirrorCtx *contexts.PullContext, layouts *ImageLayouts) erro
 		layouts.Install,
 		layouts.InstallImages,
 		WithTagToDigestMapper(layouts.TagsResolver.GetTagDigest),
+		WithStage(contexts.StagePlatform),
 	); err != nil {
 		return err
 	}
@@ -57,6 +70,7 @@ func PullStandaloneInstallers(mirrorCtx *contexts.PullContext, layouts *ImageLay
 		layouts.InstallStandaloneImages,
 		WithTagToDigestMapper(layouts.TagsResolver.GetTagDigest),
 		WithAllowMissingTags(true),
+		WithStage(contexts.StagePlatform),
 	); err != nil {
 		return err
 	}
@@ -72,6 +86,7 @@ func PullDeckhouseReleaseChannels(mirrorCtx *contexts.PullContext, layouts *Imag
 		layouts.ReleaseChannelImages,
 		WithTagToDigestMapper(layouts.TagsResolver.GetTagDigest),
 		WithAllowMissingTags(mirrorCtx.SpecificVersion != nil),
+		WithStage(contexts.StagePlatform),
 	); err != nil {
 		return err
 	}
@@ -86,6 +101,8 @@ func PullDeckhouseImages(mirrorCtx *contexts.PullContext, layouts *ImageLayouts)
 		layouts.Deckhouse,
 		layouts.DeckhouseImages,
 		WithTagToDigestMapper(layouts.TagsResolver.GetTagDigest),
+		WithStage(contexts.StagePlatform),
+		WithSkipAnnotated(mirrorCtx.SkipAnnotated),
 	); err != nil {
 		return err
 	}
@@ -101,6 +118,8 @@ func PullModules(mirrorCtx *contexts.PullContext, layouts *ImageLayouts) error {
 			moduleData.ModuleLayout,
 			moduleData.ModuleImages,
 			WithTagToDigestMapper(layouts.TagsResolver.GetTagDigest),
+			WithStage(contexts.StageModules),
+			WithSkipAnnotated(mirrorCtx.SkipAnnotated),
 		); err != nil {
 			return fmt.Errorf("pull %q module: %w", moduleName, err)
 		}
@@ -110,6 +129,7 @@ func PullModules(mirrorCtx *contexts.PullContext, layouts *ImageLayouts) error {
 			moduleData.ReleaseImages,
 			WithTagToDigestMapper(layouts.TagsResolver.GetTagDigest),
 			WithAllowMissingTags(true),
+			WithStage(contexts.StageModules),
 		); err != nil {
 			return fmt.Errorf("pull %q module release information: %w", moduleName, err)
 		}
@@ -122,16 +142,12 @@ func PullTrivyVulnerabilityDatabasesImages(
 	pullCtx *contexts.PullContext,
 	layouts *ImageLayouts,
 ) error {
-	nameOpts, _ := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&pullCtx.BaseContext)
-
-	dbImages := map[layout.Path]string{
-		layouts.TrivyDB:     path.Join(pullCtx.DeckhouseRegistryRepo, "security", "trivy-db:2"),
-		layouts.TrivyBDU:    path.Join(pullCtx.DeckhouseRegistryRepo, "security", "trivy-bdu:1"),
-		layouts.TrivyJavaDB: path.Join(pullCtx.DeckhouseRegistryRepo, "security", "trivy-java-db:1"),
-		layouts.TrivyChecks: path.Join(pullCtx.DeckhouseRegistryRepo, "security", "trivy-checks:0"),
+	if !pullCtx.PullsComponent(contexts.ComponentSecurity) {
+		return nil
 	}
+	nameOpts, _ := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&pullCtx.BaseContext)
 
-	for dbImageLayout, imageRef := range dbImages {
+	for imageRef, dbImageLayout := range securityDatabaseImages(pullCtx.DeckhouseRegistryRepo, layouts) {
 		ref, err := name.ParseReference(imageRef, nameOpts...)
 		if err != nil {
 			return fmt.Errorf("parse trivy-db reference %q: %w", imageRef, err)
@@ -143,6 +159,7 @@ func PullTrivyVulnerabilityDatabasesImages(
 			map[string]struct{}{ref.String(): {}},
 			WithTagToDigestMapper(NopTagToDigestMappingFunc),
 			WithAllowMissingTags(true), // SE edition does not contain images for trivy
+			WithStage(contexts.StageSecurity),
 		); err != nil {
 			return fmt.Errorf("pull vulnerability database: %w", err)
 		}
@@ -157,62 +174,444 @@ func PullImageSet(
 	imageSet map[string]struct{},
 	opts ...func(opts *pullImageSetOptions),
 ) error {
-	pullOpts := &pullImageSetOptions{}
+	pullOpts := &pullImageSetOptions{stage: contexts.StagePlatform}
 	for _, o := range opts {
 		o(pullOpts)
 	}
 
-	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptions(pullCtx.RegistryAuth, pullCtx.Insecure, pullCtx.SkipTLSVerification)
+	puller := &imageSetPuller{
+		pullCtx:      pullCtx,
+		targetLayout: targetLayout,
+		opts:         pullOpts,
+		totalCount:   len(imageSet),
+	}
+	puller.nameOpts, puller.remoteOpts = auth.MakeRemoteRegistryRequestOptions(pullCtx.RegistryAuth, pullCtx.Insecure, pullCtx.SkipTLSVerification)
 
-	pullCount, totalCount := 1, len(imageSet)
-	for imageReferenceString := range imageSet {
-		imageRepo, imageTag := splitImageRefByRepoAndTag(imageReferenceString)
+	imageReferences := lo.Keys(imageSet)
+	sort.Strings(imageReferences)
+	pullCtx.Run.AddTotal(pullOpts.stage, len(imageReferences))
+	if pullCtx.Concurrency <= 1 {
+		for _, imageReferenceString := range imageReferences {
+			if err := puller.pull(imageReferenceString); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 
-		// If we already know the digest of the tagged image, we should pull it by this digest instead of pulling by tag
-		// to avoid race-conditions between mirroring and releasing new builds on release channels.
-		pullReference := imageReferenceString
-		if pullOpts.tagToDigestMapper != nil {
-			if mapping := pullOpts.tagToDigestMapper(imageReferenceString); mapping != nil {
-				pullReference = imageRepo + "@" + mapping.String()
+	for _, batch := range lo.Chunk(imageReferences, pullCtx.Concurrency) {
+		errMu := &sync.Mutex{}
+		merr := &multierror.Error{}
+		parallel.ForEach(batch, func(imageReferenceString string, _ int) {
+			if err := puller.pull(imageReferenceString); err != nil {
+				errMu.Lock()
+				defer errMu.Unlock()
+				merr = multierror.Append(merr, err)
 			}
+		})
+		if err := merr.ErrorOrNil(); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		ref, err := name.ParseReference(pullReference, nameOpts...)
-		if err != nil {
-			return fmt.Errorf("parse image reference %q: %w", pullReference, err)
+// imageSetPuller pulls images of a single image set into target layout, possibly several at once.
+type imageSetPuller struct {
+	pullCtx      *contexts.PullContext
+	targetLayout layout.Path
+	opts         *pullImageSetOptions
+	nameOpts     []name.Option
+	remoteOpts   []remote.Option
+
+	totalCount int
+	pullCount  atomic.Int64
+
+	// indexMu guards index.json of target layout that is rewritten every time an image is appended to it.
+	indexMu sync.Mutex
+}
+
+func (p *imageSetPuller) pull(imageReferenceString string) error {
+	pullCtx, pullOpts := p.pullCtx, p.opts
+	pullCount := p.pullCount.Add(1)
+	imageRepo, imageTag := splitImageRefByRepoAndTag(imageReferenceString)
+
+	if pattern, matched := pullCtx.Exclusions.MatchReference(imageReferenceString); matched {
+		p.exclude(imageReferenceString, "", "--exclude-ref "+pattern)
+		pullCtx.Run.Advance(pullOpts.stage, 1)
+		return nil
+	}
+
+	// If we already know the digest of the tagged image, we should pull it by this digest instead of pulling by tag
+	// to avoid race-conditions between mirroring and releasing new builds on release channels.
+	pullReference := imageReferenceString
+	if pullOpts.tagToDigestMapper != nil {
+		if mapping := pullOpts.tagToDigestMapper(imageReferenceString); mapping != nil {
+			pullReference = imageRepo + "@" + mapping.String()
 		}
+	}
 
-		err = retry.RunTask(
-			pullCtx.Logger,
-			fmt.Sprintf("[%d / %d] Pulling %s ", pullCount, totalCount, imageReferenceString),
-			task.WithConstantRetries(5, 10*time.Second, func(ctx context.Context) error {
-				img, err := remote.Image(ref, append(remoteOpts, remote.WithContext(ctx))...)
-				if err != nil {
-					if errorutil.IsImageNotFoundError(err) && pullOpts.allowMissingTags {
-						pullCtx.Logger.WarnLn("⚠️ Not found in registry, skipping pull")
-						return nil
-					}
+	ref, err := name.ParseReference(pullReference, p.nameOpts...)
+	if err != nil {
+		return fmt.Errorf("parse image reference %q: %w", pullReference, err)
+	}
+
+	resumed, err := p.resumeImage(imageReferenceString, ref)
+	if err != nil {
+		return fmt.Errorf("resume pull of %q: %w", imageReferenceString, err)
+	}
+	if resumed {
+		pullCtx.Logger.DebugF("[%d / %d] %s was pulled before interruption, skipping", pullCount, p.totalCount, imageReferenceString)
+		// Cosign artifacts are attached to the digest in the source registry, which thinned indexes do not have.
+		image, _ := pullCtx.Checkpoint.Lookup(string(p.targetLayout), imageReferenceString)
+		if err = p.pullCosignArtifacts(imageRepo, image.SourceDigest); err != nil {
+			return fmt.Errorf("pull cosign artifacts of %q: %w", imageReferenceString, err)
+		}
+		pullCtx.Run.Advance(pullOpts.stage, 1)
+		return nil
+	}
 
-					return fmt.Errorf("pull image metadata: %w", err)
+	// pulledDigest is left nil if image was skipped or excluded.
+	var pulledDigest *v1.Hash
+	err = retry.RunTaskWithContext(
+		pullCtx.Run.Context(),
+		pullCtx.Logger,
+		fmt.Sprintf("[%d / %d] Pulling %s ", pullCount, p.totalCount, imageReferenceString),
+		task.WithConstantRetries(5, 10*time.Second, func(ctx context.Context) error {
+			pullStart := time.Now()
+			timingTransport := newPullTimingTransport(auth.MakeTransport(pullCtx.SkipTLSVerification))
+			remoteOpts := append(p.remoteOpts, remote.WithContext(ctx), remote.WithTransport(timingTransport))
+			img, remoteIndex, err := fetchImage(pullCtx, ref, remoteOpts)
+			if err != nil {
+				if errorutil.IsImageNotFoundError(err) && pullOpts.allowMissingTags {
+					pullCtx.Logger.WarnLn("⚠️ Not found in registry, skipping pull")
+					return nil
 				}
 
-				err = targetLayout.AppendImage(img,
-					layout.WithPlatform(v1.Platform{Architecture: "amd64", OS: "linux"}),
-					layout.WithAnnotations(map[string]string{
-						"org.opencontainers.image.ref.name": imageReferenceString,
-						"io.deckhouse.image.short_tag":      imageTag,
-					}),
-				)
-				if err != nil {
-					return fmt.Errorf("write image to index: %w", err)
+				return fmt.Errorf("pull image metadata: %w", err)
+			}
+			if remoteIndex != nil {
+				if pulledDigest, err = p.pullIndex(ctx, imageReferenceString, ref, remoteIndex, remoteOpts); err != nil || pulledDigest == nil {
+					return err
 				}
+				timing := timingTransport.imageTiming(pullOpts.stage, imageReferenceString, time.Since(pullStart))
+				pullCtx.Run.RecordImageTiming(timing)
+				logImageTiming(pullCtx.Logger, timing)
+				return nil
+			}
 
+			digest, err := img.Digest()
+			if err != nil {
+				return fmt.Errorf("read image digest: %w", err)
+			}
+			if pullCtx.Exclusions.MatchDigest(digest) {
+				p.exclude(imageReferenceString, digest.String(), "--exclude-digest "+digest.String())
 				return nil
-			}))
+			}
+
+			if key, value, matched, err := matchAnnotations(img, pullOpts.skipAnnotated); err != nil {
+				return fmt.Errorf("read image annotations: %w", err)
+			} else if matched {
+				pullCtx.Logger.InfoF("Skipping %s as it is annotated with %s=%s", imageReferenceString, key, value)
+				pullCtx.Run.RecordImageSkipped(pullOpts.stage, imageReferenceString)
+				return nil
+			}
+
+			if err = verifySourceSignature(ctx, pullCtx, ref.Context(), digest, p.remoteOpts); err != nil {
+				return err
+			}
+
+			if err = p.targetLayout.WriteImage(img); err != nil {
+				return fmt.Errorf("write image blobs: %w", err)
+			}
+			desc, err := partial.Descriptor(img)
+			if err != nil {
+				return fmt.Errorf("read image descriptor: %w", err)
+			}
+			desc.Platform = &v1.Platform{Architecture: "amd64", OS: "linux"}
+			desc.Annotations = map[string]string{
+				"org.opencontainers.image.ref.name": imageReferenceString,
+				"io.deckhouse.image.short_tag":      imageTag,
+			}
+			if err = p.appendToIndex(imageReferenceString, *desc, digest); err != nil {
+				return err
+			}
+			pulledDigest = &digest
+
+			pullCtx.Run.RecordImagePulled(pullOpts.stage, imageReferenceString)
+			timing := timingTransport.imageTiming(pullOpts.stage, imageReferenceString, time.Since(pullStart))
+			pullCtx.Run.RecordImageTiming(timing)
+			logImageTiming(pullCtx.Logger, timing)
+			return nil
+		}))
+	if err != nil {
+		return fmt.Errorf("pull image %q: %w", imageReferenceString, err)
+	}
+	if pulledDigest != nil {
+		if err = p.pullCosignArtifacts(imageRepo, *pulledDigest); err != nil {
+			return fmt.Errorf("pull cosign artifacts of %q: %w", imageReferenceString, err)
+		}
+	}
+	pullCtx.Run.Advance(pullOpts.stage, 1)
+	return nil
+}
+
+// fetchImage pulls image metadata. If multi-platform indexes are pulled as indexes and ref points to one,
+// its descriptor is returned instead of the image, otherwise indexes are resolved to their linux/amd64 images.
+func fetchImage(pullCtx *contexts.PullContext, ref name.Reference, remoteOpts []remote.Option) (v1.Image, *remote.Descriptor, error) {
+	if !pullCtx.PullsImageIndexes() {
+		img, err := remote.Image(ref, remoteOpts...)
+		return img, nil, err
+	}
+
+	remoteDesc, err := remote.Get(ref, remoteOpts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if remoteDesc.MediaType.IsIndex() {
+		return nil, remoteDesc, nil
+	}
+	img, err := remoteDesc.Image()
+	return img, nil, err
+}
+
+// pullIndex writes multi-platform image index into target layout, thinned to the requested platforms.
+// Digest of the index in the source registry is returned, or nil if index was excluded.
+func (p *imageSetPuller) pullIndex(
+	ctx context.Context,
+	imageReferenceString string,
+	ref name.Reference,
+	remoteIndex *remote.Descriptor,
+	remoteOpts []remote.Option,
+) (*v1.Hash, error) {
+	pullCtx := p.pullCtx
+	if pullCtx.Exclusions.MatchDigest(remoteIndex.Digest) {
+		p.exclude(imageReferenceString, remoteIndex.Digest.String(), "--exclude-digest "+remoteIndex.Digest.String())
+		return nil, nil
+	}
+	if err := verifySourceSignature(ctx, pullCtx, ref.Context(), remoteIndex.Digest, remoteOpts); err != nil {
+		return nil, err
+	}
+	index, err := layoutIndex(pullCtx, imageReferenceString, remoteIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.targetLayout.WriteIndex(index); err != nil {
+		return nil, fmt.Errorf("write image index blobs: %w", err)
+	}
+	desc, err := partial.Descriptor(index)
+	if err != nil {
+		return nil, fmt.Errorf("read image index descriptor: %w", err)
+	}
+	_, imageTag := splitImageRefByRepoAndTag(imageReferenceString)
+	desc.Annotations = map[string]string{
+		"org.opencontainers.image.ref.name": imageReferenceString,
+		"io.deckhouse.image.short_tag":      imageTag,
+	}
+	if err = p.appendToIndex(imageReferenceString, *desc, remoteIndex.Digest); err != nil {
+		return nil, err
+	}
+	pullCtx.Run.RecordImagePulled(p.opts.stage, imageReferenceString)
+
+	// Cosign artifacts of the index are attached to its digest in the source registry, not to the digest of thinned index.
+	return &remoteIndex.Digest, nil
+}
+
+// layoutIndex reads multi-platform image index the way it is written into layout: thinned to the requested platforms,
+// unless all of them are pulled or index is referenced by digest, as thinning would change the digest it is referenced by.
+func layoutIndex(pullCtx *contexts.PullContext, imageReferenceString string, remoteIndex *remote.Descriptor) (v1.ImageIndex, error) {
+	index, err := remoteIndex.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("read image index: %w", err)
+	}
+	if pullCtx.AllPlatforms || strings.Contains(imageReferenceString, "@") {
+		return index, nil
+	}
+	return thinIndex(index, pullCtx.Platforms)
+}
+
+// thinIndex removes manifests of platforms other than the given ones from image index.
+func thinIndex(index v1.ImageIndex, platforms []v1.Platform) (v1.ImageIndex, error) {
+	thinned := mutate.RemoveManifests(index, func(desc v1.Descriptor) bool {
+		return desc.Platform == nil || !slices.ContainsFunc(platforms, func(platform v1.Platform) bool {
+			return desc.Platform.Satisfies(platform)
+		})
+	})
+
+	indexManifest, err := thinned.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("read image index manifest: %w", err)
+	}
+	if len(indexManifest.Manifests) == 0 {
+		return nil, retry.Permanent(errors.New("image index has no manifests for requested platforms"))
+	}
+	return thinned, nil
+}
+
+// pullCosignArtifacts pulls signatures, attestations and SBOMs that cosign attached to image with digest into target layout
+// under the same tags they have in imageRepo, if pull was made with --include-cosign. Images may have none or only some of them.
+func (p *imageSetPuller) pullCosignArtifacts(imageRepo string, digest v1.Hash) error {
+	if !p.pullCtx.IncludeCosign {
+		return nil
+	}
+
+	for _, tag := range signature.CosignArtifactTags(digest) {
+		artifactReference := imageRepo + ":" + tag
+		ref, err := name.ParseReference(artifactReference, p.nameOpts...)
 		if err != nil {
-			return fmt.Errorf("pull image %q: %w", imageReferenceString, err)
+			return fmt.Errorf("parse image reference %q: %w", artifactReference, err)
 		}
-		pullCount++
+
+		if image, found := p.checkpointedImage(artifactReference, ref); found {
+			if err = p.appendResumed(image); err != nil {
+				return fmt.Errorf("resume pull of %q: %w", artifactReference, err)
+			}
+			continue
+		}
+
+		img, err := remote.Image(ref, append(p.remoteOpts, remote.WithContext(p.pullCtx.Run.Context()))...)
+		if err != nil {
+			if errorutil.IsImageNotFoundError(err) {
+				continue
+			}
+			return fmt.Errorf("pull %q: %w", artifactReference, err)
+		}
+		if err = p.targetLayout.WriteImage(img); err != nil {
+			return fmt.Errorf("write %q blobs: %w", artifactReference, err)
+		}
+		desc, err := partial.Descriptor(img)
+		if err != nil {
+			return fmt.Errorf("read %q descriptor: %w", artifactReference, err)
+		}
+		desc.Annotations = map[string]string{
+			"org.opencontainers.image.ref.name": artifactReference,
+			"io.deckhouse.image.short_tag":      tag,
+		}
+		if err = p.appendToIndex(artifactReference, *desc, desc.Digest); err != nil {
+			return err
+		}
+		p.pullCtx.Logger.DebugF("Pulled %s", artifactReference)
+	}
+	return nil
+}
+
+// exclude records image that was left out of the pull by one of the exclusion rules.
+func (p *imageSetPuller) exclude(imageReferenceString, digest, rule string) {
+	layoutPath, err := filepath.Rel(p.pullCtx.UnpackedImagesPath, string(p.targetLayout))
+	if err != nil || layoutPath == "." {
+		layoutPath = ""
+	}
+	p.pullCtx.Logger.InfoF("Skipping %s as it is excluded by %s", imageReferenceString, rule)
+	p.pullCtx.Exclusions.Record(contexts.ExcludedImage{
+		Reference: imageReferenceString,
+		Layout:    filepath.ToSlash(layoutPath),
+		Digest:    digest,
+		Rule:      rule,
+	})
+	p.pullCtx.Run.RecordImageSkipped(p.opts.stage, imageReferenceString)
+}
+
+// appendToIndex adds image with blobs already written to target layout into its index and records it in checkpoint
+// along with sourceDigest, the digest of image in the source registry.
+func (p *imageSetPuller) appendToIndex(imageReferenceString string, desc v1.Descriptor, sourceDigest v1.Hash) error {
+	p.indexMu.Lock()
+	defer p.indexMu.Unlock()
+
+	if err := p.targetLayout.AppendDescriptor(desc); err != nil {
+		return fmt.Errorf("write image to index: %w", err)
+	}
+	return p.pullCtx.Checkpoint.Record(string(p.targetLayout), imageReferenceString, contexts.CheckpointedImage{
+		Descriptor:   desc,
+		SourceDigest: sourceDigest,
+	})
+}
+
+// resumeImage puts image pulled by previous interrupted run back into layout index.
+// Exclusion rules and source signature verification are applied to checkpointed image as they would be to a pulled one,
+// images that are excluded or skipped now are not resumed and are left for the regular pull to handle.
+func (p *imageSetPuller) resumeImage(imageReferenceString string, ref name.Reference) (bool, error) {
+	pullCtx := p.pullCtx
+	image, found := p.checkpointedImage(imageReferenceString, ref)
+	if !found {
+		return false, nil
+	}
+	if pullCtx.Exclusions.MatchDigest(image.SourceDigest) {
+		return false, nil
+	}
+
+	if len(p.opts.skipAnnotated) > 0 && image.Descriptor.MediaType.IsImage() {
+		img, err := p.targetLayout.Image(image.Descriptor.Digest)
+		if err != nil {
+			return false, nil
+		}
+		if _, _, matched, err := matchAnnotations(img, p.opts.skipAnnotated); err != nil || matched {
+			return false, nil
+		}
+	}
+
+	if err := verifySourceSignature(pullCtx.Run.Context(), pullCtx, ref.Context(), image.SourceDigest, p.remoteOpts); err != nil {
+		return false, err
+	}
+
+	if err := p.appendResumed(image); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// checkpointedImage looks up image pulled by previous interrupted run in checkpoint. Image is only returned
+// if its manifest is still in layout blobs and ref points to the same digest in source registry,
+// tags are resolved again with HEAD request for that as they might have been moved between runs.
+func (p *imageSetPuller) checkpointedImage(imageReferenceString string, ref name.Reference) (contexts.CheckpointedImage, bool) {
+	image, found := p.pullCtx.Checkpoint.Lookup(string(p.targetLayout), imageReferenceString)
+	if !found {
+		return contexts.CheckpointedImage{}, false
+	}
+	if _, err := p.targetLayout.Bytes(image.Descriptor.Digest); err != nil {
+		return contexts.CheckpointedImage{}, false
+	}
+
+	sourceDigest, err := p.sourceDigest(ref)
+	if err != nil {
+		p.pullCtx.Logger.DebugF("Resolve %s to resume its pull: %v", imageReferenceString, err)
+		return contexts.CheckpointedImage{}, false
+	}
+	if sourceDigest != image.SourceDigest {
+		return contexts.CheckpointedImage{}, false
+	}
+	return image, true
+}
+
+// sourceDigest resolves ref to digest of image it would be pulled as, see fetch.
+func (p *imageSetPuller) sourceDigest(ref name.Reference) (v1.Hash, error) {
+	if digest, pinned := ref.(name.Digest); pinned {
+		return v1.NewHash(digest.DigestStr())
+	}
+
+	remoteOpts := append(p.remoteOpts, remote.WithContext(p.pullCtx.Run.Context()))
+	desc, err := remote.Head(ref, remoteOpts...)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	if !desc.MediaType.IsIndex() || p.pullCtx.PullsImageIndexes() {
+		return desc.Digest, nil
+	}
+	// Indexes are pulled as their linux/amd64 images then, resolving which takes reading the index
+	img, err := remote.Image(ref, remoteOpts...)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	return img.Digest()
+}
+
+// appendResumed adds checkpointed image back into layout index.
+func (p *imageSetPuller) appendResumed(image contexts.CheckpointedImage) error {
+	p.indexMu.Lock()
+	defer p.indexMu.Unlock()
+
+	if err := p.targetLayout.AppendDescriptor(image.Descriptor); err != nil {
+		return fmt.Errorf("write image to index: %w", err)
 	}
 	return nil
 }
@@ -227,6 +626,49 @@ func splitImageRefByRepoAndTag(imageReferenceString string) (repo, tag string) {
 type pullImageSetOptions struct {
 	tagToDigestMapper TagToDigestMappingFunc
 	allowMissingTags  bool
+	stage             string
+	skipAnnotated     map[string]string
+}
+
+// WithSkipAnnotated makes images that have any of the given manifest annotations or config labels to be left out of the pull.
+func WithSkipAnnotated(annotations map[string]string) func(opts *pullImageSetOptions) {
+	return func(opts *pullImageSetOptions) {
+		opts.skipAnnotated = annotations
+	}
+}
+
+// matchAnnotations finds the first of annotations that is set on image manifest or as image config label.
+func matchAnnotations(img v1.Image, annotations map[string]string) (key, value string, matched bool, err error) {
+	if len(annotations) == 0 {
+		return "", "", false, nil
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return "", "", false, fmt.Errorf("read manifest: %w", err)
+	}
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return "", "", false, fmt.Errorf("read config: %w", err)
+	}
+
+	for _, key = range slices.Sorted(maps.Keys(annotations)) {
+		value = annotations[key]
+		if manifestValue, found := manifest.Annotations[key]; found && manifestValue == value {
+			return key, value, true, nil
+		}
+		if labelValue, found := configFile.Config.Labels[key]; found && labelValue == value {
+			return key, value, true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// WithStage sets the name of the stage under which pull progress is reported to the run context.
+func WithStage(stage string) func(opts *pullImageSetOptions) {
+	return func(opts *pullImageSetOptions) {
+		opts.stage = stage
+	}
 }
 
 func WithAllowMissingTags(allow bool) func(opts *pullImageSetOptions) {
@@ -242,3 +684,29 @@ func WithTagToDigestMapper(fn TagToDigestMappingFunc) func(opts *pullImageSetOpt
 		opts.tagToDigestMapper = fn
 	}
 }
+
+func verifySourceSignature(
+	ctx context.Context,
+	pullCtx *contexts.PullContext,
+	repo name.Repository,
+	digest v1.Hash,
+	remoteOpts []remote.Option,
+) error {
+	if pullCtx.SourceSignatureKey == nil {
+		return nil
+	}
+
+	err := signature.VerifyCosignSignature(ctx, repo, digest, pullCtx.SourceSignatureKey, remoteOpts...)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, signature.ErrSignatureNotFound), errors.Is(err, signature.ErrInvalidSignature):
+		if pullCtx.SignaturePolicy == contexts.SignaturePolicyWarn {
+			pullCtx.Logger.WarnF("Signature verification of %s@%s failed: %v", repo, digest, err)
+			return nil
+		}
+		return retry.Permanent(fmt.Errorf("verify signature of %s@%s: %w", repo, digest, err))
+	default:
+		return fmt.Errorf("verify signature of %s@%s: %w", repo, digest, err)
+	}
+}
@@ -17,8 +17,11 @@ limitations under the License.
 package layouts
 
 import (
+	"context"
 	"log/slog"
 	"net/http/httptest"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -26,7 +29,9 @@ import (
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/stretchr/testify/require"
@@ -172,3 +177,221 @@ func createEmptyOCILayout(t *testing.T) layout.Path {
 	require.NoError(t, err)
 	return l
 }
+
+func TestPullImageSetSkipsAnnotatedImages(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	deckhouseRepo := strings.TrimPrefix(server.URL, "http://") + "/deckhouse/ee"
+
+	plain, err := random.Image(256, 1)
+	require.NoError(t, err)
+	deprecatedByAnnotation := mutate.Annotations(plain, map[string]string{"io.deckhouse.image/deprecated": "true"}).(v1.Image)
+	deprecatedByLabel, err := mutate.Config(plain, v1.Config{Labels: map[string]string{"io.deckhouse.image/deprecated": "true"}})
+	require.NoError(t, err)
+	notDeprecated := mutate.Annotations(plain, map[string]string{"io.deckhouse.image/deprecated": "false"}).(v1.Image)
+
+	images := map[string]v1.Image{
+		deckhouseRepo + ":plain":          plain,
+		deckhouseRepo + ":annotated":      deprecatedByAnnotation,
+		deckhouseRepo + ":labeled":        deprecatedByLabel,
+		deckhouseRepo + ":not-deprecated": notDeprecated,
+	}
+	imageSet := make(map[string]struct{}, len(images))
+	for imageRef, img := range images {
+		ref, err := name.ParseReference(imageRef, name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, img))
+		imageSet[imageRef] = struct{}{}
+	}
+
+	pullCtx := &contexts.PullContext{BaseContext: contexts.BaseContext{
+		Logger:   testLogger,
+		Insecure: true,
+		Run:      contexts.NewRunContext(context.Background()),
+	}}
+	targetLayout := createEmptyOCILayout(t)
+	err = PullImageSet(pullCtx, targetLayout, imageSet, WithSkipAnnotated(map[string]string{"io.deckhouse.image/deprecated": "true"}))
+	require.NoError(t, err)
+
+	index, err := targetLayout.ImageIndex()
+	require.NoError(t, err)
+	indexManifest, err := index.IndexManifest()
+	require.NoError(t, err)
+	pulled := make([]string, 0)
+	for _, manifest := range indexManifest.Manifests {
+		pulled = append(pulled, manifest.Annotations["io.deckhouse.image.short_tag"])
+	}
+	require.ElementsMatch(t, []string{"plain", "not-deprecated"}, pulled)
+	require.Equal(t, int64(2), pullCtx.Run.Metrics.ImagesSkipped.Load())
+}
+
+func TestPullImageSetResumesFromCheckpoint(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	deckhouseRepo := strings.TrimPrefix(server.URL, "http://") + "/deckhouse/ee"
+
+	imageSet := map[string]struct{}{}
+	for _, tag := range []string{"v1.60.0", "v1.61.0", "v1.62.0"} {
+		ref, err := name.ParseReference(deckhouseRepo+":"+tag, name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, randomImage(t)))
+		imageSet[deckhouseRepo+":"+tag] = struct{}{}
+	}
+
+	bundleDir := t.TempDir()
+	checkpointPath := filepath.Join(bundleDir, contexts.PullCheckpointFile)
+	checkpoint, err := contexts.LoadPullCheckpoint(checkpointPath)
+	require.NoError(t, err)
+	pullCtx := &contexts.PullContext{
+		BaseContext: contexts.BaseContext{
+			Logger:   testLogger,
+			Insecure: true,
+			Run:      contexts.NewRunContext(context.Background()),
+		},
+		Checkpoint: checkpoint,
+	}
+	targetLayout, err := CreateEmptyImageLayoutAtPath(filepath.Join(bundleDir, "install"))
+	require.NoError(t, err)
+	require.NoError(t, PullImageSet(pullCtx, targetLayout, imageSet))
+	pulled := pulledDigests(t, targetLayout)
+
+	// Between runs v1.61.0 is moved to another image and v1.62.0 gets excluded, only v1.60.0 can be resumed.
+	movedRef, err := name.ParseReference(deckhouseRepo+":v1.61.0", name.Insecure)
+	require.NoError(t, err)
+	moved := randomImage(t)
+	require.NoError(t, remote.Write(movedRef, moved))
+	movedDigest, err := moved.Digest()
+	require.NoError(t, err)
+	pullCtx.Exclusions, err = contexts.NewImageExclusions(nil, []string{pulled["v1.62.0"].String()})
+	require.NoError(t, err)
+
+	pullCtx.Checkpoint, err = contexts.LoadPullCheckpoint(checkpointPath)
+	require.NoError(t, err)
+	require.Equal(t, 3, pullCtx.Checkpoint.Len())
+	pullCtx.Run = contexts.NewRunContext(context.Background())
+	targetLayout, err = CreateEmptyImageLayoutAtPath(filepath.Join(bundleDir, "install"))
+	require.NoError(t, err)
+	require.NoError(t, PullImageSet(pullCtx, targetLayout, imageSet))
+
+	require.Equal(t, map[string]v1.Hash{"v1.60.0": pulled["v1.60.0"], "v1.61.0": movedDigest}, pulledDigests(t, targetLayout))
+	require.Equal(t, int64(1), pullCtx.Run.Metrics.ImagesPulled.Load(), "only moved tag should be pulled again")
+}
+
+func pulledDigests(t *testing.T, targetLayout layout.Path) map[string]v1.Hash {
+	t.Helper()
+	index, err := targetLayout.ImageIndex()
+	require.NoError(t, err)
+	indexManifest, err := index.IndexManifest()
+	require.NoError(t, err)
+	digests := map[string]v1.Hash{}
+	for _, manifest := range indexManifest.Manifests {
+		digests[manifest.Annotations["io.deckhouse.image.short_tag"]] = manifest.Digest
+	}
+	return digests
+}
+
+func TestPullImageSetConcurrently(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	deckhouseRepo := strings.TrimPrefix(server.URL, "http://") + "/deckhouse/ee"
+
+	imageSet := map[string]struct{}{}
+	tags := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		tag := "v1.60." + strconv.Itoa(i)
+		ref, err := name.ParseReference(deckhouseRepo+":"+tag, name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, randomImage(t)))
+		imageSet[deckhouseRepo+":"+tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+
+	pullCtx := &contexts.PullContext{
+		BaseContext: contexts.BaseContext{
+			Logger:   testLogger,
+			Insecure: true,
+			Run:      contexts.NewRunContext(context.Background()),
+		},
+		Concurrency: 4,
+	}
+	targetLayout := createEmptyOCILayout(t)
+	require.NoError(t, PullImageSet(pullCtx, targetLayout, imageSet))
+
+	index, err := targetLayout.ImageIndex()
+	require.NoError(t, err)
+	indexManifest, err := index.IndexManifest()
+	require.NoError(t, err)
+	pulled := make([]string, 0)
+	for _, manifest := range indexManifest.Manifests {
+		pulled = append(pulled, manifest.Annotations["io.deckhouse.image.short_tag"])
+		_, err = targetLayout.Image(manifest.Digest)
+		require.NoError(t, err)
+	}
+	require.ElementsMatch(t, tags, pulled)
+	require.Equal(t, int64(10), pullCtx.Run.Metrics.ImagesPulled.Load())
+}
+
+func TestPullImageSetThinsImageIndexes(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	deckhouseRepo := strings.TrimPrefix(server.URL, "http://") + "/deckhouse/ee"
+
+	var multiPlatformIndex v1.ImageIndex = empty.Index
+	for _, platform := range []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		{OS: "linux", Architecture: "s390x"},
+	} {
+		img, err := random.Image(256, 1)
+		require.NoError(t, err)
+		multiPlatformIndex = mutate.AppendManifests(multiPlatformIndex, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &platform},
+		})
+	}
+	ref, err := name.ParseReference(deckhouseRepo+":v1.60.0", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, multiPlatformIndex))
+	indexDigest, err := multiPlatformIndex.Digest()
+	require.NoError(t, err)
+
+	pullCtx := &contexts.PullContext{
+		BaseContext: contexts.BaseContext{
+			Logger:   testLogger,
+			Insecure: true,
+			Run:      contexts.NewRunContext(context.Background()),
+		},
+		Platforms: []v1.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64"}},
+	}
+	targetLayout := createEmptyOCILayout(t)
+	imageSet := map[string]struct{}{
+		deckhouseRepo + ":v1.60.0":                 {},
+		deckhouseRepo + "@" + indexDigest.String(): {},
+	}
+	require.NoError(t, PullImageSet(pullCtx, targetLayout, imageSet))
+
+	index, err := targetLayout.ImageIndex()
+	require.NoError(t, err)
+	indexManifest, err := index.IndexManifest()
+	require.NoError(t, err)
+	require.Len(t, indexManifest.Manifests, 2)
+	for _, desc := range indexManifest.Manifests {
+		require.True(t, desc.MediaType.IsIndex())
+		pulledIndex, err := index.ImageIndex(desc.Digest)
+		require.NoError(t, err)
+		pulledManifest, err := pulledIndex.IndexManifest()
+		require.NoError(t, err)
+
+		if strings.Contains(desc.Annotations["org.opencontainers.image.ref.name"], "@") {
+			require.Equal(t, indexDigest, desc.Digest, "index pulled by digest must be kept whole")
+			require.Len(t, pulledManifest.Manifests, 3)
+			continue
+		}
+		require.Len(t, pulledManifest.Manifests, 2)
+		for _, child := range pulledManifest.Manifests {
+			require.NotEqual(t, "s390x", child.Platform.Architecture)
+			_, err = pulledIndex.Image(child.Digest)
+			require.NoError(t, err, "images of kept platforms must be written to layout")
+		}
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+var (
+	blobPathRegex     = regexp.MustCompile(`/blobs/(sha256:[a-f0-9]{64})$`)
+	manifestPathRegex = regexp.MustCompile(`/manifests/[^/]+$`)
+)
+
+// pullTimingTransport measures how long registry takes to respond to manifest and blob requests
+// and how long it takes to read blobs data, so that slow registry can be told apart from slow network or disk.
+type pullTimingTransport struct {
+	base http.RoundTripper
+
+	mu               sync.Mutex
+	manifestResponse time.Duration
+	layers           map[string]*contexts.LayerTiming
+}
+
+func newPullTimingTransport(base http.RoundTripper) *pullTimingTransport {
+	return &pullTimingTransport{base: base, layers: make(map[string]*contexts.LayerTiming)}
+}
+
+func (t *pullTimingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet {
+		return resp, err
+	}
+	responseTime := time.Since(start)
+
+	requestPath := originalRequestPath(req)
+	if manifestPathRegex.MatchString(requestPath) {
+		t.mu.Lock()
+		t.manifestResponse += responseTime
+		t.mu.Unlock()
+		return resp, nil
+	}
+
+	match := blobPathRegex.FindStringSubmatch(requestPath)
+	if match == nil {
+		return resp, nil
+	}
+
+	t.mu.Lock()
+	layer, found := t.layers[match[1]]
+	if !found {
+		layer = &contexts.LayerTiming{Digest: match[1]}
+		t.layers[match[1]] = layer
+	}
+	layer.Response += responseTime
+	t.mu.Unlock()
+
+	// Redirects to blob storage are followed by http.Client with another request, data is read from that one
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		return resp, nil
+	}
+	resp.Body = &timedReadCloser{ReadCloser: resp.Body, start: time.Now(), done: func(size int64, network, transfer time.Duration) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		layer.Size += size
+		layer.Network += network
+		layer.Transfer += transfer
+	}}
+	return resp, nil
+}
+
+// originalRequestPath returns URL path of the request that started redirect chain, req is the last request in that chain.
+func originalRequestPath(req *http.Request) string {
+	for req.Response != nil && req.Response.Request != nil {
+		req = req.Response.Request
+	}
+	return req.URL.Path
+}
+
+// imageTiming returns timings collected so far for the image.
+func (t *pullTimingTransport) imageTiming(stage, reference string, total time.Duration) contexts.ImageTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timing := contexts.ImageTiming{
+		Stage:            stage,
+		Reference:        reference,
+		Total:            total,
+		ManifestResponse: t.manifestResponse,
+		Layers:           make([]contexts.LayerTiming, 0, len(t.layers)),
+	}
+	for _, layer := range t.layers {
+		layer.Image = reference
+		timing.Layers = append(timing.Layers, *layer)
+	}
+	sort.Slice(timing.Layers, func(i, j int) bool { return timing.Layers[i].Digest < timing.Layers[j].Digest })
+	return timing
+}
+
+// timedReadCloser measures time spent in Read calls and time until the body was read to the end or closed.
+type timedReadCloser struct {
+	io.ReadCloser
+
+	start    time.Time
+	size     int64
+	network  time.Duration
+	finished bool
+	done     func(size int64, network, transfer time.Duration)
+}
+
+func (r *timedReadCloser) Read(p []byte) (int, error) {
+	readStart := time.Now()
+	n, err := r.ReadCloser.Read(p)
+	r.network += time.Since(readStart)
+	r.size += int64(n)
+	if err != nil {
+		r.finish()
+	}
+	return n, err
+}
+
+func (r *timedReadCloser) Close() error {
+	r.finish()
+	return r.ReadCloser.Close()
+}
+
+func (r *timedReadCloser) finish() {
+	if r.finished {
+		return
+	}
+	r.finished = true
+	r.done(r.size, r.network, time.Since(r.start))
+}
+
+func logImageTiming(logger contexts.Logger, timing contexts.ImageTiming) {
+	logger.DebugF(
+		"Pulled %s in %s, registry responded to manifest requests in %s, %d layers downloaded",
+		timing.Reference, timing.Total.Round(time.Millisecond), timing.ManifestResponse.Round(time.Millisecond), len(timing.Layers),
+	)
+	for _, layer := range timing.Layers {
+		logger.DebugF("\t%s", FormatLayerTiming(layer))
+	}
+}
+
+// FormatLayerTiming describes where time was spent while downloading layer.
+func FormatLayerTiming(layer contexts.LayerTiming) string {
+	return fmt.Sprintf(
+		"layer %s (%.1f MiB): registry response %s, network %s, local disk %s",
+		layer.Digest, float64(layer.Size)/(1<<20),
+		layer.Response.Round(time.Millisecond), layer.Network.Round(time.Millisecond), layer.Local().Round(time.Millisecond),
+	)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestPullTimingTransport(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://")+"/deckhouse:v1.56.5", name.Insecure)
+	require.NoError(t, err)
+	img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+
+	timingTransport := newPullTimingTransport(remote.DefaultTransport)
+	pulledImg, err := remote.Image(ref, remote.WithTransport(timingTransport))
+	require.NoError(t, err)
+	l, err := CreateEmptyImageLayoutAtPath(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, l.AppendImage(pulledImg))
+
+	timing := timingTransport.imageTiming(contexts.StagePlatform, ref.String(), 0)
+	require.Equal(t, ref.String(), timing.Reference)
+	require.NotZero(t, timing.ManifestResponse)
+
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	expectedSizes := map[string]int64{}
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		require.NoError(t, err)
+		size, err := layer.Size()
+		require.NoError(t, err)
+		expectedSizes[digest.String()] = size
+	}
+
+	gotSizes := map[string]int64{}
+	for _, layer := range timing.Layers {
+		require.Equal(t, ref.String(), layer.Image)
+		require.NotZero(t, layer.Response)
+		require.GreaterOrEqual(t, layer.Transfer, layer.Network)
+		gotSizes[layer.Digest] = layer.Size
+	}
+	for digest, size := range expectedSizes {
+		require.Equal(t, size, gotSizes[digest], "layer %s should be downloaded once with full size", digest)
+	}
+}
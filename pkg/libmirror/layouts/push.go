@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"sync"
 	"time"
 
@@ -35,11 +36,20 @@ import (
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/regcaps"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/retry"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/retry/task"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
 )
 
-var ErrEmptyLayout = errors.New("No images in layout")
+var (
+	ErrEmptyLayout          = errors.New("No images in layout")
+	ErrImmutableTagConflict = errors.New("tag already exists in registry with different contents")
+)
+
+// immutableTagRegexp matches tags that are never moved to another image once published: release versions and digests.
+// Other tags, like release channels, are expected to point to newer images in newer bundles.
+var immutableTagRegexp = regexp.MustCompile(`^(v?\d+\.\d+\.\d+([-+].*)?|[0-9a-f]{64}|sha256-[0-9a-f]{64}(\.\w+)?)$`)
 
 func PushLayoutToRepo(
 	imagesLayout layout.Path,
@@ -48,6 +58,7 @@ func PushLayoutToRepo(
 	logger contexts.Logger,
 	parallelismConfig contexts.ParallelismConfig,
 	insecure, skipVerifyTLS bool,
+	opts ...func(opts *pushLayoutOptions),
 ) error {
 	return PushLayoutToRepoContext(
 		context.Background(),
@@ -58,6 +69,7 @@ func PushLayoutToRepo(
 		parallelismConfig,
 		insecure,
 		skipVerifyTLS,
+		opts...,
 	)
 }
 
@@ -69,16 +81,38 @@ func PushLayoutToRepoContext(
 	logger contexts.Logger,
 	parallelismConfig contexts.ParallelismConfig,
 	insecure, skipVerifyTLS bool,
+	opts ...func(opts *pushLayoutOptions),
 ) error {
+	index, err := imagesLayout.ImageIndex()
+	if err != nil {
+		return fmt.Errorf("Read OCI Image Index: %w", err)
+	}
+	return PushIndexToRepoContext(ctx, index, registryRepo, authProvider, logger, parallelismConfig, insecure, skipVerifyTLS, opts...)
+}
+
+// PushIndexToRepoContext pushes images tagged in index of OCI layout to registryRepo.
+// Unlike PushLayoutToRepoContext, index may be read from anywhere, e.g. from packed bundle.
+func PushIndexToRepoContext(
+	ctx context.Context,
+	index v1.ImageIndex,
+	registryRepo string,
+	authProvider authn.Authenticator,
+	logger contexts.Logger,
+	parallelismConfig contexts.ParallelismConfig,
+	insecure, skipVerifyTLS bool,
+	opts ...func(opts *pushLayoutOptions),
+) error {
+	pushOpts := &pushLayoutOptions{}
+	for _, o := range opts {
+		o(pushOpts)
+	}
+
 	refOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptions(authProvider, insecure, skipVerifyTLS)
+	uploader := newChunkedBlobUploader(authProvider, skipVerifyTLS)
 	if parallelismConfig.Blobs != 0 {
 		remoteOpts = append(remoteOpts, remote.WithJobs(parallelismConfig.Blobs))
 	}
 
-	index, err := imagesLayout.ImageIndex()
-	if err != nil {
-		return fmt.Errorf("Read OCI Image Index: %w", err)
-	}
 	indexManifest, err := index.IndexManifest()
 	if err != nil {
 		return fmt.Errorf("Parse OCI Image Index Manifest: %w", err)
@@ -87,6 +121,18 @@ func PushLayoutToRepoContext(
 	if len(indexManifest.Manifests) == 0 {
 		return fmt.Errorf("%s: %w", registryRepo, ErrEmptyLayout)
 	}
+	if pushOpts.skipCosignArtifacts {
+		manifests := lo.Reject(indexManifest.Manifests, func(desc v1.Descriptor, _ int) bool {
+			return signature.IsCosignArtifactTag(desc.Annotations["io.deckhouse.image.short_tag"])
+		})
+		if skipped := len(indexManifest.Manifests) - len(manifests); skipped > 0 {
+			logger.InfoF("Skipping %d cosign signatures, attestations and SBOMs of %s, push with --include-cosign to push them", skipped, registryRepo)
+		}
+		if len(manifests) == 0 {
+			return nil
+		}
+		indexManifest.Manifests = manifests
+	}
 
 	batches := lo.Chunk(indexManifest.Manifests, parallelismConfig.Images)
 	batchesCount, imagesCount := 1, 1
@@ -96,7 +142,7 @@ func PushLayoutToRepoContext(
 			tag := manifestSet[0].Annotations["io.deckhouse.image.short_tag"]
 			imageRef := registryRepo + ":" + tag
 			logger.InfoF("[%d / %d] Pushing image %s", imagesCount, len(indexManifest.Manifests), imageRef)
-			if err = pushImage(ctx, registryRepo, index, manifestSet[0], refOpts, remoteOpts); err != nil {
+			if err = pushImage(ctx, logger, registryRepo, index, manifestSet[0], refOpts, remoteOpts, uploader, pushOpts); err != nil {
 				return fmt.Errorf("Push Image: %w", err)
 			}
 			imagesCount += 1
@@ -112,7 +158,7 @@ func PushLayoutToRepoContext(
 			errMu := &sync.Mutex{}
 			merr := &multierror.Error{}
 			parallel.ForEach(manifestSet, func(item v1.Descriptor, i int) {
-				if err = pushImage(ctx, registryRepo, index, item, refOpts, remoteOpts); err != nil {
+				if err = pushImage(ctx, logger, registryRepo, index, item, refOpts, remoteOpts, uploader, pushOpts); err != nil {
 					errMu.Lock()
 					defer errMu.Unlock()
 					merr = multierror.Append(merr, err)
@@ -133,14 +179,20 @@ func PushLayoutToRepoContext(
 
 func pushImage(
 	ctx context.Context,
+	logger contexts.Logger,
 	registryRepo string,
 	index v1.ImageIndex,
 	manifest v1.Descriptor,
 	refOpts []name.Option,
 	remoteOpts []remote.Option,
+	uploader *chunkedBlobUploader,
+	pushOpts *pushLayoutOptions,
 ) error {
 	tag := manifest.Annotations["io.deckhouse.image.short_tag"]
 	imageRef := registryRepo + ":" + tag
+	if manifest.MediaType.IsIndex() {
+		return pushIndex(ctx, logger, imageRef, index, manifest, refOpts, remoteOpts, pushOpts)
+	}
 	img, err := index.Image(manifest.Digest)
 	if err != nil {
 		return fmt.Errorf("Read image: %v", err)
@@ -150,13 +202,109 @@ func pushImage(
 		return fmt.Errorf("Parse image reference: %v", err)
 	}
 
+	if pushOpts.skipExistingTags {
+		exists, err := checkTagIsAlreadyPushed(ctx, ref, manifest.Digest, remoteOpts)
+		if err != nil {
+			return err
+		}
+		if exists {
+			logger.DebugF("Skipping %s as it is already present in registry", imageRef)
+			return pushOpts.pushedBlobs.RecordImage(ref.Context(), img)
+		}
+	}
+
+	pushedImg := withMountableLayers(img, ref.Context(), pushOpts.pushedBlobs)
+
 	err = retry.RunTaskWithContext(
 		ctx, silentLogger{}, "push",
 		task.WithConstantRetries(4, 3*time.Second, func(ctx context.Context) error {
-			if err = remote.Write(ref, img, append(remoteOpts, remote.WithContext(ctx))...); err != nil {
+			if err = remote.Write(ref, pushedImg, append(remoteOpts, remote.WithContext(ctx))...); err != nil {
 				if errorutil.IsTrivyMediaTypeNotAllowedError(err) {
 					return fmt.Errorf(errorutil.CustomTrivyMediaTypesWarning)
 				}
+				if errorutil.IsDigestMismatchError(err) && !pushOpts.chunkedUploadSupported() {
+					return retry.Permanent(fmt.Errorf("Write %s to registry: %w: %s", ref.String(), ErrUploadCorrupted, proxyCorruptionDiagnosis))
+				}
+				if errorutil.IsDigestMismatchError(err) {
+					// Monolithic upload was corrupted in transit, uploading blobs in smaller chunks often gets through.
+					logger.WarnF("Upload of %s was corrupted in transit, retrying it in chunks", imageRef)
+					if uploadErr := uploader.uploadImageBlobs(ctx, ref.Context(), img); uploadErr != nil {
+						if errors.Is(uploadErr, ErrUploadCorrupted) {
+							return retry.Permanent(fmt.Errorf("Write %s to registry: %w", ref.String(), uploadErr))
+						}
+						return fmt.Errorf("Write %s to registry: %w", ref.String(), uploadErr)
+					}
+					// All blobs are in place now, so only the manifest is left to be written.
+					if err = remote.Write(ref, img, append(remoteOpts, remote.WithContext(ctx))...); err == nil {
+						return nil
+					}
+				}
+				if errorutil.IsImmutableTagError(err) {
+					exists, headErr := checkTagIsAlreadyPushed(ctx, ref, manifest.Digest, remoteOpts)
+					if headErr != nil {
+						return headErr
+					}
+					if exists {
+						logger.DebugF("Skipping %s as it is immutable and already present in registry", imageRef)
+						return nil
+					}
+				}
+				return fmt.Errorf("Write %s to registry: %w", ref.String(), err)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("Run push task: %v", err)
+	}
+	return pushOpts.pushedBlobs.RecordImage(ref.Context(), img)
+}
+
+// pushIndex pushes multi-platform image index pulled with --platforms, together with images of every platform it has.
+func pushIndex(
+	ctx context.Context,
+	logger contexts.Logger,
+	imageRef string,
+	index v1.ImageIndex,
+	manifest v1.Descriptor,
+	refOpts []name.Option,
+	remoteOpts []remote.Option,
+	pushOpts *pushLayoutOptions,
+) error {
+	imageIndex, err := index.ImageIndex(manifest.Digest)
+	if err != nil {
+		return fmt.Errorf("Read image index: %v", err)
+	}
+	ref, err := name.ParseReference(imageRef, refOpts...)
+	if err != nil {
+		return fmt.Errorf("Parse image reference: %v", err)
+	}
+
+	if pushOpts.skipExistingTags {
+		exists, err := checkTagIsAlreadyPushed(ctx, ref, manifest.Digest, remoteOpts)
+		if err != nil {
+			return err
+		}
+		if exists {
+			logger.DebugF("Skipping %s as it is already present in registry", imageRef)
+			return nil
+		}
+	}
+
+	err = retry.RunTaskWithContext(
+		ctx, silentLogger{}, "push",
+		task.WithConstantRetries(4, 3*time.Second, func(ctx context.Context) error {
+			if err = remote.WriteIndex(ref, imageIndex, append(remoteOpts, remote.WithContext(ctx))...); err != nil {
+				if errorutil.IsImmutableTagError(err) {
+					exists, headErr := checkTagIsAlreadyPushed(ctx, ref, manifest.Digest, remoteOpts)
+					if headErr != nil {
+						return headErr
+					}
+					if exists {
+						logger.DebugF("Skipping %s as it is immutable and already present in registry", imageRef)
+						return nil
+					}
+				}
 				return fmt.Errorf("Write %s to registry: %w", ref.String(), err)
 			}
 			return nil
@@ -168,6 +316,75 @@ func pushImage(
 	return nil
 }
 
+// checkTagIsAlreadyPushed returns true if tag is present in registry and points to the image with expected digest.
+// It returns ErrImmutableTagConflict if immutable tag is present but points to a different image,
+// other tags pointing to a different image are reported as not pushed to be overwritten.
+func checkTagIsAlreadyPushed(ctx context.Context, ref name.Reference, expectedDigest v1.Hash, remoteOpts []remote.Option) (bool, error) {
+	desc, err := remote.Head(ref, append(remoteOpts, remote.WithContext(ctx))...)
+	if err != nil {
+		if errorutil.IsImageNotFoundError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("Check if %s is already present in registry: %w", ref, err)
+	}
+
+	if desc.Digest != expectedDigest {
+		if !immutableTagRegexp.MatchString(ref.Identifier()) {
+			return false, nil
+		}
+		return false, fmt.Errorf(
+			"%s: %w: registry has %s, bundle has %s",
+			ref, ErrImmutableTagConflict, desc.Digest, expectedDigest,
+		)
+	}
+
+	return true, nil
+}
+
+type pushLayoutOptions struct {
+	skipExistingTags    bool
+	skipCosignArtifacts bool
+	capabilities        *regcaps.Capabilities
+	pushedBlobs         *PushedBlobs
+}
+
+// chunkedUploadSupported is true unless registry was probed and turned out to reject chunked uploads,
+// in which case retrying corrupted uploads in chunks is pointless.
+func (o *pushLayoutOptions) chunkedUploadSupported() bool {
+	return o.capabilities == nil || o.capabilities.ChunkedUpload != regcaps.Unsupported
+}
+
+// WithSkipCosignArtifacts makes push leave out cosign signatures, attestations and SBOMs pulled into layout with --include-cosign.
+func WithSkipCosignArtifacts(skip bool) func(opts *pushLayoutOptions) {
+	return func(opts *pushLayoutOptions) {
+		opts.skipCosignArtifacts = skip
+	}
+}
+
+// WithSkipExistingTags makes push check if tag is already present in registry before uploading the image.
+// Tags pointing to the same image are skipped, version and digest tags pointing to a different image are reported as conflicts,
+// while release channel tags are overwritten.
+func WithSkipExistingTags(skip bool) func(opts *pushLayoutOptions) {
+	return func(opts *pushLayoutOptions) {
+		opts.skipExistingTags = skip
+	}
+}
+
+// WithRegistryCapabilities makes push pick upload strategies based on APIs detected in target registry with regcaps.Probe.
+func WithRegistryCapabilities(caps *regcaps.Capabilities) func(opts *pushLayoutOptions) {
+	return func(opts *pushLayoutOptions) {
+		opts.capabilities = caps
+	}
+}
+
+// WithPushedBlobs makes push mount blobs that were pushed to other repositories of the registry, see PushedBlobs.
+// Pass the same PushedBlobs to pushes of every layout of the bundle.
+func WithPushedBlobs(blobs *PushedBlobs) func(opts *pushLayoutOptions) {
+	return func(opts *pushLayoutOptions) {
+		opts.pushedBlobs = blobs
+	}
+}
+
 type silentLogger struct{}
 
 var _ contexts.Logger = silentLogger{}
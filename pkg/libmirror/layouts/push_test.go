@@ -136,3 +136,76 @@ func TestPushEmptyLayoutToRepo(t *testing.T) {
 	s.ErrorIs(err, ErrEmptyLayout, "Push should fail with error about layout with no images")
 	s.Len(blobHandler.ListBlobs(), 0, "No blobs should be pushed to registry")
 }
+
+func TestPushLayoutToRepoSkipsExistingTags(t *testing.T) {
+	s := require.New(t)
+	host, repoPath, _ := mirrorTestUtils.SetupEmptyRegistryRepo(false)
+
+	img, err := random.Image(256, 1)
+	s.NoError(err)
+	imagesLayout := createEmptyOCILayout(t)
+	s.NoError(imagesLayout.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"io.deckhouse.image.short_tag": "v1.0.0",
+	})))
+
+	pushToRepo := func(l layout.Path) error {
+		return PushLayoutToRepo(
+			l,
+			host+repoPath,
+			authn.Anonymous,
+			log.NewSLogger(slog.LevelDebug),
+			contexts.DefaultParallelism,
+			true,  // Use plain insecure HTTP
+			false, // TLS verification irrelevant to HTTP requests
+			WithSkipExistingTags(true),
+		)
+	}
+
+	s.NoError(pushToRepo(imagesLayout), "First push should not fail")
+	s.NoError(pushToRepo(imagesLayout), "Second push of the same image should be skipped")
+
+	otherImg, err := random.Image(256, 1)
+	s.NoError(err)
+	conflictingLayout := createEmptyOCILayout(t)
+	s.NoError(conflictingLayout.AppendImage(otherImg, layout.WithAnnotations(map[string]string{
+		"io.deckhouse.image.short_tag": "v1.0.0",
+	})))
+	s.ErrorIs(pushToRepo(conflictingLayout), ErrImmutableTagConflict, "Push of different image under existing tag should fail")
+}
+
+func TestPushLayoutToRepoSkipExistingTagsOverwritesChannelTags(t *testing.T) {
+	s := require.New(t)
+	host, repoPath, _ := mirrorTestUtils.SetupEmptyRegistryRepo(false)
+
+	pushChannelImage := func() (v1.Hash, error) {
+		img, err := random.Image(256, 1)
+		s.NoError(err)
+		imagesLayout := createEmptyOCILayout(t)
+		s.NoError(imagesLayout.AppendImage(img, layout.WithAnnotations(map[string]string{
+			"io.deckhouse.image.short_tag": "stable",
+		})))
+		digest, err := img.Digest()
+		s.NoError(err)
+		return digest, PushLayoutToRepo(
+			imagesLayout,
+			host+repoPath,
+			authn.Anonymous,
+			log.NewSLogger(slog.LevelDebug),
+			contexts.DefaultParallelism,
+			true,  // Use plain insecure HTTP
+			false, // TLS verification irrelevant to HTTP requests
+			WithSkipExistingTags(true),
+		)
+	}
+
+	_, err := pushChannelImage()
+	s.NoError(err, "First push should not fail")
+	newerDigest, err := pushChannelImage()
+	s.NoError(err, "Push of newer image under channel tag should overwrite it")
+
+	ref, err := name.ParseReference(host+repoPath+":stable", name.Insecure)
+	s.NoError(err)
+	desc, err := remote.Head(ref)
+	s.NoError(err)
+	s.Equal(newerDigest, desc.Digest)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// PushedBlobs remembers repositories of the target registry that blobs were pushed to during the run,
+// so that pushing the same blobs into other repositories mounts them from there instead of uploading them again.
+// Blobs already present in the repository itself are never uploaded, as remote.Write checks them with HEAD request first.
+// All of its methods are safe for concurrent use and for use on nil *PushedBlobs, which remembers nothing.
+type PushedBlobs struct {
+	mu    sync.Mutex
+	repos map[v1.Hash]name.Repository
+}
+
+func NewPushedBlobs() *PushedBlobs {
+	return &PushedBlobs{repos: map[v1.Hash]name.Repository{}}
+}
+
+// Record remembers that blobs are present in repo.
+func (b *PushedBlobs) Record(repo name.Repository, digests ...v1.Hash) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, digest := range digests {
+		if _, found := b.repos[digest]; !found {
+			b.repos[digest] = repo
+		}
+	}
+}
+
+// MountSource returns another repository of the same registry that blob was pushed to.
+func (b *PushedBlobs) MountSource(repo name.Repository, digest v1.Hash) (name.Repository, bool) {
+	if b == nil {
+		return name.Repository{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	source, found := b.repos[digest]
+	if !found || source.Name() == repo.Name() || source.RegistryStr() != repo.RegistryStr() {
+		return name.Repository{}, false
+	}
+	return source, true
+}
+
+// RecordImage remembers that config and layers of the image are present in repo.
+func (b *PushedBlobs) RecordImage(repo name.Repository, img v1.Image) error {
+	if b == nil {
+		return nil
+	}
+	digests, err := imageBlobs(img)
+	if err != nil {
+		return err
+	}
+	b.Record(repo, digests...)
+	return nil
+}
+
+func imageBlobs(img v1.Image) ([]v1.Hash, error) {
+	configDigest, err := img.ConfigName()
+	if err != nil {
+		return nil, fmt.Errorf("read image config digest: %w", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("read image layers: %w", err)
+	}
+	digests := []v1.Hash{configDigest}
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			return nil, fmt.Errorf("read layer digest: %w", err)
+		}
+		digests = append(digests, digest)
+	}
+	return digests, nil
+}
+
+// mountableImage makes remote.Write try to mount layers pushed to other repositories of the target registry
+// with cross-repository blob mount. Registries that cannot mount the blob start a regular upload in response,
+// so the attempt costs no extra request.
+type mountableImage struct {
+	v1.Image
+	repo  name.Repository
+	blobs *PushedBlobs
+}
+
+func withMountableLayers(img v1.Image, repo name.Repository, blobs *PushedBlobs) v1.Image {
+	if blobs == nil {
+		return img
+	}
+	return &mountableImage{Image: img, repo: repo, blobs: blobs}
+}
+
+func (i *mountableImage) Layers() ([]v1.Layer, error) {
+	layers, err := i.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	mountable := make([]v1.Layer, 0, len(layers))
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			return nil, err
+		}
+		if source, found := i.blobs.MountSource(i.repo, digest); found {
+			layer = &remote.MountableLayer{Layer: layer, Reference: source.Digest(digest.String())}
+		}
+		mountable = append(mountable, layer)
+	}
+	return mountable, nil
+}
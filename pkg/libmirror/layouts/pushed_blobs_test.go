@@ -0,0 +1,119 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+// repoScopedBlobs wraps in-memory registry, which shares blobs between all repositories,
+// to keep track of blobs of every repository and count uploads and mounts.
+type repoScopedBlobs struct {
+	handler http.Handler
+
+	mu      sync.Mutex
+	blobs   map[string]map[string]struct{}
+	uploads int
+	mounts  int
+}
+
+func (s *repoScopedBlobs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	repo, blobPath, isBlob := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/"), "/blobs/")
+	if !isBlob {
+		s.handler.ServeHTTP(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodHead && !s.has(repo, blobPath):
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case r.Method == http.MethodPost && query.Get("mount") != "":
+		// In-memory registry does not mount blobs, it starts regular upload in response.
+		if s.has(query.Get("from"), query.Get("mount")) {
+			s.mounts++
+			s.add(repo, query.Get("mount"))
+			s.mu.Unlock()
+			w.Header().Set("Docker-Content-Digest", query.Get("mount"))
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		r.URL.RawQuery = ""
+	case r.Method == http.MethodPut && query.Get("digest") != "":
+		s.uploads++
+		s.add(repo, query.Get("digest"))
+	}
+	s.mu.Unlock()
+	s.handler.ServeHTTP(w, r)
+}
+
+func (s *repoScopedBlobs) has(repo, digest string) bool {
+	_, found := s.blobs[repo][digest]
+	return found
+}
+
+func (s *repoScopedBlobs) add(repo, digest string) {
+	if s.blobs[repo] == nil {
+		s.blobs[repo] = map[string]struct{}{}
+	}
+	s.blobs[repo][digest] = struct{}{}
+}
+
+func TestPushMountsBlobsPushedToOtherRepositories(t *testing.T) {
+	scoped := &repoScopedBlobs{handler: registry.New(), blobs: map[string]map[string]struct{}{}}
+	server := httptest.NewServer(scoped)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	imagesLayout := createEmptyOCILayout(t)
+	img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+	require.NoError(t, imagesLayout.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"io.deckhouse.image.short_tag": "v1.60.0",
+	})))
+
+	pushedBlobs := NewPushedBlobs()
+	for _, repo := range []string{"/deckhouse/ee", "/deckhouse/ee/install"} {
+		require.NoError(t, PushLayoutToRepo(
+			imagesLayout, host+repo, authn.Anonymous, testLogger, contexts.DefaultParallelism, true, false,
+			WithPushedBlobs(pushedBlobs),
+		))
+	}
+	require.Equal(t, 2, scoped.mounts, "layers pushed to the first repository must be mounted into the second one")
+	require.Equal(t, 4, scoped.uploads, "only configs must be uploaded twice")
+
+	require.NoError(t, PushLayoutToRepo(
+		imagesLayout, host+"/deckhouse/ee/install", authn.Anonymous, testLogger, contexts.DefaultParallelism, true, false,
+		WithPushedBlobs(pushedBlobs),
+	))
+	require.Equal(t, 4, scoped.uploads, "blobs already present in repository must not be uploaded again")
+}
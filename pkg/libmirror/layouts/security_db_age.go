@@ -0,0 +1,137 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/samber/lo"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+// SecurityDatabaseWarnAge is the age of vulnerability databases in source registry that is warned about
+// even if --max-security-db-age is not set. Vendor databases are normally rebuilt several times a day.
+const SecurityDatabaseWarnAge = 7 * 24 * time.Hour
+
+var ErrStaleSecurityDatabase = errors.New("vulnerability database is outdated")
+
+// SecurityDatabaseAge is the build time of vulnerability database image in source registry.
+type SecurityDatabaseAge struct {
+	Reference string
+	// Created is zero if image does not record its build time.
+	Created time.Time
+}
+
+// securityDatabaseImages returns references of vulnerability database images in Deckhouse repo
+// and layouts they are pulled to.
+func securityDatabaseImages(deckhouseRepo string, layouts *ImageLayouts) map[string]layout.Path {
+	return map[string]layout.Path{
+		path.Join(deckhouseRepo, "security", "trivy-db:2"):      layouts.TrivyDB,
+		path.Join(deckhouseRepo, "security", "trivy-bdu:1"):     layouts.TrivyBDU,
+		path.Join(deckhouseRepo, "security", "trivy-java-db:1"): layouts.TrivyJavaDB,
+		path.Join(deckhouseRepo, "security", "trivy-checks:0"):  layouts.TrivyChecks,
+	}
+}
+
+// CheckSecurityDatabasesAge looks up build time of vulnerability databases in source registry before pulling them,
+// as mirroring outdated vulnerability data into air-gapped cluster silently gives a false sense of security.
+// Databases older than SecurityDatabaseWarnAge are warned about, ones older than maxAge fail the check if it is set.
+// Databases missing from source, like in SE edition, are skipped.
+func CheckSecurityDatabasesAge(ctx context.Context, pullCtx *contexts.PullContext, maxAge time.Duration, now time.Time) ([]SecurityDatabaseAge, error) {
+	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&pullCtx.BaseContext)
+	remoteOpts = append(remoteOpts, remote.WithContext(ctx))
+
+	refs := lo.Keys(securityDatabaseImages(pullCtx.DeckhouseRegistryRepo, &ImageLayouts{}))
+	sort.Strings(refs)
+
+	ages := make([]SecurityDatabaseAge, 0, len(refs))
+	stale := make([]error, 0)
+	for _, imageRef := range refs {
+		ref, err := name.ParseReference(imageRef, nameOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("parse vulnerability database reference %q: %w", imageRef, err)
+		}
+
+		created, err := imageCreated(ref, remoteOpts)
+		if errorutil.IsImageNotFoundError(err) || errorutil.IsRepoNotFoundError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read build time of %s: %w", imageRef, err)
+		}
+
+		age := SecurityDatabaseAge{Reference: imageRef, Created: created}
+		ages = append(ages, age)
+		switch {
+		case created.IsZero():
+			pullCtx.Logger.WarnF("⚠️ Build time of vulnerability database %s is unknown, it may be outdated", imageRef)
+		case maxAge > 0 && now.Sub(created) > maxAge:
+			stale = append(stale, fmt.Errorf("%s was built %s ago, at %s", imageRef, now.Sub(created).Round(time.Hour), reportschema.Timestamp(created)))
+		case now.Sub(created) > SecurityDatabaseWarnAge:
+			pullCtx.Logger.WarnF(
+				"⚠️ Vulnerability database %s was built %s ago, at %s. Scans in the cluster will miss vulnerabilities disclosed since then",
+				imageRef, now.Sub(created).Round(time.Hour), reportschema.Timestamp(created),
+			)
+		default:
+			pullCtx.Logger.DebugF("Vulnerability database %s was built at %s", imageRef, reportschema.Timestamp(created))
+		}
+	}
+
+	if len(stale) > 0 {
+		return ages, fmt.Errorf("%w, it is older than %s: %w", ErrStaleSecurityDatabase, maxAge, errors.Join(stale...))
+	}
+	return ages, nil
+}
+
+// imageCreated returns build time of image from the standard manifest annotation set by ORAS and most build tools,
+// or from image config if there is no such annotation.
+func imageCreated(ref name.Reference, remoteOpts []remote.Option) (time.Time, error) {
+	img, err := remote.Image(ref, remoteOpts...)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("read manifest: %w", err)
+	}
+	if created, found := manifest.Annotations["org.opencontainers.image.created"]; found {
+		createdTime, err := time.Parse(time.RFC3339, created)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parse org.opencontainers.image.created annotation %q: %w", created, err)
+		}
+		return createdTime, nil
+	}
+
+	config, err := img.ConfigFile()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("read config: %w", err)
+	}
+	return config.Created.Time, nil
+}
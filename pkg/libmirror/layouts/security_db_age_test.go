@@ -0,0 +1,75 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestCheckSecurityDatabasesAge(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	deckhouseRepo := strings.TrimPrefix(server.URL, "http://") + "/deckhouse/ee"
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	annotatedDB := mutate.Annotations(randomImage(t), map[string]string{
+		"org.opencontainers.image.created": now.Add(-10 * 24 * time.Hour).Format(time.RFC3339),
+	}).(v1.Image)
+	configDB, err := mutate.CreatedAt(randomImage(t), v1.Time{Time: now.Add(-6 * time.Hour)})
+	require.NoError(t, err)
+	for ref, img := range map[string]v1.Image{
+		deckhouseRepo + "/security/trivy-db:2":     annotatedDB,
+		deckhouseRepo + "/security/trivy-bdu:1":    configDB,
+		deckhouseRepo + "/security/trivy-checks:0": randomImage(t),
+	} {
+		parsedRef, err := name.ParseReference(ref, name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(parsedRef, img))
+	}
+
+	pullCtx := &contexts.PullContext{BaseContext: contexts.BaseContext{
+		Logger:                testLogger,
+		Insecure:              true,
+		DeckhouseRegistryRepo: deckhouseRepo,
+	}}
+
+	ages, err := CheckSecurityDatabasesAge(context.Background(), pullCtx, 0, now)
+	require.NoError(t, err, "outdated databases are only warned about without max age")
+	require.Equal(t, []SecurityDatabaseAge{
+		{Reference: deckhouseRepo + "/security/trivy-bdu:1", Created: now.Add(-6 * time.Hour)},
+		{Reference: deckhouseRepo + "/security/trivy-checks:0"},
+		{Reference: deckhouseRepo + "/security/trivy-db:2", Created: now.Add(-10 * 24 * time.Hour)},
+	}, ages, "missing trivy-java-db must be skipped")
+
+	_, err = CheckSecurityDatabasesAge(context.Background(), pullCtx, 48*time.Hour, now)
+	require.ErrorIs(t, err, ErrStaleSecurityDatabase)
+	require.ErrorContains(t, err, "trivy-db:2 was built 240h0m0s ago")
+	require.NotContains(t, err.Error(), "trivy-bdu")
+}
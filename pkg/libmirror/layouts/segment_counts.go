@@ -0,0 +1,172 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// SegmentCounts are numbers of distinct tags, images and blobs in a repository.
+// Blobs are the ones referenced by image manifests directly: configs and layers of images, child manifests of indexes.
+type SegmentCounts struct {
+	Tags   int `json:"tags"`
+	Images int `json:"images"`
+	Blobs  int `json:"blobs"`
+}
+
+// Covers reports whether c has at least as many tags, images and blobs as expected.
+func (c SegmentCounts) Covers(expected SegmentCounts) bool {
+	return c.Tags >= expected.Tags && c.Images >= expected.Images && c.Blobs >= expected.Blobs
+}
+
+func (c SegmentCounts) String() string {
+	return fmt.Sprintf("%d tags, %d images, %d blobs", c.Tags, c.Images, c.Blobs)
+}
+
+// SegmentCounter accumulates counts of a repository manifest by manifest.
+type SegmentCounter struct {
+	tags   map[string]struct{}
+	images map[v1.Hash]struct{}
+	blobs  map[v1.Hash]struct{}
+}
+
+func NewSegmentCounter() *SegmentCounter {
+	return &SegmentCounter{
+		tags:   make(map[string]struct{}),
+		images: make(map[v1.Hash]struct{}),
+		blobs:  make(map[v1.Hash]struct{}),
+	}
+}
+
+// Add records tag pointing to manifest with digest, raw contents and media type.
+func (c *SegmentCounter) Add(tag string, digest v1.Hash, mediaType types.MediaType, rawManifest []byte) error {
+	c.tags[tag] = struct{}{}
+	if _, seen := c.images[digest]; seen {
+		return nil
+	}
+	c.images[digest] = struct{}{}
+
+	blobs, err := referencedBlobs(mediaType, rawManifest)
+	if err != nil {
+		return fmt.Errorf("Parse manifest %s: %w", digest, err)
+	}
+	for _, blob := range blobs {
+		c.blobs[blob] = struct{}{}
+	}
+	return nil
+}
+
+func (c *SegmentCounter) Counts() SegmentCounts {
+	return SegmentCounts{Tags: len(c.tags), Images: len(c.images), Blobs: len(c.blobs)}
+}
+
+func referencedBlobs(mediaType types.MediaType, rawManifest []byte) ([]v1.Hash, error) {
+	if mediaType.IsIndex() {
+		index, err := v1.ParseIndexManifest(bytes.NewReader(rawManifest))
+		if err != nil {
+			return nil, err
+		}
+		blobs := make([]v1.Hash, 0, len(index.Manifests))
+		for _, manifest := range index.Manifests {
+			blobs = append(blobs, manifest.Digest)
+		}
+		return blobs, nil
+	}
+
+	manifest, err := v1.ParseManifest(bytes.NewReader(rawManifest))
+	if err != nil {
+		return nil, err
+	}
+	blobs := make([]v1.Hash, 0, len(manifest.Layers)+1)
+	blobs = append(blobs, manifest.Config.Digest)
+	for _, layer := range manifest.Layers {
+		blobs = append(blobs, layer.Digest)
+	}
+	return blobs, nil
+}
+
+// CountLayout counts tagged images of the OCI layout and blobs they reference.
+func CountLayout(l layout.Path) (SegmentCounts, error) {
+	index, err := l.ImageIndex()
+	if err != nil {
+		return SegmentCounts{}, fmt.Errorf("Read index: %w", err)
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return SegmentCounts{}, fmt.Errorf("Read index manifest: %w", err)
+	}
+
+	counter := NewSegmentCounter()
+	for _, desc := range indexManifest.Manifests {
+		tag, tagged := desc.Annotations["io.deckhouse.image.short_tag"]
+		if !tagged {
+			continue
+		}
+		rawManifest, err := l.Bytes(desc.Digest)
+		if err != nil {
+			return SegmentCounts{}, fmt.Errorf("Read manifest %s: %w", desc.Digest, err)
+		}
+		if err = counter.Add(tag, desc.Digest, desc.MediaType, rawManifest); err != nil {
+			return SegmentCounts{}, err
+		}
+	}
+	return counter.Counts(), nil
+}
+
+// CountBundleSegments counts contents of every OCI layout found in unpacked bundle, keyed by their paths relative
+// to the bundle root, which are the same as repository paths relative to the root repo, "" being the root itself.
+func CountBundleSegments(unpackedImagesPath string) (map[string]SegmentCounts, error) {
+	counts := make(map[string]SegmentCounts)
+	err := filepath.WalkDir(unpackedImagesPath, func(fsPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if entry.Name() == "blobs" {
+			return filepath.SkipDir
+		}
+		if _, err = os.Stat(filepath.Join(fsPath, "oci-layout")); err != nil {
+			return nil
+		}
+
+		segment, err := filepath.Rel(unpackedImagesPath, fsPath)
+		if err != nil {
+			return err
+		}
+		if segment == "." {
+			segment = ""
+		}
+		if counts[filepath.ToSlash(segment)], err = CountLayout(layout.Path(fsPath)); err != nil {
+			return fmt.Errorf("Count contents of %q: %w", segment, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
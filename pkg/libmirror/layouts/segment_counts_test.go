@@ -0,0 +1,63 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountBundleSegments(t *testing.T) {
+	bundleDir := t.TempDir()
+	root, err := CreateEmptyImageLayoutAtPath(bundleDir)
+	require.NoError(t, err)
+	install, err := CreateEmptyImageLayoutAtPath(filepath.Join(bundleDir, "install"))
+	require.NoError(t, err)
+	_, err = CreateEmptyImageLayoutAtPath(filepath.Join(bundleDir, "modules", "foo", "release"))
+	require.NoError(t, err)
+
+	shared := randomImage(t)
+	appendWithTag(t, root, "v1.60.0", shared)
+	appendWithTag(t, root, "stable", shared)
+	appendWithTag(t, root, "v1.61.0", randomImage(t))
+	require.NoError(t, root.AppendImage(randomImage(t)), "untagged images must not be counted")
+
+	index, err := random.Index(16, 1, 2)
+	require.NoError(t, err)
+	require.NoError(t, install.AppendIndex(index, layout.WithAnnotations(map[string]string{
+		"io.deckhouse.image.short_tag": "v1.60.0",
+	})))
+
+	counts, err := CountBundleSegments(bundleDir)
+	require.NoError(t, err)
+	require.Equal(t, map[string]SegmentCounts{
+		"":                    {Tags: 3, Images: 2, Blobs: 4},
+		"install":             {Tags: 1, Images: 1, Blobs: 2},
+		"modules/foo/release": {},
+	}, counts)
+}
+
+func TestSegmentCountsCovers(t *testing.T) {
+	expected := SegmentCounts{Tags: 2, Images: 2, Blobs: 4}
+	require.True(t, SegmentCounts{Tags: 2, Images: 2, Blobs: 4}.Covers(expected))
+	require.True(t, SegmentCounts{Tags: 3, Images: 2, Blobs: 5}.Covers(expected))
+	require.False(t, SegmentCounts{Tags: 2, Images: 1, Blobs: 4}.Covers(expected))
+}
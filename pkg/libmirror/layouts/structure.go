@@ -0,0 +1,194 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"golang.org/x/sync/errgroup"
+)
+
+// IndexEntry is an image listed in layout index.
+type IndexEntry struct {
+	Tag       string
+	Digest    v1.Hash
+	MediaType types.MediaType
+	// Manifests are images of image index, usually one per platform.
+	Manifests []v1.Descriptor
+}
+
+// CheckIndexStructure looks for entries repeated in layout index and for multi-platform image indexes
+// that lack images of their own platforms, according to hasBlob, or of the required platforms.
+// Problems are prefixed with tags of the entries. Number of checked multi-platform image indexes is returned too.
+func CheckIndexStructure(entries []IndexEntry, hasBlob func(v1.Hash) bool, platforms []v1.Platform) ([]string, int) {
+	counts := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		counts[entry.Tag+"@"+entry.Digest.String()]++
+	}
+
+	problems := make([]string, 0)
+	multiPlatformIndexes := 0
+	checked := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		key := entry.Tag + "@" + entry.Digest.String()
+		if _, repeated := checked[key]; repeated {
+			continue
+		}
+		checked[key] = struct{}{}
+
+		if count := counts[key]; count > 1 {
+			problems = append(problems, fmt.Sprintf("%s: %s is listed %d times in layout index", entry.Tag, entry.Digest, count))
+		}
+
+		// Indexes of cosign artifacts and OCI referrers have no platforms to check.
+		if !entry.MediaType.IsIndex() || !slices.ContainsFunc(entry.Manifests, hasPlatform) {
+			continue
+		}
+		multiPlatformIndexes++
+		for _, manifest := range entry.Manifests {
+			if manifest.Platform != nil && !hasBlob(manifest.Digest) {
+				problems = append(problems, fmt.Sprintf("%s: image of platform %s is missing", entry.Tag, manifest.Platform))
+			}
+		}
+		for _, platform := range platforms {
+			if !slices.ContainsFunc(entry.Manifests, func(manifest v1.Descriptor) bool {
+				return manifest.Platform != nil && manifest.Platform.Satisfies(platform)
+			}) {
+				problems = append(problems, fmt.Sprintf("%s: image index has no image of platform %s", entry.Tag, platform))
+			}
+		}
+	}
+	return problems, multiPlatformIndexes
+}
+
+func hasPlatform(manifest v1.Descriptor) bool {
+	return manifest.Platform != nil
+}
+
+// LayoutStructureError lists structural problems of a single layout, see CheckIndexStructure.
+type LayoutStructureError struct {
+	Layout   string
+	Problems []string
+}
+
+func (e *LayoutStructureError) Error() string {
+	return fmt.Sprintf("%s: %d problems with layout structure:\n\t%s", e.Layout, len(e.Problems), strings.Join(e.Problems, "\n\t"))
+}
+
+// ValidateStructure checks structure of every layout after pull, up to concurrency layouts at once.
+// Problems of every layout are reported as its own LayoutStructureError, errors of all layouts are joined together.
+func ValidateStructure(imageLayouts *ImageLayouts, platforms []v1.Platform, concurrency int) error {
+	named := imageLayouts.named()
+	layoutErrors := make([]error, len(named))
+
+	errs := &errgroup.Group{}
+	errs.SetLimit(max(concurrency, 1))
+	for i, l := range named {
+		errs.Go(func() error {
+			entries, err := readIndexEntries(l.path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", l.name, err)
+			}
+			problems, _ := CheckIndexStructure(entries, func(digest v1.Hash) bool {
+				_, err := os.Stat(filepath.Join(string(l.path), "blobs", digest.Algorithm, digest.Hex))
+				return err == nil
+			}, platforms)
+			if len(problems) > 0 {
+				layoutErrors[i] = &LayoutStructureError{Layout: l.name, Problems: problems}
+			}
+			return nil
+		})
+	}
+	if err := errs.Wait(); err != nil {
+		return fmt.Errorf("validate layouts structure: %w", err)
+	}
+	return errors.Join(layoutErrors...)
+}
+
+type namedLayout struct {
+	name string
+	path layout.Path
+}
+
+// named returns every created layout with its path relative to the bundle root, sorted by it.
+func (l *ImageLayouts) named() []namedLayout {
+	result := make([]namedLayout, 0)
+	for _, nl := range []namedLayout{
+		{"deckhouse", l.Deckhouse},
+		{"install", l.Install},
+		{"install-standalone", l.InstallStandalone},
+		{"release-channel", l.ReleaseChannel},
+		{"security/trivy-db", l.TrivyDB},
+		{"security/trivy-bdu", l.TrivyBDU},
+		{"security/trivy-java-db", l.TrivyJavaDB},
+		{"security/trivy-checks", l.TrivyChecks},
+	} {
+		if nl.path != "" {
+			result = append(result, nl)
+		}
+	}
+	for moduleName, moduleLayout := range l.Modules {
+		result = append(result,
+			namedLayout{"modules/" + moduleName, moduleLayout.ModuleLayout},
+			namedLayout{"modules/" + moduleName + "/release", moduleLayout.ReleasesLayout},
+		)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	return result
+}
+
+// readIndexEntries reads images listed in layout index, with manifests of image indexes.
+func readIndexEntries(l layout.Path) ([]IndexEntry, error) {
+	index, err := l.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("read index manifest: %w", err)
+	}
+
+	entries := make([]IndexEntry, 0, len(indexManifest.Manifests))
+	for _, desc := range indexManifest.Manifests {
+		entry := IndexEntry{Tag: desc.Annotations["io.deckhouse.image.short_tag"], Digest: desc.Digest, MediaType: desc.MediaType}
+		if entry.Tag == "" {
+			entry.Tag = desc.Digest.String()
+		}
+		if desc.MediaType.IsIndex() {
+			imageIndex, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, fmt.Errorf("read image index of %s: %w", entry.Tag, err)
+			}
+			manifest, err := imageIndex.IndexManifest()
+			if err != nil {
+				return nil, fmt.Errorf("read image index of %s: %w", entry.Tag, err)
+			}
+			entry.Manifests = manifest.Manifests
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
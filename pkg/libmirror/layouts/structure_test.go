@@ -0,0 +1,84 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layouts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/modules"
+)
+
+func TestValidateStructure(t *testing.T) {
+	root := t.TempDir()
+	imageLayouts, err := CreateOCIImageLayoutsForDeckhouse(root, []modules.Module{{Name: "console"}, {Name: "commander"}})
+	require.NoError(t, err)
+
+	amd64, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	arm64, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	multiPlatform := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+	)
+	annotations := layout.WithAnnotations(map[string]string{"io.deckhouse.image.short_tag": "v1.60.0"})
+	platforms := []v1.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64"}}
+
+	require.NoError(t, imageLayouts.Install.AppendIndex(multiPlatform, annotations))
+	require.NoError(t, imageLayouts.Modules["commander"].ModuleLayout.AppendIndex(multiPlatform, annotations))
+	require.NoError(t, ValidateStructure(imageLayouts, platforms, 2))
+
+	require.NoError(t, imageLayouts.Modules["commander"].ModuleLayout.AppendIndex(multiPlatform, annotations))
+	consoleLayout := imageLayouts.Modules["console"].ModuleLayout
+	require.NoError(t, consoleLayout.AppendIndex(multiPlatform, annotations))
+	arm64Digest, err := arm64.Digest()
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(string(consoleLayout), "blobs", arm64Digest.Algorithm, arm64Digest.Hex)))
+
+	err = ValidateStructure(imageLayouts, append(platforms, v1.Platform{OS: "linux", Architecture: "s390x"}), 2)
+	require.Error(t, err)
+	layoutErrors := make([]*LayoutStructureError, 0)
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		layoutErr := &LayoutStructureError{}
+		require.True(t, errors.As(err, &layoutErr))
+		layoutErrors = append(layoutErrors, layoutErr)
+	}
+
+	indexDigest, err := multiPlatform.Digest()
+	require.NoError(t, err)
+	require.Equal(t, []*LayoutStructureError{
+		{Layout: "install", Problems: []string{"v1.60.0: image index has no image of platform linux/s390x"}},
+		{Layout: "modules/commander", Problems: []string{
+			"v1.60.0: " + indexDigest.String() + " is listed 2 times in layout index",
+			"v1.60.0: image index has no image of platform linux/s390x",
+		}},
+		{Layout: "modules/console", Problems: []string{
+			"v1.60.0: image of platform linux/arm64 is missing",
+			"v1.60.0: image index has no image of platform linux/s390x",
+		}},
+	}, layoutErrors)
+}
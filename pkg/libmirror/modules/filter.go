@@ -25,16 +25,19 @@ import (
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
 )
 
-// Filter maps module names to minimal versions of these modules to be pulled
+// Filter maps module names to minimal versions of these modules to be pulled,
+// or to semver range constraints that pulled versions must satisfy.
 type Filter struct {
-	modules map[string]*semver.Version
-	logger  contexts.Logger
+	modules     map[string]*semver.Version
+	constraints map[string]*semver.Constraints
+	logger      contexts.Logger
 }
 
 func NewFilter(filterExpression string, logger contexts.Logger) (*Filter, error) {
 	filter := &Filter{
-		modules: make(map[string]*semver.Version),
-		logger:  logger,
+		modules:     make(map[string]*semver.Version),
+		constraints: make(map[string]*semver.Constraints),
+		logger:      logger,
 	}
 	if filterExpression == "" {
 		return filter, nil
@@ -42,7 +45,7 @@ func NewFilter(filterExpression string, logger contexts.Logger) (*Filter, error)
 
 	filters := strings.Split(filterExpression, ";")
 	for _, filterExpr := range filters {
-		moduleName, moduleMinVersionString, validSplit := strings.Cut(strings.TrimSpace(filterExpr), "@")
+		moduleName, moduleVersionExpr, validSplit := strings.Cut(strings.TrimSpace(filterExpr), "@")
 		if !validSplit {
 			logger.WarnF("Malformed filter %q is ignored: invalid filter syntax", filterExpr)
 			continue
@@ -52,44 +55,64 @@ func NewFilter(filterExpression string, logger contexts.Logger) (*Filter, error)
 		if moduleName == "" {
 			return nil, fmt.Errorf("Malformed filter expression %q: empty module name", filterExpr)
 		}
-		if _, moduleRedeclared := filter.modules[moduleName]; moduleRedeclared {
+		if filter.hasModule(moduleName) {
 			return nil, fmt.Errorf("Malformed filter expression: module %s is declared multiple times", moduleName)
 		}
 
-		moduleMinVersion, err := semver.NewVersion(strings.TrimSpace(moduleMinVersionString))
-		if err != nil {
-			return nil, fmt.Errorf("Malformed filter expression %q: %w", filterExpr, err)
+		moduleVersionExpr = strings.TrimSpace(moduleVersionExpr)
+		moduleMinVersion, err := semver.NewVersion(moduleVersionExpr)
+		if err == nil {
+			filter.modules[moduleName] = moduleMinVersion
+			continue
 		}
 
-		filter.modules[moduleName] = moduleMinVersion
+		moduleConstraint, constraintErr := semver.NewConstraint(moduleVersionExpr)
+		if constraintErr != nil {
+			return nil, fmt.Errorf("Malformed filter expression %q: not a version or version range: %w", filterExpr, constraintErr)
+		}
+		filter.constraints[moduleName] = moduleConstraint
 	}
 
 	return filter, nil
 }
 
-func (f *Filter) MatchesFilter(mod *Module) bool {
-	_, hasMinVersion := f.modules[mod.Name]
-	if !hasMinVersion {
-		return false
-	}
+func (f *Filter) hasModule(moduleName string) bool {
+	_, hasMinVersion := f.modules[moduleName]
+	_, hasConstraint := f.constraints[moduleName]
+	return hasMinVersion || hasConstraint
+}
 
-	return true
+func (f *Filter) MatchesFilter(mod *Module) bool {
+	return f.hasModule(mod.Name)
 }
 
-func (f *Filter) Len() int { return len(f.modules) }
+func (f *Filter) Len() int { return len(f.modules) + len(f.constraints) }
 
 func (f *Filter) GetMinimalVersion(moduleName string) (*semver.Version, bool) {
 	v, found := f.modules[moduleName]
 	return v, found
 }
 
-func (f *Filter) FilterReleases(mod *Module) {
-	moduleMinVersion, hasMinVersion := f.modules[mod.Name]
-	if !hasMinVersion {
-		return
+// MatchesVersion reports whether version of the module satisfies the filter.
+func (f *Filter) MatchesVersion(moduleName string, v *semver.Version) bool {
+	if constraint, hasConstraint := f.constraints[moduleName]; hasConstraint {
+		return constraint.Check(v)
+	}
+	if minVersion, hasMinVersion := f.modules[moduleName]; hasMinVersion {
+		return !minVersion.GreaterThan(v)
+	}
+	return false
+}
+
+// FilterReleases removes module releases not matching the filter.
+// It returns an error if module version range from the filter does not match any of the module releases.
+func (f *Filter) FilterReleases(mod *Module) error {
+	if !f.hasModule(mod.Name) {
+		return nil
 	}
 
 	filteredReleases := make([]string, 0)
+	matchedVersions := 0
 	for _, tag := range mod.Releases {
 		v, err := semver.NewVersion(tag)
 		if err != nil {
@@ -98,12 +121,18 @@ func (f *Filter) FilterReleases(mod *Module) {
 			continue
 		}
 
-		if moduleMinVersion.GreaterThan(v) {
+		if !f.MatchesVersion(mod.Name, v) {
 			continue
 		}
 
 		filteredReleases = append(filteredReleases, tag)
+		matchedVersions++
+	}
+
+	if constraint, hasConstraint := f.constraints[mod.Name]; hasConstraint && matchedVersions == 0 {
+		return fmt.Errorf("Version range %q of module %s does not match any of its releases", constraint, mod.Name)
 	}
 
 	mod.Releases = filteredReleases
+	return nil
 }
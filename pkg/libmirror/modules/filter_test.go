@@ -194,9 +194,39 @@ func TestFilter_FilterReleases(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.filter.FilterReleases(tt.mod)
+			require.NoError(t, tt.filter.FilterReleases(tt.mod))
 			require.ElementsMatch(t, tt.want, tt.mod.Releases)
 			require.Len(t, tt.mod.Releases, len(tt.want))
 		})
 	}
 }
+
+func TestFilterWithVersionRanges(t *testing.T) {
+	logger := log.NewSLogger(slog.LevelDebug)
+	filter, err := NewFilter("sds-node-configurator@>=1.4 <1.6; module2@v1.0.0", logger)
+	require.NoError(t, err)
+	require.Equal(t, 2, filter.Len())
+	require.True(t, filter.MatchesFilter(&Module{Name: "sds-node-configurator"}))
+
+	mod := &Module{
+		Name:     "sds-node-configurator",
+		Releases: []string{"alpha", "stable", "v1.3.2", "v1.4.0", "v1.5.7", "v1.6.0"},
+	}
+	require.NoError(t, filter.FilterReleases(mod))
+	require.ElementsMatch(t, []string{"alpha", "stable", "v1.4.0", "v1.5.7"}, mod.Releases)
+
+	require.True(t, filter.MatchesVersion("module2", semver.MustParse("v1.2.0")))
+	require.False(t, filter.MatchesVersion("module2", semver.MustParse("v0.9.0")))
+	require.False(t, filter.MatchesVersion("module3", semver.MustParse("v1.2.0")))
+
+	impossible, err := NewFilter("sds-node-configurator@>=1.6 <1.4", logger)
+	require.NoError(t, err)
+	err = impossible.FilterReleases(&Module{
+		Name:     "sds-node-configurator",
+		Releases: []string{"stable", "v1.4.0", "v1.5.7", "v1.6.0"},
+	})
+	require.ErrorContains(t, err, "does not match any of its releases")
+
+	_, err = NewFilter("sds-node-configurator@>=1.x.garbage", logger)
+	require.ErrorContains(t, err, "not a version or version range")
+}
@@ -17,9 +17,11 @@ limitations under the License.
 package modules
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
+	"net/http"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/google/go-containerregistry/pkg/authn"
@@ -32,6 +34,7 @@ import (
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/images"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/taglist"
 )
 
 type Module struct {
@@ -43,11 +46,14 @@ type Module struct {
 func GetDeckhouseExternalModules(mirrorCtx *contexts.PullContext) ([]Module, error) {
 	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&mirrorCtx.BaseContext)
 	repoPathBuildFuncForDeckhouseModule := func(repo, moduleName string) string {
-		return fmt.Sprintf("%s/modules/%s", mirrorCtx.DeckhouseRegistryRepo, moduleName)
+		return fmt.Sprintf("%s/%s", repo, moduleName)
 	}
 
 	result, err := getModulesForRepo(
-		mirrorCtx.DeckhouseRegistryRepo+"/modules",
+		mirrorCtx.Run.Context(),
+		mirrorCtx.Run.TagLister(),
+		auth.MakeTransport(mirrorCtx.SkipTLSVerification),
+		mirrorCtx.DeckhouseRegistryRepo+"/"+mirrorCtx.ModulesSegment(),
 		repoPathBuildFuncForDeckhouseModule,
 		nameOpts,
 		remoteOpts,
@@ -65,7 +71,15 @@ func GetExternalModulesFromRepo(repo string, registryAuth authn.Authenticator, i
 		return fmt.Sprintf("%s/%s", repo, moduleName)
 	}
 
-	result, err := getModulesForRepo(repo, repoPathBuildFuncForExternalModule, nameOpts, remoteOpts)
+	result, err := getModulesForRepo(
+		context.Background(),
+		taglist.NewLister(),
+		auth.MakeTransport(skipVerifyTLS),
+		repo,
+		repoPathBuildFuncForExternalModule,
+		nameOpts,
+		remoteOpts,
+	)
 	if err != nil {
 		return nil, fmt.Errorf("Get external modules: %w", err)
 	}
@@ -74,6 +88,9 @@ func GetExternalModulesFromRepo(repo string, registryAuth authn.Authenticator, i
 }
 
 func getModulesForRepo(
+	ctx context.Context,
+	tagLister *taglist.Lister,
+	transport http.RoundTripper,
 	repo string,
 	repoPathBuildFunc func(repo, moduleName string) string,
 	nameOpts []name.Option,
@@ -84,7 +101,7 @@ func getModulesForRepo(
 		return nil, fmt.Errorf("Parsing modules repo: %v", err)
 	}
 
-	modules, err := remote.List(modulesRepo, remoteOpts...)
+	modules, err := tagLister.List(ctx, modulesRepo, transport, remoteOpts...)
 	if err != nil {
 		if errorutil.IsRepoNotFoundError(err) {
 			return []Module{}, nil
@@ -104,7 +121,7 @@ func getModulesForRepo(
 		if err != nil {
 			return nil, fmt.Errorf("Parsing repo: %v", err)
 		}
-		m.Releases, err = remote.List(repo, remoteOpts...)
+		m.Releases, err = tagLister.List(ctx, repo, transport, remoteOpts...)
 		if err != nil {
 			return nil, fmt.Errorf("Get releases for module %q: %w", m.RegistryPath, err)
 		}
@@ -134,10 +151,9 @@ func FindExternalModuleImages(
 		return nil, nil, fmt.Errorf("Fetch versions from %q release channels: %w", mod.Name, err)
 	}
 
-	minVersion, hasMinVersion := filter.GetMinimalVersion(mod.Name)
 	for _, tag := range mod.Releases {
 		version, err := semver.NewVersion(tag)
-		if err == nil && hasMinVersion && minVersion.Compare(version) <= 0 {
+		if err == nil && filter.MatchesVersion(mod.Name, version) {
 			releaseImages[mod.RegistryPath+"/release:"+tag] = struct{}{}
 			moduleImages[mod.RegistryPath+":"+tag] = struct{}{}
 		}
@@ -0,0 +1,125 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"golang.org/x/exp/maps"
+	"sigs.k8s.io/yaml"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+// Spec is the modules spec file listing the only modules to be mirrored and their versions.
+//
+//	modules:
+//	  - name: console
+//	    version: v1.24.3
+//	  - name: commander
+//	    version: ">=1.5.0 <1.7.0"
+type Spec struct {
+	Modules []SpecModule `json:"modules"`
+}
+
+// SpecModule pins module to exact version or to versions from semver range.
+// Unlike filter expressions, exact version is not the earliest pulled version but the only one.
+type SpecModule struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// NewFilterFromSpec reads modules spec file and makes filter matching only the versions of modules listed in it.
+func NewFilterFromSpec(specPath string, logger contexts.Logger) (*Filter, error) {
+	rawSpec, err := os.ReadFile(specPath)
+	if err != nil {
+		return nil, fmt.Errorf("read modules spec: %w", err)
+	}
+	spec := &Spec{}
+	if err = yaml.UnmarshalStrict(rawSpec, spec); err != nil {
+		return nil, fmt.Errorf("parse modules spec: %w", err)
+	}
+	if len(spec.Modules) == 0 {
+		return nil, errors.New("modules spec does not list any modules")
+	}
+
+	filter := &Filter{
+		modules:     make(map[string]*semver.Version),
+		constraints: make(map[string]*semver.Constraints),
+		logger:      logger,
+	}
+	for _, module := range spec.Modules {
+		moduleName, versionExpr := strings.TrimSpace(module.Name), strings.TrimSpace(module.Version)
+		switch {
+		case moduleName == "":
+			return nil, errors.New("modules spec lists module without name")
+		case filter.hasModule(moduleName):
+			return nil, fmt.Errorf("module %s is listed in modules spec multiple times", moduleName)
+		case versionExpr == "":
+			return nil, fmt.Errorf("version of module %s is not set in modules spec", moduleName)
+		}
+
+		if exactVersion, err := semver.NewVersion(versionExpr); err == nil {
+			versionExpr = "=" + exactVersion.String()
+		}
+		constraint, err := semver.NewConstraint(versionExpr)
+		if err != nil {
+			return nil, fmt.Errorf("version %q of module %s is not a version or version range: %w", module.Version, moduleName, err)
+		}
+		filter.constraints[moduleName] = constraint
+	}
+	return filter, nil
+}
+
+// PinVersions leaves out modules not listed in the filter and returns the releases of listed ones that match it, by module name.
+// It is an error if module is not found among the given ones or none of its releases match the filter.
+func (f *Filter) PinVersions(mods []Module) ([]Module, map[string][]string, error) {
+	pinnedModules := make([]Module, 0, f.Len())
+	pinnedVersions := make(map[string][]string, f.Len())
+	for _, mod := range mods {
+		if !f.MatchesFilter(&mod) {
+			continue
+		}
+
+		versions := make([]string, 0)
+		for _, tag := range mod.Releases {
+			v, err := semver.NewVersion(tag)
+			if err == nil && f.MatchesVersion(mod.Name, v) {
+				versions = append(versions, tag)
+			}
+		}
+		if len(versions) == 0 {
+			return nil, nil, fmt.Errorf("none of releases of module %s match its version from the filter", mod.Name)
+		}
+
+		mod.Releases = versions
+		pinnedModules = append(pinnedModules, mod)
+		pinnedVersions[mod.Name] = versions
+	}
+
+	for _, moduleName := range slices.Concat(maps.Keys(f.modules), maps.Keys(f.constraints)) {
+		if _, found := pinnedVersions[moduleName]; !found {
+			return nil, nil, fmt.Errorf("module %s is not found in source registry", moduleName)
+		}
+	}
+	return pinnedModules, pinnedVersions, nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modules
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+)
+
+func TestPinVersionsFromSpec(t *testing.T) {
+	specPath := filepath.Join(t.TempDir(), "modules.yaml")
+	require.NoError(t, os.WriteFile(specPath, []byte(`
+modules:
+  - name: console
+    version: v1.2.3
+  - name: commander
+    version: ">=1.5.0 <1.7.0"
+`), 0o644))
+	filter, err := NewFilterFromSpec(specPath, log.NewSLogger(slog.LevelDebug))
+	require.NoError(t, err)
+
+	mods := []Module{
+		{Name: "console", Releases: []string{"v1.2.2", "v1.2.3", "v1.2.4", "alpha"}},
+		{Name: "commander", Releases: []string{"v1.4.0", "v1.5.0", "v1.6.1", "v1.7.0"}},
+		{Name: "stronghold", Releases: []string{"v1.0.0"}},
+	}
+	pinnedModules, pinnedVersions, err := filter.PinVersions(mods)
+	require.NoError(t, err)
+	require.Len(t, pinnedModules, 2)
+	require.Equal(t, map[string][]string{
+		"console":   {"v1.2.3"},
+		"commander": {"v1.5.0", "v1.6.1"},
+	}, pinnedVersions)
+
+	_, _, err = filter.PinVersions(mods[1:])
+	require.ErrorContains(t, err, "module console is not found")
+
+	_, _, err = filter.PinVersions([]Module{mods[0], {Name: "commander", Releases: []string{"v1.7.0"}}})
+	require.ErrorContains(t, err, "none of releases of module commander match")
+}
+
+func TestNewFilterFromMalformedSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{name: "No modules", spec: "modules: []", wantErr: "does not list any modules"},
+		{name: "Unknown field", spec: "modules:\n  - name: console\n    versions: [v1.2.3]", wantErr: "unknown field"},
+		{name: "No version", spec: "modules:\n  - name: console", wantErr: "version of module console is not set"},
+		{name: "Bad version", spec: "modules:\n  - name: console\n    version: latest", wantErr: "not a version or version range"},
+		{name: "Duplicate module", spec: "modules:\n  - name: console\n    version: v1.0.0\n  - name: console\n    version: v1.1.0", wantErr: "listed in modules spec multiple times"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specPath := filepath.Join(t.TempDir(), "modules.yaml")
+			require.NoError(t, os.WriteFile(specPath, []byte(tt.spec), 0o644))
+			_, err := NewFilterFromSpec(specPath, log.NewSLogger(slog.LevelDebug))
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
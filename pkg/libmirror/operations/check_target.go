@@ -0,0 +1,305 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+// Names of checks performed by CheckTarget, in the order they are run.
+const (
+	TargetCheckPush         = "push"
+	TargetCheckRepositories = "repositories"
+	TargetCheckTags         = "tags"
+	TargetCheckManifestSize = "manifest-size"
+	TargetCheckMediaTypes   = "media-types"
+)
+
+// TargetManifestSize is the size of image manifest registry is checked to accept.
+// OCI distribution spec requires registries to accept manifests of at least 4 MiB.
+const TargetManifestSize = 4 * 1024 * 1024
+
+// writeCheckTag is the tag d8 mirror push validates write access with, checks overwrite it instead of leaving other tags behind.
+// Pushed images are not deleted, as not all registries are set up to take DELETE requests kindly.
+const writeCheckTag = "d8WriteCheck"
+
+// TargetCheck is the outcome of a single check of target registry.
+type TargetCheck struct {
+	Name     string             `json:"name"`
+	Status   bundle.CheckStatus `json:"status"`
+	Summary  string             `json:"summary"`
+	Problems []string           `json:"problems,omitempty"`
+}
+
+// TargetCheckReport is the outcome of all checks of target registry.
+type TargetCheckReport struct {
+	reportschema.Header
+
+	Registry string              `json:"registry"`
+	Timing   reportschema.Timing `json:"timing"`
+	Status   bundle.CheckStatus  `json:"status"`
+	Checks   []TargetCheck       `json:"checks"`
+}
+
+// Passed reports whether none of the checks failed.
+func (r *TargetCheckReport) Passed() bool {
+	return r.Status == bundle.CheckPassed
+}
+
+func (r *TargetCheckReport) add(check TargetCheck) {
+	r.Checks = append(r.Checks, check)
+	if r.Status == "" {
+		r.Status = bundle.CheckPassed
+	}
+	if check.Status == bundle.CheckFailed {
+		r.Status = bundle.CheckFailed
+	}
+}
+
+// manifestKind is a kind of manifest found in Deckhouse bundles. Indexes hold a single image of Manifest media type.
+type manifestKind struct {
+	Name                           string
+	Index, Manifest, Config, Layer types.MediaType
+}
+
+const (
+	trivyConfigMediaType types.MediaType = "application/vnd.aquasec.trivy.config.v1+json"
+	octetStreamMediaType types.MediaType = "application/octet-stream"
+)
+
+// targetManifestKinds are kinds of manifests that d8 mirror push uploads. Registries like Project Quay
+// reject OCI artifacts of security databases unless they are explicitly allowed.
+var targetManifestKinds = []manifestKind{
+	{Name: "Docker image", Manifest: types.DockerManifestSchema2, Config: types.DockerConfigJSON, Layer: types.DockerLayer},
+	{Name: "OCI image", Manifest: types.OCIManifestSchema1, Config: types.OCIConfigJSON, Layer: types.OCILayer},
+	{Name: "Docker manifest list", Index: types.DockerManifestList, Manifest: types.DockerManifestSchema2, Config: types.DockerConfigJSON, Layer: types.DockerLayer},
+	{Name: "OCI image index", Index: types.OCIImageIndex, Manifest: types.OCIManifestSchema1, Config: types.OCIConfigJSON, Layer: types.OCILayer},
+	{Name: "Trivy DB", Manifest: types.OCIManifestSchema1, Config: trivyConfigMediaType, Layer: "application/vnd.aquasec.trivy.db.layer.v1.tar+gzip"},
+	{Name: "Trivy Java DB", Manifest: types.OCIManifestSchema1, Config: trivyConfigMediaType, Layer: "application/vnd.aquasec.trivy.javadb.layer.v1.tar+gzip"},
+	{Name: "BDU database", Manifest: types.OCIManifestSchema1, Config: octetStreamMediaType, Layer: "application/deckhouse.io.bdu.layer.v1.tar+gzip"},
+	{Name: "Trivy checks", Manifest: types.OCIManifestSchema1, Config: octetStreamMediaType, Layer: "application/vnd.cncf.openpolicyagent.layer.v1.tar+gzip"},
+}
+
+// CheckTarget checks that target registry meets prerequisites of d8 mirror push, so that problems are found
+// before a long push is attempted: images can be pushed into the target repo, repositories push writes to
+// can be created, tags can be listed, large manifests are accepted and so are manifests of every kind Deckhouse uses.
+// Failing checks do not stop the remaining ones, checks that push images are skipped if images cannot be pushed at all.
+// Error is only returned if ctx is cancelled.
+func CheckTarget(ctx context.Context, mirrorCtx *contexts.PushContext) (*TargetCheckReport, error) {
+	start := time.Now()
+	rootRepo := mirrorCtx.RegistryHost + mirrorCtx.RegistryPath
+	report := &TargetCheckReport{Header: reportschema.NewHeader(reportschema.KindTargetCheckReport), Registry: rootRepo}
+	c := &targetChecker{ctx: ctx, mirrorCtx: mirrorCtx, rootRepo: rootRepo}
+	c.nameOpts, c.remoteOpts = auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&mirrorCtx.BaseContext)
+	c.remoteOpts = append(c.remoteOpts, remote.WithContext(ctx))
+
+	pushCheck := c.checkPush()
+	report.add(pushCheck)
+	canPush := pushCheck.Status == bundle.CheckPassed
+	if canPush {
+		report.add(c.checkRepositories())
+	} else {
+		report.add(TargetCheck{Name: TargetCheckRepositories, Status: bundle.CheckSkipped, Summary: "images cannot be pushed"})
+	}
+	report.add(c.checkTags(canPush))
+	if canPush {
+		report.add(c.checkManifestSize())
+		report.add(c.checkMediaTypes())
+	} else {
+		for _, name := range []string{TargetCheckManifestSize, TargetCheckMediaTypes} {
+			report.add(TargetCheck{Name: name, Status: bundle.CheckSkipped, Summary: "images cannot be pushed"})
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	report.Timing = reportschema.TimingSince(start)
+	return report, nil
+}
+
+type targetChecker struct {
+	ctx        context.Context
+	mirrorCtx  *contexts.PushContext
+	rootRepo   string
+	nameOpts   []name.Option
+	remoteOpts []remote.Option
+}
+
+func (c *targetChecker) checkPush() TargetCheck {
+	img, err := random.Image(512, 1)
+	if err == nil {
+		err = c.write(c.rootRepo, img)
+	}
+	if err != nil {
+		return failedTargetCheck(TargetCheckPush, "images cannot be pushed to "+c.rootRepo, err.Error())
+	}
+	return TargetCheck{Name: TargetCheckPush, Status: bundle.CheckPassed, Summary: "images can be pushed to " + c.rootRepo}
+}
+
+// checkRepositories creates the first of repositories push writes to that does not exist yet.
+func (c *targetChecker) checkRepositories() TargetCheck {
+	missing := make([]string, 0)
+	for _, segment := range TargetSegments[1:] {
+		repo := targetRepository(c.mirrorCtx, c.mirrorCtx.RegistrySegment(segment))
+		if _, err := c.listTags(repo); errorutil.IsRepoNotFoundError(err) {
+			missing = append(missing, repo)
+		}
+	}
+	if len(missing) == 0 {
+		return TargetCheck{
+			Name:    TargetCheckRepositories,
+			Status:  bundle.CheckPassed,
+			Summary: fmt.Sprintf("all %d nested repositories already exist", len(TargetSegments)-1),
+		}
+	}
+
+	img, err := random.Image(512, 1)
+	if err == nil {
+		err = c.write(missing[0], img)
+	}
+	if err != nil {
+		return failedTargetCheck(TargetCheckRepositories, "repositories cannot be created", fmt.Sprintf("%s: %v", missing[0], err))
+	}
+	return TargetCheck{
+		Name:    TargetCheckRepositories,
+		Status:  bundle.CheckPassed,
+		Summary: fmt.Sprintf("repositories can be created, %d of %d nested repositories are missing", len(missing), len(TargetSegments)-1),
+	}
+}
+
+func (c *targetChecker) checkTags(pushed bool) TargetCheck {
+	tags, err := c.listTags(c.rootRepo)
+	if err != nil {
+		return failedTargetCheck(TargetCheckTags, "tags cannot be listed", err.Error())
+	}
+	if pushed && !slices.Contains(tags, writeCheckTag) {
+		return failedTargetCheck(TargetCheckTags, "tags are listed incompletely",
+			fmt.Sprintf("pushed tag %s is missing from %d tags listed", writeCheckTag, len(tags)))
+	}
+	return TargetCheck{Name: TargetCheckTags, Status: bundle.CheckPassed, Summary: fmt.Sprintf("%d tags listed", len(tags))}
+}
+
+func (c *targetChecker) checkManifestSize() TargetCheck {
+	img, err := paddedImage(TargetManifestSize)
+	if err == nil {
+		err = c.write(c.rootRepo, img)
+	}
+	if err != nil {
+		return failedTargetCheck(TargetCheckManifestSize, fmt.Sprintf("%d KiB manifests are rejected", TargetManifestSize/1024), err.Error())
+	}
+	return TargetCheck{
+		Name:    TargetCheckManifestSize,
+		Status:  bundle.CheckPassed,
+		Summary: fmt.Sprintf("%d KiB manifests are accepted", TargetManifestSize/1024),
+	}
+}
+
+func (c *targetChecker) checkMediaTypes() TargetCheck {
+	problems := make([]string, 0)
+	for _, kind := range targetManifestKinds {
+		if err := c.pushManifestKind(kind); err != nil {
+			problems = append(problems, fmt.Sprintf("%s (layer %s): %v", kind.Name, kind.Layer, err))
+		}
+	}
+	if len(problems) > 0 {
+		return failedTargetCheck(TargetCheckMediaTypes,
+			fmt.Sprintf("%d of %d kinds of manifests are rejected", len(problems), len(targetManifestKinds)), problems...)
+	}
+	return TargetCheck{
+		Name:    TargetCheckMediaTypes,
+		Status:  bundle.CheckPassed,
+		Summary: fmt.Sprintf("all %d kinds of manifests are accepted", len(targetManifestKinds)),
+	}
+}
+
+func (c *targetChecker) pushManifestKind(kind manifestKind) error {
+	layer, err := random.Layer(256, kind.Layer)
+	if err != nil {
+		return err
+	}
+	img, err := mutate.AppendLayers(mutate.ConfigMediaType(mutate.MediaType(empty.Image, kind.Manifest), kind.Config), layer)
+	if err != nil {
+		return err
+	}
+	if kind.Index == "" {
+		return c.write(c.rootRepo, img)
+	}
+
+	index := mutate.IndexMediaType(mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add:        img,
+		Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+	}), kind.Index)
+	ref, err := name.NewTag(c.rootRepo+":"+writeCheckTag, c.nameOpts...)
+	if err != nil {
+		return err
+	}
+	return remote.WriteIndex(ref, index, c.remoteOpts...)
+}
+
+func (c *targetChecker) write(repo string, img v1.Image) error {
+	ref, err := name.NewTag(repo+":"+writeCheckTag, c.nameOpts...)
+	if err != nil {
+		return err
+	}
+	return remote.Write(ref, img, c.remoteOpts...)
+}
+
+func (c *targetChecker) listTags(repo string) ([]string, error) {
+	parsedRepo, err := name.NewRepository(repo, c.nameOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return remote.List(parsedRepo, c.remoteOpts...)
+}
+
+// paddedImage returns small image with manifest of exactly manifestSize bytes, padded with annotation.
+func paddedImage(manifestSize int) (v1.Image, error) {
+	img, err := random.Image(512, 1)
+	if err != nil {
+		return nil, err
+	}
+	const paddingAnnotation = "io.deckhouse.check-target.padding"
+	unpadded, err := mutate.Annotations(img, map[string]string{paddingAnnotation: ""}).(v1.Image).RawManifest()
+	if err != nil {
+		return nil, err
+	}
+	padding := strings.Repeat("0", max(manifestSize-len(unpadded), 0))
+	return mutate.Annotations(img, map[string]string{paddingAnnotation: padding}).(v1.Image), nil
+}
+
+func failedTargetCheck(name, summary string, problems ...string) TargetCheck {
+	return TargetCheck{Name: name, Status: bundle.CheckFailed, Summary: summary, Problems: problems}
+}
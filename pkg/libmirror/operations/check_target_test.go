@@ -0,0 +1,122 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	mirrorTestUtils "github.com/deckhouse/deckhouse-cli/testing/util/mirror"
+)
+
+func TestCheckTarget(t *testing.T) {
+	reg := mirrorTestUtils.SetupTestRegistry(mirrorTestUtils.WithBasicAuth("pusher", "secret"))
+	defer reg.Server.Close()
+
+	pushCtx := &contexts.PushContext{BaseContext: contexts.BaseContext{
+		Logger:       log.NewSLogger(slog.LevelDebug),
+		Insecure:     true,
+		RegistryHost: reg.Host,
+		RegistryPath: reg.RepoPath,
+		RegistryAuth: reg.Auth,
+	}}
+	report, err := CheckTarget(context.Background(), pushCtx)
+	require.NoError(t, err)
+	require.True(t, report.Passed(), "%+v", report)
+	require.Equal(t, []string{
+		TargetCheckPush, TargetCheckRepositories, TargetCheckTags, TargetCheckManifestSize, TargetCheckMediaTypes,
+	}, targetCheckNames(report))
+	require.Contains(t, report.Checks[1].Summary, "8 of 8 nested repositories are missing")
+
+	pushCtx.RegistryAuth = authn.FromConfig(authn.AuthConfig{Username: "pusher", Password: "wrong"})
+	report, err = CheckTarget(context.Background(), pushCtx)
+	require.NoError(t, err)
+	require.False(t, report.Passed())
+	for _, check := range report.Checks {
+		switch check.Name {
+		case TargetCheckPush, TargetCheckTags:
+			require.Equal(t, bundle.CheckFailed, check.Status, check.Name)
+		default:
+			require.Equal(t, bundle.CheckSkipped, check.Status, check.Name)
+		}
+	}
+}
+
+func TestCheckTargetReportsRejectedManifests(t *testing.T) {
+	handler := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || !strings.Contains(r.URL.Path, "/manifests/") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		manifest, _ := io.ReadAll(r.Body)
+		if len(manifest) > 1024*1024 || bytes.Contains(manifest, []byte("vnd.aquasec.trivy")) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"MANIFEST_INVALID","message":"manifest invalid"}]}`))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(manifest))
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	report, err := CheckTarget(context.Background(), &contexts.PushContext{BaseContext: contexts.BaseContext{
+		Logger:       log.NewSLogger(slog.LevelDebug),
+		Insecure:     true,
+		RegistryHost: strings.TrimPrefix(server.URL, "http://"),
+		RegistryPath: "/deckhouse/ee",
+	}})
+	require.NoError(t, err)
+	require.False(t, report.Passed())
+
+	statuses := map[string]bundle.CheckStatus{}
+	for _, check := range report.Checks {
+		statuses[check.Name] = check.Status
+	}
+	require.Equal(t, map[string]bundle.CheckStatus{
+		TargetCheckPush:         bundle.CheckPassed,
+		TargetCheckRepositories: bundle.CheckPassed,
+		TargetCheckTags:         bundle.CheckPassed,
+		TargetCheckManifestSize: bundle.CheckFailed,
+		TargetCheckMediaTypes:   bundle.CheckFailed,
+	}, statuses)
+	mediaTypes := report.Checks[4]
+	require.Len(t, mediaTypes.Problems, 2)
+	require.True(t, strings.HasPrefix(mediaTypes.Problems[0], "Trivy DB"), mediaTypes.Problems[0])
+	require.True(t, strings.HasPrefix(mediaTypes.Problems[1], "Trivy Java DB"), mediaTypes.Problems[1])
+}
+
+func targetCheckNames(report *TargetCheckReport) []string {
+	names := make([]string, 0, len(report.Checks))
+	for _, check := range report.Checks {
+		names = append(names, check.Name)
+	}
+	return names
+}
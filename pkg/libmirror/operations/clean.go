@@ -0,0 +1,153 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/workdir"
+)
+
+const (
+	LeftoverWorkDir          = "working directory"
+	LeftoverUnfinishedBundle = "unfinished bundle"
+)
+
+// Leftover is a working directory or an unfinished bundle left behind by failed or killed run of d8.
+type Leftover struct {
+	Kind string
+	// Path is the working directory or the bundle tar, even if only its chunks were written.
+	Path string
+	// Files are removed along with Path: chunks of unfinished bundle and its packing marker.
+	Files     []string
+	Size      int64
+	CreatedAt time.Time
+	// Resumable working directories let next run of the command that created them pick up from where it stopped.
+	Resumable bool
+}
+
+// FindLeftovers walks root looking for working directories and unfinished bundles of previous d8 runs.
+// Only the ones marked with workdir.Marker are recognized, so foreign files are never reported.
+// Leftovers of runs that may still be in progress are returned separately as inUse.
+func FindLeftovers(root string) (leftovers, inUse []Leftover, err error) {
+	leftovers, inUse = make([]Leftover, 0), make([]Leftover, 0)
+	addLeftover := func(leftover Leftover, marker *workdir.Marker) {
+		if marker != nil {
+			leftover.CreatedAt, leftover.Resumable = marker.CreatedAt, marker.Resumable
+			if marker.InUse() {
+				inUse = append(inUse, leftover)
+				return
+			}
+		}
+		leftovers = append(leftovers, leftover)
+	}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			markerPath := filepath.Join(path, workdir.MarkerFile)
+			if _, err = os.Lstat(markerPath); err != nil {
+				return nil
+			}
+			size, err := pathsSize(path)
+			if err != nil {
+				return err
+			}
+			addLeftover(Leftover{Kind: LeftoverWorkDir, Path: path, Size: size}, readMarker(markerPath))
+			return filepath.SkipDir
+		}
+
+		bundlePath, isPackingMarker := strings.CutSuffix(path, bundle.PackingMarkerSuffix)
+		if !isPackingMarker || !d.Type().IsRegular() {
+			return nil
+		}
+		files, err := bundle.FindBundleFiles(bundlePath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		files = append(files, path)
+		size, err := pathsSize(files...)
+		if err != nil {
+			return err
+		}
+		addLeftover(Leftover{Kind: LeftoverUnfinishedBundle, Path: bundlePath, Files: files, Size: size}, readMarker(path))
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("find leftovers in %s: %w", root, err)
+	}
+	return leftovers, inUse, nil
+}
+
+// readMarker returns nil for markers that cannot be read, e.g. when process was killed while writing them.
+func readMarker(markerPath string) *workdir.Marker {
+	marker, err := workdir.ReadMarker(markerPath)
+	if err != nil {
+		return nil
+	}
+	return marker
+}
+
+// RemoveLeftover removes files and directories of leftover.
+func RemoveLeftover(leftover Leftover) error {
+	if leftover.Kind == LeftoverWorkDir {
+		if err := os.RemoveAll(leftover.Path); err != nil {
+			return fmt.Errorf("remove %s: %w", leftover.Path, err)
+		}
+		return nil
+	}
+	for _, file := range leftover.Files {
+		if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func pathsSize(paths ...string) (int64, error) {
+	size := int64(0)
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("calculate size of %s: %w", root, err)
+		}
+	}
+	return size, nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/workdir"
+)
+
+func TestFindLeftoversOfFinishedRuns(t *testing.T) {
+	root := t.TempDir()
+	workDirs := workdir.NewManager(filepath.Join(root, "mirror"), false, log.NewSLogger(slog.LevelDebug))
+	running, err := workDirs.Create("unpack")
+	require.NoError(t, err)
+	failed, err := workDirs.CreateResumable("pull")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(failed.Path, "index.json"), make([]byte, 100), 0o644))
+	writeDeadProcessMarker(t, filepath.Join(failed.Path, workdir.MarkerFile), true)
+
+	bundlePath := filepath.Join(root, "d8.tar")
+	require.NoError(t, os.WriteFile(bundlePath+".0000.chunk", make([]byte, 1000), 0o644))
+	writeDeadProcessMarker(t, bundlePath+bundle.PackingMarkerSuffix, false)
+	foreignPath := filepath.Join(root, "notes.tar")
+	require.NoError(t, os.WriteFile(foreignPath+".0000.chunk", make([]byte, 10), 0o644))
+
+	leftovers, inUse, err := FindLeftovers(root)
+	require.NoError(t, err)
+	require.Len(t, inUse, 1)
+	require.Equal(t, running.Path, inUse[0].Path)
+	require.Len(t, leftovers, 2)
+
+	paths := map[string]Leftover{}
+	for _, leftover := range leftovers {
+		paths[leftover.Path] = leftover
+		require.NoError(t, RemoveLeftover(leftover))
+	}
+	require.Equal(t, LeftoverUnfinishedBundle, paths[bundlePath].Kind)
+	require.Greater(t, paths[bundlePath].Size, int64(1000))
+	require.Equal(t, LeftoverWorkDir, paths[failed.Path].Kind)
+	require.Greater(t, paths[failed.Path].Size, int64(100))
+	require.True(t, paths[failed.Path].Resumable)
+	require.False(t, paths[bundlePath].Resumable)
+
+	require.NoDirExists(t, failed.Path)
+	require.NoFileExists(t, bundlePath+".0000.chunk")
+	require.NoFileExists(t, bundlePath+bundle.PackingMarkerSuffix)
+	require.DirExists(t, running.Path)
+	require.FileExists(t, foreignPath+".0000.chunk")
+}
+
+func writeDeadProcessMarker(t *testing.T, markerPath string, resumable bool) {
+	t.Helper()
+
+	process := exec.Command("true")
+	require.NoError(t, process.Run())
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	rawMarker, err := json.Marshal(&workdir.Marker{PID: process.Process.Pid, Hostname: hostname, CreatedAt: time.Now(), Resumable: resumable})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(markerPath, rawMarker, 0o644))
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+)
+
+// CopyDeckhouseToRegistry streams planned images from source registry straight into target registry without storing them locally.
+// Every image is pushed into the repository and under the tag d8 mirror push would give it if it was pulled into a bundle,
+// multi-platform images are copied as image indexes thinned to pulled platforms, if any are requested.
+// Images are copied with up to sourceCtx.Concurrency at once, along with their cosign artifacts if sourceCtx.IncludeCosign is set.
+// Images that target already has under their tag are skipped, so that interrupted copy is resumed by running it again.
+func CopyDeckhouseToRegistry(
+	ctx context.Context,
+	sourceCtx *contexts.PullContext,
+	targetCtx *contexts.PushContext,
+	images []layouts.PlannedImage,
+) error {
+	logger := targetCtx.Logger
+
+	// Layouts are never read here, resolver only needs their paths.
+	segments := map[string]layout.Path{}
+	modulesList := make([]string, 0)
+	for _, image := range images {
+		if _, found := segments[image.Layout]; found {
+			continue
+		}
+		segments[image.Layout] = ""
+		if moduleName, found := strings.CutPrefix(image.Layout, contexts.DefaultModulesPathSuffix+"/"); found && !strings.Contains(moduleName, "/") {
+			modulesList = append(modulesList, moduleName)
+		}
+	}
+	sort.Strings(modulesList)
+
+	targetRepo, err := targetRepositoryResolver(targetCtx, segments, modulesList)
+	if err != nil {
+		return err
+	}
+
+	c := &imageCopier{sourceCtx: sourceCtx, targetCtx: targetCtx, targetRepo: targetRepo, total: len(images)}
+	c.sourceNameOpts, c.sourceRemoteOpts = auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&sourceCtx.BaseContext)
+	c.targetNameOpts, c.targetRemoteOpts = auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&targetCtx.BaseContext)
+
+	targetCtx.Run.AddTotal(contexts.StagePush, len(images))
+	copies, copyCtx := errgroup.WithContext(ctx)
+	copies.SetLimit(max(sourceCtx.Concurrency, 1))
+	for _, image := range images {
+		copies.Go(func() error {
+			return c.copy(copyCtx, image)
+		})
+	}
+	if err = copies.Wait(); err != nil {
+		return err
+	}
+	logger.InfoF("Copied %d images, %d were already present in target", c.copied.Load(), int64(len(images))-c.copied.Load())
+
+	if len(modulesList) > 0 {
+		logger.InfoLn("Pushing modules tags")
+		if err = pushModulesTags(ctx, &targetCtx.BaseContext, targetRepo(contexts.DefaultModulesPathSuffix), modulesList); err != nil {
+			return fmt.Errorf("Push modules tags: %w", err)
+		}
+	}
+	return nil
+}
+
+// imageCopier copies images of a plan from source to target registry, possibly several at once.
+type imageCopier struct {
+	sourceCtx  *contexts.PullContext
+	targetCtx  *contexts.PushContext
+	targetRepo func(segment string) string
+
+	sourceNameOpts   []name.Option
+	sourceRemoteOpts []remote.Option
+	targetNameOpts   []name.Option
+	targetRemoteOpts []remote.Option
+
+	total   int
+	started atomic.Int64
+	copied  atomic.Int64
+}
+
+func (c *imageCopier) copy(ctx context.Context, image layouts.PlannedImage) error {
+	logger := c.targetCtx.Logger
+	n := c.started.Add(1)
+	targetRemoteOpts := append(c.targetRemoteOpts, remote.WithContext(ctx))
+
+	// Images are tagged in target the way pull tags them in bundle, by the part of reference after the last colon.
+	tag := image.Reference[strings.LastIndex(image.Reference, ":")+1:]
+	targetRef, err := name.NewTag(c.targetRepo(image.Layout)+":"+tag, c.targetNameOpts...)
+	if err != nil {
+		return fmt.Errorf("Parse target reference for %q: %w", image.Reference, err)
+	}
+
+	if desc, err := remote.Head(targetRef, targetRemoteOpts...); err == nil && desc.Digest.String() == image.Digest {
+		logger.DebugF("[%d / %d] %s is already present in target", n, c.total, targetRef)
+	} else {
+		logger.InfoF("[%d / %d] Copying %s to %s", n, c.total, image.Reference, targetRef)
+		taggable, err := layouts.FetchPlannedImage(ctx, c.sourceCtx, image, c.sourceRemoteOpts...)
+		if err != nil {
+			return fmt.Errorf("Read image %q: %w", image.Reference, err)
+		}
+		// Layers of remote image are read from source registry only as they are uploaded to target.
+		if err = writeTaggable(targetRef, taggable, targetRemoteOpts); err != nil {
+			return fmt.Errorf("Write image %q to %s: %w", image.Reference, targetRef, err)
+		}
+		c.copied.Add(1)
+	}
+
+	if err = c.copyCosignArtifacts(ctx, image, targetRef.Context()); err != nil {
+		return fmt.Errorf("Copy cosign artifacts of %q: %w", image.Reference, err)
+	}
+	c.targetCtx.Run.Advance(contexts.StagePush, 1)
+	return nil
+}
+
+// copyCosignArtifacts copies signatures, attestations and SBOMs that cosign attached to image in source registry
+// into targetRepo under the same tags, if they were requested. Images may have none or only some of them.
+func (c *imageCopier) copyCosignArtifacts(ctx context.Context, image layouts.PlannedImage, targetRepo name.Repository) error {
+	if !c.sourceCtx.IncludeCosign {
+		return nil
+	}
+	sourceRepo, sourceDigest := image.Source()
+	digest, err := v1.NewHash(sourceDigest)
+	if err != nil {
+		return fmt.Errorf("Parse image digest: %w", err)
+	}
+	sourceRemoteOpts := append(c.sourceRemoteOpts, remote.WithContext(ctx))
+	targetRemoteOpts := append(c.targetRemoteOpts, remote.WithContext(ctx))
+
+	for _, tag := range signature.CosignArtifactTags(digest) {
+		sourceRef, err := name.NewTag(sourceRepo+":"+tag, c.sourceNameOpts...)
+		if err != nil {
+			return fmt.Errorf("Parse image reference: %w", err)
+		}
+		artifact, err := remote.Image(sourceRef, sourceRemoteOpts...)
+		if errorutil.IsImageNotFoundError(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("Read %s: %w", sourceRef, err)
+		}
+		artifactDigest, err := artifact.Digest()
+		if err != nil {
+			return fmt.Errorf("Read %s digest: %w", sourceRef, err)
+		}
+
+		targetRef := targetRepo.Tag(tag)
+		if desc, err := remote.Head(targetRef, targetRemoteOpts...); err == nil && desc.Digest == artifactDigest {
+			continue
+		}
+		if err = remote.Write(targetRef, artifact, targetRemoteOpts...); err != nil {
+			return fmt.Errorf("Write %s: %w", targetRef, err)
+		}
+	}
+	return nil
+}
+
+func writeTaggable(ref name.Reference, taggable remote.Taggable, remoteOpts []remote.Option) error {
+	switch t := taggable.(type) {
+	case v1.ImageIndex:
+		return remote.WriteIndex(ref, t, remoteOpts...)
+	case v1.Image:
+		return remote.Write(ref, t, remoteOpts...)
+	default:
+		return fmt.Errorf("unexpected image type %T", taggable)
+	}
+}
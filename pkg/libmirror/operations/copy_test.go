@@ -0,0 +1,129 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+)
+
+func TestCopyDeckhouseToRegistry(t *testing.T) {
+	sourceServer := httptest.NewServer(registry.New())
+	defer sourceServer.Close()
+	targetServer := httptest.NewServer(registry.New())
+	defer targetServer.Close()
+	sourceRepo := strings.TrimPrefix(sourceServer.URL, "http://") + "/deckhouse/ee"
+	targetHost := strings.TrimPrefix(targetServer.URL, "http://")
+
+	writeImage := func(ref string) v1.Hash {
+		img, err := random.Image(256, 2)
+		require.NoError(t, err)
+		parsedRef, err := name.ParseReference(ref, name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(parsedRef, img))
+		digest, err := img.Digest()
+		require.NoError(t, err)
+		return digest
+	}
+	rootDigest := writeImage(sourceRepo + ":v1.60.0")
+	installerDigest := writeImage(sourceRepo + "/install:v1.60.0")
+	moduleDigest := writeImage(sourceRepo + "/modules/console:v1.2.0")
+	signatureDigest := writeImage(sourceRepo + "/install:sha256-" + installerDigest.Hex + ".sig")
+
+	amd64, err := random.Image(256, 1)
+	require.NoError(t, err)
+	arm64, err := random.Image(256, 1)
+	require.NoError(t, err)
+	multiPlatform := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+	)
+	standaloneRef, err := name.ParseReference(sourceRepo+"/install-standalone:v1.60.0", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(standaloneRef, multiPlatform))
+	sourceIndexDigest, err := multiPlatform.Digest()
+	require.NoError(t, err)
+	thinnedIndexDigest, err := mutate.RemoveManifests(multiPlatform, func(desc v1.Descriptor) bool {
+		return desc.Platform.Architecture != "amd64"
+	}).Digest()
+	require.NoError(t, err)
+
+	images := []layouts.PlannedImage{
+		{Layout: "", Reference: sourceRepo + "@" + rootDigest.String(), Digest: rootDigest.String()},
+		{Layout: "install", Reference: sourceRepo + "/install:v1.60.0", Digest: installerDigest.String()},
+		{
+			Layout:       "install-standalone",
+			Reference:    sourceRepo + "/install-standalone:v1.60.0",
+			Digest:       thinnedIndexDigest.String(),
+			SourceDigest: sourceIndexDigest.String(),
+		},
+		{Layout: "modules/console", Reference: sourceRepo + "/modules/console:v1.2.0", Digest: moduleDigest.String()},
+	}
+
+	logger := log.NewSLogger(slog.LevelDebug)
+	sourceCtx := &contexts.PullContext{
+		BaseContext:   contexts.BaseContext{Logger: logger, Insecure: true},
+		Concurrency:   2,
+		Platforms:     []v1.Platform{{OS: "linux", Architecture: "amd64"}},
+		IncludeCosign: true,
+	}
+	targetCtx := &contexts.PushContext{BaseContext: contexts.BaseContext{
+		Logger:       logger,
+		Insecure:     true,
+		RegistryHost: targetHost,
+		RegistryPath: "/mirror",
+	}}
+	require.NoError(t, CopyDeckhouseToRegistry(context.Background(), sourceCtx, targetCtx, images))
+
+	for ref, digest := range map[string]v1.Hash{
+		targetHost + "/mirror:" + rootDigest.Hex:      rootDigest,
+		targetHost + "/mirror/install:v1.60.0":        installerDigest,
+		targetHost + "/mirror/modules/console:v1.2.0": moduleDigest,
+
+		targetHost + "/mirror/install-standalone:v1.60.0":                     thinnedIndexDigest,
+		targetHost + "/mirror/install:sha256-" + installerDigest.Hex + ".sig": signatureDigest,
+	} {
+		parsedRef, err := name.ParseReference(ref, name.Insecure)
+		require.NoError(t, err)
+		desc, err := remote.Head(parsedRef)
+		require.NoError(t, err, ref)
+		require.Equal(t, digest, desc.Digest, ref)
+	}
+	modulesTag, err := name.ParseReference(targetHost+"/mirror/modules:console", name.Insecure)
+	require.NoError(t, err)
+	_, err = remote.Head(modulesTag)
+	require.NoError(t, err, "modules must be listed in modules repository")
+
+	require.NoError(t, CopyDeckhouseToRegistry(context.Background(), sourceCtx, targetCtx, images),
+		"copy must be repeatable once target has all images")
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flatten"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/harbor"
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+// TargetSegments are paths of repositories relative to Deckhouse repo that d8 mirror push creates in target registry.
+// Repositories of modules are not known until bundle is pushed and are created by push itself.
+var TargetSegments = append(contexts.EnterpriseEdition.Segments(), "modules")
+
+// TargetReadinessReport describes whether target registry is prepared for d8 mirror push.
+type TargetReadinessReport struct {
+	reportschema.Header
+	Timing reportschema.Timing `json:"timing"`
+
+	Registry string `json:"registry"`
+	Ready    bool   `json:"ready"`
+
+	Harbor        bool   `json:"harbor"`
+	HarborProject string `json:"harborProject,omitempty"`
+	// HarborProjectCreated is set if Harbor project did not exist and was created.
+	HarborProjectCreated bool   `json:"harborProjectCreated,omitempty"`
+	HarborProjectError   string `json:"harborProjectError,omitempty"`
+
+	Repositories []RepositoryReadiness `json:"repositories"`
+}
+
+type RepositoryReadiness struct {
+	Segment    string `json:"segment"`
+	Repository string `json:"repository"`
+	Writable   bool   `json:"writable"`
+	Error      string `json:"error,omitempty"`
+}
+
+// InitTarget prepares target registry for pushes: creates Harbor project if registry is Harbor,
+// and creates every repository push writes to by uploading small write check image into it,
+// which also validates push permission. Failures are recorded in the report instead of aborting initialization,
+// so that every problem with the target can be seen at once.
+func InitTarget(ctx context.Context, mirrorCtx *contexts.PushContext) (*TargetReadinessReport, error) {
+	start := time.Now()
+	logger := mirrorCtx.Logger
+	rootRepo := mirrorCtx.RegistryHost + mirrorCtx.RegistryPath
+	report := &TargetReadinessReport{
+		Header:       reportschema.NewHeader(reportschema.KindTargetReadinessReport),
+		Registry:     rootRepo,
+		Ready:        true,
+		Repositories: make([]RepositoryReadiness, 0, len(TargetSegments)),
+	}
+
+	isHarbor, err := harbor.Detect(ctx, mirrorCtx.RegistryHost, mirrorCtx.Insecure, mirrorCtx.SkipTLSVerification)
+	if err != nil {
+		logger.DebugF("Harbor detection failed: %v", err)
+	}
+	if isHarbor {
+		report.Harbor = true
+		report.HarborProject, _, _ = strings.Cut(strings.Trim(mirrorCtx.RegistryPath, "/"), "/")
+		report.HarborProjectCreated, err = harbor.EnsureProject(
+			ctx,
+			mirrorCtx.RegistryHost,
+			report.HarborProject,
+			mirrorCtx.RegistryAuth,
+			mirrorCtx.Insecure,
+			mirrorCtx.SkipTLSVerification,
+		)
+		switch {
+		case err != nil:
+			report.Ready = false
+			report.HarborProjectError = err.Error()
+			logger.WarnF("Harbor project %s is not ready: %v", report.HarborProject, err)
+		case report.HarborProjectCreated:
+			logger.InfoF("Harbor project %s is created", report.HarborProject)
+		default:
+			logger.InfoF("Harbor project %s already exists", report.HarborProject)
+		}
+	}
+
+	for _, segment := range TargetSegments {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		segment = mirrorCtx.RegistrySegment(segment)
+		repo := targetRepository(mirrorCtx, segment)
+
+		readiness := RepositoryReadiness{Segment: segment, Repository: repo, Writable: true}
+		err = auth.ValidateWriteAccessForRepoContext(ctx, repo, mirrorCtx.RegistryAuth, mirrorCtx.Insecure, mirrorCtx.SkipTLSVerification)
+		if err != nil {
+			readiness.Writable = false
+			readiness.Error = err.Error()
+			report.Ready = false
+			logger.WarnF("Repository %s is not writable: %v", repo, err)
+		} else {
+			logger.InfoF("Repository %s is ready", repo)
+		}
+		report.Repositories = append(report.Repositories, readiness)
+	}
+
+	report.Timing = reportschema.TimingSince(start)
+	return report, nil
+}
+
+// targetRepository returns repository of target registry that d8 mirror push writes images of registry segment to.
+func targetRepository(mirrorCtx *contexts.PushContext, segment string) string {
+	rootRepo := mirrorCtx.RegistryHost + mirrorCtx.RegistryPath
+	if mirrorCtx.FlattenRepositories {
+		return flatten.RepositoryName(rootRepo, segment)
+	}
+	return path.Join(rootRepo, segment)
+}
+
+// Save writes report as JSON file to the given path.
+func (r *TargetReadinessReport) Save(filePath string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Marshal target readiness report: %w", err)
+	}
+	if err = os.WriteFile(filePath, data, 0o644); err != nil {
+		return fmt.Errorf("Write target readiness report: %w", err)
+	}
+	return nil
+}
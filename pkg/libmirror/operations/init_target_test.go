@@ -0,0 +1,71 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	mirrorTestUtils "github.com/deckhouse/deckhouse-cli/testing/util/mirror"
+)
+
+func TestInitTarget(t *testing.T) {
+	reg := mirrorTestUtils.SetupTestRegistry(mirrorTestUtils.WithBasicAuth("pusher", "secret"))
+	defer reg.Server.Close()
+
+	pushCtx := &contexts.PushContext{
+		BaseContext: contexts.BaseContext{
+			Logger:       log.NewSLogger(slog.LevelDebug),
+			Insecure:     true,
+			RegistryHost: reg.Host,
+			RegistryPath: reg.RepoPath,
+			RegistryAuth: reg.Auth,
+		},
+		FlattenRepositories: true,
+	}
+	report, err := InitTarget(context.Background(), pushCtx)
+	require.NoError(t, err)
+	require.True(t, report.Ready, "%+v", report)
+	require.False(t, report.Harbor)
+	require.Len(t, report.Repositories, len(TargetSegments))
+	require.Equal(t, reg.Host+reg.RepoPath, report.Repositories[0].Repository)
+	require.Regexp(t, `-install-[0-9a-f]{8}$`, report.Repositories[1].Repository)
+
+	pushCtx.FlattenRepositories = false
+	pushCtx.ModulesPathSuffix = "addons/modules"
+	report, err = InitTarget(context.Background(), pushCtx)
+	require.NoError(t, err)
+	require.True(t, report.Ready, "%+v", report)
+	modulesReadiness := report.Repositories[len(report.Repositories)-1]
+	require.Equal(t, "addons/modules", modulesReadiness.Segment)
+	require.Equal(t, reg.Host+reg.RepoPath+"/addons/modules", modulesReadiness.Repository)
+
+	pushCtx.RegistryAuth = authn.FromConfig(authn.AuthConfig{Username: "pusher", Password: "wrong"})
+	report, err = InitTarget(context.Background(), pushCtx)
+	require.NoError(t, err)
+	require.False(t, report.Ready)
+	for _, repo := range report.Repositories {
+		require.False(t, repo.Writable)
+		require.NotEmpty(t, repo.Error)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flatten"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/signature"
+)
+
+// PruneSegments are paths of repositories relative to Deckhouse repo whose version tags are pruned by PruneOldPatches.
+var PruneSegments = contexts.EnterpriseEdition.PlatformSegments()
+
+var (
+	patchVersionTagRegexp = regexp.MustCompile(`^v\d+\.\d+\.\d+$`)
+	digestTagRegexp       = regexp.MustCompile(`^[a-f0-9]{64}$`)
+)
+
+// PrunedTag is version tag that was deleted from the target registry, or would be deleted in dry run.
+type PrunedTag struct {
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+func (t PrunedTag) String() string {
+	return t.Repository + ":" + t.Tag + "@" + t.Digest
+}
+
+// PruneOldPatches deletes version tags from Deckhouse repositories of the target registry
+// that are older than keepPatches newest patches of their minor release.
+// Registries delete manifests by digest, which removes every tag of the image, so tags referring to the same image
+// as any tag that is kept, like release channel tags or newer patches, are not pruned.
+// In dry run nothing is deleted and the tags that would be pruned are only returned.
+func PruneOldPatches(ctx context.Context, mirrorCtx *contexts.PushContext, keepPatches int, dryRun bool) ([]PrunedTag, error) {
+	if keepPatches < 1 {
+		return nil, fmt.Errorf("At least one patch of every minor release should be kept, got %d", keepPatches)
+	}
+
+	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&mirrorCtx.BaseContext)
+	remoteOpts = append(remoteOpts, remote.WithContext(ctx))
+	rootRepo := mirrorCtx.RegistryHost + mirrorCtx.RegistryPath
+
+	pruned := make([]PrunedTag, 0)
+	for _, segment := range PruneSegments {
+		segment = mirrorCtx.RegistrySegment(segment)
+		repoName := path.Join(rootRepo, segment)
+		if mirrorCtx.FlattenRepositories {
+			repoName = flatten.RepositoryName(rootRepo, segment)
+		}
+		repo, err := name.NewRepository(repoName, nameOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("Parse repository %q: %w", repoName, err)
+		}
+
+		tags, err := mirrorCtx.Run.TagLister().List(ctx, repo, auth.MakeTransport(mirrorCtx.SkipTLSVerification), remoteOpts...)
+		if errorutil.IsRepoNotFoundError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("List tags of %s: %w", repoName, err)
+		}
+		toPrune := oldPatchTags(tags, keepPatches)
+		if len(toPrune) == 0 {
+			continue
+		}
+
+		digests, err := tagDigests(ctx, repo, tags, mirrorCtx.Parallelism.Blobs, remoteOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		keptDigests := map[string]struct{}{}
+		for tag, digest := range digests {
+			if _, prune := toPrune[tag]; !prune {
+				keptDigests[digest] = struct{}{}
+			}
+		}
+
+		deletedDigests := map[string]struct{}{}
+		for _, tag := range sortedTags(toPrune) {
+			digest := digests[tag]
+			if _, kept := keptDigests[digest]; kept {
+				mirrorCtx.Logger.InfoF("Keeping %s:%s as it refers to the same image as one of the kept tags", repoName, tag)
+				continue
+			}
+
+			prunedTag := PrunedTag{Repository: repoName, Tag: tag, Digest: digest}
+			pruned = append(pruned, prunedTag)
+			if dryRun {
+				continue
+			}
+			if _, deleted := deletedDigests[digest]; !deleted {
+				if err = remote.Delete(repo.Digest(digest), remoteOpts...); err != nil {
+					return pruned[:len(pruned)-1], fmt.Errorf("Delete %s: %w", prunedTag, err)
+				}
+				deletedDigests[digest] = struct{}{}
+			}
+			mirrorCtx.Run.RecordTagPruned(contexts.StagePush, prunedTag.String())
+		}
+	}
+
+	return pruned, nil
+}
+
+// oldPatchTags returns version tags that are older than keepPatches newest patches of their minor release.
+// Tags that are not release versions, like release channels or pre-releases, are never returned.
+func oldPatchTags(tags []string, keepPatches int) map[string]struct{} {
+	minors := map[string][]*semver.Version{}
+	for _, tag := range tags {
+		if !patchVersionTagRegexp.MatchString(tag) {
+			continue
+		}
+		version, err := semver.NewVersion(tag)
+		if err != nil {
+			continue
+		}
+		minor := fmt.Sprintf("%d.%d", version.Major(), version.Minor())
+		minors[minor] = append(minors[minor], version)
+	}
+
+	old := map[string]struct{}{}
+	for _, versions := range minors {
+		sort.Sort(sort.Reverse(semver.Collection(versions)))
+		for _, version := range versions[min(keepPatches, len(versions)):] {
+			old[version.Original()] = struct{}{}
+		}
+	}
+	return old
+}
+
+// tagDigests looks up digests of repository tags with up to concurrency HEAD requests at a time.
+// Digest-named tags are not requested as their names are the digests already, cosign artifacts tags are skipped
+// since they never refer to release images.
+func tagDigests(ctx context.Context, repo name.Repository, tags []string, concurrency int, remoteOpts []remote.Option) (map[string]string, error) {
+	digests := make(map[string]string, len(tags))
+	mu := sync.Mutex{}
+	errs, ctx := errgroup.WithContext(ctx)
+	errs.SetLimit(max(concurrency, 1))
+	for _, tag := range tags {
+		if digestTagRegexp.MatchString(tag) {
+			digests[tag] = "sha256:" + tag
+			continue
+		}
+		if signature.IsCosignArtifactTag(tag) {
+			continue
+		}
+
+		errs.Go(func() error {
+			desc, err := remote.Head(repo.Tag(tag), append(remoteOpts, remote.WithContext(ctx))...)
+			if err != nil {
+				return fmt.Errorf("Get digest of %s:%s: %w", repo.Name(), tag, err)
+			}
+			mu.Lock()
+			digests[tag] = desc.Digest.String()
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := errs.Wait(); err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
+
+func sortedTags(tags map[string]struct{}) []string {
+	sorted := make([]string, 0, len(tags))
+	for tag := range tags {
+		sorted = append(sorted, tag)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
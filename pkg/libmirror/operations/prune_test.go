@@ -0,0 +1,154 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"io"
+	golog "log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	mirrorTestUtils "github.com/deckhouse/deckhouse-cli/testing/util/mirror"
+)
+
+func TestPruneOldPatches(t *testing.T) {
+	reg := mirrorTestUtils.SetupTestRegistry()
+	defer reg.Server.Close()
+	repo := reg.Host + reg.RepoPath
+
+	v1600, v1601, v1602, v1610 := randomImage(t), randomImage(t), randomImage(t), randomImage(t)
+	for tag, img := range map[string]v1.Image{
+		"v1.60.0": v1600,
+		"v1.60.1": v1601,
+		"v1.60.2": v1602,
+		"v1.61.0": v1610,
+		"stable":  v1600,
+		"alpha":   v1610,
+	} {
+		require.NoError(t, remote.Write(parseReference(t, repo+":"+tag), img))
+	}
+	v1601Digest, err := v1601.Digest()
+	require.NoError(t, err)
+
+	pushCtx := &contexts.PushContext{
+		BaseContext: contexts.BaseContext{
+			Logger:       log.NewSLogger(slog.LevelDebug),
+			Insecure:     true,
+			RegistryHost: reg.Host,
+			RegistryPath: reg.RepoPath,
+		},
+	}
+
+	pruned, err := PruneOldPatches(context.Background(), pushCtx, 1, true)
+	require.NoError(t, err)
+	require.Equal(t, []PrunedTag{{Repository: repo, Tag: "v1.60.1", Digest: v1601Digest.String()}}, pruned)
+	_, err = remote.Head(parseReference(t, repo+"@"+v1601Digest.String()))
+	require.NoError(t, err, "dry run should not delete anything")
+
+	pruned, err = PruneOldPatches(context.Background(), pushCtx, 1, false)
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+	_, err = remote.Head(parseReference(t, repo+"@"+v1601Digest.String()))
+	require.Error(t, err)
+
+	_, err = PruneOldPatches(context.Background(), pushCtx, 0, true)
+	require.Error(t, err)
+}
+
+func TestPruneOldPatchesSkipsDigestTags(t *testing.T) {
+	heads := atomic.Int32{}
+	registryHandler := registry.New(registry.Logger(golog.New(io.Discard, "", 0)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+		}
+		registryHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	repo := host + "/deckhouse/ee"
+
+	v1600, v1601 := randomImage(t), randomImage(t)
+	v1600Digest, err := v1600.Digest()
+	require.NoError(t, err)
+	for tag, img := range map[string]v1.Image{
+		"v1.60.0":                            v1600,
+		"v1.60.1":                            v1601,
+		v1600Digest.Hex:                      v1600,
+		randomDigestTag(t):                   randomImage(t),
+		"sha256-" + v1600Digest.Hex + ".sig": randomImage(t),
+	} {
+		require.NoError(t, remote.Write(parseReference(t, repo+":"+tag), img))
+	}
+
+	pushCtx := &contexts.PushContext{
+		BaseContext: contexts.BaseContext{
+			Logger:       log.NewSLogger(slog.LevelDebug),
+			Insecure:     true,
+			RegistryHost: host,
+			RegistryPath: "/deckhouse/ee",
+		},
+		Parallelism: contexts.DefaultParallelism,
+	}
+
+	heads.Store(0)
+	pruned, err := PruneOldPatches(context.Background(), pushCtx, 1, true)
+	require.NoError(t, err)
+	require.Empty(t, pruned, "v1.60.0 is older but refers to the same image as digest-named tag")
+	require.EqualValues(t, 2, heads.Load(), "only version tags should be requested")
+}
+
+func TestOldPatchTags(t *testing.T) {
+	tags := []string{"v1.59.9", "v1.60.0", "v1.60.10", "v1.60.2", "v1.61.0", "v1.61.1-rc.1", "stable", "latest"}
+	require.Equal(t, map[string]struct{}{"v1.60.0": {}}, oldPatchTags(tags, 2))
+	require.Equal(t, map[string]struct{}{"v1.60.0": {}, "v1.60.2": {}}, oldPatchTags(tags, 1))
+}
+
+func randomImage(t *testing.T) v1.Image {
+	t.Helper()
+	img, err := random.Image(32, 1)
+	require.NoError(t, err)
+	return img
+}
+
+func randomDigestTag(t *testing.T) string {
+	t.Helper()
+	digest, err := randomImage(t).Digest()
+	require.NoError(t, err)
+	return digest.Hex
+}
+
+func parseReference(t *testing.T, ref string) name.Reference {
+	t.Helper()
+	parsed, err := name.ParseReference(ref, name.Insecure)
+	require.NoError(t, err)
+	return parsed
+}
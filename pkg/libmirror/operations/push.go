@@ -8,16 +8,21 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/samber/lo"
 
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/bundle"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flatten"
 )
 
 func PushDeckhouseToRegistry(mirrorCtx *contexts.PushContext) error {
@@ -32,43 +37,165 @@ func PushDeckhouseToRegistryContext(ctx context.Context, mirrorCtx *contexts.Pus
 		return fmt.Errorf("Find OCI Image Layouts to push: %w", err)
 	}
 
-	for repo, ociLayout := range ociLayouts {
+	targetRepo, err := targetRepositoryResolver(mirrorCtx, ociLayouts, modulesList)
+	if err != nil {
+		return err
+	}
+
+	pushedBlobs := layouts.NewPushedBlobs()
+	mirrorCtx.Run.AddTotal(contexts.StagePush, len(ociLayouts))
+	for segment, ociLayout := range ociLayouts {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		repo := targetRepo(segment)
+
+		index, err := layoutIndex(mirrorCtx, segment, ociLayout)
+		if err != nil {
+			return fmt.Errorf("Read OCI Image Index of %q: %w", segment, err)
+		}
+
 		logger.InfoLn("Mirroring", repo)
-		err = layouts.PushLayoutToRepoContext(
-			ctx, ociLayout, repo,
+		err = layouts.PushIndexToRepoContext(
+			ctx, index, repo,
 			mirrorCtx.RegistryAuth,
 			mirrorCtx.Logger,
 			mirrorCtx.Parallelism,
 			mirrorCtx.Insecure,
 			mirrorCtx.SkipTLSVerification,
+			layouts.WithSkipExistingTags(mirrorCtx.SkipExistingTags),
+			layouts.WithSkipCosignArtifacts(!mirrorCtx.IncludeCosign),
+			layouts.WithRegistryCapabilities(mirrorCtx.RegistryCapabilities),
+			layouts.WithPushedBlobs(pushedBlobs),
 		)
 		switch {
 		case errors.Is(err, layouts.ErrEmptyLayout):
 			logger.InfoF("Skipped repo %s as it contains no images", repo)
+			mirrorCtx.Run.Advance(contexts.StagePush, 1)
 			continue
 		case err != nil:
 			return fmt.Errorf("Push Deckhouse to registry: %w", err)
 		}
 
+		mirrorCtx.Run.RecordRepoPushed(contexts.StagePush, repo)
+		mirrorCtx.Run.Advance(contexts.StagePush, 1)
 		logger.InfoF("Repo %s is mirrored", repo)
 	}
 
 	logger.InfoLn("All repositories are mirrored")
 
-	if len(modulesList) == 0 {
-		return nil
+	if len(modulesList) > 0 {
+		logger.InfoLn("Pushing modules tags")
+		if err = pushModulesTags(ctx, &mirrorCtx.BaseContext, targetRepo("modules"), modulesList); err != nil {
+			return fmt.Errorf("Push modules tags: %w", err)
+		}
+		logger.InfoF("All modules tags are pushed")
 	}
 
-	logger.InfoLn("Pushing modules tags")
-	if err = pushModulesTags(ctx, &mirrorCtx.BaseContext, modulesList); err != nil {
-		return fmt.Errorf("Push modules tags: %w", err)
+	if err = verifyPushedContents(ctx, mirrorCtx, ociLayouts, targetRepo); err != nil {
+		return fmt.Errorf("Verify pushed contents: %w", err)
 	}
-	logger.InfoF("All modules tags are pushed")
 
 	return nil
 }
 
-func pushModulesTags(ctx context.Context, mirrorCtx *contexts.BaseContext, modulesList []string) error {
+// layoutIndex reads index of layout at segment from packed bundle if it is streamed, or from the unpacked one.
+func layoutIndex(mirrorCtx *contexts.PushContext, segment string, ociLayout layout.Path) (v1.ImageIndex, error) {
+	if mirrorCtx.StreamedBundle != nil {
+		return mirrorCtx.StreamedBundle.ImageIndex(segment)
+	}
+	return ociLayout.ImageIndex()
+}
+
+// verifyPushedContents compares contents of target registry with counts recorded in bundle during pull.
+// Only segments that were pushed are verified.
+func verifyPushedContents(
+	ctx context.Context,
+	mirrorCtx *contexts.PushContext,
+	ociLayouts map[string]layout.Path,
+	targetRepo func(segment string) string,
+) error {
+	recorded, err := bundle.ReadSegmentCounts(mirrorCtx.UnpackedImagesPath)
+	if err != nil {
+		return err
+	}
+	if recorded == nil {
+		mirrorCtx.Logger.DebugLn("Bundle has no recorded segment counts, skipping verification of pushed contents")
+		return nil
+	}
+
+	expected := lo.PickByKeys(recorded, lo.Keys(ociLayouts))
+
+	mirrorCtx.Logger.InfoLn("Verifying that target registry received all contents of the bundle")
+	if err = VerifySegmentCounts(ctx, mirrorCtx, expected, targetRepo); err != nil {
+		return err
+	}
+	mirrorCtx.Logger.InfoLn("Target registry has all contents of the bundle")
+	return nil
+}
+
+// targetRepositoryResolver returns func that maps paths of repositories relative to the bundle root
+// to repositories in the target registry, hosting modules under --modules-path-suffix.
+// With flattening enabled, mapping of all repos to be pushed is written to file beforehand.
+// So is the mapping of repositories that had to be lowercased, e.g. of modules with mixed-case names.
+func targetRepositoryResolver(
+	mirrorCtx *contexts.PushContext,
+	ociLayouts map[string]layout.Path,
+	modulesList []string,
+) (func(segment string) string, error) {
+	rootRepo := mirrorCtx.RegistryHost + mirrorCtx.RegistryPath
+	mapping := flatten.NewMapping(rootRepo)
+	mapping.Nested = !mirrorCtx.FlattenRepositories
+
+	segments := lo.Keys(ociLayouts)
+	if len(modulesList) > 0 {
+		segments = append(segments, contexts.DefaultModulesPathSuffix)
+	}
+	sort.Strings(segments)
+
+	segmentsByRepo := make(map[string]string, len(segments))
+	renamed := false
+	for _, segment := range segments {
+		segment = mirrorCtx.RegistrySegment(segment)
+		repo := mapping.Resolve(segment)
+		if lowercased := lowercaseRepository(rootRepo, repo); lowercased != repo {
+			mirrorCtx.Logger.WarnF("⚠️ Registry repository names must be lowercase, %s is pushed to %s", repo, lowercased)
+			repo, renamed = lowercased, true
+			mapping.Rename(segment, repo)
+		} else if mirrorCtx.FlattenRepositories {
+			mapping.Rename(segment, repo)
+		}
+
+		if other, taken := segmentsByRepo[repo]; taken {
+			return nil, fmt.Errorf("Repositories %q and %q both map to %s as registry repository names must be lowercase", other, segment, repo)
+		}
+		segmentsByRepo[repo] = segment
+	}
+
+	if mirrorCtx.FlattenRepositories || renamed {
+		if err := mapping.Save(mirrorCtx.FlattenMappingPath); err != nil {
+			return nil, err
+		}
+		mirrorCtx.Logger.InfoF("Repositories mapping is written to %s", mirrorCtx.FlattenMappingPath)
+	}
+
+	return func(segment string) string {
+		return mapping.Resolve(mirrorCtx.RegistrySegment(segment))
+	}, nil
+}
+
+// lowercaseRepository lowercases path of repo below rootRepo, as registries either reject repository names
+// with uppercase characters or silently lowercase them, breaking references to mixed-case module repositories.
+func lowercaseRepository(rootRepo, repo string) string {
+	nested, isNested := strings.CutPrefix(repo, rootRepo)
+	if !isNested {
+		return repo
+	}
+	return rootRepo + strings.ToLower(nested)
+}
+
+func pushModulesTags(ctx context.Context, mirrorCtx *contexts.BaseContext, modulesRepo string, modulesList []string) error {
 	if len(modulesList) == 0 {
 		return nil
 	}
@@ -76,12 +203,18 @@ func pushModulesTags(ctx context.Context, mirrorCtx *contexts.BaseContext, modul
 	logger := mirrorCtx.Logger
 	refOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(mirrorCtx)
 	remoteOpts = append(remoteOpts, remote.WithContext(ctx))
-	modulesRepo := path.Join(mirrorCtx.RegistryHost, mirrorCtx.RegistryPath, "modules")
 	pushCount := 1
 	for _, moduleName := range modulesList {
 		logger.InfoF("[%d / %d] Pushing module tag for %s", pushCount, len(modulesList), moduleName)
 
-		imageRef, err := name.ParseReference(modulesRepo+":"+moduleName, refOpts...)
+		// Only repositories of modules with mixed-case names are lowercased by targetRepositoryResolver,
+		// so only their tags are lowercased to match, while the rest are pushed exactly as named in bundle.
+		tag := moduleName
+		if lowercased := strings.ToLower(moduleName); lowercased != moduleName {
+			logger.WarnF("⚠️ Module %s is tagged as %s to match its lowercased repository, it will be listed under that name", moduleName, lowercased)
+			tag = lowercased
+		}
+		imageRef, err := name.ParseReference(modulesRepo+":"+tag, refOpts...)
 		if err != nil {
 			return fmt.Errorf("Parse image reference: %w", err)
 		}
@@ -99,6 +232,7 @@ func pushModulesTags(ctx context.Context, mirrorCtx *contexts.BaseContext, modul
 	return nil
 }
 
+// findLayoutsToPush returns layouts found in bundle keyed by their paths relative to bundle root, and names of modules.
 func findLayoutsToPush(ctx context.Context, mirrorCtx *contexts.PushContext) (map[string]layout.Path, []string, error) {
 	ociLayouts := make(map[string]layout.Path)
 	bundlePaths := [][]string{
@@ -117,7 +251,7 @@ func findLayoutsToPush(ctx context.Context, mirrorCtx *contexts.PushContext) (ma
 			return nil, nil, err
 		}
 
-		indexRef := path.Join(append([]string{mirrorCtx.RegistryHost + mirrorCtx.RegistryPath}, bundlePath...)...)
+		segment := path.Join(bundlePath...)
 		layoutFileSystemPath := filepath.Join(append([]string{mirrorCtx.UnpackedImagesPath}, bundlePath...)...)
 		l, err := layout.FromPath(layoutFileSystemPath)
 		if err != nil {
@@ -128,7 +262,7 @@ func findLayoutsToPush(ctx context.Context, mirrorCtx *contexts.PushContext) (ma
 			}
 			return nil, nil, err
 		}
-		ociLayouts[indexRef] = l
+		ociLayouts[segment] = l
 	}
 
 	modulesPath := filepath.Join(mirrorCtx.UnpackedImagesPath, "modules")
@@ -152,8 +286,8 @@ func findLayoutsToPush(ctx context.Context, mirrorCtx *contexts.PushContext) (ma
 
 		moduleName := dirEntry.Name()
 		modulesNames = append(modulesNames, moduleName)
-		moduleRef := path.Join(mirrorCtx.RegistryHost+mirrorCtx.RegistryPath, "modules", moduleName)
-		moduleReleasesRef := path.Join(mirrorCtx.RegistryHost+mirrorCtx.RegistryPath, "modules", moduleName, "release")
+		moduleSegment := path.Join("modules", moduleName)
+		moduleReleasesSegment := path.Join("modules", moduleName, "release")
 		moduleLayout, err := layout.FromPath(filepath.Join(modulesPath, moduleName))
 		if err != nil {
 			return nil, nil, fmt.Errorf("create module layout from path: %w", err)
@@ -162,8 +296,8 @@ func findLayoutsToPush(ctx context.Context, mirrorCtx *contexts.PushContext) (ma
 		if err != nil {
 			return nil, nil, fmt.Errorf("create module release layout from path: %w", err)
 		}
-		ociLayouts[moduleRef] = moduleLayout
-		ociLayouts[moduleReleasesRef] = moduleReleaseLayout
+		ociLayouts[moduleSegment] = moduleLayout
+		ociLayouts[moduleReleasesSegment] = moduleReleaseLayout
 	}
 	return ociLayouts, modulesNames, nil
 }
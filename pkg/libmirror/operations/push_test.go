@@ -0,0 +1,114 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/flatten"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	mirrorTestUtils "github.com/deckhouse/deckhouse-cli/testing/util/mirror"
+)
+
+func TestTargetRepositoryResolverLowercasesRepositories(t *testing.T) {
+	mappingPath := filepath.Join(t.TempDir(), "mapping.json")
+	pushCtx := &contexts.PushContext{
+		BaseContext: contexts.BaseContext{
+			Logger:       log.NewSLogger(slog.LevelDebug),
+			RegistryHost: "registry.example.com",
+			RegistryPath: "/deckhouse/ee",
+		},
+		FlattenMappingPath: mappingPath,
+	}
+	ociLayouts := map[string]layout.Path{
+		"":                          "",
+		"install":                   "",
+		"modules/MixedCase":         "",
+		"modules/MixedCase/release": "",
+	}
+
+	targetRepo, err := targetRepositoryResolver(pushCtx, ociLayouts, []string{"MixedCase"})
+	require.NoError(t, err)
+	require.Equal(t, "registry.example.com/deckhouse/ee", targetRepo(""))
+	require.Equal(t, "registry.example.com/deckhouse/ee/install", targetRepo("install"))
+	require.Equal(t, "registry.example.com/deckhouse/ee/modules/mixedcase", targetRepo("modules/MixedCase"))
+	require.Equal(t, "registry.example.com/deckhouse/ee/modules/mixedcase/release", targetRepo("modules/MixedCase/release"))
+
+	mapping, err := flatten.LoadMapping(mappingPath)
+	require.NoError(t, err)
+	require.True(t, mapping.Nested)
+	require.Equal(t, map[string]string{
+		"modules/MixedCase":         "registry.example.com/deckhouse/ee/modules/mixedcase",
+		"modules/MixedCase/release": "registry.example.com/deckhouse/ee/modules/mixedcase/release",
+	}, mapping.Repositories)
+
+	pushCtx.FlattenRepositories = true
+	targetRepo, err = targetRepositoryResolver(pushCtx, ociLayouts, []string{"MixedCase"})
+	require.NoError(t, err)
+	require.Regexp(t, `^registry\.example\.com/deckhouse/ee-modules-mixedcase-[0-9a-f]{8}$`, targetRepo("modules/MixedCase"))
+
+	ociLayouts["modules/mixedcase"] = ""
+	_, err = targetRepositoryResolver(pushCtx, ociLayouts, []string{"MixedCase", "mixedcase"})
+	require.NoError(t, err, "flattened names of different segments differ by hash")
+	pushCtx.FlattenRepositories = false
+	_, err = targetRepositoryResolver(pushCtx, ociLayouts, []string{"MixedCase", "mixedcase"})
+	require.ErrorContains(t, err, "both map to registry.example.com/deckhouse/ee/modules/mixedcase")
+}
+
+func TestTargetRepositoryResolverKeepsLowercaseRepositoriesUnmapped(t *testing.T) {
+	mappingPath := filepath.Join(t.TempDir(), "mapping.json")
+	pushCtx := &contexts.PushContext{
+		BaseContext: contexts.BaseContext{
+			Logger:       log.NewSLogger(slog.LevelDebug),
+			RegistryHost: "registry.example.com",
+			RegistryPath: "/deckhouse/ee",
+		},
+		FlattenMappingPath: mappingPath,
+	}
+
+	targetRepo, err := targetRepositoryResolver(pushCtx, map[string]layout.Path{"": "", "modules/foo": ""}, []string{"foo"})
+	require.NoError(t, err)
+	require.Equal(t, "registry.example.com/deckhouse/ee/modules/foo", targetRepo("modules/foo"))
+	require.NoFileExists(t, mappingPath)
+}
+
+func TestPushModulesTagsLowercasesOnlyMixedCaseNames(t *testing.T) {
+	reg := mirrorTestUtils.SetupTestRegistry()
+	defer reg.Server.Close()
+	modulesRepo := reg.Host + reg.RepoPath + "/modules"
+	baseCtx := &contexts.BaseContext{
+		Logger:   log.NewSLogger(slog.LevelDebug),
+		Insecure: true,
+	}
+
+	require.NoError(t, pushModulesTags(context.Background(), baseCtx, modulesRepo, []string{"MixedCase", "commander-agent"}))
+
+	repo, err := name.NewRepository(modulesRepo, name.Insecure)
+	require.NoError(t, err)
+	tags, err := remote.List(repo)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"mixedcase", "commander-agent"}, tags)
+}
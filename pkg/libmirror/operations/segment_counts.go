@@ -0,0 +1,131 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/go-multierror"
+	"github.com/samber/lo"
+	"github.com/samber/lo/parallel"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
+)
+
+// ErrIncompletePush is returned when target registry has fewer tags, images or blobs than bundle recorded after push.
+var ErrIncompletePush = errors.New("target registry is missing pushed contents")
+
+// manifestsBatchSize is how many manifests are fetched from target registry at once while counting its contents.
+const manifestsBatchSize = 16
+
+// SegmentShortfall is a repository of the target registry that has less contents than expected.
+type SegmentShortfall struct {
+	Repository string
+	Expected   layouts.SegmentCounts
+	Actual     layouts.SegmentCounts
+}
+
+func (s SegmentShortfall) String() string {
+	return fmt.Sprintf("%s has %s, expected at least %s", s.Repository, s.Actual, s.Expected)
+}
+
+// VerifySegmentCounts checks that every repository of the target registry has at least as many tags, images and blobs
+// as its bundle segment had according to counts recorded during pull. This catches uploads that silently lost images.
+func VerifySegmentCounts(
+	ctx context.Context,
+	mirrorCtx *contexts.PushContext,
+	expected map[string]layouts.SegmentCounts,
+	targetRepo func(segment string) string,
+) error {
+	segments := make([]string, 0, len(expected))
+	for segment := range expected {
+		segments = append(segments, segment)
+	}
+	sort.Strings(segments)
+
+	shortfalls := make([]string, 0)
+	for _, segment := range segments {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		repo := targetRepo(segment)
+		actual, err := countRepository(ctx, mirrorCtx, repo)
+		if err != nil {
+			return fmt.Errorf("Count contents of %s: %w", repo, err)
+		}
+		mirrorCtx.Logger.DebugF("%s has %s, bundle has %s", repo, actual, expected[segment])
+		if !actual.Covers(expected[segment]) {
+			shortfall := SegmentShortfall{Repository: repo, Expected: expected[segment], Actual: actual}
+			shortfalls = append(shortfalls, shortfall.String())
+		}
+	}
+
+	if len(shortfalls) > 0 {
+		return fmt.Errorf("%w: %s", ErrIncompletePush, strings.Join(shortfalls, "; "))
+	}
+	return nil
+}
+
+// countRepository counts tags of repository in target registry and the images and blobs they refer to.
+func countRepository(ctx context.Context, mirrorCtx *contexts.PushContext, repoName string) (layouts.SegmentCounts, error) {
+	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptionsFromMirrorContext(&mirrorCtx.BaseContext)
+	remoteOpts = append(remoteOpts, remote.WithContext(ctx))
+	repo, err := name.NewRepository(repoName, nameOpts...)
+	if err != nil {
+		return layouts.SegmentCounts{}, fmt.Errorf("Parse repository %q: %w", repoName, err)
+	}
+
+	tags, err := mirrorCtx.Run.TagLister().List(ctx, repo, auth.MakeTransport(mirrorCtx.SkipTLSVerification), remoteOpts...)
+	if errorutil.IsRepoNotFoundError(err) {
+		return layouts.SegmentCounts{}, nil
+	}
+	if err != nil {
+		return layouts.SegmentCounts{}, fmt.Errorf("List tags: %w", err)
+	}
+
+	counter := layouts.NewSegmentCounter()
+	counterMu := &sync.Mutex{}
+	for _, batch := range lo.Chunk(tags, manifestsBatchSize) {
+		merr := &multierror.Error{}
+		parallel.ForEach(batch, func(tag string, _ int) {
+			desc, err := remote.Get(repo.Tag(tag), remoteOpts...)
+			counterMu.Lock()
+			defer counterMu.Unlock()
+			if err == nil {
+				err = counter.Add(tag, desc.Digest, desc.MediaType, desc.Manifest)
+			}
+			if err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("%s: %w", tag, err))
+			}
+		})
+		if err = merr.ErrorOrNil(); err != nil {
+			return layouts.SegmentCounts{}, err
+		}
+	}
+	return counter.Counts(), nil
+}
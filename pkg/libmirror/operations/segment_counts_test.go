@@ -0,0 +1,73 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/layouts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	mirrorTestUtils "github.com/deckhouse/deckhouse-cli/testing/util/mirror"
+)
+
+func TestVerifySegmentCounts(t *testing.T) {
+	reg := mirrorTestUtils.SetupTestRegistry()
+	defer reg.Server.Close()
+	repo := reg.Host + reg.RepoPath
+
+	stable := randomImage(t)
+	require.NoError(t, remote.Write(parseReference(t, repo+":v1.60.0"), stable))
+	require.NoError(t, remote.Write(parseReference(t, repo+":stable"), stable))
+	require.NoError(t, remote.Write(parseReference(t, repo+"/install:v1.60.0"), randomImage(t)))
+
+	pushCtx := &contexts.PushContext{
+		BaseContext: contexts.BaseContext{
+			Logger:       log.NewSLogger(slog.LevelDebug),
+			Insecure:     true,
+			RegistryHost: reg.Host,
+			RegistryPath: reg.RepoPath,
+		},
+	}
+	targetRepo := func(segment string) string {
+		if segment == "" {
+			return repo
+		}
+		return repo + "/" + segment
+	}
+
+	err := VerifySegmentCounts(context.Background(), pushCtx, map[string]layouts.SegmentCounts{
+		"":        {Tags: 2, Images: 1, Blobs: 2},
+		"install": {Tags: 1, Images: 1, Blobs: 2},
+	}, targetRepo)
+	require.NoError(t, err)
+
+	err = VerifySegmentCounts(context.Background(), pushCtx, map[string]layouts.SegmentCounts{
+		"":                {Tags: 3, Images: 2, Blobs: 4},
+		"install":         {Tags: 1, Images: 1, Blobs: 2},
+		"release-channel": {Tags: 1, Images: 1, Blobs: 2},
+	}, targetRepo)
+	require.ErrorIs(t, err, ErrIncompletePush)
+	require.ErrorContains(t, err, repo+" has 2 tags, 1 images, 2 blobs, expected at least 3 tags, 2 images, 4 blobs")
+	require.ErrorContains(t, err, repo+"/release-channel has 0 tags, 0 images, 0 blobs")
+	require.NotContains(t, err.Error(), repo+"/install")
+}
@@ -18,16 +18,20 @@ package auth
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
+	"net/http"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
-	"github.com/hashicorp/go-cleanhttp"
 
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/failover"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/httppool"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/ratelimit"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/retry"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/throttle"
 )
 
 func ValidateReadAccessForImage(imageTag string, authProvider authn.Authenticator, insecure, skipVerifyTLS bool) error {
@@ -92,15 +96,22 @@ func MakeRemoteRegistryRequestOptions(authProvider authn.Authenticator, insecure
 	if authProvider != nil && authProvider != authn.Anonymous {
 		r = append(r, remote.WithAuth(authProvider))
 	}
-	if skipTLSVerification {
-		transport := cleanhttp.DefaultTransport()
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		r = append(r, remote.WithTransport(transport))
-	}
+	r = append(r, remote.WithTransport(MakeTransport(skipTLSVerification)))
 
 	return n, r
 }
 
+// MakeTransport returns HTTP transport that registry requests made with MakeRemoteRegistryRequestOptions use.
+// It is useful to wrap it into some other http.RoundTripper that should be passed with remote.WithTransport.
+// Transports are shared by all requests of the process to reuse connections to registries.
+// Requests to the source registry fail over to its mirrors if failover is enabled, see failover.Enable.
+// Transfers are limited to the rate of enabled limiter, see ratelimit.Enable.
+// Requests throttled by registry quotas are repeated after adaptive backoff if throttling is enabled, see throttle.Enable.
+// Requests failed with transient errors are repeated according to enabled policy, see retry.Enable.
+func MakeTransport(skipTLSVerification bool) http.RoundTripper {
+	return retry.Wrap(throttle.Wrap(ratelimit.Wrap(failover.Wrap(httppool.Transport(skipTLSVerification)))))
+}
+
 func MakeRemoteRegistryRequestOptionsFromMirrorContext(mirrorCtx *contexts.BaseContext) ([]name.Option, []remote.Option) {
 	return MakeRemoteRegistryRequestOptions(mirrorCtx.RegistryAuth, mirrorCtx.Insecure, mirrorCtx.SkipTLSVerification)
 }
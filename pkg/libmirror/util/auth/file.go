@@ -0,0 +1,131 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// ErrInsecureAuthFilePermissions is returned when credentials file can be read or modified by users other than its owner.
+var ErrInsecureAuthFilePermissions = errors.New("credentials file must not be accessible by group or others, set its mode to 0600")
+
+// dockerConfig is the subset of Docker config.json and kubernetes.io/dockerconfigjson secret contents that holds credentials.
+type dockerConfig struct {
+	Auths map[string]authn.AuthConfig `json:"auths"`
+}
+
+// LoadAuthFile reads registry credentials from file for the given registry host.
+// File may either be a Docker config.json (or .dockerconfigjson) with "auths" section,
+// or contain a single "username:password" line.
+// File must be readable and writable only by its owner.
+func LoadAuthFile(path, registryHost string) (authn.Authenticator, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("Read credentials file: %w", err)
+	}
+	if !stat.Mode().IsRegular() {
+		return nil, fmt.Errorf("Read credentials file: %s is not a regular file", path)
+	}
+	if runtime.GOOS != "windows" && stat.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("%s has mode %#o: %w", path, stat.Mode().Perm(), ErrInsecureAuthFilePermissions)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Read credentials file: %w", err)
+	}
+	contents = bytes.TrimSpace(contents)
+
+	if bytes.HasPrefix(contents, []byte("{")) {
+		authConfig, err := findDockerConfigAuth(contents, registryHost)
+		if err != nil {
+			return nil, fmt.Errorf("Parse credentials file %s: %w", path, err)
+		}
+		return authn.FromConfig(authConfig), nil
+	}
+
+	if bytes.ContainsAny(contents, "\r\n") {
+		return nil, fmt.Errorf("Parse credentials file %s: expected a single username:password line", path)
+	}
+	username, password, found := strings.Cut(string(contents), ":")
+	if !found || username == "" {
+		return nil, fmt.Errorf("Parse credentials file %s: expected username:password", path)
+	}
+	return authn.FromConfig(authn.AuthConfig{Username: username, Password: password}), nil
+}
+
+func findDockerConfigAuth(contents []byte, registryHost string) (authn.AuthConfig, error) {
+	config := dockerConfig{}
+	if err := json.Unmarshal(contents, &config); err != nil {
+		return authn.AuthConfig{}, err
+	}
+
+	for key, authConfig := range config.Auths {
+		if dockerConfigKeyHost(key) != registryHost {
+			continue
+		}
+		if authConfig.Auth == "" {
+			return authConfig, nil
+		}
+
+		// "auth" is base64 encoded "username:password", it takes precedence over separate fields just like in Docker
+		decoded, err := base64.StdEncoding.DecodeString(authConfig.Auth)
+		if err != nil {
+			return authn.AuthConfig{}, fmt.Errorf("decode auth for %s: %w", key, err)
+		}
+		username, password, found := strings.Cut(string(decoded), ":")
+		if !found {
+			return authn.AuthConfig{}, fmt.Errorf("decode auth for %s: expected username:password", key)
+		}
+		return authn.AuthConfig{Username: username, Password: password}, nil
+	}
+
+	return authn.AuthConfig{}, fmt.Errorf("no credentials for %s", registryHost)
+}
+
+// dockerConfigKeyHost returns registry host from "auths" key, which may also be written as URL, e.g. "https://registry.example.com/v1/".
+func dockerConfigKeyHost(key string) string {
+	if !strings.Contains(key, "://") {
+		host, _, _ := strings.Cut(key, "/")
+		return host
+	}
+	u, err := url.Parse(key)
+	if err != nil {
+		return key
+	}
+	return u.Host
+}
+
+// LoadAuthFileForRepo is LoadAuthFile for registry that hosts the given repository.
+func LoadAuthFileForRepo(path, repo string) (authn.Authenticator, error) {
+	repository, err := name.NewRepository(repo)
+	if err != nil {
+		return nil, fmt.Errorf("Parse repository %q: %w", repo, err)
+	}
+	return LoadAuthFile(path, repository.RegistryStr())
+}
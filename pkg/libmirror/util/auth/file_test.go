@@ -0,0 +1,89 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAuthFile(t *testing.T) {
+	dockerConfigAuth := base64.StdEncoding.EncodeToString([]byte("robot:s3cr3t:with:colons"))
+	tests := []struct {
+		name     string
+		contents string
+		expected authn.AuthConfig
+		errMsg   string
+	}{
+		{
+			name:     "username and password",
+			contents: "user:pa:ss\n",
+			expected: authn.AuthConfig{Username: "user", Password: "pa:ss"},
+		},
+		{
+			name:     "dockerconfigjson with auth",
+			contents: `{"auths":{"other.example.com":{"auth":"b3RoZXI6b3RoZXI="},"registry.example.com:5000":{"auth":"` + dockerConfigAuth + `"}}}`,
+			expected: authn.AuthConfig{Username: "robot", Password: "s3cr3t:with:colons"},
+		},
+		{
+			name:     "dockerconfigjson with url key and separate fields",
+			contents: `{"auths":{"https://registry.example.com:5000/v1/":{"username":"user","password":"pass"}}}`,
+			expected: authn.AuthConfig{Username: "user", Password: "pass"},
+		},
+		{
+			name:     "dockerconfigjson without registry",
+			contents: `{"auths":{"other.example.com":{"username":"user","password":"pass"}}}`,
+			errMsg:   "no credentials for registry.example.com:5000",
+		},
+		{
+			name:     "malformed",
+			contents: "just-a-token",
+			errMsg:   "expected username:password",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "auth")
+			require.NoError(t, os.WriteFile(path, []byte(tt.contents), 0o600))
+
+			authenticator, err := LoadAuthFile(path, "registry.example.com:5000")
+			if tt.errMsg != "" {
+				require.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			authConfig, err := authenticator.Authorization()
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, *authConfig)
+		})
+	}
+}
+
+func TestLoadAuthFileRejectsInsecurePermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth")
+	require.NoError(t, os.WriteFile(path, []byte("user:pass"), 0o600))
+	require.NoError(t, os.Chmod(path, 0o644))
+
+	_, err := LoadAuthFile(path, "registry.example.com")
+	require.ErrorIs(t, err, ErrInsecureAuthFilePermissions)
+}
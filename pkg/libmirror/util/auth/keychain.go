@@ -0,0 +1,50 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"sync/atomic"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+var dockerConfigDisabled atomic.Bool
+
+// DisableDockerConfig makes DefaultAuthenticator return anonymous credentials instead of looking them up in Docker config.
+func DisableDockerConfig() {
+	dockerConfigDisabled.Store(true)
+}
+
+// DefaultAuthenticator returns credentials for registry of repo to use when none were given explicitly.
+// They are looked up in Docker config.json at $DOCKER_CONFIG or ~/.docker, the same way docker login stores them,
+// including credsStore and credHelpers, which are executed only when registry asks for credentials.
+// Anonymous credentials are returned if Docker config has none for the registry, or its use is disabled.
+func DefaultAuthenticator(repo string) authn.Authenticator {
+	if dockerConfigDisabled.Load() {
+		return authn.Anonymous
+	}
+	repository, err := name.NewRepository(repo)
+	if err != nil {
+		return authn.Anonymous
+	}
+	authenticator, err := authn.DefaultKeychain.Resolve(repository.Registry)
+	if err != nil {
+		return authn.Anonymous
+	}
+	return authenticator
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultAuthenticatorReadsDockerConfig(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{
+		"auths": {"registry.example.com": {"username": "user", "password": "secret"}}
+	}`), 0o600))
+
+	authenticator := DefaultAuthenticator("registry.example.com/deckhouse/ee")
+	authConfig, err := authenticator.Authorization()
+	require.NoError(t, err)
+	require.Equal(t, "user", authConfig.Username)
+	require.Equal(t, "secret", authConfig.Password)
+
+	require.Equal(t, authn.Anonymous, DefaultAuthenticator("other.example.com/deckhouse/ee"))
+
+	DisableDockerConfig()
+	t.Cleanup(func() { dockerConfigDisabled.Store(false) })
+	require.Equal(t, authn.Anonymous, DefaultAuthenticator("registry.example.com/deckhouse/ee"))
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backoff
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, 30*time.Second, RetryAfter("30", now))
+	require.Equal(t, time.Minute, RetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now))
+	require.Zero(t, RetryAfter("", now))
+	require.Zero(t, RetryAfter("soon", now))
+	require.Zero(t, RetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+}
+
+func TestRewindReplaysBody(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://registry.example.com/v2/", strings.NewReader("payload"))
+	require.NoError(t, err)
+	require.True(t, CanRepeat(req))
+	_, _ = io.ReadAll(req.Body)
+
+	req, err = Rewind(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, "payload", string(body))
+
+	req.GetBody = nil
+	require.False(t, CanRepeat(req))
+}
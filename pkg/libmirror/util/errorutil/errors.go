@@ -58,3 +58,29 @@ func IsTrivyMediaTypeNotAllowedError(err error) bool {
 	return strings.Contains(errMsg, "MANIFEST_INVALID") &&
 		(strings.Contains(errMsg, "vnd.aquasec.trivy") || strings.Contains(errMsg, "application/octet-stream"))
 }
+
+// IsImmutableTagError reports whether registry rejected the manifest because of tag immutability policy.
+// Harbor responds with PRECONDITION_FAILED or DENIED mentioning immutability, ECR with ImageTagAlreadyExistsException.
+func IsImmutableTagError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	errMsg := strings.ToLower(err.Error())
+	return strings.Contains(errMsg, "immutable") ||
+		strings.Contains(errMsg, "imagetagalreadyexistsexception") ||
+		strings.Contains(errMsg, "tag_already_exists")
+}
+
+// IsDigestMismatchError reports whether registry rejected uploaded blob because its contents do not match the digest.
+// This usually means upload was corrupted in transit, e.g. by TLS-inspecting proxy.
+func IsDigestMismatchError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	errMsg := strings.ToLower(err.Error())
+	return strings.Contains(errMsg, "digest_invalid") ||
+		strings.Contains(errMsg, "digest did not match") ||
+		strings.Contains(errMsg, "digest mismatch")
+}
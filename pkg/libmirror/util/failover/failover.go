@@ -0,0 +1,255 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package failover sends registry requests to fallback mirrors of the source registry when it is unreachable or throttled.
+//
+// Mirrors are expected to serve the same repositories and accept the same credentials as the source registry,
+// like geo-mirrors of the vendor registry do. Requests are grouped into classes, like manifests or blobs,
+// and every class fails over on its own: throttling of blob downloads does not move manifest requests to a mirror.
+package failover
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Classes of registry requests that fail over independently.
+const (
+	ClassManifests = "manifests"
+	ClassBlobs     = "blobs"
+	ClassTags      = "tags"
+	ClassOther     = "other"
+)
+
+// PrimaryRetryInterval is how long a class of requests keeps being sent to the mirror it failed over to
+// before the source registry is tried again.
+var PrimaryRetryInterval = time.Minute
+
+var active atomic.Pointer[Group]
+
+// Enable makes Wrap route requests to the source registry of the group through its mirrors, until returned func is called.
+func Enable(g *Group) (disable func()) {
+	active.Store(g)
+	return func() { active.CompareAndSwap(g, nil) }
+}
+
+// Wrap returns transport that fails over requests to the source registry of the enabled group, if there is one.
+// Requests to other registries are passed to base as is.
+func Wrap(base http.RoundTripper) http.RoundTripper {
+	g := active.Load()
+	if g == nil {
+		return base
+	}
+	return &transport{group: g, base: base}
+}
+
+type endpoint struct {
+	host string
+	path string
+}
+
+func (e endpoint) String() string { return e.host + "/" + e.path }
+
+// Group is the source registry repository with its mirrors in the order of preference.
+type Group struct {
+	endpoints []endpoint
+
+	// OnFailover is called every time class of requests is moved to another endpoint, reason is nil when source registry is tried again.
+	OnFailover func(class, from, to string, reason error)
+
+	mu       sync.Mutex
+	classes  map[string]*classState
+	requests map[Served]int64
+}
+
+type classState struct {
+	endpoint int
+	since    time.Time
+}
+
+// NewGroup parses source repository and its mirrors, e.g. registry.deckhouse.io/deckhouse/ee.
+func NewGroup(sourceRepo string, mirrorRepos []string, opts ...name.Option) (*Group, error) {
+	g := &Group{classes: map[string]*classState{}, requests: map[Served]int64{}}
+	for _, repo := range append([]string{sourceRepo}, mirrorRepos...) {
+		ref, err := name.NewRepository(repo, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("Parse %q: %w", repo, err)
+		}
+		e := endpoint{host: ref.RegistryStr(), path: ref.RepositoryStr()}
+		for _, known := range g.endpoints {
+			if known == e {
+				return nil, fmt.Errorf("%s is listed more than once", e)
+			}
+		}
+		g.endpoints = append(g.endpoints, e)
+	}
+	return g, nil
+}
+
+// Served is the number of requests of the class that were served by the endpoint.
+type Served struct {
+	Class    string
+	Endpoint string
+	Requests int64
+}
+
+// Served returns how many requests of every class were served by every endpoint, sorted by class and endpoint.
+func (g *Group) Served() []Served {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	served := make([]Served, 0, len(g.requests))
+	for key, requests := range g.requests {
+		key.Requests = requests
+		served = append(served, key)
+	}
+	sort.Slice(served, func(i, j int) bool {
+		if served[i].Class != served[j].Class {
+			return served[i].Class < served[j].Class
+		}
+		return served[i].Endpoint < served[j].Endpoint
+	})
+	return served
+}
+
+// firstEndpoint returns index of the endpoint that requests of class should be sent to first.
+// Once in PrimaryRetryInterval a request is sent to the source registry to find out if it is available again.
+func (g *Group) firstEndpoint(class string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state, found := g.classes[class]
+	if !found || state.endpoint == 0 {
+		return 0
+	}
+	if time.Since(state.since) < PrimaryRetryInterval {
+		return state.endpoint
+	}
+	state.since = time.Now()
+	return 0
+}
+
+func (g *Group) recordServed(class string, served int, reason error) {
+	g.mu.Lock()
+	g.requests[Served{Class: class, Endpoint: g.endpoints[served].String()}]++
+	state, found := g.classes[class]
+	if !found {
+		state = &classState{}
+		g.classes[class] = state
+	}
+	from := state.endpoint
+	if from != served {
+		state.endpoint, state.since = served, time.Now()
+	}
+	g.mu.Unlock()
+
+	if from != served {
+		g.notify(class, from, served, reason)
+	}
+}
+
+func (g *Group) notify(class string, from, to int, reason error) {
+	if g.OnFailover != nil {
+		g.OnFailover(class, g.endpoints[from].String(), g.endpoints[to].String(), reason)
+	}
+}
+
+type transport struct {
+	group *Group
+	base  http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	g := t.group
+	source := g.endpoints[0]
+	// Only idempotent requests without body are safe to repeat against another endpoint.
+	if req.URL.Host != source.host || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+		return t.base.RoundTrip(req)
+	}
+
+	class := classify(req.URL.Path)
+	first := g.firstEndpoint(class)
+	var lastErr error
+	for i := range g.endpoints {
+		current := (first + i) % len(g.endpoints)
+		resp, err := t.base.RoundTrip(rewrite(req, source, g.endpoints[current]))
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+
+		failure := err
+		if err == nil {
+			failure = unavailabilityError(resp)
+		}
+		if failure == nil || i == len(g.endpoints)-1 {
+			if err == nil {
+				g.recordServed(class, current, lastErr)
+			}
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		lastErr = fmt.Errorf("%s: %w", g.endpoints[current], failure)
+	}
+	panic("unreachable")
+}
+
+// unavailabilityError returns error if response means registry is throttling or is temporarily unavailable.
+func unavailabilityError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return errors.New(resp.Status)
+	default:
+		return nil
+	}
+}
+
+// rewrite returns copy of request to the source registry that is sent to the same repository of endpoint instead.
+func rewrite(req *http.Request, source, target endpoint) *http.Request {
+	if target == source {
+		return req
+	}
+	out := req.Clone(req.Context())
+	out.Host = ""
+	out.URL.Host = target.host
+	if rest, found := strings.CutPrefix(req.URL.Path, "/v2/"+source.path+"/"); found {
+		out.URL.Path = "/v2/" + target.path + "/" + rest
+		out.URL.RawPath = ""
+	}
+	return out
+}
+
+func classify(urlPath string) string {
+	switch {
+	case strings.Contains(urlPath, "/manifests/"):
+		return ClassManifests
+	case strings.Contains(urlPath, "/blobs/"):
+		return ClassBlobs
+	case strings.HasSuffix(urlPath, "/tags/list"):
+		return ClassTags
+	default:
+		return ClassOther
+	}
+}
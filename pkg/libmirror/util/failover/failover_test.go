@@ -0,0 +1,81 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package failover
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportFailsOverPerRequestClass(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/blobs/") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer source.Close()
+
+	mirrorPaths := make([]string, 0)
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorPaths = append(mirrorPaths, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mirror.Close()
+
+	sourceHost := strings.TrimPrefix(source.URL, "http://")
+	mirrorHost := strings.TrimPrefix(mirror.URL, "http://")
+	g, err := NewGroup(sourceHost+"/deckhouse/ee", []string{mirrorHost + "/mirror/deckhouse/ee"}, name.Insecure)
+	require.NoError(t, err)
+	failovers := make([]string, 0)
+	g.OnFailover = func(class, from, to string, reason error) {
+		failovers = append(failovers, class+": "+from+" -> "+to)
+		require.Error(t, reason)
+	}
+	disable := Enable(g)
+	defer disable()
+
+	client := &http.Client{Transport: Wrap(http.DefaultTransport)}
+	for _, path := range []string{
+		"/v2/deckhouse/ee/manifests/v1.60.0",
+		"/v2/deckhouse/ee/blobs/sha256:abc",
+		"/v2/deckhouse/ee/blobs/sha256:def",
+		"/v2/deckhouse/ee/manifests/v1.61.0",
+	} {
+		resp, err := client.Get(source.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, path)
+	}
+
+	require.Equal(t, []string{"/v2/mirror/deckhouse/ee/blobs/sha256:abc", "/v2/mirror/deckhouse/ee/blobs/sha256:def"}, mirrorPaths)
+	require.Equal(t, []string{"blobs: " + sourceHost + "/deckhouse/ee -> " + mirrorHost + "/mirror/deckhouse/ee"}, failovers)
+	require.Equal(t, []Served{
+		{Class: ClassBlobs, Endpoint: mirrorHost + "/mirror/deckhouse/ee", Requests: 2},
+		{Class: ClassManifests, Endpoint: sourceHost + "/deckhouse/ee", Requests: 2},
+	}, g.Served())
+}
+
+func TestWrapWithoutEnabledGroup(t *testing.T) {
+	require.Equal(t, http.DefaultTransport, Wrap(http.DefaultTransport))
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package flagrules rejects impossible combinations of command-line flags before command does any work.
+// Only flags explicitly set by user are considered, defaults never conflict.
+package flagrules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// Rule checks a single combination of flags.
+type Rule struct {
+	check  func(flags *pflag.FlagSet) error
+	reason string
+}
+
+// Because explains in the error message why the combination is impossible.
+func (r Rule) Because(reason string) Rule {
+	r.reason = reason
+	return r
+}
+
+// Conflicts rejects flag being set together with any of the others.
+func Conflicts(flag string, others ...string) Rule {
+	return Rule{check: func(flags *pflag.FlagSet) error {
+		if !flags.Changed(flag) {
+			return nil
+		}
+		for _, other := range others {
+			if flags.Changed(other) {
+				return fmt.Errorf("--%s cannot be used together with --%s", flag, other)
+			}
+		}
+		return nil
+	}}
+}
+
+// Requires rejects flag being set without at least one of the required flags.
+func Requires(flag string, anyOf ...string) Rule {
+	return Rule{check: func(flags *pflag.FlagSet) error {
+		if !flags.Changed(flag) {
+			return nil
+		}
+		for _, required := range anyOf {
+			if flags.Changed(required) {
+				return nil
+			}
+		}
+		return fmt.Errorf("--%s is only used together with --%s", flag, strings.Join(anyOf, " or --"))
+	}}
+}
+
+// Validate returns error describing the first rule that flags set by user violate.
+func Validate(flags *pflag.FlagSet, rules ...Rule) error {
+	for _, rule := range rules {
+		err := rule.check(flags)
+		if err == nil {
+			continue
+		}
+		if rule.reason != "" {
+			return fmt.Errorf("%w: %s", err, rule.reason)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flagrules
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	rules := []Rule{
+		Conflicts("release", "min-version", "since-channel").Because("releases selection is ambiguous"),
+		Requires("health-timeout", "health-file", "health-addr"),
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "defaults", args: nil},
+		{name: "no conflict", args: []string{"--release=v1.60.0", "--health-file=/tmp/health", "--health-timeout=1m"}},
+		{
+			name:    "conflict",
+			args:    []string{"--release=v1.60.0", "--since-channel=stable"},
+			wantErr: "--release cannot be used together with --since-channel: releases selection is ambiguous",
+		},
+		{
+			name:    "missing requirement",
+			args:    []string{"--health-timeout=1m"},
+			wantErr: "--health-timeout is only used together with --health-file or --health-addr",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			flags.String("release", "", "")
+			flags.String("min-version", "", "")
+			flags.String("since-channel", "", "")
+			flags.String("health-file", "", "")
+			flags.String("health-addr", "", "")
+			flags.Duration("health-timeout", 0, "")
+			require.NoError(t, flags.Parse(tt.args))
+
+			err := Validate(flags, rules...)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package flatten maps nested Deckhouse repositories to a single path level,
+// for registries like Docker Hub that do not allow repositories deeper than <namespace>/<name>.
+//
+// Every repository is placed next to the Deckhouse root repo and named after it, its path relative to the root
+// and a short hash of that path, so that e.g. "modules/foo-bar" and "modules-foo/bar" do not collide:
+//
+//	registry.example.com/ns/deckhouse                    -> registry.example.com/ns/deckhouse
+//	registry.example.com/ns/deckhouse/install            -> registry.example.com/ns/deckhouse-install-1e142e62
+//	registry.example.com/ns/deckhouse/modules/x/release  -> registry.example.com/ns/deckhouse-modules-x-release-a7b9d678
+package flatten
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+const hashLength = 8
+
+// Mapping records which repository every nested Deckhouse repository was flattened into.
+type Mapping struct {
+	// Root is the Deckhouse root repo, as passed to d8 mirror push.
+	Root string `json:"root"`
+	// Repositories maps paths relative to Root to flattened repositories.
+	Repositories map[string]string `json:"repositories"`
+	// Nested is set if repositories were pushed inside Root and not flattened,
+	// and the mapping only lists the ones that were renamed for other reasons, e.g. lowercased.
+	Nested bool `json:"nested,omitempty"`
+}
+
+func NewMapping(root string) *Mapping {
+	return &Mapping{
+		Root:         strings.TrimSuffix(root, "/"),
+		Repositories: make(map[string]string),
+	}
+}
+
+// Repository returns flattened repository for the repo at segment path relative to mapping root
+// and records it in the mapping.
+func (m *Mapping) Repository(segment string) string {
+	segment = strings.Trim(segment, "/")
+	repo := RepositoryName(m.Root, segment)
+	m.Repositories[segment] = repo
+	return repo
+}
+
+// Rename records that the repo at segment path relative to mapping root was pushed into repo.
+func (m *Mapping) Rename(segment, repo string) {
+	m.Repositories[strings.Trim(segment, "/")] = repo
+}
+
+// Resolve returns repository recorded for segment path relative to mapping root. Segments missing from the mapping
+// resolve to where they would have been pushed without renaming.
+func (m *Mapping) Resolve(segment string) string {
+	segment = strings.Trim(segment, "/")
+	if repo, found := m.Repositories[segment]; found {
+		return repo
+	}
+	if m.Nested {
+		return strings.TrimSuffix(path.Join(m.Root, segment), "/")
+	}
+	return RepositoryName(m.Root, segment)
+}
+
+// Save writes mapping as JSON file to the given path.
+func (m *Mapping) Save(filePath string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Marshal repositories mapping: %w", err)
+	}
+	if err = os.WriteFile(filePath, data, 0o644); err != nil {
+		return fmt.Errorf("Write repositories mapping: %w", err)
+	}
+	return nil
+}
+
+// LoadMapping reads mapping previously written by Save.
+func LoadMapping(filePath string) (*Mapping, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Read repositories mapping: %w", err)
+	}
+	m := &Mapping{}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("Parse repositories mapping %s: %w", filePath, err)
+	}
+	if m.Repositories == nil {
+		m.Repositories = make(map[string]string)
+	}
+	return m, nil
+}
+
+// RepositoryName returns flattened name of the repo at segment path relative to root.
+// Root repo itself is left as is.
+func RepositoryName(root, segment string) string {
+	root = strings.TrimSuffix(root, "/")
+	segment = strings.Trim(segment, "/")
+	if segment == "" {
+		return root
+	}
+
+	hash := sha256.Sum256([]byte(segment))
+	return root + "-" + strings.ReplaceAll(segment, "/", "-") + "-" + hex.EncodeToString(hash[:])[:hashLength]
+}
+
+// Depth returns the number of path levels in repo reference, not counting the registry host.
+func Depth(repo string) int {
+	_, repoPath, _ := strings.Cut(strings.Trim(repo, "/"), "/")
+	if repoPath == "" {
+		return 0
+	}
+	return len(strings.Split(path.Clean(repoPath), "/"))
+}
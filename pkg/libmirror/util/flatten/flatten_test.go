@@ -0,0 +1,85 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flatten
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryName(t *testing.T) {
+	root := "registry.example.com/ns/deckhouse"
+
+	require.Equal(t, root, RepositoryName(root, ""))
+	require.Equal(t, root, RepositoryName(root+"/", "/"))
+
+	install := RepositoryName(root, "install")
+	require.True(t, strings.HasPrefix(install, root+"-install-"), install)
+	require.Equal(t, Depth(root), Depth(install))
+
+	release := RepositoryName(root, "modules/x/release")
+	require.True(t, strings.HasPrefix(release, root+"-modules-x-release-"), release)
+	require.Equal(t, 2, Depth(release))
+
+	require.NotEqual(t,
+		RepositoryName(root, "modules/foo-bar"),
+		RepositoryName(root, "modules-foo/bar"),
+		"Paths that flatten to the same name must get different hashes",
+	)
+}
+
+func TestMappingSaveAndLoad(t *testing.T) {
+	m := NewMapping("registry.example.com/ns/deckhouse/")
+	installRepo := m.Repository("install")
+	m.Repository("")
+
+	mappingPath := filepath.Join(t.TempDir(), "mapping.json")
+	require.NoError(t, m.Save(mappingPath))
+
+	loaded, err := LoadMapping(mappingPath)
+	require.NoError(t, err)
+	require.Equal(t, "registry.example.com/ns/deckhouse", loaded.Root)
+	require.Equal(t, map[string]string{
+		"":        "registry.example.com/ns/deckhouse",
+		"install": installRepo,
+	}, loaded.Repositories)
+}
+
+func TestMappingResolve(t *testing.T) {
+	root := "registry.example.com/ns/deckhouse"
+
+	flattened := NewMapping(root)
+	flattened.Rename("modules/Foo", root+"-modules-foo-12345678")
+	require.Equal(t, root+"-modules-foo-12345678", flattened.Resolve("/modules/Foo/"))
+	require.Equal(t, RepositoryName(root, "install"), flattened.Resolve("install"))
+
+	nested := NewMapping(root)
+	nested.Nested = true
+	nested.Rename("modules/Foo", root+"/modules/foo")
+	require.Equal(t, root+"/modules/foo", nested.Resolve("modules/Foo"))
+	require.Equal(t, root+"/install", nested.Resolve("install"))
+	require.Equal(t, root, nested.Resolve(""))
+}
+
+func TestDepth(t *testing.T) {
+	require.Equal(t, 0, Depth("registry.example.com"))
+	require.Equal(t, 1, Depth("registry.example.com/deckhouse"))
+	require.Equal(t, 4, Depth("registry.example.com/ns/deckhouse/modules/x"))
+}
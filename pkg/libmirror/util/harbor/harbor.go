@@ -0,0 +1,285 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package harbor detects Harbor registries and generates scripts that prepare them to serve mirrored Deckhouse.
+package harbor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/httppool"
+)
+
+const (
+	DefaultRobotName = "d8-mirror-pull"
+
+	requestTimeout = 10 * time.Second
+)
+
+// Detect checks whether registry at host is Harbor by calling its ping API endpoint.
+func Detect(ctx context.Context, host string, insecure, skipTLSVerification bool) (bool, error) {
+	client := newClient(skipTLSVerification)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL(host, insecure)+"/api/v2.0/ping", nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("Ping Harbor API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return false, fmt.Errorf("Ping Harbor API: %w", err)
+	}
+	return strings.TrimSpace(string(body)) == "Pong", nil
+}
+
+// EnsureProject creates Harbor project with the given name unless it exists already, reporting whether it was created.
+// Credentials of Harbor user that is allowed to create projects are taken from authProvider.
+func EnsureProject(
+	ctx context.Context,
+	host, project string,
+	authProvider authn.Authenticator,
+	insecure, skipTLSVerification bool,
+) (bool, error) {
+	client := newClient(skipTLSVerification)
+	projectsURL := baseURL(host, insecure) + "/api/v2.0/projects"
+
+	req, err := newAPIRequest(ctx, http.MethodHead, projectsURL+"?project_name="+url.QueryEscape(project), nil, authProvider)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("Check Harbor project %s: %w", project, err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return false, nil
+	case http.StatusNotFound:
+	default:
+		return false, fmt.Errorf("Check Harbor project %s: unexpected status %s", project, resp.Status)
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"project_name": project,
+		"metadata":     map[string]string{"public": "false"},
+	})
+	if err != nil {
+		return false, err
+	}
+	req, err = newAPIRequest(ctx, http.MethodPost, projectsURL, body, authProvider)
+	if err != nil {
+		return false, err
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("Create Harbor project %s: %w", project, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("Create Harbor project %s: %s: %s", project, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return true, nil
+}
+
+func newAPIRequest(ctx context.Context, method, requestURL string, body []byte, authProvider authn.Authenticator) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authProvider == nil {
+		return req, nil
+	}
+
+	authConfig, err := authProvider.Authorization()
+	if err != nil {
+		return nil, fmt.Errorf("Get Harbor credentials: %w", err)
+	}
+	if authConfig.Username != "" {
+		req.SetBasicAuth(authConfig.Username, authConfig.Password)
+	}
+	return req, nil
+}
+
+func newClient(skipTLSVerification bool) *http.Client {
+	return &http.Client{Transport: httppool.Transport(skipTLSVerification), Timeout: requestTimeout}
+}
+
+func baseURL(host string, insecure bool) string {
+	if insecure {
+		return "http://" + host
+	}
+	return "https://" + host
+}
+
+// SetupParams describe where Deckhouse was pushed to Harbor.
+type SetupParams struct {
+	Host     string
+	Insecure bool
+	// RepoPath is path of Deckhouse repo in registry, e.g. "/deckhouse/ee", its first element is Harbor project.
+	RepoPath string
+	// Flattened is set when repositories were pushed next to RepoPath instead of inside it, see --flatten-repositories.
+	Flattened bool
+	// RobotName is the name of robot account that clusters use to pull Deckhouse images.
+	RobotName string
+}
+
+type scriptData struct {
+	URL          string
+	Project      string
+	RobotName    string
+	RepoPatterns []string
+	RepoPattern  string
+}
+
+// SetupScript generates idempotent bash script that creates Harbor project for pushed Deckhouse repo,
+// robot account that can only pull from it and retention policy that keeps every pushed artifact.
+func SetupScript(params SetupParams) ([]byte, error) {
+	repoPath := strings.Trim(path.Clean("/"+params.RepoPath), "/")
+	project, pathInProject, _ := strings.Cut(repoPath, "/")
+	if project == "" {
+		return nil, fmt.Errorf("Harbor project cannot be determined from repo path %q", params.RepoPath)
+	}
+
+	robotName := params.RobotName
+	if robotName == "" {
+		robotName = DefaultRobotName
+	}
+
+	patterns := []string{"**"}
+	if pathInProject != "" {
+		patterns = []string{pathInProject, pathInProject + "/**"}
+		if params.Flattened {
+			patterns = []string{pathInProject, pathInProject + "-*"}
+		}
+	}
+	repoPattern := patterns[0]
+	if len(patterns) > 1 {
+		repoPattern = "{" + strings.Join(patterns, ",") + "}"
+	}
+
+	buf := &bytes.Buffer{}
+	err := setupScriptTemplate.Execute(buf, scriptData{
+		URL:          baseURL(params.Host, params.Insecure),
+		Project:      project,
+		RobotName:    robotName,
+		RepoPatterns: patterns,
+		RepoPattern:  repoPattern,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Render Harbor setup script: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+var setupScriptTemplate = template.Must(template.New("harbor.sh").Parse(`#!/usr/bin/env bash
+# Prepares Harbor to serve Deckhouse images pushed by d8 mirror push.
+# Safe to run several times, existing objects are left as is.
+#
+# Requires curl and jq. Harbor administrator credentials are read from environment:
+#   HARBOR_USER=admin HARBOR_PASSWORD=... ./harbor.sh
+set -euo pipefail
+
+HARBOR_URL="${HARBOR_URL:-{{ .URL }}}"
+PROJECT="{{ .Project }}"
+ROBOT_NAME="{{ .RobotName }}"
+: "${HARBOR_USER:?HARBOR_USER must be set}"
+: "${HARBOR_PASSWORD:?HARBOR_PASSWORD must be set}"
+
+for tool in curl jq; do
+  command -v "$tool" >/dev/null || { echo "$tool is required" >&2; exit 1; }
+done
+
+api() {
+  local method="$1" endpoint="$2"
+  shift 2
+  curl -fsS -u "${HARBOR_USER}:${HARBOR_PASSWORD}" -X "$method" -H "Content-Type: application/json" \
+    "${HARBOR_URL}/api/v2.0${endpoint}" "$@"
+}
+
+# Project
+if api HEAD "/projects?project_name=${PROJECT}" -o /dev/null 2>/dev/null; then
+  echo "Project ${PROJECT} already exists"
+else
+  api POST /projects -d "{\"project_name\":\"${PROJECT}\",\"metadata\":{\"public\":\"false\"}}"
+  echo "Created project ${PROJECT}"
+fi
+PROJECT_ID="$(api GET "/projects/${PROJECT}" | jq -r .project_id)"
+
+# Robot account that can only pull from the project
+if api GET "/projects/${PROJECT}/robots?q=name%3D~${ROBOT_NAME}" | jq -e 'length > 0' >/dev/null; then
+  echo "Robot account ${ROBOT_NAME} already exists in project ${PROJECT}, its secret is not shown again"
+else
+  api POST /robots -d "{
+    \"name\": \"${ROBOT_NAME}\",
+    \"description\": \"Pulls Deckhouse images mirrored by d8 mirror push\",
+    \"level\": \"project\",
+    \"duration\": -1,
+    \"permissions\": [{
+      \"kind\": \"project\",
+      \"namespace\": \"${PROJECT}\",
+      \"access\": [{\"resource\": \"repository\", \"action\": \"pull\"}]
+    }]
+  }" | jq -r '"Created robot account \(.name) with secret \(.secret)"'
+fi
+
+# Retention policy that keeps every artifact of the mirrored repositories:
+{{- range .RepoPatterns }}
+#   {{ . }}
+{{- end }}
+RETENTION_POLICY="$(jq -cn --argjson project_id "$PROJECT_ID" '{
+  algorithm: "or",
+  rules: [{
+    action: "retain",
+    template: "always",
+    disabled: false,
+    params: {},
+    tag_selectors: [{kind: "doublestar", decoration: "matches", pattern: "**"}],
+    scope_selectors: {repository: [{kind: "doublestar", decoration: "repoMatches", pattern: "{{ .RepoPattern }}"}]}
+  }],
+  trigger: {kind: "Schedule", settings: {cron: ""}},
+  scope: {level: "project", ref: $project_id}
+}')"
+RETENTION_ID="$(api GET "/projects/${PROJECT}/metadatas/retention_id" | jq -r '.retention_id // empty')"
+if [ -n "$RETENTION_ID" ]; then
+  api PUT "/retentions/${RETENTION_ID}" -d "$RETENTION_POLICY"
+  echo "Updated retention policy ${RETENTION_ID} of project ${PROJECT}"
+else
+  api POST /retentions -d "$RETENTION_POLICY"
+  echo "Created retention policy for project ${PROJECT}"
+fi
+`))
@@ -0,0 +1,107 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package harbor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	harborServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2.0/ping" {
+			_, _ = w.Write([]byte("Pong"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer harborServer.Close()
+	otherServer := httptest.NewServer(http.NotFoundHandler())
+	defer otherServer.Close()
+
+	isHarbor, err := Detect(context.Background(), strings.TrimPrefix(harborServer.URL, "http://"), true, false)
+	require.NoError(t, err)
+	require.True(t, isHarbor)
+
+	isHarbor, err = Detect(context.Background(), strings.TrimPrefix(otherServer.URL, "http://"), true, false)
+	require.NoError(t, err)
+	require.False(t, isHarbor)
+}
+
+func TestSetupScript(t *testing.T) {
+	script, err := SetupScript(SetupParams{Host: "harbor.local", RepoPath: "/deckhouse/ee"})
+	require.NoError(t, err)
+	require.Contains(t, string(script), `HARBOR_URL="${HARBOR_URL:-https://harbor.local}"`)
+	require.Contains(t, string(script), `PROJECT="deckhouse"`)
+	require.Contains(t, string(script), `ROBOT_NAME="d8-mirror-pull"`)
+	require.Contains(t, string(script), `pattern: "{ee,ee/**}"`)
+	require.Contains(t, string(script), `\"action\": \"pull\"`)
+
+	script, err = SetupScript(SetupParams{Host: "harbor.local", RepoPath: "/deckhouse/ee", Flattened: true, RobotName: "cluster"})
+	require.NoError(t, err)
+	require.Contains(t, string(script), `pattern: "{ee,ee-*}"`)
+	require.Contains(t, string(script), `ROBOT_NAME="cluster"`)
+
+	_, err = SetupScript(SetupParams{Host: "harbor.local", RepoPath: "/"})
+	require.Error(t, err)
+}
+
+func TestEnsureProject(t *testing.T) {
+	projects := map[string]struct{}{"existing": {}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "admin" || password != "Harbor12345" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodHead:
+			if _, found := projects[r.URL.Query().Get("project_name")]; found {
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		case http.MethodPost:
+			project := struct {
+				Name string `json:"project_name"`
+			}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&project))
+			projects[project.Name] = struct{}{}
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	admin := authn.FromConfig(authn.AuthConfig{Username: "admin", Password: "Harbor12345"})
+
+	created, err := EnsureProject(context.Background(), host, "existing", admin, true, false)
+	require.NoError(t, err)
+	require.False(t, created)
+
+	created, err = EnsureProject(context.Background(), host, "deckhouse", admin, true, false)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Contains(t, projects, "deckhouse")
+
+	_, err = EnsureProject(context.Background(), host, "other", authn.Anonymous, true, false)
+	require.Error(t, err)
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package health reports liveness of long-running mirroring jobs to orchestrators like Kubernetes,
+// either by periodically touching a file or via HTTP endpoint, as long as the run makes progress.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+const (
+	DefaultStaleTimeout = 30 * time.Minute
+	defaultInterval     = 10 * time.Second
+)
+
+type Options struct {
+	// File is touched every interval while the run is healthy and removed once it is stale.
+	File string
+	// Addr to listen on for HTTP health checks, e.g. ":8080". Responds to every path.
+	Addr string
+	// StaleTimeout is how long the run may go without progress before being considered stuck.
+	StaleTimeout time.Duration
+	// Interval between health file updates.
+	Interval time.Duration
+}
+
+// Monitor considers a run healthy while its progress was updated within stale timeout.
+type Monitor struct {
+	run          *contexts.RunContext
+	startedAt    time.Time
+	staleTimeout time.Duration
+}
+
+func NewMonitor(run *contexts.RunContext, staleTimeout time.Duration) *Monitor {
+	if staleTimeout <= 0 {
+		staleTimeout = DefaultStaleTimeout
+	}
+	return &Monitor{run: run, startedAt: time.Now(), staleTimeout: staleTimeout}
+}
+
+func (m *Monitor) Healthy() bool {
+	lastActivity := m.run.LastActivity()
+	if lastActivity.Before(m.startedAt) {
+		lastActivity = m.startedAt
+	}
+	return time.Since(lastActivity) < m.staleTimeout
+}
+
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if !m.Healthy() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprintf(w, "no progress for more than %s\n", m.staleTimeout)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintln(w, "ok")
+}
+
+// Start begins health reporting for the run as configured by opts. Returned function stops it and cleans up.
+// If neither file nor address are set, Start does nothing.
+func Start(run *contexts.RunContext, opts Options) (func(), error) {
+	if opts.File == "" && opts.Addr == "" {
+		return func() {}, nil
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+
+	monitor := NewMonitor(run, opts.StaleTimeout)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	var server *http.Server
+	if opts.Addr != "" {
+		listener, err := net.Listen("tcp", opts.Addr)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("listen for health checks: %w", err)
+		}
+		server = &http.Server{Handler: monitor, ReadHeaderTimeout: 5 * time.Second}
+		go func() { _ = server.Serve(listener) }()
+	}
+
+	if opts.File != "" {
+		if err := touch(opts.File); err != nil {
+			cancel()
+			if server != nil {
+				_ = server.Close()
+			}
+			return nil, fmt.Errorf("write health file: %w", err)
+		}
+	}
+
+	go func() {
+		defer close(done)
+		if opts.File == "" {
+			<-ctx.Done()
+			return
+		}
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				updateHealthFile(monitor, opts.File)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+		if server != nil {
+			_ = server.Close()
+		}
+		if opts.File != "" {
+			_ = os.Remove(opts.File)
+		}
+	}, nil
+}
+
+func updateHealthFile(monitor *Monitor, path string) {
+	if monitor.Healthy() {
+		_ = touch(path)
+		return
+	}
+	_ = os.Remove(path)
+}
+
+func touch(path string) error {
+	return os.WriteFile(path, []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0o644)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestMonitorHealthy(t *testing.T) {
+	run := contexts.NewRunContext(context.Background())
+	monitor := NewMonitor(run, time.Hour)
+	require.True(t, monitor.Healthy())
+
+	recorder := httptest.NewRecorder()
+	monitor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	monitor.startedAt = time.Now().Add(-2 * time.Hour)
+	require.False(t, monitor.Healthy(), "Run without progress for longer than stale timeout is not healthy")
+
+	recorder = httptest.NewRecorder()
+	monitor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	run.Advance(contexts.StagePlatform, 1)
+	require.True(t, monitor.Healthy(), "Progress makes run healthy again")
+}
+
+func TestStartWritesAndRemovesHealthFile(t *testing.T) {
+	healthFile := filepath.Join(t.TempDir(), "healthy")
+	stop, err := Start(contexts.NewRunContext(context.Background()), Options{File: healthFile, Interval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	require.FileExists(t, healthFile)
+
+	stop()
+	_, err = os.Stat(healthFile)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestStartRemovesStaleHealthFile(t *testing.T) {
+	healthFile := filepath.Join(t.TempDir(), "healthy")
+	stop, err := Start(contexts.NewRunContext(context.Background()), Options{
+		File:         healthFile,
+		StaleTimeout: time.Nanosecond,
+		Interval:     10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer stop()
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(healthFile)
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+}
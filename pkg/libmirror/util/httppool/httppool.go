@@ -0,0 +1,243 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package httppool provides HTTP transports shared by all registry requests of the process,
+// so that connections are kept alive and reused instead of being opened for every request.
+package httppool
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	maxIdleConns        = 512
+	maxIdleConnsPerHost = 128
+	idleConnTimeout     = 90 * time.Second
+)
+
+var (
+	transports       = map[bool]*pooledTransport{}
+	hostRootCAs      = map[string]*x509.CertPool{}
+	hostCertificates = map[string]tls.Certificate{}
+	proxy            = http.ProxyFromEnvironment
+	transportsLock   sync.Mutex
+
+	stats poolStats
+)
+
+// Transport returns shared transport for registry requests, either verifying TLS certificates or not.
+// Transports are tuned for thousands of requests to a few registries: idle connections are kept per host
+// to be reused by the following requests and HTTP/2 is negotiated where registry supports it.
+func Transport(skipTLSVerification bool) http.RoundTripper {
+	transportsLock.Lock()
+	defer transportsLock.Unlock()
+
+	if t, found := transports[skipTLSVerification]; found {
+		return t
+	}
+	t := &pooledTransport{base: newTransport(skipTLSVerification), hosts: map[string]*http.Transport{}}
+	hostTLSConfig := func(host string) *tls.Config {
+		if _, found := t.hosts[host]; !found {
+			hostTransport := newTransport(skipTLSVerification)
+			if hostTransport.TLSClientConfig == nil {
+				hostTransport.TLSClientConfig = &tls.Config{}
+			}
+			t.hosts[host] = hostTransport
+		}
+		return t.hosts[host].TLSClientConfig
+	}
+	if !skipTLSVerification {
+		for host, rootCAs := range hostRootCAs {
+			hostTLSConfig(host).RootCAs = rootCAs
+		}
+	}
+	for host, cert := range hostCertificates {
+		hostTLSConfig(host).Certificates = []tls.Certificate{cert}
+	}
+	transports[skipTLSVerification] = t
+	return t
+}
+
+// SetHostRootCAs makes transports verify certificates of the given hosts against their own CA pools,
+// so that registries signed by different private CAs can be used together. Hosts are matched either with or without port.
+// Transports returned before the call keep verifying certificates as they did.
+func SetHostRootCAs(rootCAs map[string]*x509.CertPool) {
+	transportsLock.Lock()
+	defer transportsLock.Unlock()
+
+	hostRootCAs = rootCAs
+	transports = map[bool]*pooledTransport{}
+}
+
+// SetHostClientCertificates makes transports authenticate to the given hosts with their client certificates,
+// for registries that require mutual TLS. Hosts are matched either with or without port.
+// Transports returned before the call keep presenting certificates they did.
+func SetHostClientCertificates(certificates map[string]tls.Certificate) {
+	transportsLock.Lock()
+	defer transportsLock.Unlock()
+
+	hostCertificates = certificates
+	transports = map[bool]*pooledTransport{}
+}
+
+// SetProxy makes transports send requests through proxies chosen by proxyFunc instead of the ones
+// set by HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables. Nil proxyFunc brings environment back.
+// Transports returned before the call keep using proxies they did.
+func SetProxy(proxyFunc func(*http.Request) (*url.URL, error)) {
+	transportsLock.Lock()
+	defer transportsLock.Unlock()
+
+	if proxyFunc == nil {
+		proxyFunc = http.ProxyFromEnvironment
+	}
+	proxy = proxyFunc
+	transports = map[bool]*pooledTransport{}
+}
+
+func newTransport(skipTLSVerification bool) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if skipTLSVerification {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return transport
+}
+
+// pooledTransport records how connections of the pool are used.
+type pooledTransport struct {
+	base *http.Transport
+	// hosts are transports for hosts with their own CAs or client certificates, see SetHostRootCAs and SetHostClientCertificates.
+	hosts map[string]*http.Transport
+}
+
+func (t *pooledTransport) transportFor(u *url.URL) *http.Transport {
+	if hostTransport, found := t.hosts[u.Host]; found {
+		return hostTransport
+	}
+	if hostTransport, found := t.hosts[u.Hostname()]; found {
+		return hostTransport
+	}
+	return t.base
+}
+
+func (t *pooledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				stats.reusedConnections.Add(1)
+			} else {
+				stats.newConnections.Add(1)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = countingReader{ReadCloser: req.Body, count: &stats.bytesSent}
+		if getBody := req.GetBody; getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return countingReader{ReadCloser: body, count: &stats.bytesSent}, nil
+			}
+		}
+	}
+
+	stats.requests.Add(1)
+	resp, err := t.transportFor(req.URL).RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.ProtoMajor == 2 {
+		stats.http2Requests.Add(1)
+	}
+	resp.Body = countingReader{ReadCloser: resp.Body, count: &stats.bytesReceived}
+	return resp, nil
+}
+
+// countingReader adds amount of bytes read through it to count.
+type countingReader struct {
+	io.ReadCloser
+	count *atomic.Int64
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.count.Add(int64(n))
+	return n, err
+}
+
+type poolStats struct {
+	requests          atomic.Int64
+	newConnections    atomic.Int64
+	reusedConnections atomic.Int64
+	http2Requests     atomic.Int64
+	bytesSent         atomic.Int64
+	bytesReceived     atomic.Int64
+}
+
+// Stats describes usage of shared transports since the start of the process.
+type Stats struct {
+	Requests          int64
+	NewConnections    int64
+	ReusedConnections int64
+	HTTP2Requests     int64
+	// BytesSent and BytesReceived count bodies of requests and responses, headers and TLS overhead are not counted.
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// Snapshot returns current usage of shared transports.
+func Snapshot() Stats {
+	return Stats{
+		Requests:          stats.requests.Load(),
+		NewConnections:    stats.newConnections.Load(),
+		ReusedConnections: stats.reusedConnections.Load(),
+		HTTP2Requests:     stats.http2Requests.Load(),
+		BytesSent:         stats.bytesSent.Load(),
+		BytesReceived:     stats.bytesReceived.Load(),
+	}
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf(
+		"%d registry requests over %d new connections, %d requests reused connections, %d requests used HTTP/2",
+		s.Requests, s.NewConnections, s.ReusedConnections, s.HTTP2Requests,
+	)
+}
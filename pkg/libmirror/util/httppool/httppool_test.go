@@ -0,0 +1,133 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package httppool
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportReusesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	require.Same(t, Transport(false), Transport(false))
+	require.NotSame(t, Transport(false), Transport(true))
+
+	client := &http.Client{Transport: Transport(false)}
+	before := Snapshot()
+	for i := 0; i < 10; i++ {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+	after := Snapshot()
+
+	require.Equal(t, int64(10), after.Requests-before.Requests)
+	require.Equal(t, int64(1), after.NewConnections-before.NewConnections)
+	require.Equal(t, int64(9), after.ReusedConnections-before.ReusedConnections)
+	require.Equal(t, int64(20), after.BytesReceived-before.BytesReceived)
+
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"key":"value"}`))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, int64(15), Snapshot().BytesSent-after.BytesSent)
+}
+
+func TestTransportVerifiesHostsAgainstTheirOwnCAs(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	})
+	sourceServer, targetServer := httptest.NewTLSServer(handler), httptest.NewTLSServer(handler)
+	defer sourceServer.Close()
+	defer targetServer.Close()
+
+	sourceCAs := x509.NewCertPool()
+	sourceCAs.AddCert(sourceServer.Certificate())
+	sourceURL, err := url.Parse(sourceServer.URL)
+	require.NoError(t, err)
+	SetHostRootCAs(map[string]*x509.CertPool{sourceURL.Host: sourceCAs})
+	t.Cleanup(func() { SetHostRootCAs(map[string]*x509.CertPool{}) })
+
+	client := &http.Client{Transport: Transport(false)}
+	resp, err := client.Get(sourceServer.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	_, err = client.Get(targetServer.URL)
+	require.ErrorContains(t, err, "certificate signed by unknown authority")
+}
+
+func TestTransportUsesProxy(t *testing.T) {
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proxied " + r.Host))
+	}))
+	defer proxyServer.Close()
+	proxyURL, err := url.Parse(proxyServer.URL)
+	require.NoError(t, err)
+
+	SetProxy(http.ProxyURL(proxyURL))
+	defer SetProxy(nil)
+
+	client := &http.Client{Transport: Transport(false)}
+	resp, err := client.Get("http://registry.example.com/v2/")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, "proxied registry.example.com", string(body))
+}
+
+func TestTransportPresentsHostClientCertificates(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%d client certificates", len(r.TLS.PeerCertificates))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: Transport(true)}
+	_, err = client.Get(server.URL)
+	require.Error(t, err, "server must reject clients without certificate")
+
+	// Certificate of the test server is good enough to authenticate with, it is never verified.
+	SetHostClientCertificates(map[string]tls.Certificate{serverURL.Hostname(): server.TLS.Certificates[0]})
+	t.Cleanup(func() { SetHostClientCertificates(map[string]tls.Certificate{}) })
+
+	client = &http.Client{Transport: Transport(true)}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, "1 client certificates", string(body))
+}
@@ -2,6 +2,7 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"strings"
@@ -15,17 +16,32 @@ const processPrefix = "║"
 type SLogger struct {
 	delegate     *slog.Logger
 	processDepth int
+	// structured loggers do not draw process nesting, it is recorded into "depth" attribute instead.
+	structured bool
 }
 
 func NewSLogger(logLevel slog.Level) *SLogger {
+	return NewSLoggerWithWriter(os.Stdout, logLevel)
+}
+
+// NewSLoggerWithWriter creates logger writing to w instead of standard output.
+func NewSLoggerWithWriter(w io.Writer, logLevel slog.Level) *SLogger {
 	return &SLogger{
-		delegate: slog.New(slogor.NewHandler(os.Stdout, slogor.Options{
+		delegate: slog.New(slogor.NewHandler(w, slogor.Options{
 			TimeFormat: time.StampMilli,
 			Level:      logLevel,
 		})),
 	}
 }
 
+// NewJSONSLoggerWithWriter creates logger writing records to w as JSON objects, one per line.
+func NewJSONSLoggerWithWriter(w io.Writer, logLevel slog.Level) *SLogger {
+	return &SLogger{
+		delegate:   slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: logLevel})),
+		structured: true,
+	}
+}
+
 func (s *SLogger) DebugF(format string, a ...any) {
 	s.delegate.Debug(s.formatRecord(format, a...))
 }
@@ -51,6 +67,10 @@ func (s *SLogger) WarnLn(a ...any) {
 }
 
 func (s *SLogger) Process(topic string, run func() error) error {
+	if s.structured {
+		return s.structuredProcess(topic, run)
+	}
+
 	start := time.Now()
 	s.delegate.Info(strings.Repeat("║", s.processDepth) + "╔ " + topic)
 	s.processDepth += 1
@@ -65,8 +85,24 @@ func (s *SLogger) Process(topic string, run func() error) error {
 	return nil
 }
 
+func (s *SLogger) structuredProcess(topic string, run func() error) error {
+	start := time.Now()
+	s.delegate.Info(topic+" started", "depth", s.processDepth)
+	s.processDepth += 1
+	defer func() { s.processDepth -= 1 }()
+	if err := run(); err != nil {
+		s.delegate.Error(topic+" failed", "depth", s.processDepth-1, "error", err)
+		return err
+	}
+	s.delegate.Info(topic+" succeeded", "depth", s.processDepth-1, "duration", time.Since(start).String())
+	return nil
+}
+
 func (s *SLogger) formatRecord(template string, args ...any) string {
 	prefix := strings.Repeat(processPrefix, s.processDepth)
+	if s.structured {
+		prefix = ""
+	}
 
 	if template == "" {
 		msg := &strings.Builder{}
@@ -75,8 +111,16 @@ func (s *SLogger) formatRecord(template string, args ...any) string {
 			msg.WriteString(fmt.Sprintf(" %v", arg))
 		}
 
-		return msg.String()
+		return s.trimStructured(msg.String())
 	}
 
-	return fmt.Sprintf(prefix+" "+template, args...)
+	return s.trimStructured(fmt.Sprintf(prefix+" "+template, args...))
+}
+
+// trimStructured drops space that separates process prefix from the message, structured records have no prefix.
+func (s *SLogger) trimStructured(msg string) string {
+	if s.structured {
+		return strings.TrimPrefix(msg, " ")
+	}
+	return msg
 }
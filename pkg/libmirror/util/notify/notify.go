@@ -0,0 +1,217 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package notify posts summaries of finished mirroring runs to webhooks, so that unattended scheduled mirroring
+// is watched from chats or monitoring systems instead of its logs.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/httppool"
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+// Template is the shape of notification body.
+type Template string
+
+const (
+	// TemplateJSON posts Summary as is, for webhooks of CI systems and custom receivers.
+	TemplateJSON Template = "json"
+	// TemplateSlack posts Summary.Text as Slack incoming webhook message.
+	TemplateSlack Template = "slack"
+	// TemplateTelegram posts Summary.Text as Telegram Bot API sendMessage call,
+	// chat is given with chat_id query parameter of the webhook URL.
+	TemplateTelegram Template = "telegram"
+)
+
+// Templates are all supported notification templates.
+var Templates = []Template{TemplateJSON, TemplateSlack, TemplateTelegram}
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+
+	sendTimeout = 30 * time.Second
+	// maxResponseBody is the most bytes of webhook error response that are put into error message.
+	maxResponseBody = 512
+)
+
+// Summary describes a finished mirroring run.
+type Summary struct {
+	reportschema.Header
+	Timing reportschema.Timing `json:"timing"`
+
+	// Operation is the mirror command that finished: "pull", "push" or "sync".
+	Operation string `json:"operation"`
+	Status    string `json:"status"`
+	// Versions are Deckhouse releases mirrored by the run, empty if the run did not get to look them up.
+	Versions []string `json:"versions"`
+	// BytesReceived and BytesSent are amounts of registry traffic of the run.
+	BytesReceived int64 `json:"bytesReceived"`
+	BytesSent     int64 `json:"bytesSent"`
+	// Errors are the errors run failed with.
+	Errors []string `json:"errors"`
+}
+
+// Text returns summary as a short human-readable message for chats.
+func (s *Summary) Text() string {
+	duration := time.Duration(s.Timing.DurationSeconds * float64(time.Second)).Round(time.Second).String()
+	text := &strings.Builder{}
+	if s.Status == StatusSucceeded {
+		fmt.Fprintf(text, "✅ d8 mirror %s succeeded in %s", s.Operation, duration)
+	} else {
+		fmt.Fprintf(text, "❌ d8 mirror %s failed after %s", s.Operation, duration)
+	}
+	if len(s.Versions) > 0 {
+		fmt.Fprintf(text, "\nReleases: %s", strings.Join(s.Versions, ", "))
+	}
+	fmt.Fprintf(text, "\nTraffic: %s received, %s sent", formatSize(s.BytesReceived), formatSize(s.BytesSent))
+	for _, err := range s.Errors {
+		fmt.Fprintf(text, "\nError: %s", err)
+	}
+	return text.String()
+}
+
+func formatSize(size int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(size)/1024/1024)
+}
+
+// Run collects summary of a single mirroring run.
+type Run struct {
+	operation string
+	start     time.Time
+	traffic   httppool.Stats
+	versions  []string
+}
+
+// StartRun starts measuring duration and registry traffic of the run of operation.
+func StartRun(operation string) *Run {
+	return &Run{
+		operation: operation,
+		start:     time.Now(),
+		traffic:   httppool.Snapshot(),
+		versions:  make([]string, 0),
+	}
+}
+
+// SetVersions records Deckhouse releases mirrored by the run.
+func (r *Run) SetVersions(versions []semver.Version) {
+	r.versions = make([]string, 0, len(versions))
+	for _, version := range versions {
+		r.versions = append(r.versions, "v"+version.String())
+	}
+}
+
+// Summary returns summary of the run that finished with err.
+func (r *Run) Summary(err error) *Summary {
+	traffic := httppool.Snapshot()
+	summary := &Summary{
+		Header:        reportschema.NewHeader(reportschema.KindRunSummary),
+		Timing:        reportschema.TimingSince(r.start),
+		Operation:     r.operation,
+		Status:        StatusSucceeded,
+		Versions:      slices.Clone(r.versions),
+		BytesReceived: traffic.BytesReceived - r.traffic.BytesReceived,
+		BytesSent:     traffic.BytesSent - r.traffic.BytesSent,
+		Errors:        make([]string, 0),
+	}
+	if err != nil {
+		summary.Status = StatusFailed
+		summary.Errors = append(summary.Errors, err.Error())
+	}
+	return summary
+}
+
+// Notifier posts run summaries to a webhook.
+type Notifier struct {
+	url      string
+	template Template
+	client   *http.Client
+}
+
+// NewNotifier returns notifier that posts to webhookURL with template, or nil if webhookURL is empty.
+func NewNotifier(webhookURL string, template Template) (*Notifier, error) {
+	if webhookURL == "" {
+		return nil, nil
+	}
+	if !slices.Contains(Templates, template) {
+		return nil, fmt.Errorf("unknown notification template %q, expected one of %v", template, Templates)
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an http or https URL", webhookURL)
+	}
+	if template == TemplateTelegram && u.Query().Get("chat_id") == "" {
+		return nil, errors.New("telegram webhook URL must set chat_id query parameter")
+	}
+	return &Notifier{url: webhookURL, template: template, client: &http.Client{Timeout: sendTimeout}}, nil
+}
+
+// Send posts summary to the webhook. Nil *Notifier sends nothing.
+func (n *Notifier) Send(ctx context.Context, summary *Summary) error {
+	if n == nil {
+		return nil
+	}
+
+	var payload any = summary
+	if n.template != TemplateJSON {
+		payload = map[string]string{"text": summary.Text()}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+		return fmt.Errorf("webhook responded with %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Notify sends summary and only logs a warning if it cannot be sent, as mirroring itself is done by then.
+func (n *Notifier) Notify(logger contexts.Logger, summary *Summary) {
+	if err := n.Send(context.Background(), summary); err != nil {
+		logger.WarnF("⚠️ Send completion notification: %v", err)
+	}
+}
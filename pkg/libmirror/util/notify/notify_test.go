@@ -0,0 +1,91 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+func TestNotifierPostsSummary(t *testing.T) {
+	received := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer server.Close()
+
+	run := StartRun("pull")
+	run.SetVersions([]semver.Version{*semver.MustParse("1.60.1"), *semver.MustParse("1.61.0")})
+	summary := run.Summary(errors.New("Pull images: connection reset"))
+
+	notifier, err := NewNotifier(server.URL, TemplateJSON)
+	require.NoError(t, err)
+	require.NoError(t, notifier.Send(context.Background(), summary))
+	posted := &Summary{}
+	require.NoError(t, json.Unmarshal(<-received, posted))
+	require.Equal(t, reportschema.KindRunSummary, posted.Kind)
+	require.Equal(t, StatusFailed, posted.Status)
+	require.Equal(t, []string{"v1.60.1", "v1.61.0"}, posted.Versions)
+	require.Equal(t, []string{"Pull images: connection reset"}, posted.Errors)
+
+	notifier, err = NewNotifier(server.URL, TemplateSlack)
+	require.NoError(t, err)
+	require.NoError(t, notifier.Send(context.Background(), summary))
+	message := map[string]string{}
+	require.NoError(t, json.Unmarshal(<-received, &message))
+	require.Contains(t, message["text"], "d8 mirror pull failed after 0s")
+	require.Contains(t, message["text"], "Releases: v1.60.1, v1.61.0")
+}
+
+func TestNotifierReportsRejectedNotification(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	notifier, err := NewNotifier(server.URL, TemplateSlack)
+	require.NoError(t, err)
+	err = notifier.Send(context.Background(), StartRun("push").Summary(nil))
+	require.ErrorContains(t, err, "webhook responded with 403 Forbidden: invalid_token")
+}
+
+func TestNewNotifier(t *testing.T) {
+	notifier, err := NewNotifier("", TemplateJSON)
+	require.NoError(t, err)
+	require.Nil(t, notifier)
+	require.NoError(t, notifier.Send(context.Background(), StartRun("sync").Summary(nil)), "nil notifier sends nothing")
+
+	_, err = NewNotifier("https://hooks.example.com/d8", "teams")
+	require.ErrorContains(t, err, `unknown notification template "teams"`)
+	_, err = NewNotifier("hooks.example.com/d8", TemplateJSON)
+	require.ErrorContains(t, err, "is not an http or https URL")
+	_, err = NewNotifier("https://api.telegram.org/bot123:token/sendMessage", TemplateTelegram)
+	require.ErrorContains(t, err, "chat_id")
+	_, err = NewNotifier("https://api.telegram.org/bot123:token/sendMessage?chat_id=-100123", TemplateTelegram)
+	require.NoError(t, err)
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package progress streams state of a mirroring run over a Unix domain socket,
+// so that host-level UIs can display it without parsing d8 logs.
+//
+// Protocol: every client connected to the socket receives a stream of newline-delimited JSON Message objects.
+// The first message is sent right after connecting, then one every update interval,
+// and a final one with type "done" when the run is over, after which the connection is closed.
+// Clients must ignore unknown fields and message types. Fields are only added within the same protocol version,
+// any incompatible change increments ProtocolVersion, which is sent with every message.
+// Anything clients write to the socket is ignored.
+package progress
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+	"github.com/deckhouse/deckhouse-cli/pkg/reportschema"
+)
+
+const ProtocolVersion = 1
+
+// Message types.
+const (
+	TypeProgress = "progress"
+	TypeDone     = "done"
+)
+
+const (
+	defaultInterval = time.Second
+	writeTimeout    = 5 * time.Second
+)
+
+type Message struct {
+	Version int       `json:"version"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	// Operation is the d8 command reporting its progress, e.g. "pull" or "push".
+	Operation string  `json:"operation"`
+	Stages    []Stage `json:"stages"`
+
+	ImagesPulled int64 `json:"imagesPulled"`
+	ReposPushed  int64 `json:"reposPushed"`
+
+	// Error is set in "done" message if the run failed.
+	Error string `json:"error,omitempty"`
+}
+
+type Stage struct {
+	Name  string `json:"name"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+type Options struct {
+	// SocketPath is the path to create Unix domain socket at. Stale socket file at this path is replaced.
+	SocketPath string
+	// Operation is reported to clients in every message.
+	Operation string
+	// Interval between progress updates.
+	Interval time.Duration
+}
+
+type server struct {
+	run       *contexts.RunContext
+	operation string
+	listener  net.Listener
+
+	mu      sync.Mutex
+	clients map[net.Conn]struct{}
+	closed  bool
+}
+
+// Serve starts streaming progress of the run over the socket as configured by opts.
+// Returned function sends final message with the run result to all clients and stops the server.
+// If socket path is not set, Serve does nothing.
+func Serve(run *contexts.RunContext, opts Options) (func(runErr error), error) {
+	if opts.SocketPath == "" {
+		return func(error) {}, nil
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+
+	if err := removeStaleSocket(opts.SocketPath); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", opts.SocketPath)
+	if err != nil {
+		return nil, fmt.Errorf("listen for progress clients: %w", err)
+	}
+
+	s := &server{
+		run:       run,
+		operation: opts.Operation,
+		listener:  listener,
+		clients:   make(map[net.Conn]struct{}),
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go s.acceptClients()
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.broadcast(s.message(TypeProgress, nil))
+			}
+		}
+	}()
+
+	return func(runErr error) {
+		close(stop)
+		<-done
+		_ = s.listener.Close()
+		s.broadcast(s.message(TypeDone, runErr))
+		s.disconnectAll()
+		_ = os.Remove(opts.SocketPath)
+	}, nil
+}
+
+func (s *server) acceptClients() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		if err = send(conn, s.message(TypeProgress, nil)); err != nil {
+			_ = conn.Close()
+			continue
+		}
+		s.mu.Lock()
+		if s.closed {
+			_ = conn.Close()
+		} else {
+			s.clients[conn] = struct{}{}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *server) broadcast(msg *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.clients {
+		if err := send(conn, msg); err != nil {
+			_ = conn.Close()
+			delete(s.clients, conn)
+		}
+	}
+}
+
+func (s *server) disconnectAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for conn := range s.clients {
+		_ = conn.Close()
+		delete(s.clients, conn)
+	}
+}
+
+func (s *server) message(msgType string, runErr error) *Message {
+	msg := &Message{
+		Version:   ProtocolVersion,
+		Type:      msgType,
+		Time:      time.Now().In(reportschema.Location()),
+		Operation: s.operation,
+		Stages:    make([]Stage, 0),
+	}
+	if s.run != nil {
+		for _, stage := range s.run.Progress.Snapshot() {
+			msg.Stages = append(msg.Stages, Stage{Name: stage.Stage, Done: stage.Done, Total: stage.Total})
+		}
+		msg.ImagesPulled = s.run.Metrics.ImagesPulled.Load()
+		msg.ReposPushed = s.run.Metrics.ReposPushed.Load()
+	}
+	if runErr != nil {
+		msg.Error = runErr.Error()
+	}
+	return msg
+}
+
+func send(conn net.Conn, msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err = conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
+
+func removeStaleSocket(socketPath string) error {
+	stat, err := os.Lstat(socketPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("check progress socket path: %w", err)
+	case stat.Mode()&fs.ModeSocket == 0:
+		return fmt.Errorf("%s exists and is not a socket", socketPath)
+	}
+	if err = os.Remove(socketPath); err != nil {
+		return fmt.Errorf("remove stale progress socket: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package progress
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+func TestServeStreamsProgress(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "progress.sock")
+	run := contexts.NewRunContext(context.Background())
+	run.AddTotal(contexts.StagePush, 3)
+	run.Advance(contexts.StagePush, 1)
+
+	stop, err := Serve(run, Options{SocketPath: socketPath, Operation: "push", Interval: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	conn, err := net.Dial("unix", socketPath)
+	require.NoError(t, err)
+	defer conn.Close()
+	messages := bufio.NewScanner(conn)
+
+	msg := readMessage(t, messages)
+	require.Equal(t, ProtocolVersion, msg.Version)
+	require.Equal(t, TypeProgress, msg.Type)
+	require.Equal(t, "push", msg.Operation)
+	require.Equal(t, []Stage{{Name: contexts.StagePush, Done: 1, Total: 3}}, msg.Stages)
+
+	run.Advance(contexts.StagePush, 2)
+	require.Eventually(t, func() bool {
+		msg = readMessage(t, messages)
+		return msg.Stages[0].Done == 3
+	}, time.Second, time.Millisecond)
+
+	stop(errors.New("registry is gone"))
+	for msg.Type != TypeDone {
+		msg = readMessage(t, messages)
+	}
+	require.Equal(t, "registry is gone", msg.Error)
+	require.False(t, messages.Scan(), "Connection should be closed after final message")
+
+	_, err = os.Stat(socketPath)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestServeRefusesToReplaceRegularFile(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "progress.sock")
+	require.NoError(t, os.WriteFile(socketPath, []byte("data"), 0o644))
+
+	_, err := Serve(nil, Options{SocketPath: socketPath})
+	require.Error(t, err)
+}
+
+func readMessage(t *testing.T, scanner *bufio.Scanner) *Message {
+	t.Helper()
+	require.True(t, scanner.Scan(), scanner.Err())
+	msg := &Message{}
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), msg))
+	return msg
+}
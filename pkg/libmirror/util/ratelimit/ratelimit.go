@@ -0,0 +1,187 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ratelimit limits bandwidth of registry transfers for operators mirroring over constrained links.
+//
+// All transfers of the process share one token bucket, so the limit holds no matter how many
+// images are pulled or pushed in parallel.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxChunk is the most bytes that are read at once from a limited stream,
+// so that waits between reads stay short and transfers progress smoothly.
+const maxChunk = 32 * 1024
+
+// Limiter is a token bucket that refills at the rate of bytes per second and holds at most one second worth of tokens.
+type Limiter struct {
+	rate float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns limiter that lets through bytesPerSecond on average.
+func NewLimiter(bytesPerSecond int64) *Limiter {
+	return &Limiter{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+	}
+}
+
+// WaitN takes n bytes worth of tokens from the bucket, waiting until they are refilled if the bucket runs short.
+// Tokens are taken before waiting, so concurrent transfers are let through in the order they came.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Reader returns reader that reads from r no faster than limiter allows.
+func (l *Limiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &reader{ctx: ctx, r: r, limiter: l}
+}
+
+type reader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > maxChunk {
+		p = p[:maxChunk]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+var active atomic.Pointer[Limiter]
+
+// Enable makes Wrap limit transfers with l, until returned func is called.
+func Enable(l *Limiter) (disable func()) {
+	active.Store(l)
+	return func() { active.CompareAndSwap(l, nil) }
+}
+
+// Wrap returns transport that limits bodies of requests and responses with the enabled limiter, if there is one.
+func Wrap(base http.RoundTripper) http.RoundTripper {
+	l := active.Load()
+	if l == nil {
+		return base
+	}
+	return &transport{limiter: l, base: base}
+}
+
+type transport struct {
+	limiter *Limiter
+	base    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if req.Body != nil && req.Body != http.NoBody {
+		body := req.Body
+		req = req.Clone(ctx)
+		req.Body = readCloser{Reader: t.limiter.Reader(ctx, body), Closer: body}
+		if getBody := req.GetBody; getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return readCloser{Reader: t.limiter.Reader(ctx, body), Closer: body}, nil
+			}
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	resp.Body = readCloser{Reader: t.limiter.Reader(ctx, resp.Body), Closer: resp.Body}
+	return resp, nil
+}
+
+var rateUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	// Longer suffixes go first, so that "MiB" is not taken for "B".
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// ParseRate parses bytes per second like 50MiB, 500KB or 1048576, with optional /s suffix.
+func ParseRate(s string) (int64, error) {
+	value := strings.TrimSuffix(strings.TrimSpace(s), "/s")
+	multiplier := int64(1)
+	for _, unit := range rateUnits {
+		if number, found := strings.CutSuffix(value, unit.suffix); found {
+			value, multiplier = strings.TrimSpace(number), unit.multiplier
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a rate like 50MiB or 500KB", s)
+	}
+	rate := int64(number * float64(multiplier))
+	if rate <= 0 {
+		return 0, fmt.Errorf("rate %q must be positive", s)
+	}
+	return rate, nil
+}
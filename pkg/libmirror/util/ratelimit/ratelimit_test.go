@@ -0,0 +1,94 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	for input, expected := range map[string]int64{
+		"50MiB":   50 << 20,
+		"500KB":   500 * 1000,
+		"1.5GiB":  3 << 29,
+		"1048576": 1 << 20,
+		"10MB/s":  10 * 1000 * 1000,
+		"64 KiB":  64 << 10,
+	} {
+		rate, err := ParseRate(input)
+		require.NoError(t, err, input)
+		require.Equal(t, expected, rate, input)
+	}
+
+	for _, input := range []string{"", "fast", "0MiB", "-1KB", "10Mbit"} {
+		_, err := ParseRate(input)
+		require.Error(t, err, input)
+	}
+}
+
+func TestReaderIsLimited(t *testing.T) {
+	limiter := NewLimiter(100 * 1024)
+	data := bytes.Repeat([]byte{1}, 150*1024)
+
+	start := time.Now()
+	read, err := io.ReadAll(limiter.Reader(context.Background(), bytes.NewReader(data)))
+	require.NoError(t, err)
+	require.Equal(t, data, read)
+	// First 100KiB are let through by the full bucket, the rest takes half a second to refill.
+	require.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestReaderStopsOnCancel(t *testing.T) {
+	limiter := NewLimiter(1024)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := io.ReadAll(limiter.Reader(ctx, bytes.NewReader(make([]byte, 10*1024))))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWrapLimitsRequestsAndResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	require.Same(t, http.DefaultTransport, Wrap(http.DefaultTransport), "transport must not be wrapped unless limiter is enabled")
+
+	disable := Enable(NewLimiter(64 * 1024))
+	client := &http.Client{Transport: Wrap(http.DefaultTransport)}
+	disable()
+
+	start := time.Now()
+	resp, err := client.Post(server.URL, "application/octet-stream", bytes.NewReader(make([]byte, 64*1024)))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Len(t, body, 64*1024)
+	// Upload drains the full bucket, so the download of the same size has to wait for it to refill.
+	require.GreaterOrEqual(t, time.Since(start), 800*time.Millisecond)
+}
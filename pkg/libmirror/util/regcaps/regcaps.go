@@ -0,0 +1,328 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package regcaps probes registries for optional parts of distribution API they implement,
+// so that mirroring can pick code paths that work best with the registry at hand.
+package regcaps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Support is the result of probing a single registry capability.
+type Support int
+
+const (
+	// Unknown is reported when probe could not tell if capability is supported, e.g. because of missing permissions.
+	Unknown Support = iota
+	Supported
+	Unsupported
+)
+
+func (s Support) String() string {
+	switch s {
+	case Supported:
+		return "yes"
+	case Unsupported:
+		return "no"
+	default:
+		return "unknown"
+	}
+}
+
+// Capabilities describe optional APIs implemented by registry.
+type Capabilities struct {
+	Registry string
+	// APIVersion is the value of Docker-Distribution-API-Version header of /v2/ endpoint, empty if registry omits it.
+	APIVersion string
+
+	Catalog   Support
+	Referrers Support
+	// BlobMount and ChunkedUpload are only probed with write access, see Options.Write.
+	BlobMount     Support
+	ChunkedUpload Support
+}
+
+// Matrix formats capabilities as a list of aligned "name value" lines to be printed one per line.
+func (c *Capabilities) Matrix() []string {
+	apiVersion := c.APIVersion
+	if apiVersion == "" {
+		apiVersion = "not reported"
+	}
+	rows := [][2]string{
+		{"Registry", c.Registry},
+		{"API version", apiVersion},
+		{"Catalog", c.Catalog.String()},
+		{"Referrers", c.Referrers.String()},
+		{"Blob mount", c.BlobMount.String()},
+		{"Chunked upload", c.ChunkedUpload.String()},
+	}
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		lines = append(lines, fmt.Sprintf("%-15s %s", row[0], row[1]))
+	}
+	return lines
+}
+
+type Options struct {
+	Auth authn.Authenticator
+	// Transport is used to send probe requests, http.DefaultTransport if nil.
+	Transport http.RoundTripper
+	// Write enables probes of upload APIs. They upload a tiny "{}" blob to the repository, same as empty OCI config.
+	Write bool
+}
+
+const requestTimeout = 30 * time.Second
+
+// probeBlob is uploaded by write probes, it is the empty JSON object used as config of OCI artifacts.
+var probeBlob = []byte("{}")
+
+const probeBlobDigest = "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+
+// unsupportedStatuses are returned by registries for API endpoints that they do not implement or have disabled.
+// Authorization failures are not among them: they tell about permissions of the credentials rather than
+// about the API, so such probes are reported as Unknown.
+var unsupportedStatuses = []int{
+	http.StatusBadRequest,
+	http.StatusNotFound,
+	http.StatusMethodNotAllowed,
+	http.StatusNotImplemented,
+}
+
+// Probe checks which optional APIs registry of repo implements.
+// Error is only returned if registry is unreachable or does not implement distribution API at all,
+// failures of separate probes are reported as Unknown support.
+func Probe(ctx context.Context, repo name.Repository, opts Options) (*Capabilities, error) {
+	if opts.Auth == nil {
+		opts.Auth = authn.Anonymous
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	caps := &Capabilities{Registry: repo.RegistryStr()}
+	apiVersion, err := ping(ctx, repo.Registry, opts.Transport)
+	if err != nil {
+		return nil, err
+	}
+	caps.APIVersion = apiVersion
+
+	scopes := []string{repo.Scope(transport.PullScope), "registry:catalog:*"}
+	if opts.Write {
+		scopes[0] = repo.Scope(transport.PushScope)
+	}
+	rt, err := transport.NewWithContext(ctx, repo.Registry, opts.Auth, opts.Transport, scopes)
+	if err != nil {
+		return nil, fmt.Errorf("Authenticate to %s: %w", repo.RegistryStr(), err)
+	}
+	p := &prober{client: &http.Client{Transport: rt}, repo: repo}
+
+	caps.Catalog = p.catalog(ctx)
+	caps.Referrers = p.referrers(ctx)
+	if opts.Write {
+		caps.ChunkedUpload = p.chunkedUpload(ctx)
+		if caps.ChunkedUpload == Supported {
+			caps.BlobMount = p.blobMount(ctx)
+		}
+	}
+	if caps.APIVersion == "" {
+		// Some registries only report API version to authenticated clients
+		caps.APIVersion = p.apiVersion
+	}
+	return caps, nil
+}
+
+func ping(ctx context.Context, registry name.Registry, rt http.RoundTripper) (string, error) {
+	pingURL := url.URL{Scheme: registry.Scheme(), Host: registry.RegistryStr(), Path: "/v2/"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pingURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Ping %s: %w", registry.RegistryStr(), err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnauthorized {
+		return "", fmt.Errorf("Ping %s: registry API is not available: %s", registry.RegistryStr(), resp.Status)
+	}
+	return resp.Header.Get("Docker-Distribution-API-Version"), nil
+}
+
+type prober struct {
+	client     *http.Client
+	repo       name.Repository
+	apiVersion string
+}
+
+func (p *prober) catalog(ctx context.Context) Support {
+	resp, err := p.do(ctx, http.MethodGet, p.url("/v2/_catalog", url.Values{"n": {"1"}}), nil, nil)
+	if err != nil {
+		return Unknown
+	}
+	return supportFromStatus(resp, http.StatusOK)
+}
+
+func (p *prober) referrers(ctx context.Context) Support {
+	resp, err := p.do(ctx, http.MethodGet, p.repoURL("/referrers/"+probeBlobDigest, nil), nil, nil)
+	if err != nil {
+		return Unknown
+	}
+	// Registries without referrers API route the request as an unknown one, while missing repository
+	// is reported the same way by registries implementing it, so the answer cannot be told from that.
+	if hasErrorCode(resp, transport.NameUnknownErrorCode) {
+		return Unknown
+	}
+	return supportFromStatus(resp, http.StatusOK)
+}
+
+// chunkedUpload uploads probe blob with a single PATCH request, as chunked upload protocol of OCI distribution spec describes.
+func (p *prober) chunkedUpload(ctx context.Context) Support {
+	resp, err := p.do(ctx, http.MethodPost, p.repoURL("/blobs/uploads/", nil), nil, nil)
+	if err != nil || resp.StatusCode != http.StatusAccepted {
+		return Unknown
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return Unknown
+	}
+
+	headers := map[string]string{
+		"Content-Type":  "application/octet-stream",
+		"Content-Range": fmt.Sprintf("0-%d", len(probeBlob)-1),
+	}
+	resp, err = p.do(ctx, http.MethodPatch, location.String(), probeBlob, headers)
+	if err != nil {
+		return Unknown
+	}
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNoContent {
+		p.cancelUpload(ctx, location.String())
+		return supportFromStatus(resp)
+	}
+	if location, err = resp.Location(); err != nil {
+		return Unknown
+	}
+
+	query := location.Query()
+	query.Set("digest", probeBlobDigest)
+	location.RawQuery = query.Encode()
+	resp, err = p.do(ctx, http.MethodPut, location.String(), nil, nil)
+	if err != nil {
+		return Unknown
+	}
+	return supportFromStatus(resp, http.StatusCreated)
+}
+
+// blobMount asks registry to mount probe blob uploaded by chunkedUpload. Registries without mount support
+// start a regular upload instead, which is cancelled right away.
+func (p *prober) blobMount(ctx context.Context) Support {
+	query := url.Values{"mount": {probeBlobDigest}, "from": {p.repo.RepositoryStr()}}
+	resp, err := p.do(ctx, http.MethodPost, p.repoURL("/blobs/uploads/", query), nil, nil)
+	if err != nil {
+		return Unknown
+	}
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return Supported
+	case http.StatusAccepted:
+		if location, err := resp.Location(); err == nil {
+			p.cancelUpload(ctx, location.String())
+		}
+		return Unsupported
+	default:
+		return Unknown
+	}
+}
+
+func (p *prober) cancelUpload(ctx context.Context, location string) {
+	_, _ = p.do(ctx, http.MethodDelete, location, nil, nil)
+}
+
+// probeResponse keeps what probes need of a response, so that its body can be closed right away.
+type probeResponse struct {
+	*http.Response
+	err error
+}
+
+func (p *prober) do(ctx context.Context, method, rawURL string, body []byte, headers map[string]string) (*probeResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if apiVersion := resp.Header.Get("Docker-Distribution-API-Version"); apiVersion != "" {
+		p.apiVersion = apiVersion
+	}
+	// CheckError reads the body to parse registry error codes, requests with expected statuses do not need it
+	checkErr := transport.CheckError(resp, http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent)
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return &probeResponse{Response: resp, err: checkErr}, nil
+}
+
+func (p *prober) url(path string, query url.Values) string {
+	u := url.URL{Scheme: p.repo.Registry.Scheme(), Host: p.repo.RegistryStr(), Path: path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+func (p *prober) repoURL(path string, query url.Values) string {
+	return p.url("/v2/"+p.repo.RepositoryStr()+path, query)
+}
+
+func supportFromStatus(resp *probeResponse, supportedStatuses ...int) Support {
+	switch {
+	case slices.Contains(supportedStatuses, resp.StatusCode):
+		return Supported
+	case slices.Contains(unsupportedStatuses, resp.StatusCode):
+		return Unsupported
+	default:
+		return Unknown
+	}
+}
+
+func hasErrorCode(resp *probeResponse, code transport.ErrorCode) bool {
+	var transportErr *transport.Error
+	if !errors.As(resp.err, &transportErr) {
+		return false
+	}
+	return slices.ContainsFunc(transportErr.Errors, func(diagnostic transport.Diagnostic) bool {
+		return diagnostic.Code == code
+	})
+}
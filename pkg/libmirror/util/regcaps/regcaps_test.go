@@ -0,0 +1,118 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regcaps
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+
+	mirrorTestUtils "github.com/deckhouse/deckhouse-cli/testing/util/mirror"
+)
+
+func TestProbe(t *testing.T) {
+	reg := mirrorTestUtils.SetupTestRegistry(mirrorTestUtils.WithTokenAuth("user", "pass"))
+	defer reg.Server.Close()
+	repo, err := name.NewRepository(reg.Host+reg.RepoPath, name.Insecure)
+	require.NoError(t, err)
+
+	caps, err := Probe(context.Background(), repo, Options{Auth: reg.Auth, Write: true})
+	require.NoError(t, err)
+	require.Equal(t, &Capabilities{
+		Registry:      reg.Host,
+		APIVersion:    "registry/2.0",
+		Catalog:       Supported,
+		Referrers:     Unsupported,
+		BlobMount:     Unsupported,
+		ChunkedUpload: Supported,
+	}, caps)
+}
+
+func TestProbeReadOnly(t *testing.T) {
+	server := httptest.NewServer(registry.New(
+		registry.WithReferrersSupport(true),
+		registry.Logger(log.New(io.Discard, "", 0)),
+	))
+	defer server.Close()
+	repo, err := name.NewRepository(strings.TrimPrefix(server.URL, "http://")+"/deckhouse/ee", name.Insecure)
+	require.NoError(t, err)
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(repo.Tag("latest"), img))
+
+	caps, err := Probe(context.Background(), repo, Options{})
+	require.NoError(t, err)
+	require.Equal(t, Supported, caps.Referrers)
+	require.Equal(t, Unknown, caps.ChunkedUpload, "upload APIs must not be probed without write access")
+	require.Equal(t, Unknown, caps.BlobMount)
+}
+
+func TestProbeWithoutPermissions(t *testing.T) {
+	registryHandler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/_catalog":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Method != http.MethodGet && r.Method != http.MethodHead:
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			registryHandler.ServeHTTP(w, r)
+		}
+	}))
+	defer server.Close()
+	repo, err := name.NewRepository(strings.TrimPrefix(server.URL, "http://")+"/deckhouse/ee", name.Insecure)
+	require.NoError(t, err)
+
+	caps, err := Probe(context.Background(), repo, Options{Write: true})
+	require.NoError(t, err)
+	require.Equal(t, Unknown, caps.Catalog, "denied access does not mean that API is not implemented")
+	require.Equal(t, Unknown, caps.ChunkedUpload)
+	require.Equal(t, Unknown, caps.BlobMount)
+}
+
+func TestProbeUnreachableRegistry(t *testing.T) {
+	server := httptest.NewServer(nil)
+	host := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	repo, err := name.NewRepository(host+"/repo", name.Insecure)
+	require.NoError(t, err)
+	_, err = Probe(context.Background(), repo, Options{})
+	require.Error(t, err)
+}
+
+func TestCapabilitiesMatrix(t *testing.T) {
+	caps := &Capabilities{Registry: "registry.example.com", Catalog: Supported, Referrers: Unsupported}
+	require.Equal(t, []string{
+		"Registry        registry.example.com",
+		"API version     not reported",
+		"Catalog         yes",
+		"Referrers       no",
+		"Blob mount      unknown",
+		"Chunked upload  unknown",
+	}, caps.Matrix())
+}
@@ -2,6 +2,7 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -38,9 +39,25 @@ func RunTaskWithContext(ctx context.Context, logger contexts.Logger, name string
 		if lastErr == nil {
 			return nil
 		}
+		var permanentErr *permanentError
+		if errors.As(lastErr, &permanentErr) {
+			return fmt.Errorf("%q: %w", name, permanentErr.err)
+		}
 
 		restarts += 1
 	}
 
 	return fmt.Errorf("%q: task failed to many times, last error: %w", name, lastErr)
 }
+
+// Permanent marks err returned from the task as not worth retrying, so that task fails immediately.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/retry/task"
 )
 
 var testLogger = log.NewSLogger(slog.LevelDebug)
@@ -95,3 +96,15 @@ func (s *eventualSuccessTask) Interval(_ uint) time.Duration {
 func (s *eventualSuccessTask) MaxRetries() uint {
 	return 4
 }
+
+func TestRunPermanentlyFailingTask(t *testing.T) {
+	runCount := 0
+	errPermanent := errors.New("permanent failure")
+	permanentTask := task.WithConstantRetries(5, 50*time.Millisecond, func(_ context.Context) error {
+		runCount++
+		return Permanent(errPermanent)
+	})
+
+	require.ErrorIs(t, RunTask(testLogger, "TestRunPermanentlyFailingTask", permanentTask), errPermanent)
+	require.Equal(t, 1, runCount, "Task should not be retried after permanent failure")
+}
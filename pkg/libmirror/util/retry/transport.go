@@ -0,0 +1,133 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/backoff"
+)
+
+// MaxBackoff caps waiting between retries of registry requests, both computed and requested with Retry-After header.
+const MaxBackoff = time.Minute
+
+// retryableStatusCodes are returned by registries and proxies in front of them on transient failures.
+// 429 Too Many Requests is not here, throttled requests are repeated by throttle transport.
+var retryableStatusCodes = map[int]struct{}{
+	http.StatusRequestTimeout:      {},
+	http.StatusInternalServerError: {},
+	http.StatusBadGateway:          {},
+	http.StatusServiceUnavailable:  {},
+	http.StatusGatewayTimeout:      {},
+}
+
+// Policy configures retries of registry requests that failed with network errors, timeouts or transient 5xx responses.
+type Policy struct {
+	// Retries is how many times failed request is repeated, zero disables retries.
+	Retries uint
+	// Backoff is the wait before the first retry, it doubles with every next one.
+	// Waits are randomized by up to a half of them, so that parallel requests do not retry in lockstep.
+	Backoff time.Duration
+
+	// sleep is replaced in tests to avoid waiting.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Interval returns how long to wait before retry, counted from 1.
+func (p *Policy) Interval(retry uint) time.Duration {
+	wait := MaxBackoff
+	if retry > 0 && retry < 32 {
+		wait = min(p.Backoff<<(retry-1), MaxBackoff)
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
+
+var active atomic.Pointer[Policy]
+
+// Enable makes Wrap retry requests according to policy, until returned func is called.
+func Enable(policy Policy) (disable func()) {
+	p := &policy
+	active.Store(p)
+	return func() { active.CompareAndSwap(p, nil) }
+}
+
+// Wrap returns transport that retries failed requests according to the enabled policy, if there is one.
+// Requests with bodies that cannot be replayed, like streamed blob uploads, are never retried.
+func Wrap(base http.RoundTripper) http.RoundTripper {
+	p := active.Load()
+	if p == nil || p.Retries == 0 {
+		return base
+	}
+	return &transport{policy: p, base: base}
+}
+
+type transport struct {
+	policy *Policy
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for retry := uint(0); ; retry++ {
+		if retry > 0 {
+			var err error
+			if req, err = backoff.Rewind(req); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if retry == t.policy.Retries || !backoff.CanRepeat(req) || !isTransient(ctx, resp, err) {
+			return resp, err
+		}
+
+		wait := t.policy.Interval(retry + 1)
+		if resp != nil {
+			if requested := backoff.RetryAfter(resp.Header.Get("Retry-After"), time.Now()); requested > 0 {
+				wait = min(requested, MaxBackoff)
+			}
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		sleep := t.policy.sleep
+		if sleep == nil {
+			sleep = backoff.Sleep
+		}
+		if err = sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func isTransient(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		// Requests cancelled by caller are not worth repeating, unlike the ones that timed out on their own.
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled)
+	}
+	_, retryable := retryableStatusCodes[resp.StatusCode]
+	return retryable
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportRetriesTransientFailures(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	waited := make([]time.Duration, 0)
+	policy := &Policy{Retries: 3, Backoff: time.Second, sleep: func(_ context.Context, d time.Duration) error {
+		waited = append(waited, d)
+		return nil
+	}}
+	client := &http.Client{Transport: &transport{policy: policy, base: http.DefaultTransport}}
+
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "payload", string(body), "replayable body must be sent again")
+	require.Equal(t, int32(3), requests.Load())
+	require.Len(t, waited, 2)
+	require.InDelta(t, 750*time.Millisecond, waited[0], float64(250*time.Millisecond), "first wait is the backoff randomized by up to a half")
+	require.Equal(t, 7*time.Second, waited[1], "Retry-After should be respected")
+}
+
+func TestTransportGivesUp(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	policy := &Policy{Retries: 2, Backoff: time.Second, sleep: func(context.Context, time.Duration) error { return nil }}
+	client := &http.Client{Transport: &transport{policy: policy, base: http.DefaultTransport}}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	require.Equal(t, int32(3), requests.Load(), "request must be sent once and retried twice")
+
+	requests.Store(0)
+	resp, err = client.Post(server.URL, "application/octet-stream", io.NopCloser(strings.NewReader("stream")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, int32(1), requests.Load(), "streamed body cannot be replayed")
+
+	requests.Store(0)
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	resp, err = client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, int32(1), requests.Load(), "client errors are not transient")
+}
+
+func TestPolicyInterval(t *testing.T) {
+	policy := &Policy{Backoff: time.Second}
+	for retry, base := range map[uint]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: MaxBackoff, 100: MaxBackoff} {
+		interval := policy.Interval(retry)
+		require.GreaterOrEqual(t, interval, base/2)
+		require.LessOrEqual(t, interval, base)
+	}
+}
@@ -0,0 +1,366 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoCredentials is returned by credentials providers that have no credentials to offer.
+var ErrNoCredentials = errors.New("no S3 credentials found: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, " +
+	"AWS_PROFILE of shared credentials file, AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN, or use IAM role of EC2 instance")
+
+// Credentials sign requests to S3.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Expires is zero for credentials that do not expire.
+	Expires time.Time
+}
+
+// CredentialsProvider retrieves credentials, ErrNoCredentials is returned if provider is not configured.
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// StaticCredentials are used as they are.
+type StaticCredentials Credentials
+
+func (c StaticCredentials) Retrieve(context.Context) (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// DefaultCredentialsChain looks up credentials the same way AWS CLI and SDKs do, in order:
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables;
+// web identity token of AWS_WEB_IDENTITY_TOKEN_FILE exchanged for AWS_ROLE_ARN role, as set up by IAM roles for service accounts;
+// AWS_PROFILE or default profile of shared credentials file, AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials;
+// IAM role of EC2 instance served by instance metadata service, unless AWS_EC2_METADATA_DISABLED is true.
+// Credentials that expire are retrieved again shortly before they do.
+func DefaultCredentialsChain(region string, httpClient *http.Client) CredentialsProvider {
+	return &cachedCredentials{provider: credentialsChain{
+		envCredentials{},
+		&webIdentityCredentials{region: region, httpClient: httpClient},
+		sharedFileCredentials{},
+		&instanceRoleCredentials{},
+	}}
+}
+
+type credentialsChain []CredentialsProvider
+
+func (chain credentialsChain) Retrieve(ctx context.Context) (Credentials, error) {
+	for _, provider := range chain {
+		creds, err := provider.Retrieve(ctx)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return creds, err
+	}
+	return Credentials{}, ErrNoCredentials
+}
+
+// credentialsExpiryWindow is how long before expiration credentials are retrieved again,
+// so that requests signed with them do not expire in flight.
+const credentialsExpiryWindow = 5 * time.Minute
+
+type cachedCredentials struct {
+	provider CredentialsProvider
+
+	mu    sync.Mutex
+	creds *Credentials
+}
+
+func (c *cachedCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.creds != nil && (c.creds.Expires.IsZero() || time.Until(c.creds.Expires) > credentialsExpiryWindow) {
+		return *c.creds, nil
+	}
+	creds, err := c.provider.Retrieve(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	c.creds = &creds
+	return creds, nil
+}
+
+type envCredentials struct{}
+
+func (envCredentials) Retrieve(context.Context) (Credentials, error) {
+	accessKeyID, secretAccessKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKeyID == "" || secretAccessKey == "" {
+		return Credentials{}, ErrNoCredentials
+	}
+	return Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+}
+
+// webIdentityCredentials exchanges web identity token for credentials of the role with STS AssumeRoleWithWebIdentity.
+type webIdentityCredentials struct {
+	region     string
+	httpClient *http.Client
+}
+
+func (p *webIdentityCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	tokenFile, roleARN := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || roleARN == "" {
+		return Credentials{}, ErrNoCredentials
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("Read web identity token: %w", err)
+	}
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = "d8-mirror-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_STS")
+	if endpoint == "" {
+		endpoint = "https://sts." + p.region + ".amazonaws.com"
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("Assume role %s with web identity: %w", roleARN, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("Assume role %s with web identity: %w", roleARN, responseError(resp))
+	}
+
+	result := struct {
+		AccessKeyID     string    `xml:"AssumeRoleWithWebIdentityResult>Credentials>AccessKeyId"`
+		SecretAccessKey string    `xml:"AssumeRoleWithWebIdentityResult>Credentials>SecretAccessKey"`
+		SessionToken    string    `xml:"AssumeRoleWithWebIdentityResult>Credentials>SessionToken"`
+		Expiration      time.Time `xml:"AssumeRoleWithWebIdentityResult>Credentials>Expiration"`
+	}{}
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Credentials{}, fmt.Errorf("Decode credentials of role %s: %w", roleARN, err)
+	}
+	return Credentials{
+		AccessKeyID:     result.AccessKeyID,
+		SecretAccessKey: result.SecretAccessKey,
+		SessionToken:    result.SessionToken,
+		Expires:         result.Expiration,
+	}, nil
+}
+
+// sharedFileCredentials reads credentials of the profile from shared credentials file written by aws configure.
+type sharedFileCredentials struct{}
+
+func (sharedFileCredentials) Retrieve(context.Context) (Credentials, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return Credentials{}, ErrNoCredentials
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	section, err := readProfile(path, profileName())
+	if err != nil {
+		return Credentials{}, err
+	}
+	if section["aws_access_key_id"] == "" || section["aws_secret_access_key"] == "" {
+		return Credentials{}, ErrNoCredentials
+	}
+	return Credentials{
+		AccessKeyID:     section["aws_access_key_id"],
+		SecretAccessKey: section["aws_secret_access_key"],
+		SessionToken:    section["aws_session_token"],
+	}, nil
+}
+
+// profileRegion returns region of the profile from shared config file, AWS_CONFIG_FILE or ~/.aws/config, if it is set there.
+func profileRegion() string {
+	path := os.Getenv("AWS_CONFIG_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		path = filepath.Join(home, ".aws", "config")
+	}
+	profile := profileName()
+	if profile != "default" {
+		profile = "profile " + profile
+	}
+	section, err := readProfile(path, profile)
+	if err != nil {
+		return ""
+	}
+	return section["region"]
+}
+
+func profileName() string {
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	return "default"
+}
+
+// readProfile returns keys of INI file section, ErrNoCredentials is returned if there is no such file or section.
+func readProfile(path, section string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var keys map[string]string
+	inSection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			inSection = strings.TrimSpace(line[1:len(line)-1]) == section
+			if inSection && keys == nil {
+				keys = map[string]string{}
+			}
+		case inSection:
+			key, value, found := strings.Cut(line, "=")
+			if found {
+				keys[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Read %s: %w", path, err)
+	}
+	if keys == nil {
+		return nil, ErrNoCredentials
+	}
+	return keys, nil
+}
+
+const (
+	defaultInstanceMetadataEndpoint = "http://169.254.169.254"
+	instanceMetadataTokenTTL        = "21600"
+	// instanceMetadataTimeout keeps hosts that are not EC2 instances from waiting long for metadata service that is not there.
+	instanceMetadataTimeout = time.Second
+)
+
+// instanceRoleCredentials retrieves credentials of EC2 instance IAM role from instance metadata service (IMDSv2).
+type instanceRoleCredentials struct {
+	// httpClient talks to metadata service directly, proxies are never used for link-local address.
+	httpClient *http.Client
+}
+
+func (p *instanceRoleCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	if disabled, _ := strconv.ParseBool(os.Getenv("AWS_EC2_METADATA_DISABLED")); disabled {
+		return Credentials{}, ErrNoCredentials
+	}
+	endpoint := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT")
+	if endpoint == "" {
+		endpoint = defaultInstanceMetadataEndpoint
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if p.httpClient == nil {
+		p.httpClient = &http.Client{Transport: &http.Transport{}, Timeout: instanceMetadataTimeout}
+	}
+
+	token, err := p.request(ctx, http.MethodPut, endpoint+"/latest/api/token", http.Header{
+		"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {instanceMetadataTokenTTL},
+	})
+	if err != nil {
+		// Metadata service is not reachable outside of EC2 instances
+		return Credentials{}, ErrNoCredentials
+	}
+	header := http.Header{"X-Aws-Ec2-Metadata-Token": {string(token)}}
+	roles, err := p.request(ctx, http.MethodGet, endpoint+"/latest/meta-data/iam/security-credentials/", header)
+	if err != nil {
+		return Credentials{}, ErrNoCredentials
+	}
+	role, _, _ := strings.Cut(strings.TrimSpace(string(roles)), "\n")
+	if role == "" {
+		return Credentials{}, ErrNoCredentials
+	}
+
+	rawCreds, err := p.request(ctx, http.MethodGet, endpoint+"/latest/meta-data/iam/security-credentials/"+role, header)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("Get credentials of instance role %s: %w", role, err)
+	}
+	result := struct {
+		Code            string    `json:"Code"`
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}{}
+	if err = json.Unmarshal(rawCreds, &result); err != nil {
+		return Credentials{}, fmt.Errorf("Decode credentials of instance role %s: %w", role, err)
+	}
+	if result.Code != "" && result.Code != "Success" {
+		return Credentials{}, fmt.Errorf("Get credentials of instance role %s: %s", role, result.Code)
+	}
+	return Credentials{
+		AccessKeyID:     result.AccessKeyID,
+		SecretAccessKey: result.SecretAccessKey,
+		SessionToken:    result.Token,
+		Expires:         result.Expiration,
+	}, nil
+}
+
+func (p *instanceRoleCredentials) request(ctx context.Context, method, url string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+}
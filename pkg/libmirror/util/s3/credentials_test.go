@@ -0,0 +1,143 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultCredentialsChain(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	t.Setenv("AWS_ROLE_ARN", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	retrieve := func() (Credentials, error) {
+		return DefaultCredentialsChain("us-east-1", http.DefaultClient).Retrieve(context.Background())
+	}
+
+	_, err := retrieve()
+	require.ErrorIs(t, err, ErrNoCredentials)
+
+	t.Run("instance role", func(t *testing.T) {
+		imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+				_, _ = w.Write([]byte("imds-token"))
+			case r.Header.Get("X-Aws-Ec2-Metadata-Token") != "imds-token":
+				w.WriteHeader(http.StatusUnauthorized)
+			case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+				_, _ = w.Write([]byte("mirror-role"))
+			case r.URL.Path == "/latest/meta-data/iam/security-credentials/mirror-role":
+				_, _ = fmt.Fprintf(w, `{"Code":"Success","AccessKeyId":"instance","SecretAccessKey":"secret","Token":"token","Expiration":%q}`,
+					time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+			}
+		}))
+		defer imds.Close()
+		t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+		t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", imds.URL)
+
+		creds, err := retrieve()
+		require.NoError(t, err)
+		require.Equal(t, "instance", creds.AccessKeyID)
+		require.Equal(t, "token", creds.SessionToken)
+		require.False(t, creds.Expires.IsZero())
+	})
+
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".aws"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".aws", "credentials"), []byte(`
+[default]
+aws_access_key_id = default
+aws_secret_access_key = secret
+
+[mirror]
+aws_access_key_id = profile
+aws_secret_access_key = secret
+`), 0o600))
+	t.Setenv("AWS_PROFILE", "mirror")
+	creds, err := retrieve()
+	require.NoError(t, err)
+	require.Equal(t, "profile", creds.AccessKeyID)
+
+	t.Run("web identity", func(t *testing.T) {
+		sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "AssumeRoleWithWebIdentity", r.PostForm.Get("Action"))
+			require.Equal(t, "service-account-token", r.PostForm.Get("WebIdentityToken"))
+			_, _ = fmt.Fprintf(w, `<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>
+<AccessKeyId>web-identity</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken>
+<Expiration>%s</Expiration></Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`,
+				time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		}))
+		defer sts.Close()
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("service-account-token\n"), 0o600))
+		t.Setenv("AWS_ENDPOINT_URL_STS", sts.URL)
+		t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+		t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/mirror")
+
+		creds, err := retrieve()
+		require.NoError(t, err)
+		require.Equal(t, "web-identity", creds.AccessKeyID)
+		require.Equal(t, "token", creds.SessionToken)
+	})
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "env")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	creds, err = retrieve()
+	require.NoError(t, err)
+	require.Equal(t, "env", creds.AccessKeyID)
+}
+
+func TestCachedCredentialsRefreshExpiring(t *testing.T) {
+	retrieved := 0
+	expires := time.Now().Add(time.Minute)
+	cached := &cachedCredentials{provider: credentialsFunc(func() Credentials {
+		retrieved++
+		return Credentials{AccessKeyID: "key", Expires: expires}
+	})}
+
+	_, err := cached.Retrieve(context.Background())
+	require.NoError(t, err)
+	_, err = cached.Retrieve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, retrieved, "Credentials expiring soon should be retrieved again")
+
+	expires = time.Now().Add(time.Hour)
+	_, err = cached.Retrieve(context.Background())
+	require.NoError(t, err)
+	_, err = cached.Retrieve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, retrieved)
+}
+
+type credentialsFunc func() Credentials
+
+func (f credentialsFunc) Retrieve(context.Context) (Credentials, error) { return f(), nil }
@@ -0,0 +1,587 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package s3 stores bundles in S3-compatible object storage, so that they can be transferred
+// without intermediate copy on local filesystem.
+//
+// Only operations needed for bundles are implemented: streaming multipart uploads, ranged and streaming reads,
+// and listing of objects. Requests are signed with AWS Signature Version 4 and use path-style addressing,
+// which is supported by AWS S3 as well as MinIO, Ceph and other S3-compatible storages.
+//
+// Region and endpoint are taken from the standard AWS environment variables AWS_REGION and AWS_ENDPOINT_URL_S3
+// or AWS_ENDPOINT_URL, credentials are looked up like AWS CLI does, see DefaultCredentialsChain.
+// Requests go through the same transport as registry requests, so they use the same proxies and CAs,
+// and are repeated on transient failures and throttling, like SlowDown responses, see auth.MakeTransport.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+)
+
+// Scheme prefixes bundle paths that refer to objects in S3, like s3://bucket/path/to/bundle.tar.
+const Scheme = "s3://"
+
+const (
+	defaultRegion = "us-east-1"
+
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	amzDateFormat    = "20060102T150405Z"
+)
+
+// ErrNotFound is returned when object or bucket does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// IsURL reports whether path refers to S3 object.
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, Scheme)
+}
+
+// ParseURL splits s3://bucket/key URL into bucket and key.
+func ParseURL(s3URL string) (bucket, key string, err error) {
+	bucketAndKey, found := strings.CutPrefix(s3URL, Scheme)
+	if !found {
+		return "", "", fmt.Errorf("%s is not an %s URL", s3URL, Scheme)
+	}
+	bucket, key, _ = strings.Cut(bucketAndKey, "/")
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("%s should be in %sbucket/key format", s3URL, Scheme)
+	}
+	return bucket, key, nil
+}
+
+// Client makes requests to S3-compatible object storage.
+type Client struct {
+	Endpoint    *url.URL
+	Region      string
+	Credentials CredentialsProvider
+
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewClientFromEnv creates client configured with the standard AWS environment variables and shared config files.
+func NewClientFromEnv() (*Client, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = profileRegion()
+	}
+	if region == "" {
+		region = defaultRegion
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+
+	client, err := NewClient(endpoint, region, nil)
+	if err != nil {
+		return nil, err
+	}
+	client.Credentials = DefaultCredentialsChain(region, client.httpClient)
+	return client, nil
+}
+
+func NewClient(endpoint, region string, credentials CredentialsProvider) (*Client, error) {
+	endpointURL, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("Parse S3 endpoint: %w", err)
+	}
+	if endpointURL.Scheme != "http" && endpointURL.Scheme != "https" {
+		return nil, fmt.Errorf("S3 endpoint %s should be an http or https URL", endpoint)
+	}
+
+	return &Client{
+		Endpoint:    endpointURL,
+		Region:      region,
+		Credentials: credentials,
+		httpClient:  &http.Client{Transport: auth.MakeTransport(false)},
+		now:         time.Now,
+	}, nil
+}
+
+// ObjectInfo describes object found by List.
+type ObjectInfo struct {
+	Key  string
+	Size int64
+}
+
+// List returns objects of the bucket with keys starting with prefix, sorted by key.
+func (c *Client) List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	objects := make([]ObjectInfo, 0)
+	continuationToken := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+		resp, err := c.do(ctx, http.MethodGet, bucket, "", query, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("List objects of %s: %w", bucket, err)
+		}
+
+		result := struct {
+			Contents []struct {
+				Key  string `xml:"Key"`
+				Size int64  `xml:"Size"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}{}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Decode objects list of %s: %w", bucket, err)
+		}
+
+		for _, object := range result.Contents {
+			objects = append(objects, ObjectInfo{Key: object.Key, Size: object.Size})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		continuationToken = result.NextContinuationToken
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// Stat returns object that can be read from, or ErrNotFound if there is none.
+func (c *Client) Stat(ctx context.Context, bucket, key string) (*Object, error) {
+	resp, err := c.do(ctx, http.MethodHead, bucket, key, nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Stat %s%s/%s: %w", Scheme, bucket, key, err)
+	}
+	resp.Body.Close()
+	return &Object{client: c, ctx: ctx, bucket: bucket, key: key, size: resp.ContentLength}, nil
+}
+
+// Create starts upload of the object, it is stored once returned writer is closed.
+func (c *Client) Create(ctx context.Context, bucket, key string) *Writer {
+	return &Writer{client: c, ctx: ctx, bucket: bucket, key: key, partSize: DefaultPartSize}
+}
+
+// do makes signed request to the object at key, or to the bucket itself if key is empty.
+// Responses with non-2xx status codes are converted into errors.
+func (c *Client) do(
+	ctx context.Context,
+	method, bucket, key string,
+	query url.Values,
+	header http.Header,
+	body []byte,
+) (*http.Response, error) {
+	creds, err := c.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reqURL := *c.Endpoint
+	reqURL.Path = c.Endpoint.Path + "/" + bucket
+	if key != "" {
+		reqURL.Path += "/" + key
+	}
+	reqURL.RawPath = encodePath(reqURL.Path)
+	reqURL.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	c.sign(req, payloadHash, creds)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	return nil, responseError(resp)
+}
+
+func responseError(resp *http.Response) error {
+	apiErr := struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}{}
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&apiErr)
+
+	if resp.StatusCode == http.StatusNotFound {
+		if apiErr.Code != "" {
+			return fmt.Errorf("%w: %s", ErrNotFound, apiErr.Code)
+		}
+		return ErrNotFound
+	}
+	if apiErr.Code != "" {
+		return fmt.Errorf("%s: %s: %s", resp.Status, apiErr.Code, apiErr.Message)
+	}
+	return errors.New(resp.Status)
+}
+
+// sign adds AWS Signature Version 4 to the request.
+func (c *Client) sign(req *http.Request, payloadHash string, creds Credentials) {
+	now := c.now().UTC()
+	amzDate := now.Format(amzDateFormat)
+	scope := now.Format("20060102") + "/" + c.Region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "range" || name == "content-type" || name == "content-md5" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+
+	canonicalHeaders := &strings.Builder{}
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), now.Format("20060102"))
+	signingKey = hmacSHA256(signingKey, c.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// encodePath percent-encodes every byte of path except unreserved characters and slashes,
+// as Signature Version 4 requires for canonical URIs.
+func encodePath(path string) string {
+	encoded := &strings.Builder{}
+	for _, b := range []byte(path) {
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9', strings.IndexByte("-._~/", b) >= 0:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+// canonicalQuery encodes query as required by Signature Version 4: sorted by key, with spaces encoded as %20.
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+// Object is stored in S3 and can be read either at random offsets or as a stream.
+type Object struct {
+	client *Client
+	ctx    context.Context
+	bucket string
+	key    string
+	size   int64
+
+	// window caches data around the last random read, as reads of tar headers are small and close to each other.
+	window       []byte
+	windowOffset int64
+}
+
+// readAheadSize is the minimal size of ranged reads made by Object.ReadAt.
+const readAheadSize = 1 << 20
+
+func (o *Object) Size() int64 { return o.size }
+
+func (o *Object) String() string { return Scheme + o.bucket + "/" + o.key }
+
+// Open returns stream of the object contents.
+func (o *Object) Open() (io.ReadCloser, error) {
+	resp, err := o.client.do(o.ctx, http.MethodGet, o.bucket, o.key, nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Read %s: %w", o, err)
+	}
+	return resp.Body, nil
+}
+
+// ReadAt reads object contents at offset with ranged requests. It is not safe for concurrent use.
+func (o *Object) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= o.size {
+		return 0, io.EOF
+	}
+
+	if off < o.windowOffset || off+int64(len(p)) > o.windowOffset+int64(len(o.window)) {
+		if err := o.fill(off, max(len(p), readAheadSize)); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, o.window[off-o.windowOffset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (o *Object) fill(off int64, size int) error {
+	end := min(off+int64(size), o.size) - 1
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", off, end)}}
+	resp, err := o.client.do(o.ctx, http.MethodGet, o.bucket, o.key, nil, header, nil)
+	if err != nil {
+		return fmt.Errorf("Read %s: %w", o, err)
+	}
+	defer resp.Body.Close()
+
+	window, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Read %s: %w", o, err)
+	}
+	o.window, o.windowOffset = window, off
+	return nil
+}
+
+// abortTimeout limits waiting for multipart upload to be aborted, with retries of failed abort requests.
+const abortTimeout = 5 * time.Minute
+
+// DefaultPartSize is the size of parts uploaded by Writer. S3 allows up to 10000 parts, so objects of up to ~1TB can be stored.
+const DefaultPartSize = 100 << 20
+
+// Writer uploads object in parts as it is written to, buffering only the part being written.
+// Objects smaller than one part are uploaded with a single request.
+type Writer struct {
+	client   *Client
+	ctx      context.Context
+	bucket   string
+	key      string
+	partSize int
+
+	buf      bytes.Buffer
+	uploadID string
+	parts    []completedPart
+	written  int64
+	err      error
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.buf.Write(p)
+	for w.buf.Len() >= w.partSize {
+		if err := w.uploadPart(w.buf.Next(w.partSize)); err != nil {
+			w.fail(err)
+			return 0, w.err
+		}
+	}
+	w.written += int64(len(p))
+	return len(p), nil
+}
+
+// Written returns number of bytes written so far.
+func (w *Writer) Written() int64 { return w.written }
+
+// Close uploads the rest of the object and completes the upload.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.uploadID == "" {
+		resp, err := w.client.do(w.ctx, http.MethodPut, w.bucket, w.key, nil, nil, w.buf.Bytes())
+		if err != nil {
+			w.err = fmt.Errorf("Upload %s%s/%s: %w", Scheme, w.bucket, w.key, err)
+			return w.err
+		}
+		resp.Body.Close()
+		w.err = errors.New("writer is closed")
+		return nil
+	}
+
+	if w.buf.Len() > 0 {
+		if err := w.uploadPart(w.buf.Bytes()); err != nil {
+			w.fail(err)
+			return w.err
+		}
+	}
+	if err := w.completeUpload(); err != nil {
+		w.fail(err)
+		return w.err
+	}
+	w.err = errors.New("writer is closed")
+	return nil
+}
+
+// Abort cancels the upload, so that no partially written object is stored.
+func (w *Writer) Abort() {
+	if w.err == nil {
+		w.fail(errors.New("upload is aborted"))
+	}
+}
+
+func (w *Writer) fail(err error) {
+	w.err = fmt.Errorf("Upload %s%s/%s: %w", Scheme, w.bucket, w.key, err)
+	if w.uploadID == "" {
+		return
+	}
+	// Parts of upload that is not aborted are kept, and billed, by S3 until bucket lifecycle rules remove them.
+	// Upload is aborted even if it failed because its context was cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer cancel()
+	resp, abortErr := w.client.do(ctx, http.MethodDelete, w.bucket, w.key, url.Values{"uploadId": {w.uploadID}}, nil, nil)
+	if abortErr != nil {
+		w.err = fmt.Errorf("%w; abort multipart upload %s, its parts are kept until removed: %v", w.err, w.uploadID, abortErr)
+	} else {
+		resp.Body.Close()
+	}
+	w.uploadID = ""
+}
+
+func (w *Writer) uploadPart(part []byte) error {
+	if w.uploadID == "" {
+		if err := w.createUpload(); err != nil {
+			return err
+		}
+	}
+
+	partNumber := len(w.parts) + 1
+	query := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {w.uploadID}}
+	resp, err := w.client.do(w.ctx, http.MethodPut, w.bucket, w.key, query, nil, part)
+	if err != nil {
+		return fmt.Errorf("upload part %d: %w", partNumber, err)
+	}
+	resp.Body.Close()
+	w.parts = append(w.parts, completedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+	return nil
+}
+
+func (w *Writer) createUpload() error {
+	resp, err := w.client.do(w.ctx, http.MethodPost, w.bucket, w.key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return fmt.Errorf("create multipart upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	result := struct {
+		UploadID string `xml:"UploadId"`
+	}{}
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode multipart upload: %w", err)
+	}
+	if result.UploadID == "" {
+		return errors.New("create multipart upload: no upload ID in response")
+	}
+	w.uploadID = result.UploadID
+	return nil
+}
+
+func (w *Writer) completeUpload() error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: w.parts})
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.client.do(w.ctx, http.MethodPost, w.bucket, w.key, url.Values{"uploadId": {w.uploadID}}, nil, body)
+	if err != nil {
+		return fmt.Errorf("complete multipart upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Completion may fail after 200 OK response was sent, with error in its body
+	result := struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}{}
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("complete multipart upload: %s: %s", result.Code, result.Message)
+	}
+	return nil
+}
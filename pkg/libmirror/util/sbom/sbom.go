@@ -0,0 +1,92 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sbom handles software bills of materials that cosign attaches to images under "sha256-<digest>.sbom" tags.
+// Only SPDX and CycloneDX documents are recognized, both in their native and JSON or XML encodings.
+package sbom
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// fileExtensions are keyed by media types of SBOM layers, as set by cosign attach sbom.
+var fileExtensions = map[types.MediaType]string{
+	"text/spdx":                      ".spdx",
+	"text/spdx+json":                 ".spdx.json",
+	"text/spdx+xml":                  ".spdx.xml",
+	"application/spdx+json":          ".spdx.json",
+	"application/vnd.cyclonedx":      ".cdx",
+	"application/vnd.cyclonedx+json": ".cdx.json",
+	"application/vnd.cyclonedx+xml":  ".cdx.xml",
+}
+
+// Document is a single SBOM attached to the image.
+type Document struct {
+	// Repository is the path of repository relative to the Deckhouse repo root, "" being the root itself.
+	Repository string
+	// Image is the tag of image document describes, or "sha256-<hex>" if image has no tags in the repository.
+	Image     string
+	MediaType types.MediaType
+	Data      []byte
+}
+
+// IsSupported reports whether SBOM layer of mediaType is either SPDX or CycloneDX document.
+func IsSupported(mediaType types.MediaType) bool {
+	_, supported := fileExtensions[mediaType]
+	return supported
+}
+
+// WriteFiles writes documents to dir, laid out the same way as repositories they were attached in:
+// SBOM of "modules/foo:v1.2.0" is written to <dir>/modules/foo/v1.2.0.spdx.json, for example.
+// If image has several documents of the same format, all but the first one get numeric suffixes.
+// Paths of written files are returned.
+func WriteFiles(dir string, docs []Document) ([]string, error) {
+	written := make([]string, 0, len(docs))
+	seen := make(map[string]int, len(docs))
+	for _, doc := range docs {
+		ext, supported := fileExtensions[doc.MediaType]
+		if !supported {
+			return nil, fmt.Errorf("%s:%s: unsupported SBOM media type %q", doc.Repository, doc.Image, doc.MediaType)
+		}
+		if strings.Contains(doc.Image, "/") || strings.Contains(doc.Repository, "..") {
+			return nil, errors.New("invalid image reference " + doc.Repository + ":" + doc.Image)
+		}
+
+		name := filepath.Join(dir, filepath.FromSlash(doc.Repository), doc.Image)
+		if n := seen[name+ext]; n > 0 {
+			seen[name+ext]++
+			name = fmt.Sprintf("%s-%d", name, n)
+		} else {
+			seen[name+ext] = 1
+		}
+		name += ext
+
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return nil, fmt.Errorf("create directory for %s: %w", name, err)
+		}
+		if err := os.WriteFile(name, doc.Data, 0o644); err != nil {
+			return nil, fmt.Errorf("write %s: %w", name, err)
+		}
+		written = append(written, name)
+	}
+	return written, nil
+}
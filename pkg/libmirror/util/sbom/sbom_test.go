@@ -0,0 +1,48 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sbom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFiles(t *testing.T) {
+	dir := t.TempDir()
+	written, err := WriteFiles(dir, []Document{
+		{Repository: "", Image: "v1.60.0", MediaType: "text/spdx+json", Data: []byte(`{"spdxVersion":"SPDX-2.3"}`)},
+		{Repository: "modules/foo", Image: "v1.2.0", MediaType: "application/vnd.cyclonedx+json", Data: []byte(`{"bomFormat":"CycloneDX"}`)},
+		{Repository: "modules/foo", Image: "v1.2.0", MediaType: "application/vnd.cyclonedx+json", Data: []byte(`{}`)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "v1.60.0.spdx.json"),
+		filepath.Join(dir, "modules", "foo", "v1.2.0.cdx.json"),
+		filepath.Join(dir, "modules", "foo", "v1.2.0-1.cdx.json"),
+	}, written)
+
+	data, err := os.ReadFile(written[1])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"bomFormat":"CycloneDX"}`, string(data))
+
+	_, err = WriteFiles(dir, []Document{{Image: "v1.60.0", MediaType: "application/vnd.syft+json"}})
+	require.Error(t, err, "Only SPDX and CycloneDX documents should be written")
+	require.False(t, IsSupported("application/vnd.syft+json"))
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signature
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/errorutil"
+)
+
+const cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+// CosignSignatureArtifactType is the artifact type of cosign signatures attached to images with OCI referrers API.
+const CosignSignatureArtifactType = "application/vnd.dev.cosign.artifact.sig.v1+json"
+
+var ErrSignatureNotFound = errors.New("no signature found")
+
+// cosignArtifactSuffixes are appended by cosign to tags of signatures, attestations and SBOMs it attaches to images.
+var cosignArtifactSuffixes = []string{".sig", ".att", ".sbom"}
+
+var cosignArtifactTagRegexp = regexp.MustCompile(`^sha256-([0-9a-f]{64})\.(sig|att|sbom)$`)
+
+// CosignSignatureTag returns the tag under which cosign stores signatures of the image with given digest.
+func CosignSignatureTag(digest v1.Hash) string {
+	return digest.Algorithm + "-" + digest.Hex + ".sig"
+}
+
+// CosignArtifactTags returns tags under which cosign stores signatures, attestations and SBOMs of the image with given digest.
+func CosignArtifactTags(digest v1.Hash) []string {
+	prefix := digest.Algorithm + "-" + digest.Hex
+	tags := make([]string, 0, len(cosignArtifactSuffixes))
+	for _, suffix := range cosignArtifactSuffixes {
+		tags = append(tags, prefix+suffix)
+	}
+	return tags
+}
+
+// IsCosignArtifactTag reports whether tag is one of those returned by CosignArtifactTags.
+func IsCosignArtifactTag(tag string) bool {
+	return cosignArtifactTagRegexp.MatchString(tag)
+}
+
+// ParseCosignArtifactTag returns digest of the image that cosign artifact tag belongs to
+// and the kind of artifact: "sig", "att" or "sbom". It returns false if tag is not one of CosignArtifactTags.
+func ParseCosignArtifactTag(tag string) (digest v1.Hash, kind string, ok bool) {
+	match := cosignArtifactTagRegexp.FindStringSubmatch(tag)
+	if match == nil {
+		return v1.Hash{}, "", false
+	}
+	return v1.Hash{Algorithm: "sha256", Hex: match[1]}, match[2], true
+}
+
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// VerifyCosignSignature checks that image with digest in repo has at least one cosign signature made with pub.
+// It returns ErrSignatureNotFound if image is not signed at all and ErrInvalidSignature if none of signatures is valid.
+func VerifyCosignSignature(
+	ctx context.Context,
+	repo name.Repository,
+	digest v1.Hash,
+	pub crypto.PublicKey,
+	remoteOpts ...remote.Option,
+) error {
+	signatures, err := FindCosignSignatures(ctx, repo, digest, remoteOpts...)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, signatureImage := range signatures {
+		manifest, err := signatureImage.Manifest()
+		if err != nil {
+			return fmt.Errorf("read signatures manifest: %w", err)
+		}
+		found = found || len(manifest.Layers) > 0
+
+		for _, layerDesc := range manifest.Layers {
+			sig, hasSignature := layerDesc.Annotations[cosignSignatureAnnotation]
+			if !hasSignature {
+				continue
+			}
+
+			payload, err := readLayer(signatureImage, layerDesc.Digest)
+			if err != nil {
+				return fmt.Errorf("read signature payload: %w", err)
+			}
+			if err = Verify(pub, payload, []byte(sig)); err != nil {
+				continue
+			}
+
+			signedImage := &cosignPayload{}
+			if err = json.Unmarshal(payload, signedImage); err != nil {
+				continue
+			}
+			if signedImage.Critical.Image.DockerManifestDigest == digest.String() {
+				return nil
+			}
+		}
+	}
+
+	if !found {
+		return ErrSignatureNotFound
+	}
+	return ErrInvalidSignature
+}
+
+// FindCosignSignatures returns images holding cosign signatures of image with digest in repo.
+// Signatures attached with OCI referrers API are looked up first, falling back to the referrers tag schema
+// for registries that do not implement distribution spec v1.1. Signatures stored under cosign .sig tag
+// convention are only looked up if no signatures are attached, or registry fails to list referrers.
+func FindCosignSignatures(
+	ctx context.Context,
+	repo name.Repository,
+	digest v1.Hash,
+	remoteOpts ...remote.Option,
+) ([]v1.Image, error) {
+	remoteOpts = append(slices.Clone(remoteOpts), remote.WithContext(ctx))
+
+	signatures, err := findCosignReferrers(repo, digest, remoteOpts)
+	if err == nil && len(signatures) > 0 {
+		return signatures, nil
+	}
+
+	signatureImage, err := remote.Image(repo.Tag(CosignSignatureTag(digest)), remoteOpts...)
+	if err != nil {
+		if errorutil.IsImageNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get signatures: %w", err)
+	}
+	return []v1.Image{signatureImage}, nil
+}
+
+func findCosignReferrers(repo name.Repository, digest v1.Hash, remoteOpts []remote.Option) ([]v1.Image, error) {
+	referrers, err := remote.Referrers(
+		repo.Digest(digest.String()),
+		append(remoteOpts, remote.WithFilter("artifactType", CosignSignatureArtifactType))...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list referrers: %w", err)
+	}
+	index, err := referrers.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("list referrers: %w", err)
+	}
+
+	signatures := make([]v1.Image, 0, len(index.Manifests))
+	for _, desc := range index.Manifests {
+		signatureImage, err := remote.Image(repo.Digest(desc.Digest.String()), remoteOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("get signature %s: %w", desc.Digest, err)
+		}
+		signatures = append(signatures, signatureImage)
+	}
+	return signatures, nil
+}
+
+func readLayer(img v1.Image, digest v1.Hash) ([]byte, error) {
+	layer, err := img.LayerByDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
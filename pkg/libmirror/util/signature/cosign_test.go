@@ -0,0 +1,147 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signature
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+
+	mirrorTestUtils "github.com/deckhouse/deckhouse-cli/testing/util/mirror"
+)
+
+func TestVerifyCosignSignature(t *testing.T) {
+	host, repoPath, _ := mirrorTestUtils.SetupEmptyRegistryRepo(false)
+	repo, err := name.NewRepository(host+repoPath, name.Insecure)
+	require.NoError(t, err)
+
+	vendorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	img, err := random.Image(256, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(repo.Tag("v1.0.0"), img))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	err = VerifyCosignSignature(context.Background(), repo, digest, vendorKey.Public())
+	require.ErrorIs(t, err, ErrSignatureNotFound, "Unsigned image should be reported")
+
+	sigImage := cosignSignatureImage(t, vendorKey, repo, digest)
+	require.NoError(t, remote.Write(repo.Tag(CosignSignatureTag(digest)), sigImage))
+
+	require.NoError(t, VerifyCosignSignature(context.Background(), repo, digest, vendorKey.Public()))
+	err = VerifyCosignSignature(context.Background(), repo, digest, otherKey.Public())
+	require.ErrorIs(t, err, ErrInvalidSignature, "Signature made with other key should be rejected")
+}
+
+func TestVerifyCosignSignatureAttachedWithReferrers(t *testing.T) {
+	for testName, referrersSupport := range map[string]bool{"referrers API": true, "referrers tag schema": false} {
+		t.Run(testName, func(t *testing.T) {
+			server := httptest.NewServer(registry.New(registry.WithReferrersSupport(referrersSupport)))
+			defer server.Close()
+			repo, err := name.NewRepository(strings.TrimPrefix(server.URL, "http://")+"/deckhouse/ee", name.Insecure)
+			require.NoError(t, err)
+
+			vendorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			require.NoError(t, err)
+
+			img, err := random.Image(256, 1)
+			require.NoError(t, err)
+			require.NoError(t, remote.Write(repo.Tag("v1.0.0"), img))
+			digest, err := img.Digest()
+			require.NoError(t, err)
+			desc, err := remote.Head(repo.Digest(digest.String()))
+			require.NoError(t, err)
+
+			sigImage := cosignSignatureImage(t, vendorKey, repo, digest)
+			sigImage = mutate.MediaType(sigImage, types.OCIManifestSchema1)
+			sigImage = mutate.ConfigMediaType(sigImage, CosignSignatureArtifactType)
+			sigImage = mutate.Subject(sigImage, *desc).(v1.Image)
+			sigDigest, err := sigImage.Digest()
+			require.NoError(t, err)
+			require.NoError(t, remote.Write(repo.Digest(sigDigest.String()), sigImage))
+
+			signatures, err := FindCosignSignatures(context.Background(), repo, digest)
+			require.NoError(t, err)
+			require.Len(t, signatures, 1)
+			require.NoError(t, VerifyCosignSignature(context.Background(), repo, digest, vendorKey.Public()))
+		})
+	}
+}
+
+func TestCosignArtifactTags(t *testing.T) {
+	digest, err := v1.NewHash("sha256:" + strings.Repeat("ab", 32))
+	require.NoError(t, err)
+
+	tags := CosignArtifactTags(digest)
+	require.Equal(t, []string{
+		"sha256-" + digest.Hex + ".sig",
+		"sha256-" + digest.Hex + ".att",
+		"sha256-" + digest.Hex + ".sbom",
+	}, tags)
+	require.Equal(t, CosignSignatureTag(digest), tags[0])
+	for _, tag := range tags {
+		require.True(t, IsCosignArtifactTag(tag), tag)
+	}
+	require.False(t, IsCosignArtifactTag("sha256-"+digest.Hex), "Referrers tag schema index is not a cosign artifact")
+	require.False(t, IsCosignArtifactTag("v1.60.0"))
+
+	parsedDigest, kind, ok := ParseCosignArtifactTag(tags[2])
+	require.True(t, ok)
+	require.Equal(t, digest, parsedDigest)
+	require.Equal(t, "sbom", kind)
+	_, _, ok = ParseCosignArtifactTag("sha256-" + digest.Hex)
+	require.False(t, ok)
+}
+
+func cosignSignatureImage(t *testing.T, key *ecdsa.PrivateKey, repo name.Repository, digest v1.Hash) v1.Image {
+	t.Helper()
+
+	payload := []byte(fmt.Sprintf(
+		`{"critical":{"identity":{"docker-reference":%q},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`,
+		repo.String(), digest.String(),
+	))
+	sig, err := Sign(key, payload)
+	require.NoError(t, err)
+
+	sigImage, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       static.NewLayer(payload, "application/vnd.dev.cosign.simplesigning.v1+json"),
+		Annotations: map[string]string{cosignSignatureAnnotation: string(sig)},
+		MediaType:   types.MediaType("application/vnd.dev.cosign.simplesigning.v1+json"),
+	})
+	require.NoError(t, err)
+	return sigImage
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package signature implements detached signatures compatible with "cosign sign-blob" and "cosign verify-blob":
+// signature is a base64-encoded ASN.1 ECDSA (or PKCS#1 v1.5 RSA, or Ed25519) signature of SHA-256 digest of the payload.
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// LoadSigner reads PEM-encoded unencrypted private key from keyPath.
+func LoadSigner(keyPath string) (crypto.Signer, error) {
+	rawPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read private key: %w", err)
+	}
+
+	block, _ := pem.Decode(rawPEM)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", keyPath)
+	}
+
+	var key any
+	switch {
+	case strings.HasPrefix(block.Type, "ENCRYPTED"):
+		return nil, fmt.Errorf("%s: encrypted private keys are not supported, export it unencrypted or sign with cosign sign-blob", keyPath)
+	case block.Type == "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case block.Type == "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case block.Type == "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block type %q", keyPath, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key type %T", keyPath, key)
+	}
+	return signer, nil
+}
+
+// LoadVerificationKey reads public key from PEM-encoded public key or x509 certificate at path.
+// If certificate is provided and rootsPath is not empty, certificate chain is validated against CA certificates from rootsPath.
+func LoadVerificationKey(path, rootsPath string) (crypto.PublicKey, error) {
+	rawPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read verification key: %w", err)
+	}
+
+	block, rest := pem.Decode(rawPEM)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		if rootsPath != "" {
+			return nil, errors.New("CA roots can only be used with x509 certificates")
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse public key: %w", err)
+		}
+		return pub, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		if rootsPath != "" {
+			if err = verifyCertificateChain(cert, rest, rootsPath); err != nil {
+				return nil, err
+			}
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block type %q", path, block.Type)
+	}
+}
+
+func verifyCertificateChain(cert *x509.Certificate, intermediatesPEM []byte, rootsPath string) error {
+	rawRoots, err := os.ReadFile(rootsPath)
+	if err != nil {
+		return fmt.Errorf("read CA roots: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(rawRoots) {
+		return fmt.Errorf("%s: no CA certificates found", rootsPath)
+	}
+
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM(intermediatesPEM)
+
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("verify signing certificate: %w", err)
+	}
+	return nil
+}
+
+// Sign signs payload with signer and returns base64-encoded signature.
+func Sign(signer crypto.Signer, payload []byte) ([]byte, error) {
+	var sig []byte
+	var err error
+	switch signer.Public().(type) {
+	case ed25519.PublicKey:
+		sig, err = signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		digest := sha256.Sum256(payload)
+		sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", signer.Public())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sign payload: %w", err)
+	}
+
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(sig)))
+	base64.StdEncoding.Encode(encoded, sig)
+	return encoded, nil
+}
+
+// Verify checks base64-encoded signature of payload against public key.
+func Verify(pub crypto.PublicKey, payload, encodedSig []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encodedSig)))
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+
+	digest := sha256.Sum256(payload)
+	switch key := pub.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, payload, sig) {
+			return ErrInvalidSignature
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return ErrInvalidSignature
+		}
+	case *rsa.PublicKey:
+		if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", pub)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerifyWithPublicKey(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	privatePath, publicPath := filepath.Join(dir, "key.pem"), filepath.Join(dir, "key.pub")
+	writePrivateKey(t, privatePath, key)
+	rawPub, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	writePEM(t, publicPath, "PUBLIC KEY", rawPub)
+
+	signer, err := LoadSigner(privatePath)
+	require.NoError(t, err)
+	payload := []byte(`{"bundle":"d8.tar"}`)
+	sig, err := Sign(signer, payload)
+	require.NoError(t, err)
+
+	pub, err := LoadVerificationKey(publicPath, "")
+	require.NoError(t, err)
+	require.NoError(t, Verify(pub, payload, sig))
+	require.ErrorIs(t, Verify(pub, []byte(`{"bundle":"other.tar"}`), sig), ErrInvalidSignature)
+}
+
+func TestSignAndVerifyWithCertificate(t *testing.T) {
+	dir := t.TempDir()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rawCA, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(rawCA)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Bundle signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	rawLeaf, err := x509.CreateCertificate(rand.Reader, leafTemplate, caCert, key.Public(), caKey)
+	require.NoError(t, err)
+
+	privatePath := filepath.Join(dir, "key.pem")
+	certPath, caPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "ca.pem")
+	writePrivateKey(t, privatePath, key)
+	writePEM(t, certPath, "CERTIFICATE", rawLeaf)
+	writePEM(t, caPath, "CERTIFICATE", rawCA)
+
+	signer, err := LoadSigner(privatePath)
+	require.NoError(t, err)
+	payload := []byte("payload")
+	sig, err := Sign(signer, payload)
+	require.NoError(t, err)
+
+	pub, err := LoadVerificationKey(certPath, caPath)
+	require.NoError(t, err)
+	require.NoError(t, Verify(pub, payload, sig))
+
+	otherCAKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rawOtherCA, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, otherCAKey.Public(), otherCAKey)
+	require.NoError(t, err)
+	otherCAPath := filepath.Join(dir, "other-ca.pem")
+	writePEM(t, otherCAPath, "CERTIFICATE", rawOtherCA)
+
+	_, err = LoadVerificationKey(certPath, otherCAPath)
+	require.Error(t, err, "Certificate not issued by provided CA must be rejected")
+}
+
+func writePrivateKey(t *testing.T, path string, key *ecdsa.PrivateKey) {
+	t.Helper()
+	raw, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	writePEM(t, path, "PRIVATE KEY", raw)
+}
+
+func writePEM(t *testing.T, path, blockType string, data []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0o600))
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package taglist lists repository tags with registries that enforce request quotas, like GitLab registry or ECR.
+package taglist
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/backoff"
+)
+
+const (
+	// MaxThrottledRetries is how many times a throttled request is repeated before its response is returned as is.
+	MaxThrottledRetries = 5
+	// MaxRetryAfter caps waiting requested by registry, quotas are usually enforced per minute.
+	MaxRetryAfter = time.Minute
+
+	defaultRetryAfter = time.Second
+)
+
+// Lister caches tag lists for the duration of a run and handles registry throttling:
+// requests answered with 429 Too Many Requests are repeated after the delay from Retry-After header,
+// and once a registry host throttles, listing requests to it are made one at a time.
+// Lister is safe for concurrent use, nil *Lister lists tags without caching and throttling handling.
+type Lister struct {
+	mu    sync.Mutex
+	cache map[string][]string
+	hosts map[string]*hostState
+
+	// sleep is replaced in tests to avoid waiting.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+type hostState struct {
+	throttled bool
+	serial    sync.Mutex
+}
+
+func NewLister() *Lister {
+	return &Lister{
+		cache: map[string][]string{},
+		hosts: map[string]*hostState{},
+		sleep: backoff.Sleep,
+	}
+}
+
+// List returns tags of repo. Transport is the base HTTP transport for registry requests, like the one
+// auth.MakeTransport returns, it is wrapped to handle throttling and overrides transport set in opts.
+func (l *Lister) List(ctx context.Context, repo name.Repository, transport http.RoundTripper, opts ...remote.Option) ([]string, error) {
+	if transport == nil {
+		transport = remote.DefaultTransport
+	}
+	opts = append(slices.Clone(opts), remote.WithContext(ctx))
+	if l == nil {
+		return remote.List(repo, append(opts, remote.WithTransport(transport))...)
+	}
+
+	key := repo.Name()
+	l.mu.Lock()
+	tags, found := l.cache[key]
+	l.mu.Unlock()
+	if found {
+		return slices.Clone(tags), nil
+	}
+
+	throttled := &throttledTransport{lister: l, base: transport, host: repo.RegistryStr()}
+	tags, err := remote.List(repo, append(opts, remote.WithTransport(throttled))...)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	l.cache[key] = tags
+	l.mu.Unlock()
+	return slices.Clone(tags), nil
+}
+
+// Throttled reports whether host throttled any of the requests made by l.
+func (l *Lister) Throttled(host string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state, found := l.hosts[host]
+	return found && state.throttled
+}
+
+func (l *Lister) host(host string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state, found := l.hosts[host]
+	if !found {
+		state = &hostState{}
+		l.hosts[host] = state
+	}
+	return state
+}
+
+type throttledTransport struct {
+	lister *Lister
+	base   http.RoundTripper
+	host   string
+}
+
+func (t *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	state := t.lister.host(t.host)
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			var err error
+			if req, err = backoff.Rewind(req); err != nil {
+				return nil, err
+			}
+		}
+		t.lister.mu.Lock()
+		serialize := state.throttled
+		t.lister.mu.Unlock()
+		if serialize {
+			state.serial.Lock()
+		}
+		resp, err := t.base.RoundTrip(req)
+		if serialize {
+			state.serial.Unlock()
+		}
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt == MaxThrottledRetries || !backoff.CanRepeat(req) {
+			return resp, err
+		}
+
+		t.lister.mu.Lock()
+		state.throttled = true
+		t.lister.mu.Unlock()
+
+		wait := backoff.RetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if wait == 0 {
+			wait = defaultRetryAfter << attempt
+		}
+		resp.Body.Close()
+		if err = t.lister.sleep(req.Context(), min(wait, MaxRetryAfter)); err != nil {
+			return nil, err
+		}
+	}
+}
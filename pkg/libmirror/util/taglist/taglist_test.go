@@ -0,0 +1,74 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taglist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListerCachesAndRetriesThrottledRequests(t *testing.T) {
+	registryHandler := registry.New()
+	var tagListRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list") {
+			if tagListRequests.Add(1) <= 2 {
+				w.Header().Set("Retry-After", "7")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		}
+		registryHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	repo, err := name.NewRepository(host+"/deckhouse/release-channel", name.Insecure)
+	require.NoError(t, err)
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(repo.Tag("stable"), img))
+
+	var waited []time.Duration
+	lister := NewLister()
+	lister.sleep = func(_ context.Context, d time.Duration) error {
+		waited = append(waited, d)
+		return nil
+	}
+
+	tags, err := lister.List(context.Background(), repo, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"stable"}, tags)
+	require.Equal(t, []time.Duration{7 * time.Second, 7 * time.Second}, waited, "Retry-After should be respected")
+	require.True(t, lister.Throttled(host))
+
+	tags, err = lister.List(context.Background(), repo, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"stable"}, tags)
+	require.Equal(t, int32(3), tagListRequests.Load(), "Tags should be listed from cache the second time")
+}
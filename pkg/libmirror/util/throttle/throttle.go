@@ -0,0 +1,285 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package throttle keeps registry transfers going when registry enforces request quotas, like Docker Hub, GitLab registry or ECR.
+//
+// Requests answered with 429 Too Many Requests or with quota exceeded errors are repeated after a delay instead of failing.
+// Delay grows with every throttled response and decays with every successful one, and all requests to the throttled host
+// wait it out together, so that parallel transfers back off at once instead of hitting the quota one after another.
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/backoff"
+)
+
+const (
+	// MaxDelay caps waiting after a single throttled response, both computed and requested with Retry-After header.
+	MaxDelay = time.Minute
+	// MaxWait caps total waiting for a single request, after it throttled response is returned as is.
+	// Quotas are usually enforced per minute or per hour, requests that are still throttled after that are not going to pass.
+	MaxWait = 15 * time.Minute
+
+	initialDelay = time.Second
+	// maxErrorBody is the most bytes of error response that are read to look for quota errors.
+	maxErrorBody = 4096
+)
+
+// quotaMessages are found in bodies of responses that registries and proxies in front of them use for exceeded quotas
+// instead of 429 Too Many Requests, like 403 Forbidden of GitHub, 503 Service Unavailable of some cloud registries
+// or 503 SlowDown of S3.
+var quotaMessages = []string{
+	"toomanyrequests",
+	"too many requests",
+	"rate limit",
+	"quota exceeded",
+	"throttl",
+	"slowdown",
+}
+
+// Throttler tracks throttling of every registry host and records throttling events for the run summary.
+// Throttler is safe for concurrent use.
+type Throttler struct {
+	mu    sync.Mutex
+	hosts map[string]*hostState
+
+	// now and sleep are replaced in tests to avoid waiting.
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+type hostState struct {
+	delay       time.Duration
+	pausedUntil time.Time
+
+	events     int
+	gaveUp     int
+	waited     time.Duration
+	lastStatus int
+}
+
+func NewThrottler() *Throttler {
+	return &Throttler{
+		hosts: map[string]*hostState{},
+		now:   time.Now,
+		sleep: backoff.Sleep,
+	}
+}
+
+var active atomic.Pointer[Throttler]
+
+// Enable makes Wrap handle throttling with t, until returned func is called.
+func Enable(t *Throttler) (disable func()) {
+	active.Store(t)
+	return func() { active.CompareAndSwap(t, nil) }
+}
+
+// Wrap returns transport that repeats throttled requests with the enabled throttler, if there is one.
+// Requests with bodies that cannot be replayed, like streamed blob uploads, are not repeated,
+// but their throttled responses still make the following requests to the host wait.
+func Wrap(base http.RoundTripper) http.RoundTripper {
+	t := active.Load()
+	if t == nil {
+		return base
+	}
+	return &transport{throttler: t, base: base}
+}
+
+type transport struct {
+	throttler *Throttler
+	base      http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	host := req.URL.Host
+	var waited time.Duration
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			var err error
+			if req, err = backoff.Rewind(req); err != nil {
+				return nil, err
+			}
+		}
+
+		if wait := t.throttler.pause(host); wait > 0 {
+			if err := t.throttler.sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			waited += wait
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		if !isThrottled(resp) {
+			t.throttler.passed(host)
+			return resp, nil
+		}
+
+		delay := t.throttler.throttled(host, resp)
+		if !backoff.CanRepeat(req) || waited+delay > MaxWait {
+			t.throttler.gaveUp(host)
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+		resp.Body.Close()
+	}
+}
+
+// pause returns how long request to host has to wait for the host to stop throttling.
+func (t *Throttler) pause(host string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state, found := t.hosts[host]
+	if !found {
+		return 0
+	}
+	return max(state.pausedUntil.Sub(t.now()), 0)
+}
+
+// passed decays delay of host after successful request, so that transfers speed up once quota is replenished.
+func (t *Throttler) passed(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state, found := t.hosts[host]; found {
+		state.delay /= 2
+		if state.delay < initialDelay {
+			state.delay = 0
+		}
+	}
+}
+
+// throttled records throttled response of host and pauses requests to it, returning the pause.
+// Delay requested with Retry-After header is respected, otherwise it doubles with every throttled response.
+func (t *Throttler) throttled(host string, resp *http.Response) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state, found := t.hosts[host]
+	if !found {
+		state = &hostState{}
+		t.hosts[host] = state
+	}
+
+	now := t.now()
+	state.delay = min(max(state.delay*2, initialDelay), MaxDelay)
+	delay := state.delay
+	if requested := backoff.RetryAfter(resp.Header.Get("Retry-After"), now); requested > 0 {
+		delay = min(requested, MaxDelay)
+	}
+	// Parallel requests that were throttled at once share the same pause instead of adding up theirs.
+	if until := now.Add(delay); until.After(state.pausedUntil) {
+		pausedFrom := now
+		if state.pausedUntil.After(now) {
+			pausedFrom = state.pausedUntil
+		}
+		state.waited += until.Sub(pausedFrom)
+		state.pausedUntil = until
+	}
+	state.events++
+	state.lastStatus = resp.StatusCode
+	return delay
+}
+
+func (t *Throttler) gaveUp(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hosts[host].gaveUp++
+}
+
+// HostSummary describes throttling of requests to a single registry host.
+type HostSummary struct {
+	Host string
+	// Events is the number of throttled responses.
+	Events int
+	// GaveUp is the number of throttled responses that were returned as is, because their requests could not be repeated
+	// or were throttled for longer than MaxWait.
+	GaveUp int
+	// Paused is the total time requests to the host were paused for.
+	Paused time.Duration
+	// LastStatus is HTTP status of the last throttled response.
+	LastStatus int
+}
+
+func (s HostSummary) String() string {
+	summary := fmt.Sprintf("%s throttled %d requests with HTTP %d, paused for %s in total", s.Host, s.Events, s.LastStatus, s.Paused.Round(time.Second))
+	if s.GaveUp > 0 {
+		summary += fmt.Sprintf(", %d of them were not repeated", s.GaveUp)
+	}
+	return summary
+}
+
+// Summary returns throttling of every host that throttled any requests, sorted by host.
+func (t *Throttler) Summary() []HostSummary {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make([]HostSummary, 0, len(t.hosts))
+	for host, state := range t.hosts {
+		result = append(result, HostSummary{
+			Host:       host,
+			Events:     state.events,
+			GaveUp:     state.gaveUp,
+			Paused:     state.waited,
+			LastStatus: state.lastStatus,
+		})
+	}
+	slices.SortFunc(result, func(a, b HostSummary) int { return strings.Compare(a.Host, b.Host) })
+	return result
+}
+
+// isThrottled reports whether resp is 429 Too Many Requests or an error that mentions exceeded quota.
+// Body of error response is peeked into and left readable for the caller.
+func isThrottled(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden, http.StatusServiceUnavailable:
+	default:
+		return false
+	}
+
+	peeked, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(peeked), resp.Body), Closer: resp.Body}
+	if err != nil {
+		return false
+	}
+	message := strings.ToLower(string(peeked))
+	for _, quotaMessage := range quotaMessages {
+		if strings.Contains(message, quotaMessage) {
+			return true
+		}
+	}
+	return false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
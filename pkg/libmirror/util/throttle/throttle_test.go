@@ -0,0 +1,154 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package throttle
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestThrottler returns throttler with a fake clock that moves forward only when throttler sleeps.
+func newTestThrottler() (*Throttler, *[]time.Duration) {
+	t := NewThrottler()
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	waited := make([]time.Duration, 0)
+	t.now = func() time.Time { return clock }
+	t.sleep = func(_ context.Context, d time.Duration) error {
+		waited = append(waited, d)
+		clock = clock.Add(d)
+		return nil
+	}
+	return t, &waited
+}
+
+func TestTransportRepeatsThrottledRequests(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 3:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"DENIED","message":"Quota exceeded for project"}]}`))
+		default:
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	throttler, waited := newTestThrottler()
+	client := &http.Client{Transport: &transport{throttler: throttler, base: http.DefaultTransport}}
+
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "payload", string(body), "replayable body must be sent again")
+	require.Equal(t, []time.Duration{time.Second, 7 * time.Second, 4 * time.Second}, *waited,
+		"delay doubles with every throttled response unless Retry-After is given")
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	require.Equal(t, []HostSummary{{
+		Host:       serverURL.Host,
+		Events:     3,
+		Paused:     12 * time.Second,
+		LastStatus: http.StatusForbidden,
+	}}, throttler.Summary())
+}
+
+func TestTransportPausesHostAndDecaysDelay(t *testing.T) {
+	throttled := atomic.Bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if throttled.Load() {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	throttler, waited := newTestThrottler()
+	host := strings.TrimPrefix(server.URL, "http://")
+	for i := 0; i < 3; i++ {
+		throttler.throttled(host, &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}})
+	}
+	require.Equal(t, 4*time.Second, throttler.hosts[host].delay)
+
+	client := &http.Client{Transport: &transport{throttler: throttler, base: http.DefaultTransport}}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, []time.Duration{4 * time.Second}, *waited, "requests to throttled host must wait for its pause to end")
+	require.Equal(t, 2*time.Second, throttler.hosts[host].delay, "delay must decay after successful request")
+}
+
+func TestTransportReturnsThrottledResponseOfNotRepeatableRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("rate limit exceeded"))
+	}))
+	defer server.Close()
+
+	throttler, waited := newTestThrottler()
+	client := &http.Client{Transport: &transport{throttler: throttler, base: http.DefaultTransport}}
+
+	req, err := http.NewRequest(http.MethodPatch, server.URL, io.NopCloser(strings.NewReader("layer")))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "rate limit exceeded", string(body), "peeked body must be left readable")
+	require.Empty(t, *waited)
+	require.Equal(t, 1, throttler.Summary()[0].GaveUp)
+}
+
+func TestTransportIgnoresOtherErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors":[{"code":"DENIED","message":"requested access to the resource is denied"}]}`))
+	}))
+	defer server.Close()
+
+	throttler, _ := newTestThrottler()
+	client := &http.Client{Transport: &transport{throttler: throttler, base: http.DefaultTransport}}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.Empty(t, throttler.Summary())
+}
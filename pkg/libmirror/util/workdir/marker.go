@@ -0,0 +1,72 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workdir
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// MarkerFile is written into every working directory, so that directories left by failed or killed runs
+// can be told apart from foreign files and removed with d8 mirror clean.
+const MarkerFile = ".d8-mirror-workdir"
+
+// Marker tells which process created the directory or file it marks.
+type Marker struct {
+	PID       int       `json:"pid"`
+	Hostname  string    `json:"hostname"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Resumable is set for working directories that next run can resume work from, see Manager.CreateResumable.
+	Resumable bool `json:"resumable,omitempty"`
+}
+
+// WriteMarker writes marker of the current process to markerPath.
+func WriteMarker(markerPath string, resumable bool) error {
+	hostname, _ := os.Hostname()
+	rawMarker, err := json.Marshal(&Marker{PID: os.Getpid(), Hostname: hostname, CreatedAt: time.Now(), Resumable: resumable})
+	if err != nil {
+		return fmt.Errorf("marshal marker: %w", err)
+	}
+	if err = os.WriteFile(markerPath, rawMarker, 0o644); err != nil {
+		return fmt.Errorf("write marker: %w", err)
+	}
+	return nil
+}
+
+// ReadMarker reads marker written with WriteMarker.
+func ReadMarker(markerPath string) (*Marker, error) {
+	rawMarker, err := os.ReadFile(markerPath)
+	if err != nil {
+		return nil, fmt.Errorf("read marker: %w", err)
+	}
+	marker := &Marker{}
+	if err = json.Unmarshal(rawMarker, marker); err != nil {
+		return nil, fmt.Errorf("parse marker %s: %w", markerPath, err)
+	}
+	return marker, nil
+}
+
+// InUse reports whether process that wrote the marker may still be running.
+// Markers written on other hosts, e.g. to shared storage, are always considered in use.
+func (m *Marker) InUse() bool {
+	if hostname, _ := os.Hostname(); hostname != m.Hostname {
+		return true
+	}
+	return m.PID == os.Getpid() || processRunning(m.PID)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package workdir manages lifecycle of temporary working directories used by mirroring commands:
+// creation, size accounting and cleanup on completion, on error or on interrupt.
+package workdir
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
+)
+
+var ErrInterrupted = errors.New("interrupted")
+
+// Manager owns working directories created under a single root directory.
+type Manager struct {
+	root   string
+	keep   bool
+	logger contexts.Logger
+
+	mu   sync.Mutex
+	dirs []*Dir
+}
+
+// Dir is a working directory created by Manager.
+type Dir struct {
+	Path string
+
+	// Resumable directories are kept if command fails, so that next run can pick up from where this one stopped.
+	resumable bool
+}
+
+// NewManager creates manager for working directories under root. If keep is set, directories are never removed.
+func NewManager(root string, keep bool, logger contexts.Logger) *Manager {
+	return &Manager{root: root, keep: keep, logger: logger}
+}
+
+// Create creates working directory at path relative to manager root.
+func (m *Manager) Create(path string) (*Dir, error) {
+	return m.create(path, false)
+}
+
+// CreateResumable creates working directory that is kept on failure for subsequent runs to resume work.
+func (m *Manager) CreateResumable(path string) (*Dir, error) {
+	return m.create(path, true)
+}
+
+func (m *Manager) create(path string, resumable bool) (*Dir, error) {
+	dir := &Dir{Path: filepath.Join(m.root, path), resumable: resumable}
+	if err := os.MkdirAll(dir.Path, 0o755); err != nil {
+		return nil, fmt.Errorf("create working directory: %w", err)
+	}
+	if err := WriteMarker(filepath.Join(dir.Path, MarkerFile), resumable); err != nil {
+		return nil, fmt.Errorf("create working directory: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dirs = append(m.dirs, dir)
+	return dir, nil
+}
+
+// Size returns total size of files in all working directories.
+func (m *Manager) Size() (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	total := int64(0)
+	for _, dir := range m.dirs {
+		size, err := dirSize(dir.Path)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
+
+func dirSize(path string) (int64, error) {
+	size := int64(0)
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() || d.Name() == MarkerFile {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("calculate size of %s: %w", path, err)
+	}
+	return size, nil
+}
+
+// Cleanup removes working directories after command completed with runErr.
+// Resumable directories are kept if runErr is not nil, all directories are kept if manager was asked to keep them.
+func (m *Manager) Cleanup(runErr error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.keep {
+		for _, dir := range m.dirs {
+			size, _ := dirSize(dir.Path)
+			m.logger.InfoF("Working directory %s is kept (%.1f MiB)", dir.Path, float64(size)/1024/1024)
+		}
+		return nil
+	}
+
+	errs := make([]error, 0)
+	for _, dir := range m.dirs {
+		if runErr != nil && dir.resumable {
+			m.logger.DebugF("Keeping working directory %s to resume from it later", dir.Path)
+			continue
+		}
+		if err := os.RemoveAll(dir.Path); err != nil {
+			errs = append(errs, fmt.Errorf("remove working directory: %w", err))
+		}
+	}
+	m.dirs = nil
+
+	// Root is only removed if nothing else is left in it, it may be shared with other runs.
+	if err := os.Remove(m.root); err != nil && !errors.Is(err, fs.ErrNotExist) && !isDirNotEmpty(err) {
+		errs = append(errs, fmt.Errorf("remove working directory: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+func isDirNotEmpty(err error) bool {
+	return errors.Is(err, syscall.ENOTEMPTY) || errors.Is(err, syscall.EEXIST)
+}
+
+// CleanupOnInterrupt makes manager clean up working directories and terminate the process on SIGINT or SIGTERM.
+// Returned function stops signal handling and should be called once command is done.
+func (m *Manager) CleanupOnInterrupt() func() {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			m.logger.WarnF("Received %s, cleaning up working directories", sig)
+			if err := m.Cleanup(ErrInterrupted); err != nil {
+				m.logger.WarnLn(err)
+			}
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
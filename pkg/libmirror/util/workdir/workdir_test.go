@@ -0,0 +1,63 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workdir
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/log"
+)
+
+func TestManagerCleanup(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "mirror")
+	manager := NewManager(root, false, log.NewSLogger(slog.LevelDebug))
+
+	resumable, err := manager.CreateResumable("pull")
+	require.NoError(t, err)
+	regular, err := manager.Create("unpack")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(regular.Path, "blob"), make([]byte, 1024), 0o644))
+
+	size, err := manager.Size()
+	require.NoError(t, err)
+	require.Equal(t, int64(1024), size)
+
+	require.NoError(t, manager.Cleanup(errors.New("pull failed")))
+	require.DirExists(t, resumable.Path, "Resumable directory should be kept on failure")
+	require.NoDirExists(t, regular.Path, "Regular directory should be removed on failure")
+
+	manager = NewManager(root, false, log.NewSLogger(slog.LevelDebug))
+	_, err = manager.CreateResumable("pull")
+	require.NoError(t, err)
+	require.NoError(t, manager.Cleanup(nil))
+	require.NoDirExists(t, root, "Everything should be removed on success")
+}
+
+func TestManagerKeepsDirectories(t *testing.T) {
+	manager := NewManager(t.TempDir(), true, log.NewSLogger(slog.LevelDebug))
+	dir, err := manager.Create("unpack")
+	require.NoError(t, err)
+
+	require.NoError(t, manager.Cleanup(nil))
+	require.DirExists(t, dir.Path)
+}
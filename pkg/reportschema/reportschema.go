@@ -0,0 +1,97 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package reportschema versions JSON reports that d8 writes for downstream automation.
+//
+// Every report is a JSON object that starts with "kind" and "schemaVersion" fields, see Header.
+// Timestamps in reports are RFC3339 strings with zone offset, see Timestamp.
+// Within the same schema version fields are only added, never renamed, removed or changed in meaning,
+// so consumers must ignore unknown fields. Any incompatible change increments the schema version
+// of that kind of report, which consumers should check before reading the rest of it.
+package reportschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type Kind string
+
+const (
+	// KindComparisonReport is written by "d8 mirror compare -o json", see compare.ComparisonReport.
+	KindComparisonReport Kind = "ComparisonReport"
+	// KindBundleDoctorReport is written by "d8 mirror doctor-bundle -o json", see bundle.DoctorReport.
+	KindBundleDoctorReport Kind = "BundleDoctorReport"
+	// KindVersionsPlan is written by "d8 mirror pull --explain-versions", see releases.VersionsPlan.
+	KindVersionsPlan Kind = "VersionsPlan"
+	// KindTargetReadinessReport is written by "d8 mirror init-target", see operations.TargetReadinessReport.
+	KindTargetReadinessReport Kind = "TargetReadinessReport"
+	// KindBundleInspection is written by "d8 mirror bundle inspect -o json", see bundle.Inspection.
+	KindBundleInspection Kind = "BundleInspection"
+	// KindTargetCheckReport is written by "d8 mirror check-target -o json", see operations.TargetCheckReport.
+	KindTargetCheckReport Kind = "TargetCheckReport"
+	// KindRunSummary is posted to --notify-url by "d8 mirror pull", "push" and "sync", see notify.Summary.
+	KindRunSummary Kind = "RunSummary"
+)
+
+// Versions are current schema versions of every kind of report.
+var Versions = map[Kind]int{
+	KindComparisonReport:      1,
+	KindBundleDoctorReport:    1,
+	KindVersionsPlan:          1,
+	KindTargetReadinessReport: 1,
+	KindBundleInspection:      1,
+	KindTargetCheckReport:     1,
+	KindRunSummary:            1,
+}
+
+// Header is embedded into every report, so that its fields are written first.
+type Header struct {
+	Kind          Kind   `json:"kind"`
+	SchemaVersion int    `json:"schemaVersion"`
+	GeneratedAt   string `json:"generatedAt,omitempty"`
+}
+
+// NewHeader returns header of report of the kind with its current schema version.
+func NewHeader(kind Kind) Header {
+	version, found := Versions[kind]
+	if !found {
+		panic(fmt.Sprintf("unknown report kind %q", kind))
+	}
+	return Header{Kind: kind, SchemaVersion: version, GeneratedAt: Timestamp(time.Now())}
+}
+
+// ReadHeader reads header of the raw report and checks that it is the report of expected kind
+// with schema version this build of d8 understands. Reports written before schemas were versioned
+// have no header and are read as the first version of expected kind.
+func ReadHeader(raw []byte, expected Kind) (Header, error) {
+	header := Header{}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return Header{}, fmt.Errorf("read report header: %w", err)
+	}
+	if header.Kind == "" && header.SchemaVersion == 0 {
+		header = Header{Kind: expected, SchemaVersion: 1}
+	}
+
+	if header.Kind != expected {
+		return Header{}, fmt.Errorf("expected %s report, got %s", expected, header.Kind)
+	}
+	if supported := Versions[expected]; header.SchemaVersion < 1 || header.SchemaVersion > supported {
+		return Header{}, fmt.Errorf("unsupported %s schema version %d, expected 1 to %d", expected, header.SchemaVersion, supported)
+	}
+	return header, nil
+}
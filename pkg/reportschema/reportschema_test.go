@@ -0,0 +1,64 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reportschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderIsWrittenFirst(t *testing.T) {
+	report := struct {
+		Header
+		Ready bool `json:"ready"`
+	}{
+		Header: Header{Kind: KindTargetReadinessReport, SchemaVersion: 1, GeneratedAt: "2024-10-01T12:30:00Z"},
+		Ready:  true,
+	}
+
+	raw, err := json.Marshal(report)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"kind":"TargetReadinessReport","schemaVersion":1,"generatedAt":"2024-10-01T12:30:00Z","ready":true}`, string(raw))
+	require.Regexp(t, `^\{"kind":"TargetReadinessReport","schemaVersion":1,`, string(raw))
+}
+
+func TestReadHeader(t *testing.T) {
+	header, err := ReadHeader([]byte(`{"kind":"ComparisonReport","schemaVersion":1,"source":"a"}`), KindComparisonReport)
+	require.NoError(t, err)
+	require.Equal(t, Header{Kind: KindComparisonReport, SchemaVersion: 1}, header)
+
+	header, err = ReadHeader([]byte(`{"source":"a"}`), KindComparisonReport)
+	require.NoError(t, err, "reports written before schemas were versioned must be read as the first version")
+	require.Equal(t, Header{Kind: KindComparisonReport, SchemaVersion: 1}, header)
+
+	_, err = ReadHeader([]byte(`{"kind":"VersionsPlan","schemaVersion":1}`), KindComparisonReport)
+	require.ErrorContains(t, err, "expected ComparisonReport report")
+
+	_, err = ReadHeader([]byte(`{"kind":"ComparisonReport","schemaVersion":2}`), KindComparisonReport)
+	require.ErrorContains(t, err, "unsupported ComparisonReport schema version 2")
+
+	_, err = ReadHeader([]byte(`[]`), KindComparisonReport)
+	require.Error(t, err)
+}
+
+func TestEveryKindHasVersion(t *testing.T) {
+	for _, kind := range []Kind{KindComparisonReport, KindBundleDoctorReport, KindVersionsPlan, KindTargetReadinessReport} {
+		require.NotPanics(t, func() { NewHeader(kind) }, kind)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reportschema
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+var location atomic.Pointer[time.Location]
+
+// SetLocation sets time zone that report timestamps are rendered in, UTC by default.
+func SetLocation(loc *time.Location) {
+	location.Store(loc)
+}
+
+// Location returns time zone that report timestamps are rendered in.
+func Location() *time.Location {
+	if loc := location.Load(); loc != nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// Timestamp formats t for reports as RFC3339 with zone offset.
+func Timestamp(t time.Time) string {
+	return t.In(Location()).Format(time.RFC3339)
+}
+
+// Timing is the time span of the operation that produced the report.
+type Timing struct {
+	StartedAt  string `json:"startedAt"`
+	FinishedAt string `json:"finishedAt"`
+	// DurationSeconds is measured with monotonic clock, so wall clock adjustments during the operation do not affect it.
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// TimingSince returns Timing of the operation that started at start and finishes now.
+func TimingSince(start time.Time) Timing {
+	now := time.Now()
+	return Timing{
+		StartedAt:       Timestamp(start),
+		FinishedAt:      Timestamp(now),
+		DurationSeconds: now.Sub(start).Seconds(),
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reportschema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestamp(t *testing.T) {
+	moment := time.Date(2024, 10, 1, 12, 30, 0, 0, time.UTC)
+	require.Equal(t, "2024-10-01T12:30:00Z", Timestamp(moment))
+
+	berlin := time.FixedZone("CEST", 2*60*60)
+	SetLocation(berlin)
+	defer SetLocation(nil)
+	require.Equal(t, "2024-10-01T14:30:00+02:00", Timestamp(moment))
+}
+
+func TestTimingSince(t *testing.T) {
+	start := time.Now().Add(-1500 * time.Millisecond)
+	timing := TimingSince(start)
+	require.InDelta(t, 1.5, timing.DurationSeconds, 0.5)
+
+	startedAt, err := time.Parse(time.RFC3339, timing.StartedAt)
+	require.NoError(t, err)
+	finishedAt, err := time.Parse(time.RFC3339, timing.FinishedAt)
+	require.NoError(t, err)
+	require.False(t, finishedAt.Before(startedAt))
+}
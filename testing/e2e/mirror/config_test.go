@@ -0,0 +1,144 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mirror
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+
+	mirrorTestUtils "github.com/deckhouse/deckhouse-cli/testing/util/mirror"
+)
+
+// Full-cycle test runs against in-memory registry by default.
+// To run it against real registry implementations, start them with compose.yaml next to this file and select them by name:
+//
+//	docker compose -f testing/e2e/mirror/compose.yaml up -d
+//	go test ./testing/e2e/mirror -run TestMirrorE2E -e2e.targets=in-memory,distribution,zot
+//
+// Harbor is not a single container, it should be installed with its own installer and selected with -e2e.targets=harbor.
+var (
+	targetsFlag = flag.String("e2e.targets", targetInMemory,
+		"Comma-separated list of target registry implementations to run full-cycle mirror test against: "+strings.Join(knownTargets, ", "))
+
+	distributionHostFlag = flag.String("e2e.distribution-host", "localhost:5000", "Address of CNCF Distribution registry")
+	zotHostFlag          = flag.String("e2e.zot-host", "localhost:5050", "Address of zot registry")
+
+	harborHostFlag     = flag.String("e2e.harbor-host", "localhost:8080", "Address of Harbor registry")
+	harborProjectFlag  = flag.String("e2e.harbor-project", "library", "Harbor project to push into")
+	harborUserFlag     = flag.String("e2e.harbor-user", "admin", "Harbor user")
+	harborPasswordFlag = flag.String("e2e.harbor-password", "Harbor12345", "Harbor user password")
+)
+
+const (
+	targetInMemory     = "in-memory"
+	targetDistribution = "distribution"
+	targetHarbor       = "harbor"
+	targetZot          = "zot"
+)
+
+var knownTargets = []string{targetInMemory, targetDistribution, targetHarbor, targetZot}
+
+// Config selects target registry implementations for full-cycle mirror test.
+type Config struct {
+	Targets []TargetRegistry
+}
+
+// TargetRegistry describes registry implementation that mirror pushes bundle to, along with its behaviors that affect push.
+type TargetRegistry struct {
+	Name     string
+	Host     string
+	Path     string
+	Auth     authn.Authenticator
+	Insecure bool
+
+	// OCIOnly registries reject Docker media types, so source images for them are built with OCI media types.
+	OCIOnly bool
+	// SupportsBlobMounts registries can mount blobs from another repository of the same registry instead of uploading them.
+	SupportsBlobMounts bool
+
+	// BlobHandler is set only for in-memory registry and lists blobs that were read from it.
+	BlobHandler *mirrorTestUtils.ListableBlobHandler
+}
+
+func (r TargetRegistry) Repo() string {
+	return r.Host + r.Path
+}
+
+// LoadConfig builds configuration from test flags.
+// External registries are shared between runs, so each run pushes into its own unique repository path.
+func LoadConfig(t *testing.T) Config {
+	t.Helper()
+
+	runPath := fmt.Sprintf("/e2e-%08x", rand.Uint32())
+	cfg := Config{}
+	for _, name := range strings.Split(*targetsFlag, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case targetInMemory:
+			host, repoPath, blobHandler := mirrorTestUtils.SetupEmptyRegistryRepo(false)
+			cfg.Targets = append(cfg.Targets, TargetRegistry{
+				Name:        name,
+				Host:        host,
+				Path:        repoPath,
+				Auth:        authn.Anonymous,
+				Insecure:    true,
+				BlobHandler: blobHandler,
+			})
+		case targetDistribution:
+			cfg.Targets = append(cfg.Targets, TargetRegistry{
+				Name:               name,
+				Host:               *distributionHostFlag,
+				Path:               runPath,
+				Auth:               authn.Anonymous,
+				Insecure:           true,
+				SupportsBlobMounts: true,
+			})
+		case targetHarbor:
+			cfg.Targets = append(cfg.Targets, TargetRegistry{
+				Name:               name,
+				Host:               *harborHostFlag,
+				Path:               "/" + *harborProjectFlag + runPath,
+				Auth:               authn.FromConfig(authn.AuthConfig{Username: *harborUserFlag, Password: *harborPasswordFlag}),
+				Insecure:           true,
+				SupportsBlobMounts: true,
+			})
+		case targetZot:
+			cfg.Targets = append(cfg.Targets, TargetRegistry{
+				Name:     name,
+				Host:     *zotHostFlag,
+				Path:     runPath,
+				Auth:     authn.Anonymous,
+				Insecure: true,
+				OCIOnly:  true,
+			})
+		default:
+			t.Fatalf("Unknown target registry %q in -e2e.targets, known are: %s", name, strings.Join(knownTargets, ", "))
+		}
+	}
+
+	if len(cfg.Targets) == 0 {
+		t.Fatal("No target registries selected with -e2e.targets")
+	}
+	return cfg
+}
@@ -17,17 +17,19 @@ limitations under the License.
 package mirror
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"math/rand"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/Masterminds/semver/v3"
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -35,10 +37,12 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/stretchr/testify/require"
 	"sigs.k8s.io/yaml"
 
 	"github.com/deckhouse/deckhouse-cli/internal/mirror/cmd/pull"
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/compare"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/contexts"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/operations"
 	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
@@ -47,6 +51,15 @@ import (
 )
 
 func TestMirrorE2E(t *testing.T) {
+	cfg := LoadConfig(t)
+	for _, target := range cfg.Targets {
+		t.Run(target.Name, func(t *testing.T) {
+			testMirrorFullCycle(t, target)
+		})
+	}
+}
+
+func testMirrorFullCycle(t *testing.T, target TargetRegistry) {
 	tmpDir, err := os.MkdirTemp(os.TempDir(), "mirror_e2e")
 	require.NoError(t, err)
 	t.Cleanup(func() {
@@ -55,11 +68,11 @@ func TestMirrorE2E(t *testing.T) {
 	workingDir := filepath.Join(tmpDir, "pull")
 
 	sourceHost, sourceRepoPath, sourceBlobHandler := mirrorTestUtils.SetupEmptyRegistryRepo(false)
-	targetHost, targetRepoPath, targetBlobHandler := mirrorTestUtils.SetupEmptyRegistryRepo(false)
 
-	createDeckhouseControllersAndInstallersInRegistry(t, sourceHost+sourceRepoPath)
-	createTrivyVulnerabilityDatabasesInRegistry(t, sourceHost+sourceRepoPath, true, false)
-	createDeckhouseReleaseChannelsInRegistry(t, sourceHost+sourceRepoPath)
+	fixture := &sourceFixture{t: t, repo: sourceHost + sourceRepoPath, ociMediaTypes: target.OCIOnly}
+	fixture.createDeckhouseControllersAndInstallers()
+	fixture.createTrivyVulnerabilityDatabases()
+	fixture.createDeckhouseReleaseChannels()
 
 	testLogger := log.NewSLogger(slog.LevelDebug)
 	pullCtx := &contexts.PullContext{
@@ -74,10 +87,11 @@ func TestMirrorE2E(t *testing.T) {
 	pushCtx := &contexts.PushContext{
 		BaseContext: contexts.BaseContext{
 			Logger:                testLogger,
-			Insecure:              true,
+			Insecure:              target.Insecure,
+			RegistryAuth:          target.Auth,
 			DeckhouseRegistryRepo: sourceHost + sourceRepoPath,
-			RegistryHost:          targetHost,
-			RegistryPath:          targetRepoPath,
+			RegistryHost:          target.Host,
+			RegistryPath:          target.Path,
 			UnpackedImagesPath:    workingDir,
 		},
 
@@ -88,7 +102,7 @@ func TestMirrorE2E(t *testing.T) {
 		*semver.MustParse("v1.56.5"),
 		*semver.MustParse("v1.55.7"),
 	}
-	err = pull.PullDeckhouseToLocalFS(pullCtx, versionsToPull)
+	err = pull.PullDeckhouseToLocalFS(pullCtx, versionsToPull, nil)
 	require.NoError(t, err, "Pull should be completed without errors")
 	validateDeckhouseReleasesManifests(t, pullCtx, versionsToPull)
 	for _, layoutName := range []string{"", "install", "release-channel"} {
@@ -99,57 +113,130 @@ func TestMirrorE2E(t *testing.T) {
 	err = operations.PushDeckhouseToRegistry(pushCtx)
 	require.NoError(t, err, "Push should be completed without errors")
 
-	require.Subset(t, sourceBlobHandler.ListBlobs(), targetBlobHandler.ListBlobs())
+	if target.BlobHandler != nil {
+		require.Subset(t, sourceBlobHandler.ListBlobs(), target.BlobHandler.ListBlobs())
+	}
+
+	report, err := compare.NewRegistryComparator(
+		compare.OCILayoutScheme+workingDir,
+		target.Repo(),
+		compare.ComparatorOptions{Insecure: target.Insecure, TargetAuth: target.Auth, Deep: true, FailOnExtra: true},
+	).Compare(context.Background())
+	require.NoError(t, err, "Comparison of bundle with target registry should be completed without errors")
+	if report.TargetCapabilities != nil {
+		for _, line := range report.TargetCapabilities.Matrix() {
+			t.Log(line)
+		}
+	}
+	require.NotZero(t, report.ComparedImages)
+	require.True(t, report.IsConsistent(), "Target registry should contain everything from bundle and nothing else: %s", report.Summary())
+	require.Empty(t, report.UndiscoveredRepositories, "Every repository pushed to target registry should be compared")
+
+	validateBlobMounts(t, target)
 }
 
-func createDeckhouseReleaseChannelsInRegistry(t *testing.T, repo string) {
+// validateBlobMounts copies pushed installer into another repository of the target registry
+// and checks that registries supporting blob mounts do not receive any blob uploads for it.
+func validateBlobMounts(t *testing.T, target TargetRegistry) {
 	t.Helper()
 
-	createDeckhouseReleaseChannelImageInRegistry(t, repo+"/release-channel", "alpha", "v1.56.5")
-	createDeckhouseReleaseChannelImageInRegistry(t, repo+"/release-channel", "beta", "v1.56.5")
-	createDeckhouseReleaseChannelImageInRegistry(t, repo+"/release-channel", "early-access", "v1.55.7")
-	createDeckhouseReleaseChannelImageInRegistry(t, repo+"/release-channel", "stable", "v1.55.7")
-	createDeckhouseReleaseChannelImageInRegistry(t, repo+"/release-channel", "rock-solid", "v1.55.7")
-	createDeckhouseReleaseChannelImageInRegistry(t, repo+"/release-channel", "v1.55.7", "v1.55.7")
-	createDeckhouseReleaseChannelImageInRegistry(t, repo+"/release-channel", "v1.56.5", "v1.56.5")
+	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptions(target.Auth, target.Insecure, false)
+	srcRef, err := name.ParseReference(target.Repo()+"/install:stable", nameOpts...)
+	require.NoError(t, err)
+	dstRef, err := name.ParseReference(target.Repo()+"/install-mount-check:stable", nameOpts...)
+	require.NoError(t, err)
+
+	img, err := remote.Image(srcRef, remoteOpts...)
+	require.NoError(t, err)
+
+	counter := &blobUploadsCounter{base: remote.DefaultTransport}
+	require.NoError(t, remote.Write(dstRef, img, append(remoteOpts, remote.WithTransport(counter))...))
+	if target.SupportsBlobMounts {
+		require.NotZero(t, counter.mounts.Load(), "Blobs should be mounted from another repository")
+		require.Zero(t, counter.uploads.Load(), "Blobs should not be uploaded when they can be mounted")
+	}
+}
+
+// blobUploadsCounter counts blobs mounted from another repository and blob upload chunks sent to the registry.
+type blobUploadsCounter struct {
+	base    http.RoundTripper
+	mounts  atomic.Int64
+	uploads atomic.Int64
 }
 
-func createTrivyVulnerabilityDatabasesInRegistry(t *testing.T, repo string, insecure, useTLS bool) {
-	t.Helper()
-	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptions(authn.Anonymous, insecure, useTLS)
+func (c *blobUploadsCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.Method == http.MethodPost && req.URL.Query().Has("mount") && resp.StatusCode == http.StatusCreated:
+		c.mounts.Add(1)
+	case req.Method == http.MethodPatch && strings.Contains(req.URL.Path, "/blobs/uploads/"):
+		c.uploads.Add(1)
+	}
+	return resp, nil
+}
+
+// sourceFixture fills source registry with synthetic Deckhouse images.
+type sourceFixture struct {
+	t    *testing.T
+	repo string
+
+	// ociMediaTypes makes fixture images use OCI media types instead of Docker ones for OCI-only target registries.
+	ociMediaTypes bool
+}
+
+func (f *sourceFixture) createDeckhouseReleaseChannels() {
+	f.t.Helper()
+
+	f.createDeckhouseReleaseChannelImage(f.repo+"/release-channel", "alpha", "v1.56.5")
+	f.createDeckhouseReleaseChannelImage(f.repo+"/release-channel", "beta", "v1.56.5")
+	f.createDeckhouseReleaseChannelImage(f.repo+"/release-channel", "early-access", "v1.55.7")
+	f.createDeckhouseReleaseChannelImage(f.repo+"/release-channel", "stable", "v1.55.7")
+	f.createDeckhouseReleaseChannelImage(f.repo+"/release-channel", "rock-solid", "v1.55.7")
+	f.createDeckhouseReleaseChannelImage(f.repo+"/release-channel", "v1.55.7", "v1.55.7")
+	f.createDeckhouseReleaseChannelImage(f.repo+"/release-channel", "v1.56.5", "v1.56.5")
+}
+
+func (f *sourceFixture) createTrivyVulnerabilityDatabases() {
+	f.t.Helper()
 
 	images := []string{
-		repo + "/security/trivy-db:2",
-		repo + "/security/trivy-bdu:1",
-		repo + "/security/trivy-java-db:1",
-		repo + "/security/trivy-checks:0",
+		f.repo + "/security/trivy-db:2",
+		f.repo + "/security/trivy-bdu:1",
+		f.repo + "/security/trivy-java-db:1",
+		f.repo + "/security/trivy-checks:0",
 	}
 
 	for _, image := range images {
-		ref, err := name.ParseReference(image, nameOpts...)
-		require.NoError(t, err)
 		wantImage, err := random.Image(256, 1)
-		require.NoError(t, err)
-		require.NoError(t, remote.Write(ref, wantImage, remoteOpts...))
+		require.NoError(f.t, err)
+		f.write(image, wantImage)
 	}
 }
 
-func createDeckhouseControllersAndInstallersInRegistry(t *testing.T, repo string) {
-	t.Helper()
+func (f *sourceFixture) createDeckhouseControllersAndInstallers() {
+	f.t.Helper()
 
-	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptions(nil, true, false)
-
-	createRandomImageInRegistry(t, repo+":alpha")
-	createRandomImageInRegistry(t, repo+":beta")
-	createRandomImageInRegistry(t, repo+":early-access")
-	createRandomImageInRegistry(t, repo+":stable")
-	createRandomImageInRegistry(t, repo+":rock-solid")
-	createRandomImageInRegistry(t, repo+":v1.56.5")
-	createRandomImageInRegistry(t, repo+":v1.55.7")
+	controllers := map[string]v1.Image{
+		"v1.56.5": f.randomImage(),
+		"v1.55.7": f.randomImage(),
+	}
+	controllers["alpha"] = controllers["v1.56.5"]
+	controllers["beta"] = controllers["v1.56.5"]
+	controllers["early-access"] = controllers["v1.55.7"]
+	controllers["stable"] = controllers["v1.55.7"]
+	controllers["rock-solid"] = controllers["v1.55.7"]
+
+	for shortTag, controller := range controllers {
+		f.write(f.repo+":"+shortTag, controller)
+	}
 
 	installers := map[string]v1.Image{
-		"v1.56.5": createSyntheticInstallerImage(t, "v1.56.5", repo),
-		"v1.55.7": createSyntheticInstallerImage(t, "v1.55.7", repo),
+		"v1.56.5": f.syntheticInstallerImage("v1.56.5"),
+		"v1.55.7": f.syntheticInstallerImage("v1.55.7"),
 	}
 	installers["alpha"] = installers["v1.56.5"]
 	installers["beta"] = installers["v1.56.5"]
@@ -158,22 +245,13 @@ func createDeckhouseControllersAndInstallersInRegistry(t *testing.T, repo string
 	installers["rock-solid"] = installers["v1.55.7"]
 
 	for shortTag, installer := range installers {
-		ref, err := name.ParseReference(repo+"/install:"+shortTag, nameOpts...)
-		require.NoError(t, err)
-
-		err = remote.Write(ref, installer, remoteOpts...)
-		require.NoError(t, err)
-
-		ref, err = name.ParseReference(repo+"/install-standalone:"+shortTag, nameOpts...)
-		require.NoError(t, err)
-
-		err = remote.Write(ref, installer, remoteOpts...)
-		require.NoError(t, err)
+		f.write(f.repo+"/install:"+shortTag, installer)
+		f.write(f.repo+"/install-standalone:"+shortTag, installer)
 	}
 }
 
-func createSyntheticInstallerImage(t *testing.T, version, repo string) v1.Image {
-	t.Helper()
+func (f *sourceFixture) syntheticInstallerImage(version string) v1.Image {
+	f.t.Helper()
 
 	// FROM scratch
 	base := empty.Image
@@ -184,53 +262,46 @@ func createSyntheticInstallerImage(t *testing.T, version, repo string) v1.Image
 	imagesDigests, err := json.Marshal(
 		map[string]map[string]string{
 			"common": {
-				"alpine": createRandomImageInRegistry(t, repo+":alpine"+version),
+				"alpine": f.createRandomImage(f.repo + ":alpine" + version),
 			},
 			"nodeManager": {
-				"bashibleApiserver": createRandomImageInRegistry(t, repo+":bashibleApiserver"+version),
+				"bashibleApiserver": f.createRandomImage(f.repo + ":bashibleApiserver" + version),
 			},
 		})
-	require.NoError(t, err)
+	require.NoError(f.t, err)
 	l, err := crane.Layer(map[string][]byte{
 		"deckhouse/version":                   []byte(version),
 		"deckhouse/candi/images_digests.json": imagesDigests,
 	})
-	require.NoError(t, err)
+	require.NoError(f.t, err)
 	layers = append(layers, l)
 
 	img, err := mutate.AppendLayers(base, layers...)
-	require.NoError(t, err)
+	require.NoError(f.t, err)
 
 	// ENTRYPOINT ["/bin/bash"]
 	img, err = mutate.Config(img, v1.Config{
 		Entrypoint: []string{"/bin/bash"},
 	})
-	require.NoError(t, err)
+	require.NoError(f.t, err)
 
-	return img
+	return f.withMediaTypes(img)
 }
 
-func createRandomImageInRegistry(t *testing.T, tag string) (digest string) {
-	t.Helper()
-
+func (f *sourceFixture) randomImage() v1.Image {
+	f.t.Helper()
 	img, err := random.Image(int64(rand.Intn(1024)+1), int64(rand.Intn(5)+1))
-	require.NoError(t, err)
-
-	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptions(nil, true, false)
-	ref, err := name.ParseReference(tag, nameOpts...)
-	require.NoError(t, err)
-
-	err = remote.Write(ref, img, remoteOpts...)
-	require.NoError(t, err)
-
-	digestHash, err := img.Digest()
-	require.NoError(t, err)
+	require.NoError(f.t, err)
+	return img
+}
 
-	return digestHash.String()
+func (f *sourceFixture) createRandomImage(tag string) (digest string) {
+	f.t.Helper()
+	return f.write(tag, f.randomImage())
 }
 
-func createDeckhouseReleaseChannelImageInRegistry(t *testing.T, repo, tag, version string) (digest string) {
-	t.Helper()
+func (f *sourceFixture) createDeckhouseReleaseChannelImage(repo, tag, version string) (digest string) {
+	f.t.Helper()
 
 	// FROM scratch
 	base := empty.Image
@@ -238,7 +309,7 @@ func createDeckhouseReleaseChannelImageInRegistry(t *testing.T, repo, tag, versi
 
 	// COPY ./version.json /version.json
 	changelog, err := yaml.JSONToYAML([]byte(`{"candi":{"fixes":[{"summary":"Fix deckhouse containerd start after installing new containerd-deckhouse package.","pull_request":"https://github.com/deckhouse/deckhouse/pull/6329"}]}}`))
-	require.NoError(t, err)
+	require.NoError(f.t, err)
 	versionInfo := fmt.Sprintf(
 		`{"disruptions":{"1.56":["ingressNginx"]},"requirements":{"containerdOnAllNodes":"true","ingressNginx":"1.1","k8s":"1.23.0","nodesMinimalOSVersionUbuntu":"18.04"},"version":%q}`,
 		"v"+version,
@@ -247,24 +318,61 @@ func createDeckhouseReleaseChannelImageInRegistry(t *testing.T, repo, tag, versi
 		"version.json":   []byte(versionInfo),
 		"changelog.yaml": changelog,
 	})
+	require.NoError(f.t, err)
 	layers = append(layers, l)
 
 	img, err := mutate.AppendLayers(base, layers...)
-	require.NoError(t, err)
+	require.NoError(f.t, err)
 
-	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptions(nil, true, false)
-	ref, err := name.ParseReference(repo+":"+tag, nameOpts...)
-	require.NoError(t, err)
+	return f.write(repo+":"+tag, img)
+}
 
-	err = remote.Write(ref, img, remoteOpts...)
-	require.NoError(t, err)
+// write pushes image to the source registry with fixture media types and returns digest of pushed image.
+func (f *sourceFixture) write(imageRef string, img v1.Image) (digest string) {
+	f.t.Helper()
 
-	digestHash, err := img.Digest()
-	require.NoError(t, err)
+	img = f.withMediaTypes(img)
+	nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptions(nil, true, false)
+	ref, err := name.ParseReference(imageRef, nameOpts...)
+	require.NoError(f.t, err)
+	require.NoError(f.t, remote.Write(ref, img, remoteOpts...))
 
+	digestHash, err := img.Digest()
+	require.NoError(f.t, err)
 	return digestHash.String()
 }
 
+// withMediaTypes rebuilds image with OCI media types if fixture requires them, images are returned as is otherwise.
+func (f *sourceFixture) withMediaTypes(img v1.Image) v1.Image {
+	f.t.Helper()
+
+	if !f.ociMediaTypes {
+		return img
+	}
+	mediaType, err := img.MediaType()
+	require.NoError(f.t, err)
+	if mediaType == types.OCIManifestSchema1 {
+		return img
+	}
+
+	layers, err := img.Layers()
+	require.NoError(f.t, err)
+	configFile, err := img.ConfigFile()
+	require.NoError(f.t, err)
+
+	base := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON)
+	base, err = mutate.Config(base, configFile.Config)
+	require.NoError(f.t, err)
+
+	addenda := make([]mutate.Addendum, 0, len(layers))
+	for _, layer := range layers {
+		addenda = append(addenda, mutate.Addendum{Layer: layer, MediaType: types.OCILayer})
+	}
+	ociImage, err := mutate.Append(base, addenda...)
+	require.NoError(f.t, err)
+	return ociImage
+}
+
 func validateDeckhouseReleasesManifests(t *testing.T, pullCtx *contexts.PullContext, versions []semver.Version) {
 	t.Helper()
 	deckhouseReleasesManifestsFilepath := filepath.Join(filepath.Dir(pullCtx.BundlePath), "deckhousereleases.yaml")
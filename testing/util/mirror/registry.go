@@ -2,14 +2,21 @@ package mirror
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io"
 	golog "log"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"sync"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type ListableBlobHandler struct {
@@ -33,6 +40,89 @@ func (h *ListableBlobHandler) ListBlobs() []string {
 }
 
 func SetupEmptyRegistryRepo(useTLS bool) (host, repoPath string, blobHandler *ListableBlobHandler) {
+	reg := SetupTestRegistry(WithTLS(useTLS))
+	return reg.Host, reg.RepoPath, reg.BlobHandler
+}
+
+// TestRegistry is an in-memory registry for tests, optionally serving over TLS and requiring authentication.
+type TestRegistry struct {
+	Host        string
+	RepoPath    string
+	BlobHandler *ListableBlobHandler
+	Server      *httptest.Server
+
+	// Auth holds credentials accepted by registry, it is authn.Anonymous if registry does not require authentication.
+	Auth authn.Authenticator
+}
+
+type testRegistryOptions struct {
+	useTLS    bool
+	authMode  testRegistryAuthMode
+	htpasswd  map[string][]byte
+	authCreds authn.Authenticator
+}
+
+type testRegistryAuthMode int
+
+const (
+	noAuth testRegistryAuthMode = iota
+	basicAuth
+	tokenAuth
+)
+
+const testRegistryTokenPath = "/token"
+
+// WithTLS makes registry serve HTTPS with self-signed certificate.
+func WithTLS(useTLS bool) func(opts *testRegistryOptions) {
+	return func(opts *testRegistryOptions) {
+		opts.useTLS = useTLS
+	}
+}
+
+// WithBasicAuth makes registry require HTTP basic authentication checked against htpasswd-style bcrypt hashes.
+func WithBasicAuth(username, password string) func(opts *testRegistryOptions) {
+	return func(opts *testRegistryOptions) {
+		opts.authMode = basicAuth
+		opts.addUser(username, password)
+	}
+}
+
+// WithTokenAuth makes registry require bearer tokens issued by token server at /token in exchange for basic credentials,
+// the same way Docker Distribution, Harbor and most of the cloud registries do.
+func WithTokenAuth(username, password string) func(opts *testRegistryOptions) {
+	return func(opts *testRegistryOptions) {
+		opts.authMode = tokenAuth
+		opts.addUser(username, password)
+	}
+}
+
+func (o *testRegistryOptions) addUser(username, password string) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	if o.htpasswd == nil {
+		o.htpasswd = make(map[string][]byte)
+	}
+	o.htpasswd[username] = hash
+	o.authCreds = authn.FromConfig(authn.AuthConfig{Username: username, Password: password})
+}
+
+func (o *testRegistryOptions) checkCredentials(r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	hash, found := o.htpasswd[username]
+	return found && bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+func SetupTestRegistry(opts ...func(opts *testRegistryOptions)) *TestRegistry {
+	options := &testRegistryOptions{authCreds: authn.Anonymous}
+	for _, o := range opts {
+		o(options)
+	}
+
 	memBlobHandler := registry.NewInMemoryBlobHandler()
 	bh := &ListableBlobHandler{
 		BlobHandler:    memBlobHandler,
@@ -40,18 +130,67 @@ func SetupEmptyRegistryRepo(useTLS bool) (host, repoPath string, blobHandler *Li
 	}
 	registryHandler := registry.New(registry.WithBlobHandler(bh), registry.Logger(golog.New(io.Discard, "", 0)))
 
-	server := httptest.NewUnstartedServer(registryHandler)
-	if useTLS {
+	server := httptest.NewUnstartedServer(nil)
+	server.Config.Handler = withAuth(registryHandler, options, server)
+	if options.useTLS {
 		server.StartTLS()
 	} else {
 		server.Start()
 	}
 
-	host = strings.TrimPrefix(server.URL, "http://")
-	repoPath = "/deckhouse/ee"
-	if useTLS {
+	host := strings.TrimPrefix(server.URL, "http://")
+	if options.useTLS {
 		host = strings.TrimPrefix(server.URL, "https://")
 	}
 
-	return host, repoPath, bh
+	return &TestRegistry{
+		Host:        host,
+		RepoPath:    "/deckhouse/ee",
+		BlobHandler: bh,
+		Server:      server,
+		Auth:        options.authCreds,
+	}
+}
+
+func withAuth(next http.Handler, opts *testRegistryOptions, server *httptest.Server) http.Handler {
+	switch opts.authMode {
+	case basicAuth:
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !opts.checkCredentials(r) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="test-registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	case tokenAuth:
+		issuedTokens := &sync.Map{}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == testRegistryTokenPath {
+				if !opts.checkCredentials(r) {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				token := make([]byte, 16)
+				_, _ = rand.Read(token)
+				encodedToken := hex.EncodeToString(token)
+				issuedTokens.Store(encodedToken, struct{}{})
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]string{"token": encodedToken})
+				return
+			}
+
+			token, hasToken := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if _, valid := issuedTokens.Load(token); !hasToken || !valid {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+					`Bearer realm="%s%s",service="test-registry"`, server.URL, testRegistryTokenPath,
+				))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	default:
+		return next
+	}
 }
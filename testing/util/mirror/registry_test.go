@@ -0,0 +1,52 @@
+package mirror
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deckhouse/deckhouse-cli/pkg/libmirror/util/auth"
+)
+
+func TestSetupTestRegistryAuthModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		useTLS   bool
+		authOpts []func(opts *testRegistryOptions)
+	}{
+		{name: "plain HTTP without auth"},
+		{name: "TLS without auth", useTLS: true},
+		{name: "basic auth", authOpts: []func(opts *testRegistryOptions){WithBasicAuth("user", "pass")}},
+		{name: "token auth over TLS", useTLS: true, authOpts: []func(opts *testRegistryOptions){WithTokenAuth("user", "pass")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := SetupTestRegistry(append(tt.authOpts, WithTLS(tt.useTLS))...)
+			defer reg.Server.Close()
+
+			img, err := random.Image(64, 1)
+			require.NoError(t, err)
+
+			nameOpts, remoteOpts := auth.MakeRemoteRegistryRequestOptions(reg.Auth, !tt.useTLS, tt.useTLS)
+			ref, err := name.ParseReference(reg.Host+reg.RepoPath+":latest", nameOpts...)
+			require.NoError(t, err)
+			require.NoError(t, remote.Write(ref, img, remoteOpts...), "Push with valid credentials should succeed")
+
+			_, remoteOpts = auth.MakeRemoteRegistryRequestOptions(authn.FromConfig(authn.AuthConfig{
+				Username: "user",
+				Password: "wrong",
+			}), !tt.useTLS, tt.useTLS)
+			_, err = remote.Head(ref, remoteOpts...)
+			if len(tt.authOpts) == 0 {
+				require.NoError(t, err, "Registry without auth should accept any credentials")
+			} else {
+				require.Error(t, err, "Registry should reject invalid credentials")
+			}
+		})
+	}
+}
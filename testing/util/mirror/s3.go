@@ -0,0 +1,126 @@
+/*
+Copyright 2024 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mirror
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TestS3AccessKeyID is the only access key accepted by S3 started with SetupTestS3.
+const TestS3AccessKeyID = "d8-mirror-test"
+
+// SetupTestS3 starts server that implements just enough of S3 API to store bundles:
+// single and multipart uploads, ranged reads and listing of objects.
+// Requests are only checked to be signed with TestS3AccessKeyID, signatures are not verified.
+func SetupTestS3() *httptest.Server {
+	fake := &fakeS3{objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	return httptest.NewServer(fake)
+}
+
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+TestS3AccessKeyID+"/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, bucket, query.Get("prefix"))
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[uploadID] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", uploadID)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		f.uploads[query.Get("uploadId")][partNumber] = body
+		w.Header().Set("ETag", strconv.Quote(query.Get("partNumber")))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		completed := struct {
+			Parts []struct {
+				PartNumber int `xml:"PartNumber"`
+			} `xml:"Part"`
+		}{}
+		if err = xml.Unmarshal(body, &completed); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		object := make([]byte, 0)
+		for _, part := range completed.Parts {
+			object = append(object, f.uploads[query.Get("uploadId")][part.PartNumber]...)
+		}
+		f.objects[bucket+"/"+key] = object
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[bucket+"/"+key] = body
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, found := f.objects[bucket+"/"+key]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+		http.ServeContent(w, r, key, time.Time{}, bytes.NewReader(object))
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, bucket, prefix string) {
+	keys := make([]string, 0)
+	for objectKey := range f.objects {
+		if strings.HasPrefix(objectKey, bucket+"/"+prefix) {
+			keys = append(keys, objectKey)
+		}
+	}
+	sort.Strings(keys)
+
+	fmt.Fprint(w, "<ListBucketResult>")
+	for _, objectKey := range keys {
+		fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", strings.TrimPrefix(objectKey, bucket+"/"), len(f.objects[objectKey]))
+	}
+	fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+}